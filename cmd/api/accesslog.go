@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"proofpix/internal/auth"
+)
+
+// redactedHeaders are logged with their values replaced
+var redactedHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+}
+
+// accessLogger writes one JSON line per request. Tests replace it to capture output.
+var accessLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// accessLogKey is the context key holding the in-flight accessLogEntry
+type accessLogKey struct{}
+
+// accessLogEntry collects fields that are only known inside the route, such as the user ID
+type accessLogEntry struct {
+	userID string
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack supports connection upgrades through the recorder
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// accessLog wraps a handler and logs method, route, path, status, latency and user ID for every request
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry))
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		// ServeMux records the matched pattern on the request it routed
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		accessLogger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			slog.Int("bytes", recorder.bytes),
+			slog.String("user_id", entry.userID),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.Any("headers", redactHeaders(r.Header)),
+		)
+	})
+}
+
+// recordUser copies the authenticated user ID into the access log entry.
+// It runs inside the auth middleware, where the user ID is on the request context.
+func recordUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(accessLogKey{}).(*accessLogEntry); ok {
+			if userID, ok := auth.GetUserID(r); ok {
+				entry.userID = userID
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// redactHeaders returns the request headers with credentials replaced
+func redactHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[name] {
+			redacted[name] = "[REDACTED]"
+			continue
+		}
+		if len(values) > 0 {
+			redacted[name] = values[0]
+		}
+	}
+	return redacted
+}
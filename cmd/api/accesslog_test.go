@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// captureAccessLog redirects the access logger to a buffer for the duration of the test
+func captureAccessLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := accessLogger
+	accessLogger = slog.New(slog.NewJSONHandler(&buf, nil))
+	t.Cleanup(func() { accessLogger = orig })
+	return &buf
+}
+
+func TestAccessLog_RecordsRequest(t *testing.T) {
+	buf := captureAccessLog(t)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /slow/{id}", recordUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})))
+
+	req := withUser(httptest.NewRequest(http.MethodGet, "/slow/asset-1", nil), "user-1")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("User-Agent", "proofpix-test")
+	rec := httptest.NewRecorder()
+	accessLog(mux).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("Expected status %d, but got %d", http.StatusTeapot, rec.Code)
+	}
+
+	var entry struct {
+		Method    string            `json:"method"`
+		Route     string            `json:"route"`
+		Path      string            `json:"path"`
+		Status    int               `json:"status"`
+		LatencyMS int64             `json:"latency_ms"`
+		Bytes     int               `json:"bytes"`
+		UserID    string            `json:"user_id"`
+		Headers   map[string]string `json:"headers"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON log line, but got %q: %v", buf.String(), err)
+	}
+
+	if entry.Method != http.MethodGet || entry.Route != "GET /slow/{id}" || entry.Path != "/slow/asset-1" {
+		t.Errorf("Expected GET /slow/{id} for /slow/asset-1, but got %s %s for %s", entry.Method, entry.Route, entry.Path)
+	}
+	if entry.Status != http.StatusTeapot {
+		t.Errorf("Expected logged status %d, but got %d", http.StatusTeapot, entry.Status)
+	}
+	if entry.LatencyMS < 20 {
+		t.Errorf("Expected latency of at least 20ms, but got %dms", entry.LatencyMS)
+	}
+	if entry.Bytes != len("short and stout") {
+		t.Errorf("Expected %d bytes, but got %d", len("short and stout"), entry.Bytes)
+	}
+	if entry.UserID != "user-1" {
+		t.Errorf("Expected user_id user-1, but got %q", entry.UserID)
+	}
+	if entry.Headers["Authorization"] != "[REDACTED]" {
+		t.Errorf("Expected Authorization to be redacted, but got %q", entry.Headers["Authorization"])
+	}
+	if bytes.Contains(buf.Bytes(), []byte("secret-token")) {
+		t.Errorf("Expected token to be absent from the log, but got %s", buf.String())
+	}
+	if entry.Headers["User-Agent"] != "proofpix-test" {
+		t.Errorf("Expected User-Agent to be logged, but got %q", entry.Headers["User-Agent"])
+	}
+}
+
+func TestAccessLog_UnmatchedRoute(t *testing.T) {
+	buf := captureAccessLog(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/does-not-exist", nil)
+	rec := httptest.NewRecorder()
+	accessLog(http.NewServeMux()).ServeHTTP(rec, req)
+
+	var entry struct {
+		Route  string `json:"route"`
+		Status int    `json:"status"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON log line, but got %q: %v", buf.String(), err)
+	}
+	if entry.Status != http.StatusNotFound || entry.Route != "unmatched" {
+		t.Errorf("Expected unmatched 404, but got %q %d", entry.Route, entry.Status)
+	}
+}
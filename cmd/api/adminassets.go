@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"proofpix/internal/auth"
+)
+
+// Page sizes for the admin asset listing
+const (
+	defaultAssetPageSize = 50
+	maxAssetPageSize     = 200
+)
+
+// ErrInvalidPageToken is returned when a listing page token cannot be decoded
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// AssetFilter selects the assets returned by ListAssets. Zero values leave a
+// criterion unset; CreatedBefore is exclusive.
+type AssetFilter struct {
+	Status        string
+	UserID        string
+	MinScore      *int
+	MaxScore      *int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// MetadataKey and MetadataValue match assets whose metadata holds exactly that pair
+	MetadataKey   string
+	MetadataValue string
+	// Anchored keeps only assets whose certificate has a leaf index in the log
+	Anchored bool
+	// ExcludeDeleted leaves out soft-deleted assets unless Status asks for them
+	ExcludeDeleted bool
+	Limit          int
+	PageToken      string
+}
+
+// Matches reports whether the asset satisfies every criterion of the filter
+func (f AssetFilter) Matches(asset *Asset) bool {
+	if f.Status != "" && asset.Status != f.Status {
+		return false
+	}
+	if f.UserID != "" && asset.UserID != f.UserID {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && asset.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !asset.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if f.MetadataKey != "" {
+		if value, ok := asset.Metadata[f.MetadataKey]; !ok || value != f.MetadataValue {
+			return false
+		}
+	}
+	return f.matchesUnqueried(asset)
+}
+
+// matchesUnqueried applies the criteria the Firestore query cannot: the score range,
+// anchoring and the exclusion of deleted assets
+func (f AssetFilter) matchesUnqueried(asset *Asset) bool {
+	if f.ExcludeDeleted && f.Status == "" && asset.IsDeleted() {
+		return false
+	}
+	if f.Anchored && asset.TrillianLeafIndex == 0 {
+		return false
+	}
+	if f.MinScore != nil && asset.OriginalityScore < *f.MinScore {
+		return false
+	}
+	if f.MaxScore != nil && asset.OriginalityScore > *f.MaxScore {
+		return false
+	}
+	return true
+}
+
+// pageSize returns the filter's limit, bounded to the allowed page sizes
+func (f AssetFilter) pageSize() int {
+	if f.Limit <= 0 {
+		return defaultAssetPageSize
+	}
+	if f.Limit > maxAssetPageSize {
+		return maxAssetPageSize
+	}
+	return f.Limit
+}
+
+// encodePageToken returns an opaque token resuming a listing after the asset.
+// Listings are ordered by creation time then ID, both descending.
+func encodePageToken(asset *Asset) string {
+	raw := strconv.FormatInt(asset.CreatedAt.UnixNano(), 10) + ":" + asset.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodePageToken returns the creation time and ID of the last asset of the previous page
+func decodePageToken(token string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	return time.Unix(0, unixNano).UTC(), id, nil
+}
+
+// adminAssetView is the subset of an asset shown in asset listings. Embeddings
+// and raw model output are left out.
+type adminAssetView struct {
+	ID               string            `json:"id"`
+	UserID           string            `json:"user_id"`
+	Status           string            `json:"status"`
+	CreatedAt        time.Time         `json:"created_at"`
+	OriginalityScore int               `json:"originality_score"`
+	Narrative        string            `json:"narrative,omitempty"`
+	ModelVersion     string            `json:"model_version,omitempty"`
+	AnalysisWarning  string            `json:"analysis_warning,omitempty"`
+	Logged           bool              `json:"logged"`
+	DeletedAt        *time.Time        `json:"deleted_at,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+func newAdminAssetView(asset *Asset) adminAssetView {
+	view := adminAssetView{
+		ID:               asset.ID,
+		UserID:           asset.UserID,
+		Status:           asset.Status,
+		CreatedAt:        asset.CreatedAt,
+		OriginalityScore: asset.OriginalityScore,
+		Narrative:        asset.Narrative,
+		ModelVersion:     asset.ModelVersion,
+		AnalysisWarning:  asset.AnalysisWarning,
+		Logged:           asset.TrillianLeafIndex != 0,
+		Metadata:         asset.Metadata,
+	}
+	if !asset.DeletedAt.IsZero() {
+		deletedAt := asset.DeletedAt
+		view.DeletedAt = &deletedAt
+	}
+	return view
+}
+
+// handleAdminListAssets lists assets across all users for moderation.
+// Route: GET /api/v1/admin/assets
+// Query parameters: status, user_id, tag (key:value), min_score, max_score,
+// created_after and created_before (RFC 3339), limit and page_token.
+func handleAdminListAssets(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Admin role required")
+		return
+	}
+
+	filter, fieldErr := parseAssetFilter(r)
+	if fieldErr != nil {
+		respondValidationError(w, "Invalid query parameters", *fieldErr)
+		return
+	}
+
+	assets, nextPageToken, err := repo.ListAssets(r.Context(), filter)
+	if errors.Is(err, ErrInvalidPageToken) {
+		respondValidationError(w, "Invalid query parameters", FieldError{Field: "page_token", Message: "is not a valid page token"})
+		return
+	}
+	if errors.Is(err, ErrTagFilterNotIndexed) {
+		respondValidationError(w, "Invalid query parameters", FieldError{Field: "tag", Message: "key is not indexed for filtering"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to list assets: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to list assets")
+		return
+	}
+
+	views := make([]adminAssetView, 0, len(assets))
+	for _, asset := range assets {
+		views = append(views, newAdminAssetView(asset))
+	}
+	userID, _ := auth.GetUserID(r)
+	log.Printf("Admin %s listed %d assets", userID, len(views))
+
+	data := map[string]interface{}{"assets": views}
+	if nextPageToken != "" {
+		data["next_page_token"] = nextPageToken
+	}
+	respondJSON(w, http.StatusOK, Response{Success: true, Message: "Assets retrieved successfully", Data: data})
+}
+
+// parseAssetFilter reads the listing filter from the query string, reporting the
+// first invalid parameter
+func parseAssetFilter(r *http.Request) (AssetFilter, *FieldError) {
+	query := r.URL.Query()
+	filter := AssetFilter{
+		Status:    query.Get("status"),
+		UserID:    query.Get("user_id"),
+		PageToken: query.Get("page_token"),
+	}
+
+	var fieldErr *FieldError
+	if filter.MinScore, fieldErr = scoreParam(query, "min_score"); fieldErr != nil {
+		return filter, fieldErr
+	}
+	if filter.MaxScore, fieldErr = scoreParam(query, "max_score"); fieldErr != nil {
+		return filter, fieldErr
+	}
+	if filter.MinScore != nil && filter.MaxScore != nil && *filter.MinScore > *filter.MaxScore {
+		return filter, &FieldError{Field: "min_score", Message: "must not exceed max_score"}
+	}
+	if filter.CreatedAfter, fieldErr = timeParam(query, "created_after"); fieldErr != nil {
+		return filter, fieldErr
+	}
+	if filter.CreatedBefore, fieldErr = timeParam(query, "created_before"); fieldErr != nil {
+		return filter, fieldErr
+	}
+	if tag := query.Get("tag"); tag != "" {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok || key == "" || value == "" {
+			return filter, &FieldError{Field: "tag", Message: "must be a metadata key and value as key:value"}
+		}
+		filter.MetadataKey, filter.MetadataValue = key, value
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAssetPageSize {
+			return filter, &FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxAssetPageSize)}
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+func scoreParam(query url.Values, name string) (*int, *FieldError) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	score, err := strconv.Atoi(value)
+	if err != nil || score < 0 || score > 100 {
+		return nil, &FieldError{Field: name, Message: "must be an integer between 0 and 100"}
+	}
+	return &score, nil
+}
+
+func timeParam(query url.Values, name string) (time.Time, *FieldError) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, &FieldError{Field: name, Message: "must be an RFC 3339 timestamp"}
+	}
+	return t, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+type adminListBody struct {
+	Data struct {
+		Assets        []map[string]interface{} `json:"assets"`
+		NextPageToken string                   `json:"next_page_token"`
+	} `json:"data"`
+}
+
+func adminListAssets(t *testing.T, query string, admin bool) (*httptest.ResponseRecorder, adminListBody) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/assets?"+query, nil)
+	if admin {
+		req = withAdmin(req, "admin-1")
+	} else {
+		req = withUser(req, "user-1")
+	}
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	var body adminListBody
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec, body
+}
+
+func assetIDs(body adminListBody) string {
+	ids := []string{}
+	for _, asset := range body.Data.Assets {
+		ids = append(ids, asset["id"].(string))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestHandleAdminListAssets_Filters(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"a1": {ID: "a1", UserID: "alice", Status: models.StatusCompleted, OriginalityScore: 90, CreatedAt: day(1), Embedding: []float32{0.1}, RawAnalysis: "raw"},
+		"a2": {ID: "a2", UserID: "alice", Status: models.StatusPartial, OriginalityScore: 40, CreatedAt: day(2)},
+		"b1": {ID: "b1", UserID: "bob", Status: models.StatusCompleted, OriginalityScore: 15, CreatedAt: day(3)},
+		"b2": {ID: "b2", UserID: "bob", Status: models.StatusDeleted, OriginalityScore: 70, CreatedAt: day(4), DeletedAt: day(5)},
+	}})
+
+	testCases := []struct {
+		name         string
+		query        url.Values
+		admin        bool
+		expectedCode int
+		expectedIDs  string
+	}{
+		{name: "No filters, newest first", admin: true, expectedCode: http.StatusOK, expectedIDs: "b2,b1,a2,a1"},
+		{name: "Status", query: url.Values{"status": {"completed"}}, admin: true, expectedCode: http.StatusOK, expectedIDs: "b1,a1"},
+		{name: "User", query: url.Values{"user_id": {"alice"}}, admin: true, expectedCode: http.StatusOK, expectedIDs: "a2,a1"},
+		{name: "Score range", query: url.Values{"min_score": {"30"}, "max_score": {"80"}}, admin: true, expectedCode: http.StatusOK, expectedIDs: "b2,a2"},
+		{name: "Date range", query: url.Values{"created_after": {"2024-03-02T00:00:00Z"}, "created_before": {"2024-03-04T00:00:00Z"}}, admin: true, expectedCode: http.StatusOK, expectedIDs: "b1,a2"},
+		{name: "Combined", query: url.Values{"user_id": {"bob"}, "max_score": {"50"}}, admin: true, expectedCode: http.StatusOK, expectedIDs: "b1"},
+		{name: "Inverted score range", query: url.Values{"min_score": {"80"}, "max_score": {"20"}}, admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Malformed date", query: url.Values{"created_after": {"yesterday"}}, admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Malformed page token", query: url.Values{"page_token": {"!!"}}, admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Not an admin", expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, body := adminListAssets(t, tc.query.Encode(), tc.admin)
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if got := assetIDs(body); got != tc.expectedIDs {
+				t.Errorf("Expected assets %q, but got %q", tc.expectedIDs, got)
+			}
+		})
+	}
+
+	t.Run("Safe fields only", func(t *testing.T) {
+		_, body := adminListAssets(t, "user_id=alice", true)
+		for _, asset := range body.Data.Assets {
+			for _, field := range []string{"embedding", "raw_analysis", "Embedding", "RawAnalysis"} {
+				if _, ok := asset[field]; ok {
+					t.Errorf("Expected %s to be left out, but got %v", field, asset)
+				}
+			}
+		}
+	})
+}
+
+func TestHandleAdminListAssets_Pagination(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	assets := map[string]*Asset{}
+	// Five assets sharing a timestamp so the ID breaks ties, and one older asset
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		assets[id] = &Asset{ID: id, UserID: "alice", Status: models.StatusCompleted, CreatedAt: createdAt}
+	}
+	assets["old"] = &Asset{ID: "old", UserID: "alice", Status: models.StatusCompleted, CreatedAt: createdAt.Add(-time.Hour)}
+	useFakeRepository(t, &fakeRepository{assets: assets})
+
+	var pages []string
+	token := ""
+	for i := 0; i < 5; i++ {
+		query := url.Values{"limit": {"2"}}
+		if token != "" {
+			query.Set("page_token", token)
+		}
+		rec, body := adminListAssets(t, query.Encode(), true)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, but got %d: %s", rec.Code, rec.Body.String())
+		}
+		pages = append(pages, assetIDs(body))
+		token = body.Data.NextPageToken
+		if token == "" {
+			break
+		}
+	}
+
+	expected := []string{"e,d", "c,b", "a,old"}
+	if strings.Join(pages, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected pages %v, but got %v", expected, pages)
+	}
+}
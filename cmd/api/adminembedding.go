@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/models"
+)
+
+// handleAdminAssetEmbedding returns an asset's stored embedding so similarity results
+// can be debugged. Embeddings are left out of every other response; this route is
+// for admins only and is never cached.
+// Route: GET /api/v1/admin/assets/{id}/embedding
+func handleAdminAssetEmbedding(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Admin role required")
+		return
+	}
+
+	assetID := r.PathValue("id")
+	asset, err := repo.GetAsset(r.Context(), assetID)
+	if errors.Is(err, ErrAssetNotFound) {
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+		return
+	}
+	embedding := asset.EmbeddingVector()
+	if len(embedding) == 0 {
+		respondError(w, http.StatusNotFound, "Asset has no stored embedding")
+		return
+	}
+
+	var sum float64
+	for _, value := range embedding {
+		sum += float64(value) * float64(value)
+	}
+	// An embedding stored at half precision is returned dequantized
+	precision := models.EmbeddingPrecisionFloat32
+	if len(asset.Embedding) == 0 {
+		precision = models.EmbeddingPrecisionFloat16
+	}
+	userID, _ := auth.GetUserID(r)
+	log.Printf("Admin %s read the embedding of asset %s", userID, assetID)
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Embedding retrieved successfully",
+		Data: map[string]interface{}{
+			"asset_id":        asset.ID,
+			"status":          asset.Status,
+			"embedding_model": asset.EmbeddingModel,
+			"precision":       precision,
+			"dimension":       len(embedding),
+			"norm":            math.Sqrt(sum),
+			"embedding":       embedding,
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"proofpix/internal/float16"
+	"proofpix/internal/models"
+)
+
+func TestHandleAdminAssetEmbedding(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"asset-1":  {ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, Embedding: []float32{3, 0, 4}, EmbeddingModel: "multimodalembedding@001"},
+		"half":     {ID: "half", UserID: "owner", Status: models.StatusCompleted, EmbeddingFloat16: float16.Encode([]float32{3, 0, 4}), EmbeddingModel: "multimodalembedding@001"},
+		"no-embed": {ID: "no-embed", UserID: "owner", Status: models.StatusPartial, EmbeddingFailed: true},
+	}})
+
+	testCases := []struct {
+		name         string
+		request      func(*http.Request) *http.Request
+		assetID      string
+		expectedCode int
+		// expectedPrecision is the stored precision reported with the embedding
+		expectedPrecision string
+	}{
+		{name: "Admin", request: func(r *http.Request) *http.Request { return withAdmin(r, "admin") }, assetID: "asset-1", expectedCode: http.StatusOK, expectedPrecision: models.EmbeddingPrecisionFloat32},
+		{name: "Stored at half precision", request: func(r *http.Request) *http.Request { return withAdmin(r, "admin") }, assetID: "half", expectedCode: http.StatusOK, expectedPrecision: models.EmbeddingPrecisionFloat16},
+		{name: "Owner without the admin role", request: func(r *http.Request) *http.Request { return withUser(r, "owner") }, assetID: "asset-1", expectedCode: http.StatusForbidden},
+		{name: "Anonymous", request: func(r *http.Request) *http.Request { return r }, assetID: "asset-1", expectedCode: http.StatusUnauthorized},
+		{name: "Asset without an embedding", request: func(r *http.Request) *http.Request { return withAdmin(r, "admin") }, assetID: "no-embed", expectedCode: http.StatusNotFound},
+		{name: "Unknown asset", request: func(r *http.Request) *http.Request { return withAdmin(r, "admin") }, assetID: "missing", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := tc.request(httptest.NewRequest(http.MethodGet, "/api/v1/admin/assets/"+tc.assetID+"/embedding", nil))
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				if strings.Contains(rec.Body.String(), `"embedding":`) {
+					t.Errorf("Expected no embedding in the error response, but got %s", rec.Body.String())
+				}
+				return
+			}
+
+			var body struct {
+				Data struct {
+					Embedding      []float32 `json:"embedding"`
+					Dimension      int       `json:"dimension"`
+					Norm           float64   `json:"norm"`
+					EmbeddingModel string    `json:"embedding_model"`
+					Precision      string    `json:"precision"`
+				} `json:"data"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if len(body.Data.Embedding) != 3 || body.Data.Embedding[0] != 3 || body.Data.Embedding[2] != 4 {
+				t.Errorf("Expected embedding [3 0 4], but got %v", body.Data.Embedding)
+			}
+			if body.Data.Dimension != 3 || body.Data.Norm != 5 {
+				t.Errorf("Expected dimension 3 and norm 5, but got %d and %v", body.Data.Dimension, body.Data.Norm)
+			}
+			if body.Data.EmbeddingModel != "multimodalembedding@001" {
+				t.Errorf("Expected embedding model multimodalembedding@001, but got %q", body.Data.EmbeddingModel)
+			}
+			if body.Data.Precision != tc.expectedPrecision {
+				t.Errorf("Expected precision %q, but got %q", tc.expectedPrecision, body.Data.Precision)
+			}
+			if cache := rec.Header().Get("Cache-Control"); cache != "no-store" {
+				t.Errorf("Expected Cache-Control no-store, but got %q", cache)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+
+	"proofpix/internal/auth"
+)
+
+// leafLog is the subset of the Trillian log client used to read single leaves
+type leafLog interface {
+	logRootReader
+	GetLeavesByRange(ctx context.Context, in *trillian.GetLeavesByRangeRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByRangeResponse, error)
+}
+
+// openLeafLog connects to the Trillian log server. Tests replace it with a mock client.
+var openLeafLog = func(ctx context.Context) (leafLog, func(), error) {
+	conn, err := dialLogServer(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return trillian.NewTrillianLogClient(conn), func() { closeLogServer(conn) }, nil
+}
+
+// handleAdminLogLeaf returns the raw leaf stored at an index of the log, with its
+// hashes and timestamps, for debugging anchoring problems. The index must be below
+// the current tree size.
+// Route: GET /api/v1/admin/log/leaf?index=N
+func handleAdminLogLeaf(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Admin role required")
+		return
+	}
+
+	index, err := strconv.ParseInt(r.URL.Query().Get("index"), 10, 64)
+	if err != nil || index < 0 {
+		respondValidationError(w, "Invalid query parameters", FieldError{Field: "index", Message: "must be a non-negative integer"})
+		return
+	}
+
+	logID, err := strconv.ParseInt(os.Getenv("TRILLIAN_LOG_ID"), 10, 64)
+	if err != nil {
+		log.Printf("Invalid TRILLIAN_LOG_ID: %v", err)
+		respondError(w, http.StatusInternalServerError, "Server configuration error")
+		return
+	}
+
+	ctx := r.Context()
+	client, closeLog, err := openLeafLog(ctx)
+	if err != nil {
+		log.Printf("Failed to connect to Trillian: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to connect to log")
+		return
+	}
+	defer closeLog()
+
+	root, err := latestLogRoot(ctx, client, logID)
+	if err != nil {
+		log.Printf("Failed to get latest log root: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve log root")
+		return
+	}
+	if uint64(index) >= root.TreeSize {
+		respondValidationError(w, "Invalid query parameters", FieldError{Field: "index", Message: fmt.Sprintf("must be below the tree size of %d", root.TreeSize)})
+		return
+	}
+
+	response, err := client.GetLeavesByRange(ctx, &trillian.GetLeavesByRangeRequest{LogId: logID, StartIndex: index, Count: 1})
+	if err != nil {
+		log.Printf("Failed to get leaf %d from Trillian log %d: %v", index, logID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve leaf")
+		return
+	}
+	if len(response.Leaves) == 0 || response.Leaves[0].LeafIndex != index {
+		respondError(w, http.StatusNotFound, "Leaf not found")
+		return
+	}
+
+	userID, _ := auth.GetUserID(r)
+	log.Printf("Admin %s read leaf %d of log %d", userID, index, logID)
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Leaf retrieved successfully",
+		Data:    newLeafView(response.Leaves[0], root.TreeSize),
+	})
+}
+
+// newLeafView describes a log leaf with its byte fields hex encoded
+func newLeafView(leaf *trillian.LogLeaf, treeSize uint64) map[string]interface{} {
+	view := map[string]interface{}{
+		"leaf_index":         leaf.LeafIndex,
+		"tree_size":          treeSize,
+		"leaf_value":         hex.EncodeToString(leaf.LeafValue),
+		"leaf_value_size":    len(leaf.LeafValue),
+		"merkle_leaf_hash":   hex.EncodeToString(leaf.MerkleLeafHash),
+		"leaf_identity_hash": hex.EncodeToString(leaf.LeafIdentityHash),
+		"extra_data":         hex.EncodeToString(leaf.ExtraData),
+	}
+	if leaf.QueueTimestamp != nil {
+		view["queued_at"] = leaf.QueueTimestamp.AsTime().Format(time.RFC3339Nano)
+	}
+	if leaf.IntegrateTimestamp != nil {
+		view["integrated_at"] = leaf.IntegrateTimestamp.AsTime().Format(time.RFC3339Nano)
+	}
+	return view
+}
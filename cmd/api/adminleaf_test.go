@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// mockLeafLog serves a fixed set of leaves from a tree of the given size
+type mockLeafLog struct {
+	treeSize uint64
+	leaves   map[int64]*trillian.LogLeaf
+}
+
+func (l *mockLeafLog) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	root, err := (&types.LogRootV1{TreeSize: l.treeSize, RootHash: make([]byte, 32)}).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: root}}, nil
+}
+
+func (l *mockLeafLog) GetLeavesByRange(ctx context.Context, in *trillian.GetLeavesByRangeRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByRangeResponse, error) {
+	response := &trillian.GetLeavesByRangeResponse{}
+	if leaf, ok := l.leaves[in.StartIndex]; ok {
+		response.Leaves = []*trillian.LogLeaf{leaf}
+	}
+	return response, nil
+}
+
+func TestHandleAdminLogLeaf(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	integrated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock := &mockLeafLog{
+		treeSize: 8,
+		leaves: map[int64]*trillian.LogLeaf{
+			3: {
+				LeafIndex:          3,
+				LeafValue:          []byte("certificate"),
+				MerkleLeafHash:     []byte{0xab, 0xcd},
+				LeafIdentityHash:   []byte{0x01},
+				QueueTimestamp:     timestamppb.New(integrated.Add(-time.Minute)),
+				IntegrateTimestamp: timestamppb.New(integrated),
+			},
+		},
+	}
+	orig := openLeafLog
+	t.Cleanup(func() { openLeafLog = orig })
+	openLeafLog = func(ctx context.Context) (leafLog, func(), error) {
+		return mock, func() {}, nil
+	}
+
+	testCases := []struct {
+		name         string
+		query        string
+		admin        bool
+		expectedCode int
+	}{
+		{name: "Known leaf", query: "?index=3", admin: true, expectedCode: http.StatusOK},
+		{name: "Leaf not returned by the log", query: "?index=5", admin: true, expectedCode: http.StatusNotFound},
+		{name: "Index at the tree size", query: "?index=8", admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Negative index", query: "?index=-1", admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Missing index", query: "", admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Not an admin", query: "?index=3", expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/log/leaf"+tc.query, nil)
+			if tc.admin {
+				req = withAdmin(req, "admin-1")
+			} else {
+				req = withUser(req, "user-1")
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			expected := map[string]interface{}{
+				"leaf_index":         float64(3),
+				"tree_size":          float64(8),
+				"leaf_value":         "6365727469666963617465",
+				"merkle_leaf_hash":   "abcd",
+				"leaf_identity_hash": "01",
+				"queued_at":          "2024-03-01T11:59:00Z",
+				"integrated_at":      "2024-03-01T12:00:00Z",
+			}
+			for key, value := range expected {
+				if resp.Data[key] != value {
+					t.Errorf("Expected %s to be %v, but got %v", key, value, resp.Data[key])
+				}
+			}
+		})
+	}
+}
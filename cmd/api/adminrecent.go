@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"proofpix/internal/auth"
+)
+
+// anchoredAssetView is an asset in the recently anchored listing
+type anchoredAssetView struct {
+	ID               string    `json:"id"`
+	LeafIndex        int64     `json:"leaf_index"`
+	LeafFormat       string    `json:"leaf_format,omitempty"`
+	OriginalityScore int       `json:"originality_score"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// handleAdminRecentAnchored lists the assets created since a cutoff whose
+// certificates are in the log, newest first, for transparency reports. Deleted and
+// private assets are included since their leaves stay in the log.
+// Route: GET /api/v1/admin/log/recent?since=RFC3339
+// Query parameters: since (required), limit and page_token.
+func handleAdminRecentAnchored(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Admin role required")
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("since") == "" {
+		respondValidationError(w, "Invalid query parameters", FieldError{Field: "since", Message: "is required"})
+		return
+	}
+	since, fieldErr := timeParam(query, "since")
+	if fieldErr != nil {
+		respondValidationError(w, "Invalid query parameters", *fieldErr)
+		return
+	}
+	filter := AssetFilter{CreatedAfter: since, Anchored: true, PageToken: query.Get("page_token")}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAssetPageSize {
+			respondValidationError(w, "Invalid query parameters", FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxAssetPageSize)})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	assets, nextPageToken, err := repo.ListAssets(r.Context(), filter)
+	if errors.Is(err, ErrInvalidPageToken) {
+		respondValidationError(w, "Invalid query parameters", FieldError{Field: "page_token", Message: "is not a valid page token"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to list anchored assets: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to list anchored assets")
+		return
+	}
+
+	views := make([]anchoredAssetView, 0, len(assets))
+	for _, asset := range assets {
+		views = append(views, anchoredAssetView{
+			ID:               asset.ID,
+			LeafIndex:        asset.TrillianLeafIndex,
+			LeafFormat:       asset.TrillianLeafFormat,
+			OriginalityScore: asset.OriginalityScore,
+			CreatedAt:        asset.CreatedAt,
+		})
+	}
+	userID, _ := auth.GetUserID(r)
+	log.Printf("Admin %s listed %d assets anchored since %s", userID, len(views), since.Format(time.RFC3339))
+
+	data := map[string]interface{}{"since": since, "assets": views}
+	if nextPageToken != "" {
+		data["next_page_token"] = nextPageToken
+	}
+	respondJSON(w, http.StatusOK, Response{Success: true, Message: "Anchored assets retrieved successfully", Data: data})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+type recentAnchoredBody struct {
+	Data struct {
+		Assets        []anchoredAssetView `json:"assets"`
+		NextPageToken string              `json:"next_page_token"`
+	} `json:"data"`
+}
+
+func TestHandleAdminRecentAnchored(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"old":      {ID: "old", UserID: "alice", Status: models.StatusCompleted, OriginalityScore: 90, TrillianLeafIndex: 3, CreatedAt: day(1)},
+		"recent-1": {ID: "recent-1", UserID: "alice", Status: models.StatusCompleted, OriginalityScore: 80, TrillianLeafIndex: 7, CreatedAt: day(3)},
+		"recent-2": {ID: "recent-2", UserID: "bob", Status: models.StatusDeleted, OriginalityScore: 60, TrillianLeafIndex: 9, CreatedAt: day(4), DeletedAt: day(5)},
+		"pending":  {ID: "pending", UserID: "bob", Status: models.StatusCompleted, OriginalityScore: 70, CreatedAt: day(5)},
+		"recent-3": {ID: "recent-3", UserID: "carol", Status: models.StatusCompleted, OriginalityScore: 40, TrillianLeafIndex: 12, CreatedAt: day(6)},
+	}})
+	since := day(2).Format(time.RFC3339)
+
+	testCases := []struct {
+		name         string
+		query        url.Values
+		admin        bool
+		expectedCode int
+		expectedIDs  string
+		expectMore   bool
+	}{
+		{name: "Anchored assets in the window", query: url.Values{"since": {since}}, admin: true, expectedCode: http.StatusOK, expectedIDs: "recent-3,recent-2,recent-1"},
+		{name: "Paginated", query: url.Values{"since": {since}, "limit": {"2"}}, admin: true, expectedCode: http.StatusOK, expectedIDs: "recent-3,recent-2", expectMore: true},
+		{name: "Window after every asset", query: url.Values{"since": {day(7).Format(time.RFC3339)}}, admin: true, expectedCode: http.StatusOK},
+		{name: "Missing since", query: url.Values{}, admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Invalid since", query: url.Values{"since": {"yesterday"}}, admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Invalid limit", query: url.Values{"since": {since}, "limit": {"0"}}, admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Invalid page token", query: url.Values{"since": {since}, "page_token": {"not-a-token"}}, admin: true, expectedCode: http.StatusBadRequest},
+		{name: "Not an admin", query: url.Values{"since": {since}}, expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/log/recent?"+tc.query.Encode(), nil)
+			if tc.admin {
+				req = withAdmin(req, "admin-1")
+			} else {
+				req = withUser(req, "user-1")
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var body recentAnchoredBody
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			ids := []string{}
+			for _, asset := range body.Data.Assets {
+				ids = append(ids, asset.ID)
+			}
+			if got := strings.Join(ids, ","); got != tc.expectedIDs {
+				t.Errorf("Expected assets %q, but got %q", tc.expectedIDs, got)
+			}
+			if tc.expectMore != (body.Data.NextPageToken != "") {
+				t.Errorf("Expected more pages=%t, but got token %q", tc.expectMore, body.Data.NextPageToken)
+			}
+		})
+	}
+}
+
+func TestHandleAdminRecentAnchored_FollowsPages(t *testing.T) {
+	assets := map[string]*Asset{}
+	for i, id := range []string{"a", "b", "c"} {
+		assets[id] = &Asset{ID: id, UserID: "alice", Status: models.StatusCompleted, OriginalityScore: 50 + i, TrillianLeafIndex: int64(i + 1), CreatedAt: time.Date(2024, 3, i+1, 0, 0, 0, 0, time.UTC)}
+	}
+	useFakeRepository(t, &fakeRepository{assets: assets})
+
+	var seen []anchoredAssetView
+	query := url.Values{"since": {"2024-01-01T00:00:00Z"}, "limit": {"2"}}
+	for page := 0; page < 3; page++ {
+		req := withAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/admin/log/recent?"+query.Encode(), nil), "admin-1")
+		rec := httptest.NewRecorder()
+		serve(t, rec, req)
+		var body recentAnchoredBody
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		seen = append(seen, body.Data.Assets...)
+		if body.Data.NextPageToken == "" {
+			break
+		}
+		query.Set("page_token", body.Data.NextPageToken)
+	}
+
+	if len(seen) != 3 {
+		t.Fatalf("Expected 3 assets across the pages, but got %+v", seen)
+	}
+	last := seen[2]
+	if last.ID != "a" || last.LeafIndex != 1 || last.OriginalityScore != 50 {
+		t.Errorf("Expected the oldest asset last with its leaf index and score, but got %+v", last)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// assetProofVersion identifies the layout of the inclusion proof file
+const assetProofVersion = 1
+
+// assetProof is the standalone inclusion proof file of an asset's certificate. To
+// check it, hash the leaf with hash_algorithm (or take leaf_hash as given) and fold
+// in inclusion_proof.hashes from leaf_index to reach log_root.root_hash at
+// log_root.tree_size. Byte fields are base64 encoded; unlike the proof bundle, the
+// file carries neither the credential nor the issuer key.
+type assetProof struct {
+	Version        int                  `json:"version"`
+	AssetID        string               `json:"asset_id"`
+	LogID          int64                `json:"log_id"`
+	LeafFormat     string               `json:"leaf_format"`
+	HashAlgorithm  string               `json:"hash_algorithm"`
+	LeafHash       []byte               `json:"leaf_hash"`
+	InclusionProof bundleInclusionProof `json:"inclusion_proof"`
+	LogRoot        bundleLogRoot        `json:"log_root"`
+}
+
+// handleAssetProof handles GET /api/v1/assets/{id}/proof. It returns only the
+// inclusion proof of the asset's certificate and the log root it leads to, as a JSON
+// attachment, 202 while the certificate is not yet in the log, and 404 for unknown
+// assets.
+func handleAssetProof(w http.ResponseWriter, r *http.Request) {
+	assetID := r.PathValue("id")
+	ctx := r.Context()
+
+	asset, err := repo.GetAsset(ctx, assetID)
+	if err != nil && !errors.Is(err, ErrAssetNotFound) {
+		log.Printf("Failed to fetch asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+		return
+	}
+	if err != nil || asset.IsDeleted() || !canVerifyAsset(r, asset) {
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+		return
+	}
+
+	proof, ok := fetchLoggedProof(w, ctx, asset)
+	if !ok {
+		return
+	}
+
+	logRoot, err := proof.logRoot()
+	if err != nil {
+		log.Printf("Failed to encode log root: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to encode log root")
+		return
+	}
+	leafFormat := proof.leafFormat(asset)
+	leafValue, err := proof.hasher.Value(leafFormat, proof.certData)
+	if err != nil {
+		log.Printf("Failed to rebuild leaf for asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to rebuild log leaf")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="proofpix-%s-proof.json"`, assetID))
+	w.Header().Set("Cache-Control", verifyCacheControl(asset))
+	respondJSON(w, http.StatusOK, assetProof{
+		Version:       assetProofVersion,
+		AssetID:       assetID,
+		LogID:         proof.logID,
+		LeafFormat:    leafFormat,
+		HashAlgorithm: proof.hasher.Algorithm(),
+		LeafHash:      proof.hasher.HashLeaf(leafValue),
+		InclusionProof: bundleInclusionProof{
+			LeafIndex: asset.TrillianLeafIndex,
+			TreeSize:  proof.root.TreeSize,
+			Hashes:    proof.hashes,
+		},
+		LogRoot: logRoot,
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+func TestHandleAssetProof(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	createdAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	logged := &Asset{ID: "logged", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 9, TrillianLeafIndex: 3, TrillianLeafFormat: leaf.FormatHash}
+	private := &Asset{ID: "private", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 9, TrillianLeafIndex: 3, TrillianLeafFormat: leaf.FormatHash, Visibility: models.VisibilityPrivate}
+	unlogged := &Asset{ID: "unlogged", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 9}
+	processing := &Asset{ID: "processing", UserID: "owner", Status: models.StatusProcessing, CreatedAt: createdAt}
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"logged": logged, "private": private, "unlogged": unlogged, "processing": processing,
+	}})
+
+	certificates := map[string][]byte{}
+	for _, asset := range []*Asset{logged, private, unlogged} {
+		credential, err := certificate.Generate(asset)
+		if err != nil {
+			t.Fatalf("Failed to generate certificate: %v", err)
+		}
+		certificates[asset.ID], _ = json.MarshalIndent(credential, "", "  ")
+	}
+	useFakeCertificates(t, certificates)
+
+	// A four-leaf log with the requested certificate at index 3
+	leafHash := func(assetID string) []byte {
+		value, _ := leaf.Value(leaf.FormatHash, certificates[assetID])
+		return leaf.HashLeaf(value)
+	}
+	h0, h1, h2 := leaf.HashLeaf([]byte("a")), leaf.HashLeaf([]byte("b")), leaf.HashLeaf([]byte("c"))
+	left := leaf.HashChildren(h0, h1)
+	rootHash := func(assetID string) []byte {
+		return leaf.HashChildren(left, leaf.HashChildren(h2, leafHash(assetID)))
+	}
+
+	var requested string
+	origProof, origRoot := fetchInclusionProof, fetchLogRoot
+	t.Cleanup(func() { fetchInclusionProof, fetchLogRoot = origProof, origRoot })
+	fetchLogRoot = func(ctx context.Context, logID int64) (*types.LogRootV1, error) {
+		return &types.LogRootV1{TreeSize: 4, RootHash: rootHash(requested), TimestampNanos: 1700000000000000000, Revision: 12}, nil
+	}
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: leafIndex, Hashes: [][]byte{h2, left}}}, nil
+	}
+
+	testCases := []struct {
+		name                 string
+		assetID              string
+		userID               string
+		expectedCode         int
+		expectedCacheControl string
+	}{
+		{name: "Logged asset", assetID: "logged", expectedCode: http.StatusOK, expectedCacheControl: "no-cache"},
+		{name: "Private asset with owner", assetID: "private", userID: "owner", expectedCode: http.StatusOK, expectedCacheControl: "private, no-cache"},
+		{name: "Private asset with another user", assetID: "private", userID: "someone-else", expectedCode: http.StatusNotFound},
+		{name: "Not yet logged", assetID: "unlogged", expectedCode: http.StatusAccepted},
+		{name: "Still processing", assetID: "processing", expectedCode: http.StatusAccepted},
+		{name: "Unknown asset", assetID: "missing", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requested = tc.assetID
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/assets/"+tc.assetID+"/proof", nil)
+			if tc.userID != "" {
+				req = withUser(req, tc.userID)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				if got := rec.Header().Get("Content-Disposition"); got != "" {
+					t.Errorf("Expected no attachment without a proof, but got %q", got)
+				}
+				return
+			}
+
+			expectedDisposition := `attachment; filename="proofpix-` + tc.assetID + `-proof.json"`
+			if got := rec.Header().Get("Content-Disposition"); got != expectedDisposition {
+				t.Errorf("Expected Content-Disposition %q, but got %q", expectedDisposition, got)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Expected Content-Type application/json, but got %q", got)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tc.expectedCacheControl {
+				t.Errorf("Expected Cache-Control %q, but got %q", tc.expectedCacheControl, got)
+			}
+
+			// The file holds just the documented fields
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+				t.Fatalf("Failed to decode proof file: %v", err)
+			}
+			for _, field := range []string{"version", "asset_id", "log_id", "leaf_format", "hash_algorithm", "leaf_hash", "inclusion_proof", "log_root"} {
+				if _, ok := fields[field]; !ok {
+					t.Errorf("Expected field %s in the proof file", field)
+				}
+			}
+			if len(fields) != 8 {
+				t.Errorf("Expected only the 8 documented fields, but got %d: %s", len(fields), rec.Body.String())
+			}
+
+			var proof assetProof
+			if err := json.Unmarshal(rec.Body.Bytes(), &proof); err != nil {
+				t.Fatalf("Failed to decode proof file: %v", err)
+			}
+			if proof.Version != assetProofVersion || proof.AssetID != tc.assetID || proof.LogID != 42 || proof.LeafFormat != leaf.FormatHash || proof.HashAlgorithm != leaf.SHA256 {
+				t.Errorf("Expected version %d of the proof of %s in log 42, but got %+v", assetProofVersion, tc.assetID, proof)
+			}
+			if !bytes.Equal(proof.LeafHash, leafHash(tc.assetID)) {
+				t.Errorf("Expected the leaf hash of the stored certificate, but got %x", proof.LeafHash)
+			}
+			if proof.InclusionProof.LeafIndex != 3 || proof.InclusionProof.TreeSize != 4 || len(proof.InclusionProof.Hashes) != 2 {
+				t.Errorf("Expected the inclusion proof for leaf 3 of 4, but got %+v", proof.InclusionProof)
+			}
+			if proof.LogRoot.TreeSize != 4 || proof.LogRoot.Revision != 12 || !bytes.Equal(proof.LogRoot.RootHash, rootHash(tc.assetID)) {
+				t.Errorf("Expected the log root at tree size 4, but got %+v", proof.LogRoot)
+			}
+			var decoded types.LogRootV1
+			if err := decoded.UnmarshalBinary(proof.LogRoot.Encoded); err != nil || !bytes.Equal(decoded.RootHash, proof.LogRoot.RootHash) {
+				t.Errorf("Expected the encoded log root to decode to the root hash, but got %+v (%v)", decoded, err)
+			}
+
+			// The file alone proves the leaf hash is in the log
+			if err := leaf.VerifyInclusion(proof.InclusionProof.LeafIndex, int64(proof.InclusionProof.TreeSize), proof.LeafHash, proof.InclusionProof.Hashes, proof.LogRoot.RootHash); err != nil {
+				t.Errorf("Expected the proof to verify against the log root, but got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+
+	"proofpix/internal/models"
+)
+
+// badgeBucket is the GCS bucket the worker writes generated badges to
+const badgeBucket = "proofpix-badges"
+
+// defaultBadgeMaxAge is how long, in seconds, browsers and CDNs may cache a badge
+const defaultBadgeMaxAge = 86400
+
+// ErrBadgeNotFound is returned when no badge has been generated for an asset
+var ErrBadgeNotFound = errors.New("badge not found")
+
+// fetchBadge loads the stored badge PNG for an asset. Tests replace it with a fake.
+var fetchBadge = downloadBadge
+
+// downloadBadge reads badges/{assetID}.png from the badge bucket
+func downloadBadge(ctx context.Context, assetID string) ([]byte, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %v", err)
+	}
+	defer client.Close()
+
+	objectName := fmt.Sprintf("badges/%s.png", assetID)
+	reader, err := client.Bucket(badgeBucket).Object(objectName).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrBadgeNotFound
+		}
+		return nil, fmt.Errorf("failed to open badge %s: %v", objectName, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read badge %s: %v", objectName, err)
+	}
+	return data, nil
+}
+
+// badgeMaxAge returns the badge Cache-Control max-age from BADGE_CACHE_MAX_AGE
+func badgeMaxAge() int {
+	maxAge := defaultBadgeMaxAge
+	if value := os.Getenv("BADGE_CACHE_MAX_AGE"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			maxAge = parsed
+		} else {
+			log.Printf("Invalid BADGE_CACHE_MAX_AGE %q, using default of %d seconds", value, defaultBadgeMaxAge)
+		}
+	}
+	return maxAge
+}
+
+// badgeETag derives a strong ETag from the inputs the badge is rendered from
+func badgeETag(asset *Asset) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", asset.ID, asset.OriginalityScore)))
+	return fmt.Sprintf(`"%x"`, hash[:16])
+}
+
+// verifyETag derives the ETag of a logged asset's verify response from the asset ID,
+// its leaf index, which changes only when the asset is re-anchored, and the size of
+// the log root the proof and confirmations were taken against
+func verifyETag(asset *Asset, treeSize uint64) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d", asset.ID, asset.TrillianLeafIndex, treeSize)))
+	return fmt.Sprintf(`"%x"`, hash[:16])
+}
+
+// etagMatches reports whether an If-None-Match header matches the given ETag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleBadge serves the PNG badge of a completed asset with caching headers
+// Route: GET /api/v1/badge/{id}
+func handleBadge(w http.ResponseWriter, r *http.Request) {
+	assetID := r.PathValue("id")
+
+	ctx := context.Background()
+	asset, err := repo.GetAsset(ctx, assetID)
+	if err != nil {
+		if errors.Is(err, ErrAssetNotFound) {
+			respondError(w, http.StatusNotFound, "Badge not found")
+			return
+		}
+		log.Printf("Failed to fetch asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+		return
+	}
+	if asset.IsDeleted() || asset.Status != models.StatusCompleted {
+		respondError(w, http.StatusNotFound, "Badge not found")
+		return
+	}
+
+	// Badges never change for a given asset and score, so they can be cached aggressively
+	etag := badgeETag(asset)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", badgeMaxAge()))
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	badge, err := fetchBadge(ctx, assetID)
+	if err != nil {
+		// Don't let caches hold on to an error response
+		w.Header().Del("ETag")
+		w.Header().Set("Cache-Control", "no-store")
+		if errors.Is(err, ErrBadgeNotFound) {
+			respondError(w, http.StatusNotFound, "Badge not found")
+			return
+		}
+		log.Printf("Failed to fetch badge for asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch badge")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(badge)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(badge)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestHandleBadge_CachingHeaders(t *testing.T) {
+	t.Setenv("BADGE_CACHE_MAX_AGE", "3600")
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, OriginalityScore: 9},
+		},
+	})
+
+	fetches := 0
+	orig := fetchBadge
+	fetchBadge = func(ctx context.Context, assetID string) ([]byte, error) {
+		fetches++
+		return []byte("\x89PNG badge"), nil
+	}
+	t.Cleanup(func() { fetchBadge = orig })
+
+	// First request returns the badge with caching headers
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/badge/asset-1", nil)
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Expected Content-Type image/png, but got %q", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); !strings.Contains(got, "public") || !strings.Contains(got, "max-age=3600") {
+		t.Errorf("Expected public Cache-Control with max-age=3600, but got %q", got)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Expected an ETag header")
+	}
+
+	// Revalidating with the ETag returns 304 without fetching the badge again
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/badge/asset-1", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, but got %d", http.StatusNotModified, rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected empty body for 304, but got %d bytes", rec.Body.Len())
+	}
+	if fetches != 1 {
+		t.Errorf("Expected badge to be fetched once, but got %d fetches", fetches)
+	}
+
+	// A stale ETag gets the full badge again
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/badge/asset-1", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	rec = httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for stale ETag, but got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestBadgeETag_ChangesWithScore(t *testing.T) {
+	low := badgeETag(&Asset{ID: "asset-1", OriginalityScore: 3})
+	high := badgeETag(&Asset{ID: "asset-1", OriginalityScore: 9})
+	if low == high {
+		t.Errorf("Expected ETag to change with score, but both were %s", low)
+	}
+}
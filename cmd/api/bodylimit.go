@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"proofpix/internal/bodylimit"
+)
+
+// routeBodyLimits holds the routes that accept more than MAX_REQUEST_BODY_BYTES,
+// keyed by path, with the bound they enforce themselves
+var routeBodyLimits = map[string]int64{
+	"/api/v1/verify/image": maxVerifyImageBytes,
+}
+
+// limitRequestBody bounds every request body so no handler can be made to read an
+// arbitrarily large one. A declared length over the limit is rejected with 413
+// before the handler runs; a body that turns out longer fails its read with an
+// *http.MaxBytesError, which handlers report with bodylimit.IsTooLarge.
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := routeBodyLimits[r.URL.Path]
+		if !ok {
+			limit = bodylimit.MaxBytesFromEnv()
+		}
+		if r.ContentLength > limit {
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", limit))
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
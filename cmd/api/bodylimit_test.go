@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "64")
+	t.Setenv("READ_ONLY", "")
+	useFakeRepository(t, &fakeRepository{})
+	oversized := `{"certificate":"` + strings.Repeat("x", 100) + `"}`
+
+	testCases := []struct {
+		name         string
+		request      func() *http.Request
+		expectedCode int
+	}{
+		{
+			name: "Oversized upload request",
+			request: func() *http.Request {
+				return withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets", strings.NewReader(oversized)), "owner")
+			},
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Oversized body without a declared length",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/log/inclusion-by-hash", io.NopCloser(strings.NewReader(oversized)))
+				req.ContentLength = -1
+				return req
+			},
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Body within the limit",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/api/v1/log/inclusion-by-hash", strings.NewReader(`{}`))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Route with its own limit",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/api/v1/verify/image", strings.NewReader(strings.Repeat("x", 100)))
+			},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			serve(t, rec, tc.request())
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusRequestEntityTooLarge {
+				return
+			}
+			var resp Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if resp.Code != ErrCodeTooLarge {
+				t.Errorf("Expected code %s, but got %s", ErrCodeTooLarge, resp.Code)
+			}
+		})
+	}
+}
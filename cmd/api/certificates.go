@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"cloud.google.com/go/storage"
+
+	"proofpix/internal/certificate"
+)
+
+// certificateBucket is the GCS bucket the worker writes issued credentials to
+const certificateBucket = "proofpix-certificates"
+
+// Certificate consistency states reported by the verify endpoint
+const (
+	certificateConsistent   = "consistent"
+	certificateInconsistent = "inconsistent"
+	certificateMissing      = "missing"
+	certificateUnavailable  = "unavailable"
+)
+
+// ErrCertificateNotFound is returned when no certificate has been stored for an asset
+var ErrCertificateNotFound = errors.New("certificate not found")
+
+// fetchCertificate loads the stored credential JSON for an asset. Tests replace it with a fake.
+var fetchCertificate = downloadCertificate
+
+// storeCertificate writes credential JSON to the certificate bucket under the given
+// object name. Tests replace it with a fake.
+var storeCertificate = uploadCertificate
+
+// uploadCertificate writes data to objectName in the certificate bucket
+func uploadCertificate(ctx context.Context, objectName string, data []byte) error {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create storage client: %v", err)
+	}
+	defer client.Close()
+
+	writer := client.Bucket(certificateBucket).Object(objectName).NewWriter(ctx)
+	writer.ContentType = "application/json"
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write certificate %s: %v", objectName, err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close certificate writer for %s: %v", objectName, err)
+	}
+	return nil
+}
+
+// downloadCertificate reads the asset's certificate from the certificate bucket at
+// the path given by CERTIFICATE_PATH_TEMPLATE, falling back to the flat layout
+func downloadCertificate(ctx context.Context, asset *Asset) ([]byte, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %v", err)
+	}
+	defer client.Close()
+
+	for _, objectName := range certificate.ObjectPaths(asset) {
+		reader, err := client.Bucket(certificateBucket).Object(objectName).NewReader(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to open certificate %s: %v", objectName, err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate %s: %v", objectName, err)
+		}
+		return data, nil
+	}
+	return nil, ErrCertificateNotFound
+}
+
+// checkCertificate re-verifies the stored credential against the current asset data.
+// It returns one of the certificate* states, a human readable detail for failures,
+// and the stored certificate bytes when they could be fetched.
+func checkCertificate(ctx context.Context, asset *Asset) (state string, detail string, data []byte) {
+	data, err := fetchCertificate(ctx, asset)
+	if err != nil {
+		if errors.Is(err, ErrCertificateNotFound) {
+			return certificateMissing, "", nil
+		}
+		log.Printf("Failed to fetch certificate for asset %s: %v", asset.ID, err)
+		return certificateUnavailable, "", nil
+	}
+
+	// Signed credentials are checked with the issuer key their proof names; without a
+	// signing key configured they are reported inconsistent rather than accepted unchecked
+	if err := certificate.VerifyJSONWithKey(data, asset, credentialKey(data)); err != nil {
+		log.Printf("Certificate for asset %s failed verification: %v", asset.ID, err)
+		return certificateInconsistent, err.Error(), data
+	}
+	return certificateConsistent, "", data
+}
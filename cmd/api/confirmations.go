@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+)
+
+// loggedVerification is the verify response for a logged asset: the Trillian
+// inclusion proof with the number of confirmations, both taken against the same
+// signed root
+type loggedVerification struct {
+	*trillian.GetInclusionProofResponse
+	Confirmations int64 `json:"confirmations"`
+}
+
+// leafConfirmations returns how many leaves the tree holds from leafIndex on, the
+// leaf itself included, like blockchain confirmations. A leaf the tree has not yet
+// grown to include has none.
+func leafConfirmations(treeSize uint64, leafIndex int64) int64 {
+	if leafIndex < 0 || treeSize <= uint64(leafIndex) {
+		return 0
+	}
+	return int64(treeSize) - leafIndex
+}
+
+// newLoggedVerification adds the confirmations of the asset's leaf to its inclusion
+// proof, counted against the root the proof was requested for
+func newLoggedVerification(asset *Asset, proof *trillian.GetInclusionProofResponse, root *types.LogRootV1) loggedVerification {
+	return loggedVerification{
+		GetInclusionProofResponse: proof,
+		Confirmations:             leafConfirmations(root.TreeSize, asset.TrillianLeafIndex),
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+
+	"proofpix/internal/models"
+)
+
+func TestLeafConfirmations(t *testing.T) {
+	testCases := []struct {
+		name      string
+		treeSize  uint64
+		leafIndex int64
+		expected  int64
+	}{
+		{name: "Newest leaf", treeSize: 4, leafIndex: 3, expected: 1},
+		{name: "Tree grew after the leaf", treeSize: 10, leafIndex: 3, expected: 7},
+		{name: "Not yet integrated", treeSize: 3, leafIndex: 3, expected: 0},
+		{name: "Root older than the leaf", treeSize: 2, leafIndex: 5, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := leafConfirmations(tc.treeSize, tc.leafIndex); got != tc.expected {
+				t.Errorf("Expected %d confirmations, but got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_Confirmations(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"logged":  {ID: "logged", UserID: "owner", Status: models.StatusCompleted, CreatedAt: time.Now(), TrillianLeafIndex: 3},
+		"pending": {ID: "pending", UserID: "owner", Status: models.StatusCompleted, CreatedAt: time.Now()},
+	}})
+	useFakeCertificates(t, nil)
+
+	var rootErr error
+	origProof, origRoot := fetchInclusionProof, fetchLogRoot
+	t.Cleanup(func() { fetchInclusionProof, fetchLogRoot = origProof, origRoot })
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: leafIndex}}, nil
+	}
+	fetchLogRoot = func(ctx context.Context, logID int64) (*types.LogRootV1, error) {
+		if rootErr != nil {
+			return nil, rootErr
+		}
+		return &types.LogRootV1{TreeSize: 10}, nil
+	}
+
+	testCases := []struct {
+		name                  string
+		assetID               string
+		rootErr               error
+		expectedCode          int
+		expectedConfirmations *int64
+	}{
+		{name: "Logged leaf with later leaves", assetID: "logged", expectedCode: http.StatusOK, expectedConfirmations: int64Ptr(7)},
+		{name: "Log root unavailable", assetID: "logged", rootErr: errors.New("connection refused"), expectedCode: http.StatusInternalServerError},
+		{name: "Not yet integrated", assetID: "pending", expectedCode: http.StatusAccepted, expectedConfirmations: int64Ptr(0)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verifyCache.clear()
+			rootErr = tc.rootErr
+			rec := httptest.NewRecorder()
+			serve(t, rec, httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+tc.assetID, nil))
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+
+			// Logged assets carry the field at the top level, pending ones under data
+			var body struct {
+				Confirmations *int64 `json:"confirmations"`
+				Data          struct {
+					Confirmations *int64 `json:"confirmations"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			got := body.Confirmations
+			if rec.Code == http.StatusAccepted {
+				got = body.Data.Confirmations
+			}
+			switch {
+			case tc.expectedConfirmations == nil && got != nil:
+				t.Errorf("Expected no confirmations, but got %d", *got)
+			case tc.expectedConfirmations != nil && (got == nil || *got != *tc.expectedConfirmations):
+				t.Errorf("Expected %d confirmations, but got %s", *tc.expectedConfirmations, rec.Body.String())
+			}
+		})
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/models"
+)
+
+// Credential serializations offered by the certificate download endpoint
+const (
+	contentTypeJSONLD = "application/ld+json"
+	contentTypeJSON   = "application/json"
+	contentTypeVCJWT  = "application/vc+jwt"
+)
+
+// credentialMediaTypes maps acceptable media types to the serialization served for them
+var credentialMediaTypes = map[string]string{
+	contentTypeJSONLD: contentTypeJSONLD,
+	contentTypeJSON:   contentTypeJSON,
+	contentTypeVCJWT:  contentTypeVCJWT,
+	"application/jwt": contentTypeVCJWT,
+	"application/*":   contentTypeJSONLD,
+	"*/*":             contentTypeJSONLD,
+}
+
+// credentialSigner signs VC-JWT downloads when credential signing is configured
+var credentialSigner *certificate.SigningConfig
+
+// retiredKeys are the issuer's earlier signing keys from CERTIFICATE_RETIRED_KEYS,
+// still published and accepted for the credentials they signed
+var retiredKeys []certificate.DIDKey
+
+// negotiateCredentialType picks the serialization for an Accept header, preferring
+// the highest quality value and then header order. An empty header means JSON-LD;
+// ok is false when none of the accepted types can be served.
+func negotiateCredentialType(accept string) (contentType string, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return contentTypeJSONLD, true
+	}
+
+	bestQuality := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		quality := 1.0
+		for _, param := range params[1:] {
+			if name, value, found := strings.Cut(strings.TrimSpace(param), "="); found && name == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		served, known := credentialMediaTypes[mediaType]
+		if !known || quality <= bestQuality {
+			continue
+		}
+		contentType, bestQuality, ok = served, quality, true
+	}
+	return contentType, ok
+}
+
+// handleCertificateDownload handles GET /api/v1/certificate/{id}, serving the stored
+// credential as JSON-LD (default), plain JSON or a VC-JWT depending on Accept
+func handleCertificateDownload(w http.ResponseWriter, r *http.Request) {
+	assetID := r.PathValue("id")
+	w.Header().Set("Vary", "Accept")
+
+	contentType, ok := negotiateCredentialType(r.Header.Get("Accept"))
+	if !ok {
+		respondError(w, http.StatusNotAcceptable, "Supported types: application/ld+json, application/json, application/vc+jwt")
+		return
+	}
+
+	ctx := context.Background()
+	asset, err := repo.GetAsset(ctx, assetID)
+	if err != nil {
+		if errors.Is(err, ErrAssetNotFound) {
+			respondError(w, http.StatusNotFound, "Certificate not found")
+			return
+		}
+		log.Printf("Failed to fetch asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+		return
+	}
+	if asset.IsDeleted() || asset.Status != models.StatusCompleted || !canVerifyAsset(r, asset) {
+		respondError(w, http.StatusNotFound, "Certificate not found")
+		return
+	}
+
+	data, err := fetchCertificate(ctx, asset)
+	if err != nil {
+		if errors.Is(err, ErrCertificateNotFound) {
+			respondError(w, http.StatusNotFound, "Certificate not found")
+			return
+		}
+		log.Printf("Failed to fetch certificate for asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch certificate")
+		return
+	}
+
+	body := data
+	switch contentType {
+	case contentTypeJSON:
+		// Plain JSON drops the JSON-LD context so it reads as an ordinary document
+		var document map[string]interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			log.Printf("Stored certificate for asset %s is not valid JSON: %v", assetID, err)
+			respondError(w, http.StatusInternalServerError, "Stored certificate is malformed")
+			return
+		}
+		delete(document, "@context")
+		body, _ = json.MarshalIndent(document, "", "  ")
+	case contentTypeVCJWT:
+		var credential certificate.VerifiableCredential
+		if err := json.Unmarshal(data, &credential); err != nil {
+			log.Printf("Stored certificate for asset %s is not valid JSON: %v", assetID, err)
+			respondError(w, http.StatusInternalServerError, "Stored certificate is malformed")
+			return
+		}
+		token, err := certificate.EncodeJWT(&credential, credentialSigner)
+		if err != nil {
+			log.Printf("Failed to encode VC-JWT for asset %s: %v", assetID, err)
+			respondError(w, http.StatusInternalServerError, "Failed to encode certificate")
+			return
+		}
+		body = []byte(token)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if asset.IsPrivate() {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
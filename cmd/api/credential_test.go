@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/models"
+)
+
+func TestHandleCertificateDownload_ContentNegotiation(t *testing.T) {
+	asset := &Asset{ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, OriginalityScore: 9, CreatedAt: time.Now()}
+	credential, err := certificate.Generate(asset)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	stored, _ := json.MarshalIndent(credential, "", "  ")
+
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{"asset-1": asset}})
+	useFakeCertificates(t, map[string][]byte{"asset-1": stored})
+
+	testCases := []struct {
+		name                string
+		accept              string
+		expectedCode        int
+		expectedContentType string
+	}{
+		{name: "Default", expectedCode: http.StatusOK, expectedContentType: contentTypeJSONLD},
+		{name: "Wildcard", accept: "*/*", expectedCode: http.StatusOK, expectedContentType: contentTypeJSONLD},
+		{name: "JSON-LD", accept: "application/ld+json", expectedCode: http.StatusOK, expectedContentType: contentTypeJSONLD},
+		{name: "Plain JSON", accept: "application/json", expectedCode: http.StatusOK, expectedContentType: contentTypeJSON},
+		{name: "VC-JWT", accept: "application/vc+jwt", expectedCode: http.StatusOK, expectedContentType: contentTypeVCJWT},
+		{name: "Quality preference", accept: "application/ld+json;q=0.5, application/vc+jwt", expectedCode: http.StatusOK, expectedContentType: contentTypeVCJWT},
+		{name: "Unsupported type", accept: "application/xml", expectedCode: http.StatusNotAcceptable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/certificate/asset-1", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != tc.expectedContentType {
+				t.Errorf("Expected Content-Type %s, but got %s", tc.expectedContentType, got)
+			}
+			if rec.Header().Get("Vary") != "Accept" {
+				t.Errorf("Expected Vary: Accept")
+			}
+
+			switch tc.expectedContentType {
+			case contentTypeJSONLD, contentTypeJSON:
+				var document map[string]interface{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &document); err != nil {
+					t.Fatalf("Expected a JSON document, but got %v", err)
+				}
+				_, hasContext := document["@context"]
+				if hasContext != (tc.expectedContentType == contentTypeJSONLD) {
+					t.Errorf("Expected @context present=%t for %s", !hasContext, tc.expectedContentType)
+				}
+				if document["credentialSubject"] == nil {
+					t.Errorf("Expected a credentialSubject, but got %v", document)
+				}
+			case contentTypeVCJWT:
+				parts := strings.Split(rec.Body.String(), ".")
+				if len(parts) != 3 {
+					t.Fatalf("Expected a three-segment JWT, but got %q", rec.Body.String())
+				}
+				payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+				if err != nil {
+					t.Fatalf("Expected base64url claims, but got %v", err)
+				}
+				var claims struct {
+					Subject string                           `json:"sub"`
+					VC      certificate.VerifiableCredential `json:"vc"`
+				}
+				if err := json.Unmarshal(payload, &claims); err != nil {
+					t.Fatalf("Expected JSON claims, but got %v", err)
+				}
+				if claims.Subject != "urn:proofpix:asset:asset-1" || claims.VC.Proof.ProofValue != credential.Proof.ProofValue {
+					t.Errorf("Expected the stored credential in the JWT, but got %+v", claims)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCertificateDownload_PrivateAsset(t *testing.T) {
+	asset := &Asset{ID: "private", UserID: "owner", Status: models.StatusCompleted, OriginalityScore: 9, CreatedAt: time.Now(), Visibility: models.VisibilityPrivate}
+	credential, err := certificate.Generate(asset)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	stored, _ := json.MarshalIndent(credential, "", "  ")
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{"private": asset}})
+	useFakeCertificates(t, map[string][]byte{"private": stored})
+
+	testCases := []struct {
+		name         string
+		request      func(*http.Request) *http.Request
+		expectedCode int
+	}{
+		{name: "Owner", request: func(r *http.Request) *http.Request { return withUser(r, "owner") }, expectedCode: http.StatusOK},
+		{name: "Admin", request: func(r *http.Request) *http.Request { return withAdmin(r, "admin") }, expectedCode: http.StatusOK},
+		{name: "Without auth", expectedCode: http.StatusNotFound},
+		{name: "Another user", request: func(r *http.Request) *http.Request { return withUser(r, "someone-else") }, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/certificate/private", nil)
+			if tc.request != nil {
+				req = tc.request(req)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode == http.StatusOK && rec.Header().Get("Cache-Control") != "private, no-cache" {
+				t.Errorf("Expected Cache-Control private, no-cache, but got %q", rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// decodeAssetFields builds an asset from raw document data one field at a time.
+// It is the fallback when DataTo fails: a field with an unexpected type (for
+// example an embedding stored in another encoding) is left at its zero value and
+// named in SkippedFields, so the fields verification relies on still load.
+// An error is returned only when status, which every handler depends on, cannot
+// be read.
+func decodeAssetFields(docID string, data map[string]interface{}) (*Asset, error) {
+	d := fieldDecoder{data: data}
+	asset := &Asset{
+		ID:                      d.string("id"),
+		UserID:                  d.string("user_id"),
+		Status:                  d.string("status"),
+		CreatedAt:               d.time("created_at"),
+		RawAnalysis:             d.string("raw_analysis"),
+		OriginalityScore:        int(d.int("originality_score")),
+		Narrative:               d.string("narrative"),
+		Embedding:               d.float32s("embedding"),
+		EmbeddingFloat16:        d.bytes("embedding_f16"),
+		TrillianLeafIndex:       d.int("trillian_leaf_index"),
+		TrillianLeafFormat:      d.string("trillian_leaf_format"),
+		DeletedAt:               d.time("deleted_at"),
+		StatusBeforeDelete:      d.string("status_before_delete"),
+		AnalysisFailed:          d.bool("analysis_failed"),
+		EmbeddingFailed:         d.bool("embedding_failed"),
+		CertificateUploadFailed: d.bool("certificate_upload_failed"),
+		BadgeUploadFailed:       d.bool("badge_upload_failed"),
+		ModelVersion:            d.string("model_version"),
+		EmbeddingModel:          d.string("embedding_model"),
+		ImageHash:               d.string("image_hash"),
+		ThumbnailURL:            d.string("thumbnail_url"),
+		NarrativeLanguage:       d.string("narrative_language"),
+		NarrativeOriginal:       d.string("narrative_original"),
+		NarrativeTranslatedFrom: d.string("narrative_translated_from"),
+		ShortCode:               d.string("short_code"),
+		AnalysisWarning:         d.string("analysis_warning"),
+		SkipAnchoring:           d.bool("skip_anchoring"),
+		BelowAnchorMinScore:     d.bool("below_anchor_min_score"),
+		Visibility:              d.string("visibility"),
+		UploadBucket:            d.string("upload_bucket"),
+		ProcessedBy:             d.string("processed_by"),
+		Metadata:                d.stringMap("metadata"),
+		RelatedAsset:            d.relatedAsset("related_asset"),
+		Frames:                  d.frames("frames"),
+		CredentialHistory:       d.credentialHistory("credential_history"),
+	}
+	if asset.ID == "" {
+		asset.ID = docID
+	}
+
+	sort.Strings(d.skipped)
+	for _, field := range d.skipped {
+		if field == "status" {
+			return nil, fmt.Errorf("asset %s has an unreadable status field", docID)
+		}
+	}
+	asset.SkippedFields = d.skipped
+	return asset, nil
+}
+
+// fieldDecoder reads typed values out of Firestore document data, recording the
+// names of fields that are present but hold an unexpected type
+type fieldDecoder struct {
+	data    map[string]interface{}
+	skipped []string
+}
+
+func (d *fieldDecoder) skip(field string) {
+	d.skipped = append(d.skipped, field)
+}
+
+func (d *fieldDecoder) string(field string) string {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return ""
+	}
+	s, ok := value.(string)
+	if !ok {
+		d.skip(field)
+	}
+	return s
+}
+
+func (d *fieldDecoder) bool(field string) bool {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return false
+	}
+	b, ok := value.(bool)
+	if !ok {
+		d.skip(field)
+	}
+	return b
+}
+
+func (d *fieldDecoder) int(field string) int64 {
+	switch value := d.data[field].(type) {
+	case nil:
+		return 0
+	case int64:
+		return value
+	case int:
+		return int64(value)
+	case float64:
+		if value == float64(int64(value)) {
+			return int64(value)
+		}
+	}
+	d.skip(field)
+	return 0
+}
+
+func (d *fieldDecoder) bytes(field string) []byte {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		d.skip(field)
+	}
+	return b
+}
+
+func (d *fieldDecoder) time(field string) time.Time {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return time.Time{}
+	}
+	t, ok := value.(time.Time)
+	if !ok {
+		d.skip(field)
+	}
+	return t
+}
+
+func (d *fieldDecoder) float32s(field string) []float32 {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		d.skip(field)
+		return nil
+	}
+	vector := make([]float32, len(items))
+	for i, item := range items {
+		switch n := item.(type) {
+		case float64:
+			vector[i] = float32(n)
+		case int64:
+			vector[i] = float32(n)
+		default:
+			d.skip(field)
+			return nil
+		}
+	}
+	return vector
+}
+
+func (d *fieldDecoder) stringMap(field string) map[string]string {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return nil
+	}
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		d.skip(field)
+		return nil
+	}
+	result := make(map[string]string, len(entries))
+	for key, entry := range entries {
+		s, ok := entry.(string)
+		if !ok {
+			d.skip(field)
+			return nil
+		}
+		result[key] = s
+	}
+	return result
+}
+
+// nested returns a decoder for a map value, or false when value is not a map
+func nested(value interface{}) (*fieldDecoder, bool) {
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return &fieldDecoder{data: data}, true
+}
+
+func (d *fieldDecoder) relatedAsset(field string) *models.RelatedAsset {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return nil
+	}
+	related, ok := nested(value)
+	if !ok {
+		d.skip(field)
+		return nil
+	}
+	distance, ok := related.data["distance"].(float64)
+	assetID := related.string("asset_id")
+	if !ok || len(related.skipped) > 0 {
+		d.skip(field)
+		return nil
+	}
+	return &models.RelatedAsset{AssetID: assetID, Distance: float32(distance)}
+}
+
+func (d *fieldDecoder) credentialHistory(field string) []models.CredentialRevision {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		d.skip(field)
+		return nil
+	}
+	history := make([]models.CredentialRevision, 0, len(items))
+	for _, item := range items {
+		entry, ok := nested(item)
+		if !ok {
+			d.skip(field)
+			return nil
+		}
+		revision := models.CredentialRevision{
+			ObjectPath:         entry.string("object_path"),
+			ReplacedAt:         entry.time("replaced_at"),
+			TrillianLeafIndex:  entry.int("trillian_leaf_index"),
+			TrillianLeafFormat: entry.string("trillian_leaf_format"),
+		}
+		if len(entry.skipped) > 0 {
+			d.skip(field)
+			return nil
+		}
+		history = append(history, revision)
+	}
+	return history
+}
+
+func (d *fieldDecoder) frames(field string) []models.FrameResult {
+	value, ok := d.data[field]
+	if !ok || value == nil {
+		return nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		d.skip(field)
+		return nil
+	}
+	frames := make([]models.FrameResult, 0, len(items))
+	for _, item := range items {
+		entry, ok := nested(item)
+		if !ok {
+			d.skip(field)
+			return nil
+		}
+		offset, ok := entry.data["offset_seconds"].(float64)
+		if !ok {
+			// Whole seconds are stored as integers
+			offset = float64(entry.int("offset_seconds"))
+		}
+		frame := models.FrameResult{
+			OffsetSeconds:    offset,
+			OriginalityScore: int(entry.int("originality_score")),
+			Narrative:        entry.string("narrative"),
+			AnalysisFailed:   entry.bool("analysis_failed"),
+			EmbeddingFailed:  entry.bool("embedding_failed"),
+		}
+		if len(entry.skipped) > 0 {
+			d.skip(field)
+			return nil
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestDecodeAssetFields(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"id":                  "asset-1",
+			"user_id":             "owner",
+			"status":              models.StatusCompleted,
+			"created_at":          createdAt,
+			"originality_score":   int64(87),
+			"embedding":           []interface{}{0.5, 1.0},
+			"trillian_leaf_index": int64(42),
+			"model_version":       "gemini-1.5-flash-002",
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		mutate          func(data map[string]interface{})
+		expectError     bool
+		expectedSkipped []string
+		expectedVector  []float32
+	}{
+		{name: "All fields valid", mutate: func(map[string]interface{}) {}, expectedVector: []float32{0.5, 1}},
+		{
+			name:            "Embedding is a string",
+			mutate:          func(data map[string]interface{}) { data["embedding"] = "AAAAPwAAgD8=" },
+			expectedSkipped: []string{"embedding"},
+		},
+		{
+			name:            "Embedding holds a non-number",
+			mutate:          func(data map[string]interface{}) { data["embedding"] = []interface{}{0.5, "x"} },
+			expectedSkipped: []string{"embedding"},
+		},
+		{
+			name: "Several malformed fields",
+			mutate: func(data map[string]interface{}) {
+				data["narrative"] = int64(3)
+				data["embedding"] = map[string]interface{}{"values": []interface{}{1.0}}
+			},
+			expectedSkipped: []string{"embedding", "narrative"},
+		},
+		{
+			name: "Nested fields decode",
+			mutate: func(data map[string]interface{}) {
+				data["related_asset"] = map[string]interface{}{"asset_id": "asset-0", "distance": 0.05}
+				data["metadata"] = map[string]interface{}{"campaign": "spring"}
+				data["credential_history"] = []interface{}{
+					map[string]interface{}{"object_path": "asset-1/history/a.json", "replaced_at": createdAt, "trillian_leaf_index": int64(3)},
+				}
+				data["frames"] = []interface{}{
+					map[string]interface{}{"offset_seconds": int64(0), "originality_score": int64(90)},
+					map[string]interface{}{"offset_seconds": 2.5, "originality_score": int64(40), "narrative": "Spliced"},
+				}
+			},
+			expectedVector: []float32{0.5, 1},
+		},
+		{
+			name: "Malformed nested fields",
+			mutate: func(data map[string]interface{}) {
+				data["related_asset"] = "asset-0"
+				data["metadata"] = map[string]interface{}{"campaign": int64(1)}
+				data["credential_history"] = []interface{}{map[string]interface{}{"object_path": int64(1)}}
+				data["frames"] = []interface{}{map[string]interface{}{"originality_score": "high"}}
+			},
+			expectedSkipped: []string{"credential_history", "frames", "metadata", "related_asset"},
+			expectedVector:  []float32{0.5, 1},
+		},
+		{
+			name:        "Status is unreadable",
+			mutate:      func(data map[string]interface{}) { data["status"] = int64(1) },
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := valid()
+			tc.mutate(data)
+
+			asset, err := decodeAssetFields("asset-1", data)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+
+			// The fields verification needs are always intact
+			if asset.ID != "asset-1" || asset.Status != models.StatusCompleted || asset.OriginalityScore != 87 ||
+				asset.TrillianLeafIndex != 42 || !asset.CreatedAt.Equal(createdAt) || asset.ModelVersion != "gemini-1.5-flash-002" {
+				t.Errorf("Expected verification fields to decode, but got %+v", asset)
+			}
+			if !reflect.DeepEqual(asset.SkippedFields, tc.expectedSkipped) {
+				t.Errorf("Expected skipped fields %v, but got %v", tc.expectedSkipped, asset.SkippedFields)
+			}
+			if !reflect.DeepEqual(asset.Embedding, tc.expectedVector) {
+				t.Errorf("Expected embedding %v, but got %v", tc.expectedVector, asset.Embedding)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_MalformedEmbedding(t *testing.T) {
+	asset, err := decodeAssetFields("asset-1", map[string]interface{}{
+		"user_id":           "owner",
+		"status":            models.StatusCompleted,
+		"originality_score": int64(87),
+		"embedding":         "not-a-vector",
+	})
+	if err != nil {
+		t.Fatalf("decodeAssetFields() failed: %v", err)
+	}
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{"asset-1": asset}})
+	useFakeCertificates(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/asset-1", nil)
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	// Not yet logged, so verification reports pending inclusion instead of failing
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d, but got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+}
+
+func TestFirestoreRepository_RefusesPartiallyDecodedSave(t *testing.T) {
+	asset := &Asset{ID: "asset-1", Status: models.StatusCompleted, SkippedFields: []string{"embedding"}}
+	err := firestoreRepository{}.SaveAsset(context.Background(), asset)
+	if !errors.Is(err, ErrAssetPartiallyDecoded) {
+		t.Errorf("Expected ErrAssetPartiallyDecoded, but got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/models"
+)
+
+// handleDeleteAsset soft-deletes an asset owned by the caller
+// Route: DELETE /api/v1/assets/{id}
+func handleDeleteAsset(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "User ID not found in context")
+		return
+	}
+
+	assetID := r.PathValue("id")
+
+	asset, ok := getOwnedAsset(w, r, assetID, userID)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	asset.SoftDelete(now)
+	if err := repo.SaveAsset(r.Context(), asset); err != nil {
+		log.Printf("Failed to soft-delete asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to delete asset")
+		return
+	}
+
+	verifyCache.invalidate(assetID)
+	// The worker's reaper removes the asset from search on its next run if this fails
+	if err := updateSearchIndex(r.Context(), assetID, indexActionRemove); err != nil {
+		log.Printf("Failed to remove asset %s from the search index: %v", assetID, err)
+	}
+	log.Printf("Asset %s soft-deleted by user %s", assetID, userID)
+	response := Response{
+		Success: true,
+		Message: "Asset deleted; it can be restored until the retention period ends",
+		Data: map[string]interface{}{
+			"asset_id":   assetID,
+			"status":     asset.Status,
+			"deleted_at": asset.DeletedAt,
+			"purge_at":   asset.DeletedAt.Add(models.RetentionPeriod()),
+		},
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleRestoreAsset undoes a soft delete within the retention period
+// Route: POST /api/v1/assets/{id}/restore
+func handleRestoreAsset(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "User ID not found in context")
+		return
+	}
+
+	assetID := r.PathValue("id")
+
+	asset, ok := getOwnedAsset(w, r, assetID, userID)
+	if !ok {
+		return
+	}
+
+	if !asset.IsDeleted() {
+		respondError(w, http.StatusConflict, "Asset is not deleted")
+		return
+	}
+
+	if err := asset.Restore(time.Now().UTC(), models.RetentionPeriod()); err != nil {
+		if errors.Is(err, models.ErrRestoreWindowExpired) {
+			respondError(w, http.StatusGone, "Restore window has expired")
+			return
+		}
+		log.Printf("Failed to restore asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to restore asset")
+		return
+	}
+
+	if err := repo.SaveAsset(r.Context(), asset); err != nil {
+		log.Printf("Failed to save restored asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to restore asset")
+		return
+	}
+
+	verifyCache.invalidate(assetID)
+	// If this fails the asset stays out of search until the index is rebuilt
+	if err := updateSearchIndex(r.Context(), assetID, indexActionRestore); err != nil {
+		log.Printf("Failed to restore asset %s to the search index: %v", assetID, err)
+	}
+	log.Printf("Asset %s restored by user %s", assetID, userID)
+	response := Response{
+		Success: true,
+		Message: "Asset restored successfully",
+		Data: map[string]interface{}{
+			"asset_id": assetID,
+			"status":   asset.Status,
+		},
+	}
+	respondJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestDeleteAndRestoreAsset(t *testing.T) {
+	fake := &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "owner", Status: "completed"},
+		},
+	}
+	useFakeRepository(t, fake)
+	var indexUpdates []string
+	orig := updateSearchIndex
+	updateSearchIndex = func(ctx context.Context, assetID, action string) error {
+		indexUpdates = append(indexUpdates, action+" "+assetID)
+		return nil
+	}
+	t.Cleanup(func() { updateSearchIndex = orig })
+
+	// Soft-delete the asset
+	req := withUser(httptest.NewRequest(http.MethodDelete, "/api/v1/assets/asset-1", nil), "owner")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected delete status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if stored := fake.assets["asset-1"]; stored.Status != models.StatusDeleted || stored.DeletedAt.IsZero() {
+		t.Fatalf("Expected asset to be soft-deleted with a timestamp, but got status %q at %v", stored.Status, stored.DeletedAt)
+	}
+
+	// Restore it within the retention window
+	req = withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets/asset-1/restore", nil), "owner")
+	rec = httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected restore status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if stored := fake.assets["asset-1"]; stored.Status != "completed" {
+		t.Errorf("Expected restored status to be completed, but got %q", stored.Status)
+	}
+
+	// The worker drops the asset from search on delete and puts it back on restore
+	if expected := []string{"remove asset-1", "restore asset-1"}; !reflect.DeepEqual(indexUpdates, expected) {
+		t.Errorf("Expected index updates %v, but got %v", expected, indexUpdates)
+	}
+}
+
+func TestRestoreAsset_WindowExpired(t *testing.T) {
+	t.Setenv("ASSET_RETENTION_DAYS", "7")
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {
+				ID:                 "asset-1",
+				UserID:             "owner",
+				Status:             models.StatusDeleted,
+				StatusBeforeDelete: "completed",
+				DeletedAt:          time.Now().Add(-8 * 24 * time.Hour),
+			},
+		},
+	})
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets/asset-1/restore", nil), "owner")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusGone {
+		t.Errorf("Expected status %d, but got %d", http.StatusGone, rec.Code)
+	}
+}
+
+func TestDeleteAsset_NotOwner(t *testing.T) {
+	fake := &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "owner", Status: "completed"},
+		},
+	}
+	useFakeRepository(t, fake)
+
+	req := withUser(httptest.NewRequest(http.MethodDelete, "/api/v1/assets/asset-1", nil), "intruder")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, but got %d", http.StatusNotFound, rec.Code)
+	}
+	if fake.assets["asset-1"].IsDeleted() {
+		t.Errorf("Expected asset owned by another user to be untouched")
+	}
+}
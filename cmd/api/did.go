@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"proofpix/internal/certificate"
+)
+
+// handleDIDDocument handles GET /.well-known/did.json, publishing the signing key
+// and any retired keys under the issuer DID so did:web resolvers can verify
+// credentials. It returns 404 unless CERTIFICATE_ISSUER_DID names this host's DID
+// and credentials are signed.
+func handleDIDDocument(w http.ResponseWriter, r *http.Request) {
+	issuer := certificate.IssuerFromEnv()
+	if !certificate.IsDID(issuer) || credentialSigner == nil {
+		respondError(w, http.StatusNotFound, "No DID document is published")
+		return
+	}
+
+	// The document lists the key under the fragment of the configured verification method
+	did, fragment, _ := strings.Cut(credentialSigner.VerificationMethod, "#")
+	if did != issuer || fragment == "" {
+		log.Printf("Verification method %q is not a key of issuer %s", credentialSigner.VerificationMethod, issuer)
+		respondError(w, http.StatusNotFound, "No DID document is published")
+		return
+	}
+
+	keys := []certificate.DIDKey{{Fragment: fragment, PublicKey: credentialSigner.PublicKey()}}
+	for _, key := range retiredKeys {
+		if key.Fragment != fragment {
+			keys = append(keys, key)
+		}
+	}
+	doc, err := certificate.NewDIDDocument(issuer, keys...)
+	if err != nil {
+		log.Printf("Failed to build DID document: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to build DID document")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, http.StatusOK, doc)
+}
+
+// credentialKey returns the issuer key to check a stored credential with: the
+// retired key published under the fragment its proof's verificationMethod names,
+// or the current signing key otherwise. It is nil when signing is not configured.
+func credentialKey(data []byte) crypto.PublicKey {
+	if credentialSigner == nil {
+		return nil
+	}
+
+	var credential certificate.VerifiableCredential
+	if err := json.Unmarshal(data, &credential); err != nil {
+		return credentialSigner.PublicKey()
+	}
+	method := credential.Proof.VerificationMethod
+	if method == "" || method == credentialSigner.VerificationMethod {
+		return credentialSigner.PublicKey()
+	}
+
+	// Retired keys are only published under the issuer DID
+	did, fragment, _ := strings.Cut(method, "#")
+	if did != "" && did != certificate.IssuerFromEnv() {
+		return credentialSigner.PublicKey()
+	}
+	for _, key := range retiredKeys {
+		if key.Fragment == fragment {
+			return key.PublicKey
+		}
+	}
+	return credentialSigner.PublicKey()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proofpix/internal/certificate"
+)
+
+func TestHandleDIDDocument(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	signer, err := certificate.NewSigningConfig(certificate.AlgorithmEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "did:web:proofpix.com#key-1")
+	if err != nil {
+		t.Fatalf("Failed to create signing config: %v", err)
+	}
+	_, retiredKey, _ := ed25519.GenerateKey(rand.Reader)
+	origSigner, origRetired := credentialSigner, retiredKeys
+	t.Cleanup(func() { credentialSigner, retiredKeys = origSigner, origRetired })
+	retiredKeys = []certificate.DIDKey{{Fragment: "key-0", PublicKey: retiredKey.Public()}}
+
+	testCases := []struct {
+		name         string
+		did          string
+		signer       *certificate.SigningConfig
+		expectedCode int
+	}{
+		{name: "DID and signing configured", did: "did:web:proofpix.com", signer: signer, expectedCode: http.StatusOK},
+		{name: "DIDs disabled", did: "", signer: signer, expectedCode: http.StatusNotFound},
+		{name: "Unsigned credentials", did: "did:web:proofpix.com", signer: nil, expectedCode: http.StatusNotFound},
+		{name: "Key belongs to another DID", did: "did:web:example.com", signer: signer, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CERTIFICATE_ISSUER_DID", tc.did)
+			credentialSigner = tc.signer
+
+			rec := httptest.NewRecorder()
+			serve(t, rec, httptest.NewRequest(http.MethodGet, "/.well-known/did.json", nil))
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var doc certificate.DIDDocument
+			if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("Failed to decode DID document: %v", err)
+			}
+			publicKey, err := doc.PublicKey("did:web:proofpix.com#key-1")
+			if err != nil || !key.Public().(ed25519.PublicKey).Equal(publicKey) {
+				t.Errorf("Expected the signing key under #key-1, but got %v (%v)", publicKey, err)
+			}
+			publicKey, err = doc.PublicKey("did:web:proofpix.com#key-0")
+			if err != nil || !retiredKey.Public().(ed25519.PublicKey).Equal(publicKey) {
+				t.Errorf("Expected the retired key under #key-0, but got %v (%v)", publicKey, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/models"
+)
+
+// downloadURLExpiry is how long an issued download URL stays valid
+const downloadURLExpiry = 15 * time.Minute
+
+// Content dispositions a download URL can have its object served with
+const (
+	dispositionInline     = "inline"
+	dispositionAttachment = "attachment"
+)
+
+// Files of an asset that download URLs are issued for
+const (
+	fileCertificate = "certificate"
+	fileBadge       = "badge"
+)
+
+// errDownloadNotFound is returned when none of the objects a download URL may point
+// at exist
+var errDownloadNotFound = errors.New("object not found")
+
+// downloadURLOptions returns the options for a V4 GET URL. A non-empty
+// responseDisposition is signed into the URL as response-content-disposition, so
+// GCS serves the object with that Content-Disposition instead of its stored one.
+func downloadURLOptions(responseDisposition string, expires time.Time) *storage.SignedURLOptions {
+	opts := &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  "GET",
+		Expires: expires,
+	}
+	if responseDisposition != "" {
+		opts.QueryParameters = url.Values{"response-content-disposition": {responseDisposition}}
+	}
+	return opts
+}
+
+// signDownloadURL returns a signed URL for the first of objectNames that exists in
+// the bucket. Tests replace it with a fake.
+var signDownloadURL = signedDownloadURL
+
+// signedDownloadURL signs a GET URL for the first existing object of objectNames, or
+// returns errDownloadNotFound
+func signedDownloadURL(ctx context.Context, bucketName string, objectNames []string, opts *storage.SignedURLOptions) (string, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create storage client: %v", err)
+	}
+	defer client.Close()
+
+	bucket := client.Bucket(bucketName)
+	for _, objectName := range objectNames {
+		if _, err := bucket.Object(objectName).Attrs(ctx); err != nil {
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				continue
+			}
+			return "", fmt.Errorf("failed to read %s: %v", objectName, err)
+		}
+		return bucket.SignedURL(objectName, opts)
+	}
+	return "", errDownloadNotFound
+}
+
+// handleDownloadURL issues a short-lived signed URL for an asset's stored certificate
+// or badge: GET /api/v1/assets/{id}/download-url?file=certificate|badge. By default
+// the URL downloads the file as an attachment named proofpix-{file}-{id}.{ext};
+// disposition=inline opens it in the browser instead.
+func handleDownloadURL(w http.ResponseWriter, r *http.Request) {
+	assetID := r.PathValue("id")
+	query := r.URL.Query()
+
+	file := query.Get("file")
+	if file == "" {
+		file = fileCertificate
+	}
+	if file != fileCertificate && file != fileBadge {
+		respondValidationError(w, "Invalid file", FieldError{Field: "file", Message: "must be certificate or badge"})
+		return
+	}
+	disposition := query.Get("disposition")
+	if disposition == "" {
+		disposition = dispositionAttachment
+	}
+	if disposition != dispositionAttachment && disposition != dispositionInline {
+		respondValidationError(w, "Invalid disposition", FieldError{Field: "disposition", Message: "must be attachment or inline"})
+		return
+	}
+
+	ctx := r.Context()
+	asset, err := repo.GetAsset(ctx, assetID)
+	if err != nil && !errors.Is(err, ErrAssetNotFound) {
+		log.Printf("Failed to fetch asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+		return
+	}
+	if err != nil || asset.IsDeleted() || !canVerifyAsset(r, asset) {
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+		return
+	}
+	if asset.Status != models.StatusCompleted {
+		respondError(w, http.StatusNotFound, "Asset has no "+file+" yet")
+		return
+	}
+
+	bucketName, objectNames, filename := certificateBucket, certificate.ObjectPaths(asset), fmt.Sprintf("proofpix-certificate-%s.json", assetID)
+	if file == fileBadge {
+		bucketName, objectNames, filename = badgeBucket, []string{fmt.Sprintf("badges/%s.png", assetID)}, fmt.Sprintf("proofpix-badge-%s.png", assetID)
+	}
+
+	expires := time.Now().Add(downloadURLExpiry)
+	signedURL, err := signDownloadURL(ctx, bucketName, objectNames, downloadURLOptions(fmt.Sprintf("%s; filename=%q", disposition, filename), expires))
+	if err != nil {
+		if errors.Is(err, errDownloadNotFound) {
+			respondError(w, http.StatusNotFound, "No "+file+" is stored for this asset")
+			return
+		}
+		log.Printf("Failed to sign %s download URL for asset %s: %v", file, assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to generate download URL")
+		return
+	}
+
+	// Signed URLs are bearer credentials for the file until they expire
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Download URL generated",
+		Data: map[string]interface{}{
+			"asset_id":   assetID,
+			"file":       file,
+			"filename":   filename,
+			"url":        signedURL,
+			"expires_at": expires.UTC().Format(time.RFC3339),
+		},
+	})
+}
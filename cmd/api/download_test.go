@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"proofpix/internal/models"
+)
+
+func TestDownloadURLOptions(t *testing.T) {
+	expires := time.Now().Add(downloadURLExpiry)
+
+	opts := downloadURLOptions(`attachment; filename="proofpix-certificate-a1.json"`, expires)
+	if opts.Method != "GET" || opts.Scheme != storage.SigningSchemeV4 || !opts.Expires.Equal(expires) {
+		t.Errorf("Expected a V4 GET URL expiring at %v, but got %+v", expires, opts)
+	}
+	if got := opts.QueryParameters.Get("response-content-disposition"); got != `attachment; filename="proofpix-certificate-a1.json"` {
+		t.Errorf("Expected the response disposition in the query parameters, but got %q", got)
+	}
+
+	if opts := downloadURLOptions("", expires); opts.QueryParameters != nil {
+		t.Errorf("Expected no query parameters without a disposition, but got %v", opts.QueryParameters)
+	}
+}
+
+func TestDownloadURLOptions_SignedIntoURL(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	opts := downloadURLOptions(`attachment; filename="proofpix-badge-a1.png"`, time.Now().Add(downloadURLExpiry))
+	opts.GoogleAccessID = "signer@example.iam.gserviceaccount.com"
+	opts.PrivateKey = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	signed, err := storage.SignedURL(badgeBucket, "badges/a1.png", opts)
+	if err != nil {
+		t.Fatalf("SignedURL() failed: %v", err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("Failed to parse signed URL: %v", err)
+	}
+	if got := parsed.Query().Get("response-content-disposition"); got != `attachment; filename="proofpix-badge-a1.png"` {
+		t.Errorf("Expected the signed URL to carry the disposition, but got %q in %s", got, signed)
+	}
+}
+
+func TestHandleDownloadURL(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"a1":      {ID: "a1", UserID: "owner", Status: models.StatusCompleted},
+			"private": {ID: "private", UserID: "owner", Status: models.StatusCompleted, Visibility: models.VisibilityPrivate},
+			"pending": {ID: "pending", UserID: "owner", Status: models.StatusProcessing},
+			"no-file": {ID: "no-file", UserID: "owner", Status: models.StatusCompleted},
+		},
+	})
+
+	var signedBucket, signedDisposition string
+	var signedObjects []string
+	orig := signDownloadURL
+	signDownloadURL = func(ctx context.Context, bucketName string, objectNames []string, opts *storage.SignedURLOptions) (string, error) {
+		if strings.Contains(objectNames[0], "no-file") {
+			return "", errDownloadNotFound
+		}
+		signedBucket, signedObjects = bucketName, objectNames
+		signedDisposition = opts.QueryParameters.Get("response-content-disposition")
+		return "https://storage.example/" + bucketName + "/" + objectNames[0], nil
+	}
+	t.Cleanup(func() { signDownloadURL = orig })
+
+	testCases := []struct {
+		name                string
+		path                string
+		userID              string
+		expectedCode        int
+		expectedBucket      string
+		expectedObject      string
+		expectedDisposition string
+	}{
+		{name: "Certificate as attachment by default", path: "/api/v1/assets/a1/download-url", expectedCode: http.StatusOK,
+			expectedBucket: certificateBucket, expectedObject: "certificates/a1.json", expectedDisposition: `attachment; filename="proofpix-certificate-a1.json"`},
+		{name: "Badge inline", path: "/api/v1/assets/a1/download-url?file=badge&disposition=inline", expectedCode: http.StatusOK,
+			expectedBucket: badgeBucket, expectedObject: "badges/a1.png", expectedDisposition: `inline; filename="proofpix-badge-a1.png"`},
+		{name: "Private asset with owner", path: "/api/v1/assets/private/download-url", userID: "owner", expectedCode: http.StatusOK,
+			expectedBucket: certificateBucket, expectedObject: "certificates/private.json", expectedDisposition: `attachment; filename="proofpix-certificate-private.json"`},
+		{name: "Private asset with another user", path: "/api/v1/assets/private/download-url", userID: "someone-else", expectedCode: http.StatusNotFound},
+		{name: "Unknown file", path: "/api/v1/assets/a1/download-url?file=thumbnail", expectedCode: http.StatusBadRequest},
+		{name: "Unknown disposition", path: "/api/v1/assets/a1/download-url?disposition=download", expectedCode: http.StatusBadRequest},
+		{name: "Pending asset", path: "/api/v1/assets/pending/download-url", expectedCode: http.StatusNotFound},
+		{name: "Missing object", path: "/api/v1/assets/no-file/download-url", expectedCode: http.StatusNotFound},
+		{name: "Missing asset", path: "/api/v1/assets/missing/download-url", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			signedBucket, signedObjects, signedDisposition = "", nil, ""
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.userID != "" {
+				req = withUser(req, tc.userID)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if signedBucket != tc.expectedBucket || len(signedObjects) == 0 || signedObjects[0] != tc.expectedObject {
+				t.Errorf("Expected a URL for %s/%s, but got %s/%v", tc.expectedBucket, tc.expectedObject, signedBucket, signedObjects)
+			}
+			if signedDisposition != tc.expectedDisposition {
+				t.Errorf("Expected disposition %q, but got %q", tc.expectedDisposition, signedDisposition)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Expected Cache-Control no-store, but got %q", got)
+			}
+			var response struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Data["url"] != "https://storage.example/"+tc.expectedBucket+"/"+tc.expectedObject {
+				t.Errorf("Expected the signed URL in the response, but got %v", response.Data["url"])
+			}
+		})
+	}
+}
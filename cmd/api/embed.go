@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"proofpix/internal/models"
+)
+
+// Cache lifetimes for the embed widget. A verified widget can still change if the
+// asset is deleted, so it is cached briefly; other states are revalidated quickly
+// so the widget picks up the result once processing finishes.
+const (
+	embedVerifiedMaxAge = 300
+	embedPendingMaxAge  = 30
+)
+
+// embedCSP allows the widget to be framed anywhere while loading nothing but the
+// badge image from this server and inline styles; it contains no script.
+const embedCSP = "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'; frame-ancestors *"
+
+// embedView is the data the widget template renders
+type embedView struct {
+	AssetID   string
+	State     string // verified, pending or unknown
+	Score     int
+	Anchored  bool
+	BadgeURL  string
+	VerifyURL string
+}
+
+var embedTemplate = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>ProofPix verification</title>
+<style>
+body{margin:0;font:14px/1.4 system-ui,sans-serif;color:#1f2933}
+.pp{display:flex;align-items:center;gap:10px;padding:8px 12px;border:1px solid #d2d6dc;border-radius:8px;max-width:320px}
+.pp img{height:40px}
+.pp a{color:#1a56db}
+.pp small{display:block;color:#616e7c}
+</style>
+</head>
+<body>
+<div class="pp pp-{{.State}}">
+{{- if eq .State "verified"}}
+<img src="{{.BadgeURL}}" alt="Verified by ProofPix">
+<div><strong>Verified by ProofPix</strong>
+<small>Originality score: <span class="pp-score">{{.Score}}</span>{{if not .Anchored}} &middot; log anchoring pending{{end}}</small>
+<a href="{{.VerifyURL}}" target="_blank" rel="noopener">Verify this image</a></div>
+{{- else if eq .State "pending"}}
+<div><strong>ProofPix verification pending</strong>
+<small>This image is still being analyzed.</small>
+<a href="{{.VerifyURL}}" target="_blank" rel="noopener">Check status</a></div>
+{{- else}}
+<div><strong>Not verified by ProofPix</strong>
+<small>No verification exists for this image.</small></div>
+{{- end}}
+</div>
+</body>
+</html>
+`))
+
+// publicBaseURL returns the origin the widget's verify link points to: PUBLIC_BASE_URL when
+// set, otherwise the scheme and host the request arrived on
+func publicBaseURL(r *http.Request) string {
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// handleEmbed renders a compact, script-free verification widget for an asset that
+// sites can show in an iframe. It reflects the asset's current status.
+// Route: GET /embed/{id}
+func handleEmbed(w http.ResponseWriter, r *http.Request) {
+	assetID := r.PathValue("id")
+	view := embedView{
+		AssetID:   assetID,
+		State:     "unknown",
+		BadgeURL:  "/api/v1/badge/" + assetID,
+		VerifyURL: publicBaseURL(r) + "/api/v1/verify/" + assetID,
+	}
+	code := http.StatusOK
+	maxAge := embedPendingMaxAge
+
+	asset, err := repo.GetAsset(context.Background(), assetID)
+	switch {
+	case errors.Is(err, ErrAssetNotFound):
+		code = http.StatusNotFound
+	case err != nil:
+		log.Printf("Failed to fetch asset %s for embed: %v", assetID, err)
+		http.Error(w, "Failed to fetch asset", http.StatusInternalServerError)
+		return
+	case asset.IsDeleted(), !canVerifyAsset(r, asset):
+		code = http.StatusNotFound
+	case asset.IsQuotaExceeded():
+		// Never analyzed, so there is nothing to wait for
+	case asset.Status == models.StatusCompleted:
+		view.State = "verified"
+		view.Score = asset.OriginalityScore
+		view.Anchored = asset.TrillianLeafIndex != 0 || asset.AnchoringSkipped() != ""
+		maxAge = embedVerifiedMaxAge
+	default:
+		view.State = "pending"
+	}
+
+	var body bytes.Buffer
+	if err := embedTemplate.Execute(&body, view); err != nil {
+		log.Printf("Failed to render embed for asset %s: %v", assetID, err)
+		http.Error(w, "Failed to render widget", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", embedCSP)
+	if code == http.StatusOK && asset.IsPrivate() {
+		// Shown only to its owner, so it must stay out of shared caches
+		w.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	}
+	w.WriteHeader(code)
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestHandleEmbed(t *testing.T) {
+	t.Setenv("PUBLIC_BASE_URL", "https://verify.proofpix.example/")
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, OriginalityScore: 87, TrillianLeafIndex: 4},
+			"asset-2": {ID: "asset-2", UserID: "owner", Status: models.StatusPartial},
+			"asset-3": {ID: "asset-3", UserID: "owner", Status: models.StatusDeleted},
+			"private": {ID: "private", UserID: "owner", Status: models.StatusCompleted, OriginalityScore: 64, Visibility: models.VisibilityPrivate},
+		},
+	})
+
+	testCases := []struct {
+		name           string
+		assetID        string
+		userID         string
+		expectedCode   int
+		expectedMaxAge string
+		contains       []string
+		notContains    []string
+	}{
+		{
+			name:           "Verified asset",
+			assetID:        "asset-1",
+			expectedCode:   http.StatusOK,
+			expectedMaxAge: "max-age=300",
+			contains: []string{
+				`<span class="pp-score">87</span>`,
+				`href="https://verify.proofpix.example/api/v1/verify/asset-1"`,
+				`src="/api/v1/badge/asset-1"`,
+			},
+			notContains: []string{"anchoring pending"},
+		},
+		{
+			name:           "Pending asset",
+			assetID:        "asset-2",
+			expectedCode:   http.StatusOK,
+			expectedMaxAge: "max-age=30",
+			contains:       []string{"verification pending", "https://verify.proofpix.example/api/v1/verify/asset-2"},
+			notContains:    []string{"pp-score"},
+		},
+		{
+			name:           "Unknown asset",
+			assetID:        "missing",
+			expectedCode:   http.StatusNotFound,
+			expectedMaxAge: "max-age=30",
+			contains:       []string{"Not verified by ProofPix"},
+			notContains:    []string{"<a "},
+		},
+		{
+			name:           "Deleted asset",
+			assetID:        "asset-3",
+			expectedCode:   http.StatusNotFound,
+			expectedMaxAge: "max-age=30",
+			contains:       []string{"Not verified by ProofPix"},
+		},
+		{
+			name:           "Private asset with owner",
+			assetID:        "private",
+			userID:         "owner",
+			expectedCode:   http.StatusOK,
+			expectedMaxAge: "private, no-cache",
+			contains:       []string{`<span class="pp-score">64</span>`},
+		},
+		{
+			name:           "Private asset without auth",
+			assetID:        "private",
+			expectedCode:   http.StatusNotFound,
+			expectedMaxAge: "max-age=30",
+			contains:       []string{"Not verified by ProofPix"},
+			notContains:    []string{"pp-score"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/embed/"+tc.assetID, nil)
+			if tc.userID != "" {
+				req = withUser(req, tc.userID)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d", tc.expectedCode, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+				t.Errorf("Expected an HTML widget, but got Content-Type %q", got)
+			}
+			if got := rec.Header().Get("Cache-Control"); !strings.Contains(got, tc.expectedMaxAge) {
+				t.Errorf("Expected Cache-Control with %s, but got %q", tc.expectedMaxAge, got)
+			}
+			if got := rec.Header().Get("Content-Security-Policy"); !strings.Contains(got, "default-src 'none'") {
+				t.Errorf("Expected a restrictive Content-Security-Policy, but got %q", got)
+			}
+
+			body := rec.Body.String()
+			if strings.Contains(body, "<script") {
+				t.Errorf("Expected a script-free widget, but got %s", body)
+			}
+			for _, want := range tc.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected widget to contain %q, but got %s", want, body)
+				}
+			}
+			for _, unwanted := range tc.notContains {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("Expected widget not to contain %q, but got %s", unwanted, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+
+	"proofpix/internal/auth"
+)
+
+// Machine-readable error codes returned in the "code" field of error responses.
+// Clients should branch on the code rather than the message, which may change.
+const (
+	ErrCodeValidation     = "VALIDATION_ERROR"
+	ErrCodeUnauthorized   = auth.ErrCodeUnauthorized
+	ErrCodeForbidden      = "FORBIDDEN"
+	ErrCodeQuotaExceeded  = "QUOTA_EXCEEDED"
+	ErrCodeAssetNotFound  = "ASSET_NOT_FOUND"
+	ErrCodeNotFound       = "NOT_FOUND"
+	ErrCodeNotAcceptable  = "NOT_ACCEPTABLE"
+	ErrCodeConflict       = "CONFLICT"
+	ErrCodeGone           = "GONE"
+	ErrCodeReadOnly       = "READ_ONLY"
+	ErrCodeTooLarge       = "REQUEST_TOO_LARGE"
+	ErrCodeLeafNotFound   = "LEAF_NOT_FOUND"
+	ErrCodeLogUnavailable = "LOG_UNAVAILABLE"
+	ErrCodeInternal       = auth.ErrCodeInternal
+)
+
+// FieldError describes a problem with one request field in a validation error
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// errorCodeForStatus returns the code used when a handler does not pick a more
+// specific one
+func errorCodeForStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return ErrCodeValidation
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusNotAcceptable:
+		return ErrCodeNotAcceptable
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusGone:
+		return ErrCodeGone
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodeTooLarge
+	}
+	return ErrCodeInternal
+}
+
+// respondErrorCode sends an error response with a specific code
+func respondErrorCode(w http.ResponseWriter, statusCode int, code, message string) {
+	respondJSON(w, statusCode, Response{Success: false, Message: message, Code: code})
+}
+
+// respondValidationError sends a 400 with the fields that failed validation
+func respondValidationError(w http.ResponseWriter, message string, details ...FieldError) {
+	respondJSON(w, http.StatusBadRequest, Response{Success: false, Message: message, Code: ErrCodeValidation, Details: details})
+}
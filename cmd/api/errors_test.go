@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/models"
+)
+
+func TestErrorResponses_Codes(t *testing.T) {
+	t.Setenv("READ_ONLY", "")
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"asset-1": {ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, CreatedAt: time.Now()},
+	}})
+
+	testCases := []struct {
+		name            string
+		request         func() *http.Request
+		expectedCode    int
+		expectedErrCode string
+		expectedField   string
+	}{
+		{
+			name:            "Unknown asset on verify",
+			request:         func() *http.Request { return httptest.NewRequest(http.MethodGet, "/api/v1/verify/missing", nil) },
+			expectedCode:    http.StatusNotFound,
+			expectedErrCode: ErrCodeAssetNotFound,
+		},
+		{
+			name: "Another user's asset events",
+			request: func() *http.Request {
+				return withUser(httptest.NewRequest(http.MethodGet, "/api/v1/assets/asset-1/events", nil), "intruder")
+			},
+			expectedCode:    http.StatusNotFound,
+			expectedErrCode: ErrCodeAssetNotFound,
+		},
+		{
+			name: "Invalid admin listing parameter",
+			request: func() *http.Request {
+				return withAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/admin/assets?min_score=high", nil), "admin-1")
+			},
+			expectedCode:    http.StatusBadRequest,
+			expectedErrCode: ErrCodeValidation,
+			expectedField:   "min_score",
+		},
+		{
+			name: "Admin listing without the admin role",
+			request: func() *http.Request {
+				return withUser(httptest.NewRequest(http.MethodGet, "/api/v1/admin/assets", nil), "owner")
+			},
+			expectedCode:    http.StatusForbidden,
+			expectedErrCode: ErrCodeForbidden,
+		},
+		{
+			name:            "Unauthenticated request",
+			request:         func() *http.Request { return httptest.NewRequest(http.MethodGet, "/api/v1/assets/asset-1/events", nil) },
+			expectedCode:    http.StatusUnauthorized,
+			expectedErrCode: ErrCodeUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			serve(t, rec, tc.request())
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			var body Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Success || body.Message == "" {
+				t.Errorf("Expected success=false with a message, but got %+v", body)
+			}
+			if body.Code != tc.expectedErrCode {
+				t.Errorf("Expected code %q, but got %q", tc.expectedErrCode, body.Code)
+			}
+			if tc.expectedField != "" && (len(body.Details) != 1 || body.Details[0].Field != tc.expectedField) {
+				t.Errorf("Expected details for field %q, but got %+v", tc.expectedField, body.Details)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_ErrorShape(t *testing.T) {
+	handler := auth.VerifyFirebaseJWT(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected the request to be rejected")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/profile", nil))
+
+	var body Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusUnauthorized || body.Success || body.Code != ErrCodeUnauthorized {
+		t.Errorf("Expected a 401 with code %q, but got %d %+v", ErrCodeUnauthorized, rec.Code, body)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"proofpix/internal/auth"
+)
+
+// handleAssetEvents returns the processing audit trail of an asset to its owner
+// Route: GET /api/v1/assets/{id}/events
+func handleAssetEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "User ID not found in context")
+		return
+	}
+
+	assetID := r.PathValue("id")
+
+	// Only the owner may see the audit trail
+	if _, ok := getOwnedAsset(w, r, assetID, userID); !ok {
+		return
+	}
+
+	events, err := repo.ListEvents(r.Context(), assetID)
+	if err != nil {
+		log.Printf("Failed to list events for asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset events")
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Message: "Asset events retrieved successfully",
+		Data: map[string]interface{}{
+			"asset_id": assetID,
+			"events":   events,
+		},
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// getOwnedAsset fetches an asset and checks that it belongs to userID.
+// Assets owned by other users are reported as missing so their existence isn't leaked.
+// On failure an error response has already been written.
+func getOwnedAsset(w http.ResponseWriter, r *http.Request, assetID, userID string) (*Asset, bool) {
+	asset, err := repo.GetAsset(r.Context(), assetID)
+	if err != nil {
+		if errors.Is(err, ErrAssetNotFound) {
+			respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+			return nil, false
+		}
+		log.Printf("Failed to fetch asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+		return nil, false
+	}
+
+	if asset.UserID != userID {
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+		return nil, false
+	}
+	return asset, true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestHandleAssetEvents(t *testing.T) {
+	now := time.Now().UTC()
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "owner"},
+		},
+		events: map[string][]models.AssetEvent{
+			"asset-1": {
+				{Stage: models.StageDownloaded, Success: true, Timestamp: now},
+				{Stage: models.StageAnalyzed, Success: true, Timestamp: now},
+			},
+		},
+	})
+
+	testCases := []struct {
+		name           string
+		userID         string
+		path           string
+		expectedStatus int
+	}{
+		{name: "Owner sees events", userID: "owner", path: "/api/v1/assets/asset-1/events", expectedStatus: http.StatusOK},
+		{name: "Other user is refused", userID: "intruder", path: "/api/v1/assets/asset-1/events", expectedStatus: http.StatusNotFound},
+		{name: "Unknown asset", userID: "owner", path: "/api/v1/assets/missing/events", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(http.MethodGet, tc.path, nil), tc.userID)
+			rec := httptest.NewRecorder()
+
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, but got %d", tc.expectedStatus, rec.Code)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data struct {
+					Events []models.AssetEvent `json:"events"`
+				} `json:"data"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(body.Data.Events) != 2 || body.Data.Events[1].Stage != models.StageAnalyzed {
+				t.Errorf("Expected 2 events ending with %q, but got %+v", models.StageAnalyzed, body.Data.Events)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/models"
+)
+
+// handleProfileExport handles GET /api/v1/profile/export, streaming a ZIP of the
+// caller's certificates as certificates/{asset_id}.json, and with badges=true their
+// badges as badges/{asset_id}.png. Assets are listed a page at a time and each file
+// is copied into the archive as it is fetched, so the export is never held in memory.
+func handleProfileExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "User ID not found in context")
+		return
+	}
+
+	includeBadges := false
+	if value := r.URL.Query().Get("badges"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondValidationError(w, "Invalid query parameters", FieldError{Field: "badges", Message: "must be true or false"})
+			return
+		}
+		includeBadges = parsed
+	}
+
+	ctx := r.Context()
+	filter := AssetFilter{UserID: userID, Status: models.StatusCompleted, ExcludeDeleted: true, Limit: maxAssetPageSize}
+
+	// The first page is fetched before anything is written so a failing listing
+	// still gets an error response
+	page, nextPageToken, err := repo.ListAssets(ctx, filter)
+	if err != nil {
+		log.Printf("Failed to list assets for export by user %s: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to list assets")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="proofpix-certificates.zip"`)
+	w.WriteHeader(http.StatusOK)
+
+	archive := zip.NewWriter(w)
+	exported := 0
+	for {
+		for _, asset := range page {
+			written, err := exportAsset(ctx, archive, asset, includeBadges)
+			if err != nil {
+				// The response is already under way, so the only way left to signal
+				// the failure is an archive without its central directory
+				log.Printf("Aborting export for user %s: %v", userID, err)
+				return
+			}
+			if written {
+				exported++
+			}
+		}
+		if nextPageToken == "" {
+			break
+		}
+		filter.PageToken = nextPageToken
+		if page, nextPageToken, err = repo.ListAssets(ctx, filter); err != nil {
+			log.Printf("Aborting export for user %s: failed to list assets: %v", userID, err)
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed to finish export for user %s: %v", userID, err)
+		return
+	}
+	log.Printf("Exported %d certificates for user %s", exported, userID)
+}
+
+// exportAsset adds the asset's certificate, and its badge when asked for, to the
+// archive. Assets without a stored certificate are skipped and report false; an
+// error means the archive can no longer be written.
+func exportAsset(ctx context.Context, archive *zip.Writer, asset *Asset, includeBadges bool) (bool, error) {
+	data, err := fetchCertificate(ctx, asset)
+	if errors.Is(err, ErrCertificateNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Skipping certificate for asset %s in export: %v", asset.ID, err)
+		return false, nil
+	}
+	if err := writeArchiveFile(archive, fmt.Sprintf("certificates/%s.json", asset.ID), asset, data); err != nil {
+		return false, err
+	}
+
+	if includeBadges {
+		badge, err := fetchBadge(ctx, asset.ID)
+		switch {
+		case errors.Is(err, ErrBadgeNotFound):
+		case err != nil:
+			log.Printf("Skipping badge for asset %s in export: %v", asset.ID, err)
+		default:
+			if err := writeArchiveFile(archive, fmt.Sprintf("badges/%s.png", asset.ID), asset, badge); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// writeArchiveFile adds one file to the archive, dated with the asset's creation time
+func writeArchiveFile(archive *zip.Writer, name string, asset *Asset, data []byte) error {
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: asset.CreatedAt})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %v", name, err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestHandleProfileExport(t *testing.T) {
+	// More certified assets than fit on one page, so the export has to paginate
+	certified := maxAssetPageSize + 5
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	assets := map[string]*Asset{
+		"processing":  {ID: "processing", UserID: "alice", Status: "processing", CreatedAt: base},
+		"deleted":     {ID: "deleted", UserID: "alice", Status: models.StatusDeleted, CreatedAt: base, DeletedAt: base},
+		"uncertified": {ID: "uncertified", UserID: "alice", Status: models.StatusCompleted, CreatedAt: base},
+		"bob-1":       {ID: "bob-1", UserID: "bob", Status: models.StatusCompleted, CreatedAt: base},
+	}
+	certificates := map[string][]byte{
+		"processing": []byte(`{}`),
+		"deleted":    []byte(`{}`),
+		"bob-1":      []byte(`{}`),
+	}
+	for i := 0; i < certified; i++ {
+		id := fmt.Sprintf("alice-%03d", i)
+		assets[id] = &Asset{ID: id, UserID: "alice", Status: models.StatusCompleted, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		certificates[id] = []byte(fmt.Sprintf(`{"id":%q}`, id))
+	}
+	useFakeRepository(t, &fakeRepository{assets: assets})
+	useFakeCertificates(t, certificates)
+
+	orig := fetchBadge
+	fetchBadge = func(ctx context.Context, assetID string) ([]byte, error) {
+		if assetID == "alice-000" {
+			return nil, ErrBadgeNotFound
+		}
+		return []byte("\x89PNG " + assetID), nil
+	}
+	t.Cleanup(func() { fetchBadge = orig })
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedBadges int
+	}{
+		{name: "Certificates only", query: "", expectedBadges: 0},
+		{name: "With badges", query: "?badges=true", expectedBadges: certified - 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(http.MethodGet, "/api/v1/profile/export"+tc.query, nil), "alice")
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/zip" {
+				t.Errorf("Expected Content-Type application/zip, but got %q", got)
+			}
+
+			archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+			if err != nil {
+				t.Fatalf("Expected a valid ZIP, but got %v", err)
+			}
+			entries := map[string]string{}
+			for _, file := range archive.File {
+				reader, err := file.Open()
+				if err != nil {
+					t.Fatalf("Failed to open %s: %v", file.Name, err)
+				}
+				data, _ := io.ReadAll(reader)
+				reader.Close()
+				entries[file.Name] = string(data)
+			}
+
+			if len(entries) != certified+tc.expectedBadges {
+				t.Errorf("Expected %d entries, but got %d", certified+tc.expectedBadges, len(entries))
+			}
+			for i := 0; i < certified; i++ {
+				id := fmt.Sprintf("alice-%03d", i)
+				name := "certificates/" + id + ".json"
+				if entries[name] != string(certificates[id]) {
+					t.Errorf("Expected %s to hold the stored certificate, but got %q", name, entries[name])
+				}
+			}
+			for _, id := range []string{"processing", "deleted", "uncertified", "bob-1"} {
+				if _, ok := entries["certificates/"+id+".json"]; ok {
+					t.Errorf("Expected no certificate for asset %s", id)
+				}
+			}
+			if tc.expectedBadges > 0 {
+				if got := entries["badges/alice-001.png"]; got != "\x89PNG alice-001" {
+					t.Errorf("Expected the badge of alice-001, but got %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleProfileExport_InvalidBadges(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{})
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/v1/profile/export?badges=maybe", nil), "alice")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, but got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+}
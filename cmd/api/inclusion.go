@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"proofpix/internal/bodylimit"
+	"proofpix/internal/leaf"
+)
+
+// maxInclusionRequestBytes bounds the certificate accepted by the inclusion-by-hash endpoint
+const maxInclusionRequestBytes = 1 << 20
+
+// errLeafNotLogged is returned when no logged leaf matches the submitted certificate
+var errLeafNotLogged = errors.New("certificate is not in the log")
+
+// logRootReader is the part of the Trillian log client that reads the latest root
+type logRootReader interface {
+	GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error)
+}
+
+// inclusionLog is the subset of the Trillian log client used to prove inclusion by hash
+type inclusionLog interface {
+	logRootReader
+	GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error)
+}
+
+// openInclusionLog connects to the Trillian log server. Tests replace it with a mock client.
+var openInclusionLog = func(ctx context.Context) (inclusionLog, func(), error) {
+	conn, err := dialLogServer(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return trillian.NewTrillianLogClient(conn), func() { closeLogServer(conn) }, nil
+}
+
+// inclusionByHashRequest carries either a certificate or the digest of one, hashed
+// with the algorithm of the log's tree
+type inclusionByHashRequest struct {
+	Certificate     json.RawMessage `json:"certificate"`
+	CertificateHash string          `json:"certificate_hash"`
+	// LeafFormat is the format the certificate was logged in; defaults to TRILLIAN_LEAF_FORMAT
+	LeafFormat string `json:"leaf_format"`
+}
+
+// candidateLeafValues returns the leaf values the request may have been logged as.
+// Hash leaves cover the exact stored bytes, so a certificate that was reformatted
+// in transit is also tried in the indented form the worker stores.
+func (req *inclusionByHashRequest) candidateLeafValues(hasher leaf.Hasher) ([][]byte, string, error) {
+	if req.CertificateHash != "" {
+		hash, err := hex.DecodeString(req.CertificateHash)
+		if err != nil || len(hash) != hasher.Size() {
+			return nil, "", fmt.Errorf("certificate_hash must be a hex-encoded %s digest", hasher.Algorithm())
+		}
+		return [][]byte{hash}, leaf.FormatHash, nil
+	}
+	if len(req.Certificate) == 0 {
+		return nil, "", fmt.Errorf("certificate or certificate_hash is required")
+	}
+
+	format := req.LeafFormat
+	if format == "" {
+		var err error
+		if format, err = leaf.FormatFromEnv(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	forms := [][]byte{req.Certificate}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, req.Certificate, "", "  "); err == nil && !bytes.Equal(indented.Bytes(), req.Certificate) {
+		forms = append(forms, indented.Bytes())
+	}
+
+	var values [][]byte
+	for _, form := range forms {
+		value, err := hasher.Value(format, form)
+		if err != nil {
+			return nil, "", err
+		}
+		values = append(values, value)
+	}
+	return values, format, nil
+}
+
+// handleInclusionByHash handles POST /api/v1/log/inclusion-by-hash. It lets auditors
+// holding a certificate, but not its asset ID, prove that the certificate was logged.
+func handleInclusionByHash(w http.ResponseWriter, r *http.Request) {
+	var req inclusionByHashRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxInclusionRequestBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if bodylimit.IsTooLarge(err) {
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", maxInclusionRequestBytes))
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Invalid JSON request")
+		return
+	}
+
+	hasher, err := leaf.HasherFromEnv()
+	if err != nil {
+		log.Printf("Failed to configure leaf hashing: %v", err)
+		respondError(w, http.StatusInternalServerError, "Server configuration error")
+		return
+	}
+	leafValues, format, err := req.candidateLeafValues(hasher)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logID, err := strconv.ParseInt(os.Getenv("TRILLIAN_LOG_ID"), 10, 64)
+	if err != nil {
+		log.Printf("Invalid TRILLIAN_LOG_ID: %v", err)
+		respondError(w, http.StatusInternalServerError, "Server configuration error")
+		return
+	}
+
+	ctx := r.Context()
+	client, closeLog, err := openInclusionLog(ctx)
+	if err != nil {
+		log.Printf("Failed to connect to Trillian: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to connect to log")
+		return
+	}
+	defer closeLog()
+
+	root, err := latestLogRoot(ctx, client, logID)
+	if err != nil {
+		log.Printf("Failed to get latest log root: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve log root")
+		return
+	}
+
+	for _, leafValue := range leafValues {
+		leafHash := hasher.HashLeaf(leafValue)
+		proof, err := inclusionProofByHash(ctx, client, logID, leafHash, root.TreeSize)
+		if errors.Is(err, errLeafNotLogged) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to get inclusion proof by hash: %v", err)
+			respondError(w, http.StatusInternalServerError, "Failed to retrieve inclusion proof")
+			return
+		}
+
+		hashes := make([]string, len(proof.Hashes))
+		for i, h := range proof.Hashes {
+			hashes[i] = hex.EncodeToString(h)
+		}
+		respondJSON(w, http.StatusOK, Response{
+			Success: true,
+			Message: "Certificate is included in the log",
+			Data: map[string]interface{}{
+				"leaf_format": format,
+				"leaf_hash":   hex.EncodeToString(leafHash),
+				"leaf_index":  proof.LeafIndex,
+				"tree_size":   root.TreeSize,
+				"root_hash":   hex.EncodeToString(root.RootHash),
+				"proof":       hashes,
+			},
+		})
+		return
+	}
+
+	respondError(w, http.StatusNotFound, "Certificate not found in the log")
+}
+
+// latestLogRoot fetches and decodes the log's latest signed root
+func latestLogRoot(ctx context.Context, client logRootReader, logID int64) (*types.LogRootV1, error) {
+	response, err := client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: logID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest log root: %v", err)
+	}
+	if response.SignedLogRoot == nil {
+		return nil, fmt.Errorf("log %d returned no signed root", logID)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(response.SignedLogRoot.LogRoot); err != nil {
+		return nil, fmt.Errorf("failed to decode log root: %v", err)
+	}
+	return &root, nil
+}
+
+// inclusionProofByHash returns the proof for a Merkle leaf hash, or errLeafNotLogged
+func inclusionProofByHash(ctx context.Context, client inclusionLog, logID int64, leafHash []byte, treeSize uint64) (*trillian.Proof, error) {
+	response, err := client.GetInclusionProofByHash(ctx, &trillian.GetInclusionProofByHashRequest{
+		LogId:    logID,
+		LeafHash: leafHash,
+		TreeSize: int64(treeSize),
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, errLeafNotLogged
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inclusion proof by hash from Trillian log %d: %w", logID, err)
+	}
+	if len(response.Proof) == 0 {
+		return nil, errLeafNotLogged
+	}
+	return response.Proof[0], nil
+}
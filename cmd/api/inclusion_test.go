@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"proofpix/internal/leaf"
+)
+
+// mockInclusionLog answers proofs for the Merkle leaf hashes it holds
+type mockInclusionLog struct {
+	treeSize uint64
+	leaves   map[string]int64
+}
+
+func (l *mockInclusionLog) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	root, err := (&types.LogRootV1{TreeSize: l.treeSize, RootHash: make([]byte, 32)}).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: root}}, nil
+}
+
+func (l *mockInclusionLog) GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	index, ok := l.leaves[string(in.LeafHash)]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "no leaf found for hash")
+	}
+	return &trillian.GetInclusionProofByHashResponse{
+		Proof: []*trillian.Proof{{LeafIndex: index, Hashes: [][]byte{make([]byte, 32)}}},
+	}, nil
+}
+
+func TestHandleInclusionByHash(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LEAF_FORMAT", "")
+
+	// The worker stores certificates indented; hash leaves cover those exact bytes
+	stored := []byte("{\n  \"id\": \"urn:proofpix:asset:asset-1\"\n}")
+	storedHash := sha256.Sum256(stored)
+	mock := &mockInclusionLog{
+		treeSize: 8,
+		leaves:   map[string]int64{string(leaf.HashLeaf(storedHash[:])): 5},
+	}
+
+	orig := openInclusionLog
+	t.Cleanup(func() { openInclusionLog = orig })
+	openInclusionLog = func(ctx context.Context) (inclusionLog, func(), error) {
+		return mock, func() {}, nil
+	}
+
+	otherHash := sha256.Sum256([]byte("never logged"))
+	testCases := []struct {
+		name          string
+		body          string
+		expectedCode  int
+		expectedIndex int64
+	}{
+		{name: "Certificate as stored", body: `{"certificate":` + string(stored) + `}`, expectedCode: http.StatusOK, expectedIndex: 5},
+		{name: "Reformatted certificate", body: `{"certificate":{"id":"urn:proofpix:asset:asset-1"}}`, expectedCode: http.StatusOK, expectedIndex: 5},
+		{name: "Certificate hash", body: `{"certificate_hash":"` + hex.EncodeToString(storedHash[:]) + `"}`, expectedCode: http.StatusOK, expectedIndex: 5},
+		{name: "Hash not logged", body: `{"certificate_hash":"` + hex.EncodeToString(otherHash[:]) + `"}`, expectedCode: http.StatusNotFound},
+		{name: "Certificate not logged", body: `{"certificate":{"id":"urn:proofpix:asset:other"}}`, expectedCode: http.StatusNotFound},
+		{name: "Malformed hash", body: `{"certificate_hash":"abc"}`, expectedCode: http.StatusBadRequest},
+		{name: "Empty request", body: `{}`, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/log/inclusion-by-hash", bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data struct {
+					LeafIndex int64    `json:"leaf_index"`
+					TreeSize  uint64   `json:"tree_size"`
+					Proof     []string `json:"proof"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Data.LeafIndex != tc.expectedIndex {
+				t.Errorf("Expected leaf index %d, but got %d", tc.expectedIndex, body.Data.LeafIndex)
+			}
+			if body.Data.TreeSize != 8 || len(body.Data.Proof) != 1 {
+				t.Errorf("Expected a proof against tree size 8, but got %+v", body.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"proofpix/internal/auth"
+)
+
+// handleListAssets lists the caller's own assets, newest first. Deleted assets are
+// only listed when status=deleted is asked for.
+// Route: GET /api/v1/assets
+// Query parameters: tag (key:value, matched exactly against the asset metadata),
+// status, min_score, max_score, created_after and created_before (RFC 3339),
+// limit and page_token.
+func handleListAssets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "User ID not found in context")
+		return
+	}
+
+	filter, fieldErr := parseAssetFilter(r)
+	if fieldErr != nil {
+		respondValidationError(w, "Invalid query parameters", *fieldErr)
+		return
+	}
+	// Users only ever see their own assets
+	filter.UserID = userID
+	filter.ExcludeDeleted = true
+
+	assets, nextPageToken, err := repo.ListAssets(r.Context(), filter)
+	if errors.Is(err, ErrInvalidPageToken) {
+		respondValidationError(w, "Invalid query parameters", FieldError{Field: "page_token", Message: "is not a valid page token"})
+		return
+	}
+	if errors.Is(err, ErrTagFilterNotIndexed) {
+		respondValidationError(w, "Invalid query parameters", FieldError{Field: "tag", Message: "key is not indexed for filtering"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to list assets for user %s: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to list assets")
+		return
+	}
+
+	views := make([]adminAssetView, 0, len(assets))
+	for _, asset := range assets {
+		views = append(views, newAdminAssetView(asset))
+	}
+
+	data := map[string]interface{}{"assets": views}
+	if nextPageToken != "" {
+		data["next_page_token"] = nextPageToken
+	}
+	respondJSON(w, http.StatusOK, Response{Success: true, Message: "Assets retrieved successfully", Data: data})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestHandleListAssets_MetadataTag(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	spring := map[string]string{"campaign": "spring"}
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"a1": {ID: "a1", UserID: "alice", Status: models.StatusCompleted, CreatedAt: day(1), Metadata: spring},
+		"a2": {ID: "a2", UserID: "alice", Status: models.StatusPartial, CreatedAt: day(2), Metadata: spring},
+		"a3": {ID: "a3", UserID: "alice", Status: models.StatusCompleted, CreatedAt: day(3), Metadata: map[string]string{"campaign": "spring-2"}},
+		"a4": {ID: "a4", UserID: "alice", Status: models.StatusCompleted, CreatedAt: day(4), Metadata: map[string]string{"theme": "spring"}},
+		"a5": {ID: "a5", UserID: "alice", Status: models.StatusDeleted, CreatedAt: day(5), DeletedAt: day(6), Metadata: spring},
+		"a6": {ID: "a6", UserID: "alice", Status: models.StatusCompleted, CreatedAt: day(6), Metadata: map[string]string{"campaign": "spring", "region": "eu:west"}},
+		"b1": {ID: "b1", UserID: "bob", Status: models.StatusCompleted, CreatedAt: day(7), Metadata: spring},
+	}})
+
+	testCases := []struct {
+		name         string
+		query        url.Values
+		expectedCode int
+		expectedIDs  string
+	}{
+		{name: "Exact key and value", query: url.Values{"tag": {"campaign:spring"}}, expectedCode: http.StatusOK, expectedIDs: "a6,a2,a1"},
+		{name: "Value containing a colon", query: url.Values{"tag": {"region:eu:west"}}, expectedCode: http.StatusOK, expectedIDs: "a6"},
+		{name: "With status", query: url.Values{"tag": {"campaign:spring"}, "status": {"completed"}}, expectedCode: http.StatusOK, expectedIDs: "a6,a1"},
+		{name: "With date range", query: url.Values{"tag": {"campaign:spring"}, "created_before": {"2024-03-02T00:00:00Z"}}, expectedCode: http.StatusOK, expectedIDs: "a1"},
+		{name: "Deleted only on request", query: url.Values{"tag": {"campaign:spring"}, "status": {"deleted"}}, expectedCode: http.StatusOK, expectedIDs: "a5"},
+		{name: "No tag lists every asset", expectedCode: http.StatusOK, expectedIDs: "a6,a4,a3,a2,a1"},
+		{name: "Other users are ignored", query: url.Values{"tag": {"campaign:spring"}, "user_id": {"bob"}}, expectedCode: http.StatusOK, expectedIDs: "a6,a2,a1"},
+		{name: "Unknown tag", query: url.Values{"tag": {"campaign:autumn"}}, expectedCode: http.StatusOK, expectedIDs: ""},
+		{name: "Missing value", query: url.Values{"tag": {"campaign:"}}, expectedCode: http.StatusBadRequest},
+		{name: "Missing separator", query: url.Values{"tag": {"campaign"}}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(http.MethodGet, "/api/v1/assets?"+tc.query.Encode(), nil), "alice")
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			var body adminListBody
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if got := assetIDs(body); got != tc.expectedIDs {
+				t.Errorf("Expected assets %q, but got %q", tc.expectedIDs, got)
+			}
+		})
+	}
+}
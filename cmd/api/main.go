@@ -1,31 +1,37 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
-	"cloud.google.com/go/firestore"
-	"cloud.google.com/go/storage"
 	"github.com/google/trillian"
 	"github.com/google/uuid"
 	"github.com/rs/cors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"proofpix/internal/auth"
+	"proofpix/internal/certificate"
+	"proofpix/internal/config"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
 )
 
-// Response represents a JSON response
+// Response represents a JSON response. Error responses also carry a
+// machine-readable Code and, for validation errors, the offending fields.
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Code    string       `json:"code,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
+	Data    interface{}  `json:"data,omitempty"`
 }
 
 // UserResponse represents a user response
@@ -38,29 +44,46 @@ type UserResponse struct {
 type AssetResponse struct {
 	AssetID   string `json:"asset_id"`
 	UploadURL string `json:"upload_url"`
+	Status    string `json:"status"`
 }
 
 // Asset represents an image asset with its analysis results
-type Asset struct {
-	ID               string    `firestore:"id"`
-	UserID           string    `firestore:"user_id"`
-	Status           string    `firestore:"status"`
-	CreatedAt        time.Time `firestore:"created_at"`
-	RawAnalysis      string    `firestore:"raw_analysis"`
-	OriginalityScore int       `firestore:"originality_score"`
-	Narrative        string    `firestore:"narrative"`
-	Embedding        []float32 `firestore:"embedding"`
-	TrillianLeafIndex int64    `firestore:"trillian_leaf_index,omitempty"`
-}
+type Asset = models.Asset
 
 func main() {
-	// Initialize Firebase
+	// Validate the configuration before anything depends on it
+	cfg, err := config.Load(config.API)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	
+	// Initialize Firebase; transient failures are retried on the next authenticated request
 	if err := auth.InitFirebase(); err != nil {
-		log.Fatalf("Failed to initialize Firebase: %v", err)
+		if errors.Is(err, auth.ErrFirebaseConfig) {
+			log.Fatalf("Failed to initialize Firebase: %v", err)
+		}
+		log.Printf("Failed to initialize Firebase, will retry on demand: %v", err)
+	}
+
+	// Tokens the optional auth routes cannot verify are ignored or rejected by policy
+	if _, err := auth.OptionalAuthPolicyFromEnv(); err != nil {
+		log.Fatalf("Failed to configure optional authentication: %v", err)
 	}
 
+	// Load the optional credential signing key used for VC-JWT downloads
+	signer, err := certificate.SigningConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure credential signing: %v", err)
+	}
+	credentialSigner = signer
+	retired, err := certificate.RetiredKeysFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load retired credential keys: %v", err)
+	}
+	retiredKeys = retired
+
 	// Setup routes with CORS middleware
-	mux := http.NewServeMux()
+	mux := newRouter()
 	
 	// Configure CORS middleware with rs/cors library  
 	c := cors.New(cors.Options{
@@ -85,8 +108,67 @@ func main() {
 		Debug:            true,
 	})
 	
-	// Wrap mux with CORS middleware
-	handler := c.Handler(mux)
+	// Wrap mux with CORS and access logging middleware
+	handler := accessLog(c.Handler(mux))
+
+	port := cfg.Port
+
+	fmt.Printf("ProofPix API server starting on port %s...\n", port)
+	fmt.Println("Available endpoints:")
+	fmt.Println("  GET  /                     - Root endpoint (public)")
+	fmt.Println("  GET  /health               - Health check (public)")
+	fmt.Println("  GET  /api/v1/public        - Public endpoint")
+	fmt.Println("  GET  /api/v1/verify/{id}   - Asset verification (public)")
+	fmt.Println("  POST /api/v1/verify/image  - Verification status of assets certifying an uploaded image (public)")
+	fmt.Println("  GET  /api/v1/badge/{id}    - Asset badge PNG, cacheable (public)")
+	fmt.Println("  POST /api/v1/log/inclusion-by-hash - Inclusion proof for a certificate or its hash (public)")
+	fmt.Println("  GET  /api/v1/certificate/{id} - Credential as JSON-LD, JSON or VC-JWT via Accept (public)")
+	fmt.Println("  GET  /api/v1/assets/{id}/proof-bundle - Credential, inclusion proof, log root and issuer key for offline verification (public)")
+	fmt.Println("  GET  /api/v1/assets/{id}/proof - Inclusion proof and log root as a standalone JSON file (public, or owner if private)")
+	fmt.Println("  GET  /api/v1/assets/{id}/provenance - Whether an asset is the original or a derivative of a near duplicate (public, or owner if private)")
+	fmt.Println("  GET  /api/v1/assets/{id}/download-url - Signed URL downloading the certificate or badge with a friendly filename (public, or owner if private)")
+	fmt.Println("  GET  /embed/{id} - Embeddable verification widget for iframes (public)")
+	fmt.Println("  GET  /v/{shortcode} - Redirect from a badge short code to the asset verification (public)")
+	fmt.Println("  GET  /.well-known/did.json - Issuer DID document with the credential signing key (public)")
+	fmt.Println("  GET  /api/v1/protected     - Protected endpoint (requires auth)")
+	fmt.Println("  GET  /api/v1/profile       - User profile (requires auth)")
+	fmt.Println("  GET  /api/v1/profile/export - ZIP of your certificates, and badges with badges=true (requires auth)")
+	fmt.Println("  POST /api/v1/assets        - Generate upload URL (requires auth)")
+	fmt.Println("  GET  /api/v1/assets        - List your assets by metadata tag, status and date (requires auth)")
+	fmt.Println("  GET  /api/v1/assets/{id}/events - Asset processing audit trail (requires auth)")
+	fmt.Println("  GET  /api/v1/assets/{id}/stream - Live processing progress as Server-Sent Events (requires auth)")
+	fmt.Println("  DELETE /api/v1/assets/{id}     - Soft-delete an asset (requires auth)")
+	fmt.Println("  POST /api/v1/assets/{id}/restore - Restore a soft-deleted asset (requires auth)")
+	fmt.Println("  POST /api/v1/assets/{id}/credential/regenerate - Re-sign the credential from current asset data (requires auth)")
+	fmt.Println("  GET  /api/v1/optional      - Optional auth endpoint")
+	fmt.Println("  GET  /api/v1/admin         - Admin endpoint (requires auth)")
+	fmt.Println("  GET  /api/v1/admin/assets  - List assets across users with filters (requires admin)")
+	fmt.Println("  GET  /api/v1/admin/assets/{id}/embedding - Stored embedding with dimension and norm for debugging (requires admin)")
+	fmt.Println("  POST /api/v1/admin/assets/requeue-failed - Send failed assets back to the worker (requires admin)")
+	fmt.Println("  GET  /api/v1/admin/log/leaf?index=N - Raw Trillian leaf and metadata at an index (requires admin)")
+	fmt.Println("  GET  /api/v1/admin/log/recent?since=RFC3339 - Assets anchored in the log since a time, paginated (requires admin)")
+	
+	log.Fatal(http.ListenAndServe(":"+port, handler))
+}
+
+// Authentication middleware applied to routes. Tests replace these with stubs.
+var (
+	requireAuth  = auth.VerifyFirebaseJWT
+	optionalAuth = auth.OptionalFirebaseJWT
+)
+
+// newRouter registers all API routes using method-aware patterns.
+// Path parameters such as {id} are read in handlers via r.PathValue.
+// Requests with an unsupported method receive 405 with an Allow header, and request
+// bodies are bounded by limitRequestBody.
+func newRouter() http.Handler {
+	mux := http.NewServeMux()
+
+	// Auth wrappers also record the user ID for the access log
+	authenticated := func(h http.HandlerFunc) http.Handler { return requireAuth(recordUser(h)) }
+	maybeAuthenticated := func(h http.HandlerFunc) http.Handler { return optionalAuth(recordUser(h)) }
+	// Mutating routes are disabled while READ_ONLY is set
+	writable := func(h http.Handler) http.Handler { return blockInReadOnly(h) }
 
 	// Public routes (no authentication required)
 	mux.HandleFunc("/health", handleHealth)
@@ -97,48 +179,47 @@ func main() {
 		w.Write([]byte("TEST HANDLER WORKING!"))
 	})
 	mux.HandleFunc("/api/v1/public", handlePublic)
-	mux.HandleFunc("/api/v1/verify/", verifyHandler)
-	
+	// Verify is public; a token is only read to allow admin-only proof details
+	mux.Handle("GET /api/v1/verify/{id}", maybeAuthenticated(verifyHandler))
+	mux.Handle("POST /api/v1/verify/image", maybeAuthenticated(handleVerifyImage))
+	mux.HandleFunc("GET /api/v1/badge/{id}", handleBadge)
+	mux.HandleFunc("POST /api/v1/log/inclusion-by-hash", handleInclusionByHash)
+	mux.Handle("GET /api/v1/certificate/{id}", maybeAuthenticated(handleCertificateDownload))
+	mux.Handle("GET /api/v1/assets/{id}/proof-bundle", maybeAuthenticated(handleProofBundle))
+	mux.Handle("GET /api/v1/assets/{id}/proof", maybeAuthenticated(handleAssetProof))
+	mux.Handle("GET /api/v1/assets/{id}/provenance", maybeAuthenticated(handleAssetProvenance))
+	mux.Handle("GET /api/v1/assets/{id}/download-url", maybeAuthenticated(handleDownloadURL))
+	mux.Handle("GET /embed/{id}", maybeAuthenticated(handleEmbed))
+	mux.HandleFunc("GET /v/{shortcode}", handleShortCode)
+	mux.HandleFunc("GET /.well-known/did.json", handleDIDDocument)
+
 	// Handle root path specifically (not as catch-all)
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Only handle exact root path
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
-		}
-		handleRoot(w, r)
-	})
+	mux.HandleFunc("/{$}", handleRoot)
 
 	// Protected routes (authentication required)
-	mux.Handle("/api/v1/protected", auth.VerifyFirebaseJWT(http.HandlerFunc(handleProtected)))
-	mux.Handle("/api/v1/profile", auth.VerifyFirebaseJWT(http.HandlerFunc(handleProfile)))
-    mux.Handle("/api/v1/assets", auth.VerifyFirebaseJWT(http.HandlerFunc(handleAssets)))
-    mux.Handle("/api/v1/assets/", auth.VerifyFirebaseJWT(http.HandlerFunc(handleAssets)))
+	mux.Handle("/api/v1/protected", authenticated(handleProtected))
+	mux.Handle("/api/v1/profile", authenticated(handleProfile))
+	mux.Handle("GET /api/v1/profile/export", authenticated(handleProfileExport))
+	mux.Handle("POST /api/v1/assets", writable(authenticated(handleAssets)))
+	mux.Handle("GET /api/v1/assets", authenticated(handleListAssets))
+	mux.Handle("DELETE /api/v1/assets/{id}", writable(authenticated(handleDeleteAsset)))
+	mux.Handle("GET /api/v1/assets/{id}/events", authenticated(handleAssetEvents))
+	mux.Handle("GET /api/v1/assets/{id}/stream", authenticated(handleAssetStream))
+	mux.Handle("POST /api/v1/assets/{id}/restore", writable(authenticated(handleRestoreAsset)))
+	mux.Handle("POST /api/v1/assets/{id}/credential/regenerate", writable(authenticated(handleRegenerateCredential)))
 
 	// Optional authentication routes (works with or without auth)
-	mux.Handle("/api/v1/optional", auth.OptionalFirebaseJWT(http.HandlerFunc(handleOptional)))
+	mux.Handle("/api/v1/optional", maybeAuthenticated(handleOptional))
 
 	// Admin routes (protected + additional checks can be added)
-	mux.Handle("/api/v1/admin", auth.VerifyFirebaseJWT(http.HandlerFunc(handleAdmin)))
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	fmt.Printf("ProofPix API server starting on port %s...\n", port)
-	fmt.Println("Available endpoints:")
-	fmt.Println("  GET  /                     - Root endpoint (public)")
-	fmt.Println("  GET  /health               - Health check (public)")
-	fmt.Println("  GET  /api/v1/public        - Public endpoint")
-	fmt.Println("  GET  /api/v1/verify/{id}   - Asset verification (public)")
-	fmt.Println("  GET  /api/v1/protected     - Protected endpoint (requires auth)")
-	fmt.Println("  GET  /api/v1/profile       - User profile (requires auth)")
-	fmt.Println("  POST /api/v1/assets        - Generate upload URL (requires auth)")
-	fmt.Println("  GET  /api/v1/optional      - Optional auth endpoint")
-	fmt.Println("  GET  /api/v1/admin         - Admin endpoint (requires auth)")
-	
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	mux.Handle("/api/v1/admin", authenticated(handleAdmin))
+	mux.Handle("GET /api/v1/admin/assets", authenticated(handleAdminListAssets))
+	mux.Handle("GET /api/v1/admin/assets/{id}/embedding", authenticated(handleAdminAssetEmbedding))
+	mux.Handle("POST /api/v1/admin/assets/requeue-failed", writable(authenticated(handleRequeueFailed)))
+	mux.Handle("GET /api/v1/admin/log/leaf", authenticated(handleAdminLogLeaf))
+	mux.Handle("GET /api/v1/admin/log/recent", authenticated(handleAdminRecentAnchored))
+
+	return limitRequestBody(mux)
 }
 
 // handleRoot handles the root endpoint
@@ -498,6 +579,23 @@ func handleOptional(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
+// isAdminRequest reports whether the request is authenticated as a user whose
+// Firebase custom claims carry the admin role
+func isAdminRequest(r *http.Request) bool {
+	user, ok := auth.GetUser(r)
+	if !ok {
+		return false
+	}
+	if customClaims, exists := user.Claims["custom_claims"]; exists {
+		if claims, ok := customClaims.(map[string]interface{}); ok {
+			if role, exists := claims["role"]; exists {
+				return role == "admin"
+			}
+		}
+	}
+	return false
+}
+
 // handleAdmin handles admin endpoints (can add additional role checks here)
 func handleAdmin(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
@@ -506,22 +604,13 @@ func handleAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, ok := auth.GetUser(r)
-	if !ok {
+	if _, ok := auth.GetUser(r); !ok {
 		respondError(w, http.StatusInternalServerError, "User not found in context")
 		return
 	}
 
 	// Here you could add additional admin role checks
-	// For example, check if user has admin role in custom claims
-	isAdmin := false
-	if customClaims, exists := user.Claims["custom_claims"]; exists {
-		if claims, ok := customClaims.(map[string]interface{}); ok {
-			if role, exists := claims["role"]; exists {
-				isAdmin = role == "admin"
-			}
-		}
-	}
+	isAdmin := isAdminRequest(r)
 
 	response := Response{
 		Success: true,
@@ -538,12 +627,6 @@ func handleAdmin(w http.ResponseWriter, r *http.Request) {
 
 // handleAssets handles asset upload requests by generating pre-signed URLs
 func handleAssets(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST method
-	if r.Method != http.MethodPost {
-		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
 	// Get authenticated user ID from context (added by middleware)
 	userID, ok := auth.GetUserID(r)
 	if !ok {
@@ -551,6 +634,27 @@ func handleAssets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Read the optional metadata tags to store on the asset
+	createReq, fieldErr := parseCreateAssetRequest(r)
+	if fieldErr != nil {
+		respondValidationError(w, "Invalid asset request", *fieldErr)
+		return
+	}
+
+	// Enforce the per-user asset quota before issuing an upload URL
+	ctx := context.Background()
+	allowed, count, quota, err := checkAssetQuota(ctx, r, userID)
+	if err != nil {
+		log.Printf("Failed to check asset quota for user %s: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to check storage quota")
+		return
+	}
+	if !allowed {
+		log.Printf("User %s is over quota (%d of %d assets)", userID, count, quota)
+		respondErrorCode(w, http.StatusForbidden, ErrCodeQuotaExceeded, fmt.Sprintf("Storage quota exceeded: %d of %d assets used", count, quota))
+		return
+	}
+
 	// Generate a new unique asset ID
 	assetID := uuid.New().String()
 
@@ -565,33 +669,25 @@ func handleAssets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create Google Cloud Storage client
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
+	uploadURL, err := signUploadURL(ctx, bucketName, objectName)
 	if err != nil {
-		log.Printf("Failed to create storage client: %v", err)
-		respondError(w, http.StatusInternalServerError, "Storage service unavailable")
+		log.Printf("Failed to generate signed URL: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to generate upload URL")
 		return
 	}
-	defer client.Close()
 
-	// Get bucket handle
-	bucket := client.Bucket(bucketName)
-
-	// Generate signed URL for PUT operation
-	opts := &storage.SignedURLOptions{
-		Scheme:  storage.SigningSchemeV4,
-		Method:  "PUT",
-		Headers: []string{
-			"Content-Type:image/jpeg",
-		},
-		Expires: time.Now().Add(15 * time.Minute), // 15 minutes expiry
+	// Record the asset before handing out its URL, so it can be verified while pending
+	pending := &Asset{
+		ID:           assetID,
+		UserID:       userID,
+		Status:       models.StatusAwaitingUpload,
+		CreatedAt:    time.Now().UTC(),
+		Metadata:     createReq.Metadata,
+		UploadBucket: bucketName,
 	}
-
-	uploadURL, err := bucket.SignedURL(objectName, opts)
-	if err != nil {
-		log.Printf("Failed to generate signed URL: %v", err)
-		respondError(w, http.StatusInternalServerError, "Failed to generate upload URL")
+	if err := repo.SaveAsset(ctx, pending); err != nil {
+		log.Printf("Failed to create pending asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to create asset")
 		return
 	}
 
@@ -599,6 +695,7 @@ func handleAssets(w http.ResponseWriter, r *http.Request) {
 	assetResponse := AssetResponse{
 		AssetID:   assetID,
 		UploadURL: uploadURL,
+		Status:    pending.Status,
 	}
 
 	response := Response{
@@ -612,56 +709,42 @@ func handleAssets(w http.ResponseWriter, r *http.Request) {
 
 // verifyHandler handles asset verification requests
 func verifyHandler(w http.ResponseWriter, r *http.Request) {
-	// Only allow GET method
-	if r.Method != http.MethodGet {
-		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	// Parse assetID from URL path
-	// Expected path: /api/v1/verify/{assetID}
-	path := r.URL.Path
-	const prefix = "/api/v1/verify/"
-	
-	if !strings.HasPrefix(path, prefix) {
-		respondError(w, http.StatusBadRequest, "Invalid verify path")
-		return
-	}
-	
-	assetID := strings.TrimPrefix(path, prefix)
+	// Asset ID comes from the route: GET /api/v1/verify/{id}
+	assetID := r.PathValue("id")
 	if assetID == "" {
-		respondError(w, http.StatusBadRequest, "Asset ID is required")
+		respondValidationError(w, "Asset ID is required", FieldError{Field: "id", Message: "is required"})
 		return
 	}
 	
 	// Log the assetID to console
 	log.Printf("Verify request received for assetID: %s", assetID)
 	
-	// Get project ID from environment
-	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
-	if projectID == "" {
-		log.Printf("GOOGLE_CLOUD_PROJECT environment variable not set")
-		respondError(w, http.StatusInternalServerError, "Server configuration error")
+	// Consumers can ask for a tamper-evident result signed with the credential key
+	w.Header().Set("Vary", "Accept")
+	wantJWT := wantsVerificationJWT(r)
+	if wantJWT && credentialSigner == nil {
+		respondError(w, http.StatusNotAcceptable, "Signed verification results are not available: no signing key is configured")
 		return
 	}
 	
-	// Initialize Firestore client
+	// Fully logged and failed assets verify the same way until they change, so their
+	// response is served from memory without reading the asset, certificate or leaf
+	// again; a logged one only costs a read of the log root to check it is current
 	ctx := context.Background()
-	client, err := firestore.NewClient(ctx, projectID)
-	if err != nil {
-		log.Printf("Failed to create Firestore client: %v", err)
-		respondError(w, http.StatusInternalServerError, "Database service unavailable")
-		return
+	cacheable := !wantJWT && r.URL.Query().Get("verbose") != "true"
+	if cacheable {
+		if entry, ok := verifyCache.get(assetID); ok && entry.current(ctx) {
+			respondCachedVerification(w, r, entry)
+			return
+		}
 	}
-	defer client.Close()
 	
-	// Fetch the asset document from Firestore
-	docRef := client.Collection("assets").Doc(assetID)
-	docSnap, err := docRef.Get(ctx)
+	// Fetch the asset document
+	asset, err := repo.GetAsset(ctx, assetID)
 	if err != nil {
-		if firestore.IsNotFound(err) {
+		if errors.Is(err, ErrAssetNotFound) {
 			log.Printf("Asset not found: %s", assetID)
-			respondError(w, http.StatusNotFound, "Asset not found")
+			respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
 			return
 		}
 		log.Printf("Failed to fetch asset %s: %v", assetID, err)
@@ -669,26 +752,124 @@ func verifyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Unmarshal the document data into Asset struct
-	var asset Asset
-	if err := docSnap.DataTo(&asset); err != nil {
-		log.Printf("Failed to unmarshal asset %s: %v", assetID, err)
-		respondError(w, http.StatusInternalServerError, "Failed to parse asset data")
+	// Soft-deleted assets are no longer publicly verifiable
+	if asset.IsDeleted() {
+		log.Printf("Asset %s is deleted", assetID)
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
 		return
 	}
 	
-	// Check if asset has been logged to Trillian
-	if asset.TrillianLeafIndex == 0 {
+	// Private assets are verifiable only by their owner; anyone else is told they do not exist
+	if !canVerifyAsset(r, asset) {
+		log.Printf("Asset %s is private, refusing verification to a non-owner", assetID)
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+		return
+	}
+	
+	// Partially processed assets are awaiting a retry of the failed stage
+	if asset.IsPartial() {
+		response := Response{
+			Success: true,
+			Message: "Asset partially processed; awaiting retry of the failed stage",
+			Data: map[string]interface{}{
+				"asset_id":         assetID,
+				"status":           models.StatusPartial,
+				"logged":           false,
+				"analysis_failed":  asset.AnalysisFailed,
+				"embedding_failed": asset.EmbeddingFailed,
+				"model_version":    asset.ModelVersion,
+			},
+		}
+		respondFailedVerification(w, assetID, asset, cacheable, http.StatusAccepted, response)
+		return
+	}
+	
+	// Assets created at upload time have nothing to verify until the worker finishes
+	if asset.IsPending() {
+		message := "Asset awaiting upload"
+		if asset.Status == models.StatusProcessing {
+			message = "Asset is being processed"
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		respondJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Message: message,
+			Data: map[string]interface{}{
+				"asset_id": assetID,
+				"status":   asset.Status,
+				"logged":   false,
+			},
+		})
+		return
+	}
+	
+	// Assets blocked by the owner's analysis budget were never analyzed or certified
+	if asset.IsQuotaExceeded() {
 		response := Response{
 			Success: true,
-			Message: "Asset found but not yet included in the log",
+			Message: "Asset not analyzed: the owner's monthly analysis budget is exhausted",
 			Data: map[string]interface{}{
 				"asset_id": assetID,
-				"status":   "pending_inclusion",
+				"status":   models.StatusQuotaExceeded,
 				"logged":   false,
 			},
 		}
-		respondJSON(w, http.StatusAccepted, response)
+		respondFailedVerification(w, assetID, asset, cacheable, http.StatusAccepted, response)
+		return
+	}
+	
+	// Re-check the stored certificate against the asset, independently of log inclusion
+	certStatus, certDetail, certData := checkCertificate(ctx, asset)
+	if certStatus == certificateInconsistent {
+		response := Response{
+			Success: false,
+			Message: "Stored certificate is inconsistent with the asset",
+			Data: map[string]interface{}{
+				"asset_id":           assetID,
+				"status":             "certificate_inconsistent",
+				"certificate_status": certStatus,
+				"certificate_detail": certDetail,
+				"logged":             asset.TrillianLeafIndex != 0,
+			},
+		}
+		respondFailedVerification(w, assetID, asset, cacheable, http.StatusConflict, response)
+		return
+	}
+	
+	// The uploader opted out of log anchoring, or the asset scored below the worker's
+	// minimum for it, so there is no inclusion to wait for
+	if skipped := asset.AnchoringSkipped(); skipped != "" {
+		if wantJWT {
+			respondSignedVerification(w, http.StatusOK, newVerificationResult(asset, "not_anchored", certStatus))
+			return
+		}
+		message := "Asset certified but not anchored in the log by the uploader's choice"
+		if skipped == models.AnchoringBelowMinScore {
+			message = "Asset certified but not anchored in the log: its originality score is below the minimum for anchoring"
+		}
+		response := Response{
+			Success: true,
+			Message: message,
+			Data: map[string]interface{}{
+				"asset_id":           assetID,
+				"status":             "not_anchored",
+				"anchoring":          skipped,
+				"logged":             false,
+				"certificate_status": certStatus,
+				"model_version":      asset.ModelVersion,
+			},
+		}
+		respondJSON(w, http.StatusOK, response)
+		return
+	}
+	
+	// Check if asset has been logged to Trillian
+	if asset.TrillianLeafIndex == 0 {
+		if wantJWT {
+			respondSignedVerification(w, http.StatusAccepted, newVerificationResult(asset, "pending_inclusion", certStatus))
+			return
+		}
+		respondPendingInclusion(w, asset, certStatus, 0)
 		return
 	}
 	
@@ -707,28 +888,127 @@ func verifyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Call getInclusionProof function
-	inclusionProofResponse, err := getInclusionProof(ctx, logID, asset.TrillianLeafIndex)
+	// Leaves are rebuilt with the hash algorithm of the log's tree
+	hasher, err := leaf.HasherFromEnv()
 	if err != nil {
-		log.Printf("Failed to get inclusion proof for asset %s: %v", assetID, err)
-		respondError(w, http.StatusInternalServerError, "Failed to retrieve inclusion proof")
+		log.Printf("Failed to configure leaf hashing: %v", err)
+		respondError(w, http.StatusInternalServerError, "Server configuration error")
 		return
 	}
 	
-	// Set Content-Type header to application/json
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	// Admins can ask for a step-by-step account of the inclusion proof check
+	if r.URL.Query().Get("verbose") == "true" && isAdminRequest(r) {
+		respondVerboseVerification(w, ctx, logID, hasher, asset, certStatus, certData)
+		return
+	}
+	if wantJWT {
+		respondVerificationJWT(w, ctx, logID, hasher, asset, certStatus, certData)
+		return
+	}
+	
+	// Prove inclusion against the log's latest signed root, which Trillian needs the
+	// tree size of, and count the leaf's confirmations from the same root
+	root, err := fetchLogRoot(ctx, logID)
+	if err != nil {
+		log.Printf("Failed to fetch log root for asset %s: %v", assetID, err)
+		respondTrillianError(w, err, "Failed to retrieve log root")
+		return
+	}
+	if uint64(asset.TrillianLeafIndex) >= root.TreeSize {
+		respondPendingInclusion(w, asset, certStatus, asset.TrillianLeafIndex)
+		return
+	}
+	
+	// A logged asset verifies the same way until its leaf changes or the tree grows,
+	// so clients holding the current response can skip the other log round trips
+	etag := verifyETag(asset, root.TreeSize)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", verifyCacheControl(asset))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	
+	// Compare the logged leaf with the stored certificate in the format it was logged with
+	if certData != nil {
+		leafValue, err := fetchLeafValue(ctx, logID, asset.TrillianLeafIndex)
+		if isNotYetIntegrated(err) {
+			respondPendingInclusion(w, asset, certStatus, asset.TrillianLeafIndex)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to fetch leaf %d for asset %s: %v", asset.TrillianLeafIndex, assetID, err)
+			respondTrillianError(w, err, "Failed to retrieve log leaf")
+			return
+		}
+		matched, err := hasher.Matches(asset.TrillianLeafFormat, certData, leafValue)
+		if err != nil {
+			log.Printf("Failed to compare leaf for asset %s: %v", assetID, err)
+			respondError(w, http.StatusInternalServerError, "Failed to compare log leaf")
+			return
+		}
+		if !matched {
+			response := Response{
+				Success: false,
+				Message: "Logged leaf does not match the stored certificate",
+				Data: map[string]interface{}{
+					"asset_id":    assetID,
+					"status":      "leaf_mismatch",
+					"leaf_format": asset.TrillianLeafFormat,
+					"leaf_index":  asset.TrillianLeafIndex,
+					"logged":      true,
+				},
+			}
+			respondFailedVerification(w, assetID, asset, cacheable, http.StatusConflict, response)
+			return
+		}
+	}
+	
+	inclusionProofResponse, err := fetchInclusionProof(ctx, logID, asset.TrillianLeafIndex, int64(root.TreeSize))
+	if isNotYetIntegrated(err) {
+		respondPendingInclusion(w, asset, certStatus, asset.TrillianLeafIndex)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get inclusion proof for asset %s: %v", assetID, err)
+		respondTrillianError(w, err, "Failed to retrieve inclusion proof")
+		return
+	}
 	
-	// Marshal the inclusion proof response to JSON and write it
-	if err := json.NewEncoder(w).Encode(inclusionProofResponse); err != nil {
+	// Marshal the inclusion proof response, with the leaf's confirmations, to JSON
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(newLoggedVerification(asset, inclusionProofResponse, root)); err != nil {
 		log.Printf("Error encoding inclusion proof response to JSON: %v", err)
-		// Response headers already sent, so we can't change status code
+		respondError(w, http.StatusInternalServerError, "Failed to encode inclusion proof")
 		return
 	}
+	
+	// Only responses that are the same for every caller and cannot turn out
+	// differently on a retry are cached for the logged TTL
+	if cacheable && certStatus == certificateConsistent && !asset.IsPrivate() {
+		verifyCache.put(assetID, cachedVerification{outcome: verifyOutcomeLogged, code: http.StatusOK, body: body.Bytes(), certStatus: certStatus, etag: etag, modelVersion: asset.ModelVersion, treeSize: root.TreeSize})
+	}
+	
+	// Set Content-Type header to application/json
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Certificate-Status", certStatus)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", verifyCacheControl(asset))
+	if asset.ModelVersion != "" {
+		w.Header().Set("X-Model-Version", asset.ModelVersion)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
 }
 
-// getInclusionProof retrieves an inclusion proof from the Trillian log server
-func getInclusionProof(ctx context.Context, logID int64, leafIndex int64) (*trillian.GetInclusionProofResponse, error) {
+// Trillian calls made by the verify endpoint. Tests replace them with a mock log.
+var (
+	fetchInclusionProof = getInclusionProof
+	fetchLeafValue      = getLeafValue
+)
+
+// dialLogServer opens a gRPC connection to the Trillian log server in TRILLIAN_LOG_SERVER_ADDR
+func dialLogServer(ctx context.Context) (*grpc.ClientConn, error) {
 	// Read TRILLIAN_LOG_SERVER_ADDR from environment variable
 	logServerAddr := os.Getenv("TRILLIAN_LOG_SERVER_ADDR")
 	if logServerAddr == "" {
@@ -741,13 +1021,49 @@ func getInclusionProof(ctx context.Context, logID int64, leafIndex int64) (*tril
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Trillian Log Server at %s: %v", logServerAddr, err)
 	}
+	return conn, nil
+}
+
+// closeLogServer closes a connection opened by dialLogServer, logging any error
+func closeLogServer(conn *grpc.ClientConn) {
+	if closeErr := conn.Close(); closeErr != nil {
+		log.Printf("Error closing gRPC connection: %v", closeErr)
+	}
+}
+
+// getLeafValue reads the value of a single leaf from the Trillian log
+func getLeafValue(ctx context.Context, logID int64, leafIndex int64) ([]byte, error) {
+	conn, err := dialLogServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeLogServer(conn)
+	
+	client := trillian.NewTrillianLogClient(conn)
+	response, err := client.GetLeavesByRange(ctx, &trillian.GetLeavesByRangeRequest{
+		LogId:      logID,
+		StartIndex: leafIndex,
+		Count:      1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaf %d from Trillian log %d: %w", leafIndex, logID, err)
+	}
+	if len(response.Leaves) == 0 {
+		return nil, fmt.Errorf("leaf %d in Trillian log %d: %w", leafIndex, logID, errLeafNotIntegrated)
+	}
+	return response.Leaves[0].LeafValue, nil
+}
+
+// getInclusionProof retrieves an inclusion proof from the Trillian log server for
+// the tree of the given size, which must include the leaf
+func getInclusionProof(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+	conn, err := dialLogServer(ctx)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Ensure the gRPC connection is properly closed
-	defer func() {
-		if closeErr := conn.Close(); closeErr != nil {
-			log.Printf("Error closing gRPC connection: %v", closeErr)
-		}
-	}()
+	defer closeLogServer(conn)
 	
 	// Create a trillian.TrillianLogClient
 	client := trillian.NewTrillianLogClient(conn)
@@ -756,12 +1072,13 @@ func getInclusionProof(ctx context.Context, logID int64, leafIndex int64) (*tril
 	request := &trillian.GetInclusionProofRequest{
 		LogId:     logID,
 		LeafIndex: leafIndex,
+		TreeSize:  treeSize,
 	}
 	
 	log.Printf("Requesting inclusion proof for log %d, leaf index %d", logID, leafIndex)
 	response, err := client.GetInclusionProof(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get inclusion proof from Trillian log %d for leaf %d: %v", logID, leafIndex, err)
+		return nil, fmt.Errorf("failed to get inclusion proof from Trillian log %d for leaf %d: %w", logID, leafIndex, err)
 	}
 	
 	log.Printf("Successfully retrieved inclusion proof for log %d, leaf index %d", logID, leafIndex)
@@ -782,9 +1099,5 @@ func respondJSON(w http.ResponseWriter, statusCode int, data interface{}) {
 
 // respondError sends an error response
 func respondError(w http.ResponseWriter, statusCode int, message string) {
-	response := Response{
-		Success: false,
-		Message: message,
-	}
-	respondJSON(w, statusCode, response)
+	respondErrorCode(w, statusCode, errorCodeForStatus(statusCode), message)
 } 
\ No newline at end of file
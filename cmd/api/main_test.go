@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/types"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/models"
+)
+
+// fakeRepository is an in-memory AssetRepository used by handler tests
+type fakeRepository struct {
+	assets map[string]*Asset
+	events map[string][]models.AssetEvent
+	quotas map[string]int
+	// shortCodes maps short codes to asset IDs
+	shortCodes map[string]string
+}
+
+func (f *fakeRepository) GetAsset(ctx context.Context, assetID string) (*Asset, error) {
+	asset, ok := f.assets[assetID]
+	if !ok {
+		return nil, ErrAssetNotFound
+	}
+	return asset, nil
+}
+
+func (f *fakeRepository) SaveAsset(ctx context.Context, asset *Asset) error {
+	stored := *asset
+	f.assets[asset.ID] = &stored
+	return nil
+}
+
+func (f *fakeRepository) ListEvents(ctx context.Context, assetID string) ([]models.AssetEvent, error) {
+	return f.events[assetID], nil
+}
+
+func (f *fakeRepository) CountUserAssets(ctx context.Context, userID string) (int, error) {
+	count := 0
+	for _, asset := range f.assets {
+		if asset.UserID == userID && !asset.IsDeleted() && asset.Status != models.StatusAwaitingUpload {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeRepository) GetUserQuota(ctx context.Context, userID string) (int, bool, error) {
+	quota, ok := f.quotas[userID]
+	return quota, ok, nil
+}
+
+func (f *fakeRepository) ListAssets(ctx context.Context, filter AssetFilter) ([]*Asset, string, error) {
+	var after time.Time
+	var afterID string
+	if filter.PageToken != "" {
+		var err error
+		if after, afterID, err = decodePageToken(filter.PageToken); err != nil {
+			return nil, "", err
+		}
+	}
+
+	matching := []*Asset{}
+	for _, asset := range f.assets {
+		if !filter.Matches(asset) {
+			continue
+		}
+		if afterID != "" && !asset.CreatedAt.Before(after) && !(asset.CreatedAt.Equal(after) && asset.ID < afterID) {
+			continue
+		}
+		matching = append(matching, asset)
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		if !matching[i].CreatedAt.Equal(matching[j].CreatedAt) {
+			return matching[i].CreatedAt.After(matching[j].CreatedAt)
+		}
+		return matching[i].ID > matching[j].ID
+	})
+
+	if len(matching) > filter.pageSize() {
+		page := matching[:filter.pageSize()]
+		return page, encodePageToken(page[len(page)-1]), nil
+	}
+	return matching, "", nil
+}
+
+func (f *fakeRepository) FindAssetsByImageHash(ctx context.Context, imageHash string) ([]*Asset, error) {
+	matching := []*Asset{}
+	for _, asset := range f.assets {
+		if asset.ImageHash == imageHash {
+			matching = append(matching, asset)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID < matching[j].ID })
+	return matching, nil
+}
+
+func (f *fakeRepository) FindRelatedAssets(ctx context.Context, assetID string) ([]*Asset, error) {
+	matching := []*Asset{}
+	for _, asset := range f.assets {
+		if asset.RelatedAsset != nil && asset.RelatedAsset.AssetID == assetID {
+			matching = append(matching, asset)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID < matching[j].ID })
+	return matching, nil
+}
+
+func (f *fakeRepository) ResolveShortCode(ctx context.Context, code string) (string, error) {
+	assetID, ok := f.shortCodes[code]
+	if !ok {
+		return "", ErrAssetNotFound
+	}
+	return assetID, nil
+}
+
+// useFakeRepository installs a fake repository for the duration of the test
+func useFakeRepository(t *testing.T, fake *fakeRepository) {
+	t.Helper()
+	orig := repo
+	repo = fake
+	// Cached verify responses belong to the previous repository's assets
+	verifyCache.clear()
+	t.Cleanup(func() {
+		repo = orig
+		verifyCache.clear()
+	})
+}
+
+// useFakeCertificates serves stored certificates from memory for the duration of the test
+func useFakeCertificates(t *testing.T, certificates map[string][]byte) {
+	t.Helper()
+	orig := fetchCertificate
+	fetchCertificate = func(ctx context.Context, asset *Asset) ([]byte, error) {
+		data, ok := certificates[asset.ID]
+		if !ok {
+			return nil, ErrCertificateNotFound
+		}
+		return data, nil
+	}
+	// Cached verify responses were checked against the previous certificates
+	verifyCache.clear()
+	t.Cleanup(func() { fetchCertificate = orig })
+}
+
+// withUser returns a copy of the request authenticated as the given user
+func withUser(r *http.Request, userID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, userID))
+}
+
+// serve routes the request through newRouter with authentication stubbed out:
+// requests carrying a user from withUser are treated as authenticated.
+func serve(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	t.Helper()
+	origRequire, origOptional := requireAuth, optionalAuth
+	t.Cleanup(func() { requireAuth, optionalAuth = origRequire, origOptional })
+
+	requireAuth = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := auth.GetUserID(r); !ok {
+				respondError(w, http.StatusUnauthorized, "Missing Authorization header")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	optionalAuth = func(next http.Handler) http.Handler { return next }
+
+	newRouter().ServeHTTP(w, r)
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{name: "Upload with PUT", method: http.MethodPut, path: "/api/v1/assets"},
+		{name: "Verify with POST", method: http.MethodPost, path: "/api/v1/verify/asset-1"},
+		{name: "Events with DELETE", method: http.MethodDelete, path: "/api/v1/assets/asset-1/events"},
+		{name: "Restore with GET", method: http.MethodGet, path: "/api/v1/assets/asset-1/restore"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(tc.method, tc.path, nil), "owner")
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("Expected status %d, but got %d", http.StatusMethodNotAllowed, rec.Code)
+			}
+			if rec.Header().Get("Allow") == "" {
+				t.Errorf("Expected an Allow header on 405 responses")
+			}
+		})
+	}
+}
+
+func TestRouter_PathParameterExtraction(t *testing.T) {
+	// The fake only knows "asset-42"; a 200 proves {id} was extracted correctly
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-42": {ID: "asset-42", UserID: "owner"},
+		},
+	})
+
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/v1/assets/asset-42/events", nil), "owner")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouter_UnknownPathIsNotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/does-not-exist", nil)
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, but got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+// useFakeLogRoot serves a latest log root of the given tree size for the duration of the test
+func useFakeLogRoot(t *testing.T, treeSize uint64) {
+	t.Helper()
+	orig := fetchLogRoot
+	fetchLogRoot = func(ctx context.Context, logID int64) (*types.LogRootV1, error) {
+		return &types.LogRootV1{TreeSize: treeSize}, nil
+	}
+	t.Cleanup(func() { fetchLogRoot = orig })
+}
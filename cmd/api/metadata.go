@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Limits on the metadata tags an uploader attaches to a new asset
+const (
+	maxMetadataTags        = 20
+	maxMetadataValueLength = 256
+)
+
+// metadataKeyPattern restricts tag keys to names that need no quoting in a Firestore
+// field path and cannot be confused with the key:value separator of the tag filter
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// createAssetRequest is the optional JSON body of POST /api/v1/assets
+type createAssetRequest struct {
+	// Metadata holds key/value tags to list the asset by, such as campaign: spring
+	Metadata map[string]string `json:"metadata"`
+}
+
+// parseCreateAssetRequest reads the optional body of an upload request. An empty body
+// asks for an asset without metadata.
+func parseCreateAssetRequest(r *http.Request) (createAssetRequest, *FieldError) {
+	var req createAssetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return req, &FieldError{Field: "body", Message: "must be a JSON object"}
+	}
+	if len(req.Metadata) > maxMetadataTags {
+		return req, &FieldError{Field: "metadata", Message: fmt.Sprintf("must hold at most %d tags", maxMetadataTags)}
+	}
+	for key, value := range req.Metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return req, &FieldError{Field: "metadata", Message: fmt.Sprintf("key %q must be 1 to 64 letters, digits, underscores or hyphens", key)}
+		}
+		if value == "" || utf8.RuneCountInString(value) > maxMetadataValueLength {
+			return req, &FieldError{Field: "metadata", Message: fmt.Sprintf("value of %q must be 1 to %d characters", key, maxMetadataValueLength)}
+		}
+	}
+	return req, nil
+}
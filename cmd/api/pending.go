@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultIntegrationInterval is how often the Trillian log signer integrates queued leaves
+const defaultIntegrationInterval = 60 * time.Second
+
+// errLeafNotIntegrated is returned when a queued leaf is not yet part of the log tree
+var errLeafNotIntegrated = errors.New("leaf not yet integrated into the log")
+
+// integrationInterval returns the log integration interval from TRILLIAN_INTEGRATION_INTERVAL
+func integrationInterval() time.Duration {
+	interval := defaultIntegrationInterval
+	if value := os.Getenv("TRILLIAN_INTEGRATION_INTERVAL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			interval = parsed
+		} else {
+			log.Printf("Invalid TRILLIAN_INTEGRATION_INTERVAL %q, using default of %s", value, defaultIntegrationInterval)
+		}
+	}
+	return interval
+}
+
+// estimatedInclusionWait estimates how long until an asset created at createdAt is
+// included: the remainder of the first integration interval, or a full interval once
+// that has passed (for example while the worker is still processing).
+func estimatedInclusionWait(createdAt, now time.Time) time.Duration {
+	interval := integrationInterval()
+	if createdAt.IsZero() {
+		return interval
+	}
+	if elapsed := now.Sub(createdAt); elapsed >= 0 && elapsed < interval {
+		return interval - elapsed
+	}
+	return interval
+}
+
+// isNotYetIntegrated reports whether a Trillian error means the leaf is queued but not
+// in the tree yet. NotFound is not one of them: the log has no such leaf or tree.
+func isNotYetIntegrated(err error) bool {
+	if errors.Is(err, errLeafNotIntegrated) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.OutOfRange, codes.FailedPrecondition:
+		return true
+	}
+	return false
+}
+
+// respondPendingInclusion sends a 202 for an asset that is not yet in the log, with a
+// Retry-After header and an estimated wait. queuedLeafIndex is included when known.
+func respondPendingInclusion(w http.ResponseWriter, asset *Asset, certStatus string, queuedLeafIndex int64) {
+	wait := estimatedInclusionWait(asset.CreatedAt, time.Now())
+	retryAfter := int(wait.Round(time.Second) / time.Second)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	data := map[string]interface{}{
+		"asset_id":                     asset.ID,
+		"status":                       "pending_inclusion",
+		"logged":                       false,
+		"certificate_status":           certStatus,
+		"confirmations":                0,
+		"retry_after_seconds":          retryAfter,
+		"estimated_wait":               fmt.Sprintf("%ds", retryAfter),
+		"integration_interval_seconds": int(integrationInterval() / time.Second),
+	}
+	if queuedLeafIndex > 0 {
+		data["queued_leaf_index"] = queuedLeafIndex
+	}
+	if asset.ModelVersion != "" {
+		data["model_version"] = asset.ModelVersion
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Message: "Asset found but not yet included in the log",
+		Data:    data,
+	})
+}
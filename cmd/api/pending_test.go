@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"proofpix/internal/models"
+)
+
+// pendingData is the subset of the pending response checked by these tests
+type pendingData struct {
+	Status            string `json:"status"`
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+	QueuedLeafIndex   int64  `json:"queued_leaf_index"`
+}
+
+func decodePending(t *testing.T, rec *httptest.ResponseRecorder) pendingData {
+	t.Helper()
+	var body struct {
+		Data pendingData `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return body.Data
+}
+
+func TestVerifyHandler_PendingRetryHint(t *testing.T) {
+	t.Setenv("TRILLIAN_INTEGRATION_INTERVAL", "30s")
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, CreatedAt: time.Now().Add(-10 * time.Second)},
+		},
+	})
+	useFakeCertificates(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/asset-1", nil)
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Retry-After"); got == "" {
+		t.Errorf("Expected a Retry-After header")
+	}
+
+	data := decodePending(t, rec)
+	if data.Status != "pending_inclusion" {
+		t.Errorf("Expected status pending_inclusion, but got %q", data.Status)
+	}
+	// 10s into a 30s interval leaves roughly 20s to wait
+	if data.RetryAfterSeconds < 19 || data.RetryAfterSeconds > 21 {
+		t.Errorf("Expected retry_after_seconds around 20, but got %d", data.RetryAfterSeconds)
+	}
+	if data.QueuedLeafIndex != 0 {
+		t.Errorf("Expected no queued leaf index, but got %d", data.QueuedLeafIndex)
+	}
+}
+
+func TestVerifyHandler_QueuedLeafNotIntegrated(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, TrillianLeafIndex: 17},
+		},
+	})
+	useFakeCertificates(t, nil)
+	useFakeLogRoot(t, 10)
+
+	origProof := fetchInclusionProof
+	t.Cleanup(func() { fetchInclusionProof = origProof })
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		return nil, status.Error(codes.OutOfRange, "leaf index 17 beyond tree size 10")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/asset-1", nil)
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a Retry-After header")
+	}
+	if data := decodePending(t, rec); data.QueuedLeafIndex != 17 {
+		t.Errorf("Expected queued_leaf_index 17, but got %d", data.QueuedLeafIndex)
+	}
+}
+
+func TestEstimatedInclusionWait(t *testing.T) {
+	t.Setenv("TRILLIAN_INTEGRATION_INTERVAL", "1m")
+	now := time.Now()
+
+	testCases := []struct {
+		name      string
+		createdAt time.Time
+		expected  time.Duration
+	}{
+		{name: "Unknown creation time", expected: time.Minute},
+		{name: "Within first interval", createdAt: now.Add(-15 * time.Second), expected: 45 * time.Second},
+		{name: "Past first interval", createdAt: now.Add(-5 * time.Minute), expected: time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := estimatedInclusionWait(tc.createdAt, now); got != tc.expected {
+				t.Errorf("Expected wait %s, but got %s", tc.expected, got)
+			}
+		})
+	}
+}
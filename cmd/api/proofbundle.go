@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/google/trillian/types"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+// proofBundleVersion identifies the layout of the proof bundle document
+const proofBundleVersion = 1
+
+// proofBundle is a self-contained document for verifying an asset's certificate
+// offline: rebuild the leaf from credential_bytes with leaf_format, hash it with
+// hash_algorithm, and fold in the inclusion proof hashes to reach the log root hash. Credential is the
+// same document for reading; the hash leaf format covers the exact stored bytes,
+// which only credential_bytes preserves.
+type proofBundle struct {
+	Version         int                  `json:"version"`
+	AssetID         string               `json:"asset_id"`
+	Credential      json.RawMessage      `json:"credential"`
+	CredentialBytes []byte               `json:"credential_bytes"`
+	LeafFormat      string               `json:"leaf_format"`
+	HashAlgorithm   string               `json:"hash_algorithm"`
+	LogID           int64                `json:"log_id"`
+	InclusionProof  bundleInclusionProof `json:"inclusion_proof"`
+	LogRoot         bundleLogRoot        `json:"log_root"`
+	IssuerPublicKey *bundleIssuerKey     `json:"issuer_public_key,omitempty"`
+}
+
+// bundleInclusionProof is the audit path from the credential's leaf to the log root.
+// Byte fields are base64 encoded.
+type bundleInclusionProof struct {
+	LeafIndex int64    `json:"leaf_index"`
+	TreeSize  uint64   `json:"tree_size"`
+	Hashes    [][]byte `json:"hashes"`
+}
+
+// bundleLogRoot is the log root the proof leads to, decoded and in its binary
+// encoding as served by the log
+type bundleLogRoot struct {
+	TreeSize       uint64 `json:"tree_size"`
+	RootHash       []byte `json:"root_hash"`
+	TimestampNanos uint64 `json:"timestamp_nanos"`
+	Revision       uint64 `json:"revision"`
+	Encoded        []byte `json:"encoded"`
+}
+
+// bundleIssuerKey is the public key that verifies the credential's signed proof.
+// It is omitted when credentials carry an unsigned digest proof.
+type bundleIssuerKey struct {
+	Algorithm          string `json:"algorithm"`
+	VerificationMethod string `json:"verification_method,omitempty"`
+	PublicKeyPEM       string `json:"public_key_pem"`
+}
+
+// issuerKey describes the configured signing key, or returns nil when signing is off
+func issuerKey(signer *certificate.SigningConfig) (*bundleIssuerKey, error) {
+	if signer == nil {
+		return nil, nil
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.PublicKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode issuer public key: %v", err)
+	}
+	return &bundleIssuerKey{
+		Algorithm:          signer.Algorithm,
+		VerificationMethod: signer.VerificationMethod,
+		PublicKeyPEM:       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	}, nil
+}
+
+// loggedProof is an asset's inclusion proof, checked against the log root it leads to
+type loggedProof struct {
+	certData []byte
+	hasher   leaf.Hasher
+	logID    int64
+	root     *types.LogRootV1
+	hashes   [][]byte
+}
+
+// leafFormat returns the format the asset's certificate was logged in
+func (p *loggedProof) leafFormat(asset *Asset) string {
+	if asset.TrillianLeafFormat == "" {
+		return leaf.FormatHash
+	}
+	return asset.TrillianLeafFormat
+}
+
+// fetchLoggedProof returns the inclusion proof of a completed asset's certificate
+// against the current log root, checked before it is handed out. It writes 202 while
+// the certificate is not yet in the log, 404 when the asset has no certificate, and
+// 409 when the certificate or proof does not match, and then returns false.
+func fetchLoggedProof(w http.ResponseWriter, ctx context.Context, asset *Asset) (*loggedProof, bool) {
+	assetID := asset.ID
+
+	switch {
+	case asset.IsQuotaExceeded():
+		respondError(w, http.StatusNotFound, "Asset was not analyzed, so it has no certificate")
+		return nil, false
+	case asset.Status != models.StatusCompleted:
+		respondJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Message: "Asset is still being processed",
+			Data:    map[string]interface{}{"asset_id": assetID, "status": asset.Status, "logged": false},
+		})
+		return nil, false
+	case asset.AnchoringSkipped() == models.AnchoringSkippedByChoice:
+		respondError(w, http.StatusNotFound, "Asset was not anchored in the log by the uploader's choice")
+		return nil, false
+	case asset.AnchoringSkipped() == models.AnchoringBelowMinScore:
+		respondError(w, http.StatusNotFound, "Asset was not anchored in the log: its originality score is below the minimum for anchoring")
+		return nil, false
+	}
+
+	certStatus, certDetail, certData := checkCertificate(ctx, asset)
+	switch certStatus {
+	case certificateMissing:
+		respondError(w, http.StatusNotFound, "Certificate not found")
+		return nil, false
+	case certificateUnavailable:
+		respondError(w, http.StatusInternalServerError, "Failed to fetch certificate")
+		return nil, false
+	case certificateInconsistent:
+		log.Printf("Refusing inclusion proof for asset %s: %s", assetID, certDetail)
+		respondError(w, http.StatusConflict, "Stored certificate is inconsistent with the asset")
+		return nil, false
+	}
+
+	if asset.TrillianLeafIndex == 0 {
+		respondPendingInclusion(w, asset, certStatus, 0)
+		return nil, false
+	}
+
+	logID, err := strconv.ParseInt(os.Getenv("TRILLIAN_LOG_ID"), 10, 64)
+	if err != nil {
+		log.Printf("Invalid TRILLIAN_LOG_ID: %v", err)
+		respondError(w, http.StatusInternalServerError, "Server configuration error")
+		return nil, false
+	}
+	hasher, err := leaf.HasherFromEnv()
+	if err != nil {
+		log.Printf("Failed to configure leaf hashing: %v", err)
+		respondError(w, http.StatusInternalServerError, "Server configuration error")
+		return nil, false
+	}
+
+	root, err := fetchLogRoot(ctx, logID)
+	if err != nil {
+		log.Printf("Failed to fetch log root for asset %s: %v", assetID, err)
+		respondTrillianError(w, err, "Failed to retrieve log root")
+		return nil, false
+	}
+	if uint64(asset.TrillianLeafIndex) >= root.TreeSize {
+		respondPendingInclusion(w, asset, certStatus, asset.TrillianLeafIndex)
+		return nil, false
+	}
+
+	// Prove inclusion against the root being handed out, not whatever the log has by now
+	proofResponse, err := fetchInclusionProof(ctx, logID, asset.TrillianLeafIndex, int64(root.TreeSize))
+	if isNotYetIntegrated(err) {
+		respondPendingInclusion(w, asset, certStatus, asset.TrillianLeafIndex)
+		return nil, false
+	}
+	if err == nil && proofResponse.Proof == nil {
+		err = fmt.Errorf("log returned no inclusion proof")
+	}
+	if err != nil {
+		log.Printf("Failed to get inclusion proof for asset %s: %v", assetID, err)
+		respondTrillianError(w, err, "Failed to retrieve inclusion proof")
+		return nil, false
+	}
+
+	// Check the proof before handing it out, so auditors never get one that fails
+	if err := checkBundleProof(hasher, asset, certData, root, proofResponse.Proof.Hashes); err != nil {
+		log.Printf("Inclusion proof for asset %s does not verify: %v", assetID, err)
+		respondError(w, http.StatusConflict, "Inclusion proof does not match the log root")
+		return nil, false
+	}
+
+	return &loggedProof{certData: certData, hasher: hasher, logID: logID, root: root, hashes: proofResponse.Proof.Hashes}, true
+}
+
+// logRoot returns the log root the proof leads to in its bundled form
+func (p *loggedProof) logRoot() (bundleLogRoot, error) {
+	encoded, err := p.root.MarshalBinary()
+	if err != nil {
+		return bundleLogRoot{}, err
+	}
+	return bundleLogRoot{
+		TreeSize:       p.root.TreeSize,
+		RootHash:       p.root.RootHash,
+		TimestampNanos: p.root.TimestampNanos,
+		Revision:       p.root.Revision,
+		Encoded:        encoded,
+	}, nil
+}
+
+// handleProofBundle handles GET /api/v1/assets/{id}/proof-bundle. It returns the
+// credential, its inclusion proof, the log root and the issuer key as one JSON file,
+// 202 while the certificate is not yet in the log, and 404 for unknown assets.
+func handleProofBundle(w http.ResponseWriter, r *http.Request) {
+	assetID := r.PathValue("id")
+	ctx := r.Context()
+
+	asset, err := repo.GetAsset(ctx, assetID)
+	if err != nil && !errors.Is(err, ErrAssetNotFound) {
+		log.Printf("Failed to fetch asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+		return
+	}
+	if err != nil || asset.IsDeleted() || !canVerifyAsset(r, asset) {
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+		return
+	}
+
+	proof, ok := fetchLoggedProof(w, ctx, asset)
+	if !ok {
+		return
+	}
+
+	key, err := issuerKey(credentialSigner)
+	if err != nil {
+		log.Printf("Failed to describe issuer key: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to encode issuer public key")
+		return
+	}
+	logRoot, err := proof.logRoot()
+	if err != nil {
+		log.Printf("Failed to encode log root: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to encode log root")
+		return
+	}
+
+	bundle := proofBundle{
+		Version:         proofBundleVersion,
+		AssetID:         assetID,
+		Credential:      proof.certData,
+		CredentialBytes: proof.certData,
+		LeafFormat:      proof.leafFormat(asset),
+		HashAlgorithm:   proof.hasher.Algorithm(),
+		LogID:           proof.logID,
+		InclusionProof: bundleInclusionProof{
+			LeafIndex: asset.TrillianLeafIndex,
+			TreeSize:  proof.root.TreeSize,
+			Hashes:    proof.hashes,
+		},
+		LogRoot:         logRoot,
+		IssuerPublicKey: key,
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="proofpix-%s-bundle.json"`, assetID))
+	respondJSON(w, http.StatusOK, bundle)
+}
+
+// checkBundleProof recomputes the log root from the stored credential and the proof hashes
+func checkBundleProof(hasher leaf.Hasher, asset *Asset, certData []byte, root *types.LogRootV1, hashes [][]byte) error {
+	leafValue, err := hasher.Value(asset.TrillianLeafFormat, certData)
+	if err != nil {
+		return err
+	}
+	computed, err := hasher.RootFromInclusionProof(asset.TrillianLeafIndex, int64(root.TreeSize), hasher.HashLeaf(leafValue), hashes)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(computed, root.RootHash) {
+		return leaf.ErrInclusionProofMismatch
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+func TestHandleProofBundle(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	createdAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	logged := &Asset{ID: "logged", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 9, TrillianLeafIndex: 3, TrillianLeafFormat: leaf.FormatHash}
+	queued := &Asset{ID: "queued", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 9, TrillianLeafIndex: 9, TrillianLeafFormat: leaf.FormatHash}
+	unlogged := &Asset{ID: "unlogged", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 9}
+	processing := &Asset{ID: "processing", UserID: "owner", Status: models.StatusPartial, CreatedAt: createdAt}
+	private := &Asset{ID: "private", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 9, Visibility: models.VisibilityPrivate}
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"logged": logged, "queued": queued, "unlogged": unlogged, "processing": processing, "private": private,
+	}})
+
+	certificates := map[string][]byte{}
+	for _, asset := range []*Asset{logged, queued, unlogged, private} {
+		credential, err := certificate.Generate(asset)
+		if err != nil {
+			t.Fatalf("Failed to generate certificate: %v", err)
+		}
+		certificates[asset.ID], _ = json.MarshalIndent(credential, "", "  ")
+	}
+	useFakeCertificates(t, certificates)
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	signer, err := certificate.NewSigningConfig(certificate.AlgorithmEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "did:web:proofpix.com#key-1")
+	if err != nil {
+		t.Fatalf("Failed to create signing config: %v", err)
+	}
+	origSigner := credentialSigner
+	credentialSigner = signer
+	t.Cleanup(func() { credentialSigner = origSigner })
+
+	// A four-leaf log with the logged certificate at index 3
+	storedLeaf, _ := leaf.Value(leaf.FormatHash, certificates["logged"])
+	h0, h1, h2, h3 := leaf.HashLeaf([]byte("a")), leaf.HashLeaf([]byte("b")), leaf.HashLeaf([]byte("c")), leaf.HashLeaf(storedLeaf)
+	left := leaf.HashChildren(h0, h1)
+	rootHash := leaf.HashChildren(left, leaf.HashChildren(h2, h3))
+
+	origProof, origRoot := fetchInclusionProof, fetchLogRoot
+	t.Cleanup(func() { fetchInclusionProof, fetchLogRoot = origProof, origRoot })
+	fetchLogRoot = func(ctx context.Context, logID int64) (*types.LogRootV1, error) {
+		return &types.LogRootV1{TreeSize: 4, RootHash: rootHash, TimestampNanos: 1700000000000000000, Revision: 12}, nil
+	}
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		if treeSize != 4 {
+			t.Errorf("Expected the proof to be requested at the bundled tree size 4, but got %d", treeSize)
+		}
+		return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: leafIndex, Hashes: [][]byte{h2, left}}}, nil
+	}
+
+	testCases := []struct {
+		name         string
+		assetID      string
+		userID       string
+		expectedCode int
+	}{
+		{name: "Logged asset", assetID: "logged", expectedCode: http.StatusOK},
+		{name: "Leaf not yet integrated", assetID: "queued", expectedCode: http.StatusAccepted},
+		{name: "Not yet queued", assetID: "unlogged", expectedCode: http.StatusAccepted},
+		{name: "Still processing", assetID: "processing", expectedCode: http.StatusAccepted},
+		{name: "Unknown asset", assetID: "missing", expectedCode: http.StatusNotFound},
+		{name: "Private asset with owner", assetID: "private", userID: "owner", expectedCode: http.StatusAccepted},
+		{name: "Private asset without auth", assetID: "private", expectedCode: http.StatusNotFound},
+		{name: "Private asset with another user", assetID: "private", userID: "someone-else", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/assets/"+tc.assetID+"/proof-bundle", nil)
+			if tc.userID != "" {
+				req = withUser(req, tc.userID)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var bundle proofBundle
+			if err := json.Unmarshal(rec.Body.Bytes(), &bundle); err != nil {
+				t.Fatalf("Failed to decode bundle: %v", err)
+			}
+
+			// Credential
+			var credential certificate.VerifiableCredential
+			if err := json.Unmarshal(bundle.Credential, &credential); err != nil || credential.CredentialSubject.ID == "" {
+				t.Errorf("Expected the credential in the bundle, but got %s (%v)", bundle.Credential, err)
+			}
+			if string(bundle.CredentialBytes) != string(certificates["logged"]) {
+				t.Errorf("Expected the exact stored credential bytes in the bundle")
+			}
+			// Inclusion proof
+			if bundle.InclusionProof.LeafIndex != 3 || bundle.InclusionProof.TreeSize != 4 || len(bundle.InclusionProof.Hashes) != 2 {
+				t.Errorf("Expected the inclusion proof for leaf 3 of 4, but got %+v", bundle.InclusionProof)
+			}
+			// Log root
+			var decoded types.LogRootV1
+			if err := decoded.UnmarshalBinary(bundle.LogRoot.Encoded); err != nil || string(decoded.RootHash) != string(rootHash) {
+				t.Errorf("Expected the encoded log root to decode to the root hash, but got %+v (%v)", decoded, err)
+			}
+			// Issuer public key
+			if bundle.IssuerPublicKey == nil {
+				t.Fatalf("Expected the issuer public key in the bundle")
+			}
+			block, _ := pem.Decode([]byte(bundle.IssuerPublicKey.PublicKeyPEM))
+			if block == nil {
+				t.Fatalf("Expected a PEM public key, but got %q", bundle.IssuerPublicKey.PublicKeyPEM)
+			}
+			publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil || !key.Public().(ed25519.PublicKey).Equal(publicKey) {
+				t.Errorf("Expected the signing key's public half, but got %v (%v)", publicKey, err)
+			}
+
+			// The bundle alone is enough to verify inclusion
+			hasher, err := leaf.NewHasher(bundle.HashAlgorithm)
+			if err != nil || bundle.HashAlgorithm != leaf.SHA256 {
+				t.Fatalf("Expected hash algorithm %s, but got %q (%v)", leaf.SHA256, bundle.HashAlgorithm, err)
+			}
+			leafValue, _ := hasher.Value(bundle.LeafFormat, bundle.CredentialBytes)
+			if err := hasher.VerifyInclusion(bundle.InclusionProof.LeafIndex, int64(bundle.InclusionProof.TreeSize), hasher.HashLeaf(leafValue), bundle.InclusionProof.Hashes, bundle.LogRoot.RootHash); err != nil {
+				t.Errorf("Expected the bundle to verify offline, but got %v", err)
+			}
+		})
+	}
+}
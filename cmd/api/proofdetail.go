@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/trillian/types"
+
+	"proofpix/internal/leaf"
+)
+
+// Steps of the inclusion proof check reported in verbose verification
+const (
+	proofStepCertificate = "certificate"
+	proofStepRootFetch   = "root_fetch"
+	proofStepLeafHash    = "leaf_hash"
+	proofStepProofFetch  = "proof_fetch"
+	proofStepRecompute   = "proof_recomputation"
+)
+
+// fetchLogRoot reads the log's latest signed root. Tests replace it with a fake.
+var fetchLogRoot = getLatestLogRoot
+
+// getLatestLogRoot fetches the latest signed root from the Trillian log server
+func getLatestLogRoot(ctx context.Context, logID int64) (*types.LogRootV1, error) {
+	client, closeLog, err := openInclusionLog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeLog()
+	return latestLogRoot(ctx, client, logID)
+}
+
+// proofVerification records how far the inclusion proof check got. Hashes are hex
+// encoded; expected values come from the stored certificate and the signed root,
+// the others from what the log returned.
+type proofVerification struct {
+	Verified         bool   `json:"verified"`
+	FailedStep       string `json:"failed_step,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+	LeafIndex        int64  `json:"leaf_index"`
+	TreeSize         uint64 `json:"tree_size,omitempty"`
+	ExpectedLeafHash string `json:"expected_leaf_hash,omitempty"`
+	LoggedLeafHash   string `json:"logged_leaf_hash,omitempty"`
+	ExpectedRootHash string `json:"expected_root_hash,omitempty"`
+	ComputedRootHash string `json:"computed_root_hash,omitempty"`
+}
+
+func (v *proofVerification) fail(step string, err error) {
+	v.FailedStep = step
+	v.Reason = err.Error()
+}
+
+// respondVerboseVerification checks a logged asset's inclusion proof step by step
+// and reports which step failed, with the expected and computed hashes. It exposes
+// log internals and is only reachable by admins.
+func respondVerboseVerification(w http.ResponseWriter, ctx context.Context, logID int64, hasher leaf.Hasher, asset *Asset, certStatus string, certData []byte) {
+	check := &proofVerification{LeafIndex: asset.TrillianLeafIndex}
+	data := map[string]interface{}{
+		"asset_id":           asset.ID,
+		"certificate_status": certStatus,
+		"logged":             true,
+		"proof_verification": check,
+	}
+	respondFailure := func(code int, message string) {
+		data["status"] = "proof_verification_failed"
+		respondJSON(w, code, Response{Success: false, Message: message, Data: data})
+	}
+	respondLogFailure := func(err error, message string) {
+		failure := trillianFailureFor(err, message)
+		respondFailure(failure.statusCode, failure.message)
+	}
+
+	if certData == nil {
+		check.fail(proofStepCertificate, fmt.Errorf("stored certificate is %s", certStatus))
+		respondFailure(http.StatusInternalServerError, "Stored certificate unavailable")
+		return
+	}
+	expectedLeaf, err := hasher.Value(asset.TrillianLeafFormat, certData)
+	if err != nil {
+		check.fail(proofStepCertificate, err)
+		respondFailure(http.StatusInternalServerError, "Failed to rebuild the log leaf")
+		return
+	}
+	expectedLeafHash := hasher.HashLeaf(expectedLeaf)
+	check.ExpectedLeafHash = hex.EncodeToString(expectedLeafHash)
+
+	root, err := fetchLogRoot(ctx, logID)
+	if err != nil {
+		log.Printf("Failed to fetch log root for asset %s: %v", asset.ID, err)
+		check.fail(proofStepRootFetch, err)
+		respondLogFailure(err, "Failed to retrieve log root")
+		return
+	}
+	check.TreeSize = root.TreeSize
+	check.ExpectedRootHash = hex.EncodeToString(root.RootHash)
+	if uint64(asset.TrillianLeafIndex) >= root.TreeSize {
+		respondPendingInclusion(w, asset, certStatus, asset.TrillianLeafIndex)
+		return
+	}
+
+	loggedLeaf, err := fetchLeafValue(ctx, logID, asset.TrillianLeafIndex)
+	if isNotYetIntegrated(err) {
+		respondPendingInclusion(w, asset, certStatus, asset.TrillianLeafIndex)
+		return
+	}
+	if err != nil {
+		check.fail(proofStepLeafHash, err)
+		respondLogFailure(err, "Failed to retrieve log leaf")
+		return
+	}
+	loggedLeafHash := hasher.HashLeaf(loggedLeaf)
+	check.LoggedLeafHash = hex.EncodeToString(loggedLeafHash)
+	if check.LoggedLeafHash != check.ExpectedLeafHash {
+		check.fail(proofStepLeafHash, fmt.Errorf("logged leaf hash does not match the stored certificate"))
+		respondFailure(http.StatusConflict, "Logged leaf does not match the stored certificate")
+		return
+	}
+
+	proofResponse, err := fetchInclusionProof(ctx, logID, asset.TrillianLeafIndex, int64(root.TreeSize))
+	if err == nil && proofResponse.Proof == nil {
+		err = fmt.Errorf("log returned no inclusion proof")
+	}
+	if err != nil {
+		check.fail(proofStepProofFetch, err)
+		respondLogFailure(err, "Failed to retrieve inclusion proof")
+		return
+	}
+
+	computed, err := leaf.RootFromInclusionProof(asset.TrillianLeafIndex, int64(root.TreeSize), expectedLeafHash, proofResponse.Proof.Hashes)
+	if err != nil {
+		check.fail(proofStepRecompute, err)
+		respondFailure(http.StatusConflict, "Inclusion proof is malformed")
+		return
+	}
+	check.ComputedRootHash = hex.EncodeToString(computed)
+	if check.ComputedRootHash != check.ExpectedRootHash {
+		check.fail(proofStepRecompute, leaf.ErrInclusionProofMismatch)
+		respondFailure(http.StatusConflict, "Inclusion proof does not match the log root")
+		return
+	}
+
+	check.Verified = true
+	data["status"] = "verified"
+	data["inclusion_proof"] = proofResponse
+	respondJSON(w, http.StatusOK, Response{Success: true, Message: "Inclusion proof verified", Data: data})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	firebaseauth "firebase.google.com/go/v4/auth"
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+// withAdmin returns a copy of the request authenticated as a user with the admin role
+func withAdmin(r *http.Request, userID string) *http.Request {
+	token := &firebaseauth.Token{UID: userID, Claims: map[string]interface{}{
+		"custom_claims": map[string]interface{}{"role": "admin"},
+	}}
+	ctx := context.WithValue(r.Context(), auth.UserIDKey, userID)
+	return r.WithContext(context.WithValue(ctx, auth.UserKey, token))
+}
+
+func TestVerifyHandler_VerboseProofDetails(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	asset := &Asset{
+		ID:                 "asset-1",
+		UserID:             "owner",
+		Status:             models.StatusCompleted,
+		CreatedAt:          time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore:   9,
+		TrillianLeafIndex:  3,
+		TrillianLeafFormat: leaf.FormatHash,
+	}
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{"asset-1": asset}})
+	credential, err := certificate.Generate(asset)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	stored, _ := json.MarshalIndent(credential, "", "  ")
+	useFakeCertificates(t, map[string][]byte{"asset-1": stored})
+
+	// A four-leaf log with the certificate's leaf at index 3
+	storedLeaf, _ := leaf.Value(leaf.FormatHash, stored)
+	h0, h1, h2, h3 := leaf.HashLeaf([]byte("a")), leaf.HashLeaf([]byte("b")), leaf.HashLeaf([]byte("c")), leaf.HashLeaf(storedLeaf)
+	left := leaf.HashChildren(h0, h1)
+	rootHash := leaf.HashChildren(left, leaf.HashChildren(h2, h3))
+	validProof := [][]byte{h2, left}
+
+	origProof, origLeaf, origRoot := fetchInclusionProof, fetchLeafValue, fetchLogRoot
+	t.Cleanup(func() { fetchInclusionProof, fetchLeafValue, fetchLogRoot = origProof, origLeaf, origRoot })
+
+	testCases := []struct {
+		name               string
+		admin              bool
+		loggedLeaf         []byte
+		proof              [][]byte
+		rootErr            error
+		expectedCode       int
+		expectedFailedStep string
+		expectVerbose      bool
+	}{
+		{name: "Verified", admin: true, loggedLeaf: storedLeaf, proof: validProof, expectedCode: http.StatusOK, expectVerbose: true},
+		{name: "Leaf hash mismatch", admin: true, loggedLeaf: []byte("tampered"), proof: validProof, expectedCode: http.StatusConflict, expectedFailedStep: proofStepLeafHash, expectVerbose: true},
+		{name: "Root mismatch", admin: true, loggedLeaf: storedLeaf, proof: [][]byte{h1, left}, expectedCode: http.StatusConflict, expectedFailedStep: proofStepRecompute, expectVerbose: true},
+		{name: "Root fetch fails", admin: true, rootErr: fmt.Errorf("log unavailable"), expectedCode: http.StatusInternalServerError, expectedFailedStep: proofStepRootFetch, expectVerbose: true},
+		{name: "Not an admin", loggedLeaf: storedLeaf, proof: validProof, expectedCode: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fetchLogRoot = func(ctx context.Context, logID int64) (*types.LogRootV1, error) {
+				if tc.rootErr != nil {
+					return nil, tc.rootErr
+				}
+				return &types.LogRootV1{TreeSize: 4, RootHash: rootHash}, nil
+			}
+			fetchLeafValue = func(ctx context.Context, logID int64, leafIndex int64) ([]byte, error) {
+				return tc.loggedLeaf, nil
+			}
+			fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+				return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: leafIndex, Hashes: tc.proof}}, nil
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/asset-1?verbose=true", nil)
+			if tc.admin {
+				req = withAdmin(req, "admin-1")
+			} else {
+				req = withUser(req, "someone")
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+
+			var body struct {
+				Data struct {
+					Check *proofVerification `json:"proof_verification"`
+				} `json:"data"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			check := body.Data.Check
+			if !tc.expectVerbose {
+				if check != nil {
+					t.Errorf("Expected no proof details for a non-admin, but got %+v", check)
+				}
+				return
+			}
+			if check == nil {
+				t.Fatalf("Expected proof details, but got %s", rec.Body.String())
+			}
+			if check.FailedStep != tc.expectedFailedStep {
+				t.Errorf("Expected failed step %q, but got %q (%s)", tc.expectedFailedStep, check.FailedStep, check.Reason)
+			}
+			if check.Verified != (tc.expectedFailedStep == "") {
+				t.Errorf("Expected verified=%t, but got %t", tc.expectedFailedStep == "", check.Verified)
+			}
+
+			switch tc.expectedFailedStep {
+			case proofStepLeafHash:
+				if check.ExpectedLeafHash != hex.EncodeToString(h3) || check.LoggedLeafHash != hex.EncodeToString(leaf.HashLeaf([]byte("tampered"))) {
+					t.Errorf("Expected the stored and logged leaf hashes, but got %+v", check)
+				}
+			case proofStepRecompute:
+				if check.ExpectedRootHash != hex.EncodeToString(rootHash) || check.ComputedRootHash == "" || check.ComputedRootHash == check.ExpectedRootHash {
+					t.Errorf("Expected differing expected and computed roots, but got %+v", check)
+				}
+			case "":
+				if check.ComputedRootHash != hex.EncodeToString(rootHash) {
+					t.Errorf("Expected computed root %x, but got %s", rootHash, check.ComputedRootHash)
+				}
+			}
+		})
+	}
+}
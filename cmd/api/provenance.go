@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// Provenance of an asset among its near duplicates
+const (
+	provenanceOriginal   = "original"
+	provenanceDerivative = "derivative"
+)
+
+// nearMatches returns the near duplicates of an asset known from duplicate
+// detection: the match the worker recorded when it processed the asset, and the
+// assets that recorded this one as theirs. Deleted assets are left out.
+func nearMatches(ctx context.Context, asset *Asset) ([]*Asset, error) {
+	related, err := repo.FindRelatedAssets(ctx, asset.ID)
+	if err != nil {
+		return nil, err
+	}
+	if asset.RelatedAsset != nil {
+		recorded, err := repo.GetAsset(ctx, asset.RelatedAsset.AssetID)
+		switch {
+		case err == nil:
+			if recorded.ID == "" {
+				recorded.ID = asset.RelatedAsset.AssetID
+			}
+			related = append(related, recorded)
+		case !errors.Is(err, ErrAssetNotFound):
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{asset.ID: true}
+	matches := []*Asset{}
+	for _, match := range related {
+		if seen[match.ID] || match.IsDeleted() {
+			continue
+		}
+		seen[match.ID] = true
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// earliestMatch returns the near match created before the asset and before every
+// other match, or nil when the asset is the earliest. Matches without a creation
+// time cannot be ordered and are ignored.
+func earliestMatch(asset *Asset, matches []*Asset) *Asset {
+	var earliest *Asset
+	for _, match := range matches {
+		if match.CreatedAt.IsZero() || !match.CreatedAt.Before(asset.CreatedAt) {
+			continue
+		}
+		if earliest == nil || match.CreatedAt.Before(earliest.CreatedAt) {
+			earliest = match
+		}
+	}
+	return earliest
+}
+
+// handleAssetProvenance reports whether an asset is the original among its near
+// duplicates, the earliest created, or a derivative of an earlier one:
+// GET /api/v1/assets/{id}/provenance. Private assets are visible to their owner only,
+// and the original's ID is only disclosed to callers who may see that asset.
+func handleAssetProvenance(w http.ResponseWriter, r *http.Request) {
+	assetID := r.PathValue("id")
+	ctx := r.Context()
+
+	asset, err := repo.GetAsset(ctx, assetID)
+	if err != nil && !errors.Is(err, ErrAssetNotFound) {
+		log.Printf("Failed to fetch asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+		return
+	}
+	if err != nil || asset.IsDeleted() || !canVerifyAsset(r, asset) {
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+		return
+	}
+	if asset.ID == "" {
+		asset.ID = assetID
+	}
+
+	// Duplicate detection runs when the worker indexes the asset
+	if asset.IsPending() {
+		respondJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Message: "Asset is still being processed",
+			Data:    map[string]interface{}{"asset_id": assetID, "status": asset.Status},
+		})
+		return
+	}
+
+	matches, err := nearMatches(ctx, asset)
+	if err != nil {
+		log.Printf("Failed to fetch near matches of asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch near matches")
+		return
+	}
+
+	data := map[string]interface{}{
+		"asset_id":     assetID,
+		"provenance":   provenanceOriginal,
+		"near_matches": len(matches),
+	}
+	// What is disclosed depends on who asks when either asset is private
+	cacheControl := verifyCacheControl(asset)
+	if original := earliestMatch(asset, matches); original != nil {
+		data["provenance"] = provenanceDerivative
+		if canVerifyAsset(r, original) {
+			data["original_asset_id"] = original.ID
+		}
+		if original.IsPrivate() {
+			cacheControl = verifyCacheControl(original)
+		}
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	respondJSON(w, http.StatusOK, Response{Success: true, Message: "Asset provenance", Data: data})
+}
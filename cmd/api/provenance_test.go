@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestHandleAssetProvenance(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"original": {ID: "original", UserID: "alice", Status: models.StatusCompleted, CreatedAt: created},
+			"copy": {ID: "copy", UserID: "bob", Status: models.StatusCompleted, CreatedAt: created.Add(time.Hour),
+				RelatedAsset: &models.RelatedAsset{AssetID: "original", Distance: 0.01}},
+			"unrelated": {ID: "unrelated", UserID: "alice", Status: models.StatusCompleted, CreatedAt: created},
+			"secret": {ID: "secret", UserID: "carol", Status: models.StatusCompleted, CreatedAt: created,
+				Visibility: models.VisibilityPrivate},
+			"copy-of-secret": {ID: "copy-of-secret", UserID: "bob", Status: models.StatusCompleted, CreatedAt: created.Add(time.Hour),
+				RelatedAsset: &models.RelatedAsset{AssetID: "secret", Distance: 0.02}},
+			"processing": {ID: "processing", UserID: "alice", Status: models.StatusProcessing, CreatedAt: created},
+		},
+	})
+
+	testCases := []struct {
+		name               string
+		assetID            string
+		userID             string
+		expectedCode       int
+		expectedProvenance string
+		expectedOriginal   string
+	}{
+		{name: "Earlier asset is the original", assetID: "original", expectedCode: http.StatusOK, expectedProvenance: provenanceOriginal},
+		{name: "Later near duplicate is a derivative", assetID: "copy", expectedCode: http.StatusOK, expectedProvenance: provenanceDerivative, expectedOriginal: "original"},
+		{name: "Asset without near matches is an original", assetID: "unrelated", expectedCode: http.StatusOK, expectedProvenance: provenanceOriginal},
+		{name: "Private original is not named to others", assetID: "copy-of-secret", expectedCode: http.StatusOK, expectedProvenance: provenanceDerivative},
+		{name: "Private original is named to its owner", assetID: "copy-of-secret", userID: "carol", expectedCode: http.StatusOK, expectedProvenance: provenanceDerivative, expectedOriginal: "secret"},
+		{name: "Private asset requires its owner", assetID: "secret", userID: "bob", expectedCode: http.StatusNotFound},
+		{name: "Private asset with owner", assetID: "secret", userID: "carol", expectedCode: http.StatusOK, expectedProvenance: provenanceOriginal},
+		{name: "Pending asset", assetID: "processing", expectedCode: http.StatusAccepted},
+		{name: "Missing asset", assetID: "missing", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/assets/"+tc.assetID+"/provenance", nil)
+			if tc.userID != "" {
+				req = withUser(req, tc.userID)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			var response struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got := response.Data["provenance"]; got != tc.expectedProvenance {
+				t.Errorf("Expected provenance %q, but got %v", tc.expectedProvenance, got)
+			}
+			if got, _ := response.Data["original_asset_id"].(string); got != tc.expectedOriginal {
+				t.Errorf("Expected original asset %q, but got %q", tc.expectedOriginal, got)
+			}
+		})
+	}
+}
+
+func TestHandleAssetProvenance_PrivateOriginalIsNotShared(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"secret": {ID: "secret", UserID: "carol", Status: models.StatusCompleted, CreatedAt: created,
+				Visibility: models.VisibilityPrivate},
+			"copy": {ID: "copy", UserID: "bob", Status: models.StatusCompleted, CreatedAt: created.Add(time.Hour),
+				RelatedAsset: &models.RelatedAsset{AssetID: "secret", Distance: 0.02}},
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	serve(t, rec, httptest.NewRequest(http.MethodGet, "/api/v1/assets/copy/provenance", nil))
+	if got := rec.Header().Get("Cache-Control"); got != "private, no-cache" {
+		t.Errorf("Expected Cache-Control private, no-cache, but got %q", got)
+	}
+}
+
+func TestEarliestMatch(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	asset := &Asset{ID: "asset", CreatedAt: created}
+
+	testCases := []struct {
+		name     string
+		matches  []*Asset
+		expected string
+	}{
+		{name: "No matches", expected: ""},
+		{name: "Only later matches", matches: []*Asset{{ID: "later", CreatedAt: created.Add(time.Minute)}}, expected: ""},
+		{name: "Same time is not earlier", matches: []*Asset{{ID: "tie", CreatedAt: created}}, expected: ""},
+		{name: "Unknown creation time is ignored", matches: []*Asset{{ID: "unknown"}}, expected: ""},
+		{name: "Earliest of several", matches: []*Asset{
+			{ID: "earlier", CreatedAt: created.Add(-time.Hour)},
+			{ID: "earliest", CreatedAt: created.Add(-2 * time.Hour)},
+			{ID: "later", CreatedAt: created.Add(time.Hour)},
+		}, expected: "earliest"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ""
+			if match := earliestMatch(asset, tc.matches); match != nil {
+				got = match.ID
+			}
+			if got != tc.expected {
+				t.Errorf("Expected earliest match %q, but got %q", tc.expected, got)
+			}
+		})
+	}
+}
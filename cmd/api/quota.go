@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"proofpix/internal/auth"
+)
+
+// defaultAssetQuota is the number of live assets a user may hold when no override applies
+const defaultAssetQuota = 1000
+
+// assetQuotaClaim is the custom Firebase claim that overrides a user's asset quota
+const assetQuotaClaim = "asset_quota"
+
+// defaultQuota returns the per-user asset quota from ASSET_QUOTA_PER_USER.
+// A value of 0 disables quota enforcement.
+func defaultQuota() int {
+	quota := defaultAssetQuota
+	if value := os.Getenv("ASSET_QUOTA_PER_USER"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			quota = parsed
+		} else {
+			log.Printf("Invalid ASSET_QUOTA_PER_USER %q, using default of %d assets", value, defaultAssetQuota)
+		}
+	}
+	return quota
+}
+
+// userQuota resolves the quota for the caller. The asset_quota token claim takes
+// precedence, then a Firestore user_quotas override, then the configured default.
+func userQuota(ctx context.Context, r *http.Request, userID string) (int, error) {
+	if token, ok := auth.GetUser(r); ok && token != nil {
+		if value, ok := token.Claims[assetQuotaClaim].(float64); ok && value >= 0 {
+			return int(value), nil
+		}
+	}
+
+	quota, found, err := repo.GetUserQuota(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		return quota, nil
+	}
+	return defaultQuota(), nil
+}
+
+// checkAssetQuota reports whether the user may create another asset. A quota of 0 means unlimited.
+func checkAssetQuota(ctx context.Context, r *http.Request, userID string) (allowed bool, count, quota int, err error) {
+	quota, err = userQuota(ctx, r, userID)
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("failed to resolve quota: %v", err)
+	}
+	if quota == 0 {
+		return true, 0, 0, nil
+	}
+
+	count, err = repo.CountUserAssets(ctx, userID)
+	if err != nil {
+		return false, 0, quota, fmt.Errorf("failed to count assets: %v", err)
+	}
+	return count < quota, count, quota, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fbauth "firebase.google.com/go/v4/auth"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/models"
+)
+
+// assetsFor returns n live assets owned by the user
+func assetsFor(userID string, n int) map[string]*Asset {
+	assets := make(map[string]*Asset)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("%s-asset-%d", userID, i)
+		assets[id] = &Asset{ID: id, UserID: userID, Status: models.StatusCompleted}
+	}
+	return assets
+}
+
+func TestCheckAssetQuota(t *testing.T) {
+	t.Setenv("ASSET_QUOTA_PER_USER", "3")
+
+	testCases := []struct {
+		name     string
+		existing int
+		status   string
+		override map[string]int
+		claim    interface{}
+		expected bool
+	}{
+		{name: "Below quota", existing: 2, expected: true},
+		{name: "At quota", existing: 3, expected: false},
+		{name: "Over quota", existing: 4, expected: false},
+		{name: "Deleted assets do not count", existing: 3, status: models.StatusDeleted, expected: true},
+		{name: "Assets awaiting upload do not count", existing: 3, status: models.StatusAwaitingUpload, expected: true},
+		{name: "Assets being processed count", existing: 3, status: models.StatusProcessing, expected: false},
+		{name: "Firestore override raises quota", existing: 3, override: map[string]int{"user-1": 5}, expected: true},
+		{name: "Claim override takes precedence", existing: 3, override: map[string]int{"user-1": 1}, claim: float64(10), expected: true},
+		{name: "Zero quota is unlimited", existing: 50, override: map[string]int{"user-1": 0}, expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeRepository{assets: assetsFor("user-1", tc.existing), quotas: tc.override}
+			if tc.status != "" {
+				for _, asset := range fake.assets {
+					asset.Status = tc.status
+					break
+				}
+			}
+			useFakeRepository(t, fake)
+
+			req := withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets", nil), "user-1")
+			if tc.claim != nil {
+				token := &fbauth.Token{UID: "user-1", Claims: map[string]interface{}{assetQuotaClaim: tc.claim}}
+				req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, token))
+			}
+
+			allowed, _, _, err := checkAssetQuota(req.Context(), req, "user-1")
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if allowed != tc.expected {
+				t.Errorf("Expected allowed to be %t, but got %t", tc.expected, allowed)
+			}
+		})
+	}
+}
+
+func TestHandleAssets_QuotaExceeded(t *testing.T) {
+	t.Setenv("ASSET_QUOTA_PER_USER", "2")
+	useFakeRepository(t, &fakeRepository{assets: assetsFor("user-1", 2)})
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets", nil), "user-1")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, but got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+}
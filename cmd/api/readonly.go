@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// defaultReadOnlyMessage is returned for blocked writes when READ_ONLY_MESSAGE is unset
+const defaultReadOnlyMessage = "ProofPix is in read-only maintenance mode; uploads and changes are temporarily disabled"
+
+// readOnly reports whether READ_ONLY is set, disabling uploads, deletes and restores
+func readOnly() bool {
+	value := os.Getenv("READ_ONLY")
+	if value == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid READ_ONLY %q, using default of false", value)
+		return false
+	}
+	return enabled
+}
+
+// readOnlyMessage returns the maintenance message from READ_ONLY_MESSAGE, or the default
+func readOnlyMessage() string {
+	if message := os.Getenv("READ_ONLY_MESSAGE"); message != "" {
+		return message
+	}
+	return defaultReadOnlyMessage
+}
+
+// blockInReadOnly wraps a mutating route so it returns 503 while the API is read-only.
+// Read routes such as verify are not wrapped and keep working during maintenance.
+func blockInReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if readOnly() {
+			log.Printf("Rejecting %s %s: API is in read-only mode", r.Method, r.URL.Path)
+			respondErrorCode(w, http.StatusServiceUnavailable, ErrCodeReadOnly, readOnlyMessage())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	t.Setenv("READ_ONLY", "true")
+	t.Setenv("READ_ONLY_MESSAGE", "Down for maintenance until 14:00 UTC")
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "user-1", Status: models.StatusCompleted},
+		},
+	})
+	useFakeCertificates(t, nil)
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{name: "Upload blocked", method: http.MethodPost, path: "/api/v1/assets", expectedCode: http.StatusServiceUnavailable},
+		{name: "Delete blocked", method: http.MethodDelete, path: "/api/v1/assets/asset-1", expectedCode: http.StatusServiceUnavailable},
+		{name: "Restore blocked", method: http.MethodPost, path: "/api/v1/assets/asset-1/restore", expectedCode: http.StatusServiceUnavailable},
+		{name: "Verify allowed", method: http.MethodGet, path: "/api/v1/verify/asset-1", expectedCode: http.StatusAccepted},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(tc.method, tc.path, nil), "user-1")
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusServiceUnavailable {
+				return
+			}
+			var body Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Message != "Down for maintenance until 14:00 UTC" {
+				t.Errorf("Expected the configured maintenance message, but got %q", body.Message)
+			}
+		})
+	}
+}
+
+func TestReadOnlyMode_Disabled(t *testing.T) {
+	t.Setenv("READ_ONLY", "")
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"asset-1": {ID: "asset-1", UserID: "user-1", Status: models.StatusCompleted},
+		},
+	})
+
+	req := withUser(httptest.NewRequest(http.MethodDelete, "/api/v1/assets/asset-1", nil), "user-1")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code == http.StatusServiceUnavailable {
+		t.Errorf("Expected delete to be allowed outside read-only mode, but got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/trillian"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/bodylimit"
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+// maxRegenerateRequestBytes bounds the optional body of a regenerate request
+const maxRegenerateRequestBytes = 1 << 10
+
+// queueCertificateLeaf queues a leaf value in the Trillian log and returns its leaf
+// index. Tests replace it with a mock log.
+var queueCertificateLeaf = queueLeaf
+
+// regenerateRequest is the optional body of a regenerate request
+type regenerateRequest struct {
+	// Reanchor queues the new credential in the transparency log
+	Reanchor bool `json:"reanchor"`
+}
+
+// handleRegenerateCredential rebuilds and re-signs an asset's credential from its
+// current data, for example after its metadata was corrected. The replaced
+// credential is kept under the asset's certificate history and recorded on the
+// asset. With "reanchor" the new credential is also queued in Trillian; otherwise
+// the old leaf index is dropped, since it no longer matches the stored credential.
+// Route: POST /api/v1/assets/{id}/credential/regenerate
+func handleRegenerateCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "User ID not found in context")
+		return
+	}
+
+	var req regenerateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRegenerateRequestBytes)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		if bodylimit.IsTooLarge(err) {
+			respondError(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+			return
+		}
+		respondValidationError(w, "Invalid JSON request body")
+		return
+	}
+
+	assetID := r.PathValue("id")
+
+	// Owners regenerate their own credentials; admins may regenerate any
+	var asset *Asset
+	if isAdminRequest(r) {
+		var err error
+		if asset, err = repo.GetAsset(r.Context(), assetID); err != nil {
+			if errors.Is(err, ErrAssetNotFound) {
+				respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+				return
+			}
+			log.Printf("Failed to fetch asset %s: %v", assetID, err)
+			respondError(w, http.StatusInternalServerError, "Failed to fetch asset")
+			return
+		}
+	} else if asset, ok = getOwnedAsset(w, r, assetID, userID); !ok {
+		return
+	}
+
+	if asset.IsDeleted() {
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Asset not found")
+		return
+	}
+	if asset.Status != models.StatusCompleted {
+		respondError(w, http.StatusConflict, "Only completed assets have a credential to regenerate")
+		return
+	}
+	if req.Reanchor && asset.SkipAnchoring {
+		respondValidationError(w, "Asset was certified without anchoring",
+			FieldError{Field: "reanchor", Message: "asset opted out of the transparency log"})
+		return
+	}
+	if req.Reanchor && asset.BelowAnchorMinScore {
+		respondValidationError(w, "Asset was certified without anchoring",
+			FieldError{Field: "reanchor", Message: "asset scored below the minimum for the transparency log"})
+		return
+	}
+
+	ctx := r.Context()
+	previous, err := fetchCertificate(ctx, asset)
+	if err != nil && !errors.Is(err, ErrCertificateNotFound) {
+		log.Printf("Failed to fetch certificate for asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to fetch current credential")
+		return
+	}
+
+	certificateJSON, err := buildCredential(asset)
+	if err != nil {
+		log.Printf("Failed to regenerate certificate for asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to generate credential")
+		return
+	}
+
+	// Keep the replaced credential before overwriting it
+	var previousPath string
+	if previous != nil {
+		now := time.Now().UTC()
+		previousPath = certificate.HistoryObjectPath(asset, now)
+		if err := storeCertificate(ctx, previousPath, previous); err != nil {
+			log.Printf("Failed to archive certificate for asset %s: %v", assetID, err)
+			respondError(w, http.StatusInternalServerError, "Failed to archive current credential")
+			return
+		}
+		asset.CredentialHistory = append(asset.CredentialHistory, models.CredentialRevision{
+			ObjectPath:         previousPath,
+			ReplacedAt:         now,
+			TrillianLeafIndex:  asset.TrillianLeafIndex,
+			TrillianLeafFormat: asset.TrillianLeafFormat,
+		})
+	}
+
+	objectPath := certificate.ObjectPath(asset)
+	if err := storeCertificate(ctx, objectPath, certificateJSON); err != nil {
+		log.Printf("Failed to upload regenerated certificate for asset %s: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to store credential")
+		return
+	}
+
+	// The old leaf anchors the archived credential, not the new one. Without a
+	// re-anchor the new credential is deliberately left out of the log, which verify
+	// reports as not anchored rather than waiting for an inclusion that never comes.
+	asset.TrillianLeafIndex, asset.TrillianLeafFormat = 0, ""
+	asset.SkipAnchoring = !req.Reanchor
+	var anchorErr error
+	if req.Reanchor {
+		anchorErr = anchorCredential(ctx, asset, certificateJSON)
+	}
+
+	// Record the new credential and its history even if anchoring failed. The stored
+	// certificate has changed either way, so a cached verification is stale.
+	err = repo.SaveAsset(ctx, asset)
+	verifyCache.invalidate(assetID)
+	if err != nil {
+		log.Printf("Failed to save asset %s after regenerating its certificate: %v", assetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to save asset")
+		return
+	}
+	if anchorErr != nil {
+		log.Printf("Failed to re-anchor certificate for asset %s: %v", assetID, anchorErr)
+		respondError(w, http.StatusBadGateway, "Credential regenerated but could not be anchored")
+		return
+	}
+
+	log.Printf("Certificate for asset %s regenerated by user %s", assetID, userID)
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Credential regenerated successfully",
+		Data: map[string]interface{}{
+			"asset_id":             assetID,
+			"certificate_path":     objectPath,
+			"previous_certificate": previousPath,
+			"reanchored":           req.Reanchor,
+			"trillian_leaf_index":  asset.TrillianLeafIndex,
+		},
+	})
+}
+
+// buildCredential generates the asset's credential, signs it when signing is
+// configured, and encodes it the way the worker stores credentials
+func buildCredential(asset *Asset) ([]byte, error) {
+	credential, err := certificate.Generate(asset)
+	if err != nil {
+		return nil, err
+	}
+	if credentialSigner != nil {
+		if err := credentialSigner.Sign(credential); err != nil {
+			return nil, fmt.Errorf("failed to sign certificate: %v", err)
+		}
+	}
+	return json.MarshalIndent(credential, "", "  ")
+}
+
+// anchorCredential queues the credential in the log configured in TRILLIAN_LOG_ID,
+// encoded per TRILLIAN_LEAF_FORMAT, and records the new leaf on the asset
+func anchorCredential(ctx context.Context, asset *Asset, certificateJSON []byte) error {
+	logID, err := strconv.ParseInt(os.Getenv("TRILLIAN_LOG_ID"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid TRILLIAN_LOG_ID: %v", err)
+	}
+	format, err := leaf.FormatFromEnv()
+	if err != nil {
+		return err
+	}
+	hasher, err := leaf.HasherFromEnv()
+	if err != nil {
+		return err
+	}
+	value, err := hasher.Value(format, certificateJSON)
+	if err != nil {
+		return err
+	}
+	leafIndex, err := queueCertificateLeaf(ctx, logID, value)
+	if err != nil {
+		return err
+	}
+	asset.TrillianLeafIndex, asset.TrillianLeafFormat = leafIndex, format
+	return nil
+}
+
+// queueLeaf submits a leaf value to the Trillian log and returns its leaf index
+func queueLeaf(ctx context.Context, logID int64, leafValue []byte) (int64, error) {
+	conn, err := dialLogServer(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer closeLogServer(conn)
+
+	client := trillian.NewTrillianLogClient(conn)
+	response, err := client.QueueLeaf(ctx, &trillian.QueueLeafRequest{
+		LogId: logID,
+		Leaf:  &trillian.LogLeaf{LeafValue: leafValue},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue leaf in Trillian log %d: %v", logID, err)
+	}
+	if response.GetQueuedLeaf().GetLeaf() == nil {
+		return 0, fmt.Errorf("QueueLeaf response does not contain a queued leaf")
+	}
+	if code := response.QueuedLeaf.GetStatus().GetCode(); code != 0 {
+		return 0, fmt.Errorf("Trillian QueueLeaf failed with status code %d: %s", code, response.QueuedLeaf.Status.Message)
+	}
+	return response.QueuedLeaf.Leaf.LeafIndex, nil
+}
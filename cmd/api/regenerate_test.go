@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+// useFakeCertificateStore records uploaded certificates by object name for the duration of the test
+func useFakeCertificateStore(t *testing.T) map[string][]byte {
+	t.Helper()
+	uploaded := map[string][]byte{}
+	orig := storeCertificate
+	storeCertificate = func(ctx context.Context, objectName string, data []byte) error {
+		uploaded[objectName] = data
+		return nil
+	}
+	t.Cleanup(func() { storeCertificate = orig })
+	return uploaded
+}
+
+func TestRegenerateCredential_ReflectsUpdatedMetadata(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LEAF_FORMAT", leaf.FormatCertificate)
+
+	asset := &Asset{
+		ID:                 "asset-1",
+		UserID:             "owner",
+		Status:             models.StatusCompleted,
+		CreatedAt:          time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore:   4,
+		Narrative:          "Original narrative",
+		TrillianLeafIndex:  3,
+		TrillianLeafFormat: leaf.FormatHash,
+	}
+	original, _ := certificate.Generate(asset)
+	originalJSON, _ := json.MarshalIndent(original, "", "  ")
+
+	// The asset's metadata is corrected after the credential was issued
+	updated := *asset
+	updated.OriginalityScore = 8
+	updated.Narrative = "Corrected narrative"
+	fake := &fakeRepository{assets: map[string]*Asset{"asset-1": &updated}}
+	useFakeRepository(t, fake)
+	useFakeCertificates(t, map[string][]byte{"asset-1": originalJSON})
+	uploaded := useFakeCertificateStore(t)
+
+	var queued []byte
+	origQueue := queueCertificateLeaf
+	queueCertificateLeaf = func(ctx context.Context, logID int64, leafValue []byte) (int64, error) {
+		queued = leafValue
+		return 9, nil
+	}
+	t.Cleanup(func() { queueCertificateLeaf = origQueue })
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets/asset-1/credential/regenerate", strings.NewReader(`{"reanchor": true}`)), "owner")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	stored := fake.assets["asset-1"]
+	regenerated, ok := uploaded[certificate.ObjectPath(stored)]
+	if !ok {
+		t.Fatalf("Expected the credential to be uploaded to %s, but got uploads %v", certificate.ObjectPath(stored), objectNames(uploaded))
+	}
+	var credential certificate.VerifiableCredential
+	if err := json.Unmarshal(regenerated, &credential); err != nil {
+		t.Fatalf("Failed to decode regenerated credential: %v", err)
+	}
+	if credential.CredentialSubject.AuthenticityNarrative != "Corrected narrative" {
+		t.Errorf("Expected the corrected narrative, but got %q", credential.CredentialSubject.AuthenticityNarrative)
+	}
+	if credential.CredentialSubject.AuthenticityRating.RatingValue != 8 {
+		t.Errorf("Expected rating 8, but got %d", credential.CredentialSubject.AuthenticityRating.RatingValue)
+	}
+	if err := certificate.VerifyJSON(regenerated, stored); err != nil {
+		t.Errorf("Expected the regenerated credential to verify against the asset, but got %v", err)
+	}
+
+	// The replaced credential is archived and recorded with its old leaf
+	if len(stored.CredentialHistory) != 1 {
+		t.Fatalf("Expected one history entry, but got %+v", stored.CredentialHistory)
+	}
+	revision := stored.CredentialHistory[0]
+	if !bytes.Equal(uploaded[revision.ObjectPath], originalJSON) {
+		t.Errorf("Expected the original credential archived at %s, but got uploads %v", revision.ObjectPath, objectNames(uploaded))
+	}
+	if revision.TrillianLeafIndex != 3 || revision.TrillianLeafFormat != leaf.FormatHash {
+		t.Errorf("Expected the history to keep leaf 3 (%s), but got %d (%s)", leaf.FormatHash, revision.TrillianLeafIndex, revision.TrillianLeafFormat)
+	}
+
+	// The new credential is anchored in the configured leaf format
+	if matched, _ := leaf.Matches(leaf.FormatCertificate, regenerated, queued); !matched {
+		t.Errorf("Expected the queued leaf to encode the regenerated credential")
+	}
+	if stored.TrillianLeafIndex != 9 || stored.TrillianLeafFormat != leaf.FormatCertificate {
+		t.Errorf("Expected leaf 9 (%s), but got %d (%s)", leaf.FormatCertificate, stored.TrillianLeafIndex, stored.TrillianLeafFormat)
+	}
+}
+
+func TestRegenerateCredential_Errors(t *testing.T) {
+	newAsset := func() *Asset {
+		return &Asset{ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, TrillianLeafIndex: 3, SkipAnchoring: true}
+	}
+
+	testCases := []struct {
+		name           string
+		userID         string
+		admin          bool
+		body           string
+		status         string
+		expectedStatus int
+		expectLeaf     int64
+	}{
+		{name: "Owner without reanchor clears the leaf", userID: "owner", expectedStatus: http.StatusOK},
+		{name: "Admin may regenerate", userID: "moderator", admin: true, expectedStatus: http.StatusOK},
+		{name: "Other users see no asset", userID: "intruder", expectedStatus: http.StatusNotFound, expectLeaf: 3},
+		{name: "Asset still processing", userID: "owner", status: models.StatusPartial, expectedStatus: http.StatusConflict, expectLeaf: 3},
+		{name: "Reanchor after opting out", userID: "owner", body: `{"reanchor": true}`, expectedStatus: http.StatusBadRequest, expectLeaf: 3},
+		{name: "Malformed body", userID: "owner", body: `{`, expectedStatus: http.StatusBadRequest, expectLeaf: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset := newAsset()
+			if tc.status != "" {
+				asset.Status = tc.status
+			}
+			fake := &fakeRepository{assets: map[string]*Asset{"asset-1": asset}}
+			useFakeRepository(t, fake)
+			useFakeCertificates(t, map[string][]byte{})
+			useFakeCertificateStore(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/assets/asset-1/credential/regenerate", strings.NewReader(tc.body))
+			if tc.admin {
+				req = withAdmin(req, tc.userID)
+			} else {
+				req = withUser(req, tc.userID)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if leafIndex := fake.assets["asset-1"].TrillianLeafIndex; leafIndex != tc.expectLeaf {
+				t.Errorf("Expected leaf index %d, but got %d", tc.expectLeaf, leafIndex)
+			}
+		})
+	}
+}
+
+func TestRegenerateCredential_AnchorFailureStillSaves(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	fake := &fakeRepository{assets: map[string]*Asset{
+		"asset-1": {ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, Narrative: "Corrected", TrillianLeafIndex: 3},
+	}}
+	useFakeRepository(t, fake)
+	useFakeCertificates(t, map[string][]byte{"asset-1": []byte(`{"old": true}`)})
+	useFakeCertificateStore(t)
+
+	origQueue := queueCertificateLeaf
+	queueCertificateLeaf = func(ctx context.Context, logID int64, leafValue []byte) (int64, error) {
+		return 0, errors.New("log unavailable")
+	}
+	t.Cleanup(func() { queueCertificateLeaf = origQueue })
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets/asset-1/credential/regenerate", strings.NewReader(`{"reanchor": true}`)), "owner")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusBadGateway, rec.Code, rec.Body.String())
+	}
+	stored := fake.assets["asset-1"]
+	if len(stored.CredentialHistory) != 1 || stored.TrillianLeafIndex != 0 {
+		t.Errorf("Expected the history saved and the stale leaf cleared, but got %+v, leaf %d", stored.CredentialHistory, stored.TrillianLeafIndex)
+	}
+}
+
+func TestRegenerateCredential_WithoutReanchor(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	asset := &Asset{ID: "asset-1", UserID: "owner", Status: models.StatusCompleted, CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), OriginalityScore: 8, TrillianLeafIndex: 3}
+	original, _ := certificate.Generate(asset)
+	originalJSON, _ := json.MarshalIndent(original, "", "  ")
+	fake := &fakeRepository{assets: map[string]*Asset{"asset-1": asset}}
+	useFakeRepository(t, fake)
+	useFakeCertificates(t, map[string][]byte{"asset-1": originalJSON})
+	uploaded := useFakeCertificateStore(t)
+
+	origQueue := queueCertificateLeaf
+	queueCertificateLeaf = func(ctx context.Context, logID int64, leafValue []byte) (int64, error) {
+		t.Errorf("Expected no leaf to be queued without a re-anchor")
+		return 0, nil
+	}
+	t.Cleanup(func() { queueCertificateLeaf = origQueue })
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets/asset-1/credential/regenerate", strings.NewReader(`{}`)), "owner")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	stored := fake.assets["asset-1"]
+	if stored.AnchoringSkipped() != models.AnchoringSkippedByChoice {
+		t.Errorf("Expected the asset to be left unanchored by choice, but got %q with leaf %d", stored.AnchoringSkipped(), stored.TrillianLeafIndex)
+	}
+
+	// Verifying the regenerated credential reports it as not anchored instead of pending forever
+	useFakeCertificates(t, map[string][]byte{"asset-1": uploaded[certificate.ObjectPath(stored)]})
+	rec = httptest.NewRecorder()
+	serve(t, rec, httptest.NewRequest(http.MethodGet, "/api/v1/verify/asset-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Data struct {
+			Status            string `json:"status"`
+			CertificateStatus string `json:"certificate_status"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Data.Status != "not_anchored" || body.Data.CertificateStatus != certificateConsistent {
+		t.Errorf("Expected a consistent not_anchored asset, but got %s", rec.Body.String())
+	}
+}
+
+// objectNames lists the object names of uploaded certificates for failure messages
+func objectNames(uploaded map[string][]byte) []string {
+	names := make([]string, 0, len(uploaded))
+	for name := range uploaded {
+		names = append(names, name)
+	}
+	return names
+}
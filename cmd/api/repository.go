@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"proofpix/internal/models"
+)
+
+// ErrAssetNotFound is returned by the repository when an asset document does not exist
+var ErrAssetNotFound = errors.New("asset not found")
+
+// ErrAssetPartiallyDecoded is returned when saving an asset that was read with
+// unreadable fields left empty
+var ErrAssetPartiallyDecoded = errors.New("asset was only partially decoded")
+
+// ErrTagFilterNotIndexed is returned when listing by a metadata tag whose key has no
+// Firestore composite index. Each key to filter on must be listed in the
+// asset_metadata_tag_keys Terraform variable.
+var ErrTagFilterNotIndexed = errors.New("metadata tag filter has no composite index")
+
+// AssetRepository abstracts the asset reads made by the API handlers
+type AssetRepository interface {
+	GetAsset(ctx context.Context, assetID string) (*Asset, error)
+	SaveAsset(ctx context.Context, asset *Asset) error
+	ListEvents(ctx context.Context, assetID string) ([]models.AssetEvent, error)
+	CountUserAssets(ctx context.Context, userID string) (int, error)
+	GetUserQuota(ctx context.Context, userID string) (quota int, found bool, err error)
+	ListAssets(ctx context.Context, filter AssetFilter) (assets []*Asset, nextPageToken string, err error)
+	FindAssetsByImageHash(ctx context.Context, imageHash string) ([]*Asset, error)
+	FindRelatedAssets(ctx context.Context, assetID string) ([]*Asset, error)
+	ResolveShortCode(ctx context.Context, code string) (assetID string, err error)
+}
+
+// repo is the repository used by the handlers. Tests replace it with a fake.
+var repo AssetRepository = firestoreRepository{}
+
+// firestoreRepository implements AssetRepository on top of Firestore
+type firestoreRepository struct{}
+
+// client creates a Firestore client for the configured project
+func (firestoreRepository) client(ctx context.Context) (*firestore.Client, error) {
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return nil, fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+	return firestore.NewClient(ctx, projectID)
+}
+
+// GetAsset fetches a single asset document by ID
+func (r firestoreRepository) GetAsset(ctx context.Context, assetID string) (*Asset, error) {
+	client, err := r.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	docSnap, err := client.Collection("assets").Doc(assetID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrAssetNotFound
+		}
+		return nil, err
+	}
+
+	var asset Asset
+	if err := docSnap.DataTo(&asset); err != nil {
+		// One malformed field should not make the whole asset unreadable
+		lenient, decodeErr := decodeAssetFields(docSnap.Ref.ID, docSnap.Data())
+		if decodeErr != nil {
+			return nil, fmt.Errorf("failed to parse asset data: %v", err)
+		}
+		log.Printf("Asset %s decoded without unreadable fields %v: %v", assetID, lenient.SkippedFields, err)
+		return lenient, nil
+	}
+	return &asset, nil
+}
+
+// SaveAsset overwrites the asset document with the given asset. Assets decoded with
+// skipped fields are refused, since writing them back would erase those fields.
+func (r firestoreRepository) SaveAsset(ctx context.Context, asset *Asset) error {
+	if len(asset.SkippedFields) > 0 {
+		return fmt.Errorf("%w: %v", ErrAssetPartiallyDecoded, asset.SkippedFields)
+	}
+
+	client, err := r.client(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	_, err = client.Collection("assets").Doc(asset.ID).Set(ctx, asset)
+	return err
+}
+
+// ListEvents returns the audit trail of an asset ordered by timestamp
+func (r firestoreRepository) ListEvents(ctx context.Context, assetID string) ([]models.AssetEvent, error) {
+	client, err := r.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	iter := client.Collection("assets").Doc(assetID).Collection("events").
+		OrderBy("timestamp", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+
+	events := []models.AssetEvent{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var event models.AssetEvent
+		if err := doc.DataTo(&event); err != nil {
+			return nil, fmt.Errorf("failed to parse event %s: %v", doc.Ref.ID, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// CountUserAssets returns how many assets the user holds, excluding soft-deleted ones
+// and ones still awaiting their upload. An upload URL that is never used leaves its
+// asset awaiting upload for good, so counting those would eat into the quota. The
+// count is an aggregation query, so no asset documents are read.
+func (r firestoreRepository) CountUserAssets(ctx context.Context, userID string) (int, error) {
+	client, err := r.client(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	query := client.Collection("assets").
+		Where("user_id", "==", userID).
+		Where("status", "not-in", []string{models.StatusDeleted, models.StatusAwaitingUpload})
+	result, err := query.NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count, ok := result["count"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("aggregation result has no count")
+	}
+	return int(count.GetIntegerValue()), nil
+}
+
+// GetUserQuota reads a per-user quota override from the user_quotas collection
+func (r firestoreRepository) GetUserQuota(ctx context.Context, userID string) (int, bool, error) {
+	client, err := r.client(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer client.Close()
+
+	docSnap, err := client.Collection("user_quotas").Doc(userID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	value, ok := docSnap.Data()["max_assets"].(int64)
+	if !ok || value < 0 {
+		return 0, false, nil
+	}
+	return int(value), true, nil
+}
+
+// ListAssets returns one page of assets across all users matching the filter, newest
+// first. Status, owner, creation date and metadata tag are filtered by the query; the
+// score range and deleted assets are applied as documents are read, so a page is
+// filled from as many documents as it takes.
+func (r firestoreRepository) ListAssets(ctx context.Context, filter AssetFilter) ([]*Asset, string, error) {
+	client, err := r.client(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer client.Close()
+
+	query := client.Collection("assets").Query
+	if filter.Status != "" {
+		query = query.Where("status", "==", filter.Status)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id", "==", filter.UserID)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("created_at", ">=", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at", "<", filter.CreatedBefore)
+	}
+	if filter.MetadataKey != "" {
+		query = query.WherePath(firestore.FieldPath{"metadata", filter.MetadataKey}, "==", filter.MetadataValue)
+	}
+	query = query.OrderBy("created_at", firestore.Desc).OrderBy(firestore.DocumentID, firestore.Desc)
+	if filter.PageToken != "" {
+		createdAt, id, err := decodePageToken(filter.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.StartAfter(createdAt, id)
+	}
+
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	assets := []*Asset{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return assets, "", nil
+		}
+		if filter.MetadataKey != "" && status.Code(err) == codes.FailedPrecondition {
+			return nil, "", fmt.Errorf("%w: %v", ErrTagFilterNotIndexed, err)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		var asset Asset
+		if err := doc.DataTo(&asset); err != nil {
+			lenient, decodeErr := decodeAssetFields(doc.Ref.ID, doc.Data())
+			if decodeErr != nil {
+				log.Printf("Skipping unreadable asset %s in listing: %v", doc.Ref.ID, err)
+				continue
+			}
+			asset = *lenient
+		}
+		asset.ID = doc.Ref.ID
+		if !filter.matchesUnqueried(&asset) {
+			continue
+		}
+		if len(assets) == filter.pageSize() {
+			return assets, encodePageToken(assets[len(assets)-1]), nil
+		}
+		assets = append(assets, &asset)
+	}
+}
+
+// FindAssetsByImageHash returns every asset, of any owner or status, whose uploaded
+// image has the given SHA-256. Unreadable documents are skipped.
+func (r firestoreRepository) FindAssetsByImageHash(ctx context.Context, imageHash string) ([]*Asset, error) {
+	client, err := r.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	iter := client.Collection("assets").Where("image_hash", "==", imageHash).Documents(ctx)
+	defer iter.Stop()
+
+	assets := []*Asset{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return assets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var asset Asset
+		if err := doc.DataTo(&asset); err != nil {
+			lenient, decodeErr := decodeAssetFields(doc.Ref.ID, doc.Data())
+			if decodeErr != nil {
+				log.Printf("Skipping unreadable asset %s in image hash lookup: %v", doc.Ref.ID, err)
+				continue
+			}
+			asset = *lenient
+		}
+		asset.ID = doc.Ref.ID
+		assets = append(assets, &asset)
+	}
+}
+
+// FindRelatedAssets returns every asset, of any owner or status, whose duplicate
+// detection recorded assetID as its nearest match. Unreadable documents are skipped.
+func (r firestoreRepository) FindRelatedAssets(ctx context.Context, assetID string) ([]*Asset, error) {
+	client, err := r.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	iter := client.Collection("assets").WherePath(firestore.FieldPath{"related_asset", "asset_id"}, "==", assetID).Documents(ctx)
+	defer iter.Stop()
+
+	assets := []*Asset{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return assets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var asset Asset
+		if err := doc.DataTo(&asset); err != nil {
+			lenient, decodeErr := decodeAssetFields(doc.Ref.ID, doc.Data())
+			if decodeErr != nil {
+				log.Printf("Skipping unreadable asset %s in related asset lookup: %v", doc.Ref.ID, err)
+				continue
+			}
+			asset = *lenient
+		}
+		asset.ID = doc.Ref.ID
+		assets = append(assets, &asset)
+	}
+}
+
+// ResolveShortCode returns the asset holding a short code from the short_codes
+// collection the worker reserves codes in, or ErrAssetNotFound for an unknown code
+func (r firestoreRepository) ResolveShortCode(ctx context.Context, code string) (string, error) {
+	client, err := r.client(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	docSnap, err := client.Collection("short_codes").Doc(code).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return "", ErrAssetNotFound
+		}
+		return "", err
+	}
+	assetID, _ := docSnap.Data()["asset_id"].(string)
+	if assetID == "" {
+		return "", fmt.Errorf("short code %s has no asset_id", code)
+	}
+	return assetID, nil
+}
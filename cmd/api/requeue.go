@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/models"
+)
+
+// defaultRequeueConcurrency bounds how many assets are sent to the worker at once,
+// overridable with REQUEUE_CONCURRENCY
+const defaultRequeueConcurrency = 4
+
+// workerRequestTimeout bounds each call to the worker's /process endpoint, which
+// only accepts the job and returns
+const workerRequestTimeout = 30 * time.Second
+
+// requeueRunning is held while failed assets are being requeued, so a second run
+// cannot send every asset to the worker twice
+var requeueRunning sync.Mutex
+
+// triggerProcessing asks the worker to process an asset again. Tests replace it.
+var triggerProcessing = postProcessRequest
+
+// requeueConcurrency returns REQUEUE_CONCURRENCY
+func requeueConcurrency() int {
+	value := os.Getenv("REQUEUE_CONCURRENCY")
+	if value == "" {
+		return defaultRequeueConcurrency
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		log.Printf("Invalid REQUEUE_CONCURRENCY %q, using default of %d", value, defaultRequeueConcurrency)
+		return defaultRequeueConcurrency
+	}
+	return concurrency
+}
+
+// handleRequeueFailed sends every failed asset back to the worker. Failed assets are
+// those left partial by a failed analysis or embedding stage; the worker fills in
+// the missing stage and certifies them. created_after and created_before (RFC 3339)
+// narrow the run to a date range. The response counts the assets found and how
+// many the worker accepted.
+// Route: POST /api/v1/admin/assets/requeue-failed
+func handleRequeueFailed(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Admin role required")
+		return
+	}
+
+	filter := AssetFilter{Status: models.StatusPartial, Limit: maxAssetPageSize}
+	var fieldErr *FieldError
+	query := r.URL.Query()
+	if filter.CreatedAfter, fieldErr = timeParam(query, "created_after"); fieldErr != nil {
+		respondValidationError(w, "Invalid query parameters", *fieldErr)
+		return
+	}
+	if filter.CreatedBefore, fieldErr = timeParam(query, "created_before"); fieldErr != nil {
+		respondValidationError(w, "Invalid query parameters", *fieldErr)
+		return
+	}
+
+	if !requeueRunning.TryLock() {
+		respondError(w, http.StatusConflict, "A requeue of failed assets is already running")
+		return
+	}
+	defer requeueRunning.Unlock()
+
+	ctx := r.Context()
+	var assets []*Asset
+	for {
+		page, nextPageToken, err := repo.ListAssets(ctx, filter)
+		if err != nil {
+			log.Printf("Failed to list failed assets: %v", err)
+			respondError(w, http.StatusInternalServerError, "Failed to list failed assets")
+			return
+		}
+		assets = append(assets, page...)
+		if nextPageToken == "" {
+			break
+		}
+		filter.PageToken = nextPageToken
+	}
+
+	rejected := requeueAssets(ctx, assets, requeueConcurrency())
+	userID, _ := auth.GetUserID(r)
+	log.Printf("Admin %s requeued %d of %d failed assets", userID, len(assets)-len(rejected), len(assets))
+
+	data := map[string]interface{}{
+		"matched":  len(assets),
+		"requeued": len(assets) - len(rejected),
+		"failed":   len(rejected),
+	}
+	if len(rejected) > 0 {
+		data["failed_asset_ids"] = rejected
+	}
+	respondJSON(w, http.StatusOK, Response{Success: true, Message: "Failed assets requeued", Data: data})
+}
+
+// requeueAssets triggers processing of each asset with at most concurrency requests
+// in flight, returning the IDs of the assets the worker did not accept
+func requeueAssets(ctx context.Context, assets []*Asset, concurrency int) []string {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		rejected []string
+	)
+	slots := make(chan struct{}, concurrency)
+	for _, asset := range assets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(asset *Asset) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := triggerProcessing(ctx, asset); err != nil {
+				log.Printf("Failed to requeue asset %s: %v", asset.ID, err)
+				mu.Lock()
+				rejected = append(rejected, asset.ID)
+				mu.Unlock()
+			}
+		}(asset)
+	}
+	wg.Wait()
+	sort.Strings(rejected)
+	return rejected
+}
+
+// postProcessRequest posts the asset to the /process endpoint of the worker at WORKER_URL
+func postProcessRequest(ctx context.Context, asset *Asset) error {
+	workerURL := strings.TrimSuffix(os.Getenv("WORKER_URL"), "/")
+	if workerURL == "" {
+		return fmt.Errorf("WORKER_URL environment variable not set")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"user_id":        asset.UserID,
+		"asset_id":       asset.ID,
+		"skip_anchoring": asset.SkipAnchoring,
+		"visibility":     asset.Visibility,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, workerRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, workerURL+"/process", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach worker: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("worker responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
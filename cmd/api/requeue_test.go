@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// useFakeWorker records the assets sent for processing, rejecting those in reject
+func useFakeWorker(t *testing.T, reject map[string]bool) func() []string {
+	t.Helper()
+	var mu sync.Mutex
+	var requeued []string
+	orig := triggerProcessing
+	triggerProcessing = func(ctx context.Context, asset *Asset) error {
+		if reject[asset.ID] {
+			return errors.New("worker unavailable")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requeued = append(requeued, asset.ID)
+		return nil
+	}
+	t.Cleanup(func() { triggerProcessing = orig })
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(requeued)
+		return requeued
+	}
+}
+
+func TestHandleRequeueFailed(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	assets := map[string]*Asset{
+		"done": {ID: "done", UserID: "alice", Status: models.StatusCompleted, CreatedAt: day(1)},
+	}
+	for i, id := range []string{"f1", "f2", "f3", "f4", "f5"} {
+		assets[id] = &Asset{ID: id, UserID: "alice", Status: models.StatusPartial, CreatedAt: day(i + 1), AnalysisFailed: true}
+	}
+
+	testCases := []struct {
+		name             string
+		query            url.Values
+		reject           map[string]bool
+		expectedCode     int
+		expectedRequeued string
+		expectedFailed   int
+	}{
+		{name: "Every failed asset", expectedCode: http.StatusOK, expectedRequeued: "f1,f2,f3,f4,f5"},
+		{name: "Within a date range", query: url.Values{"created_after": {"2024-03-02T00:00:00Z"}, "created_before": {"2024-03-04T00:00:00Z"}}, expectedCode: http.StatusOK, expectedRequeued: "f2,f3"},
+		{name: "Worker rejects some", reject: map[string]bool{"f4": true}, expectedCode: http.StatusOK, expectedRequeued: "f1,f2,f3,f5", expectedFailed: 1},
+		{name: "Invalid date", query: url.Values{"created_after": {"yesterday"}}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			useFakeRepository(t, &fakeRepository{assets: assets})
+			t.Setenv("REQUEUE_CONCURRENCY", "2")
+			requeued := useFakeWorker(t, tc.reject)
+
+			req := withAdmin(httptest.NewRequest(http.MethodPost, "/api/v1/admin/assets/requeue-failed?"+tc.query.Encode(), nil), "admin")
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if got := strings.Join(requeued(), ","); got != tc.expectedRequeued {
+				t.Errorf("Expected assets %q to be requeued, but got %q", tc.expectedRequeued, got)
+			}
+			var body struct {
+				Data struct {
+					Matched  int `json:"matched"`
+					Requeued int `json:"requeued"`
+					Failed   int `json:"failed"`
+				} `json:"data"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			expectedRequeued := len(strings.Split(tc.expectedRequeued, ","))
+			if body.Data.Requeued != expectedRequeued || body.Data.Failed != tc.expectedFailed || body.Data.Matched != expectedRequeued+tc.expectedFailed {
+				t.Errorf("Expected %d requeued and %d failed, but got %+v", expectedRequeued, tc.expectedFailed, body.Data)
+			}
+		})
+	}
+}
+
+func TestHandleRequeueFailed_RejectsNonAdmins(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{}})
+	useFakeWorker(t, nil)
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/v1/admin/assets/requeue-failed", nil), "alice")
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, but got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestHandleRequeueFailed_PreventsOverlappingRuns(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"f1": {ID: "f1", UserID: "alice", Status: models.StatusPartial},
+	}})
+	started, release := make(chan struct{}), make(chan struct{})
+	orig := triggerProcessing
+	triggerProcessing = func(ctx context.Context, asset *Asset) error {
+		close(started)
+		<-release
+		return nil
+	}
+	t.Cleanup(func() { triggerProcessing = orig })
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handleRequeueFailed(first, withAdmin(httptest.NewRequest(http.MethodPost, "/api/v1/admin/assets/requeue-failed", nil), "admin"))
+	}()
+	<-started
+
+	second := httptest.NewRecorder()
+	handleRequeueFailed(second, withAdmin(httptest.NewRequest(http.MethodPost, "/api/v1/admin/assets/requeue-failed", nil), "admin"))
+	if second.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for an overlapping run, but got %d", http.StatusConflict, second.Code)
+	}
+
+	close(release)
+	<-done
+	if first.Code != http.StatusOK {
+		t.Errorf("Expected the first run to finish with %d, but got %d", http.StatusOK, first.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Index changes the API asks the worker to make when an asset is deleted or restored
+const (
+	indexActionRemove  = "remove"
+	indexActionRestore = "restore"
+)
+
+// updateSearchIndex asks the worker to drop an asset from similarity search or put
+// it back. Tests replace it.
+var updateSearchIndex = postIndexUpdate
+
+// postIndexUpdate posts to /index/assets/{id}/{action} on the worker at WORKER_URL,
+// which applies the change and passes it on to its peers. INDEX_DELTA_SECRET is sent
+// in X-Index-Delta-Secret when set.
+func postIndexUpdate(ctx context.Context, assetID, action string) error {
+	workerURL := strings.TrimSuffix(os.Getenv("WORKER_URL"), "/")
+	if workerURL == "" {
+		return fmt.Errorf("WORKER_URL environment variable not set")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, workerRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, workerURL+"/index/assets/"+assetID+"/"+action, nil)
+	if err != nil {
+		return err
+	}
+	if secret := os.Getenv("INDEX_DELTA_SECRET"); secret != "" {
+		req.Header.Set("X-Index-Delta-Secret", secret)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach worker: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("worker responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
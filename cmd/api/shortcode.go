@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"proofpix/internal/shortcode"
+)
+
+// handleShortCode handles GET /v/{shortcode}, redirecting a code typed from a printed
+// badge to the verification of its asset. Codes are matched leniently, so 7k3m-q9td
+// and 7K3MQ9TD resolve alike, and the query string is passed on to the verification.
+func handleShortCode(w http.ResponseWriter, r *http.Request) {
+	code, err := shortcode.Normalize(r.PathValue("shortcode"))
+	if err != nil {
+		respondValidationError(w, "Invalid short code", FieldError{Field: "shortcode", Message: err.Error()})
+		return
+	}
+
+	assetID, err := repo.ResolveShortCode(context.Background(), code)
+	if err != nil {
+		if errors.Is(err, ErrAssetNotFound) {
+			respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "Short code not found")
+			return
+		}
+		log.Printf("Failed to resolve short code %s: %v", code, err)
+		respondError(w, http.StatusInternalServerError, "Failed to resolve short code")
+		return
+	}
+
+	target := "/api/v1/verify/" + url.PathEscape(assetID)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
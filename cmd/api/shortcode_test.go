@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleShortCode(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{
+		assets:     map[string]*Asset{},
+		shortCodes: map[string]string{"7K3MQ9TD": "asset-1"},
+	})
+
+	testCases := []struct {
+		name             string
+		path             string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{name: "Exact code", path: "/v/7K3MQ9TD", expectedCode: http.StatusFound, expectedLocation: "/api/v1/verify/asset-1"},
+		{name: "Typed with dash and lower case", path: "/v/7k3m-q9td", expectedCode: http.StatusFound, expectedLocation: "/api/v1/verify/asset-1"},
+		{name: "Query string kept", path: "/v/7K3MQ9TD?verbose=true", expectedCode: http.StatusFound, expectedLocation: "/api/v1/verify/asset-1?verbose=true"},
+		{name: "Unknown code", path: "/v/7K3MQ9TE", expectedCode: http.StatusNotFound},
+		{name: "Malformed code", path: "/v/abc!", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if location := rec.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("Expected Location %q, but got %q", tc.expectedLocation, location)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"proofpix/internal/auth"
+	"proofpix/internal/models"
+)
+
+// defaultAssetStreamTimeout bounds how long an event stream stays open, overridable
+// with ASSET_STREAM_TIMEOUT. Clients reconnect to keep following a slow asset.
+const defaultAssetStreamTimeout = 10 * time.Minute
+
+// assetStreamTimeout returns ASSET_STREAM_TIMEOUT
+func assetStreamTimeout() time.Duration {
+	value := os.Getenv("ASSET_STREAM_TIMEOUT")
+	if value == "" {
+		return defaultAssetStreamTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Printf("Invalid ASSET_STREAM_TIMEOUT %q, using default of %v", value, defaultAssetStreamTimeout)
+		return defaultAssetStreamTimeout
+	}
+	return timeout
+}
+
+// watchAssetEvents calls emit with each of an asset's events in order: those
+// already recorded, then new ones as the worker records them. It returns when emit
+// returns false or ctx ends. Tests replace it with a fake event source.
+var watchAssetEvents = watchFirestoreEvents
+
+// watchFirestoreEvents follows the assets/{assetID}/events subcollection with a
+// Firestore snapshot listener
+func watchFirestoreEvents(ctx context.Context, assetID string, emit func(models.AssetEvent) bool) error {
+	client, err := firestoreRepository{}.client(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	snapshots := client.Collection("assets").Doc(assetID).Collection("events").
+		OrderBy("timestamp", firestore.Asc).Snapshots(ctx)
+	defer snapshots.Stop()
+
+	for {
+		snapshot, err := snapshots.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, change := range snapshot.Changes {
+			if change.Kind != firestore.DocumentAdded {
+				continue
+			}
+			var event models.AssetEvent
+			if err := change.Doc.DataTo(&event); err != nil {
+				return fmt.Errorf("failed to parse event %s: %v", change.Doc.Ref.ID, err)
+			}
+			if !emit(event) {
+				return nil
+			}
+		}
+	}
+}
+
+// handleAssetStream streams an asset's processing progress to its owner as
+// Server-Sent Events: a "status" event with the asset's current status, then one
+// event per recorded stage, named after it. The stream closes after the
+// "completed" or "failed" event, or after ASSET_STREAM_TIMEOUT.
+// Route: GET /api/v1/assets/{id}/stream
+func handleAssetStream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "User ID not found in context")
+		return
+	}
+
+	assetID := r.PathValue("id")
+
+	// Only the owner may follow the asset's processing
+	asset, ok := getOwnedAsset(w, r, assetID, userID)
+	if !ok {
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(name string, data interface{}) bool {
+		if err := writeServerSentEvent(w, name, data); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+	if !send("status", map[string]string{"asset_id": assetID, "status": asset.Status}) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), assetStreamTimeout())
+	defer cancel()
+	err := watchAssetEvents(ctx, assetID, func(event models.AssetEvent) bool {
+		if !send(event.Stage, event) {
+			return false
+		}
+		return event.Stage != models.StageCompleted && event.Stage != models.StageFailed
+	})
+	if err != nil {
+		log.Printf("Event stream for asset %s interrupted: %v", assetID, err)
+		send("error", map[string]string{"message": "Event stream interrupted"})
+	}
+}
+
+// writeServerSentEvent writes one named event with a JSON data line
+func writeServerSentEvent(w io.Writer, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
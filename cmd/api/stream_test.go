@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// useFakeEventSource streams the given events from memory for the duration of the
+// test, recording whether the handler stopped the stream before they ran out
+func useFakeEventSource(t *testing.T, events []models.AssetEvent) *bool {
+	t.Helper()
+	stopped := new(bool)
+	orig := watchAssetEvents
+	watchAssetEvents = func(ctx context.Context, assetID string, emit func(models.AssetEvent) bool) error {
+		for _, event := range events {
+			if !emit(event) {
+				*stopped = true
+				return nil
+			}
+		}
+		// A live source would keep waiting for events until ctx ends
+		<-ctx.Done()
+		return nil
+	}
+	t.Cleanup(func() { watchAssetEvents = orig })
+	return stopped
+}
+
+// streamedEvents returns the names of the Server-Sent Events in a response body
+func streamedEvents(t *testing.T, body string) []string {
+	t.Helper()
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestHandleAssetStream(t *testing.T) {
+	t.Setenv("ASSET_STREAM_TIMEOUT", "200ms")
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"asset-1": {ID: "asset-1", UserID: "owner", Status: models.StatusProcessing},
+	}})
+
+	now := time.Now().UTC()
+	stage := func(name string, success bool) models.AssetEvent {
+		return models.AssetEvent{Stage: name, Success: success, Timestamp: now}
+	}
+
+	testCases := []struct {
+		name            string
+		userID          string
+		events          []models.AssetEvent
+		expectedStatus  int
+		expectedEvents  []string
+		expectedStopped bool
+	}{
+		{
+			name:   "Closes on completion",
+			userID: "owner",
+			events: []models.AssetEvent{
+				stage(models.StageDownloaded, true), stage(models.StageAnalyzed, true), stage(models.StageEmbedded, true),
+				stage(models.StageSaved, true), stage(models.StageCertified, true), stage(models.StageLogged, true),
+				stage(models.StageCompleted, true), stage(models.StageDownloaded, true),
+			},
+			expectedStatus:  http.StatusOK,
+			expectedEvents:  []string{"status", "downloaded", "analyzed", "embedded", "saved", "certified", "logged", "completed"},
+			expectedStopped: true,
+		},
+		{
+			name:            "Closes on failure",
+			userID:          "owner",
+			events:          []models.AssetEvent{stage(models.StageDownloaded, false), stage(models.StageFailed, false), stage(models.StageCompleted, true)},
+			expectedStatus:  http.StatusOK,
+			expectedEvents:  []string{"status", "downloaded", "failed"},
+			expectedStopped: true,
+		},
+		{
+			name:           "Times out while processing continues",
+			userID:         "owner",
+			events:         []models.AssetEvent{stage(models.StageDownloaded, true)},
+			expectedStatus: http.StatusOK,
+			expectedEvents: []string{"status", "downloaded"},
+		},
+		{
+			name:           "Other user is refused",
+			userID:         "intruder",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stopped := useFakeEventSource(t, tc.events)
+
+			rec := httptest.NewRecorder()
+			serve(t, rec, withUser(httptest.NewRequest(http.MethodGet, "/api/v1/assets/asset-1/stream", nil), tc.userID))
+
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != "text/event-stream" {
+				t.Errorf("Expected an event stream, but got %q", contentType)
+			}
+			if events := streamedEvents(t, rec.Body.String()); !reflect.DeepEqual(events, tc.expectedEvents) {
+				t.Errorf("Expected events %v, but got %v", tc.expectedEvents, events)
+			}
+			if *stopped != tc.expectedStopped {
+				t.Errorf("Expected the stream to be stopped by the handler to be %t, but got %t", tc.expectedStopped, *stopped)
+			}
+			if !strings.Contains(rec.Body.String(), `data: {"asset_id":"asset-1","status":"processing"}`) {
+				t.Errorf("Expected the current status first, but got %s", rec.Body.String())
+			}
+		})
+	}
+}
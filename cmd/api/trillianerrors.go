@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// trillianRetryAfter is the Retry-After sent when the log is unavailable
+const trillianRetryAfter = 30 * time.Second
+
+// trillianFailure is the HTTP response sent when a Trillian call fails with a
+// given gRPC status code
+type trillianFailure struct {
+	statusCode int
+	code       string
+	message    string
+}
+
+// trillianFailures maps the gRPC status codes of Trillian failures to the response
+// clients get; codes not listed here are a 500 with the caller's message
+var trillianFailures = map[codes.Code]trillianFailure{
+	// The log has no such leaf or tree, which retrying will not change
+	codes.NotFound: {http.StatusNotFound, ErrCodeLeafNotFound, "Log leaf not found"},
+	// The log server is down or overloaded; clients may retry later
+	codes.Unavailable:       {http.StatusServiceUnavailable, ErrCodeLogUnavailable, "Transparency log is temporarily unavailable"},
+	codes.DeadlineExceeded:  {http.StatusServiceUnavailable, ErrCodeLogUnavailable, "Transparency log did not respond in time"},
+	codes.ResourceExhausted: {http.StatusServiceUnavailable, ErrCodeLogUnavailable, "Transparency log is overloaded"},
+}
+
+// trillianFailureFor returns the response for a failed Trillian call, falling back
+// to a 500 with message for unmapped codes
+func trillianFailureFor(err error, message string) trillianFailure {
+	if failure, ok := trillianFailures[status.Code(err)]; ok {
+		return failure
+	}
+	return trillianFailure{statusCode: http.StatusInternalServerError, code: ErrCodeInternal, message: message}
+}
+
+// respondTrillianError sends the response mapped from a failed Trillian call's gRPC
+// status code, or a 500 with message
+func respondTrillianError(w http.ResponseWriter, err error, message string) {
+	failure := trillianFailureFor(err, message)
+	if failure.statusCode == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(int(trillianRetryAfter/time.Second)))
+	}
+	respondErrorCode(w, failure.statusCode, failure.code, failure.message)
+}
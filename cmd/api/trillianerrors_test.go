@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+func TestVerifyHandler_TrillianErrors(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("READ_ONLY", "")
+
+	logged := &Asset{
+		ID:                "logged",
+		UserID:            "owner",
+		Status:            models.StatusCompleted,
+		CreatedAt:         time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore:  9,
+		TrillianLeafIndex: 3,
+	}
+	credential, err := certificate.Generate(logged)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	stored, _ := json.MarshalIndent(credential, "", "  ")
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{"logged": logged}})
+	useFakeCertificates(t, map[string][]byte{"logged": stored})
+	useFakeLogRoot(t, 10)
+	hashLeaf, _ := leaf.Value(leaf.FormatHash, stored)
+
+	// The log fails the way the gRPC client does, wrapped by the fetch functions
+	var leafErr, proofErr error
+	origProof, origLeaf := fetchInclusionProof, fetchLeafValue
+	t.Cleanup(func() { fetchInclusionProof, fetchLeafValue = origProof, origLeaf })
+	fetchLeafValue = func(ctx context.Context, logID int64, leafIndex int64) ([]byte, error) {
+		if leafErr != nil {
+			return nil, fmt.Errorf("failed to get leaf %d from Trillian log %d: %w", leafIndex, logID, leafErr)
+		}
+		return hashLeaf, nil
+	}
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		if proofErr != nil {
+			return nil, fmt.Errorf("failed to get inclusion proof from Trillian log %d for leaf %d: %w", logID, leafIndex, proofErr)
+		}
+		return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: leafIndex}}, nil
+	}
+
+	testCases := []struct {
+		name               string
+		leafErr            error
+		proofErr           error
+		expectedCode       int
+		expectedErrorCode  string
+		expectedRetryAfter string
+	}{
+		{name: "NotFound", proofErr: status.Error(codes.NotFound, "no such leaf"), expectedCode: http.StatusNotFound, expectedErrorCode: ErrCodeLeafNotFound},
+		{name: "Unavailable", proofErr: status.Error(codes.Unavailable, "connection refused"), expectedCode: http.StatusServiceUnavailable, expectedErrorCode: ErrCodeLogUnavailable, expectedRetryAfter: "30"},
+		{name: "DeadlineExceeded", proofErr: status.Error(codes.DeadlineExceeded, "deadline exceeded"), expectedCode: http.StatusServiceUnavailable, expectedErrorCode: ErrCodeLogUnavailable, expectedRetryAfter: "30"},
+		{name: "ResourceExhausted", proofErr: status.Error(codes.ResourceExhausted, "quota"), expectedCode: http.StatusServiceUnavailable, expectedErrorCode: ErrCodeLogUnavailable, expectedRetryAfter: "30"},
+		{name: "Internal", proofErr: status.Error(codes.Internal, "storage failure"), expectedCode: http.StatusInternalServerError, expectedErrorCode: ErrCodeInternal},
+		{name: "PermissionDenied", proofErr: status.Error(codes.PermissionDenied, "denied"), expectedCode: http.StatusInternalServerError, expectedErrorCode: ErrCodeInternal},
+		{name: "Not a gRPC error", proofErr: errors.New("TRILLIAN_LOG_SERVER_ADDR environment variable not set"), expectedCode: http.StatusInternalServerError, expectedErrorCode: ErrCodeInternal},
+		{name: "OutOfRange is still pending", proofErr: status.Error(codes.OutOfRange, "leaf index 3 beyond tree size 2"), expectedCode: http.StatusAccepted},
+		{name: "Leaf fetch Unavailable", leafErr: status.Error(codes.Unavailable, "connection refused"), expectedCode: http.StatusServiceUnavailable, expectedErrorCode: ErrCodeLogUnavailable, expectedRetryAfter: "30"},
+		{name: "Leaf fetch NotFound", leafErr: status.Error(codes.NotFound, "tree not found"), expectedCode: http.StatusNotFound, expectedErrorCode: ErrCodeLeafNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			leafErr, proofErr = tc.leafErr, tc.proofErr
+			rec := httptest.NewRecorder()
+			serve(t, rec, httptest.NewRequest(http.MethodGet, "/api/v1/verify/logged", nil))
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Retry-After"); tc.expectedErrorCode != "" && got != tc.expectedRetryAfter {
+				t.Errorf("Expected Retry-After %q, but got %q", tc.expectedRetryAfter, got)
+			}
+			if tc.expectedErrorCode == "" {
+				return
+			}
+			var response Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Code != tc.expectedErrorCode {
+				t.Errorf("Expected error code %s, but got %s", tc.expectedErrorCode, response.Code)
+			}
+			if response.Success || response.Message == "" {
+				t.Errorf("Expected a failure with a message, but got %+v", response)
+			}
+		})
+	}
+}
+
+func TestTrillianFailureFor_DistinctMessages(t *testing.T) {
+	messages := map[string]codes.Code{}
+	for code := range trillianFailures {
+		message := trillianFailureFor(status.Error(code, "failure"), "Failed to retrieve inclusion proof").message
+		if other, ok := messages[message]; ok {
+			t.Errorf("Expected a distinct message for %s, but it shares %q with %s", code, message, other)
+		}
+		messages[message] = code
+	}
+	if got := trillianFailureFor(status.Error(codes.Internal, "failure"), "Failed to retrieve log leaf").message; got != "Failed to retrieve log leaf" {
+		t.Errorf("Expected unmapped codes to keep the caller's message, but got %q", got)
+	}
+}
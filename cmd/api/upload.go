@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// uploadURLExpiry is how long an issued upload URL stays valid
+const uploadURLExpiry = 15 * time.Minute
+
+// signUploadURL returns a signed URL the client PUTs its JPEG to. Tests replace it
+// with a fake.
+var signUploadURL = signedUploadURL
+
+// signedUploadURL signs a V4 PUT URL for an object in a Google Cloud Storage bucket
+func signedUploadURL(ctx context.Context, bucketName, objectName string) (string, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create storage client: %v", err)
+	}
+	defer client.Close()
+
+	opts := &storage.SignedURLOptions{
+		Scheme: storage.SigningSchemeV4,
+		Method: "PUT",
+		Headers: []string{
+			"Content-Type:image/jpeg",
+		},
+		Expires: time.Now().Add(uploadURLExpiry),
+	}
+	return client.Bucket(bucketName).SignedURL(objectName, opts)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestHandleAssets_CreatesPendingAsset(t *testing.T) {
+	t.Setenv("GCS_BUCKET_NAME", "proofpix-uploads")
+	t.Setenv("READ_ONLY", "")
+	fake := &fakeRepository{assets: map[string]*Asset{}}
+	useFakeRepository(t, fake)
+	orig := signUploadURL
+	signUploadURL = func(ctx context.Context, bucketName, objectName string) (string, error) {
+		return "https://storage.example/" + bucketName + "/" + objectName, nil
+	}
+	t.Cleanup(func() { signUploadURL = orig })
+
+	rec := httptest.NewRecorder()
+	serve(t, rec, withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets", nil), "owner"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Data AssetResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Data.Status != models.StatusAwaitingUpload {
+		t.Errorf("Expected status %q, but got %q", models.StatusAwaitingUpload, response.Data.Status)
+	}
+
+	stored := fake.assets[response.Data.AssetID]
+	if stored == nil || stored.UserID != "owner" || stored.Status != models.StatusAwaitingUpload || stored.CreatedAt.IsZero() {
+		t.Fatalf("Expected a pending asset owned by the uploader, but got %+v", stored)
+	}
+	if stored.UploadBucket != "proofpix-uploads" {
+		t.Errorf("Expected the pending asset to record upload bucket proofpix-uploads, but got %q", stored.UploadBucket)
+	}
+
+	// Verification reports each early state until the worker completes the asset
+	for _, status := range []string{models.StatusAwaitingUpload, models.StatusProcessing} {
+		stored.Status = status
+		rec := httptest.NewRecorder()
+		serve(t, rec, httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+stored.ID, nil))
+		var verification struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &verification)
+		if rec.Code != http.StatusAccepted || verification.Data["status"] != status {
+			t.Errorf("Expected a %d reporting %q, but got %d: %s", http.StatusAccepted, status, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleAssets_StoresMetadataForTagFilter(t *testing.T) {
+	t.Setenv("GCS_BUCKET_NAME", "proofpix-uploads")
+	t.Setenv("READ_ONLY", "")
+	fake := &fakeRepository{assets: map[string]*Asset{
+		"untagged": {ID: "untagged", UserID: "owner", Status: models.StatusCompleted, CreatedAt: time.Now().UTC()},
+		"other":    {ID: "other", UserID: "owner", Status: models.StatusCompleted, CreatedAt: time.Now().UTC(), Metadata: map[string]string{"campaign": "autumn"}},
+	}}
+	useFakeRepository(t, fake)
+	orig := signUploadURL
+	signUploadURL = func(ctx context.Context, bucketName, objectName string) (string, error) {
+		return "https://storage.example/" + bucketName + "/" + objectName, nil
+	}
+	t.Cleanup(func() { signUploadURL = orig })
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"metadata": {"campaign": "spring"}}`)
+	serve(t, rec, withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets", body), "owner"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Data AssetResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	stored := fake.assets[response.Data.AssetID]
+	if stored == nil || stored.Metadata["campaign"] != "spring" {
+		t.Fatalf("Expected the pending asset to hold the metadata, but got %+v", stored)
+	}
+
+	rec = httptest.NewRecorder()
+	serve(t, rec, withUser(httptest.NewRequest(http.MethodGet, "/api/v1/assets?tag=campaign:spring", nil), "owner"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var listing struct {
+		Data struct {
+			Assets []adminAssetView `json:"assets"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(listing.Data.Assets) != 1 || listing.Data.Assets[0].ID != stored.ID {
+		t.Errorf("Expected only asset %s to match the tag, but got %+v", stored.ID, listing.Data.Assets)
+	}
+}
+
+func TestHandleAssets_RejectsInvalidMetadata(t *testing.T) {
+	t.Setenv("GCS_BUCKET_NAME", "proofpix-uploads")
+	t.Setenv("READ_ONLY", "")
+	fake := &fakeRepository{assets: map[string]*Asset{}}
+	useFakeRepository(t, fake)
+
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{name: "not JSON", body: `campaign=spring`},
+		{name: "key with separator", body: `{"metadata": {"campaign:x": "spring"}}`},
+		{name: "key with dot", body: `{"metadata": {"a.b": "spring"}}`},
+		{name: "empty value", body: `{"metadata": {"campaign": ""}}`},
+		{name: "long value", body: `{"metadata": {"campaign": "` + strings.Repeat("x", maxMetadataValueLength+1) + `"}}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			serve(t, rec, withUser(httptest.NewRequest(http.MethodPost, "/api/v1/assets", strings.NewReader(tc.body)), "owner"))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, but got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+			if len(fake.assets) != 0 {
+				t.Errorf("Expected no asset to be created, but got %d", len(fake.assets))
+			}
+		})
+	}
+}
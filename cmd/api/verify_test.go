@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+func TestVerifyHandler_PendingStates(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"pending": {ID: "pending", UserID: "owner", Status: models.StatusCompleted, ModelVersion: "gemini-1.5-flash-002"},
+			"partial": {ID: "partial", UserID: "owner", Status: models.StatusPartial, EmbeddingFailed: true, ModelVersion: "gemini-1.5-flash-002"},
+			"deleted": {ID: "deleted", UserID: "owner", Status: models.StatusDeleted},
+		},
+	})
+	useFakeCertificates(t, nil)
+
+	testCases := []struct {
+		name                    string
+		assetID                 string
+		expectedCode            int
+		expectedStatus          string
+		expectedEmbeddingFailed bool
+	}{
+		{name: "Completed but not logged", assetID: "pending", expectedCode: http.StatusAccepted, expectedStatus: "pending_inclusion"},
+		{name: "Partial asset", assetID: "partial", expectedCode: http.StatusAccepted, expectedStatus: models.StatusPartial, expectedEmbeddingFailed: true},
+		{name: "Deleted asset", assetID: "deleted", expectedCode: http.StatusNotFound},
+		{name: "Unknown asset", assetID: "missing", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+tc.assetID, nil)
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedStatus == "" {
+				return
+			}
+
+			var body struct {
+				Data struct {
+					Status          string `json:"status"`
+					EmbeddingFailed bool   `json:"embedding_failed"`
+					ModelVersion    string `json:"model_version"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Data.Status != tc.expectedStatus {
+				t.Errorf("Expected status %q, but got %q", tc.expectedStatus, body.Data.Status)
+			}
+			if body.Data.EmbeddingFailed != tc.expectedEmbeddingFailed {
+				t.Errorf("Expected embedding_failed %t, but got %t", tc.expectedEmbeddingFailed, body.Data.EmbeddingFailed)
+			}
+			if body.Data.ModelVersion != "gemini-1.5-flash-002" {
+				t.Errorf("Expected model_version gemini-1.5-flash-002, but got %q", body.Data.ModelVersion)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_CertificateConsistency(t *testing.T) {
+	asset := &Asset{
+		ID:               "asset-1",
+		UserID:           "owner",
+		Status:           models.StatusCompleted,
+		CreatedAt:        time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore: 9,
+	}
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{"asset-1": asset}})
+
+	credential, err := certificate.Generate(asset)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	valid, _ := json.Marshal(credential)
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	signer, err := certificate.NewSigningConfig(certificate.AlgorithmEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "did:web:proofpix.com#key-1")
+	if err != nil {
+		t.Fatalf("Failed to create signing config: %v", err)
+	}
+	if err := signer.Sign(credential); err != nil {
+		t.Fatalf("Failed to sign certificate: %v", err)
+	}
+	signed, _ := json.Marshal(credential)
+	// Declaring a cryptosuite is all it takes for a forged proof to look signed
+	credential.Proof.ProofValue = "uZm9yZ2Vk"
+	forged, _ := json.Marshal(credential)
+
+	credential, _ = certificate.Generate(asset)
+	credential.Proof.ProofValue = "0000"
+	tampered, _ := json.Marshal(credential)
+
+	// After a rotation, credentials signed with #key-1 are checked with the retired key
+	t.Setenv("CERTIFICATE_ISSUER_DID", "did:web:proofpix.com")
+	_, rotatedKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ = x509.MarshalPKCS8PrivateKey(rotatedKey)
+	rotated, err := certificate.NewSigningConfig(certificate.AlgorithmEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "did:web:proofpix.com#key-2")
+	if err != nil {
+		t.Fatalf("Failed to create signing config: %v", err)
+	}
+	retired := []certificate.DIDKey{{Fragment: "key-1", PublicKey: signer.PublicKey()}}
+
+	origSigner, origRetired := credentialSigner, retiredKeys
+	t.Cleanup(func() { credentialSigner, retiredKeys = origSigner, origRetired })
+
+	testCases := []struct {
+		name               string
+		stored             []byte
+		signer             *certificate.SigningConfig
+		retired            []certificate.DIDKey
+		expectedCode       int
+		expectedCertStatus string
+	}{
+		{name: "Consistent certificate", stored: valid, expectedCode: http.StatusAccepted, expectedCertStatus: certificateConsistent},
+		{name: "Tampered certificate", stored: tampered, expectedCode: http.StatusConflict, expectedCertStatus: certificateInconsistent},
+		{name: "Missing certificate", expectedCode: http.StatusAccepted, expectedCertStatus: certificateMissing},
+		{name: "Signed certificate", stored: signed, signer: signer, expectedCode: http.StatusAccepted, expectedCertStatus: certificateConsistent},
+		{name: "Forged signature", stored: forged, signer: signer, expectedCode: http.StatusConflict, expectedCertStatus: certificateInconsistent},
+		{name: "Signed certificate without a key", stored: signed, expectedCode: http.StatusConflict, expectedCertStatus: certificateInconsistent},
+		{name: "Signed with a retired key", stored: signed, signer: rotated, retired: retired, expectedCode: http.StatusAccepted, expectedCertStatus: certificateConsistent},
+		{name: "Signed with an unpublished key", stored: signed, signer: rotated, expectedCode: http.StatusConflict, expectedCertStatus: certificateInconsistent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			credentialSigner, retiredKeys = tc.signer, tc.retired
+			certificates := map[string][]byte{}
+			if tc.stored != nil {
+				certificates["asset-1"] = tc.stored
+			}
+			useFakeCertificates(t, certificates)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/asset-1", nil)
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+
+			var body struct {
+				Data struct {
+					CertificateStatus string `json:"certificate_status"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Data.CertificateStatus != tc.expectedCertStatus {
+				t.Errorf("Expected certificate_status %q, but got %q", tc.expectedCertStatus, body.Data.CertificateStatus)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_LeafFormats(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+
+	asset := &Asset{
+		ID:                "asset-1",
+		UserID:            "owner",
+		Status:            models.StatusCompleted,
+		CreatedAt:         time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore:  9,
+		TrillianLeafIndex: 3,
+	}
+	credential, err := certificate.Generate(asset)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	stored, _ := json.MarshalIndent(credential, "", "  ")
+	useFakeCertificates(t, map[string][]byte{"asset-1": stored})
+	useFakeLogRoot(t, 10)
+
+	origProof, origLeaf := fetchInclusionProof, fetchLeafValue
+	t.Cleanup(func() { fetchInclusionProof, fetchLeafValue = origProof, origLeaf })
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		// Trillian rejects a proof request without a tree size
+		if treeSize != 10 {
+			t.Errorf("Expected a proof for the latest root's tree size 10, but got %d", treeSize)
+		}
+		return &trillian.GetInclusionProofResponse{}, nil
+	}
+
+	hashLeaf, _ := leaf.Value(leaf.FormatHash, stored)
+	certificateLeaf, _ := leaf.Value(leaf.FormatCertificate, stored)
+
+	testCases := []struct {
+		name         string
+		format       string
+		logged       []byte
+		expectedCode int
+	}{
+		{name: "Hash leaf matches", format: leaf.FormatHash, logged: hashLeaf, expectedCode: http.StatusOK},
+		{name: "Legacy assets default to hash", format: "", logged: hashLeaf, expectedCode: http.StatusOK},
+		{name: "Certificate leaf matches", format: leaf.FormatCertificate, logged: certificateLeaf, expectedCode: http.StatusOK},
+		{name: "Hash leaf read as certificate", format: leaf.FormatCertificate, logged: hashLeaf, expectedCode: http.StatusConflict},
+		{name: "Tampered log leaf", format: leaf.FormatHash, logged: []byte("tampered"), expectedCode: http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logged := *asset
+			logged.TrillianLeafFormat = tc.format
+			useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{"asset-1": &logged}})
+
+			// Mock log holding the leaf at the asset's index
+			fetchLeafValue = func(ctx context.Context, logID int64, leafIndex int64) ([]byte, error) {
+				if leafIndex != 3 {
+					t.Errorf("Expected leaf index 3, but got %d", leafIndex)
+				}
+				return tc.logged, nil
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/asset-1", nil)
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Errorf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_SkippedAnchoring(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"unanchored": {ID: "unanchored", UserID: "owner", Status: models.StatusCompleted, SkipAnchoring: true},
+			"low-score":  {ID: "low-score", UserID: "owner", Status: models.StatusCompleted, OriginalityScore: 20, BelowAnchorMinScore: true},
+			"pending":    {ID: "pending", UserID: "owner", Status: models.StatusCompleted},
+		},
+	})
+	useFakeCertificates(t, nil)
+
+	testCases := []struct {
+		name               string
+		assetID            string
+		expectedCode       int
+		expectedStatus     string
+		expectedAnchoring  string
+		expectedRetryAfter bool
+	}{
+		{name: "Skipped by choice", assetID: "unanchored", expectedCode: http.StatusOK, expectedStatus: "not_anchored", expectedAnchoring: models.AnchoringSkippedByChoice},
+		{name: "Below the minimum score", assetID: "low-score", expectedCode: http.StatusOK, expectedStatus: "not_anchored", expectedAnchoring: models.AnchoringBelowMinScore},
+		{name: "Still pending", assetID: "pending", expectedCode: http.StatusAccepted, expectedStatus: "pending_inclusion", expectedRetryAfter: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+tc.assetID, nil)
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if hasRetry := rec.Header().Get("Retry-After") != ""; hasRetry != tc.expectedRetryAfter {
+				t.Errorf("Expected Retry-After present=%t, but got %t", tc.expectedRetryAfter, hasRetry)
+			}
+
+			var body struct {
+				Data struct {
+					Status    string `json:"status"`
+					Anchoring string `json:"anchoring"`
+					Logged    bool   `json:"logged"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Data.Status != tc.expectedStatus {
+				t.Errorf("Expected status %q, but got %q", tc.expectedStatus, body.Data.Status)
+			}
+			if body.Data.Anchoring != tc.expectedAnchoring {
+				t.Errorf("Expected anchoring %q, but got %q", tc.expectedAnchoring, body.Data.Anchoring)
+			}
+			if body.Data.Logged {
+				t.Errorf("Expected logged to be false")
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_QuotaExceeded(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"blocked": {ID: "blocked", UserID: "owner", Status: models.StatusQuotaExceeded},
+		},
+	})
+	useFakeCertificates(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/blocked", nil)
+	rec := httptest.NewRecorder()
+	serve(t, rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Data struct {
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Data.Status != models.StatusQuotaExceeded {
+		t.Errorf("Expected status %q, but got %q", models.StatusQuotaExceeded, body.Data.Status)
+	}
+}
+
+func TestVerifyHandler_ConditionalRequests(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+
+	logged := &Asset{
+		ID:                "logged",
+		UserID:            "owner",
+		Status:            models.StatusCompleted,
+		CreatedAt:         time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore:  9,
+		TrillianLeafIndex: 3,
+	}
+	credential, err := certificate.Generate(logged)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	stored, _ := json.MarshalIndent(credential, "", "  ")
+	useFakeCertificates(t, map[string][]byte{"logged": stored})
+	useFakeLogRoot(t, 10)
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"logged":  logged,
+		"pending": {ID: "pending", UserID: "owner", Status: models.StatusCompleted},
+	}})
+
+	logCalls := 0
+	origProof, origLeaf := fetchInclusionProof, fetchLeafValue
+	t.Cleanup(func() { fetchInclusionProof, fetchLeafValue = origProof, origLeaf })
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		logCalls++
+		return &trillian.GetInclusionProofResponse{}, nil
+	}
+	hashLeaf, _ := leaf.Value(leaf.FormatHash, stored)
+	fetchLeafValue = func(ctx context.Context, logID int64, leafIndex int64) ([]byte, error) {
+		logCalls++
+		return hashLeaf, nil
+	}
+
+	get := func(assetID, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+assetID, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		serve(t, rec, req)
+		return rec
+	}
+
+	first := get("logged", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, but got %d and ETag %q", first.Code, etag)
+	}
+
+	logCalls = 0
+	repeat := get("logged", etag)
+	if repeat.Code != http.StatusNotModified {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusNotModified, repeat.Code, repeat.Body.String())
+	}
+	if repeat.Body.Len() != 0 || repeat.Header().Get("ETag") != etag {
+		t.Errorf("Expected an empty 304 carrying ETag %s, but got %q with ETag %q", etag, repeat.Body.String(), repeat.Header().Get("ETag"))
+	}
+	if logCalls != 0 {
+		t.Errorf("Expected no log calls for an unchanged asset, but got %d", logCalls)
+	}
+
+	// Re-anchoring moves the asset to a new leaf, which invalidates the ETag
+	reanchored := *logged
+	reanchored.TrillianLeafIndex = 7
+	if verifyETag(&reanchored, 10) == etag {
+		t.Errorf("Expected a new leaf index to change the ETag")
+	}
+
+	// Later leaves add confirmations, which also invalidates the ETag
+	if verifyETag(logged, 11) == etag {
+		t.Errorf("Expected a grown tree to change the ETag")
+	}
+
+	pending := get("pending", `"anything", *`)
+	if pending.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, but got %d", http.StatusAccepted, pending.Code)
+	}
+	if pending.Header().Get("ETag") != "" || pending.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected an uncached pending response, but got ETag %q and Cache-Control %q", pending.Header().Get("ETag"), pending.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Verify cache defaults, overridable with VERIFY_CACHE_TTL, VERIFY_CACHE_FAILED_TTL
+// and VERIFY_CACHE_MAX_ENTRIES
+const (
+	defaultVerifyCacheTTL        = time.Minute
+	defaultVerifyCacheFailedTTL  = 30 * time.Second
+	defaultVerifyCacheMaxEntries = 1000
+)
+
+// Verification outcomes the cache keeps apart, each with its own TTL
+const (
+	// verifyOutcomeLogged is an asset whose certificate is consistent and whose leaf
+	// is integrated in the log
+	verifyOutcomeLogged = "logged"
+	// verifyOutcomeFailed is an asset whose processing or verification failed, which
+	// a retry or regeneration may still fix
+	verifyOutcomeFailed = "failed"
+	// verifyOutcomePending is an asset still being uploaded, processed or logged; it
+	// is never cached
+	verifyOutcomePending = "pending"
+)
+
+// verifyCacheTTL returns how long a verify response with the given outcome is
+// reused: VERIFY_CACHE_TTL for logged assets, VERIFY_CACHE_FAILED_TTL for failed
+// ones, and 0 for pending ones, which are never cached
+func verifyCacheTTL(outcome string) time.Duration {
+	switch outcome {
+	case verifyOutcomeLogged:
+		return verifyCacheDurationEnv("VERIFY_CACHE_TTL", defaultVerifyCacheTTL)
+	case verifyOutcomeFailed:
+		return verifyCacheDurationEnv("VERIFY_CACHE_FAILED_TTL", defaultVerifyCacheFailedTTL)
+	default:
+		return 0
+	}
+}
+
+// verifyCacheDurationEnv returns a positive duration from the environment
+func verifyCacheDurationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Invalid %s %q, using default of %v", name, value, fallback)
+		return fallback
+	}
+	return ttl
+}
+
+// verifyCacheMaxEntries returns VERIFY_CACHE_MAX_ENTRIES; 0 turns the cache off
+func verifyCacheMaxEntries() int {
+	value := os.Getenv("VERIFY_CACHE_MAX_ENTRIES")
+	if value == "" {
+		return defaultVerifyCacheMaxEntries
+	}
+	maxEntries, err := strconv.Atoi(value)
+	if err != nil || maxEntries < 0 {
+		log.Printf("Invalid VERIFY_CACHE_MAX_ENTRIES %q, using default of %d", value, defaultVerifyCacheMaxEntries)
+		return defaultVerifyCacheMaxEntries
+	}
+	return maxEntries
+}
+
+// verifyCacheNow is the cache's clock; tests replace it to expire entries
+var verifyCacheNow = time.Now
+
+// cachedVerification is a complete verify response and the outcome it reports.
+// A logged response also records the size of the log root its proof and
+// confirmations were taken against.
+type cachedVerification struct {
+	outcome      string
+	code         int
+	body         []byte
+	certStatus   string
+	etag         string
+	modelVersion string
+	treeSize     uint64
+	expires      time.Time
+}
+
+// current reports whether a cached response still matches the log. Failed
+// responses do not depend on it; a logged one is reused only while the log's
+// latest signed root has the size it was built against, since its confirmations
+// grow with the tree.
+func (e cachedVerification) current(ctx context.Context) bool {
+	if e.outcome != verifyOutcomeLogged {
+		return true
+	}
+	logID, err := strconv.ParseInt(os.Getenv("TRILLIAN_LOG_ID"), 10, 64)
+	if err != nil {
+		return false
+	}
+	root, err := fetchLogRoot(ctx, logID)
+	if err != nil {
+		log.Printf("Failed to fetch log root to check a cached verification: %v", err)
+		return false
+	}
+	return root.TreeSize == e.treeSize
+}
+
+// verifyResponseCache keeps verify responses of public assets that are fully logged
+// or whose processing or verification failed, each for the TTL of its outcome.
+// Those responses only change when the asset is reprocessed, deleted or its
+// credential regenerated; pending and private assets are never stored. The cache
+// is local to each API instance, so only the instance that handled the change
+// drops its entry: the others keep serving the old response until it expires,
+// which is why the TTLs are kept short. Verification is eventually consistent
+// within VERIFY_CACHE_TTL.
+type verifyResponseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedVerification
+}
+
+// verifyCache is the verify handler's response cache
+var verifyCache = &verifyResponseCache{entries: map[string]cachedVerification{}}
+
+// get returns the unexpired response cached for an asset
+func (c *verifyResponseCache) get(assetID string) (cachedVerification, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[assetID]
+	if !ok {
+		return cachedVerification{}, false
+	}
+	if !verifyCacheNow().Before(entry.expires) {
+		delete(c.entries, assetID)
+		return cachedVerification{}, false
+	}
+	return entry, true
+}
+
+// put caches an asset's response for the TTL of its outcome; pending responses are
+// never cached. When the cache is full, expired entries are dropped first, then the
+// one closest to expiring.
+func (c *verifyResponseCache) put(assetID string, entry cachedVerification) {
+	maxEntries := verifyCacheMaxEntries()
+	ttl := verifyCacheTTL(entry.outcome)
+	if maxEntries == 0 || ttl <= 0 {
+		return
+	}
+	now := verifyCacheNow()
+	entry.expires = now.Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[assetID]; !exists && len(c.entries) >= maxEntries {
+		var oldestID string
+		var oldest time.Time
+		for id, cached := range c.entries {
+			if !now.Before(cached.expires) {
+				delete(c.entries, id)
+				continue
+			}
+			if oldestID == "" || cached.expires.Before(oldest) {
+				oldestID, oldest = id, cached.expires
+			}
+		}
+		if len(c.entries) >= maxEntries {
+			delete(c.entries, oldestID)
+		}
+	}
+	c.entries[assetID] = entry
+}
+
+// invalidate drops this instance's cached response of an asset that changed.
+// Other instances only drop theirs when it expires.
+func (c *verifyResponseCache) invalidate(assetID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, assetID)
+}
+
+// clear drops every cached response
+func (c *verifyResponseCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]cachedVerification{}
+}
+
+// respondCachedVerification writes a cached verify response with the headers it
+// was first served with, or 304 when the client already holds it
+func respondCachedVerification(w http.ResponseWriter, r *http.Request, entry cachedVerification) {
+	if entry.etag != "" {
+		w.Header().Set("ETag", entry.etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, entry.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if entry.certStatus != "" {
+		w.Header().Set("X-Certificate-Status", entry.certStatus)
+	}
+	if entry.modelVersion != "" {
+		w.Header().Set("X-Model-Version", entry.modelVersion)
+	}
+	w.WriteHeader(entry.code)
+	w.Write(entry.body)
+}
+
+// respondFailedVerification writes the verify response of an asset whose processing
+// or verification failed, caching it for VERIFY_CACHE_FAILED_TTL when the same
+// response would be served to every caller
+func respondFailedVerification(w http.ResponseWriter, assetID string, asset *Asset, cacheable bool, statusCode int, response Response) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(response); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to encode verification")
+		return
+	}
+	if cacheable && !asset.IsPrivate() {
+		verifyCache.put(assetID, cachedVerification{outcome: verifyOutcomeFailed, code: statusCode, body: body.Bytes()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body.Bytes())
+}
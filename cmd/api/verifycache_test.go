@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+// countingRepository counts the asset reads made through a fake repository
+type countingRepository struct {
+	*fakeRepository
+	gets int
+}
+
+func (r *countingRepository) GetAsset(ctx context.Context, assetID string) (*Asset, error) {
+	r.gets++
+	return r.fakeRepository.GetAsset(ctx, assetID)
+}
+
+func TestVerifyHandler_Cache(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("READ_ONLY", "")
+	t.Setenv("VERIFY_CACHE_TTL", "1m")
+
+	logged := &Asset{
+		ID:                "logged",
+		UserID:            "owner",
+		Status:            models.StatusCompleted,
+		CreatedAt:         time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore:  9,
+		TrillianLeafIndex: 3,
+	}
+	credential, err := certificate.Generate(logged)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	stored, _ := json.MarshalIndent(credential, "", "  ")
+	useFakeCertificates(t, map[string][]byte{"logged": stored})
+	useFakeLogRoot(t, 10)
+	fake := &fakeRepository{assets: map[string]*Asset{
+		"logged":  logged,
+		"pending": {ID: "pending", UserID: "owner", Status: models.StatusCompleted},
+		"partial": {ID: "partial", UserID: "owner", Status: models.StatusPartial, AnalysisFailed: true},
+	}}
+	useFakeRepository(t, fake)
+	counting := &countingRepository{fakeRepository: fake}
+	repo = counting
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	origNow := verifyCacheNow
+	verifyCacheNow = func() time.Time { return now }
+	t.Cleanup(func() { verifyCacheNow = origNow })
+
+	logCalls := 0
+	origProof, origLeaf := fetchInclusionProof, fetchLeafValue
+	t.Cleanup(func() { fetchInclusionProof, fetchLeafValue = origProof, origLeaf })
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		logCalls++
+		return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: leafIndex}}, nil
+	}
+	hashLeaf, _ := leaf.Value(leaf.FormatHash, stored)
+	fetchLeafValue = func(ctx context.Context, logID int64, leafIndex int64) ([]byte, error) {
+		logCalls++
+		return hashLeaf, nil
+	}
+
+	get := func(assetID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		serve(t, rec, httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+assetID, nil))
+		return rec
+	}
+
+	first := get("logged")
+	if first.Code != http.StatusOK || counting.gets != 1 || logCalls != 2 {
+		t.Fatalf("Expected a 200 read from the repository and the log, but got %d after %d reads and %d log calls", first.Code, counting.gets, logCalls)
+	}
+
+	// The second verification is served from the cache
+	second := get("logged")
+	if second.Code != http.StatusOK || second.Body.String() != first.Body.String() {
+		t.Fatalf("Expected the same 200 response, but got %d: %s", second.Code, second.Body.String())
+	}
+	if counting.gets != 1 || logCalls != 2 {
+		t.Errorf("Expected no repository or log calls for a cached response, but got %d reads and %d log calls in total", counting.gets, logCalls)
+	}
+	for _, header := range []string{"ETag", "X-Certificate-Status", "Content-Type"} {
+		if second.Header().Get(header) != first.Header().Get(header) {
+			t.Errorf("Expected header %s %q, but got %q", header, first.Header().Get(header), second.Header().Get(header))
+		}
+	}
+
+	// A grown tree adds confirmations, so the cached response is rebuilt against the new root
+	useFakeLogRoot(t, 12)
+	grown := get("logged")
+	if grown.Code != http.StatusOK || counting.gets != 2 || logCalls != 4 {
+		t.Fatalf("Expected a 200 rebuilt from the repository and the log, but got %d after %d reads and %d log calls in total", grown.Code, counting.gets, logCalls)
+	}
+	var body loggedVerification
+	if err := json.Unmarshal(grown.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Confirmations != 9 {
+		t.Errorf("Expected 9 confirmations, but got %d", body.Confirmations)
+	}
+	if grown.Header().Get("ETag") == first.Header().Get("ETag") {
+		t.Errorf("Expected a grown tree to change the ETag")
+	}
+
+	// Pending assets are never cached
+	get("pending")
+	get("pending")
+	if counting.gets != 4 {
+		t.Errorf("Expected every pending verification to read the repository, but got %d reads in total", counting.gets)
+	}
+
+	// Failed assets are cached with the status they were first served with
+	first = get("partial")
+	second = get("partial")
+	if counting.gets != 5 || second.Code != http.StatusAccepted || second.Body.String() != first.Body.String() {
+		t.Errorf("Expected the same 202 served from the cache, but got %d after %d reads in total: %s", second.Code, counting.gets, second.Body.String())
+	}
+
+	// Entries expire after VERIFY_CACHE_TTL
+	now = now.Add(2 * time.Minute)
+	get("logged")
+	if counting.gets != 6 {
+		t.Errorf("Expected an expired entry to be re-read, but got %d reads in total", counting.gets)
+	}
+
+	// Deleting the asset invalidates its entry
+	rec := httptest.NewRecorder()
+	serve(t, rec, withUser(httptest.NewRequest(http.MethodDelete, "/api/v1/assets/logged", nil), "owner"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the asset to be deleted, but got %d: %s", rec.Code, rec.Body.String())
+	}
+	if deleted := get("logged"); deleted.Code != http.StatusNotFound {
+		t.Errorf("Expected a deleted asset to return %d, but got %d", http.StatusNotFound, deleted.Code)
+	}
+}
+
+func TestVerifyResponseCache_Bounded(t *testing.T) {
+	t.Setenv("VERIFY_CACHE_MAX_ENTRIES", "2")
+	cache := &verifyResponseCache{entries: map[string]cachedVerification{}}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	origNow := verifyCacheNow
+	verifyCacheNow = func() time.Time { return now }
+	t.Cleanup(func() { verifyCacheNow = origNow })
+
+	for _, id := range []string{"a", "b", "c"} {
+		cache.put(id, cachedVerification{outcome: verifyOutcomeLogged, body: []byte(id)})
+		now = now.Add(time.Second)
+	}
+	if _, ok := cache.get("a"); ok {
+		t.Errorf("Expected the oldest entry to be evicted")
+	}
+	for _, id := range []string{"b", "c"} {
+		if _, ok := cache.get(id); !ok {
+			t.Errorf("Expected entry %s to be kept", id)
+		}
+	}
+
+	t.Setenv("VERIFY_CACHE_MAX_ENTRIES", "0")
+	cache.put("d", cachedVerification{outcome: verifyOutcomeLogged, body: []byte("d")})
+	if _, ok := cache.get("d"); ok {
+		t.Errorf("Expected nothing to be cached when VERIFY_CACHE_MAX_ENTRIES is 0")
+	}
+}
+
+func TestVerifyResponseCache_TTLPerOutcome(t *testing.T) {
+	t.Setenv("VERIFY_CACHE_MAX_ENTRIES", "")
+	t.Setenv("VERIFY_CACHE_TTL", "10m")
+	t.Setenv("VERIFY_CACHE_FAILED_TTL", "1m")
+	cache := &verifyResponseCache{entries: map[string]cachedVerification{}}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	origNow := verifyCacheNow
+	verifyCacheNow = func() time.Time { return now }
+	t.Cleanup(func() { verifyCacheNow = origNow })
+
+	cache.put("logged", cachedVerification{outcome: verifyOutcomeLogged, code: http.StatusOK})
+	cache.put("failed", cachedVerification{outcome: verifyOutcomeFailed, code: http.StatusConflict})
+	cache.put("pending", cachedVerification{outcome: verifyOutcomePending, code: http.StatusAccepted})
+
+	if _, ok := cache.get("pending"); ok {
+		t.Errorf("Expected a pending response never to be cached")
+	}
+	for _, id := range []string{"logged", "failed"} {
+		if _, ok := cache.get(id); !ok {
+			t.Errorf("Expected entry %s to be cached", id)
+		}
+	}
+
+	// The failed entry expires after VERIFY_CACHE_FAILED_TTL, the logged one lives on
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.get("failed"); ok {
+		t.Errorf("Expected the failed entry to expire after VERIFY_CACHE_FAILED_TTL")
+	}
+	if _, ok := cache.get("logged"); !ok {
+		t.Errorf("Expected the logged entry to outlive the failed one")
+	}
+
+	now = now.Add(10 * time.Minute)
+	if _, ok := cache.get("logged"); ok {
+		t.Errorf("Expected the logged entry to expire after VERIFY_CACHE_TTL")
+	}
+}
+
+func TestVerifyCacheTTL(t *testing.T) {
+	testCases := []struct {
+		name      string
+		outcome   string
+		ttl       string
+		failedTTL string
+		expected  time.Duration
+	}{
+		{name: "Logged default", outcome: verifyOutcomeLogged, expected: defaultVerifyCacheTTL},
+		{name: "Failed default", outcome: verifyOutcomeFailed, expected: defaultVerifyCacheFailedTTL},
+		{name: "Logged configured", outcome: verifyOutcomeLogged, ttl: "1h", expected: time.Hour},
+		{name: "Failed configured", outcome: verifyOutcomeFailed, failedTTL: "10s", expected: 10 * time.Second},
+		{name: "Invalid failed TTL", outcome: verifyOutcomeFailed, failedTTL: "-1s", expected: defaultVerifyCacheFailedTTL},
+		{name: "Pending is never cached", outcome: verifyOutcomePending, ttl: "1h", failedTTL: "1h", expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("VERIFY_CACHE_TTL", tc.ttl)
+			t.Setenv("VERIFY_CACHE_FAILED_TTL", tc.failedTTL)
+			if got := verifyCacheTTL(tc.outcome); got != tc.expected {
+				t.Errorf("Expected TTL %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+
+	"proofpix/internal/models"
+)
+
+// maxVerifyImageBytes bounds the image accepted by the verify-by-image endpoint
+const maxVerifyImageBytes = 32 << 20
+
+// errMissingImage is returned when a verify-by-image request carries no image
+var errMissingImage = errors.New("image is required")
+
+// handleVerifyImage handles POST /api/v1/verify/image. It hashes the uploaded image
+// and returns the verification status of every asset certifying the same bytes, so
+// anyone holding a copy of an image can check it without knowing its asset ID. The
+// image is sent as the raw request body or as the "image" field of a multipart form.
+// Each match carries a summary status; GET /api/v1/verify/{id} gives its full proof.
+func handleVerifyImage(w http.ResponseWriter, r *http.Request) {
+	imageData, err := readVerifyImage(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Image exceeds %d bytes", maxVerifyImageBytes))
+			return
+		}
+		if errors.Is(err, errMissingImage) {
+			respondValidationError(w, "Image is required", FieldError{Field: "image", Message: "is required"})
+			return
+		}
+		log.Printf("Failed to read image for verification: %v", err)
+		respondError(w, http.StatusBadRequest, "Failed to read image")
+		return
+	}
+
+	imageHash := fmt.Sprintf("%x", sha256.Sum256(imageData))
+	log.Printf("Verify image request received for image hash %s", imageHash)
+
+	ctx := r.Context()
+	assets, err := repo.FindAssetsByImageHash(ctx, imageHash)
+	if err != nil {
+		log.Printf("Failed to look up assets for image hash %s: %v", imageHash, err)
+		respondError(w, http.StatusInternalServerError, "Failed to look up assets")
+		return
+	}
+
+	// Oldest first, so the original certification of the image leads
+	sort.SliceStable(assets, func(i, j int) bool { return assets[i].CreatedAt.Before(assets[j].CreatedAt) })
+	matches := []map[string]interface{}{}
+	for _, asset := range assets {
+		// Soft-deleted assets are no longer publicly verifiable, and private ones
+		// only by their owner
+		if asset.IsDeleted() || !canVerifyAsset(r, asset) {
+			continue
+		}
+		matches = append(matches, imageMatchStatus(ctx, asset))
+	}
+
+	if len(matches) == 0 {
+		respondErrorCode(w, http.StatusNotFound, ErrCodeAssetNotFound, "No asset matches this image")
+		return
+	}
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: fmt.Sprintf("Found %d asset(s) matching this image", len(matches)),
+		Data: map[string]interface{}{
+			"image_hash": imageHash,
+			"matches":    matches,
+		},
+	})
+}
+
+// readVerifyImage reads the image from a multipart "image" field or, for any other
+// content type, from the raw request body
+func readVerifyImage(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxVerifyImageBytes)
+
+	body := io.Reader(r.Body)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			return nil, err
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil, errMissingImage
+			}
+			if err != nil {
+				return nil, err
+			}
+			if part.FormName() == "image" {
+				body = part
+				break
+			}
+		}
+	}
+
+	imageData, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(imageData) == 0 {
+		return nil, errMissingImage
+	}
+	return imageData, nil
+}
+
+// imageMatchStatus summarizes the verification status of an asset matching an
+// uploaded image, using the same states as the verify endpoint
+func imageMatchStatus(ctx context.Context, asset *Asset) map[string]interface{} {
+	match := map[string]interface{}{
+		"asset_id":   asset.ID,
+		"created_at": asset.CreatedAt,
+		"verify_url": "/api/v1/verify/" + asset.ID,
+		"logged":     false,
+	}
+	if asset.ModelVersion != "" {
+		match["model_version"] = asset.ModelVersion
+	}
+
+	switch {
+	case asset.IsPartial():
+		match["status"] = models.StatusPartial
+		return match
+	case asset.IsQuotaExceeded():
+		match["status"] = models.StatusQuotaExceeded
+		return match
+	}
+
+	certStatus, _, _ := checkCertificate(ctx, asset)
+	match["certificate_status"] = certStatus
+	switch {
+	case certStatus == certificateInconsistent:
+		match["status"] = "certificate_inconsistent"
+	case asset.TrillianLeafIndex != 0:
+		match["status"] = "logged"
+		match["logged"] = true
+		match["leaf_index"] = asset.TrillianLeafIndex
+	case asset.AnchoringSkipped() != "":
+		match["status"] = "not_anchored"
+		match["anchoring"] = asset.AnchoringSkipped()
+	default:
+		match["status"] = "pending_inclusion"
+	}
+	return match
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestHandleVerifyImage(t *testing.T) {
+	image := []byte("original image bytes")
+	imageHash := fmt.Sprintf("%x", sha256.Sum256(image))
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"copy":     {ID: "copy", UserID: "bob", Status: models.StatusCompleted, CreatedAt: day(3), ImageHash: imageHash},
+		"original": {ID: "original", UserID: "alice", Status: models.StatusCompleted, CreatedAt: day(1), ImageHash: imageHash, TrillianLeafIndex: 5},
+		"deleted":  {ID: "deleted", UserID: "carol", Status: models.StatusDeleted, CreatedAt: day(2), DeletedAt: day(4), ImageHash: imageHash},
+		"private":  {ID: "private", UserID: "dave", Status: models.StatusCompleted, CreatedAt: day(2), ImageHash: imageHash, Visibility: models.VisibilityPrivate},
+		"other":    {ID: "other", UserID: "alice", Status: models.StatusCompleted, CreatedAt: day(2), ImageHash: fmt.Sprintf("%x", sha256.Sum256([]byte("other")))},
+	}})
+	useFakeCertificates(t, map[string][]byte{})
+
+	multipartBody := func(field string, data []byte) (io.Reader, string) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		part, _ := writer.CreateFormFile(field, "photo.jpg")
+		part.Write(data)
+		writer.Close()
+		return &buf, writer.FormDataContentType()
+	}
+
+	testCases := []struct {
+		name             string
+		body             func() (io.Reader, string)
+		userID           string
+		expectedCode     int
+		expectedMatches  string
+		expectedStatuses string
+	}{
+		{
+			name:             "Raw body matching several assets",
+			body:             func() (io.Reader, string) { return bytes.NewReader(image), "image/jpeg" },
+			expectedCode:     http.StatusOK,
+			expectedMatches:  "original,copy",
+			expectedStatuses: "logged,pending_inclusion",
+		},
+		{
+			name:             "Multipart image field",
+			body:             func() (io.Reader, string) { return multipartBody("image", image) },
+			expectedCode:     http.StatusOK,
+			expectedMatches:  "original,copy",
+			expectedStatuses: "logged,pending_inclusion",
+		},
+		{
+			name:             "Private match with its owner",
+			body:             func() (io.Reader, string) { return bytes.NewReader(image), "image/jpeg" },
+			userID:           "dave",
+			expectedCode:     http.StatusOK,
+			expectedMatches:  "original,private,copy",
+			expectedStatuses: "logged,pending_inclusion,pending_inclusion",
+		},
+		{
+			name:             "Private match with another user",
+			body:             func() (io.Reader, string) { return bytes.NewReader(image), "image/jpeg" },
+			userID:           "bob",
+			expectedCode:     http.StatusOK,
+			expectedMatches:  "original,copy",
+			expectedStatuses: "logged,pending_inclusion",
+		},
+		{
+			name:         "Unknown image",
+			body:         func() (io.Reader, string) { return strings.NewReader("never uploaded"), "image/png" },
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Empty body",
+			body:         func() (io.Reader, string) { return strings.NewReader(""), "image/png" },
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Multipart without an image field",
+			body:         func() (io.Reader, string) { return multipartBody("file", image) },
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, contentType := tc.body()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/verify/image", body)
+			req.Header.Set("Content-Type", contentType)
+			if tc.userID != "" {
+				req = withUser(req, tc.userID)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data struct {
+					ImageHash string `json:"image_hash"`
+					Matches   []struct {
+						AssetID   string `json:"asset_id"`
+						Status    string `json:"status"`
+						Logged    bool   `json:"logged"`
+						LeafIndex int64  `json:"leaf_index"`
+					} `json:"matches"`
+				} `json:"data"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &response)
+			if response.Data.ImageHash != imageHash {
+				t.Errorf("Expected image hash %s, but got %s", imageHash, response.Data.ImageHash)
+			}
+			var ids, statuses []string
+			for _, match := range response.Data.Matches {
+				ids = append(ids, match.AssetID)
+				statuses = append(statuses, match.Status)
+			}
+			if got := strings.Join(ids, ","); got != tc.expectedMatches {
+				t.Errorf("Expected matches %q, but got %q", tc.expectedMatches, got)
+			}
+			if got := strings.Join(statuses, ","); got != tc.expectedStatuses {
+				t.Errorf("Expected statuses %q, but got %q", tc.expectedStatuses, got)
+			}
+			if first := response.Data.Matches[0]; !first.Logged || first.LeafIndex != 5 {
+				t.Errorf("Expected the original to be logged at leaf 5, but got logged=%t leaf=%d", first.Logged, first.LeafIndex)
+			}
+		})
+	}
+}
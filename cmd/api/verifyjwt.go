@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+)
+
+// contentTypeJWT is the media type of verify results signed as a JWT
+const contentTypeJWT = "application/jwt"
+
+// wantsVerificationJWT reports whether the Accept header asks for the verify result
+// as a signed JWT
+func wantsVerificationJWT(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), contentTypeJWT) {
+			continue
+		}
+		for _, param := range params[1:] {
+			if name, value, found := strings.Cut(strings.TrimSpace(param), "="); found && name == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// newVerificationResult starts the attested result for an asset in the given inclusion status
+func newVerificationResult(asset *Asset, status, certStatus string) *certificate.VerificationResult {
+	return &certificate.VerificationResult{
+		AssetID:           asset.ID,
+		Score:             asset.OriginalityScore,
+		Status:            status,
+		Logged:            status == "logged",
+		CertificateStatus: certStatus,
+		LeafIndex:         asset.TrillianLeafIndex,
+	}
+}
+
+// respondSignedVerification writes a verification result as a JWT signed with the
+// credential signing key
+func respondSignedVerification(w http.ResponseWriter, code int, result *certificate.VerificationResult) {
+	token, err := certificate.EncodeVerificationJWT(certificate.IssuerFromEnv(), result, credentialSigner, time.Now())
+	if err != nil {
+		log.Printf("Failed to sign verification result for asset %s: %v", result.AssetID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to sign verification result")
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJWT)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	w.Write([]byte(token))
+}
+
+// respondVerificationJWT verifies a logged asset's inclusion proof against the latest
+// log root and attests the outcome in a signed JWT. Unlike the JSON response, which
+// relays the log's proof, the proof is checked here before the server signs for it.
+func respondVerificationJWT(w http.ResponseWriter, ctx context.Context, logID int64, hasher leaf.Hasher, asset *Asset, certStatus string, certData []byte) {
+	if certData == nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Stored certificate is %s", certStatus))
+		return
+	}
+
+	root, err := fetchLogRoot(ctx, logID)
+	if err != nil {
+		log.Printf("Failed to fetch log root for asset %s: %v", asset.ID, err)
+		respondTrillianError(w, err, "Failed to retrieve log root")
+		return
+	}
+	if uint64(asset.TrillianLeafIndex) >= root.TreeSize {
+		respondSignedVerification(w, http.StatusAccepted, newVerificationResult(asset, "pending_inclusion", certStatus))
+		return
+	}
+
+	proofResponse, err := fetchInclusionProof(ctx, logID, asset.TrillianLeafIndex, int64(root.TreeSize))
+	if isNotYetIntegrated(err) {
+		respondSignedVerification(w, http.StatusAccepted, newVerificationResult(asset, "pending_inclusion", certStatus))
+		return
+	}
+	if err == nil && proofResponse.Proof == nil {
+		err = fmt.Errorf("log returned no inclusion proof")
+	}
+	if err != nil {
+		log.Printf("Failed to get inclusion proof for asset %s: %v", asset.ID, err)
+		respondTrillianError(w, err, "Failed to retrieve inclusion proof")
+		return
+	}
+	if err := checkBundleProof(hasher, asset, certData, root, proofResponse.Proof.Hashes); err != nil {
+		log.Printf("Inclusion proof for asset %s does not verify: %v", asset.ID, err)
+		respondError(w, http.StatusConflict, "Inclusion proof does not match the log root")
+		return
+	}
+
+	result := newVerificationResult(asset, "logged", certStatus)
+	result.TreeSize = root.TreeSize
+	result.RootHash = hex.EncodeToString(root.RootHash)
+	respondSignedVerification(w, http.StatusOK, result)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+func TestVerifyHandler_SignedJWT(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("CERTIFICATE_ISSUER_DID", "did:web:proofpix.com")
+	createdAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	logged := &Asset{ID: "logged", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 9, TrillianLeafIndex: 3, TrillianLeafFormat: leaf.FormatHash}
+	queued := &Asset{ID: "queued", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 7, TrillianLeafIndex: 9, TrillianLeafFormat: leaf.FormatHash}
+	unanchored := &Asset{ID: "unanchored", UserID: "owner", Status: models.StatusCompleted, CreatedAt: createdAt, OriginalityScore: 5, SkipAnchoring: true}
+	useFakeRepository(t, &fakeRepository{assets: map[string]*Asset{
+		"logged": logged, "queued": queued, "unanchored": unanchored,
+	}})
+
+	certificates := map[string][]byte{}
+	for _, asset := range []*Asset{logged, queued, unanchored} {
+		credential, err := certificate.Generate(asset)
+		if err != nil {
+			t.Fatalf("Failed to generate certificate: %v", err)
+		}
+		certificates[asset.ID], _ = json.MarshalIndent(credential, "", "  ")
+	}
+	useFakeCertificates(t, certificates)
+
+	publicKey, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	signer, err := certificate.NewSigningConfig(certificate.AlgorithmEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "did:web:proofpix.com#key-1")
+	if err != nil {
+		t.Fatalf("Failed to create signing config: %v", err)
+	}
+	origSigner := credentialSigner
+	t.Cleanup(func() { credentialSigner = origSigner })
+
+	// A four-leaf log with the logged certificate at index 3
+	storedLeaf, _ := leaf.Value(leaf.FormatHash, certificates["logged"])
+	h0, h1, h2, h3 := leaf.HashLeaf([]byte("a")), leaf.HashLeaf([]byte("b")), leaf.HashLeaf([]byte("c")), leaf.HashLeaf(storedLeaf)
+	left := leaf.HashChildren(h0, h1)
+	rootHash := leaf.HashChildren(left, leaf.HashChildren(h2, h3))
+
+	origProof, origRoot := fetchInclusionProof, fetchLogRoot
+	t.Cleanup(func() { fetchInclusionProof, fetchLogRoot = origProof, origRoot })
+	fetchLogRoot = func(ctx context.Context, logID int64) (*types.LogRootV1, error) {
+		return &types.LogRootV1{TreeSize: 4, RootHash: rootHash}, nil
+	}
+	proofHashes := [][]byte{h2, left}
+	fetchInclusionProof = func(ctx context.Context, logID int64, leafIndex int64, treeSize int64) (*trillian.GetInclusionProofResponse, error) {
+		return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: leafIndex, Hashes: proofHashes}}, nil
+	}
+
+	testCases := []struct {
+		name           string
+		assetID        string
+		noSigner       bool
+		forgedProof    bool
+		expectedCode   int
+		expectedStatus string
+	}{
+		{name: "Logged asset", assetID: "logged", expectedCode: http.StatusOK, expectedStatus: "logged"},
+		{name: "Leaf not yet integrated", assetID: "queued", expectedCode: http.StatusAccepted, expectedStatus: "pending_inclusion"},
+		{name: "Not anchored", assetID: "unanchored", expectedCode: http.StatusOK, expectedStatus: "not_anchored"},
+		{name: "Proof does not verify", assetID: "logged", forgedProof: true, expectedCode: http.StatusConflict},
+		{name: "No signing key", assetID: "logged", noSigner: true, expectedCode: http.StatusNotAcceptable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			credentialSigner = signer
+			if tc.noSigner {
+				credentialSigner = nil
+			}
+			proofHashes = [][]byte{h2, left}
+			if tc.forgedProof {
+				proofHashes = [][]byte{h2, h0}
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+tc.assetID, nil)
+			req.Header.Set("Accept", "application/jwt")
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedStatus == "" {
+				return
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != "application/jwt" {
+				t.Errorf("Expected Content-Type application/jwt, but got %q", contentType)
+			}
+
+			// The token verifies with the server's public key
+			parts := strings.Split(rec.Body.String(), ".")
+			if len(parts) != 3 {
+				t.Fatalf("Expected 3 JWT segments, but got %d", len(parts))
+			}
+			signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+			if err != nil || !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+				t.Fatalf("Expected the JWT to verify with the server public key (%v)", err)
+			}
+
+			var header struct {
+				Algorithm string `json:"alg"`
+				KeyID     string `json:"kid"`
+			}
+			var claims struct {
+				Issuer       string                         `json:"iss"`
+				Subject      string                         `json:"sub"`
+				IssuedAt     int64                          `json:"iat"`
+				Verification certificate.VerificationResult `json:"verification"`
+			}
+			for i, target := range []interface{}{&header, &claims} {
+				data, _ := base64.RawURLEncoding.DecodeString(parts[i])
+				if err := json.Unmarshal(data, target); err != nil {
+					t.Fatalf("Segment %d is not JSON: %v", i, err)
+				}
+			}
+			if header.Algorithm != "EdDSA" || header.KeyID != "did:web:proofpix.com#key-1" {
+				t.Errorf("Expected an EdDSA header naming the signing key, but got %+v", header)
+			}
+			if claims.Issuer != "did:web:proofpix.com" || claims.Subject != tc.assetID || claims.IssuedAt == 0 {
+				t.Errorf("Expected issuer, subject and issue time claims, but got %+v", claims)
+			}
+			result := claims.Verification
+			if result.AssetID != tc.assetID || result.Status != tc.expectedStatus || result.Logged != (tc.expectedStatus == "logged") {
+				t.Errorf("Expected %s to be %s, but got %+v", tc.assetID, tc.expectedStatus, result)
+			}
+			if tc.expectedStatus == "logged" {
+				if result.Score != 9 || result.LeafIndex != 3 || result.TreeSize != 4 || result.RootHash != hex.EncodeToString(rootHash) {
+					t.Errorf("Expected score 9 at leaf 3 under root %x of size 4, but got %+v", rootHash, result)
+				}
+			}
+		})
+	}
+}
+
+func TestWantsVerificationJWT(t *testing.T) {
+	testCases := []struct {
+		accept   string
+		expected bool
+	}{
+		{accept: "", expected: false},
+		{accept: "application/json", expected: false},
+		{accept: "application/jwt", expected: true},
+		{accept: "application/json, Application/JWT;q=0.5", expected: true},
+		{accept: "application/jwt;q=0", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/asset-1", nil)
+			req.Header.Set("Accept", tc.accept)
+			if got := wantsVerificationJWT(req); got != tc.expected {
+				t.Errorf("Expected %t for %q, but got %t", tc.expected, tc.accept, got)
+			}
+		})
+	}
+}
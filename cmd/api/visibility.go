@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+
+	"proofpix/internal/auth"
+)
+
+// canVerifyAsset reports whether the caller may verify the asset: anyone for a
+// public asset, and only its authenticated owner or an admin for a private one
+func canVerifyAsset(r *http.Request, asset *Asset) bool {
+	if !asset.IsPrivate() {
+		return true
+	}
+	if userID, ok := auth.GetUserID(r); ok && userID == asset.UserID {
+		return true
+	}
+	return isAdminRequest(r)
+}
+
+// verifyCacheControl keeps the verification of a private asset, which depends on
+// who asked, out of shared caches
+func verifyCacheControl(asset *Asset) string {
+	if asset.IsPrivate() {
+		return "private, no-cache"
+	}
+	return "no-cache"
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestVerifyHandler_Visibility(t *testing.T) {
+	useFakeRepository(t, &fakeRepository{
+		assets: map[string]*Asset{
+			"public":  {ID: "public", UserID: "owner", Status: models.StatusCompleted, SkipAnchoring: true, Visibility: models.VisibilityPublic},
+			"legacy":  {ID: "legacy", UserID: "owner", Status: models.StatusCompleted, SkipAnchoring: true},
+			"private": {ID: "private", UserID: "owner", Status: models.StatusCompleted, SkipAnchoring: true, Visibility: models.VisibilityPrivate},
+		},
+	})
+	useFakeCertificates(t, nil)
+
+	testCases := []struct {
+		name         string
+		assetID      string
+		request      func(r *http.Request) *http.Request
+		expectedCode int
+	}{
+		{name: "Public without auth", assetID: "public", expectedCode: http.StatusOK},
+		{name: "No visibility is public", assetID: "legacy", expectedCode: http.StatusOK},
+		{name: "Private with owner", assetID: "private", request: func(r *http.Request) *http.Request { return withUser(r, "owner") }, expectedCode: http.StatusOK},
+		{name: "Private with admin", assetID: "private", request: func(r *http.Request) *http.Request { return withAdmin(r, "admin") }, expectedCode: http.StatusOK},
+		{name: "Private without auth", assetID: "private", expectedCode: http.StatusNotFound},
+		{name: "Private with another user", assetID: "private", request: func(r *http.Request) *http.Request { return withUser(r, "someone-else") }, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+tc.assetID, nil)
+			if tc.request != nil {
+				req = tc.request(req)
+			}
+			rec := httptest.NewRecorder()
+			serve(t, rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode == http.StatusNotFound {
+				// Indistinguishable from an asset that does not exist
+				missing := httptest.NewRecorder()
+				serve(t, missing, httptest.NewRequest(http.MethodGet, "/api/v1/verify/missing", nil))
+				if rec.Body.String() != missing.Body.String() {
+					t.Errorf("Expected the same response as a missing asset, but got %s and %s", rec.Body.String(), missing.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyCacheControl(t *testing.T) {
+	if got := verifyCacheControl(&Asset{Visibility: models.VisibilityPrivate}); got != "private, no-cache" {
+		t.Errorf("Expected private, no-cache for a private asset, but got %q", got)
+	}
+	if got := verifyCacheControl(&Asset{}); got != "no-cache" {
+		t.Errorf("Expected no-cache for a public asset, but got %q", got)
+	}
+}
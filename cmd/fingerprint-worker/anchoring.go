@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// anchorMinScore returns ANCHOR_MIN_SCORE, the lowest originality score whose
+// certificate is queued in Trillian. Assets scoring below it are still certified
+// but not anchored, keeping low-confidence results out of the log. 0, the
+// default, anchors every asset.
+func anchorMinScore() int {
+	value := os.Getenv("ANCHOR_MIN_SCORE")
+	if value == "" {
+		return 0
+	}
+	score, err := strconv.Atoi(value)
+	if err != nil || score < 0 {
+		log.Printf("Invalid ANCHOR_MIN_SCORE %q, using default of 0", value)
+		return 0
+	}
+	return score
+}
+
+// belowAnchorMinScore reports whether an analyzed asset scored too low to be anchored.
+// Assets whose uploader opted out are not anchored either way.
+func belowAnchorMinScore(p *pipelineState) bool {
+	return !p.skipAnchoring && p.analysisErr == nil && p.score < anchorMinScore()
+}
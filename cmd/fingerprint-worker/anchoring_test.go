@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestProcessImage_SkipAnchoring(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+
+	testCases := []struct {
+		name           string
+		skipAnchoring  bool
+		expectedQueued bool
+	}{
+		{name: "Anchored by default", expectedQueued: true},
+		{name: "Anchoring skipped", skipAnchoring: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return nil
+			}
+			var certified, badged, queued bool
+			storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error {
+				certified = true
+				return nil
+			}
+			storeBadge = func(ctx context.Context, assetID string, data []byte) error {
+				badged = true
+				return nil
+			}
+			queueLeaf = func(ctx context.Context, logID int64, logServerAddr string, leafValue []byte) (int64, error) {
+				queued = true
+				return 7, nil
+			}
+			var stages []string
+			appendEvent = func(ctx context.Context, assetID string, event models.AssetEvent) error {
+				stages = append(stages, event.Stage)
+				return nil
+			}
+
+			processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket, SkipAnchoring: tc.skipAnchoring})
+
+			if saved == nil || saved.SkipAnchoring != tc.skipAnchoring {
+				t.Fatalf("Expected asset to record SkipAnchoring=%t, but got %+v", tc.skipAnchoring, saved)
+			}
+			if !certified || !badged {
+				t.Errorf("Expected certificate and badge to be generated, but got certificate=%t badge=%t", certified, badged)
+			}
+			if queued != tc.expectedQueued {
+				t.Errorf("Expected queued=%t, but got %t", tc.expectedQueued, queued)
+			}
+			if last := stages[len(stages)-1]; last != models.StageCompleted {
+				t.Errorf("Expected processing to complete, but last stage was %q", last)
+			}
+		})
+	}
+}
+
+func TestProcessImage_AnchorMinScore(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+
+	// The stubbed analysis scores every asset 95
+	testCases := []struct {
+		name          string
+		minScore      string
+		skipAnchoring bool
+		expectedBelow bool
+	}{
+		{name: "No minimum", minScore: ""},
+		{name: "At the minimum", minScore: "95"},
+		{name: "Below the minimum", minScore: "96", expectedBelow: true},
+		{name: "Skipped by request below the minimum", minScore: "96", skipAnchoring: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("ANCHOR_MIN_SCORE", tc.minScore)
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return nil
+			}
+			var certified, queued bool
+			storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error {
+				certified = true
+				return nil
+			}
+			queueLeaf = func(ctx context.Context, logID int64, logServerAddr string, leafValue []byte) (int64, error) {
+				queued = true
+				return 7, nil
+			}
+
+			_, last := processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket, SkipAnchoring: tc.skipAnchoring})
+			if last.err != nil {
+				t.Fatalf("Expected processing to complete, but stage %s failed: %v", last.stage, last.err)
+			}
+
+			if saved == nil || saved.OriginalityScore != 95 || saved.BelowAnchorMinScore != tc.expectedBelow {
+				t.Fatalf("Expected asset scored 95 to record BelowAnchorMinScore=%t, but got %+v", tc.expectedBelow, saved)
+			}
+			if !certified {
+				t.Error("Expected a certificate whatever the score")
+			}
+			if expectedQueued := !tc.expectedBelow && !tc.skipAnchoring; queued != expectedQueued {
+				t.Errorf("Expected queued=%t, but got %t", expectedQueued, queued)
+			}
+			expectedSkipped := ""
+			switch {
+			case tc.skipAnchoring:
+				expectedSkipped = models.AnchoringSkippedByChoice
+			case tc.expectedBelow:
+				expectedSkipped = models.AnchoringBelowMinScore
+			}
+			if got := saved.AnchoringSkipped(); got != expectedSkipped {
+				t.Errorf("Expected anchoring skipped %q, but got %q", expectedSkipped, got)
+			}
+		})
+	}
+}
+
+func TestAnchorMinScore(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected int
+	}{
+		{value: "", expected: 0},
+		{value: "70", expected: 70},
+		{value: "0", expected: 0},
+		{value: "-5", expected: 0},
+		{value: "high", expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv("ANCHOR_MIN_SCORE", tc.value)
+			if got := anchorMinScore(); got != tc.expected {
+				t.Errorf("Expected %d, but got %d", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"proofpix/internal/models"
+)
+
+// transitionStatus moves an asset from one status to another. Tests replace it
+// with a fake.
+var transitionStatus = transitionAssetStatus
+
+// markProcessing moves an asset the API created when issuing its upload URL from
+// awaiting_upload to processing, so verification can report that it is being
+// worked on. Assets uploaded without a pending document, and retries of assets
+// already further along, are left as they are. Failures are logged but never stop
+// processing; the save stage writes the final status regardless.
+func markProcessing(ctx context.Context, assetID string) {
+	moved, err := transitionStatus(ctx, assetID, models.StatusAwaitingUpload, models.StatusProcessing)
+	if err != nil {
+		log.Printf("Failed to mark asset %s as processing: %v", assetID, err)
+		return
+	}
+	if moved {
+		log.Printf("Asset %s is now processing", assetID)
+	}
+}
+
+// transitionAssetStatus sets the asset's status to "to" if it is currently "from",
+// in a transaction so a concurrent save is never overwritten. It reports whether
+// the status changed; a missing document is left missing.
+func transitionAssetStatus(ctx context.Context, assetID, from, to string) (bool, error) {
+	// Get project ID from environment
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return false, fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+
+	// Initialize Firestore client
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return false, fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	docRef := client.Collection("assets").Doc(assetID)
+	var moved bool
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		moved = false
+		docSnap, err := tx.Get(docRef)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if current, _ := docSnap.Data()["status"].(string); current != from {
+			return nil
+		}
+		moved = true
+		return tx.Update(docRef, []firestore.Update{{Path: "status", Value: to}})
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to update asset status: %v", err)
+	}
+	return moved, nil
+}
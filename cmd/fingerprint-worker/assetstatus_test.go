@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestProcessImage_StatusTransitions(t *testing.T) {
+	stubServices(t)
+
+	// statuses stands in for the asset documents' status fields
+	statuses := map[string]string{}
+	transitionStatus = func(ctx context.Context, assetID, from, to string) (bool, error) {
+		if assetID == "unreachable" {
+			return false, errors.New("firestore unavailable")
+		}
+		current, exists := statuses[assetID]
+		if !exists || current != from {
+			return false, nil
+		}
+		statuses[assetID] = to
+		return true, nil
+	}
+	var statusAtDownload string
+	fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+		statusAtDownload = statuses[assetID]
+		if assetID == "broken" {
+			return nil, errors.New("object not found")
+		}
+		return testImageData, nil
+	}
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		statuses[asset.ID] = asset.Status
+		return nil
+	}
+
+	testCases := []struct {
+		name             string
+		assetID          string
+		initial          string
+		expectedAtStart  string
+		expectedAtFinish string
+	}{
+		{
+			name:             "Pending upload is processed to completion",
+			assetID:          "pending",
+			initial:          models.StatusAwaitingUpload,
+			expectedAtStart:  models.StatusProcessing,
+			expectedAtFinish: models.StatusCompleted,
+		},
+		{
+			name:             "Failed processing stays processing",
+			assetID:          "broken",
+			initial:          models.StatusAwaitingUpload,
+			expectedAtStart:  models.StatusProcessing,
+			expectedAtFinish: models.StatusProcessing,
+		},
+		{
+			name:             "Upload without a pending document",
+			assetID:          "legacy",
+			expectedAtFinish: models.StatusCompleted,
+		},
+		{
+			name:             "Retry of a partial asset keeps its status until saved",
+			assetID:          "partial",
+			initial:          models.StatusPartial,
+			expectedAtStart:  models.StatusPartial,
+			expectedAtFinish: models.StatusCompleted,
+		},
+		{
+			name:             "Status update failure does not stop processing",
+			assetID:          "unreachable",
+			expectedAtFinish: models.StatusCompleted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.initial != "" {
+				statuses[tc.assetID] = tc.initial
+			}
+			processImage("user-1", tc.assetID, processOptions{Bucket: defaultUploadBucket})
+
+			if statusAtDownload != tc.expectedAtStart {
+				t.Errorf("Expected status %q while downloading, but got %q", tc.expectedAtStart, statusAtDownload)
+			}
+			if statuses[tc.assetID] != tc.expectedAtFinish {
+				t.Errorf("Expected final status %q, but got %q", tc.expectedAtFinish, statuses[tc.assetID])
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"proofpix/internal/bodylimit"
+)
+
+// limitRequestBody bounds every request body at MAX_REQUEST_BODY_BYTES. A declared
+// length over the limit is rejected with 413 before the handler runs; a body that
+// turns out longer fails its read, which handlers report with bodylimit.IsTooLarge.
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := bodylimit.MaxBytesFromEnv()
+		if r.ContentLength > limit {
+			http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
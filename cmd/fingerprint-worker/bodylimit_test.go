@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "64")
+	oversized := `{"user_id":"user-1","asset_id":"` + strings.Repeat("a", 100) + `"}`
+
+	testCases := []struct {
+		name          string
+		declareLength bool
+	}{
+		{name: "Declared length", declareLength: true},
+		{name: "Without a declared length", declareLength: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/process", io.NopCloser(strings.NewReader(oversized)))
+			if tc.declareLength {
+				req.ContentLength = int64(len(oversized))
+			} else {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			limitRequestBody(http.HandlerFunc(processHandler)).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("Expected status %d, but got %d: %s", http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
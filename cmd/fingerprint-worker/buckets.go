@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultUploadBucket is the bucket uploads are read from when a request names none
+const defaultUploadBucket = "proofpix-assets-upload"
+
+// errBucketNotAllowed is returned for a requested bucket missing from the allowlist
+var errBucketNotAllowed = errors.New("bucket not allowed")
+
+// allowedUploadBuckets returns the buckets the worker may read uploads from: the
+// default bucket plus any listed in the comma-separated ALLOWED_UPLOAD_BUCKETS
+func allowedUploadBuckets() map[string]bool {
+	allowed := map[string]bool{defaultUploadBucket: true}
+	for _, bucket := range strings.Split(os.Getenv("ALLOWED_UPLOAD_BUCKETS"), ",") {
+		if bucket = strings.TrimSpace(bucket); bucket != "" {
+			allowed[bucket] = true
+		}
+	}
+	return allowed
+}
+
+// resolveUploadBucket returns the bucket to read an upload from. An empty request
+// means the default bucket; anything else must be on the allowlist so callers
+// cannot point the worker at arbitrary buckets.
+func resolveUploadBucket(requested string) (string, error) {
+	if requested == "" {
+		return defaultUploadBucket, nil
+	}
+	if !allowedUploadBuckets()[requested] {
+		return "", fmt.Errorf("%w: %q", errBucketNotAllowed, requested)
+	}
+	return requested, nil
+}
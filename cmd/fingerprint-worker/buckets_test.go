@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestProcessHandler_BucketAllowlist(t *testing.T) {
+	t.Setenv("ALLOWED_UPLOAD_BUCKETS", "tenant-a-uploads, tenant-b-uploads")
+
+	testCases := []struct {
+		name           string
+		bucketField    string
+		expectedCode   int
+		expectedBucket string
+	}{
+		{name: "No bucket uses default", expectedCode: http.StatusOK, expectedBucket: defaultUploadBucket},
+		{name: "Allowlisted bucket", bucketField: `,"bucket":"tenant-b-uploads"`, expectedCode: http.StatusOK, expectedBucket: "tenant-b-uploads"},
+		{name: "Unknown bucket", bucketField: `,"bucket":"attacker-bucket"`, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			fetched := make(chan string, 1)
+			done := make(chan struct{})
+			fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+				fetched <- bucket
+				return nil, fmt.Errorf("stop after download")
+			}
+			appendEvent = func(ctx context.Context, assetID string, event models.AssetEvent) error {
+				if event.Stage == models.StageFailed {
+					close(done)
+				}
+				return nil
+			}
+
+			body := `{"user_id":"user-1","asset_id":"asset-1"` + tc.bucketField + `}`
+			req := httptest.NewRequest(http.MethodPost, "/process", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			processHandler(rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedBucket == "" {
+				select {
+				case bucket := <-fetched:
+					t.Errorf("Expected no download for a rejected bucket, but read from %q", bucket)
+				default:
+				}
+				return
+			}
+
+			select {
+			case bucket := <-fetched:
+				if bucket != tc.expectedBucket {
+					t.Errorf("Expected download from bucket %q, but got %q", tc.expectedBucket, bucket)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Expected the image to be downloaded")
+			}
+			<-done
+		})
+	}
+}
+
+func TestResolveUploadBucket(t *testing.T) {
+	t.Setenv("ALLOWED_UPLOAD_BUCKETS", "")
+
+	if bucket, err := resolveUploadBucket(""); err != nil || bucket != defaultUploadBucket {
+		t.Errorf("Expected default bucket %q, but got %q (%v)", defaultUploadBucket, bucket, err)
+	}
+	if bucket, err := resolveUploadBucket(defaultUploadBucket); err != nil || bucket != defaultUploadBucket {
+		t.Errorf("Expected default bucket to be allowed, but got %q (%v)", bucket, err)
+	}
+	if _, err := resolveUploadBucket("tenant-a-uploads"); err == nil {
+		t.Errorf("Expected an error for a bucket missing from the allowlist, but got nil")
+	}
+}
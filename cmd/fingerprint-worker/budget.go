@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"proofpix/internal/models"
+)
+
+// analysisUsageCollection holds one document per user and month counting Vertex calls
+const analysisUsageCollection = "analysis_usage"
+
+// Vertex usage accounting. It is a package variable so tests can track usage in memory.
+var reserveUsage = reserveAnalysisUsage
+
+// monthlyAnalysisBudget returns ANALYSIS_MONTHLY_BUDGET, the number of Vertex calls
+// (analysis or embedding) each user may make per calendar month. 0 means unlimited.
+func monthlyAnalysisBudget() int {
+	value := os.Getenv("ANALYSIS_MONTHLY_BUDGET")
+	if value == "" {
+		return 0
+	}
+	budget, err := strconv.Atoi(value)
+	if err != nil || budget < 0 {
+		log.Printf("Invalid ANALYSIS_MONTHLY_BUDGET %q, using default of unlimited", value)
+		return 0
+	}
+	return budget
+}
+
+// usageMonth returns the UTC calendar month usage is accounted in, e.g. "2026-10"
+func usageMonth(now time.Time) string {
+	return now.UTC().Format("2006-01")
+}
+
+// budgetStage charges the Vertex calls still needed for the asset against the
+// owner's monthly budget. Once the budget is exhausted a new asset is saved as
+// quota_exceeded and processing stops; a partial asset keeps its earlier results.
+// Accounting errors are logged and never block processing.
+func budgetStage(ctx context.Context, p *pipelineState) error {
+	budget := monthlyAnalysisBudget()
+	if budget == 0 {
+		return nil
+	}
+
+	calls := 0
+	if !p.analysisReused {
+		calls++
+	}
+	if !p.embeddingReused {
+		calls++
+	}
+	// Each keyframe of a video is analyzed and embedded on its own
+	if p.isVideo() {
+		calls *= len(p.frames)
+	}
+	if calls == 0 {
+		return nil
+	}
+
+	used, allowed, err := reserveUsage(ctx, p.userID, usageMonth(time.Now()), calls, budget)
+	if err != nil {
+		log.Printf("Failed to check analysis budget for user %s, continuing: %v", p.userID, err)
+		return nil
+	}
+	if allowed {
+		return nil
+	}
+
+	budgetErr := fmt.Errorf("monthly analysis budget exhausted: %d of %d calls used", used, budget)
+	if p.previous != nil {
+		return budgetErr
+	}
+
+	asset := &Asset{
+		ID:         p.assetID,
+		UserID:     p.userID,
+		Status:     models.StatusQuotaExceeded,
+		CreatedAt:  time.Now(),
+		Visibility: assetVisibility(p.visibility),
+	}
+	if err := storeAsset(ctx, asset); err != nil {
+		recordEvent(ctx, p.assetID, models.StageSaved, err)
+		return fmt.Errorf("%v; failed to save asset to Firestore: %v", budgetErr, err)
+	}
+	recordEvent(ctx, p.assetID, models.StageSaved, nil)
+	return budgetErr
+}
+
+// reserveAnalysisUsage adds calls to the user's usage for the month if that stays
+// within budget. It returns the usage after the attempt and whether it was allowed.
+func reserveAnalysisUsage(ctx context.Context, userID, month string, calls, budget int) (int, bool, error) {
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return 0, false, fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	docRef := client.Collection(analysisUsageCollection).Doc(userID + "_" + month)
+	var used int
+	var allowed bool
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		used, allowed = 0, false
+		docSnap, err := tx.Get(docRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if value, ok := docSnap.Data()["calls"].(int64); ok {
+				used = int(value)
+			}
+		}
+		if used+calls > budget {
+			return nil
+		}
+		used += calls
+		allowed = true
+		return tx.Set(docRef, map[string]interface{}{
+			"user_id":    userID,
+			"month":      month,
+			"calls":      used,
+			"updated_at": time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to update analysis usage: %v", err)
+	}
+	return used, allowed, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestProcessImage_BudgetExceededBlocksAnalysis(t *testing.T) {
+	t.Setenv("ANALYSIS_MONTHLY_BUDGET", "2")
+	stubServices(t)
+
+	// Track usage in memory the way the Firestore transaction does
+	usage := map[string]int{}
+	reserveUsage = func(ctx context.Context, userID, month string, calls, budget int) (int, bool, error) {
+		key := userID + "_" + month
+		if usage[key]+calls > budget {
+			return usage[key], false, nil
+		}
+		usage[key] += calls
+		return usage[key], true, nil
+	}
+
+	var analyzeCalls int32
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		atomic.AddInt32(&analyzeCalls, 1)
+		return "Confidence Score: 0.95\n\nJustification: Natural lighting.", "gemini-1.5-flash", nil
+	}
+	saved := map[string]*Asset{}
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved[asset.ID] = asset
+		return nil
+	}
+	var lastEvent models.AssetEvent
+	appendEvent = func(ctx context.Context, assetID string, event models.AssetEvent) error {
+		lastEvent = event
+		return nil
+	}
+
+	// The first upload uses both calls of the budget
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+	if saved["asset-1"] == nil || saved["asset-1"].Status != models.StatusCompleted {
+		t.Fatalf("Expected first asset to complete, but got %+v", saved["asset-1"])
+	}
+
+	// The second upload exceeds it
+	processImage("user-1", "asset-2", processOptions{Bucket: defaultUploadBucket})
+
+	if analyzeCalls != 1 {
+		t.Errorf("Expected analysis to run only for the first asset, but got %d calls", analyzeCalls)
+	}
+	blocked := saved["asset-2"]
+	if blocked == nil {
+		t.Fatalf("Expected the blocked asset to be saved")
+	}
+	if blocked.Status != models.StatusQuotaExceeded {
+		t.Errorf("Expected status %q, but got %q", models.StatusQuotaExceeded, blocked.Status)
+	}
+	if lastEvent.Stage != models.StageFailed || lastEvent.Success {
+		t.Errorf("Expected a failed event, but got %+v", lastEvent)
+	}
+
+	// Another user has their own budget
+	processImage("user-2", "asset-3", processOptions{Bucket: defaultUploadBucket})
+	if saved["asset-3"] == nil || saved["asset-3"].Status != models.StatusCompleted {
+		t.Errorf("Expected another user's asset to complete, but got %+v", saved["asset-3"])
+	}
+}
+
+func TestBudgetStage_AccountingErrorDoesNotBlock(t *testing.T) {
+	t.Setenv("ANALYSIS_MONTHLY_BUDGET", "1")
+	stubServices(t)
+	reserveUsage = func(ctx context.Context, userID, month string, calls, budget int) (int, bool, error) {
+		return 0, false, fmt.Errorf("firestore unavailable")
+	}
+
+	if err := budgetStage(context.Background(), &pipelineState{userID: "user-1", assetID: "asset-1"}); err != nil {
+		t.Errorf("Expected processing to continue, but got %v", err)
+	}
+}
+
+func TestUsageMonth(t *testing.T) {
+	now := time.Date(2026, 10, 31, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	if got := usageMonth(now); got != "2026-11" {
+		t.Errorf("Expected usage month 2026-11, but got %s", got)
+	}
+}
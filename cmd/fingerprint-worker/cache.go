@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// analysisCacheCollection is the Firestore collection holding cached analysis results
+const analysisCacheCollection = "analysis_cache"
+
+// CachedAnalysis holds the Vertex results for an image, keyed by its SHA-256 content hash
+type CachedAnalysis struct {
+	RawAnalysis      string `firestore:"raw_analysis"`
+	OriginalityScore int    `firestore:"originality_score"`
+	Narrative        string `firestore:"narrative"`
+	// Narrative language, recorded when NARRATIVE_LANGUAGE is tag or translate
+	NarrativeLanguage       string    `firestore:"narrative_language,omitempty"`
+	NarrativeOriginal       string    `firestore:"narrative_original,omitempty"`
+	NarrativeTranslatedFrom string    `firestore:"narrative_translated_from,omitempty"`
+	Embedding               []float32 `firestore:"embedding"`
+	ModelVersion            string    `firestore:"model_version,omitempty"`
+	EmbeddingModel          string    `firestore:"embedding_model,omitempty"`
+	AnalysisWarning         string    `firestore:"analysis_warning,omitempty"`
+	CachedAt                time.Time `firestore:"cached_at"`
+}
+
+// AnalysisCache stores analysis results so identical images are not sent to Vertex twice
+type AnalysisCache interface {
+	Get(ctx context.Context, imageHash string) (*CachedAnalysis, bool, error)
+	Put(ctx context.Context, imageHash string, result *CachedAnalysis) error
+}
+
+// newAnalysisCache returns the cache selected by ANALYSIS_CACHE:
+// "firestore", "memory", or "" / "none" to disable caching
+func newAnalysisCache(kind string) (AnalysisCache, error) {
+	switch kind {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		return newMemoryAnalysisCache(), nil
+	case "firestore":
+		projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+		if projectID == "" {
+			return nil, fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+		}
+		return &firestoreAnalysisCache{projectID: projectID}, nil
+	default:
+		return nil, fmt.Errorf("unknown ANALYSIS_CACHE %q: expected firestore, memory or none", kind)
+	}
+}
+
+// lookupCachedAnalysis returns the cached result for an image hash, or nil on a miss.
+// Cache errors are logged and treated as a miss so processing is never blocked.
+// Embeddings of different models are not comparable, so an entry embedded with any
+// model but the primary one in EMBEDDING_MODELS is a miss, and is replaced once the
+// image is processed again.
+func lookupCachedAnalysis(ctx context.Context, imageHash string) *CachedAnalysis {
+	if analysisCache == nil {
+		return nil
+	}
+	result, found, err := analysisCache.Get(ctx, imageHash)
+	if err != nil {
+		log.Printf("Failed to read analysis cache for image hash %s: %v", imageHash, err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+	if primary := embeddingModels()[0]; result.EmbeddingModel != primary {
+		log.Printf("Ignoring cached analysis for image hash %s embedded with %q instead of %s", imageHash, result.EmbeddingModel, primary)
+		return nil
+	}
+	return result
+}
+
+// storeCachedAnalysis saves a result to the cache, logging any failure
+func storeCachedAnalysis(ctx context.Context, imageHash string, result *CachedAnalysis) {
+	if analysisCache == nil {
+		return
+	}
+	result.CachedAt = time.Now().UTC()
+	if err := analysisCache.Put(ctx, imageHash, result); err != nil {
+		log.Printf("Failed to write analysis cache for image hash %s: %v", imageHash, err)
+	}
+}
+
+// memoryAnalysisCache is a process-local cache, useful for single instances and tests
+type memoryAnalysisCache struct {
+	mu      sync.RWMutex
+	entries map[string]CachedAnalysis
+}
+
+func newMemoryAnalysisCache() *memoryAnalysisCache {
+	return &memoryAnalysisCache{entries: make(map[string]CachedAnalysis)}
+}
+
+func (c *memoryAnalysisCache) Get(ctx context.Context, imageHash string) (*CachedAnalysis, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, found := c.entries[imageHash]
+	if !found {
+		return nil, false, nil
+	}
+	return &entry, true, nil
+}
+
+func (c *memoryAnalysisCache) Put(ctx context.Context, imageHash string, result *CachedAnalysis) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[imageHash] = *result
+	return nil
+}
+
+// firestoreAnalysisCache shares cached results between worker instances
+type firestoreAnalysisCache struct {
+	projectID string
+}
+
+func (c *firestoreAnalysisCache) Get(ctx context.Context, imageHash string) (*CachedAnalysis, bool, error) {
+	client, err := firestore.NewClient(ctx, c.projectID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	docSnap, err := client.Collection(analysisCacheCollection).Doc(imageHash).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	var result CachedAnalysis
+	if err := docSnap.DataTo(&result); err != nil {
+		return nil, false, fmt.Errorf("failed to parse cached analysis: %v", err)
+	}
+	return &result, true, nil
+}
+
+func (c *firestoreAnalysisCache) Put(ctx context.Context, imageHash string, result *CachedAnalysis) error {
+	client, err := firestore.NewClient(ctx, c.projectID)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	_, err = client.Collection(analysisCacheCollection).Doc(imageHash).Set(ctx, result)
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProcessImage_CachedAnalysisSkipsVertex(t *testing.T) {
+	stubServices(t)
+
+	origCache := analysisCache
+	analysisCache = newMemoryAnalysisCache()
+	defer func() { analysisCache = origCache }()
+
+	// Count calls to the (fake) Vertex services
+	var analyzeCalls, embedCalls int32
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		atomic.AddInt32(&analyzeCalls, 1)
+		return "Confidence Score: 0.95\n\nJustification: Natural lighting.", "gemini-1.5-flash", nil
+	}
+	embedImage = func(imageData []byte, model string) ([]float32, error) {
+		atomic.AddInt32(&embedCalls, 1)
+		return []float32{0.1, 0.2, 0.3}, nil
+	}
+
+	// Capture the assets that get saved
+	var saved []*Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = append(saved, asset)
+		return nil
+	}
+
+	// Both uploads have identical content
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+	processImage("user-2", "asset-2", processOptions{Bucket: defaultUploadBucket})
+
+	if analyzeCalls != 1 {
+		t.Errorf("Expected analysis to be called once, but got %d calls", analyzeCalls)
+	}
+	if embedCalls != 1 {
+		t.Errorf("Expected embedding to be called once, but got %d calls", embedCalls)
+	}
+
+	if len(saved) != 2 {
+		t.Fatalf("Expected 2 saved assets, but got %d", len(saved))
+	}
+	if saved[1].OriginalityScore != 95 || saved[1].Narrative != "Natural lighting." {
+		t.Errorf("Expected cached score and narrative on second asset, but got %d / %q", saved[1].OriginalityScore, saved[1].Narrative)
+	}
+	if len(saved[1].Embedding) != 3 {
+		t.Errorf("Expected cached embedding on second asset, but got %v", saved[1].Embedding)
+	}
+}
+
+func TestLookupCachedAnalysis_EmbeddingModel(t *testing.T) {
+	t.Setenv("EMBEDDING_MODELS", "multimodalembedding@002, multimodalembedding@001")
+	origCache := analysisCache
+	analysisCache = newMemoryAnalysisCache()
+	defer func() { analysisCache = origCache }()
+
+	testCases := []struct {
+		name     string
+		model    string
+		expected bool
+	}{
+		{name: "Primary model", model: "multimodalembedding@002", expected: true},
+		{name: "Fallback model", model: "multimodalembedding@001", expected: false},
+		{name: "Entry cached before the model was recorded", model: "", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analysisCache.Put(context.Background(), "hash", &CachedAnalysis{Embedding: []float32{0.1}, EmbeddingModel: tc.model})
+
+			if found := lookupCachedAnalysis(context.Background(), "hash") != nil; found != tc.expected {
+				t.Errorf("Expected a cache hit to be %t, but got %t", tc.expected, found)
+			}
+		})
+	}
+}
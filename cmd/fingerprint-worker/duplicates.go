@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"proofpix/internal/index"
+	"proofpix/internal/models"
+)
+
+// searchSimilar runs the similarity search for a newly indexed asset. It is a
+// package variable so tests can observe the search.
+var searchSimilar = searchIndex
+
+// searchIndex searches the live index, ranked by RankOptionsFromEnv, for the k
+// nearest assets other than excludeID
+func searchIndex(vector []float32, k int, excludeID string) ([]float32, []string, error) {
+	return globalIndexManager.SearchRanked(vector, k, index.RankOptionsFromEnv(), excludeID)
+}
+
+// duplicateSearchK returns DUPLICATE_SEARCH_K, the neighbours the worker searches
+// for when it indexes a new asset, to log them and detect near duplicates. It
+// defaults to SEARCH_DEFAULT_K and is clamped to SEARCH_MAX_K; 0 skips the search.
+func duplicateSearchK() int {
+	value := os.Getenv("DUPLICATE_SEARCH_K")
+	if value == "" {
+		return index.DefaultK()
+	}
+	k, err := strconv.Atoi(value)
+	if err != nil || k < 0 {
+		log.Printf("Invalid DUPLICATE_SEARCH_K %q, using default of %d", value, index.DefaultK())
+		return index.DefaultK()
+	}
+	if k == 0 {
+		return 0
+	}
+	return index.ClampK(k)
+}
+
+// defaultDuplicateDistance is the largest search distance at which an existing
+// asset counts as a likely source of the new image, overridable with
+// DUPLICATE_DISTANCE_THRESHOLD. For unit-length embeddings it is roughly a
+// cosine similarity of 0.95.
+const defaultDuplicateDistance = 0.1
+
+// duplicateDistanceThreshold returns DUPLICATE_DISTANCE_THRESHOLD; 0 disables
+// duplicate detection
+func duplicateDistanceThreshold() float32 {
+	value := os.Getenv("DUPLICATE_DISTANCE_THRESHOLD")
+	if value == "" {
+		return defaultDuplicateDistance
+	}
+	threshold, err := strconv.ParseFloat(value, 32)
+	if err != nil || threshold < 0 {
+		log.Printf("Invalid DUPLICATE_DISTANCE_THRESHOLD %q, using default of %v", value, defaultDuplicateDistance)
+		return defaultDuplicateDistance
+	}
+	return float32(threshold)
+}
+
+// nearDuplicate returns the closest search result when it is within the duplicate
+// threshold, or nil. Results may be re-ranked, so the smallest distance is used
+// rather than the first result.
+func nearDuplicate(distances []float32, assetIDs []string) *models.RelatedAsset {
+	threshold := duplicateDistanceThreshold()
+	if threshold == 0 {
+		return nil
+	}
+	var nearest *models.RelatedAsset
+	for i, assetID := range assetIDs {
+		if distances[i] > threshold {
+			continue
+		}
+		if nearest == nil || distances[i] < nearest.Distance {
+			nearest = &models.RelatedAsset{AssetID: assetID, Distance: distances[i]}
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNearDuplicate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold string
+		distances []float32
+		assetIDs  []string
+		expected  string
+	}{
+		{name: "Nearest within threshold", distances: []float32{0.01, 0.05}, assetIDs: []string{"asset-a", "asset-b"}, expected: "asset-a"},
+		{name: "Re-ranked results use the smallest distance", distances: []float32{0.05, 0.01}, assetIDs: []string{"asset-a", "asset-b"}, expected: "asset-b"},
+		{name: "Nothing within threshold", distances: []float32{0.4, 0.9}, assetIDs: []string{"asset-a", "asset-b"}},
+		{name: "No results", distances: nil, assetIDs: nil},
+		{name: "Custom threshold", threshold: "0.5", distances: []float32{0.4}, assetIDs: []string{"asset-a"}, expected: "asset-a"},
+		{name: "Zero disables detection", threshold: "0", distances: []float32{0}, assetIDs: []string{"asset-a"}},
+		{name: "Invalid threshold uses default", threshold: "close", distances: []float32{0.01}, assetIDs: []string{"asset-a"}, expected: "asset-a"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("DUPLICATE_DISTANCE_THRESHOLD", tc.threshold)
+			related := nearDuplicate(tc.distances, tc.assetIDs)
+			if tc.expected == "" {
+				if related != nil {
+					t.Errorf("Expected no related asset, but got %+v", related)
+				}
+				return
+			}
+			if related == nil || related.AssetID != tc.expected {
+				t.Errorf("Expected related asset %q, but got %+v", tc.expected, related)
+			}
+		})
+	}
+}
+
+func TestRelatedAssetInCredential(t *testing.T) {
+	testCases := []struct {
+		name      string
+		distances []float32
+		present   bool
+	}{
+		{name: "Near duplicate", distances: []float32{0.02}, present: true},
+		{name: "Distinct image", distances: []float32{0.8}, present: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return nil
+			}
+
+			p := &pipelineState{assetID: "asset-2", userID: "user-1", score: 80}
+			p.relatedAsset = nearDuplicate(tc.distances, []string{"asset-1"})
+			if err := saveStage(context.Background(), p); err != nil {
+				t.Fatalf("saveStage failed: %v", err)
+			}
+			if (saved.RelatedAsset != nil) != tc.present {
+				t.Errorf("Expected a related asset on the saved asset to be %t, but got %+v", tc.present, saved.RelatedAsset)
+			}
+			if err := certifyStage(context.Background(), p); err != nil {
+				t.Fatalf("certifyStage failed: %v", err)
+			}
+			if got := strings.Contains(string(p.certificateJSON), `"relatedAsset"`); got != tc.present {
+				t.Errorf("Expected relatedAsset in the credential to be %t, but got %s", tc.present, p.certificateJSON)
+			}
+			if tc.present && !strings.Contains(string(p.certificateJSON), "urn:proofpix:asset:asset-1") {
+				t.Errorf("Expected the credential to name asset-1, but got %s", p.certificateJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"proofpix/internal/index"
+	"proofpix/internal/models"
+)
+
+// defaultEmbeddingModel is the Vertex model used when EMBEDDING_MODELS is not set
+const defaultEmbeddingModel = "multimodalembedding@001"
+
+// embeddingModels returns the ordered models in EMBEDDING_MODELS, a comma-separated
+// list whose first entry is the primary model and the rest are fallbacks
+func embeddingModels() []string {
+	var models []string
+	for _, model := range strings.Split(os.Getenv("EMBEDDING_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 {
+		return []string{defaultEmbeddingModel}
+	}
+	return models
+}
+
+// embeddingPrecision returns EMBEDDING_PRECISION, the precision embeddings are
+// stored at on assets. Unset, they are stored as float32.
+func embeddingPrecision() string {
+	value := os.Getenv("EMBEDDING_PRECISION")
+	switch precision := strings.ToLower(strings.TrimSpace(value)); precision {
+	case "":
+		return models.EmbeddingPrecisionFloat32
+	case models.EmbeddingPrecisionFloat32, models.EmbeddingPrecisionFloat16:
+		return precision
+	}
+	log.Printf("Invalid EMBEDDING_PRECISION %q, using default of %s", value, models.EmbeddingPrecisionFloat32)
+	return models.EmbeddingPrecisionFloat32
+}
+
+// embedWithFallback embeds the image with each configured model in turn until one
+// succeeds, returning the embedding and the model that produced it. The index holds
+// vectors of the primary model's dimension, so a fallback returning any other
+// dimension is rejected rather than mixed into the index.
+func embedWithFallback(imageData []byte) ([]float32, string, error) {
+	var errs []error
+	for i, model := range embeddingModels() {
+		embedding, err := embedImage(imageData, model)
+		if err == nil && i > 0 && len(embedding) != index.EmbeddingDimension {
+			err = fmt.Errorf("returned %d dimensions, but the index holds %d", len(embedding), index.EmbeddingDimension)
+		}
+		if err != nil {
+			log.Printf("Embedding model %s failed: %v", model, err)
+			errs = append(errs, fmt.Errorf("%s: %v", model, err))
+			continue
+		}
+		if i > 0 {
+			log.Printf("Embedding generated by fallback model %s", model)
+		}
+		return embedding, model, nil
+	}
+	return nil, "", fmt.Errorf("all embedding models failed: %v", errors.Join(errs...))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"proofpix/internal/index"
+)
+
+func TestEmbeddingModels(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected []string
+	}{
+		{value: "", expected: []string{defaultEmbeddingModel}},
+		{value: " , ", expected: []string{defaultEmbeddingModel}},
+		{value: "multimodalembedding@001, multimodalembedding@002", expected: []string{"multimodalembedding@001", "multimodalembedding@002"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv("EMBEDDING_MODELS", tc.value)
+			if models := embeddingModels(); !reflect.DeepEqual(models, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, models)
+			}
+		})
+	}
+}
+
+func TestProcessImage_FallbackEmbeddingModel(t *testing.T) {
+	compatible := make([]float32, index.EmbeddingDimension)
+	compatible[0] = 1
+
+	testCases := []struct {
+		name          string
+		responses     map[string][]float32
+		expectedModel string
+		expectedCalls []string
+	}{
+		{
+			name:          "Primary succeeds",
+			responses:     map[string][]float32{"primary": {0.1, 0.2}, "fallback": compatible},
+			expectedModel: "primary",
+			expectedCalls: []string{"primary"},
+		},
+		{
+			name:          "Primary fails and the fallback succeeds",
+			responses:     map[string][]float32{"fallback": compatible},
+			expectedModel: "fallback",
+			expectedCalls: []string{"primary", "fallback"},
+		},
+		{
+			name:          "Fallback with another dimension is rejected",
+			responses:     map[string][]float32{"fallback": {0.1, 0.2}, "last-resort": compatible},
+			expectedModel: "last-resort",
+			expectedCalls: []string{"primary", "fallback", "last-resort"},
+		},
+		{
+			name:          "Every model fails",
+			responses:     map[string][]float32{},
+			expectedCalls: []string{"primary", "fallback", "last-resort"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("EMBEDDING_MODELS", "primary,fallback,last-resort")
+
+			var calls []string
+			embedImage = func(imageData []byte, model string) ([]float32, error) {
+				calls = append(calls, model)
+				embedding, ok := tc.responses[model]
+				if !ok {
+					return nil, errors.New("model unavailable")
+				}
+				return embedding, nil
+			}
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return nil
+			}
+
+			processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+
+			if !reflect.DeepEqual(calls, tc.expectedCalls) {
+				t.Errorf("Expected models %v to be tried, but got %v", tc.expectedCalls, calls)
+			}
+			if saved == nil {
+				t.Fatalf("Expected the asset to be saved")
+			}
+			if saved.EmbeddingModel != tc.expectedModel {
+				t.Errorf("Expected embedding model %q, but got %q", tc.expectedModel, saved.EmbeddingModel)
+			}
+			if saved.EmbeddingFailed != (tc.expectedModel == "") {
+				t.Errorf("Expected embedding failed=%t, but got %t", tc.expectedModel == "", saved.EmbeddingFailed)
+			}
+			if tc.expectedModel != "" && len(saved.Embedding) != len(tc.responses[tc.expectedModel]) {
+				t.Errorf("Expected the %s embedding to be saved, but got %d dimensions", tc.expectedModel, len(saved.Embedding))
+			}
+		})
+	}
+}
+
+func TestProcessImage_EmbeddingPrecision(t *testing.T) {
+	testCases := []struct {
+		value         string
+		expectFloat16 bool
+	}{
+		{value: ""},
+		{value: "float32"},
+		{value: "float16", expectFloat16: true},
+		{value: " FLOAT16 ", expectFloat16: true},
+		{value: "int4"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("EMBEDDING_PRECISION", tc.value)
+			embedding := []float32{0.6, -0.8, 0}
+			embedImage = func(imageData []byte, model string) ([]float32, error) { return embedding, nil }
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return nil
+			}
+
+			processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+
+			if saved == nil {
+				t.Fatalf("Expected the asset to be saved")
+			}
+			if tc.expectFloat16 != (saved.Embedding == nil) || tc.expectFloat16 != (saved.EmbeddingFloat16 != nil) {
+				t.Errorf("Expected float16 storage=%t, but got %v / %v", tc.expectFloat16, saved.Embedding, saved.EmbeddingFloat16)
+			}
+			stored := saved.EmbeddingVector()
+			if len(stored) != len(embedding) {
+				t.Fatalf("Expected %d stored dimensions, but got %v", len(embedding), stored)
+			}
+			for i := range embedding {
+				if diff := stored[i] - embedding[i]; diff > 1e-3 || diff < -1e-3 {
+					t.Errorf("Expected stored value %d near %v, but got %v", i, embedding[i], stored[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"proofpix/internal/models"
+)
+
+// recordEvent appends a stage event to the asset's audit trail.
+// Failures to record are logged but never interrupt processing.
+func recordEvent(ctx context.Context, assetID, stage string, stageErr error) {
+	event := models.AssetEvent{
+		Stage:     stage,
+		Success:   stageErr == nil,
+		Timestamp: time.Now().UTC(),
+	}
+	if stageErr != nil {
+		event.Detail = stageErr.Error()
+	}
+
+	if err := appendEvent(ctx, assetID, event); err != nil {
+		log.Printf("Failed to record %s event for asset %s: %v", stage, assetID, err)
+	}
+}
+
+// saveAssetEvent writes an event to the assets/{assetID}/events subcollection in Firestore
+func saveAssetEvent(ctx context.Context, assetID string, event models.AssetEvent) error {
+	// Get project ID from environment
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+
+	// Initialize Firestore client
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	_, _, err = client.Collection("assets").Doc(assetID).Collection("events").Add(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to save event to Firestore: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"proofpix/internal/index"
+	"proofpix/internal/models"
+)
+
+func TestProcessImage_RecordsStageEvents(t *testing.T) {
+	// Trillian must be configured for the "logged" stage to run
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+
+	// Replace every external service with a fake that succeeds
+	var events []models.AssetEvent
+	stubServices(t)
+	appendEvent = func(ctx context.Context, assetID string, event models.AssetEvent) error {
+		events = append(events, event)
+		return nil
+	}
+
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+
+	expectedStages := []string{
+		models.StageDownloaded,
+		models.StageAnalyzed,
+		models.StageEmbedded,
+		models.StageSaved,
+		models.StageCertified,
+		models.StageLogged,
+		models.StageCompleted,
+	}
+	if len(events) != len(expectedStages) {
+		t.Fatalf("Expected %d events, but got %d: %+v", len(expectedStages), len(events), events)
+	}
+	for i, stage := range expectedStages {
+		if events[i].Stage != stage {
+			t.Errorf("Expected event %d to be %q, but got %q", i, stage, events[i].Stage)
+		}
+		if !events[i].Success {
+			t.Errorf("Expected event %q to succeed, but got detail %q", events[i].Stage, events[i].Detail)
+		}
+		if events[i].Timestamp.IsZero() {
+			t.Errorf("Expected event %q to have a timestamp", events[i].Stage)
+		}
+	}
+}
+
+// testImageData is the download returned by stubServices: a JPEG header followed by filler
+var testImageData = []byte("\xff\xd8\xff\xe0image-bytes")
+
+// stubServices replaces the external service calls used by processImage with
+// successful fakes and restores the originals when the test finishes.
+func stubServices(t *testing.T) {
+	t.Helper()
+
+	origIndex, origHealth := globalIndexManager, health
+	origFetch, origAnalyze, origEmbed := fetchImage, analyzeImage, embedImage
+	origLoad, origAsset, origCert, origBadge := loadAsset, storeAsset, storeCertificate, storeBadge
+	origQueue, origLeafIndex, origEvent := queueLeaf, storeLeafIndex, appendEvent
+	origReserve, origPending := reserveUsage, pendingSaves
+	origBatch, origQueueBatch := leafBatch, queueLeafBatch
+	origInclusion := fetchLogInclusion
+	origFetchURL, origUpload := fetchImageURL, storeUpload
+	origThumbnail, origShortCode := storeThumbnail, reserveShortCode
+	origTranslate, origSearch := translateNarrative, searchSimilar
+	origPublish, origTransition := publishIndexDelta, transitionStatus
+	origExtract, origMarkUpload := extractKeyframes, markUploadFailed
+	origRemoval, origReadVector := publishIndexRemoval, readIndexVector
+	origListDeleted, origPurge := listDeletedAssets, purgeDeletedAsset
+	t.Cleanup(func() {
+		globalIndexManager, health = origIndex, origHealth
+		fetchImage, analyzeImage, embedImage = origFetch, origAnalyze, origEmbed
+		loadAsset, storeAsset, storeCertificate, storeBadge = origLoad, origAsset, origCert, origBadge
+		queueLeaf, storeLeafIndex, appendEvent = origQueue, origLeafIndex, origEvent
+		reserveUsage, pendingSaves = origReserve, origPending
+		leafBatch, queueLeafBatch = origBatch, origQueueBatch
+		fetchLogInclusion = origInclusion
+		fetchImageURL, storeUpload = origFetchURL, origUpload
+		storeThumbnail, reserveShortCode = origThumbnail, origShortCode
+		translateNarrative, searchSimilar = origTranslate, origSearch
+		publishIndexDelta, transitionStatus = origPublish, origTransition
+		extractKeyframes, markUploadFailed = origExtract, origMarkUpload
+		publishIndexRemoval, readIndexVector = origRemoval, origReadVector
+		listDeletedAssets, purgeDeletedAsset = origListDeleted, origPurge
+	})
+	pendingSaves = &saveQueue{}
+	leafBatch = nil
+	fetchLogInclusion = func(ctx context.Context, logID int64, leafIndex int64) (*logInclusion, error) {
+		return nil, errLeafNotIntegrated
+	}
+
+	globalIndexManager = &index.IndexManager{}
+	health = &workerHealth{searchReady: true}
+	fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+		return testImageData, nil
+	}
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		return "Confidence Score: 0.95\n\nJustification: Natural lighting.", "gemini-1.5-flash", nil
+	}
+	embedImage = func(imageData []byte, model string) ([]float32, error) {
+		return []float32{0.1, 0.2, 0.3}, nil
+	}
+	storeUpload = func(ctx context.Context, bucket, userID, assetID string, imageData []byte, mimeType string) error {
+		return nil
+	}
+	storeThumbnail = func(ctx context.Context, assetID string, data []byte) error { return nil }
+	publishIndexDelta = func(assetID string, vector []float32) {}
+	publishIndexRemoval = func(assetID string) {}
+	transitionStatus = func(ctx context.Context, assetID, from, to string) (bool, error) { return false, nil }
+	reserveShortCode = func(ctx context.Context, code, assetID string) (bool, error) { return true, nil }
+	translateNarrative = func(narrative, language string) (string, error) {
+		return "", errors.New("translation not stubbed")
+	}
+	extractKeyframes = func(ctx context.Context, video []byte, opts keyframeOptions) ([]keyframe, error) {
+		return nil, errors.New("keyframe extraction not stubbed")
+	}
+	loadAsset = func(ctx context.Context, assetID string) (*Asset, error) { return nil, nil }
+	storeAsset = func(ctx context.Context, asset *Asset) error { return nil }
+	storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error { return nil }
+	storeBadge = func(ctx context.Context, assetID string, data []byte) error { return nil }
+	markUploadFailed = func(ctx context.Context, assetID, field string) error { return nil }
+	queueLeaf = func(ctx context.Context, logID int64, logServerAddr string, leafValue []byte) (int64, error) {
+		return 7, nil
+	}
+	queueLeafBatch = func(ctx context.Context, logID int64, logServerAddr string, leafValues [][]byte) ([]int64, []error) {
+		leafIndexes := make([]int64, len(leafValues))
+		for i := range leafIndexes {
+			leafIndexes[i] = int64(7 + i)
+		}
+		return leafIndexes, make([]error, len(leafValues))
+	}
+	storeLeafIndex = func(ctx context.Context, assetID string, leafIndex int64, leafFormat string) error { return nil }
+	appendEvent = func(ctx context.Context, assetID string, event models.AssetEvent) error { return nil }
+	reserveUsage = func(ctx context.Context, userID, month string, calls, budget int) (int, bool, error) {
+		return calls, true, nil
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultFirestoreWriteAttempts is how many times a Firestore write is tried
+// before it is given up, overridable with FIRESTORE_WRITE_MAX_ATTEMPTS
+const defaultFirestoreWriteAttempts = 4
+
+// Backoff between Firestore write attempts: the delay starts at
+// firestoreRetryBaseDelay and doubles up to firestoreRetryMaxDelay. Tests shorten them.
+var (
+	firestoreRetryBaseDelay = 250 * time.Millisecond
+	firestoreRetryMaxDelay  = 4 * time.Second
+)
+
+// firestoreWriteAttempts returns FIRESTORE_WRITE_MAX_ATTEMPTS
+func firestoreWriteAttempts() int {
+	value := os.Getenv("FIRESTORE_WRITE_MAX_ATTEMPTS")
+	if value == "" {
+		return defaultFirestoreWriteAttempts
+	}
+	attempts, err := strconv.Atoi(value)
+	if err != nil || attempts <= 0 {
+		log.Printf("Invalid FIRESTORE_WRITE_MAX_ATTEMPTS %q, using default of %d", value, defaultFirestoreWriteAttempts)
+		return defaultFirestoreWriteAttempts
+	}
+	return attempts
+}
+
+// isRetryableFirestoreError reports whether a failed write may succeed if repeated
+func isRetryableFirestoreError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted, codes.Internal:
+		return true
+	}
+	return false
+}
+
+// retryFirestoreWrite runs write until it succeeds, fails with a non-retryable
+// error, or FIRESTORE_WRITE_MAX_ATTEMPTS is reached, backing off between attempts
+func retryFirestoreWrite(ctx context.Context, description string, write func() error) error {
+	attempts := firestoreWriteAttempts()
+	delay := firestoreRetryBaseDelay
+
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil || !isRetryableFirestoreError(err) || attempt == attempts {
+			return err
+		}
+		log.Printf("Firestore %s failed (attempt %d of %d), retrying in %v: %v", description, attempt, attempts, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > firestoreRetryMaxDelay {
+			delay = firestoreRetryMaxDelay
+		}
+	}
+}
+
+// saveQueue holds the pipelines of assets whose save failed even after retrying,
+// so their Vertex results are not lost. Retrying resumes each pipeline at the save
+// stage. The queue lives in memory: assets still queued when the instance stops
+// must be reprocessed, which the analysis cache makes cheap.
+type saveQueue struct {
+	mu      sync.Mutex
+	pending map[string]*pipelineState
+}
+
+// pendingSaves is the worker's queue of assets awaiting a repeated save
+var pendingSaves = &saveQueue{}
+
+// add queues a pipeline, replacing any earlier one for the same asset
+func (q *saveQueue) add(p *pipelineState) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[string]*pipelineState)
+	}
+	q.pending[p.assetID] = p
+}
+
+// len returns the number of queued assets
+func (q *saveQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// retry resumes every queued pipeline from the save stage. Assets whose save
+// fails again are queued again by saveStage.
+func (q *saveQueue) retry(ctx context.Context) (saved, remaining int) {
+	q.mu.Lock()
+	queued := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+
+	for assetID, p := range queued {
+		log.Printf("Retrying queued save for asset %s", assetID)
+		runPipeline(ctx, p, stagesFrom("save"))
+		if p.asset != nil {
+			saved++
+		}
+	}
+	return saved, q.len()
+}
+
+// retrySavesHandler repeats the saves of queued assets. Like /reap it is meant to
+// be called on a schedule.
+func retrySavesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	saved, remaining := pendingSaves.retry(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int{
+		"saved":     saved,
+		"remaining": remaining,
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fastFirestoreRetries removes the backoff delay for the duration of the test
+func fastFirestoreRetries(t *testing.T) {
+	t.Helper()
+	origBase, origMax := firestoreRetryBaseDelay, firestoreRetryMaxDelay
+	firestoreRetryBaseDelay, firestoreRetryMaxDelay = time.Millisecond, time.Millisecond
+	t.Cleanup(func() { firestoreRetryBaseDelay, firestoreRetryMaxDelay = origBase, origMax })
+}
+
+func TestRetryFirestoreWrite(t *testing.T) {
+	fastFirestoreRetries(t)
+	t.Setenv("FIRESTORE_WRITE_MAX_ATTEMPTS", "3")
+
+	unavailable := status.Error(codes.Unavailable, "firestore unavailable")
+	testCases := []struct {
+		name             string
+		failures         []error
+		expectError      bool
+		expectedAttempts int
+	}{
+		{name: "Succeeds first time", expectedAttempts: 1},
+		{name: "Unavailable then succeeds", failures: []error{unavailable, unavailable}, expectedAttempts: 3},
+		{name: "Gives up after max attempts", failures: []error{unavailable, unavailable, unavailable, unavailable}, expectError: true, expectedAttempts: 3},
+		{name: "Non-retryable error", failures: []error{status.Error(codes.PermissionDenied, "denied")}, expectError: true, expectedAttempts: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			// A fake Firestore write that fails with the listed errors, then succeeds
+			err := retryFirestoreWrite(context.Background(), "test write", func() error {
+				attempts++
+				if attempts <= len(tc.failures) {
+					return tc.failures[attempts-1]
+				}
+				return nil
+			})
+			if tc.expectError != (err != nil) {
+				t.Errorf("Expected error=%t, but got %v", tc.expectError, err)
+			}
+			if attempts != tc.expectedAttempts {
+				t.Errorf("Expected %d attempts, but got %d", tc.expectedAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestSaveStage_QueuesFailedSaveForRetry(t *testing.T) {
+	stubServices(t)
+	firestoreDown := true
+	var saved []*Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		if firestoreDown {
+			return status.Error(codes.Unavailable, "firestore unavailable")
+		}
+		saved = append(saved, asset)
+		return nil
+	}
+	var certified bool
+	storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error {
+		certified = true
+		return nil
+	}
+
+	p := &pipelineState{userID: "user-1", assetID: "asset-1", score: 90, narrative: "Lighting and shadows look natural.", embedding: []float32{0.1}}
+	if err := saveStage(context.Background(), p); err == nil {
+		t.Fatalf("Expected the save to fail while Firestore is down")
+	}
+	if pendingSaves.len() != 1 {
+		t.Fatalf("Expected the asset to be queued for retry, but %d are queued", pendingSaves.len())
+	}
+
+	// Still down: the asset stays queued
+	if savedCount, remaining := pendingSaves.retry(context.Background()); savedCount != 0 || remaining != 1 {
+		t.Errorf("Expected the asset to stay queued, but got saved=%d remaining=%d", savedCount, remaining)
+	}
+
+	firestoreDown = false
+	savedCount, remaining := pendingSaves.retry(context.Background())
+	if savedCount != 1 || remaining != 0 {
+		t.Errorf("Expected the queued asset to be saved, but got saved=%d remaining=%d", savedCount, remaining)
+	}
+	if len(saved) != 1 || saved[0].OriginalityScore != 90 {
+		t.Errorf("Expected the original results to be saved, but got %+v", saved)
+	}
+	if !certified {
+		t.Errorf("Expected the resumed pipeline to certify the asset")
+	}
+}
+
+func TestIsRetryableFirestoreError(t *testing.T) {
+	if !isRetryableFirestoreError(status.Error(codes.DeadlineExceeded, "slow")) {
+		t.Errorf("Expected DeadlineExceeded to be retryable")
+	}
+	if isRetryableFirestoreError(errors.New("plain error")) {
+		t.Errorf("Expected a non-gRPC error not to be retryable")
+	}
+}
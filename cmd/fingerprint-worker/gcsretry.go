@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+)
+
+// defaultGCSUploadAttempts is how many times a certificate or badge upload is tried
+// before it is given up, overridable with GCS_UPLOAD_MAX_ATTEMPTS
+const defaultGCSUploadAttempts = 4
+
+// Backoff between GCS upload attempts: the delay starts at gcsRetryBaseDelay and
+// doubles up to gcsRetryMaxDelay. Tests shorten them.
+var (
+	gcsRetryBaseDelay = 250 * time.Millisecond
+	gcsRetryMaxDelay  = 4 * time.Second
+)
+
+// isRetryableGCSError reports whether a failed upload may succeed if repeated. It
+// is a package variable so tests can classify their fake errors.
+var isRetryableGCSError = storage.ShouldRetry
+
+// Fields set on an asset when its certificate or badge could not be uploaded
+const (
+	certificateUploadFailedField = "certificate_upload_failed"
+	badgeUploadFailedField       = "badge_upload_failed"
+)
+
+// markUploadFailed records on an asset that one of its uploads failed for good. It
+// is a package variable so tests can substitute a fake.
+var markUploadFailed = setUploadFailed
+
+// gcsUploadAttempts returns GCS_UPLOAD_MAX_ATTEMPTS
+func gcsUploadAttempts() int {
+	value := os.Getenv("GCS_UPLOAD_MAX_ATTEMPTS")
+	if value == "" {
+		return defaultGCSUploadAttempts
+	}
+	attempts, err := strconv.Atoi(value)
+	if err != nil || attempts <= 0 {
+		log.Printf("Invalid GCS_UPLOAD_MAX_ATTEMPTS %q, using default of %d", value, defaultGCSUploadAttempts)
+		return defaultGCSUploadAttempts
+	}
+	return attempts
+}
+
+// objectWriter is the part of *storage.Writer an upload uses
+type objectWriter interface {
+	Write(p []byte) (int, error)
+	Close() error
+}
+
+// writeObject uploads data through a writer from open, opening a fresh writer for
+// each attempt since a failed GCS writer cannot be reused. It retries until the
+// upload succeeds, fails with a non-retryable error, or GCS_UPLOAD_MAX_ATTEMPTS is
+// reached, backing off between attempts.
+func writeObject(ctx context.Context, description string, open func() objectWriter, data []byte) error {
+	attempts := gcsUploadAttempts()
+	delay := gcsRetryBaseDelay
+
+	for attempt := 1; ; attempt++ {
+		err := writeOnce(open(), data)
+		if err == nil || !isRetryableGCSError(err) || attempt == attempts {
+			return err
+		}
+		log.Printf("GCS upload of %s failed (attempt %d of %d), retrying in %v: %v", description, attempt, attempts, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > gcsRetryMaxDelay {
+			delay = gcsRetryMaxDelay
+		}
+	}
+}
+
+// writeOnce writes data and closes the writer, which finalizes the upload. GCS
+// reports most failures from Close, so its error is returned unwrapped for
+// isRetryableGCSError.
+func writeOnce(writer objectWriter, data []byte) error {
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// setUploadFailed sets field on the asset's Firestore document
+func setUploadFailed(ctx context.Context, assetID, field string) error {
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	return retryFirestoreWrite(ctx, "update of "+field+" on asset "+assetID, func() error {
+		_, err := client.Collection("assets").Doc(assetID).Update(ctx, []firestore.Update{{Path: field, Value: true}})
+		return err
+	})
+}
+
+// recordUploadFailure flags an asset whose certificate or badge upload failed for
+// good, logging rather than returning an error since the pipeline carries on
+func recordUploadFailure(ctx context.Context, assetID, field string) {
+	if err := markUploadFailed(ctx, assetID, field); err != nil {
+		log.Printf("Failed to record %s on asset %s: %v", field, assetID, err)
+		return
+	}
+	log.Printf("Recorded %s on asset %s for a later backfill", field, assetID)
+}
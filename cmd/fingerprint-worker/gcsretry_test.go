@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// fakeObjectWriter buffers an upload and fails its Close with err, as GCS reports
+// most upload failures when the writer is finalized
+type fakeObjectWriter struct {
+	buf    bytes.Buffer
+	err    error
+	closed bool
+}
+
+func (w *fakeObjectWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *fakeObjectWriter) Close() error {
+	w.closed = true
+	return w.err
+}
+
+func TestWriteObject(t *testing.T) {
+	origBase, origMax := gcsRetryBaseDelay, gcsRetryMaxDelay
+	gcsRetryBaseDelay, gcsRetryMaxDelay = time.Millisecond, time.Millisecond
+	t.Cleanup(func() { gcsRetryBaseDelay, gcsRetryMaxDelay = origBase, origMax })
+	t.Setenv("GCS_UPLOAD_MAX_ATTEMPTS", "3")
+
+	unavailable := &googleapi.Error{Code: 503, Message: "backend unavailable"}
+	testCases := []struct {
+		name             string
+		failures         []error
+		expectError      bool
+		expectedAttempts int
+	}{
+		{name: "Succeeds first time", expectedAttempts: 1},
+		{name: "Unavailable then succeeds", failures: []error{unavailable, unavailable}, expectedAttempts: 3},
+		{name: "Gives up after max attempts", failures: []error{unavailable, unavailable, unavailable, unavailable}, expectError: true, expectedAttempts: 3},
+		{name: "Non-retryable error", failures: []error{&googleapi.Error{Code: 403, Message: "forbidden"}}, expectError: true, expectedAttempts: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Each attempt gets a fresh fake writer failing with the next listed error
+			var writers []*fakeObjectWriter
+			err := writeObject(context.Background(), "test object", func() objectWriter {
+				writer := &fakeObjectWriter{}
+				if len(writers) < len(tc.failures) {
+					writer.err = tc.failures[len(writers)]
+				}
+				writers = append(writers, writer)
+				return writer
+			}, []byte("badge-bytes"))
+
+			if tc.expectError != (err != nil) {
+				t.Errorf("Expected error=%t, but got %v", tc.expectError, err)
+			}
+			if len(writers) != tc.expectedAttempts {
+				t.Fatalf("Expected %d attempts, but got %d", tc.expectedAttempts, len(writers))
+			}
+			for i, writer := range writers {
+				if writer.buf.String() != "badge-bytes" || !writer.closed {
+					t.Errorf("Expected attempt %d to write the whole object and close its writer, but got %q (closed %t)", i+1, writer.buf.String(), writer.closed)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadFailuresAreRecorded(t *testing.T) {
+	stubServices(t)
+	uploadErr := errors.New("upload failed after retries")
+	storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error { return uploadErr }
+	storeBadge = func(ctx context.Context, assetID string, data []byte) error { return uploadErr }
+	var marked []string
+	markUploadFailed = func(ctx context.Context, assetID, field string) error {
+		marked = append(marked, assetID+":"+field)
+		return nil
+	}
+
+	// The certificate upload fails, so the badge stage has nothing to render
+	p := &pipelineState{assetID: "asset-1", asset: &Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 90, CreatedAt: time.Now()}}
+	if err := certifyStage(context.Background(), p); err != nil {
+		t.Fatalf("Expected the pipeline to carry on, but got %v", err)
+	}
+	if !p.asset.CertificateUploadFailed || p.certificateJSON != nil {
+		t.Errorf("Expected the certificate upload failure to be recorded, but got %+v", p.asset)
+	}
+
+	p.certificateJSON = []byte("{}")
+	if err := badgeStage(context.Background(), p); err != nil {
+		t.Fatalf("Expected the pipeline to carry on, but got %v", err)
+	}
+	if !p.asset.BadgeUploadFailed {
+		t.Errorf("Expected the badge upload failure to be recorded, but got %+v", p.asset)
+	}
+
+	expected := []string{"asset-1:" + certificateUploadFailedField, "asset-1:" + badgeUploadFailedField}
+	if !reflect.DeepEqual(marked, expected) {
+		t.Errorf("Expected flags %v on the stored asset, but got %v", expected, marked)
+	}
+}
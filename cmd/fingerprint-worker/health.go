@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"proofpix/internal/index"
+)
+
+// Index lifecycle calls made at startup. They are package variables so tests can
+// simulate GCS and Firestore failures.
+var (
+	loadIndex = func(ctx context.Context, m *index.IndexManager) error {
+		return m.Load(ctx, indexBucketName)
+	}
+	buildIndex = func(ctx context.Context, m *index.IndexManager, projectID string) error {
+		return m.Build(ctx, projectID, assetsCollection)
+	}
+	saveIndex = func(ctx context.Context, m *index.IndexManager) error {
+		return m.Save(ctx, indexBucketName)
+	}
+)
+
+// workerHealth records whether similarity search is available. When the index
+// cannot be loaded or built the worker keeps analyzing and certifying images with
+// search disabled, and reports itself as degraded.
+type workerHealth struct {
+	mu          sync.RWMutex
+	searchReady bool
+	indexErr    error
+}
+
+// health is the worker's current health
+var health = &workerHealth{}
+
+func (h *workerHealth) setIndex(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.searchReady = err == nil
+	h.indexErr = err
+}
+
+// searchEnabled reports whether the similarity index is usable
+func (h *workerHealth) searchEnabled() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.searchReady
+}
+
+// initIndex loads the index from GCS, or builds it from Firestore when none is stored.
+// It returns an error only when no usable index could be produced; failing to save a
+// freshly built index is logged since search still works from memory.
+func initIndex(ctx context.Context) error {
+	log.Printf("Loading index from GCS bucket: %s", indexBucketName)
+	loadErr := loadIndex(ctx, globalIndexManager)
+	if loadErr == nil && globalIndexManager.HasIndex() {
+		log.Println("Index successfully loaded from GCS")
+		return nil
+	}
+	if errors.Is(loadErr, index.ErrSnapshotNotFound) {
+		log.Printf("No usable index in GCS, building index from Firestore: %v", loadErr)
+		loadErr = nil
+	} else if loadErr != nil {
+		log.Printf("Failed to load index, building from Firestore instead: %v", loadErr)
+	} else {
+		log.Println("Index not found in GCS, building index from Firestore...")
+	}
+
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+	if err := buildIndex(ctx, globalIndexManager, projectID); err != nil {
+		if loadErr != nil {
+			return fmt.Errorf("failed to load index (%v) and failed to build index: %v", loadErr, err)
+		}
+		return fmt.Errorf("failed to build index: %v", err)
+	}
+
+	log.Println("Successfully built index, saving to GCS...")
+	if err := saveIndex(ctx, globalIndexManager); err != nil {
+		log.Printf("Failed to save index to GCS, continuing with in-memory index: %v", err)
+	} else {
+		log.Println("Successfully saved new index to GCS")
+	}
+	return nil
+}
+
+// setupIndex initializes the index and records the result in the health flag.
+// Failures leave the worker running in degraded mode rather than exiting.
+func setupIndex(ctx context.Context) {
+	indexMaintenance.Lock()
+	globalIndexManager = &index.IndexManager{}
+	// Vectors of a loaded snapshot are not held in memory, so searching by asset
+	// reads them from the asset documents
+	globalIndexManager.SetVectorSource(index.FirestoreVectors{ProjectID: os.Getenv("GOOGLE_CLOUD_PROJECT"), Collection: assetsCollection})
+	err := initIndex(ctx)
+	indexMaintenance.Unlock()
+	health.setIndex(err)
+	if err != nil {
+		log.Printf("Index unavailable, running in degraded mode with search disabled: %v", err)
+		return
+	}
+	log.Println("Index is ready for use")
+}
+
+// healthHandler reports worker health. It always returns 200 so a degraded worker
+// keeps serving; the body says whether search is disabled.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	health.mu.RLock()
+	body := map[string]interface{}{
+		"status": "ok",
+		"search": "enabled",
+	}
+	if !health.searchReady {
+		body["status"] = "degraded"
+		body["search"] = "disabled"
+		if health.indexErr != nil {
+			body["index_error"] = health.indexErr.Error()
+		}
+	}
+	health.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(body)
+}
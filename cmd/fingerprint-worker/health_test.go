@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proofpix/internal/index"
+	"proofpix/internal/models"
+)
+
+func TestSetupIndex_FailedLoadDegradesGracefully(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+	stubServices(t)
+
+	origLoad, origBuild, origSave := loadIndex, buildIndex, saveIndex
+	t.Cleanup(func() { loadIndex, buildIndex, saveIndex = origLoad, origBuild, origSave })
+	loadIndex = func(ctx context.Context, m *index.IndexManager) error {
+		return fmt.Errorf("bucket unavailable")
+	}
+	buildIndex = func(ctx context.Context, m *index.IndexManager, projectID string) error {
+		return fmt.Errorf("firestore unavailable")
+	}
+	saveIndex = func(ctx context.Context, m *index.IndexManager) error {
+		t.Errorf("Expected no save when the index could not be built")
+		return nil
+	}
+
+	setupIndex(context.Background())
+
+	if health.searchEnabled() {
+		t.Fatalf("Expected search to be disabled after a failed load and build")
+	}
+
+	// The health endpoint reports the degradation but still returns 200
+	rec := httptest.NewRecorder()
+	healthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, but got %d", http.StatusOK, rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if body["status"] != "degraded" || body["search"] != "disabled" {
+		t.Errorf("Expected degraded status with search disabled, but got %v", body)
+	}
+
+	// Analysis and certification still run
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+	var certified bool
+	storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error {
+		certified = true
+		return nil
+	}
+
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+
+	if saved == nil || saved.Status != models.StatusCompleted {
+		t.Errorf("Expected asset to be saved as completed, but got %+v", saved)
+	}
+	if !certified {
+		t.Errorf("Expected a certificate to be generated in degraded mode")
+	}
+}
+
+func TestInitIndex_BuildsWhenLoadFails(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+	stubServices(t)
+
+	origLoad, origBuild, origSave := loadIndex, buildIndex, saveIndex
+	t.Cleanup(func() { loadIndex, buildIndex, saveIndex = origLoad, origBuild, origSave })
+	loadIndex = func(ctx context.Context, m *index.IndexManager) error {
+		return fmt.Errorf("corrupt index object")
+	}
+	var built bool
+	buildIndex = func(ctx context.Context, m *index.IndexManager, projectID string) error {
+		built = true
+		return nil
+	}
+	saveIndex = func(ctx context.Context, m *index.IndexManager) error {
+		return fmt.Errorf("bucket read-only")
+	}
+
+	setupIndex(context.Background())
+
+	if !built {
+		t.Errorf("Expected the index to be rebuilt after a failed load")
+	}
+	if !health.searchEnabled() {
+		t.Errorf("Expected search to stay enabled when only saving failed")
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// workerID identifies this worker on the assets it processes. main captures it
+// from WORKER_ID at startup.
+var workerID string
+
+// workerIdentity returns WORKER_ID, naming the worker or region in multi-region
+// deployments, or the hostname when it is unset
+func workerIdentity() string {
+	if id := strings.TrimSpace(os.Getenv("WORKER_ID")); id != "" {
+		return id
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("Failed to read hostname for the worker ID: %v", err)
+		return ""
+	}
+	return hostname
+}
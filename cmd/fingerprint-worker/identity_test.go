@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"proofpix/internal/certificate"
+)
+
+func TestWorkerIdentity(t *testing.T) {
+	t.Setenv("WORKER_ID", " us-central1-worker-2 ")
+	if id := workerIdentity(); id != "us-central1-worker-2" {
+		t.Errorf("Expected the configured worker ID, but got %q", id)
+	}
+
+	t.Setenv("WORKER_ID", "")
+	hostname, _ := os.Hostname()
+	if id := workerIdentity(); id != hostname {
+		t.Errorf("Expected the hostname %q, but got %q", hostname, id)
+	}
+}
+
+func TestWorkerIdentityInCredential(t *testing.T) {
+	stubServices(t)
+	t.Setenv("CERTIFICATE_INCLUDE_WORKER", "true")
+	origID := workerID
+	workerID = "us-central1-worker-2"
+	t.Cleanup(func() { workerID = origID })
+
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+
+	p := &pipelineState{assetID: "asset-1", userID: "user-1", score: 80}
+	if err := saveStage(context.Background(), p); err != nil {
+		t.Fatalf("saveStage failed: %v", err)
+	}
+	if saved.ProcessedBy != "us-central1-worker-2" {
+		t.Errorf("Expected the asset to record the worker, but got %q", saved.ProcessedBy)
+	}
+	if err := certifyStage(context.Background(), p); err != nil {
+		t.Fatalf("certifyStage failed: %v", err)
+	}
+
+	var credential certificate.VerifiableCredential
+	if err := json.Unmarshal(p.certificateJSON, &credential); err != nil {
+		t.Fatalf("Failed to parse credential: %v", err)
+	}
+	if credential.Proof.ProcessedBy != "us-central1-worker-2" {
+		t.Errorf("Expected the credential proof to name the worker, but got %q", credential.Proof.ProcessedBy)
+	}
+}
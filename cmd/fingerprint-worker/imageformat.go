@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errUnsupportedImageType is returned when an upload is not an image type Vertex accepts
+var errUnsupportedImageType = errors.New("unsupported image type")
+
+// allowedImageTypes are the MIME types sent to Vertex for analysis
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+	"image/heic": true,
+	"image/heif": true,
+}
+
+// HEIF brands found in the ftyp box, which http.DetectContentType does not recognise
+var heifBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+}
+
+// sniffImageType detects an image's MIME type from its leading bytes, ignoring
+// the object's name, and fails unless it is an allowed type
+func sniffImageType(imageData []byte) (string, error) {
+	mimeType := http.DetectContentType(imageData)
+	if len(imageData) >= 12 && string(imageData[4:8]) == "ftyp" {
+		if heif, ok := heifBrands[string(imageData[8:12])]; ok {
+			mimeType = heif
+		}
+	}
+	if !allowedImageTypes[mimeType] {
+		return "", fmt.Errorf("%w: %s", errUnsupportedImageType, mimeType)
+	}
+	return mimeType, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// encodeTestImage encodes a small image with the given encoder
+func encodeTestImage(t *testing.T, encode func(*bytes.Buffer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniffImageType(t *testing.T) {
+	pngData := encodeTestImage(t, func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+	jpegData := encodeTestImage(t, func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) })
+	gifData := encodeTestImage(t, func(b *bytes.Buffer, img image.Image) error { return gif.Encode(b, img, nil) })
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		expectedType string
+		expectError  bool
+	}{
+		{name: "JPEG", data: jpegData, expectedType: "image/jpeg"},
+		{name: "PNG", data: pngData, expectedType: "image/png"},
+		{name: "WebP", data: []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), expectedType: "image/webp"},
+		{name: "HEIC", data: []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), expectedType: "image/heic"},
+		{name: "GIF is not accepted", data: gifData, expectError: true},
+		{name: "Text", data: []byte("definitely not an image"), expectError: true},
+		{name: "Empty", data: nil, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mimeType, err := sniffImageType(tc.data)
+			if tc.expectError {
+				if !errors.Is(err, errUnsupportedImageType) {
+					t.Errorf("Expected errUnsupportedImageType, but got %v (%q)", err, mimeType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if mimeType != tc.expectedType {
+				t.Errorf("Expected %q, but got %q", tc.expectedType, mimeType)
+			}
+		})
+	}
+}
+
+func TestDownloadStage_MismatchedExtension(t *testing.T) {
+	pngData := encodeTestImage(t, func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) })
+
+	testCases := []struct {
+		name         string
+		upload       []byte
+		expectedMIME string
+		expectError  bool
+	}{
+		{name: "PNG stored as .jpg", upload: pngData, expectedMIME: "image/png"},
+		{name: "Text stored as .jpg", upload: []byte("<html>not an image</html>"), expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+				return tc.upload, nil
+			}
+			var sentMIME string
+			analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+				sentMIME = mimeType
+				return "Confidence Score: 0.95\n\nJustification: Natural lighting.", "test-model", nil
+			}
+
+			p := &pipelineState{userID: "user-1", assetID: "asset-1"}
+			err := downloadStage(context.Background(), p)
+			if tc.expectError {
+				if !errors.Is(err, errUnsupportedImageType) {
+					t.Errorf("Expected errUnsupportedImageType, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if err := analyzeStage(context.Background(), p); err != nil {
+				t.Fatalf("Expected analyze stage to succeed, but got %v", err)
+			}
+			if sentMIME != tc.expectedMIME {
+				t.Errorf("Expected Vertex to receive %q, but got %q", tc.expectedMIME, sentMIME)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Limits on images fetched by URL, overridable with IMAGE_URL_MAX_BYTES and
+// IMAGE_URL_TIMEOUT
+const (
+	defaultImageURLMaxBytes = 20 << 20
+	defaultImageURLTimeout  = 30 * time.Second
+	// maxImageURLRedirects bounds the redirects followed, each to an allowed host
+	maxImageURLRedirects = 3
+)
+
+var (
+	// errImageURLNotAllowed is returned for URLs whose scheme or host is not allowed
+	errImageURLNotAllowed = errors.New("image URL not allowed")
+	// errImageTooLarge is returned when a fetched image exceeds IMAGE_URL_MAX_BYTES
+	errImageTooLarge = errors.New("image too large")
+)
+
+// Calls made by /process/url. They are package variables so tests can substitute
+// fakes for GCS.
+var (
+	fetchImageURL = downloadImageURL
+	storeUpload   = uploadImage
+)
+
+// allowedImageHosts returns the hosts in the comma-separated IMAGE_URL_ALLOWED_HOSTS.
+// An entry starting with "*." also allows every subdomain. Without the variable no
+// host is allowed.
+func allowedImageHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("IMAGE_URL_ALLOWED_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// checkImageURL returns an error unless u is an http or https URL on an allowed host.
+// Only named hosts are fetched, so callers cannot make the worker reach metadata
+// servers or other internal addresses.
+func checkImageURL(u *url.URL) error {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("%w: scheme %q", errImageURLNotAllowed, u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in URL", errImageURLNotAllowed)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range allowedImageHosts() {
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %q", errImageURLNotAllowed, host)
+}
+
+// imageURLMaxBytes returns IMAGE_URL_MAX_BYTES
+func imageURLMaxBytes() int64 {
+	value := os.Getenv("IMAGE_URL_MAX_BYTES")
+	if value == "" {
+		return defaultImageURLMaxBytes
+	}
+	maxBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxBytes <= 0 {
+		log.Printf("Invalid IMAGE_URL_MAX_BYTES %q, using default of %d", value, defaultImageURLMaxBytes)
+		return defaultImageURLMaxBytes
+	}
+	return maxBytes
+}
+
+// imageURLTimeout returns IMAGE_URL_TIMEOUT
+func imageURLTimeout() time.Duration {
+	value := os.Getenv("IMAGE_URL_TIMEOUT")
+	if value == "" {
+		return defaultImageURLTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Printf("Invalid IMAGE_URL_TIMEOUT %q, using default of %v", value, defaultImageURLTimeout)
+		return defaultImageURLTimeout
+	}
+	return timeout
+}
+
+// downloadImageURL fetches an image from an allowed host, following redirects only
+// to allowed hosts and reading at most IMAGE_URL_MAX_BYTES
+func downloadImageURL(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errImageURLNotAllowed, err)
+	}
+	if err := checkImageURL(u); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: imageURLTimeout(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxImageURLRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxImageURLRedirects)
+			}
+			return checkImageURL(req.URL)
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", u.Redacted(), resp.StatusCode)
+	}
+
+	maxBytes := imageURLMaxBytes()
+	if resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the limit of %d", errImageTooLarge, resp.ContentLength, maxBytes)
+	}
+	imageData, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", u.Redacted(), err)
+	}
+	if int64(len(imageData)) > maxBytes {
+		return nil, fmt.Errorf("%w: exceeds the limit of %d bytes", errImageTooLarge, maxBytes)
+	}
+	return imageData, nil
+}
+
+// uploadImage stores image bytes at the canonical upload path, uploads/{userID}/{assetID}.jpg
+func uploadImage(ctx context.Context, bucketName, userID, assetID string, imageData []byte, mimeType string) error {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create Google Cloud Storage client: %v", err)
+	}
+	defer client.Close()
+
+	objectPath := fmt.Sprintf("uploads/%s/%s.jpg", userID, assetID)
+	writer := client.Bucket(bucketName).Object(objectPath).NewWriter(ctx)
+	writer.ContentType = mimeType
+	if _, err := writer.Write(imageData); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write %s to bucket %s: %v", objectPath, bucketName, err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write %s to bucket %s: %v", objectPath, bucketName, err)
+	}
+	return nil
+}
+
+// processURLHandler processes an image referenced by image_url instead of one
+// already uploaded to GCS. The image is fetched before responding, so bad URLs and
+// disallowed hosts are reported to the caller, then stored at the canonical upload
+// path so the asset looks like any other upload, and processed asynchronously.
+func processURLHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received %s request to %s", r.Method, r.URL.Path)
+
+	req, ok := decodeProcessRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.ImageURL == "" {
+		http.Error(w, "Missing image_url", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	imageData, err := fetchImageURL(ctx, req.ImageURL)
+	switch {
+	case errors.Is(err, errImageURLNotAllowed):
+		log.Printf("Rejected image URL for asset_id=%s: %v", req.AssetID, err)
+		http.Error(w, "Image URL not allowed", http.StatusBadRequest)
+		return
+	case errors.Is(err, errImageTooLarge):
+		log.Printf("Image URL for asset_id=%s is too large: %v", req.AssetID, err)
+		http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		log.Printf("Failed to download image URL for asset_id=%s: %v", req.AssetID, err)
+		http.Error(w, "Failed to download image", http.StatusBadGateway)
+		return
+	}
+	mimeType, err := sniffImageType(imageData)
+	if err != nil {
+		log.Printf("Image URL for asset_id=%s is not a supported image: %v", req.AssetID, err)
+		http.Error(w, "Unsupported image type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err := storeUpload(ctx, req.options.Bucket, req.UserID, req.AssetID, imageData, mimeType); err != nil {
+		log.Printf("Failed to store image for asset_id=%s: %v", req.AssetID, err)
+		http.Error(w, "Failed to store image", http.StatusInternalServerError)
+		return
+	}
+
+	// The pipeline starts from the fetched bytes rather than reading them back
+	opts := req.options
+	opts.imageData = imageData
+	go processImage(req.UserID, req.AssetID, opts)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "accepted",
+		"message": "Image downloaded, processing started",
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestProcessURLHandler(t *testing.T) {
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo.jpg":
+			w.Write(testImageData)
+		case "/notes.txt":
+			w.Write([]byte("not an image"))
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/computeMetadata/v1/", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer imageServer.Close()
+	t.Setenv("IMAGE_URL_ALLOWED_HOSTS", "127.0.0.1, *.example.com")
+
+	testCases := []struct {
+		name         string
+		imageURL     string
+		maxBytes     string
+		expectedCode int
+	}{
+		{name: "Allowed host", imageURL: imageServer.URL + "/photo.jpg", expectedCode: http.StatusOK},
+		{name: "Metadata server", imageURL: "http://169.254.169.254/computeMetadata/v1/", expectedCode: http.StatusBadRequest},
+		{name: "Internal host", imageURL: "http://localhost:8080/health", expectedCode: http.StatusBadRequest},
+		{name: "Lookalike of allowed suffix", imageURL: "https://evilexample.com/photo.jpg", expectedCode: http.StatusBadRequest},
+		{name: "Redirect to metadata server", imageURL: imageServer.URL + "/metadata", expectedCode: http.StatusBadRequest},
+		{name: "Non-HTTP scheme", imageURL: "file:///etc/passwd", expectedCode: http.StatusBadRequest},
+		{name: "Missing image_url", expectedCode: http.StatusBadRequest},
+		{name: "Image too large", imageURL: imageServer.URL + "/photo.jpg", maxBytes: "8", expectedCode: http.StatusRequestEntityTooLarge},
+		{name: "Not an image", imageURL: imageServer.URL + "/notes.txt", expectedCode: http.StatusUnsupportedMediaType},
+		{name: "Missing image", imageURL: imageServer.URL + "/missing.jpg", expectedCode: http.StatusBadGateway},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("IMAGE_URL_MAX_BYTES", tc.maxBytes)
+
+			var stored []byte
+			var storedPath string
+			storeUpload = func(ctx context.Context, bucket, userID, assetID string, imageData []byte, mimeType string) error {
+				stored = imageData
+				storedPath = fmt.Sprintf("%s/uploads/%s/%s.jpg", bucket, userID, assetID)
+				return nil
+			}
+			fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+				t.Errorf("Expected the fetched image to be processed without reading it back from %s", bucket)
+				return testImageData, nil
+			}
+			analyzed := make(chan []byte, 1)
+			analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+				analyzed <- imageData
+				return "Confidence Score: 0.95\n\nJustification: Natural lighting.", "gemini-1.5-flash", nil
+			}
+			done := make(chan struct{})
+			appendEvent = func(ctx context.Context, assetID string, event models.AssetEvent) error {
+				if event.Stage == models.StageCompleted || event.Stage == models.StageFailed {
+					close(done)
+				}
+				return nil
+			}
+
+			body := fmt.Sprintf(`{"user_id":"user-1","asset_id":"asset-1","skip_anchoring":true,"image_url":%q}`, tc.imageURL)
+			req := httptest.NewRequest(http.MethodPost, "/process/url", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			processURLHandler(rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				if stored != nil {
+					t.Errorf("Expected nothing stored for a rejected URL, but stored %d bytes", len(stored))
+				}
+				return
+			}
+
+			if !bytes.Equal(stored, testImageData) {
+				t.Errorf("Expected the fetched image to be stored, but got %q", stored)
+			}
+			expectedPath := defaultUploadBucket + "/uploads/user-1/asset-1.jpg"
+			if storedPath != expectedPath {
+				t.Errorf("Expected image stored at %s, but got %s", expectedPath, storedPath)
+			}
+			select {
+			case imageData := <-analyzed:
+				if !bytes.Equal(imageData, testImageData) {
+					t.Errorf("Expected the fetched image to be analyzed, but got %q", imageData)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Expected the image to be processed")
+			}
+			<-done
+		})
+	}
+}
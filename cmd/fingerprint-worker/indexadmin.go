@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"proofpix/internal/index"
+)
+
+// indexMaintenance is held while the index is built, saved or reloaded as a whole,
+// so an admin save or reload cannot overlap a rebuild or another admin action
+var indexMaintenance sync.Mutex
+
+// indexAdminResult reports the index after an admin save or reload. Ntotal is the
+// number of vectors FAISS holds and IDMapSize the number mapped to asset IDs; a gap
+// between them is the drift these endpoints help debug.
+type indexAdminResult struct {
+	Action    string `json:"action"`
+	Ntotal    int64  `json:"ntotal"`
+	IDMapSize int    `json:"id_map_size"`
+}
+
+// indexSaveHandler saves the in-memory index to GCS as a new snapshot.
+// Route: POST /admin/index/save
+func indexSaveHandler(w http.ResponseWriter, r *http.Request) {
+	if !indexMaintenance.TryLock() {
+		http.Error(w, "Index rebuild, save or reload in progress", http.StatusConflict)
+		return
+	}
+	defer indexMaintenance.Unlock()
+
+	if !globalIndexManager.HasIndex() {
+		http.Error(w, "No index to save", http.StatusConflict)
+		return
+	}
+	if err := saveIndex(r.Context(), globalIndexManager); err != nil {
+		if errors.Is(err, index.ErrSaveInProgress) {
+			http.Error(w, "Index save in progress", http.StatusConflict)
+			return
+		}
+		log.Printf("Admin index save failed: %v", err)
+		http.Error(w, "Failed to save index", http.StatusBadGateway)
+		return
+	}
+	log.Println("Saved index on admin request")
+	respondIndexAdmin(w, "save")
+}
+
+// indexReloadHandler replaces the in-memory index with the current snapshot in GCS.
+// The vectors and their asset IDs are replaced together. A failed reload, including
+// one that finds no snapshot, keeps the index in place; a successful one re-enables
+// search on a degraded worker.
+// Route: POST /admin/index/reload
+func indexReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if !indexMaintenance.TryLock() {
+		http.Error(w, "Index rebuild, save or reload in progress", http.StatusConflict)
+		return
+	}
+	defer indexMaintenance.Unlock()
+
+	err := loadIndex(r.Context(), globalIndexManager)
+	if errors.Is(err, index.ErrSnapshotNotFound) {
+		log.Printf("Admin index reload found no snapshot: %v", err)
+		http.Error(w, "No index snapshot found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Admin index reload failed: %v", err)
+		http.Error(w, "Failed to reload index", http.StatusBadGateway)
+		return
+	}
+	health.setIndex(nil)
+	log.Println("Reloaded index on admin request")
+	respondIndexAdmin(w, "reload")
+}
+
+// respondIndexAdmin writes the index size after an admin action
+func respondIndexAdmin(w http.ResponseWriter, action string) {
+	ntotal, mapped := globalIndexManager.Stats()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(indexAdminResult{Action: action, Ntotal: ntotal, IDMapSize: mapped})
+}
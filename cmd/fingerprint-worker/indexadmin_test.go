@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+
+	"proofpix/internal/index"
+)
+
+// memoryStore is an in-memory stand-in for the GCS index bucket
+type memoryStore struct {
+	objects map[string][]byte
+}
+
+func (s *memoryStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	data, ok := s.objects[name]
+	if !ok {
+		return nil, index.ErrObjectNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.objects[name] = data
+	return nil
+}
+
+func (s *memoryStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	for name := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, name string) error {
+	delete(s.objects, name)
+	return nil
+}
+
+// serializedIndex returns a FAISS index file holding n vectors
+func serializedIndex(t *testing.T, n int) []byte {
+	t.Helper()
+	idx, err := faiss.NewIndexFlatL2(index.EmbeddingDimension)
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer idx.Delete()
+	if err := idx.Add(make([]float32, n*index.EmbeddingDimension)); err != nil {
+		t.Fatalf("Failed to add vectors: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "index.bin")
+	if err := faiss.WriteIndex(idx, path); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	return data
+}
+
+// postIndexAdmin calls an admin index handler and decodes a successful result
+func postIndexAdmin(t *testing.T, handler http.HandlerFunc, path string, expectedCode int) indexAdminResult {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	if rec.Code != expectedCode {
+		t.Fatalf("Expected status %d from %s, but got %d: %s", expectedCode, path, rec.Code, rec.Body.String())
+	}
+	var result indexAdminResult
+	if expectedCode == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return result
+}
+
+func TestIndexAdminHandlers(t *testing.T) {
+	stubServices(t)
+	t.Setenv("INDEX_LOAD_MODE", "")
+	store := &memoryStore{objects: map[string][]byte{}}
+	var loadErr error
+	origLoad, origSave := loadIndex, saveIndex
+	t.Cleanup(func() { loadIndex, saveIndex = origLoad, origSave })
+	loadIndex = func(ctx context.Context, m *index.IndexManager) error {
+		if loadErr != nil {
+			return loadErr
+		}
+		return m.LoadSnapshot(ctx, store)
+	}
+	saveIndex = func(ctx context.Context, m *index.IndexManager) error {
+		_, err := m.SaveSnapshot(ctx, store, 0)
+		return err
+	}
+	health.setIndex(errors.New("index not loaded at startup"))
+
+	// Nothing stored and nothing in memory yet
+	postIndexAdmin(t, indexReloadHandler, "/admin/index/reload", http.StatusNotFound)
+	postIndexAdmin(t, indexSaveHandler, "/admin/index/save", http.StatusConflict)
+
+	// A reload picks up the stored snapshot with the asset IDs saved next to it
+	store.objects[index.LegacyObject] = serializedIndex(t, 2)
+	store.objects["latest.labels.json"] = []byte(`{"ids":{"0":"asset-a","1":"asset-b"}}`)
+	result := postIndexAdmin(t, indexReloadHandler, "/admin/index/reload", http.StatusOK)
+	if result.Action != "reload" || result.Ntotal != 2 || result.IDMapSize != 2 {
+		t.Errorf("Expected 2 vectors and 2 mapped IDs after the reload, but got %+v", result)
+	}
+	if !health.searchEnabled() {
+		t.Error("Expected a successful reload to enable search")
+	}
+
+	// A save uploads the index as it is in memory
+	if err := globalIndexManager.Add("asset-1", make([]float32, index.EmbeddingDimension)); err != nil {
+		t.Fatalf("Failed to add vector: %v", err)
+	}
+	result = postIndexAdmin(t, indexSaveHandler, "/admin/index/save", http.StatusOK)
+	if result.Action != "save" || result.Ntotal != 3 || result.IDMapSize != 3 {
+		t.Errorf("Expected 3 vectors and 3 mapped IDs after the save, but got %+v", result)
+	}
+	if current, err := index.CurrentSnapshot(context.Background(), store); err != nil || current == "" {
+		t.Fatalf("Expected the save to move the latest pointer, but got %q (%v)", current, err)
+	}
+	result = postIndexAdmin(t, indexReloadHandler, "/admin/index/reload", http.StatusOK)
+	if result.Ntotal != 3 || result.IDMapSize != 3 {
+		t.Errorf("Expected the saved snapshot to be reloaded with 3 vectors and their IDs, but got %+v", result)
+	}
+
+	// A failed reload keeps the loaded index
+	loadErr = errors.New("bucket unavailable")
+	postIndexAdmin(t, indexReloadHandler, "/admin/index/reload", http.StatusBadGateway)
+	if ntotal, _ := globalIndexManager.Stats(); ntotal != 3 {
+		t.Errorf("Expected the index to be kept after a failed reload, but it holds %d vectors", ntotal)
+	}
+	loadErr = nil
+
+	// So does a reload of a snapshot whose labels are missing, rather than pairing
+	// the new vectors with the old asset IDs
+	current, _ := index.CurrentSnapshot(context.Background(), store)
+	delete(store.objects, strings.TrimSuffix(current, ".faiss")+".labels.json")
+	postIndexAdmin(t, indexReloadHandler, "/admin/index/reload", http.StatusNotFound)
+	if ntotal, mapped := globalIndexManager.Stats(); ntotal != 3 || mapped != 3 {
+		t.Errorf("Expected the index and its IDs to be kept, but got %d vectors and %d IDs", ntotal, mapped)
+	}
+
+	// Neither action overlaps a rebuild
+	indexMaintenance.Lock()
+	postIndexAdmin(t, indexSaveHandler, "/admin/index/save", http.StatusConflict)
+	postIndexAdmin(t, indexReloadHandler, "/admin/index/reload", http.StatusConflict)
+	indexMaintenance.Unlock()
+}
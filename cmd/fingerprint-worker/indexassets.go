@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"proofpix/internal/index"
+)
+
+// readIndexVector reads the stored embedding of an asset to put back in the index.
+// Tests replace it.
+var readIndexVector = func(ctx context.Context, assetID string) ([]float32, error) {
+	return index.FirestoreVectors{ProjectID: os.Getenv("GOOGLE_CLOUD_PROJECT"), Collection: "assets"}.Vector(ctx, assetID)
+}
+
+// indexRemoveHandler drops a soft-deleted asset from search on this worker and its
+// peers. The API calls it on delete; the reaper removes any asset it missed.
+// Route: POST /index/assets/{id}/remove
+func indexRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	if !validIndexDeltaSecret(w, r) {
+		return
+	}
+	assetID := r.PathValue("id")
+
+	globalIndexManager.Remove(assetID)
+	publishIndexRemoval(assetID)
+	log.Printf("Removed asset %s from the index", assetID)
+	respondIndexAsset(w, assetID, true)
+}
+
+// indexRestoreHandler puts a restored asset's stored embedding back in the index on
+// this worker and its peers. The API calls it on restore.
+// Route: POST /index/assets/{id}/restore
+func indexRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if !validIndexDeltaSecret(w, r) {
+		return
+	}
+	assetID := r.PathValue("id")
+
+	if !globalIndexManager.HasIndex() {
+		http.Error(w, "Index not loaded", http.StatusServiceUnavailable)
+		return
+	}
+	vector, err := readIndexVector(r.Context(), assetID)
+	if errors.Is(err, index.ErrAssetNotIndexed) {
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read the embedding of restored asset %s: %v", assetID, err)
+		http.Error(w, "Failed to read embedding", http.StatusBadGateway)
+		return
+	}
+	applied, err := globalIndexManager.ApplyDelta(assetID, vector)
+	if err != nil {
+		log.Printf("Failed to restore asset %s to the index: %v", assetID, err)
+		http.Error(w, "Failed to restore asset to the index", http.StatusInternalServerError)
+		return
+	}
+	publishIndexDelta(assetID, vector)
+	log.Printf("Restored asset %s to the index", assetID)
+	respondIndexAsset(w, assetID, applied)
+}
+
+// respondIndexAsset reports whether an index remove or restore changed the index
+func respondIndexAsset(w http.ResponseWriter, assetID string, applied bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"asset_id": assetID, "applied": applied})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"proofpix/internal/bodylimit"
+	"proofpix/internal/index"
+)
+
+// indexDeltaTimeout bounds each delta posted to a peer worker
+const indexDeltaTimeout = 10 * time.Second
+
+// indexDeltaSecretHeader carries INDEX_DELTA_SECRET on deltas sent to peers
+const indexDeltaSecretHeader = "X-Index-Delta-Secret"
+
+// indexDelta is a vector added to one worker's index, or an asset removed from it,
+// sent to the others
+type indexDelta struct {
+	AssetID string    `json:"asset_id"`
+	Vector  []float32 `json:"vector,omitempty"`
+	// Removed asks peers to drop the asset from search, as when it is soft-deleted
+	Removed bool `json:"removed,omitempty"`
+}
+
+// indexPeerURLs returns the base URLs of the other workers from INDEX_PEER_URLS, a
+// comma-separated list. Unset, deltas are not sent.
+func indexPeerURLs() []string {
+	var peers []string
+	for _, peer := range strings.Split(os.Getenv("INDEX_PEER_URLS"), ",") {
+		if peer = strings.TrimSuffix(strings.TrimSpace(peer), "/"); peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// publishIndexDelta tells the peer workers about a vector added to this worker's
+// index. It returns at once; tests replace it to observe deltas.
+var publishIndexDelta = broadcastIndexDelta
+
+// publishIndexRemoval tells the peer workers about an asset removed from this
+// worker's index. It returns at once; tests replace it to observe removals.
+var publishIndexRemoval = broadcastIndexRemoval
+
+// broadcastIndexDelta posts the delta to every peer in the background. Failed posts
+// are not retried: a peer that misses a delta only finds the asset once its index is
+// rebuilt from Firestore or replaced by a snapshot that holds the vector.
+func broadcastIndexDelta(assetID string, vector []float32) {
+	broadcast(indexDelta{AssetID: assetID, Vector: vector})
+}
+
+// broadcastIndexRemoval posts a removal to every peer in the background. A peer that
+// misses it keeps returning the asset until the reaper next runs there.
+func broadcastIndexRemoval(assetID string) {
+	broadcast(indexDelta{AssetID: assetID, Removed: true})
+}
+
+// broadcast posts an index change to every peer in the background
+func broadcast(delta indexDelta) {
+	peers := indexPeerURLs()
+	if len(peers) == 0 {
+		return
+	}
+	body, err := json.Marshal(delta)
+	if err != nil {
+		log.Printf("Failed to encode index delta for asset %s: %v", delta.AssetID, err)
+		return
+	}
+	for _, peer := range peers {
+		go func(peer string) {
+			if err := postIndexDelta(context.Background(), peer, body); err != nil {
+				log.Printf("Failed to send index delta for asset %s to %s: %v", delta.AssetID, peer, err)
+			}
+		}(peer)
+	}
+}
+
+// postIndexDelta posts an encoded delta to the /index/delta endpoint of a peer
+func postIndexDelta(ctx context.Context, peer string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, indexDeltaTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer+"/index/delta", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := os.Getenv("INDEX_DELTA_SECRET"); secret != "" {
+		req.Header.Set(indexDeltaSecretHeader, secret)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach peer: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// validIndexDeltaSecret reports whether the request carries INDEX_DELTA_SECRET, when
+// one is set, and otherwise responds with 401
+func validIndexDeltaSecret(w http.ResponseWriter, r *http.Request) bool {
+	if secret := os.Getenv("INDEX_DELTA_SECRET"); secret != "" {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(indexDeltaSecretHeader)), []byte(secret)) != 1 {
+			http.Error(w, "Invalid index delta secret", http.StatusUnauthorized)
+			return false
+		}
+	}
+	return true
+}
+
+// indexDeltaHandler applies a vector added by another worker to this worker's
+// in-memory index, or an asset removed from it, so searches agree across instances.
+// When INDEX_DELTA_SECRET is set, deltas must carry it in the X-Index-Delta-Secret
+// header.
+// Route: POST /index/delta
+func indexDeltaHandler(w http.ResponseWriter, r *http.Request) {
+	if !validIndexDeltaSecret(w, r) {
+		return
+	}
+
+	var delta indexDelta
+	if err := json.NewDecoder(r.Body).Decode(&delta); err != nil {
+		if bodylimit.IsTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+	if delta.AssetID == "" || (len(delta.Vector) == 0 && !delta.Removed) {
+		http.Error(w, "Missing asset_id or vector", http.StatusBadRequest)
+		return
+	}
+	if delta.Removed {
+		globalIndexManager.Remove(delta.AssetID)
+		log.Printf("Applied index removal for asset %s", delta.AssetID)
+		respondIndexAsset(w, delta.AssetID, true)
+		return
+	}
+
+	if !globalIndexManager.HasIndex() {
+		http.Error(w, "Index not loaded", http.StatusServiceUnavailable)
+		return
+	}
+	applied, err := globalIndexManager.ApplyDelta(delta.AssetID, delta.Vector)
+	if errors.Is(err, index.ErrDimensionMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to apply index delta for asset %s: %v", delta.AssetID, err)
+		http.Error(w, "Failed to apply index delta", http.StatusInternalServerError)
+		return
+	}
+	if applied {
+		log.Printf("Applied index delta for asset %s", delta.AssetID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"asset_id": delta.AssetID, "applied": applied})
+}
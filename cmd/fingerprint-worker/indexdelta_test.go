@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/index"
+)
+
+func TestBroadcastIndexDelta(t *testing.T) {
+	t.Setenv("INDEX_DELTA_SECRET", "s3cret")
+	received := make(chan indexDelta, 2)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index/delta" || r.Header.Get(indexDeltaSecretHeader) != "s3cret" {
+			t.Errorf("Expected a delta to /index/delta carrying the secret, but got %s with %q", r.URL.Path, r.Header.Get(indexDeltaSecretHeader))
+		}
+		var delta indexDelta
+		if err := json.NewDecoder(r.Body).Decode(&delta); err != nil {
+			t.Errorf("Failed to decode delta: %v", err)
+		}
+		received <- delta
+	}))
+	defer peer.Close()
+	t.Setenv("INDEX_PEER_URLS", peer.URL+"/, "+peer.URL)
+
+	broadcastIndexDelta("asset-1", []float32{0.1, 0.2, 0.3})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case delta := <-received:
+			if delta.AssetID != "asset-1" || !reflect.DeepEqual(delta.Vector, []float32{0.1, 0.2, 0.3}) {
+				t.Errorf("Expected the delta of asset-1, but got %+v", delta)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected a delta for each peer, but got %d", i)
+		}
+	}
+}
+
+func TestIndexDeltaHandler(t *testing.T) {
+	stubServices(t)
+	t.Setenv("INDEX_DELTA_SECRET", "s3cret")
+	globalIndexManager = &index.IndexManager{}
+
+	testCases := []struct {
+		name         string
+		secret       string
+		body         string
+		expectedCode int
+	}{
+		{name: "Missing secret", body: `{"asset_id":"asset-1","vector":[0.1]}`, expectedCode: http.StatusUnauthorized},
+		{name: "Wrong secret", secret: "guess", body: `{"asset_id":"asset-1","vector":[0.1]}`, expectedCode: http.StatusUnauthorized},
+		{name: "Missing vector", secret: "s3cret", body: `{"asset_id":"asset-1"}`, expectedCode: http.StatusBadRequest},
+		{name: "Malformed body", secret: "s3cret", body: `{"asset_id":`, expectedCode: http.StatusBadRequest},
+		{name: "Index not loaded", secret: "s3cret", body: `{"asset_id":"asset-1","vector":[0.1]}`, expectedCode: http.StatusServiceUnavailable},
+		{name: "Removal needs no vector", secret: "s3cret", body: `{"asset_id":"asset-1","removed":true}`, expectedCode: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/index/delta", strings.NewReader(tc.body))
+			if tc.secret != "" {
+				req.Header.Set(indexDeltaSecretHeader, tc.secret)
+			}
+			rec := httptest.NewRecorder()
+			indexDeltaHandler(rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Errorf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"google.golang.org/api/aiplatform/v1"
+
+	"proofpix/internal/vertex"
+)
+
+// Narrative language handling, selected with NARRATIVE_LANGUAGE
+const (
+	// languageOff stores narratives as the model returned them, untagged
+	languageOff = "off"
+	// languageTag records the detected language of the narrative on the asset
+	languageTag = "tag"
+	// languageTranslate also translates non-English narratives to English, keeping
+	// the original alongside
+	languageTranslate = "translate"
+)
+
+// Language tags returned by detectLanguage besides the detected languages
+const (
+	languageEnglish = "en"
+	// languageUndetermined is the BCP 47 tag for text whose language is unknown
+	languageUndetermined = "und"
+)
+
+// translateNarrative translates a narrative to English. It is a package variable so
+// tests can substitute a fake for Vertex.
+var translateNarrative = translateWithGemini
+
+// narrativeLanguageMode returns NARRATIVE_LANGUAGE, defaulting to off
+func narrativeLanguageMode() string {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("NARRATIVE_LANGUAGE")))
+	switch mode {
+	case "":
+		return languageOff
+	case languageOff, languageTag, languageTranslate:
+		return mode
+	}
+	log.Printf("Invalid NARRATIVE_LANGUAGE %q, using default of %s", mode, languageOff)
+	return languageOff
+}
+
+// scriptLanguages maps scripts to the language a narrative written in them is taken
+// to be in. Han is checked after Hiragana and Katakana, which only Japanese uses.
+var scriptLanguages = []struct {
+	script   *unicode.RangeTable
+	language string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// stopwords are frequent short words that tell Latin-script languages apart
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "is", "this", "with", "that", "are", "in", "to", "no"},
+	"es": {"el", "la", "los", "las", "de", "y", "es", "con", "que", "una", "del", "por"},
+	"fr": {"le", "la", "les", "de", "et", "est", "des", "une", "avec", "que", "du", "pas"},
+	"de": {"der", "die", "das", "und", "ist", "ein", "eine", "mit", "nicht", "von", "zu"},
+	"it": {"il", "la", "di", "e", "è", "che", "con", "una", "del", "della", "non", "sono"},
+	"pt": {"o", "a", "os", "de", "e", "é", "com", "que", "uma", "do", "da", "não"},
+	"nl": {"de", "het", "een", "en", "is", "van", "met", "niet", "dat", "op", "zijn"},
+}
+
+// detectLanguage guesses the language of a narrative, returning a BCP 47 tag or
+// languageUndetermined. Text mostly in a non-Latin script is identified by script;
+// Latin text by which language's stopwords it uses most. This only needs to tell a
+// model's English replies from the occasional reply in another language, not to
+// identify languages in general.
+func detectLanguage(text string) string {
+	letters := 0
+	scriptCounts := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				scriptCounts[s.language]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return languageUndetermined
+	}
+	for _, s := range scriptLanguages {
+		if count := scriptCounts[s.language]; count*2 > letters {
+			return s.language
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	best, bestHits, tied := languageUndetermined, 0, false
+	for language, list := range stopwords {
+		hits := 0
+		for _, word := range words {
+			for _, stopword := range list {
+				if word == stopword {
+					hits++
+					break
+				}
+			}
+		}
+		switch {
+		case hits > bestHits:
+			best, bestHits, tied = language, hits, false
+		case hits == bestHits:
+			tied = true
+		}
+	}
+	if bestHits < 2 || tied {
+		return languageUndetermined
+	}
+	return best
+}
+
+// tagNarrativeLanguage records the language of a freshly parsed narrative and, with
+// NARRATIVE_LANGUAGE=translate, replaces a non-English one with its English
+// translation. A failed translation keeps the original narrative, tagged.
+func tagNarrativeLanguage(ctx context.Context, p *pipelineState) {
+	mode := narrativeLanguageMode()
+	if mode == languageOff || p.narrative == "" {
+		return
+	}
+
+	language := detectLanguage(p.narrative)
+	p.narrativeLanguage = language
+	if mode != languageTranslate || language == languageEnglish || language == languageUndetermined {
+		return
+	}
+
+	var translated string
+	var err error
+	if poolErr := analysisPool.do(ctx, func() {
+		translated, err = translateNarrative(p.narrative, language)
+	}); poolErr != nil {
+		err = poolErr
+	}
+	translated = strings.TrimSpace(translated)
+	if err == nil && translated == "" {
+		err = fmt.Errorf("empty translation")
+	}
+	if err != nil {
+		log.Printf("Failed to translate %s narrative for asset %s, keeping the original: %v", language, p.assetID, err)
+		return
+	}
+	log.Printf("Translated %s narrative for asset %s to English", language, p.assetID)
+	p.narrativeOriginal, p.narrativeTranslatedFrom = p.narrative, language
+	p.narrative, p.narrativeLanguage = translated, languageEnglish
+}
+
+// translateWithGemini asks the analysis model for an English translation of a narrative
+func translateWithGemini(narrative, language string) (string, error) {
+	ctx := context.Background()
+
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return "", fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+	client, err := vertex.NewService(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create AI Platform service: %v", err)
+	}
+
+	prompt := fmt.Sprintf("Translate the following text from language %q to English. Reply with the translation only.\n\n%s", language, narrative)
+	req := &aiplatform.GoogleCloudAiplatformV1GenerateContentRequest{
+		Contents: []*aiplatform.GoogleCloudAiplatformV1Content{
+			{Role: "user", Parts: []*aiplatform.GoogleCloudAiplatformV1Part{{Text: prompt}}},
+		},
+		GenerationConfig: &aiplatform.GoogleCloudAiplatformV1GenerationConfig{
+			Temperature:     0,
+			MaxOutputTokens: 2048,
+		},
+	}
+	endpoint := fmt.Sprintf("projects/%s/locations/us-central1/publishers/google/models/%s", projectID, analysisModel())
+	resp, err := client.Projects.Locations.Publishers.Models.GenerateContent(endpoint, req).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("API call failed: %v", err)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("no translation in response")
+	}
+	return resp.Candidates[0].Content.Parts[0].Text, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const frenchAnalysis = "Confidence Score: 0.92\n\nJustification: La lumière est naturelle et les ombres sont cohérentes avec la scène, sans artefacts de génération."
+
+func TestDetectLanguage(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{text: "The lighting is natural and the shadows are consistent with the scene.", expected: "en"},
+		{text: "La lumière est naturelle et les ombres sont cohérentes avec la scène.", expected: "fr"},
+		{text: "La iluminación es natural y las sombras son coherentes con la escena.", expected: "es"},
+		{text: "Die Beleuchtung ist natürlich und die Schatten sind mit der Szene stimmig.", expected: "de"},
+		{text: "照明は自然で、影はシーンと一致しています。", expected: "ja"},
+		{text: "Освещение естественное, тени соответствуют сцене.", expected: "ru"},
+		{text: "Natural lighting.", expected: "und"},
+		{text: "0.95", expected: "und"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected+": "+tc.text, func(t *testing.T) {
+			if got := detectLanguage(tc.text); got != tc.expected {
+				t.Errorf("Expected %s, but got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestProcessImage_NarrativeLanguage(t *testing.T) {
+	const frenchNarrative = "La lumière est naturelle et les ombres sont cohérentes avec la scène, sans artefacts de génération."
+	const translation = "The lighting is natural and the shadows are consistent with the scene, with no generation artifacts."
+
+	testCases := []struct {
+		name                   string
+		mode                   string
+		translateErr           error
+		expectedNarrative      string
+		expectedLanguage       string
+		expectedOriginal       string
+		expectedTranslatedFrom string
+	}{
+		{name: "Disabled by default", mode: "", expectedNarrative: frenchNarrative},
+		{name: "Tagged", mode: "tag", expectedNarrative: frenchNarrative, expectedLanguage: "fr"},
+		{name: "Translated", mode: "translate", expectedNarrative: translation, expectedLanguage: "en", expectedOriginal: frenchNarrative, expectedTranslatedFrom: "fr"},
+		{name: "Translation fails", mode: "translate", translateErr: errors.New("vertex unavailable"), expectedNarrative: frenchNarrative, expectedLanguage: "fr"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("NARRATIVE_LANGUAGE", tc.mode)
+			analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+				return frenchAnalysis, "gemini-1.5-flash", nil
+			}
+			translateNarrative = func(narrative, language string) (string, error) {
+				if narrative != frenchNarrative || language != "fr" {
+					t.Errorf("Expected the French narrative to be translated, but got %q (%s)", narrative, language)
+				}
+				return translation, tc.translateErr
+			}
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return nil
+			}
+
+			processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket, SkipAnchoring: true})
+
+			if saved == nil {
+				t.Fatalf("Expected the asset to be saved")
+			}
+			if saved.Narrative != tc.expectedNarrative {
+				t.Errorf("Expected narrative %q, but got %q", tc.expectedNarrative, saved.Narrative)
+			}
+			if saved.NarrativeLanguage != tc.expectedLanguage {
+				t.Errorf("Expected narrative language %q, but got %q", tc.expectedLanguage, saved.NarrativeLanguage)
+			}
+			if saved.NarrativeOriginal != tc.expectedOriginal || saved.NarrativeTranslatedFrom != tc.expectedTranslatedFrom {
+				t.Errorf("Expected original %q in %q, but got %q in %q", tc.expectedOriginal, tc.expectedTranslatedFrom, saved.NarrativeOriginal, saved.NarrativeTranslatedFrom)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"proofpix/internal/leaf"
+)
+
+func TestLogCertificate_LeafFormats(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+
+	certificateJSON := []byte("{\n  \"issuer\": \"https://proofpix.com\"\n}")
+	hash := sha256.Sum256(certificateJSON)
+
+	testCases := []struct {
+		name         string
+		format       string
+		expectedLeaf []byte
+		storedFormat string
+	}{
+		{name: "Default hash format", format: "", expectedLeaf: hash[:], storedFormat: leaf.FormatHash},
+		{name: "Full certificate format", format: leaf.FormatCertificate, expectedLeaf: []byte(`{"issuer":"https://proofpix.com"}`), storedFormat: leaf.FormatCertificate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TRILLIAN_LEAF_FORMAT", tc.format)
+			stubServices(t)
+
+			// Mock log that records queued leaves
+			var queued [][]byte
+			queueLeaf = func(ctx context.Context, logID int64, logServerAddr string, leafValue []byte) (int64, error) {
+				queued = append(queued, leafValue)
+				return int64(len(queued)), nil
+			}
+			var storedFormat string
+			storeLeafIndex = func(ctx context.Context, assetID string, leafIndex int64, leafFormat string) error {
+				storedFormat = leafFormat
+				return nil
+			}
+
+			logCertificate(context.Background(), "asset-1", certificateJSON)
+
+			if len(queued) != 1 {
+				t.Fatalf("Expected 1 queued leaf, but got %d", len(queued))
+			}
+			if !bytes.Equal(queued[0], tc.expectedLeaf) {
+				t.Errorf("Expected leaf %q, but got %q", tc.expectedLeaf, queued[0])
+			}
+			if storedFormat != tc.storedFormat {
+				t.Errorf("Expected stored leaf format %q, but got %q", tc.storedFormat, storedFormat)
+			}
+			if tc.format == leaf.FormatCertificate && !json.Valid(queued[0]) {
+				t.Errorf("Expected certificate leaf to be valid JSON")
+			}
+		})
+	}
+}
+
+func TestLogCertificate_HashAlgorithms(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+	t.Setenv("TRILLIAN_LEAF_FORMAT", leaf.FormatHash)
+
+	certificateJSON := []byte("{\n  \"issuer\": \"https://proofpix.com\"\n}")
+
+	for _, algorithm := range []string{"", leaf.SHA256, leaf.SHA384, leaf.SHA512} {
+		t.Run("Algorithm "+algorithm, func(t *testing.T) {
+			t.Setenv("TRILLIAN_HASH_ALGORITHM", algorithm)
+			stubServices(t)
+
+			var queued []byte
+			queueLeaf = func(ctx context.Context, logID int64, logServerAddr string, leafValue []byte) (int64, error) {
+				queued = leafValue
+				return 1, nil
+			}
+
+			logged := logCertificate(context.Background(), "asset-1", certificateJSON)
+			if logged == nil {
+				t.Fatalf("Expected the certificate to be logged")
+			}
+
+			// Verify side: rebuild the leaf from the stored certificate with the tree's algorithm
+			hasher, err := leaf.NewHasher(algorithm)
+			if err != nil {
+				t.Fatalf("NewHasher() failed: %v", err)
+			}
+			if len(queued) != hasher.Size() {
+				t.Errorf("Expected a %d-byte %s leaf, but got %d bytes", hasher.Size(), hasher.Algorithm(), len(queued))
+			}
+			matched, err := hasher.Matches(leaf.FormatHash, certificateJSON, queued)
+			if err != nil {
+				t.Fatalf("Matches() failed: %v", err)
+			}
+			if !matched {
+				t.Errorf("Expected the queued leaf to match the leaf rebuilt on the verify side")
+			}
+			if !bytes.Equal(logged.hasher.HashLeaf(queued), hasher.HashLeaf(queued)) {
+				t.Errorf("Expected the worker to hash leaves with %s when waiting for inclusion", hasher.Algorithm())
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"proofpix/internal/models"
+)
+
+// defaultLeafBatchInterval is how long a leaf may wait in a batch before it is
+// flushed, overridable with TRILLIAN_BATCH_INTERVAL
+const defaultLeafBatchInterval = 2 * time.Second
+
+// leafFlushTimeout bounds a batch flush, which runs after the requests that
+// queued the leaves have returned
+const leafFlushTimeout = 30 * time.Second
+
+// queueLeafBatch submits several leaf values to Trillian, returning the leaf index
+// or error of each. It is a package variable so tests can substitute a fake.
+var queueLeafBatch = queueLeavesInTrillian
+
+// leafBatch buffers leaves for Trillian when TRILLIAN_BATCH_SIZE is above 1, and is
+// nil when each leaf is queued as its certificate is logged
+var leafBatch *leafBatcher
+
+// pendingLeaf is a certificate leaf waiting in a batch
+type pendingLeaf struct {
+	assetID    string
+	leafValue  []byte
+	leafFormat string
+}
+
+// leafBatcher collects leaves and submits them together once size leaves are
+// pending or the oldest has waited interval, then stores each asset's leaf index
+type leafBatcher struct {
+	logID         int64
+	logServerAddr string
+	size          int
+	interval      time.Duration
+
+	mu      sync.Mutex
+	pending []pendingLeaf
+	timer   *time.Timer
+	closed  bool
+	// flushing is held for the whole of a flush, so flushes run one at a time and
+	// close returns only after a flush started by the timer has finished
+	flushing sync.Mutex
+}
+
+// newLeafBatcherFromEnv returns a batcher for TRILLIAN_BATCH_SIZE leaves, or nil when
+// batching is off (the default) or Trillian is not configured
+func newLeafBatcherFromEnv() *leafBatcher {
+	value := os.Getenv("TRILLIAN_BATCH_SIZE")
+	if value == "" {
+		return nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 {
+		log.Printf("Invalid TRILLIAN_BATCH_SIZE %q, using default of 1", value)
+		return nil
+	}
+	if size == 1 {
+		return nil
+	}
+
+	interval := defaultLeafBatchInterval
+	if value := os.Getenv("TRILLIAN_BATCH_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Invalid TRILLIAN_BATCH_INTERVAL %q, using default of %v", value, defaultLeafBatchInterval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	logID, err := strconv.ParseInt(os.Getenv("TRILLIAN_LOG_ID"), 10, 64)
+	logServerAddr := os.Getenv("TRILLIAN_LOG_SERVER_ADDR")
+	if err != nil || logServerAddr == "" {
+		log.Printf("Trillian leaf batching disabled: TRILLIAN_LOG_ID or TRILLIAN_LOG_SERVER_ADDR not configured")
+		return nil
+	}
+	return &leafBatcher{logID: logID, logServerAddr: logServerAddr, size: size, interval: interval}
+}
+
+// add queues a leaf, flushing the batch when it is full. A closed batcher flushes
+// every leaf at once so nothing is left behind after shutdown.
+func (b *leafBatcher) add(ctx context.Context, l pendingLeaf) {
+	b.mu.Lock()
+	b.pending = append(b.pending, l)
+	full := len(b.pending) >= b.size || b.closed
+	if !full && b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, func() { b.flush(context.Background()) })
+	}
+	b.mu.Unlock()
+
+	if full {
+		b.flush(ctx)
+	}
+}
+
+// flush submits every pending leaf in one batch and records the outcome per asset
+func (b *leafBatcher) flush(ctx context.Context) {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	// The requests that queued these leaves may already be finished
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), leafFlushTimeout)
+	defer cancel()
+
+	values := make([][]byte, len(batch))
+	for i, l := range batch {
+		values[i] = l.leafValue
+	}
+	log.Printf("Flushing %d batched leaves to Trillian log %d", len(batch), b.logID)
+	leafIndexes, errs := queueLeafBatch(ctx, b.logID, b.logServerAddr, values)
+
+	for i, l := range batch {
+		if errs[i] != nil {
+			log.Printf("Failed to queue certificate leaf in Trillian for asset %s: %v", l.assetID, errs[i])
+			recordEvent(ctx, l.assetID, models.StageLogged, errs[i])
+			continue
+		}
+		if err := storeLeafIndex(ctx, l.assetID, leafIndexes[i], l.leafFormat); err != nil {
+			log.Printf("Failed to update Trillian leaf index in Firestore for asset %s: %v", l.assetID, err)
+			recordEvent(ctx, l.assetID, models.StageLogged, err)
+			continue
+		}
+		log.Printf("Successfully saved Trillian leaf index %d to Firestore for asset %s", leafIndexes[i], l.assetID)
+		recordEvent(ctx, l.assetID, models.StageLogged, nil)
+	}
+}
+
+// close flushes pending leaves before the worker stops, waiting for any flush in
+// progress; leaves added afterwards are submitted immediately. It is safe to call
+// on a nil batcher.
+func (b *leafBatcher) close(ctx context.Context) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.flush(ctx)
+}
+
+// queueLeavesInTrillian submits a batch of leaf values to the Trillian Log Server.
+// Trillian 1.7 no longer offers a QueueLeaves RPC, so the leaves share one
+// connection and are queued one after another; this still saves the connection
+// setup that queueing each leaf separately costs.
+func queueLeavesInTrillian(ctx context.Context, logID int64, logServerAddr string, leafValues [][]byte) ([]int64, []error) {
+	leafIndexes := make([]int64, len(leafValues))
+	errs := make([]error, len(leafValues))
+
+	conn, err := grpc.DialContext(ctx, logServerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		err = fmt.Errorf("failed to connect to Trillian Log Server at %s: %v", logServerAddr, err)
+		for i := range errs {
+			errs[i] = err
+		}
+		return leafIndexes, errs
+	}
+	defer func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			log.Printf("Error closing gRPC connection: %v", closeErr)
+		}
+	}()
+
+	client := trillian.NewTrillianLogClient(conn)
+	for i, leafValue := range leafValues {
+		leafIndexes[i], errs[i] = submitLeaf(ctx, client, logID, leafValue)
+	}
+	return leafIndexes, errs
+}
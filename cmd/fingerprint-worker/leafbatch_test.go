@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLeafBatcher_FlushesInOneCall(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+
+	testCases := []struct {
+		name          string
+		size          string
+		interval      string
+		leaves        int
+		closeBatcher  bool
+		expectedCalls []int
+	}{
+		{name: "Flushed when the batch is full", size: "3", interval: "1h", leaves: 3, expectedCalls: []int{3}},
+		{name: "Flushed by the timer", size: "10", interval: "20ms", leaves: 2, expectedCalls: []int{2}},
+		{name: "Flushed on shutdown", size: "10", interval: "1h", leaves: 4, closeBatcher: true, expectedCalls: []int{4}},
+		{name: "Full batch then remainder on shutdown", size: "2", interval: "1h", leaves: 3, closeBatcher: true, expectedCalls: []int{2, 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TRILLIAN_BATCH_SIZE", tc.size)
+			t.Setenv("TRILLIAN_BATCH_INTERVAL", tc.interval)
+			stubServices(t)
+
+			var mu sync.Mutex
+			var calls []int
+			allStored := make(chan struct{})
+			queueLeafBatch = func(ctx context.Context, logID int64, logServerAddr string, leafValues [][]byte) ([]int64, []error) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, len(leafValues))
+				leafIndexes := make([]int64, len(leafValues))
+				for i := range leafValues {
+					leafIndexes[i] = int64(100*len(calls) + i)
+				}
+				return leafIndexes, make([]error, len(leafValues))
+			}
+			stored := make(map[string]int64)
+			storeLeafIndex = func(ctx context.Context, assetID string, leafIndex int64, leafFormat string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				stored[assetID] = leafIndex
+				if len(stored) == tc.leaves {
+					close(allStored)
+				}
+				return nil
+			}
+
+			leafBatch = newLeafBatcherFromEnv()
+			if leafBatch == nil {
+				t.Fatalf("Expected batching to be enabled")
+			}
+			for i := 0; i < tc.leaves; i++ {
+				logCertificate(context.Background(), fmt.Sprintf("asset-%d", i), []byte(fmt.Sprintf(`{"n":%d}`, i)))
+			}
+			if !tc.closeBatcher {
+				select {
+				case <-allStored:
+				case <-time.After(2 * time.Second):
+					t.Fatalf("Expected the batch to be flushed")
+				}
+			}
+			// Closing waits for a flush started by the timer, so it no longer touches
+			// the stubbed services once the test restores them
+			leafBatch.close(context.Background())
+
+			mu.Lock()
+			defer mu.Unlock()
+			if fmt.Sprint(calls) != fmt.Sprint(tc.expectedCalls) {
+				t.Errorf("Expected batches of %v, but got %v", tc.expectedCalls, calls)
+			}
+			if len(stored) != tc.leaves {
+				t.Errorf("Expected a leaf index stored for each of %d assets, but got %v", tc.leaves, stored)
+			}
+			if tc.leaves >= 2 && stored["asset-0"] == stored["asset-1"] {
+				t.Errorf("Expected each asset to get its own leaf index, but got %v", stored)
+			}
+		})
+	}
+}
+
+func TestLeafBatcher_RecordsPerLeafFailures(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+	t.Setenv("TRILLIAN_BATCH_SIZE", "2")
+	stubServices(t)
+
+	queueLeafBatch = func(ctx context.Context, logID int64, logServerAddr string, leafValues [][]byte) ([]int64, []error) {
+		return []int64{5, 0}, []error{nil, fmt.Errorf("resource exhausted")}
+	}
+	stored := make(map[string]int64)
+	storeLeafIndex = func(ctx context.Context, assetID string, leafIndex int64, leafFormat string) error {
+		stored[assetID] = leafIndex
+		return nil
+	}
+
+	leafBatch = newLeafBatcherFromEnv()
+	logCertificate(context.Background(), "asset-ok", []byte(`{"n":1}`))
+	logCertificate(context.Background(), "asset-failed", []byte(`{"n":2}`))
+
+	if len(stored) != 1 || stored["asset-ok"] != 5 {
+		t.Errorf("Expected only asset-ok to get leaf index 5, but got %v", stored)
+	}
+}
+
+func TestNewLeafBatcherFromEnv_Disabled(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+
+	for _, size := range []string{"", "1", "0", "many"} {
+		t.Setenv("TRILLIAN_BATCH_SIZE", size)
+		if b := newLeafBatcherFromEnv(); b != nil {
+			t.Errorf("Expected batching off for TRILLIAN_BATCH_SIZE %q, but got %+v", size, b)
+		}
+	}
+
+	t.Setenv("TRILLIAN_BATCH_SIZE", "5")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "")
+	if b := newLeafBatcherFromEnv(); b != nil {
+		t.Errorf("Expected batching off without a log server, but got %+v", b)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -10,382 +9,389 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
 	"strconv"
-	"sync"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
 	"google.golang.org/api/aiplatform/v1"
-	"google.golang.org/api/option"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	
 	"github.com/google/trillian"
 	
+	"proofpix/internal/bodylimit"
 	"proofpix/internal/certificate"
+	"proofpix/internal/config"
 	"proofpix/internal/index"
+	"proofpix/internal/leaf"
 	"proofpix/internal/models"
+	"proofpix/internal/vertex"
 )
 
 // Constants for index management
 const (
 	indexBucketName    = "proofpix-index"
-	indexObjectName    = "latest.faiss"
 	assetsCollection   = "assets"
 )
 
+// shutdownTimeout bounds draining requests and batched leaves when the worker
+// stops; Cloud Run allows 10 seconds after SIGTERM
+const shutdownTimeout = 9 * time.Second
+
 // Global index manager instance
 var globalIndexManager *index.IndexManager
 
+// Global analysis result cache, nil when caching is disabled
+var analysisCache AnalysisCache
+
+// Global credential signing config, nil when credentials are not signed
+var credentialSigner *certificate.SigningConfig
+
 // Asset represents an image asset with its analysis results
-type Asset struct {
-	ID               string    `firestore:"id"`
-	UserID           string    `firestore:"user_id"`
-	Status           string    `firestore:"status"`
-	CreatedAt        time.Time `firestore:"created_at"`
-	RawAnalysis      string    `firestore:"raw_analysis"`
-	OriginalityScore int       `firestore:"originality_score"`
-	Narrative        string    `firestore:"narrative"`
-	Embedding        []float32 `firestore:"embedding"`
-	TrillianLeafIndex int64    `firestore:"trillian_leaf_index,omitempty"`
-}
+type Asset = models.Asset
+
+// External service calls made while processing an image. They are package
+// variables so tests can substitute fakes for GCS, Vertex AI, Firestore and Trillian.
+var (
+	fetchImage       = downloadImage
+	analyzeImage     = getAuthenticityAnalysis
+	embedImage       = getEmbedding
+	loadAsset        = getAsset
+	storeAsset       = saveAsset
+	storeCertificate = saveJSONCertificate
+	storeBadge       = savePNGBadge
+	queueLeaf        = queueLeafInTrillian
+	storeLeafIndex   = updateTrillianLeafIndex
+	appendEvent      = saveAssetEvent
+)
 
 func main() {
 	log.Println("Fingerprint worker started")
 	
+	// Validate the configuration before anything depends on it
+	cfg, err := config.Load(config.Worker)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	
+	// Record which worker processed each asset
+	workerID = workerIdentity()
+	log.Printf("Worker ID: %s", workerID)
+	
 	// Initialize index startup lifecycle
 	ctx := context.Background()
 	
-	// Create a new instance of IndexManager
-	globalIndexManager = &index.IndexManager{}
+	// Load or build the similarity index; without one the worker runs with search disabled
+	setupIndex(ctx)
 	
-	// Call the Load method on the manager instance
-	log.Printf("Loading index from GCS bucket: %s, object: %s", indexBucketName, indexObjectName)
-	err := globalIndexManager.Load(ctx, indexBucketName, indexObjectName)
+	// Configure the analysis result cache
+	analysisCache, err = newAnalysisCache(os.Getenv("ANALYSIS_CACHE"))
 	if err != nil {
-		log.Fatalf("Failed to load index: %v", err)
+		log.Fatalf("Failed to configure analysis cache: %v", err)
 	}
 	
-	// Check if the manager's internal index is still nil
-	if !globalIndexManager.HasIndex() {
-		// Log that we are building the index from Firestore
-		log.Println("Index not found in GCS, building index from Firestore...")
-		
-		// Get project ID from environment for Build method
-		projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
-		if projectID == "" {
-			log.Fatal("GOOGLE_CLOUD_PROJECT environment variable not set")
-		}
-		
-		// Call the Build method
-		err = globalIndexManager.Build(ctx, projectID, assetsCollection)
-		if err != nil {
-			log.Fatalf("Failed to build index: %v", err)
-		}
-		
-		// If Build succeeds, log that we are saving the new index to GCS
-		log.Println("Successfully built index, saving to GCS...")
-		
-		// Call the Save method
-		err = globalIndexManager.Save(ctx, indexBucketName, indexObjectName)
-		if err != nil {
-			log.Fatalf("Failed to save index to GCS: %v", err)
-		}
-		
-		log.Println("Successfully saved new index to GCS")
-	} else {
-		log.Println("Index successfully loaded from GCS")
+	// Configure credential signing; a key that does not match the algorithm is fatal
+	credentialSigner, err = certificate.SigningConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure credential signing: %v", err)
+	}
+	if credentialSigner != nil {
+		log.Printf("Signing credentials with %s", credentialSigner.Algorithm)
 	}
 	
-	// Log final message confirming that the index is ready
-	log.Println("Index is ready for use")
+	// Bound Vertex calls across all requests
+	configureVertexPools()
+	
+	// Optionally buffer Trillian leaves and submit them in batches
+	leafBatch = newLeafBatcherFromEnv()
+	if leafBatch != nil {
+		log.Printf("Batching Trillian leaves: up to %d per batch, flushed every %v", leafBatch.size, leafBatch.interval)
+	}
 	
 	// Set up HTTP handler
 	http.HandleFunc("/process", processHandler)
+	http.HandleFunc("/process/sync", processSyncHandler)
+	http.HandleFunc("/process/url", processURLHandler)
+	http.HandleFunc("/reap", reapHandler)
+	http.HandleFunc("/retry-saves", retrySavesHandler)
+	http.HandleFunc("POST /admin/assets/{id}/reverify-embedding", reverifyEmbeddingHandler)
+	http.HandleFunc("POST /admin/index/save", indexSaveHandler)
+	http.HandleFunc("POST /admin/index/reload", indexReloadHandler)
+	http.HandleFunc("POST /index/delta", indexDeltaHandler)
+	http.HandleFunc("POST /index/assets/{id}/remove", indexRemoveHandler)
+	http.HandleFunc("POST /index/assets/{id}/restore", indexRestoreHandler)
+	http.HandleFunc("/health", healthHandler)
 	
 	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := cfg.Port
 	
 	log.Printf("Starting server on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	// Bound request bodies for every handler
+	server := &http.Server{Addr: ":" + port, Handler: limitRequestBody(http.DefaultServeMux)}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	
+	// Cloud Run sends SIGTERM before stopping an instance: finish in-flight requests,
+	// then flush batched leaves so no certificate is left out of the log
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	<-stop
+	log.Println("Shutting down fingerprint worker")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server cleanly: %v", err)
+	}
+	leafBatch.close(shutdownCtx)
 }
 
 // processHandler handles incoming HTTP requests to process images
 func processHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received %s request to %s", r.Method, r.URL.Path)
 	
+	req, ok := decodeProcessRequest(w, r)
+	if !ok {
+		return
+	}
+	
+	// Launch processImage as a goroutine for asynchronous processing
+	go processImage(req.UserID, req.AssetID, req.options)
+	
+	// Immediately return 200 OK
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "accepted",
+		"message": "Image processing started",
+	})
+	log.Printf("Request accepted, processing started asynchronously")
+}
+
+// processRequest is the JSON body of /process, /process/sync and /process/url
+type processRequest struct {
+	UserID  string `json:"user_id"`
+	AssetID string `json:"asset_id"`
+	Bucket  string `json:"bucket"`
+	// SkipAnchoring certifies the asset without queueing it in the transparency log
+	SkipAnchoring bool `json:"skip_anchoring"`
+	// Visibility is public or private; empty keeps an existing asset's visibility
+	// or applies ASSET_DEFAULT_VISIBILITY
+	Visibility string `json:"visibility"`
+	// WaitForInclusion makes /process/sync wait until the certificate leaf is
+	// integrated into the log before responding
+	WaitForInclusion bool `json:"wait_for_inclusion"`
+	// ImageURL is the image fetched by /process/url in place of an upload
+	ImageURL string `json:"image_url"`
+
+	// options are the validated processing settings
+	options processOptions
+}
+
+// decodeProcessRequest parses and validates a processing request, writing an
+// error response and returning false when it is unusable
+func decodeProcessRequest(w http.ResponseWriter, r *http.Request) (processRequest, bool) {
+	var req processRequest
+	
 	// Only accept POST requests
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+		return req, false
 	}
 	
 	// Parse JSON request body
-	var req struct {
-		UserID  string `json:"user_id"`
-		AssetID string `json:"asset_id"`
-	}
-	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("Failed to parse request body: %v", err)
+		if bodylimit.IsTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return req, false
+		}
 		http.Error(w, "Invalid JSON request", http.StatusBadRequest)
-		return
+		return req, false
 	}
 	
 	// Validate required fields
 	if req.UserID == "" || req.AssetID == "" {
 		log.Printf("Missing required fields: user_id=%s, asset_id=%s", req.UserID, req.AssetID)
 		http.Error(w, "Missing user_id or asset_id", http.StatusBadRequest)
-		return
+		return req, false
 	}
 	
-	log.Printf("Processing request for user_id=%s, asset_id=%s", req.UserID, req.AssetID)
+	// Only read uploads from allowlisted buckets
+	bucket, err := resolveUploadBucket(req.Bucket)
+	if err != nil {
+		log.Printf("Rejected request for asset_id=%s: %v", req.AssetID, err)
+		http.Error(w, "Bucket not allowed", http.StatusBadRequest)
+		return req, false
+	}
 	
-	// Launch processImage as a goroutine for asynchronous processing
-	go processImage(req.UserID, req.AssetID)
+	visibility, err := models.ParseVisibility(req.Visibility)
+	if err != nil {
+		log.Printf("Rejected request for asset_id=%s: %v", req.AssetID, err)
+		http.Error(w, "Invalid visibility", http.StatusBadRequest)
+		return req, false
+	}
 	
-	// Immediately return 200 OK
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "accepted",
-		"message": "Image processing started",
-	})
-	log.Printf("Request accepted, processing started asynchronously")
+	log.Printf("Processing request for user_id=%s, asset_id=%s, bucket=%s, skip_anchoring=%t, visibility=%s", req.UserID, req.AssetID, bucket, req.SkipAnchoring, visibility)
+	req.options = processOptions{Bucket: bucket, SkipAnchoring: req.SkipAnchoring, Visibility: visibility}
+	return req, true
 }
 
-// processImage downloads an image from Google Cloud Storage and processes it asynchronously
-func processImage(userID, assetID string) {
+// processImage downloads an image from Google Cloud Storage and runs it through the
+// processing stages, returning the pipeline and the result of its last stage
+func processImage(userID, assetID string, opts processOptions) (*pipelineState, stageResult) {
 	ctx := context.Background()
 	
-	// 1. Initialize a new Google Cloud Storage client
+	markProcessing(ctx, assetID)
+	state := &pipelineState{userID: userID, assetID: assetID, bucket: opts.Bucket, skipAnchoring: opts.SkipAnchoring, visibility: opts.Visibility, imageData: opts.imageData}
+	results := runPipeline(ctx, state, processingStages)
+	
+	last := results[len(results)-1]
+	if last.err != nil {
+		log.Printf("Image processing stopped at stage %s for user_id=%s, asset_id=%s", last.stage, userID, assetID)
+		return state, last
+	}
+	log.Printf("Image processing completed for user_id=%s, asset_id=%s", userID, assetID)
+	return state, last
+}
+
+// loadPartialAsset returns the stored asset if an earlier run saved it with partial
+// results, or nil when the asset should be processed from scratch
+func loadPartialAsset(ctx context.Context, assetID string) *Asset {
+	asset, err := loadAsset(ctx, assetID)
+	if err != nil {
+		log.Printf("Failed to load existing asset %s, processing from scratch: %v", assetID, err)
+		return nil
+	}
+	if asset == nil || !asset.IsPartial() {
+		return nil
+	}
+	return asset
+}
+
+// logCertificate queues the certificate in Trillian, encoded per TRILLIAN_LEAF_FORMAT, and stores the resulting leaf index.
+// It returns the queued leaf, or nil when the leaf was not queued or was handed to the batcher.
+func logCertificate(ctx context.Context, assetID string, certificateJSON []byte) *loggedLeaf {
+	trillianLogID := os.Getenv("TRILLIAN_LOG_ID")
+	trillianLogServerAddr := os.Getenv("TRILLIAN_LOG_SERVER_ADDR")
+	
+	if trillianLogID == "" || trillianLogServerAddr == "" {
+		log.Printf("Skipping Trillian integration for asset %s: TRILLIAN_LOG_ID or TRILLIAN_LOG_SERVER_ADDR not configured", assetID)
+		return nil
+	}
+	
+	// Parse log ID from string to int64
+	logID, err := strconv.ParseInt(trillianLogID, 10, 64)
+	if err != nil {
+		log.Printf("Failed to parse TRILLIAN_LOG_ID for asset %s: %v", assetID, err)
+		recordEvent(ctx, assetID, models.StageLogged, err)
+		return nil
+	}
+	
+	// Encode the certificate as a leaf in the configured format (hash or full certificate)
+	leafFormat, err := leaf.FormatFromEnv()
+	if err != nil {
+		log.Printf("Invalid leaf format for asset %s: %v", assetID, err)
+		recordEvent(ctx, assetID, models.StageLogged, err)
+		return nil
+	}
+	// Hash leaves with the algorithm of the tree so the log's proofs verify
+	hasher, err := leaf.HasherFromEnv()
+	if err != nil {
+		log.Printf("Invalid leaf hash algorithm for asset %s: %v", assetID, err)
+		recordEvent(ctx, assetID, models.StageLogged, err)
+		return nil
+	}
+	leafValue, err := hasher.Value(leafFormat, certificateJSON)
+	if err != nil {
+		log.Printf("Failed to encode certificate leaf for asset %s: %v", assetID, err)
+		recordEvent(ctx, assetID, models.StageLogged, err)
+		return nil
+	}
+	
+	// With batching on, the leaf index is stored when the batch is flushed
+	if leafBatch != nil {
+		log.Printf("Batching certificate leaf (%s format) for asset %s", leafFormat, assetID)
+		leafBatch.add(ctx, pendingLeaf{assetID: assetID, leafValue: leafValue, leafFormat: leafFormat})
+		return nil
+	}
+	
+	// Queue the leaf in Trillian
+	leafIndex, err := queueLeaf(ctx, logID, trillianLogServerAddr, leafValue)
+	if err != nil {
+		log.Printf("Failed to queue certificate leaf in Trillian for asset %s: %v", assetID, err)
+		recordEvent(ctx, assetID, models.StageLogged, err)
+		return nil
+	}
+	log.Printf("Successfully queued certificate leaf (%s format) in Trillian for asset %s with leaf index %d", leafFormat, assetID, leafIndex)
+	
+	// Update the TrillianLeafIndex and leaf format fields directly in Firestore
+	if err := storeLeafIndex(ctx, assetID, leafIndex, leafFormat); err != nil {
+		log.Printf("Failed to update Trillian leaf index in Firestore for asset %s: %v", assetID, err)
+		recordEvent(ctx, assetID, models.StageLogged, err)
+		return nil
+	}
+	log.Printf("Successfully saved Trillian leaf index %d to Firestore for asset %s", leafIndex, assetID)
+	recordEvent(ctx, assetID, models.StageLogged, nil)
+	return &loggedLeaf{logID: logID, index: leafIndex, value: leafValue, hasher: hasher}
+}
+
+// downloadImage reads the uploaded image for an asset from Google Cloud Storage
+func downloadImage(ctx context.Context, bucketName, userID, assetID string) ([]byte, error) {
+	// Initialize a new Google Cloud Storage client
 	log.Println("Initializing Google Cloud Storage client...")
 	client, err := storage.NewClient(ctx)
 	if err != nil {
-		log.Printf("Failed to create Google Cloud Storage client: %v", err)
-		return
+		return nil, fmt.Errorf("failed to create Google Cloud Storage client: %v", err)
 	}
 	defer client.Close()
 	
-	// 2. Construct the object path using the userID and assetID
+	// Construct the object path using the userID and assetID
 	objectPath := fmt.Sprintf("uploads/%s/%s.jpg", userID, assetID)
 	log.Printf("Constructed object path: %s", objectPath)
 	
-	// 3. Use the client to open and read the object from the proofpix-assets-upload bucket
-	bucketName := "proofpix-assets-upload"
-	bucket := client.Bucket(bucketName)
-	object := bucket.Object(objectPath)
+	// Use the client to open and read the object from the upload bucket
+	object := client.Bucket(bucketName).Object(objectPath)
 	
 	log.Printf("Opening object %s from bucket %s...", objectPath, bucketName)
 	reader, err := object.NewReader(ctx)
 	if err != nil {
-		log.Printf("Failed to open object %s from bucket %s: %v", objectPath, bucketName, err)
-		return
+		return nil, fmt.Errorf("failed to open object %s from bucket %s: %v", objectPath, bucketName, err)
 	}
 	defer reader.Close()
 	
-	// 4. Read the file content into a byte slice
+	// Read the file content into a byte slice
 	log.Println("Reading file content...")
 	imageData, err := io.ReadAll(reader)
 	if err != nil {
-		log.Printf("Failed to read file content: %v", err)
-		return
+		return nil, fmt.Errorf("failed to read file content: %v", err)
 	}
 	
-	// 5. Add logging to confirm successful download and print the size of the downloaded image data
 	log.Printf("Successfully downloaded image from GCS")
 	log.Printf("Image data size: %d bytes (%.2f KB)", len(imageData), float64(len(imageData))/1024)
-	
-	// 6. Run getAuthenticityAnalysis and getEmbedding concurrently
-	var wg sync.WaitGroup
-	
-	// Variables to store results from both functions
-	var analysisText string
-	var analysisErr error
-	var embedding []float32
-	var embeddingErr error
-	
-	// Launch goroutine for getAuthenticityAnalysis
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		analysisText, analysisErr = getAuthenticityAnalysis(imageData)
-	}()
-	
-	// Launch goroutine for getEmbedding
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		embedding, embeddingErr = getEmbedding(imageData)
-	}()
-	
-	// Wait for both functions to complete
-	log.Println("Waiting for authenticity analysis and embedding generation to complete...")
-	wg.Wait()
-	
-	// Check and log results from both functions
-	var score int
-	var narrative string
-	
-	if analysisErr != nil {
-		log.Printf("Failed to analyze image authenticity: %v", analysisErr)
-	} else {
-		log.Printf("Authenticity analysis result: %s", analysisText)
-		
-		// Parse the analysis text to extract score and narrative
-		parsedScore, parsedNarrative, parseErr := parseAnalysis(analysisText)
-		if parseErr != nil {
-			log.Printf("Failed to parse analysis for asset %s: %v", assetID, parseErr)
-			// Fall back to default values
-			score = 0
-			narrative = analysisText // Use raw analysis text as fallback
-		} else {
-			score = parsedScore
-			narrative = parsedNarrative
-			log.Printf("Successfully parsed analysis for asset %s: score=%d, narrative=%s", assetID, score, narrative)
-		}
-	}
-	
-	if embeddingErr != nil {
-		log.Printf("Failed to generate embedding: %v", embeddingErr)
-	} else {
-		log.Printf("Received embedding with %d dimensions", len(embedding))
-		
-		// Perform similarity search with the new embedding
-		distances, assetIDs, searchErr := globalIndexManager.Search(embedding, 5)
-		if searchErr != nil {
-			log.Printf("Failed to perform similarity search: %v", searchErr)
-		} else {
-			log.Printf("Similarity search found asset IDs: %v with distances: %v", assetIDs, distances)
-		}
-		
-		// Add the new embedding to the live index
-		addErr := globalIndexManager.Add(assetID, embedding)
-		if addErr != nil {
-			log.Printf("Failed to add embedding to index for asset %s: %v", assetID, addErr)
-		} else {
-			log.Printf("Successfully added embedding to index for asset %s", assetID)
-		}
-	}
-	
-	// Only save asset if both operations succeeded
-	if analysisErr == nil && embeddingErr == nil {
-		// Create new Asset struct
-		asset := &Asset{
-			ID:               assetID,
-			UserID:           userID,
-			Status:           "completed",
-			CreatedAt:        time.Now(),
-			RawAnalysis:      analysisText,
-			OriginalityScore: score,
-			Narrative:        narrative,
-			Embedding:        embedding,
-		}
-		
-		// Save asset to Firestore
-		if err := saveAsset(ctx, asset); err != nil {
-			log.Printf("Failed to save asset %s to Firestore: %v", assetID, err)
-		} else {
-			log.Printf("Successfully saved asset %s to Firestore", assetID)
-			
-			// Generate and save certificate after successful asset save
-			log.Printf("Generating verifiable credential certificate for asset %s", assetID)
-			credential, err := certificate.Generate(asset)
-			if err != nil {
-				log.Printf("Failed to generate certificate for asset %s: %v", assetID, err)
-			} else {
-				// Marshal the credential to nicely formatted JSON
-				certificateJSON, err := json.MarshalIndent(credential, "", "  ")
-				if err != nil {
-					log.Printf("Failed to marshal certificate to JSON for asset %s: %v", assetID, err)
-				} else {
-					// Save the certificate to GCS
-											if err := saveJSONCertificate(ctx, assetID, certificateJSON); err != nil {
-							log.Printf("Failed to save certificate to GCS for asset %s: %v", assetID, err)
-						} else {
-							log.Printf("Successfully generated and saved certificate for asset %s", assetID)
-							
-							// Queue certificate hash in Trillian
-							trillianLogID := os.Getenv("TRILLIAN_LOG_ID")
-							trillianLogServerAddr := os.Getenv("TRILLIAN_LOG_SERVER_ADDR")
-							
-							if trillianLogID != "" && trillianLogServerAddr != "" {
-								// Parse log ID from string to int64
-								logID, parseErr := strconv.ParseInt(trillianLogID, 10, 64)
-								if parseErr != nil {
-									log.Printf("Failed to parse TRILLIAN_LOG_ID for asset %s: %v", assetID, parseErr)
-								} else {
-									// Create SHA256 hash of certificate JSON
-									hash := sha256.Sum256(certificateJSON)
-									leafValue := hash[:]
-									
-									// Queue the leaf in Trillian
-									leafIndex, err := queueLeafInTrillian(ctx, logID, trillianLogServerAddr, leafValue)
-									if err != nil {
-										log.Printf("Failed to queue certificate hash in Trillian for asset %s: %v", assetID, err)
-									} else {
-										log.Printf("Successfully queued certificate hash in Trillian for asset %s with leaf index %d", assetID, leafIndex)
-										
-										// Get project ID from environment for Firestore client
-										projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
-										if projectID == "" {
-											log.Printf("GOOGLE_CLOUD_PROJECT environment variable not set, cannot update Trillian leaf index for asset %s", assetID)
-										} else {
-											// Initialize Firestore client
-											firestoreClient, err := firestore.NewClient(ctx, projectID)
-											if err != nil {
-												log.Printf("Failed to create Firestore client for updating asset %s: %v", assetID, err)
-											} else {
-												defer firestoreClient.Close()
-												
-												// Update the TrillianLeafIndex field directly in Firestore
-												_, updateErr := firestoreClient.Collection("assets").Doc(assetID).Update(ctx, []firestore.Update{
-													{Path: "trillian_leaf_index", Value: leafIndex},
-												})
-												if updateErr != nil {
-													log.Printf("Failed to update Trillian leaf index in Firestore for asset %s: %v", assetID, updateErr)
-												} else {
-													log.Printf("Successfully saved Trillian leaf index %d to Firestore for asset %s", leafIndex, assetID)
-												}
-											}
-										}
-									}
-								}
-							} else {
-								log.Printf("Skipping Trillian integration for asset %s: TRILLIAN_LOG_ID or TRILLIAN_LOG_SERVER_ADDR not configured", assetID)
-							}
-							
-							// Generate and save badge
-						log.Printf("Generating badge for asset %s with score %d", assetID, asset.OriginalityScore)
-						badgeData, err := certificate.GenerateBadge(asset.OriginalityScore)
-						if err != nil {
-							log.Printf("Failed to generate badge for asset %s: %v", assetID, err)
-						} else {
-							// Save the badge to GCS
-							if err := savePNGBadge(ctx, assetID, badgeData); err != nil {
-								log.Printf("Failed to save badge to GCS for asset %s: %v", assetID, err)
-							} else {
-								log.Printf("Successfully generated and saved badge for asset %s", assetID)
-							}
-						}
-					}
-				}
-			}
-		}
-	} else {
-		log.Printf("Skipping asset save due to processing errors for asset_id=%s", assetID)
+	return imageData, nil
+}
+
+// defaultAnalysisModel is the Gemini model used for authenticity analysis
+const defaultAnalysisModel = "gemini-1.5-flash"
+
+// analysisModel returns the Gemini model name from ANALYSIS_MODEL, or the default
+func analysisModel() string {
+	if model := os.Getenv("ANALYSIS_MODEL"); model != "" {
+		return model
 	}
-	
-	log.Printf("Image processing completed for user_id=%s, asset_id=%s", userID, assetID)
+	return defaultAnalysisModel
 }
 
-// getAuthenticityAnalysis accepts image data as a byte slice and returns analysis text and an error
-func getAuthenticityAnalysis(imageData []byte) (string, error) {
+// getAuthenticityAnalysis accepts image data as a byte slice with its MIME type and returns analysis text, the model version that produced it, and an error
+func getAuthenticityAnalysis(imageData []byte, mimeType string) (string, string, error) {
 	ctx := context.Background()
 	
 	// 1. Initialize the Vertex AI client for the correct GCP project and region
@@ -394,13 +400,13 @@ func getAuthenticityAnalysis(imageData []byte) (string, error) {
 	// Get project ID from environment
 	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
 	if projectID == "" {
-		return "", fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+		return "", "", fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
 	}
 	
 	// Initialize the AI Platform service (equivalent to generativelanguage.NewPredictionClient)
-	client, err := aiplatform.NewService(ctx, option.WithScopes(aiplatform.CloudPlatformScope))
+	client, err := vertex.NewService(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to create AI Platform service: %v", err)
+		return "", "", fmt.Errorf("failed to create AI Platform service: %v", err)
 	}
 	
 	// 2. Define the endpoint for the Gemini Pro Vision model
@@ -421,7 +427,7 @@ func getAuthenticityAnalysis(imageData []byte) (string, error) {
 					},
 					{
 						"inline_data": map[string]interface{}{
-							"mime_type": "image/jpeg",
+							"mime_type": mimeType,
 							"data":      imageBase64,
 						},
 					},
@@ -439,16 +445,16 @@ func getAuthenticityAnalysis(imageData []byte) (string, error) {
 	// Convert payload to JSON
 	payloadBytes, err := json.Marshal(requestPayload)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request payload: %v", err)
+		return "", "", fmt.Errorf("failed to marshal request payload: %v", err)
 	}
 	
 	// Create the API request
 	location := "us-central1"
-	model := "gemini-1.5-flash"
+	model := analysisModel()
 	
 	req := &aiplatform.GoogleCloudAiplatformV1GenerateContentRequest{}
 	if err := json.Unmarshal(payloadBytes, req); err != nil {
-		return "", fmt.Errorf("failed to unmarshal request: %v", err)
+		return "", "", fmt.Errorf("failed to unmarshal request: %v", err)
 	}
 	
 	// 5. Call the Predict method on the Gemini client with this request
@@ -459,38 +465,44 @@ func getAuthenticityAnalysis(imageData []byte) (string, error) {
 	
 	// 7. Handle and return any errors from the API call
 	if err != nil {
-		return "", fmt.Errorf("API call failed: %v", err)
+		return "", "", fmt.Errorf("API call failed: %v", err)
 	}
 	
 	// 6. If the call is successful, extract the text content from the first candidate in the response
 	if resp == nil {
-		return "", fmt.Errorf("received nil response from API")
+		return "", "", fmt.Errorf("received nil response from API")
 	}
 	
 	if len(resp.Candidates) == 0 {
-		return "", fmt.Errorf("no candidates in response")
+		return "", "", fmt.Errorf("no candidates in response")
 	}
 	
 	candidate := resp.Candidates[0]
 	if candidate.Content == nil {
-		return "", fmt.Errorf("candidate has no content")
+		return "", "", fmt.Errorf("candidate has no content")
 	}
 	
 	if len(candidate.Content.Parts) == 0 {
-		return "", fmt.Errorf("candidate content has no parts")
+		return "", "", fmt.Errorf("candidate content has no parts")
 	}
 	
 	// Extract text from the first part
 	part := candidate.Content.Parts[0]
 	if part.Text == "" {
-		return "", fmt.Errorf("candidate part has no text")
+		return "", "", fmt.Errorf("candidate part has no text")
+	}
+	
+	// Record the exact version that answered, falling back to the configured model name
+	modelVersion := resp.ModelVersion
+	if modelVersion == "" {
+		modelVersion = model
 	}
 	
-	return part.Text, nil
+	return part.Text, modelVersion, nil
 }
 
 // getEmbedding accepts image data as a byte slice and returns embedding vector and an error
-func getEmbedding(imageData []byte) ([]float32, error) {
+func getEmbedding(imageData []byte, model string) ([]float32, error) {
 	ctx := context.Background()
 	
 	// 1. Initialize the Vertex AI client for the correct GCP project and region
@@ -503,14 +515,14 @@ func getEmbedding(imageData []byte) ([]float32, error) {
 	}
 	
 	// Initialize the AI Platform service
-	client, err := aiplatform.NewService(ctx, option.WithScopes(aiplatform.CloudPlatformScope))
+	client, err := vertex.NewService(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AI Platform service: %v", err)
 	}
 	
 	// 2. The endpoint for the multimodal embedding model is the same (us-central1-aiplatform.googleapis.com:443)
 	
-	// 3. Construct a request to the multimodalembedding@001 model
+	// 3. Construct a request to the embedding model
 	// The request contains the image part but does not require a text prompt
 	imageBase64 := base64.StdEncoding.EncodeToString(imageData)
 	
@@ -532,7 +544,6 @@ func getEmbedding(imageData []byte) ([]float32, error) {
 	
 	// Create the API request
 	location := "us-central1"
-	model := "multimodalembedding@001"
 	
 	req := &aiplatform.GoogleCloudAiplatformV1PredictRequest{}
 	if err := json.Unmarshal(payloadBytes, req); err != nil {
@@ -612,8 +623,34 @@ func saveAsset(ctx context.Context, asset *Asset) error {
 	// Get reference to document in assets collection using Asset ID
 	docRef := client.Collection("assets").Doc(asset.ID)
 
-	// Use Set method to write the Asset struct to the document
-	_, err = docRef.Set(ctx, asset)
+	// Write the Asset struct to the document, retrying transient failures. The API stores
+	// the uploader's metadata tags on the pending asset, which the worker never loads, so
+	// they are carried over from the stored document rather than erased by the Set.
+	err = retryFirestoreWrite(ctx, "save of asset "+asset.ID, func() error {
+		return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			toSave := asset
+			if asset.Metadata == nil {
+				docSnap, err := tx.Get(docRef)
+				if err != nil && status.Code(err) != codes.NotFound {
+					return err
+				}
+				if err == nil {
+					var stored struct {
+						Metadata map[string]string `firestore:"metadata"`
+					}
+					if err := docSnap.DataTo(&stored); err != nil {
+						return err
+					}
+					if len(stored.Metadata) > 0 {
+						withMetadata := *asset
+						withMetadata.Metadata = stored.Metadata
+						toSave = &withMetadata
+					}
+				}
+			}
+			return tx.Set(docRef, toSave)
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("failed to save asset to Firestore: %v", err)
 	}
@@ -622,6 +659,61 @@ func saveAsset(ctx context.Context, asset *Asset) error {
 	return nil
 }
 
+// getAsset fetches an asset document from Firestore, returning nil if it does not exist
+func getAsset(ctx context.Context, assetID string) (*Asset, error) {
+	// Get project ID from environment
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return nil, fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+
+	// Initialize Firestore client
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	docSnap, err := client.Collection("assets").Doc(assetID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch asset from Firestore: %v", err)
+	}
+
+	var asset Asset
+	if err := docSnap.DataTo(&asset); err != nil {
+		return nil, fmt.Errorf("failed to parse asset data: %v", err)
+	}
+	return &asset, nil
+}
+
+// updateTrillianLeafIndex records the Trillian leaf index and leaf format on an existing asset document
+func updateTrillianLeafIndex(ctx context.Context, assetID string, leafIndex int64, leafFormat string) error {
+	// Get project ID from environment
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+
+	// Initialize Firestore client
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	_, err = client.Collection("assets").Doc(assetID).Update(ctx, []firestore.Update{
+		{Path: "trillian_leaf_index", Value: leafIndex},
+		{Path: "trillian_leaf_format", Value: leafFormat},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update Trillian leaf index: %v", err)
+	}
+	return nil
+}
+
 // savePNGBadge uploads PNG badge data to Google Cloud Storage
 func savePNGBadge(ctx context.Context, assetID string, data []byte) error {
 	// Initialize Google Cloud Storage client
@@ -639,20 +731,15 @@ func savePNGBadge(ctx context.Context, assetID string, data []byte) error {
 	bucket := client.Bucket(bucketName)
 	object := bucket.Object(objectName)
 
-	// Create a writer to upload the data
-	writer := object.NewWriter(ctx)
-	writer.ContentType = "image/png"
-
-	// Write the PNG data
-	_, err = writer.Write(data)
+	// Upload the PNG data, retrying transient failures with a fresh writer
+	err = writeObject(ctx, "badge of asset "+assetID, func() objectWriter {
+		writer := object.NewWriter(ctx)
+		writer.ContentType = "image/png"
+		applyObjectMetadata(&writer.ObjectAttrs, certificate.BadgeObjects, fmt.Sprintf("proofpix-badge-%s.png", assetID))
+		return writer
+	}, data)
 	if err != nil {
-		writer.Close()
-		return fmt.Errorf("failed to write badge data: %v", err)
-	}
-
-	// Close the writer to finalize the upload
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to close storage writer: %v", err)
+		return fmt.Errorf("failed to upload badge data: %v", err)
 	}
 
 	log.Printf("Successfully saved badge for asset %s to GCS bucket %s", assetID, bucketName)
@@ -660,7 +747,7 @@ func savePNGBadge(ctx context.Context, assetID string, data []byte) error {
 }
 
 // saveJSONCertificate uploads JSON certificate data to Google Cloud Storage
-func saveJSONCertificate(ctx context.Context, assetID string, data []byte) error {
+func saveJSONCertificate(ctx context.Context, asset *Asset, data []byte) error {
 	// Initialize Google Cloud Storage client
 	client, err := storage.NewClient(ctx)
 	if err != nil {
@@ -668,31 +755,26 @@ func saveJSONCertificate(ctx context.Context, assetID string, data []byte) error
 	}
 	defer client.Close()
 
-	// Construct object name: certificates/{assetID}.json
+	// Construct object name from CERTIFICATE_PATH_TEMPLATE
 	bucketName := "proofpix-certificates"
-	objectName := fmt.Sprintf("certificates/%s.json", assetID)
+	objectName := certificate.ObjectPath(asset)
 
 	// Get bucket and object reference
 	bucket := client.Bucket(bucketName)
 	object := bucket.Object(objectName)
 
-	// Create a writer to upload the data
-	writer := object.NewWriter(ctx)
-	writer.ContentType = "application/json"
-
-	// Write the JSON data
-	_, err = writer.Write(data)
+	// Upload the JSON data, retrying transient failures with a fresh writer
+	err = writeObject(ctx, "certificate of asset "+asset.ID, func() objectWriter {
+		writer := object.NewWriter(ctx)
+		writer.ContentType = "application/json"
+		applyObjectMetadata(&writer.ObjectAttrs, certificate.CertificateObjects, fmt.Sprintf("proofpix-certificate-%s.json", asset.ID))
+		return writer
+	}, data)
 	if err != nil {
-		writer.Close()
-		return fmt.Errorf("failed to write certificate data: %v", err)
+		return fmt.Errorf("failed to upload certificate data: %v", err)
 	}
 
-	// Close the writer to finalize the upload
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to close storage writer: %v", err)
-	}
-
-	log.Printf("Successfully saved certificate for asset %s to GCS bucket %s", assetID, bucketName)
+	log.Printf("Successfully saved certificate for asset %s to gs://%s/%s", asset.ID, bucketName, objectName)
 	return nil
 }
 
@@ -702,7 +784,7 @@ func queueLeafInTrillian(ctx context.Context, logID int64, logServerAddr string,
 	log.Printf("Establishing gRPC connection to Trillian Log Server at %s", logServerAddr)
 	conn, err := grpc.DialContext(ctx, logServerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
-		return fmt.Errorf("failed to connect to Trillian Log Server at %s: %v", logServerAddr, err)
+		return 0, fmt.Errorf("failed to connect to Trillian Log Server at %s: %v", logServerAddr, err)
 	}
 	
 	// 7. Ensure the gRPC connection is properly closed
@@ -714,7 +796,11 @@ func queueLeafInTrillian(ctx context.Context, logID int64, logServerAddr string,
 	
 	// 2. Create a new trillian.TrillianLogClient using the connection
 	client := trillian.NewTrillianLogClient(conn)
-	
+	return submitLeaf(ctx, client, logID, leafValue)
+}
+
+// submitLeaf queues one leaf value with an open Trillian client and returns its leaf index
+func submitLeaf(ctx context.Context, client trillian.TrillianLogClient, logID int64, leafValue []byte) (int64, error) {
 	// 3. Create the trillian.LogLeaf that will be submitted
 	logLeaf := &trillian.LogLeaf{
 		LeafValue: leafValue,
@@ -730,20 +816,20 @@ func queueLeafInTrillian(ctx context.Context, logID int64, logServerAddr string,
 	log.Printf("Submitting leaf to Trillian log %d", logID)
 	response, err := client.QueueLeaf(ctx, request)
 	if err != nil {
-		return fmt.Errorf("failed to queue leaf in Trillian log %d: %v", logID, err)
+		return 0, fmt.Errorf("failed to queue leaf in Trillian log %d: %v", logID, err)
 	}
 	
 	// 6. Check the response. If the result is not OK or an error occurs, return a descriptive error
 	if response == nil {
-		return fmt.Errorf("received nil response from Trillian QueueLeaf call")
+		return 0, fmt.Errorf("received nil response from Trillian QueueLeaf call")
 	}
 	
 	if response.QueuedLeaf == nil {
-		return fmt.Errorf("QueueLeaf response does not contain a queued leaf")
+		return 0, fmt.Errorf("QueueLeaf response does not contain a queued leaf")
 	}
 	
 	if response.QueuedLeaf.Status == nil {
-		return fmt.Errorf("QueueLeaf response does not contain leaf status")
+		return 0, fmt.Errorf("QueueLeaf response does not contain leaf status")
 	}
 	
 	// Check if the status code indicates success (typically google.rpc.Code.OK = 0)
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"proofpix/internal/certificate"
+)
+
+func TestProcessImage_ModelVersionPropagatesToCredential(t *testing.T) {
+	stubServices(t)
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		return "Confidence Score: 0.95\n\nJustification: Natural lighting.", "gemini-1.5-flash-002", nil
+	}
+
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+	var certificateJSON []byte
+	storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error {
+		certificateJSON = data
+		return nil
+	}
+
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+
+	if saved == nil || saved.ModelVersion != "gemini-1.5-flash-002" {
+		t.Fatalf("Expected asset to record model version gemini-1.5-flash-002, but got %+v", saved)
+	}
+	var credential certificate.VerifiableCredential
+	if err := json.Unmarshal(certificateJSON, &credential); err != nil {
+		t.Fatalf("Failed to decode stored certificate: %v", err)
+	}
+	if credential.CredentialSubject.ModelVersion != "gemini-1.5-flash-002" {
+		t.Errorf("Expected credential model version gemini-1.5-flash-002, but got %q", credential.CredentialSubject.ModelVersion)
+	}
+}
+
+func TestAnalysisModel(t *testing.T) {
+	t.Setenv("ANALYSIS_MODEL", "")
+	if got := analysisModel(); got != defaultAnalysisModel {
+		t.Errorf("Expected default model %q, but got %q", defaultAnalysisModel, got)
+	}
+	t.Setenv("ANALYSIS_MODEL", "gemini-2.0-flash")
+	if got := analysisModel(); got != "gemini-2.0-flash" {
+		t.Errorf("Expected configured model gemini-2.0-flash, but got %q", got)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"cloud.google.com/go/storage"
+
+	"proofpix/internal/certificate"
+)
+
+// applyObjectMetadata sets the cache-control and storage class configured for a kind
+// of object, certificate.CertificateObjects or certificate.BadgeObjects, on an
+// upload's attributes, with a Content-Disposition naming the downloaded file.
+// Invalid settings are logged and the defaults used instead.
+func applyObjectMetadata(attrs *storage.ObjectAttrs, kind, filename string) {
+	meta, err := certificate.ObjectMetadataFromEnv(kind)
+	if err != nil {
+		log.Printf("%v, using defaults", err)
+		meta = certificate.DefaultObjectMetadata(kind)
+	}
+	attrs.CacheControl = meta.CacheControl
+	attrs.StorageClass = meta.StorageClass
+	attrs.ContentDisposition = fmt.Sprintf("inline; filename=%q", filename)
+}
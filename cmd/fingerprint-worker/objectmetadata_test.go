@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+
+	"proofpix/internal/certificate"
+)
+
+func TestApplyObjectMetadata(t *testing.T) {
+	t.Setenv("CERTIFICATE_OBJECT_CACHE_CONTROL", "private, max-age=60")
+	t.Setenv("CERTIFICATE_OBJECT_STORAGE_CLASS", "coldline")
+	t.Setenv("BADGE_OBJECT_CACHE_CONTROL", "forever")
+	t.Setenv("BADGE_OBJECT_STORAGE_CLASS", "")
+
+	client, err := storage.NewClient(context.Background(), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create storage client: %v", err)
+	}
+	defer client.Close()
+	object := client.Bucket("proofpix-certificates").Object("certificates/asset-1.json")
+
+	testCases := []struct {
+		name                string
+		kind                string
+		filename            string
+		expectedCache       string
+		expectedClass       string
+		expectedDisposition string
+	}{
+		{
+			name:                "Configured certificate metadata",
+			kind:                certificate.CertificateObjects,
+			filename:            "proofpix-certificate-asset-1.json",
+			expectedCache:       "private, max-age=60",
+			expectedClass:       "COLDLINE",
+			expectedDisposition: `inline; filename="proofpix-certificate-asset-1.json"`,
+		},
+		{
+			name:                "Invalid badge metadata falls back to defaults",
+			kind:                certificate.BadgeObjects,
+			filename:            "proofpix-badge-asset-1.png",
+			expectedCache:       certificate.DefaultBadgeCacheControl,
+			expectedDisposition: `inline; filename="proofpix-badge-asset-1.png"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			writer := object.NewWriter(context.Background())
+			applyObjectMetadata(&writer.ObjectAttrs, tc.kind, tc.filename)
+
+			if writer.CacheControl != tc.expectedCache {
+				t.Errorf("Expected Cache-Control %q, but got %q", tc.expectedCache, writer.CacheControl)
+			}
+			if writer.StorageClass != tc.expectedClass {
+				t.Errorf("Expected storage class %q, but got %q", tc.expectedClass, writer.StorageClass)
+			}
+			if writer.ContentDisposition != tc.expectedDisposition {
+				t.Errorf("Expected Content-Disposition %q, but got %q", tc.expectedDisposition, writer.ContentDisposition)
+			}
+		})
+	}
+}
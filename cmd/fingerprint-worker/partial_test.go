@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestProcessImage_PartialSuccess(t *testing.T) {
+	testCases := []struct {
+		name                    string
+		analysisErr             error
+		embeddingErr            error
+		expectedAnalysisFailed  bool
+		expectedEmbeddingFailed bool
+	}{
+		{
+			name:                    "Analysis only success",
+			embeddingErr:            fmt.Errorf("embedding quota exceeded"),
+			expectedEmbeddingFailed: true,
+		},
+		{
+			name:                   "Embedding only success",
+			analysisErr:            fmt.Errorf("analysis quota exceeded"),
+			expectedAnalysisFailed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+				if tc.analysisErr != nil {
+					return "", "", tc.analysisErr
+				}
+				return "Confidence Score: 0.95\n\nJustification: Natural lighting.", "gemini-1.5-flash", nil
+			}
+			embedImage = func(imageData []byte, model string) ([]float32, error) {
+				if tc.embeddingErr != nil {
+					return nil, tc.embeddingErr
+				}
+				return []float32{0.1, 0.2, 0.3}, nil
+			}
+
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return nil
+			}
+			var certified bool
+			storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error {
+				certified = true
+				return nil
+			}
+
+			processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+
+			if saved == nil {
+				t.Fatalf("Expected partial asset to be saved, but nothing was saved")
+			}
+			if saved.Status != models.StatusPartial {
+				t.Errorf("Expected status %q, but got %q", models.StatusPartial, saved.Status)
+			}
+			if saved.AnalysisFailed != tc.expectedAnalysisFailed {
+				t.Errorf("Expected AnalysisFailed to be %t, but got %t", tc.expectedAnalysisFailed, saved.AnalysisFailed)
+			}
+			if saved.EmbeddingFailed != tc.expectedEmbeddingFailed {
+				t.Errorf("Expected EmbeddingFailed to be %t, but got %t", tc.expectedEmbeddingFailed, saved.EmbeddingFailed)
+			}
+			if !tc.expectedAnalysisFailed && saved.OriginalityScore != 95 {
+				t.Errorf("Expected score 95 to be kept, but got %d", saved.OriginalityScore)
+			}
+			if !tc.expectedEmbeddingFailed && len(saved.Embedding) != 3 {
+				t.Errorf("Expected embedding to be kept, but got %v", saved.Embedding)
+			}
+			if certified {
+				t.Errorf("Expected no certificate for a partial asset")
+			}
+		})
+	}
+}
+
+func TestProcessImage_RetryFillsMissingStage(t *testing.T) {
+	stubServices(t)
+
+	// An earlier run saved the analysis but not the embedding
+	loadAsset = func(ctx context.Context, assetID string) (*Asset, error) {
+		return &Asset{
+			ID:               assetID,
+			UserID:           "user-1",
+			Status:           models.StatusPartial,
+			RawAnalysis:      "Confidence Score: 0.80\n\nJustification: Earlier run.",
+			OriginalityScore: 80,
+			Narrative:        "Earlier run.",
+			EmbeddingFailed:  true,
+		}, nil
+	}
+
+	var analyzeCalls, embedCalls int32
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		atomic.AddInt32(&analyzeCalls, 1)
+		return "", "", fmt.Errorf("analysis should not be re-run")
+	}
+	embedImage = func(imageData []byte, model string) ([]float32, error) {
+		atomic.AddInt32(&embedCalls, 1)
+		return []float32{0.1, 0.2, 0.3}, nil
+	}
+
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+
+	if analyzeCalls != 0 {
+		t.Errorf("Expected analysis not to be re-run, but got %d calls", analyzeCalls)
+	}
+	if embedCalls != 1 {
+		t.Errorf("Expected embedding to be generated once, but got %d calls", embedCalls)
+	}
+	if saved == nil {
+		t.Fatalf("Expected asset to be saved, but nothing was saved")
+	}
+	if saved.Status != models.StatusCompleted {
+		t.Errorf("Expected status %q, but got %q", models.StatusCompleted, saved.Status)
+	}
+	if saved.OriginalityScore != 80 || saved.Narrative != "Earlier run." {
+		t.Errorf("Expected earlier analysis to be kept, but got %d / %q", saved.OriginalityScore, saved.Narrative)
+	}
+	if saved.AnalysisFailed || saved.EmbeddingFailed {
+		t.Errorf("Expected no failure flags, but got analysis=%t embedding=%t", saved.AnalysisFailed, saved.EmbeddingFailed)
+	}
+}
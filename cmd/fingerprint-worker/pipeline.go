@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// processOptions are the per-request settings from the /process body
+type processOptions struct {
+	// Bucket is the allowlisted bucket holding the upload
+	Bucket string
+	// SkipAnchoring certifies the asset without queueing it in Trillian
+	SkipAnchoring bool
+	// Visibility is the requested asset visibility, empty when none was requested
+	Visibility string
+
+	// imageData is an image already fetched by the caller, which the download
+	// stage uses instead of reading the upload back from the bucket
+	imageData []byte
+}
+
+// pipelineState carries the data produced by each stage to the stages after it
+type pipelineState struct {
+	userID  string
+	assetID string
+	bucket  string
+
+	skipAnchoring bool
+	visibility    string
+
+	imageData []byte
+	imageHash string
+	// mimeType is the image or video format detected from imageData
+	mimeType string
+	// frames are the keyframes of a video upload, analyzed in place of the image
+	frames []keyframe
+	// thumbnailURL is the stored preview of the image, if one was produced
+	thumbnailURL string
+	// shortCode is the code reserved for the asset, if one could be
+	shortCode string
+
+	// Earlier results that let analysis or embedding be skipped
+	cached   *CachedAnalysis
+	previous *Asset
+
+	analysisText   string
+	analysisErr    error
+	analysisReused bool
+	modelVersion   string
+	score          int
+	narrative      string
+	// narrativeLanguage tags the narrative's language; narrativeOriginal keeps a
+	// narrative translated to English in its original narrativeTranslatedFrom
+	narrativeLanguage       string
+	narrativeOriginal       string
+	narrativeTranslatedFrom string
+	// analysisWarning describes why a stored analysis failed validation
+	analysisWarning string
+
+	embedding       []float32
+	embeddingErr    error
+	embeddingReused bool
+	// embeddingModel is the model that produced embedding
+	embeddingModel string
+	// relatedAsset is the near-duplicate found by the similarity search, if any
+	relatedAsset *models.RelatedAsset
+	// frameResults are the per-keyframe results of a video upload
+	frameResults []models.FrameResult
+
+	asset           *Asset
+	certificateJSON []byte
+	// logged is the certificate leaf queued in Trillian, nil when it was not
+	// queued or is waiting in a batch
+	logged *loggedLeaf
+}
+
+// pipelineStage is one named step of image processing. Returning an error stops
+// the pipeline; failures that should not stop it are recorded by the stage itself.
+type pipelineStage struct {
+	name string
+	run  func(ctx context.Context, p *pipelineState) error
+}
+
+// stageResult records the outcome of a stage that ran
+type stageResult struct {
+	stage    string
+	err      error
+	duration time.Duration
+}
+
+// processingStages is the order in which processImage runs the stages
+var processingStages = []pipelineStage{
+	{name: "download", run: downloadStage},
+	{name: "reuse", run: reuseStage},
+	{name: "budget", run: budgetStage},
+	{name: "analyze", run: analyzeStage},
+	{name: "index", run: indexStage},
+	{name: "thumbnail", run: thumbnailStage},
+	{name: "shortcode", run: shortCodeStage},
+	{name: "save", run: saveStage},
+	{name: "certify", run: certifyStage},
+	{name: "log", run: logStage},
+	{name: "badge", run: badgeStage},
+}
+
+// stagesFrom returns the processing stages starting with the named one
+func stagesFrom(name string) []pipelineStage {
+	for i, stage := range processingStages {
+		if stage.name == name {
+			return processingStages[i:]
+		}
+	}
+	return nil
+}
+
+// runPipeline executes stages in order until one fails, recording a failed event
+// for the asset in that case. It returns the results of the stages that ran.
+func runPipeline(ctx context.Context, p *pipelineState, stages []pipelineStage) []stageResult {
+	results := make([]stageResult, 0, len(stages))
+	for _, stage := range stages {
+		start := time.Now()
+		err := stage.run(ctx, p)
+		results = append(results, stageResult{stage: stage.name, err: err, duration: time.Since(start)})
+		if err != nil {
+			log.Printf("Stage %s failed for asset %s: %v", stage.name, p.assetID, err)
+			recordEvent(ctx, p.assetID, models.StageFailed, err)
+			return results
+		}
+	}
+	recordEvent(ctx, p.assetID, models.StageCompleted, nil)
+	return results
+}
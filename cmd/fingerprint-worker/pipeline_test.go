@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestRunPipeline(t *testing.T) {
+	testCases := []struct {
+		name           string
+		failAt         string
+		expectedRan    []string
+		expectedFinal  string
+		expectedFailed bool
+	}{
+		{
+			name:          "All stages succeed",
+			expectedRan:   []string{"first", "second", "third"},
+			expectedFinal: models.StageCompleted,
+		},
+		{
+			name:           "Failure stops later stages",
+			failAt:         "second",
+			expectedRan:    []string{"first", "second"},
+			expectedFinal:  models.StageFailed,
+			expectedFailed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			var events []models.AssetEvent
+			appendEvent = func(ctx context.Context, assetID string, event models.AssetEvent) error {
+				events = append(events, event)
+				return nil
+			}
+
+			var ran []string
+			stage := func(name string) pipelineStage {
+				return pipelineStage{name: name, run: func(ctx context.Context, p *pipelineState) error {
+					ran = append(ran, name)
+					if name == tc.failAt {
+						return fmt.Errorf("%s broke", name)
+					}
+					return nil
+				}}
+			}
+
+			results := runPipeline(context.Background(), &pipelineState{assetID: "asset-1"},
+				[]pipelineStage{stage("first"), stage("second"), stage("third")})
+
+			if fmt.Sprint(ran) != fmt.Sprint(tc.expectedRan) {
+				t.Errorf("Expected stages %v to run, but got %v", tc.expectedRan, ran)
+			}
+			if len(results) != len(tc.expectedRan) {
+				t.Fatalf("Expected %d results, but got %d", len(tc.expectedRan), len(results))
+			}
+			if last := results[len(results)-1]; (last.err != nil) != tc.expectedFailed {
+				t.Errorf("Expected last result failed=%t, but got error %v", tc.expectedFailed, last.err)
+			}
+			if len(events) != 1 || events[0].Stage != tc.expectedFinal {
+				t.Errorf("Expected a single %q event, but got %+v", tc.expectedFinal, events)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+)
+
+// Default number of Vertex calls of each kind the whole worker may have in flight
+const (
+	defaultAnalysisConcurrency  = 4
+	defaultEmbeddingConcurrency = 8
+)
+
+// callPool bounds how many calls of one kind run at once across all requests
+type callPool struct {
+	slots chan struct{}
+}
+
+func newCallPool(limit int) *callPool {
+	return &callPool{slots: make(chan struct{}, limit)}
+}
+
+// do runs fn once a slot is free. It returns the context error without running fn
+// if ctx is done first.
+func (c *callPool) do(ctx context.Context, fn func()) error {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.slots }()
+	fn()
+	return nil
+}
+
+// Shared Vertex pools. Analysis and embedding have separate quotas, so each gets its
+// own limit; every request draws from the same pools so the limits hold globally.
+var (
+	analysisPool  = newCallPool(defaultAnalysisConcurrency)
+	embeddingPool = newCallPool(defaultEmbeddingConcurrency)
+)
+
+// configureVertexPools sizes the shared pools from VERTEX_ANALYSIS_CONCURRENCY and
+// VERTEX_EMBEDDING_CONCURRENCY
+func configureVertexPools() {
+	analysisPool = newCallPool(concurrencyLimit("VERTEX_ANALYSIS_CONCURRENCY", defaultAnalysisConcurrency))
+	embeddingPool = newCallPool(concurrencyLimit("VERTEX_EMBEDDING_CONCURRENCY", defaultEmbeddingConcurrency))
+	log.Printf("Vertex concurrency limits: analysis=%d, embedding=%d", cap(analysisPool.slots), cap(embeddingPool.slots))
+}
+
+// concurrencyLimit reads a positive limit from the environment, falling back on invalid values
+func concurrencyLimit(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		log.Printf("Invalid %s %q, using default of %d", name, value, fallback)
+		return fallback
+	}
+	return limit
+}
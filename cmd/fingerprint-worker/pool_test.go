@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// inFlight tracks the current and peak number of concurrent calls
+type inFlight struct {
+	current atomic.Int32
+	peak    atomic.Int32
+}
+
+func (f *inFlight) enter() {
+	n := f.current.Add(1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			return
+		}
+	}
+}
+
+func (f *inFlight) leave() { f.current.Add(-1) }
+
+func TestAnalyzeStage_PoolCapsVertexCallsAcrossRequests(t *testing.T) {
+	stubServices(t)
+	origAnalysis, origEmbedding := analysisPool, embeddingPool
+	t.Cleanup(func() { analysisPool, embeddingPool = origAnalysis, origEmbedding })
+	analysisPool = newCallPool(2)
+	embeddingPool = newCallPool(3)
+
+	var analyses, embeddings inFlight
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		analyses.enter()
+		defer analyses.leave()
+		time.Sleep(10 * time.Millisecond)
+		return "Confidence Score: 0.90\n\nJustification: Natural.", "test-model", nil
+	}
+	embedImage = func(imageData []byte, model string) ([]float32, error) {
+		embeddings.enter()
+		defer embeddings.leave()
+		time.Sleep(10 * time.Millisecond)
+		return []float32{1}, nil
+	}
+
+	// Many simultaneous requests, each running its own analyze stage
+	const requests = 12
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := &pipelineState{assetID: "asset", imageData: []byte("image-bytes")}
+			if err := analyzeStage(context.Background(), p); err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := analyses.peak.Load(); peak > 2 {
+		t.Errorf("Expected at most 2 concurrent analysis calls, but got %d", peak)
+	}
+	if peak := embeddings.peak.Load(); peak > 3 {
+		t.Errorf("Expected at most 3 concurrent embedding calls, but got %d", peak)
+	}
+	if analyses.peak.Load() < 2 {
+		t.Errorf("Expected analysis calls to overlap up to the limit, but peak was %d", analyses.peak.Load())
+	}
+}
+
+func TestCallPool_CanceledWhileWaiting(t *testing.T) {
+	pool := newCallPool(1)
+	release := make(chan struct{})
+	go pool.do(context.Background(), func() { <-release })
+	defer close(release)
+
+	// Wait until the only slot is taken
+	for len(pool.slots) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ran := false
+	err := pool.do(ctx, func() { ran = true })
+	if err != context.DeadlineExceeded || ran {
+		t.Errorf("Expected DeadlineExceeded without running, but got err=%v ran=%t", err, ran)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected int
+	}{
+		{name: "Unset", value: "", expected: 4},
+		{name: "Valid", value: "10", expected: 10},
+		{name: "Zero", value: "0", expected: 4},
+		{name: "Invalid", value: "many", expected: 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("VERTEX_ANALYSIS_CONCURRENCY", tc.value)
+			if got := concurrencyLimit("VERTEX_ANALYSIS_CONCURRENCY", 4); got != tc.expected {
+				t.Errorf("Expected %d, but got %d", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/models"
+)
+
+// reapHandler removes soft-deleted assets from the live index and purges the
+// ones whose retention period has elapsed. It is intended to be triggered on a
+// schedule (e.g. Cloud Scheduler).
+func reapHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	removed, purged, err := reapDeletedAssets(r.Context(), time.Now().UTC(), models.RetentionPeriod())
+	if err != nil {
+		log.Printf("Failed to reap deleted assets: %v", err)
+		http.Error(w, "Failed to reap deleted assets", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int{
+		"removed_from_index": removed,
+		"purged":             purged,
+	})
+}
+
+// listDeletedAssets returns every soft-deleted asset. Tests replace it.
+var listDeletedAssets = queryDeletedAssets
+
+// purgeDeletedAsset permanently deletes a soft-deleted asset. Tests replace it.
+var purgeDeletedAsset = purgeAsset
+
+// reapDeletedAssets removes soft-deleted assets from search and purges expired ones
+func reapDeletedAssets(ctx context.Context, now time.Time, retention time.Duration) (removed, purged int, err error) {
+	assets, err := listDeletedAssets(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, asset := range assets {
+		// Deleted assets must not show up in similarity search, even during the grace
+		// period. The API removes them on delete; this catches any it could not reach.
+		globalIndexManager.Remove(asset.ID)
+		removed++
+
+		if !asset.PurgeEligible(now, retention) {
+			continue
+		}
+		if err := purgeDeletedAsset(ctx, asset); err != nil {
+			log.Printf("Failed to purge asset %s: %v", asset.ID, err)
+			continue
+		}
+		log.Printf("Purged asset %s deleted at %v", asset.ID, asset.DeletedAt)
+		purged++
+	}
+
+	return removed, purged, nil
+}
+
+// queryDeletedAssets reads the soft-deleted assets from Firestore, skipping unreadable ones
+func queryDeletedAssets(ctx context.Context) ([]*Asset, error) {
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return nil, fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	iter := client.Collection("assets").Where("status", "==", models.StatusDeleted).Documents(ctx)
+	defer iter.Stop()
+
+	var assets []*Asset
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return assets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var asset Asset
+		if err := doc.DataTo(&asset); err != nil {
+			log.Printf("Skipping unreadable deleted asset %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		asset.ID = doc.Ref.ID
+		assets = append(assets, &asset)
+	}
+}
+
+// storedObject names a GCS object written for an asset
+type storedObject struct {
+	bucket, name string
+}
+
+// uploadBucketOf returns the bucket the asset's original upload was read from
+func uploadBucketOf(asset *Asset) string {
+	if asset.UploadBucket != "" {
+		return asset.UploadBucket
+	}
+	return defaultUploadBucket
+}
+
+// purgeObjects returns every GCS object the pipeline may have written for the asset.
+// Objects that were never written are skipped by the purge.
+func purgeObjects(asset *Asset) []storedObject {
+	objects := []storedObject{
+		{bucket: uploadBucketOf(asset), name: fmt.Sprintf("uploads/%s/%s.jpg", asset.UserID, asset.ID)},
+		{bucket: "proofpix-badges", name: fmt.Sprintf("badges/%s.png", asset.ID)},
+		{bucket: thumbnailBucket(), name: fmt.Sprintf("thumbnails/%s.jpg", asset.ID)},
+	}
+	for _, objectName := range certificate.ObjectPaths(asset) {
+		objects = append(objects, storedObject{bucket: "proofpix-certificates", name: objectName})
+	}
+	// Credentials replaced by regeneration, recorded under the path they were kept at
+	for _, revision := range asset.CredentialHistory {
+		objects = append(objects, storedObject{bucket: "proofpix-certificates", name: revision.ObjectPath})
+	}
+	return objects
+}
+
+// purgeAsset permanently deletes an asset's stored artifacts and its Firestore documents
+func purgeAsset(ctx context.Context, asset *Asset) error {
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	storageClient, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	objects := purgeObjects(asset)
+	// A replaced credential is archived before the asset records it, so one whose
+	// record was never saved is only found by listing the history folder
+	history := storageClient.Bucket("proofpix-certificates").Objects(ctx, &storage.Query{Prefix: certificate.HistoryPrefix(asset)})
+	for {
+		attrs, err := history.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list credential history: %v", err)
+		}
+		objects = append(objects, storedObject{bucket: "proofpix-certificates", name: attrs.Name})
+	}
+
+	for _, object := range objects {
+		err := storageClient.Bucket(object.bucket).Object(object.name).Delete(ctx)
+		if err != nil && err != storage.ErrObjectNotExist {
+			return fmt.Errorf("failed to delete gs://%s/%s: %v", object.bucket, object.name, err)
+		}
+	}
+
+	// Free the asset's short codes, including any claimed by a run whose save failed
+	codes := client.Collection(shortCodesCollection).Where("asset_id", "==", asset.ID).Documents(ctx)
+	defer codes.Stop()
+	for {
+		code, err := codes.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list short codes: %v", err)
+		}
+		if _, err := code.Ref.Delete(ctx); err != nil {
+			return fmt.Errorf("failed to delete short code %s: %v", code.Ref.ID, err)
+		}
+	}
+
+	// Delete the audit trail before the asset document itself
+	docRef := client.Collection("assets").Doc(asset.ID)
+	events := docRef.Collection("events").Documents(ctx)
+	defer events.Stop()
+	for {
+		event, err := events.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list events: %v", err)
+		}
+		if _, err := event.Ref.Delete(ctx); err != nil {
+			return fmt.Errorf("failed to delete event %s: %v", event.Ref.ID, err)
+		}
+	}
+
+	if _, err := docRef.Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete asset document: %v", err)
+	}
+	return nil
+}
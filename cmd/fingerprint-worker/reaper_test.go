@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/index"
+	"proofpix/internal/models"
+)
+
+// loadTestIndex loads an index holding a zero vector for each asset into globalIndexManager
+func loadTestIndex(t *testing.T, assetIDs ...string) {
+	t.Helper()
+	ids := map[string]string{}
+	for i, assetID := range assetIDs {
+		ids[strconv.Itoa(i)] = assetID
+	}
+	labels, err := json.Marshal(map[string]interface{}{"ids": ids})
+	if err != nil {
+		t.Fatalf("Failed to encode labels: %v", err)
+	}
+	store := &memoryStore{objects: map[string][]byte{
+		index.LegacyObject:   serializedIndex(t, len(assetIDs)),
+		"latest.labels.json": labels,
+	}}
+	globalIndexManager = &index.IndexManager{}
+	if err := globalIndexManager.LoadSnapshot(context.Background(), store); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+}
+
+// searchableAssets returns the sorted IDs of every asset search can return
+func searchableAssets(t *testing.T) []string {
+	t.Helper()
+	_, assetIDs, err := globalIndexManager.Search(make([]float32, index.EmbeddingDimension), 10)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	sort.Strings(assetIDs)
+	return assetIDs
+}
+
+func TestReapDeletedAssets(t *testing.T) {
+	stubServices(t)
+	loadTestIndex(t, "expired", "recent", "live")
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	retention := 30 * 24 * time.Hour
+
+	listDeletedAssets = func(ctx context.Context) ([]*Asset, error) {
+		return []*Asset{
+			{ID: "expired", Status: models.StatusDeleted, DeletedAt: now.Add(-31 * 24 * time.Hour)},
+			{ID: "recent", Status: models.StatusDeleted, DeletedAt: now.Add(-24 * time.Hour)},
+			{ID: "unpurgeable", Status: models.StatusDeleted, DeletedAt: now.Add(-40 * 24 * time.Hour)},
+		}, nil
+	}
+	var purgedIDs []string
+	purgeDeletedAsset = func(ctx context.Context, asset *Asset) error {
+		if asset.ID == "unpurgeable" {
+			return errors.New("storage unavailable")
+		}
+		purgedIDs = append(purgedIDs, asset.ID)
+		return nil
+	}
+
+	removed, purged, err := reapDeletedAssets(context.Background(), now, retention)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if removed != 3 || purged != 1 {
+		t.Errorf("Expected 3 removed and 1 purged, but got %d and %d", removed, purged)
+	}
+	if !reflect.DeepEqual(purgedIDs, []string{"expired"}) {
+		t.Errorf("Expected only the expired asset to be purged, but got %v", purgedIDs)
+	}
+	if searchable := searchableAssets(t); !reflect.DeepEqual(searchable, []string{"live"}) {
+		t.Errorf("Expected only the live asset to stay searchable, but got %v", searchable)
+	}
+}
+
+func TestPurgeObjects(t *testing.T) {
+	t.Setenv("THUMBNAIL_BUCKET", "thumbs")
+	asset := &Asset{ID: "asset-1", UserID: "user-1", CredentialHistory: []models.CredentialRevision{
+		{ObjectPath: "certificates/asset-1/history/20240101T000000.000000000Z.json"},
+	}}
+
+	expected := []storedObject{
+		{bucket: "proofpix-assets-upload", name: "uploads/user-1/asset-1.jpg"},
+		{bucket: "proofpix-badges", name: "badges/asset-1.png"},
+		{bucket: "thumbs", name: "thumbnails/asset-1.jpg"},
+	}
+	for _, objectName := range certificate.ObjectPaths(asset) {
+		expected = append(expected, storedObject{bucket: "proofpix-certificates", name: objectName})
+	}
+	expected = append(expected, storedObject{bucket: "proofpix-certificates", name: "certificates/asset-1/history/20240101T000000.000000000Z.json"})
+	if objects := purgeObjects(asset); !reflect.DeepEqual(objects, expected) {
+		t.Errorf("Expected the purge to delete %v, but got %v", expected, objects)
+	}
+}
+
+func TestPurgeObjects_UploadBucket(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bucket   string
+		expected string
+	}{
+		{name: "Recorded bucket", bucket: "partner-uploads", expected: "partner-uploads"},
+		{name: "Asset saved before the bucket was recorded", expected: defaultUploadBucket},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := purgeObjects(&Asset{ID: "asset-1", UserID: "user-1", UploadBucket: tc.bucket})
+			if upload := objects[0]; upload.bucket != tc.expected || upload.name != "uploads/user-1/asset-1.jpg" {
+				t.Errorf("Expected the upload to be purged from %s, but got %+v", tc.expected, upload)
+			}
+		})
+	}
+}
+
+func TestReapDeletedAssets_ListFails(t *testing.T) {
+	stubServices(t)
+	listDeletedAssets = func(ctx context.Context) ([]*Asset, error) {
+		return nil, errors.New("firestore unavailable")
+	}
+
+	rec := httptest.NewRecorder()
+	reapHandler(rec, httptest.NewRequest(http.MethodPost, "/reap", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, but got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestIndexRemoveAndRestoreHandlers(t *testing.T) {
+	stubServices(t)
+	t.Setenv("INDEX_DELTA_SECRET", "s3cret")
+	loadTestIndex(t, "asset-1", "asset-2")
+	var removals, deltas []string
+	publishIndexRemoval = func(assetID string) { removals = append(removals, assetID) }
+	publishIndexDelta = func(assetID string, vector []float32) { deltas = append(deltas, assetID) }
+	readIndexVector = func(ctx context.Context, assetID string) ([]float32, error) {
+		if assetID != "asset-1" {
+			return nil, index.ErrAssetNotIndexed
+		}
+		return make([]float32, index.EmbeddingDimension), nil
+	}
+
+	post := func(path, secret string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if secret != "" {
+			req.Header.Set(indexDeltaSecretHeader, secret)
+		}
+		rec := httptest.NewRecorder()
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /index/assets/{id}/remove", indexRemoveHandler)
+		mux.HandleFunc("POST /index/assets/{id}/restore", indexRestoreHandler)
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("/index/assets/asset-1/remove", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without the secret, but got %d", http.StatusUnauthorized, code)
+	}
+
+	// Deleting drops the asset from search here and on the peers
+	if code := post("/index/assets/asset-1/remove", "s3cret"); code != http.StatusOK {
+		t.Fatalf("Expected status %d from remove, but got %d", http.StatusOK, code)
+	}
+	if searchable := searchableAssets(t); !reflect.DeepEqual(searchable, []string{"asset-2"}) {
+		t.Errorf("Expected the removed asset to leave search, but got %v", searchable)
+	}
+	if !reflect.DeepEqual(removals, []string{"asset-1"}) {
+		t.Errorf("Expected the removal to be published, but got %v", removals)
+	}
+
+	// Restoring puts its stored embedding back and tells the peers
+	if code := post("/index/assets/asset-1/restore", "s3cret"); code != http.StatusOK {
+		t.Fatalf("Expected status %d from restore, but got %d", http.StatusOK, code)
+	}
+	if searchable := searchableAssets(t); !reflect.DeepEqual(searchable, []string{"asset-1", "asset-2"}) {
+		t.Errorf("Expected the restored asset to be searchable again, but got %v", searchable)
+	}
+	if !reflect.DeepEqual(deltas, []string{"asset-1"}) {
+		t.Errorf("Expected the restored vector to be published, but got %v", deltas)
+	}
+
+	if code := post("/index/assets/missing/restore", "s3cret"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown asset, but got %d", http.StatusNotFound, code)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+
+	"proofpix/internal/bodylimit"
+	"proofpix/internal/index"
+)
+
+// defaultEmbeddingReverifyTolerance is the largest distance between the unit-length
+// stored and recomputed embeddings still counted as a match. Vertex embeddings of
+// the same image vary slightly between calls, so an exact match cannot be expected.
+const defaultEmbeddingReverifyTolerance = 0.05
+
+// embeddingReverifyTolerance returns EMBEDDING_REVERIFY_TOLERANCE
+func embeddingReverifyTolerance() float64 {
+	value := os.Getenv("EMBEDDING_REVERIFY_TOLERANCE")
+	if value == "" {
+		return defaultEmbeddingReverifyTolerance
+	}
+	tolerance, err := strconv.ParseFloat(value, 64)
+	if err != nil || tolerance < 0 {
+		log.Printf("Invalid EMBEDDING_REVERIFY_TOLERANCE %q, using default of %g", value, defaultEmbeddingReverifyTolerance)
+		return defaultEmbeddingReverifyTolerance
+	}
+	return tolerance
+}
+
+// reverifyRequest is the optional JSON body of the embedding reverification
+type reverifyRequest struct {
+	// Bucket is the allowlisted bucket holding the upload, the default one when empty
+	Bucket string `json:"bucket"`
+}
+
+// embeddingReverification reports how a recomputed embedding compares to the stored one
+type embeddingReverification struct {
+	AssetID             string   `json:"asset_id"`
+	EmbeddingModel      string   `json:"embedding_model"`
+	Dimension           int      `json:"dimension"`
+	RecomputedDimension int      `json:"recomputed_dimension"`
+	Distance            *float64 `json:"distance"`
+	// Similarity labels the distance with the cosine SIMILARITY_BANDS
+	Similarity string  `json:"similarity,omitempty"`
+	Tolerance  float64 `json:"tolerance"`
+	Matches    bool    `json:"matches"`
+}
+
+// compareEmbeddings returns the L2 distance between two embeddings scaled to unit
+// length, so the comparison does not depend on how the stored vector was
+// normalized. ok is false when the dimensions differ.
+func compareEmbeddings(stored, recomputed []float32) (distance float64, ok bool) {
+	if len(stored) != len(recomputed) {
+		return 0, false
+	}
+	a, b := index.Normalize(stored), index.Normalize(recomputed)
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum), true
+}
+
+// reverifyEmbeddingHandler re-downloads an asset's image, recomputes its embedding
+// with the model that produced the stored one, and reports whether the two still
+// agree within EMBEDDING_REVERIFY_TOLERANCE. A mismatch means the stored embedding
+// drifted from the image, through a bug or tampering, and similarity results for
+// the asset cannot be trusted. The asset is not modified.
+// Route: POST /admin/assets/{id}/reverify-embedding
+func reverifyEmbeddingHandler(w http.ResponseWriter, r *http.Request) {
+	assetID := r.PathValue("id")
+
+	var req reverifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		if bodylimit.IsTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+	bucket, err := resolveUploadBucket(req.Bucket)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	asset, err := loadAsset(ctx, assetID)
+	if err != nil {
+		log.Printf("Failed to load asset %s for embedding reverification: %v", assetID, err)
+		http.Error(w, "Failed to load asset", http.StatusInternalServerError)
+		return
+	}
+	if asset == nil {
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return
+	}
+	stored := asset.EmbeddingVector()
+	if len(stored) == 0 {
+		http.Error(w, "Asset has no stored embedding", http.StatusConflict)
+		return
+	}
+
+	imageData, err := fetchImage(ctx, bucket, asset.UserID, assetID)
+	if err != nil {
+		log.Printf("Failed to download image of asset %s for embedding reverification: %v", assetID, err)
+		http.Error(w, "Failed to download image", http.StatusBadGateway)
+		return
+	}
+
+	model := asset.EmbeddingModel
+	if model == "" {
+		model = embeddingModels()[0]
+	}
+	var recomputed []float32
+	if poolErr := embeddingPool.do(ctx, func() {
+		recomputed, err = embedImage(imageData, model)
+	}); poolErr != nil {
+		err = poolErr
+	}
+	if err != nil {
+		log.Printf("Failed to recompute embedding of asset %s with %s: %v", assetID, model, err)
+		http.Error(w, "Failed to recompute embedding", http.StatusBadGateway)
+		return
+	}
+
+	result := embeddingReverification{
+		AssetID:             assetID,
+		EmbeddingModel:      model,
+		Dimension:           len(stored),
+		RecomputedDimension: len(recomputed),
+		Tolerance:           embeddingReverifyTolerance(),
+	}
+	if distance, ok := compareEmbeddings(stored, recomputed); ok {
+		result.Distance = &distance
+		result.Matches = distance <= result.Tolerance
+		// The bands are in squared distances, as the index reports them
+		result.Similarity = index.SimilarityBandsFor(index.MetricCosine).Label(float32(distance * distance))
+	}
+	if result.Matches {
+		log.Printf("Stored embedding of asset %s matches its image (distance %.4f)", assetID, *result.Distance)
+	} else {
+		log.Printf("Stored embedding of asset %s does not match its image: %+v", assetID, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
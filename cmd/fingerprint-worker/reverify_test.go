@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"proofpix/internal/float16"
+	"proofpix/internal/index"
+)
+
+func TestReverifyEmbeddingHandler(t *testing.T) {
+	stored := []float32{0.6, 0.8, 0}
+
+	testCases := []struct {
+		name            string
+		body            string
+		asset           *Asset
+		recomputed      []float32
+		expectedCode    int
+		expectedMatches bool
+		// expectedSimilarity labels the distance, empty when there is none
+		expectedSimilarity string
+	}{
+		{name: "Same embedding", asset: &Asset{ID: "asset-1", UserID: "user-1", Embedding: stored, EmbeddingModel: "multimodalembedding@001"}, recomputed: []float32{0.6, 0.8, 0}, expectedCode: http.StatusOK, expectedMatches: true, expectedSimilarity: index.SimilarityIdentical},
+		{name: "Unnormalized but same direction", asset: &Asset{ID: "asset-1", UserID: "user-1", Embedding: stored, EmbeddingModel: "multimodalembedding@001"}, recomputed: []float32{3, 4, 0}, expectedCode: http.StatusOK, expectedMatches: true, expectedSimilarity: index.SimilarityIdentical},
+		{name: "Within tolerance", asset: &Asset{ID: "asset-1", UserID: "user-1", Embedding: stored, EmbeddingModel: "multimodalembedding@001"}, recomputed: []float32{0.61, 0.79, 0.01}, expectedCode: http.StatusOK, expectedMatches: true, expectedSimilarity: index.SimilarityIdentical},
+		{name: "Stored at half precision", asset: &Asset{ID: "asset-1", UserID: "user-1", EmbeddingFloat16: float16.Encode(stored), EmbeddingModel: "multimodalembedding@001"}, recomputed: []float32{0.6, 0.8, 0}, expectedCode: http.StatusOK, expectedMatches: true, expectedSimilarity: index.SimilarityIdentical},
+		{name: "Drifted embedding", asset: &Asset{ID: "asset-1", UserID: "user-1", Embedding: stored, EmbeddingModel: "multimodalembedding@001"}, recomputed: []float32{0, 0.6, 0.8}, expectedCode: http.StatusOK, expectedMatches: false, expectedSimilarity: index.SimilarityDifferent},
+		{name: "Different dimension", asset: &Asset{ID: "asset-1", UserID: "user-1", Embedding: stored, EmbeddingModel: "multimodalembedding@001"}, recomputed: []float32{0.6, 0.8}, expectedCode: http.StatusOK, expectedMatches: false},
+		{name: "Asset not found", expectedCode: http.StatusNotFound},
+		{name: "No stored embedding", asset: &Asset{ID: "asset-1", UserID: "user-1"}, expectedCode: http.StatusConflict},
+		{name: "Bucket not allowed", body: `{"bucket": "someone-elses-bucket"}`, asset: &Asset{ID: "asset-1", UserID: "user-1", Embedding: stored}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			loadAsset = func(ctx context.Context, assetID string) (*Asset, error) {
+				return tc.asset, nil
+			}
+			var fetchedUser string
+			fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+				fetchedUser = userID
+				return testImageData, nil
+			}
+			var usedModel string
+			embedImage = func(imageData []byte, model string) ([]float32, error) {
+				usedModel = model
+				return tc.recomputed, nil
+			}
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /admin/assets/{id}/reverify-embedding", reverifyEmbeddingHandler)
+			req := httptest.NewRequest(http.MethodPost, "/admin/assets/asset-1/reverify-embedding", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var result embeddingReverification
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if result.Matches != tc.expectedMatches {
+				t.Errorf("Expected matches=%t, but got %+v", tc.expectedMatches, result)
+			}
+			if result.AssetID != "asset-1" || result.Tolerance != defaultEmbeddingReverifyTolerance {
+				t.Errorf("Expected the asset ID and default tolerance, but got %+v", result)
+			}
+			if (result.Distance == nil) != (len(tc.recomputed) != len(stored)) {
+				t.Errorf("Expected a distance only for equal dimensions, but got %+v", result)
+			}
+			if result.Similarity != tc.expectedSimilarity {
+				t.Errorf("Expected similarity %q, but got %q", tc.expectedSimilarity, result.Similarity)
+			}
+			if fetchedUser != "user-1" || usedModel != "multimodalembedding@001" {
+				t.Errorf("Expected the image of user-1 embedded with the stored model, but got %q and %q", fetchedUser, usedModel)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"proofpix/internal/shortcode"
+)
+
+// shortCodesCollection maps each short code to the asset holding it
+const shortCodesCollection = "short_codes"
+
+// maxShortCodeAttempts bounds the candidates tried when codes collide
+const maxShortCodeAttempts = 5
+
+// errShortCodesExhausted is returned when every candidate code is already taken
+var errShortCodesExhausted = errors.New("no free short code")
+
+// reserveShortCode claims a code for an asset. It is a package variable so tests
+// can keep the short_codes collection in memory.
+var reserveShortCode = claimShortCode
+
+// claimShortCode creates short_codes/{code} for the asset. It reports false when the
+// code already belongs to a different asset; a code already held by the same asset,
+// as on a retried run, counts as claimed.
+func claimShortCode(ctx context.Context, code, assetID string) (bool, error) {
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if projectID == "" {
+		return false, fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable not set")
+	}
+
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return false, fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	docRef := client.Collection(shortCodesCollection).Doc(code)
+	_, err = docRef.Create(ctx, map[string]interface{}{
+		"asset_id":   assetID,
+		"created_at": time.Now(),
+	})
+	if err == nil {
+		return true, nil
+	}
+	if status.Code(err) != codes.AlreadyExists {
+		return false, fmt.Errorf("failed to create short code: %v", err)
+	}
+
+	docSnap, err := docRef.Get(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to read short code: %v", err)
+	}
+	holder, _ := docSnap.Data()["asset_id"].(string)
+	return holder == assetID, nil
+}
+
+// assignShortCode reserves the first free candidate code for the asset
+func assignShortCode(ctx context.Context, assetID string) (string, error) {
+	for attempt := 0; attempt < maxShortCodeAttempts; attempt++ {
+		code := shortcode.Generate(assetID, attempt)
+		claimed, err := reserveShortCode(ctx, code, assetID)
+		if err != nil {
+			return "", err
+		}
+		if claimed {
+			return code, nil
+		}
+		log.Printf("Short code %s for asset %s is taken, trying another", code, assetID)
+	}
+	return "", fmt.Errorf("%w after %d attempts", errShortCodesExhausted, maxShortCodeAttempts)
+}
+
+// shortCodeStage gives the asset a short code for printed badges. Assets keep the
+// code they were given on an earlier run. The UUID always works, so failures are
+// logged and processing continues without a code.
+func shortCodeStage(ctx context.Context, p *pipelineState) error {
+	if p.previous != nil && p.previous.ShortCode != "" {
+		p.shortCode = p.previous.ShortCode
+		return nil
+	}
+
+	code, err := assignShortCode(ctx, p.assetID)
+	if err != nil {
+		log.Printf("Failed to assign a short code to asset %s: %v", p.assetID, err)
+		return nil
+	}
+	p.shortCode = code
+	log.Printf("Assigned short code %s to asset %s", shortcode.Format(code), p.assetID)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"proofpix/internal/shortcode"
+)
+
+// useFakeShortCodes keeps the short_codes collection in memory
+func useFakeShortCodes(t *testing.T, taken map[string]string) {
+	t.Helper()
+	orig := reserveShortCode
+	t.Cleanup(func() { reserveShortCode = orig })
+	reserveShortCode = func(ctx context.Context, code, assetID string) (bool, error) {
+		if holder, ok := taken[code]; ok {
+			return holder == assetID, nil
+		}
+		taken[code] = assetID
+		return true, nil
+	}
+}
+
+func TestAssignShortCode_Collisions(t *testing.T) {
+	first := shortcode.Generate("asset-1", 0)
+	second := shortcode.Generate("asset-1", 1)
+	taken := map[string]string{first: "other-asset"}
+	useFakeShortCodes(t, taken)
+
+	code, err := assignShortCode(context.Background(), "asset-1")
+	if err != nil {
+		t.Fatalf("assignShortCode() failed: %v", err)
+	}
+	if code != second {
+		t.Errorf("Expected the next candidate %s after a collision, but got %s", second, code)
+	}
+	if taken[first] != "other-asset" || taken[second] != "asset-1" {
+		t.Errorf("Expected the existing code to be kept and the new one reserved, but got %v", taken)
+	}
+
+	// A retried run keeps the code the asset already holds
+	if again, err := assignShortCode(context.Background(), "asset-1"); err != nil || again != second {
+		t.Errorf("Expected %s again, but got %s (%v)", second, again, err)
+	}
+
+	// Every candidate taken
+	for attempt := 0; attempt < maxShortCodeAttempts; attempt++ {
+		taken[shortcode.Generate("asset-2", attempt)] = "other-asset"
+	}
+	if _, err := assignShortCode(context.Background(), "asset-2"); !errors.Is(err, errShortCodesExhausted) {
+		t.Errorf("Expected errShortCodesExhausted, but got %v", err)
+	}
+}
+
+func TestProcessImage_AssignsShortCode(t *testing.T) {
+	stubServices(t)
+	taken := map[string]string{}
+	useFakeShortCodes(t, taken)
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket, SkipAnchoring: true})
+
+	if saved == nil || saved.ShortCode == "" {
+		t.Fatalf("Expected the saved asset to have a short code, but got %+v", saved)
+	}
+	if taken[saved.ShortCode] != "asset-1" {
+		t.Errorf("Expected %s to be reserved for asset-1, but got %v", saved.ShortCode, taken)
+	}
+
+	// A failed reservation leaves the asset without a code but still processed
+	reserveShortCode = func(ctx context.Context, code, assetID string) (bool, error) {
+		return false, errors.New("firestore unavailable")
+	}
+	saved = nil
+	processImage("user-1", "asset-2", processOptions{Bucket: defaultUploadBucket, SkipAnchoring: true})
+	if saved == nil || saved.ShortCode != "" {
+		t.Errorf("Expected asset-2 saved without a short code, but got %+v", saved)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"proofpix/internal/certificate"
+)
+
+func TestCertifyStage_SignsWhenConfigured(t *testing.T) {
+	stubServices(t)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	t.Setenv("CERTIFICATE_SIGNING_ALGORITHM", certificate.AlgorithmEd25519)
+	t.Setenv("CERTIFICATE_SIGNING_KEY_FILE", keyFile)
+
+	orig := credentialSigner
+	t.Cleanup(func() { credentialSigner = orig })
+	credentialSigner, err = certificate.SigningConfigFromEnv()
+	if err != nil {
+		t.Fatalf("SigningConfigFromEnv() failed: %v", err)
+	}
+
+	p := &pipelineState{assetID: "asset-1", asset: &Asset{ID: "asset-1", UserID: "user-1", CreatedAt: time.Now()}}
+	if err := certifyStage(context.Background(), p); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	var credential certificate.VerifiableCredential
+	if err := json.Unmarshal(p.certificateJSON, &credential); err != nil {
+		t.Fatalf("Failed to decode certificate: %v", err)
+	}
+	if err := certificate.VerifySignature(&credential, credentialSigner.PublicKey()); err != nil {
+		t.Errorf("Expected stored certificate to be signed, but got %v", err)
+	}
+}
+
+func TestBadgeStage_SignsWhenConfigured(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	signer, err := certificate.NewSigningConfig(certificate.AlgorithmEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "")
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+
+	testCases := []struct {
+		name           string
+		badgeSigning   string
+		signer         *certificate.SigningConfig
+		expectedSigned bool
+	}{
+		{name: "Signed", badgeSigning: "true", signer: signer, expectedSigned: true},
+		{name: "Badge signing off", badgeSigning: "", signer: signer},
+		{name: "Credentials unsigned", badgeSigning: "true"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("BADGE_SIGNING", tc.badgeSigning)
+			orig := credentialSigner
+			t.Cleanup(func() { credentialSigner = orig })
+			credentialSigner = tc.signer
+
+			var stored []byte
+			storeBadge = func(ctx context.Context, assetID string, data []byte) error {
+				stored = data
+				return nil
+			}
+			p := &pipelineState{assetID: "asset-1", certificateJSON: []byte("{}"), asset: &Asset{ID: "asset-1", OriginalityScore: 87}}
+			if err := badgeStage(context.Background(), p); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+
+			metadata, err := certificate.VerifyBadge(stored, signer.PublicKey())
+			if !tc.expectedSigned {
+				if !errors.Is(err, certificate.ErrBadgeNotSigned) {
+					t.Errorf("Expected an unsigned badge, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected the stored badge to verify, but got %v", err)
+			}
+			if metadata.AssetID != "asset-1" || metadata.Score != 87 {
+				t.Errorf("Expected the badge to be signed for asset-1 scoring 87, but got %+v", metadata)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/index"
+	"proofpix/internal/models"
+)
+
+// downloadStage downloads the uploaded image from the request's Google Cloud Storage bucket,
+// unless the caller already fetched it
+func downloadStage(ctx context.Context, p *pipelineState) error {
+	imageData := p.imageData
+	var err error
+	if imageData == nil {
+		imageData, err = fetchImage(ctx, p.bucket, p.userID, p.assetID)
+	}
+	if err != nil {
+		recordEvent(ctx, p.assetID, models.StageDownloaded, err)
+		return fmt.Errorf("failed to download image: %v", err)
+	}
+	// Uploads are always stored as .jpg, so the format comes from the content
+	mimeType, err := sniffImageType(imageData)
+	if errors.Is(err, errUnsupportedImageType) {
+		// Short clips are analyzed through their keyframes instead
+		if videoType, ok := sniffVideoType(imageData); ok {
+			p.frames, err = extractKeyframes(ctx, imageData, keyframeOptionsFromEnv())
+			if err != nil {
+				err = fmt.Errorf("failed to extract video keyframes: %w", err)
+			} else {
+				mimeType = videoType
+				log.Printf("Extracted %d keyframes from %s upload for asset %s", len(p.frames), videoType, p.assetID)
+			}
+		}
+	}
+	if err != nil {
+		recordEvent(ctx, p.assetID, models.StageDownloaded, err)
+		return err
+	}
+	p.imageData = imageData
+	p.mimeType = mimeType
+	recordEvent(ctx, p.assetID, models.StageDownloaded, nil)
+	return nil
+}
+
+// reuseStage picks up earlier Vertex results for the image: cached results for
+// identical content, or the completed stage of a partially processed asset
+func reuseStage(ctx context.Context, p *pipelineState) error {
+	p.imageHash = fmt.Sprintf("%x", sha256.Sum256(p.imageData))
+	// Cached results are a single image's, without the per-keyframe results of a video
+	if !p.isVideo() {
+		p.cached = lookupCachedAnalysis(ctx, p.imageHash)
+	}
+
+	if p.cached != nil {
+		log.Printf("Reusing cached analysis for asset %s (image hash %s)", p.assetID, p.imageHash)
+		p.analysisText, p.score, p.narrative = p.cached.RawAnalysis, p.cached.OriginalityScore, p.cached.Narrative
+		p.modelVersion = p.cached.ModelVersion
+		p.analysisWarning = p.cached.AnalysisWarning
+		p.narrativeLanguage, p.narrativeOriginal, p.narrativeTranslatedFrom = p.cached.NarrativeLanguage, p.cached.NarrativeOriginal, p.cached.NarrativeTranslatedFrom
+		p.embedding, p.embeddingModel = p.cached.Embedding, p.cached.EmbeddingModel
+		p.analysisReused, p.embeddingReused = true, true
+		return nil
+	}
+
+	// A retry of a partially processed asset only redoes the stage that failed
+	p.previous = loadPartialAsset(ctx, p.assetID)
+	if p.previous != nil {
+		// Keep the uploader's anchoring choice from the first attempt
+		p.skipAnchoring = p.skipAnchoring || p.previous.SkipAnchoring
+		if p.visibility == "" {
+			p.visibility = p.previous.Visibility
+		}
+		log.Printf("Retrying partial asset %s (analysis failed: %t, embedding failed: %t)", p.assetID, p.previous.AnalysisFailed, p.previous.EmbeddingFailed)
+		if p.isVideo() {
+			// Both results aggregate every keyframe, so all of them are analyzed again
+			return nil
+		}
+		if !p.previous.AnalysisFailed {
+			p.analysisText, p.score, p.narrative = p.previous.RawAnalysis, p.previous.OriginalityScore, p.previous.Narrative
+			p.modelVersion = p.previous.ModelVersion
+			p.analysisWarning = p.previous.AnalysisWarning
+			p.narrativeLanguage, p.narrativeOriginal, p.narrativeTranslatedFrom = p.previous.NarrativeLanguage, p.previous.NarrativeOriginal, p.previous.NarrativeTranslatedFrom
+			p.analysisReused = true
+		}
+		if !p.previous.EmbeddingFailed {
+			p.embedding, p.embeddingModel = p.previous.EmbeddingVector(), p.previous.EmbeddingModel
+			p.embeddingReused = true
+		}
+	}
+	return nil
+}
+
+// analyzeStage runs whichever of the authenticity analysis and embedding is still
+// needed concurrently, each through its shared Vertex pool. It fails only when
+// neither produced a result.
+func analyzeStage(ctx context.Context, p *pipelineState) error {
+	if p.isVideo() {
+		return analyzeKeyframes(ctx, p)
+	}
+	var wg sync.WaitGroup
+
+	if !p.analysisReused {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := analysisPool.do(ctx, func() {
+				p.analysisText, p.modelVersion, p.analysisErr = analyzeImage(p.imageData, p.mimeType)
+			}); err != nil {
+				p.analysisErr = err
+			}
+		}()
+	}
+
+	if !p.embeddingReused {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := embeddingPool.do(ctx, func() {
+				p.embedding, p.embeddingModel, p.embeddingErr = embedWithFallback(p.imageData)
+				if p.embeddingErr == nil {
+					// Normalize once here so the saved and indexed vectors are the same
+					p.embedding = index.PrepareVector(p.embedding)
+				}
+			}); err != nil {
+				p.embeddingErr = err
+			}
+		}()
+	}
+
+	log.Println("Waiting for authenticity analysis and embedding generation to complete...")
+	wg.Wait()
+
+	if p.analysisErr != nil {
+		log.Printf("Failed to analyze image authenticity: %v", p.analysisErr)
+	} else if !p.analysisReused {
+		log.Printf("Authenticity analysis result: %s", p.analysisText)
+
+		// Parse the analysis text to extract score and narrative
+		parsedScore, parsedNarrative, parseErr := parseAnalysis(p.analysisText)
+		if parseErr != nil {
+			log.Printf("Failed to parse analysis for asset %s: %v", p.assetID, parseErr)
+			// Fall back to the raw analysis text
+			p.score = 0
+			p.narrative = p.analysisText
+		} else {
+			p.score = parsedScore
+			p.narrative = parsedNarrative
+			log.Printf("Successfully parsed analysis for asset %s: score=%d, narrative=%s", p.assetID, p.score, p.narrative)
+			tagNarrativeLanguage(ctx, p)
+			checkAnalysis(p)
+		}
+	}
+	if p.embeddingErr != nil {
+		log.Printf("Failed to generate embedding: %v", p.embeddingErr)
+	}
+	recordEvent(ctx, p.assetID, models.StageAnalyzed, p.analysisErr)
+	recordEvent(ctx, p.assetID, models.StageEmbedded, p.embeddingErr)
+
+	// Remember fresh results so identical images are not billed again
+	if p.cached == nil && p.analysisErr == nil && p.embeddingErr == nil {
+		storeCachedAnalysis(ctx, p.imageHash, &CachedAnalysis{
+			RawAnalysis:             p.analysisText,
+			OriginalityScore:        p.score,
+			Narrative:               p.narrative,
+			Embedding:               p.embedding,
+			ModelVersion:            p.modelVersion,
+			NarrativeLanguage:       p.narrativeLanguage,
+			NarrativeOriginal:       p.narrativeOriginal,
+			NarrativeTranslatedFrom: p.narrativeTranslatedFrom,
+			EmbeddingModel:          p.embeddingModel,
+			AnalysisWarning:         p.analysisWarning,
+		})
+	}
+
+	// Nothing worth saving when both stages failed
+	if p.analysisErr != nil && p.embeddingErr != nil {
+		return fmt.Errorf("analysis and embedding failed")
+	}
+	return nil
+}
+
+// indexStage searches for similar images and adds the new embedding to the live index
+func indexStage(ctx context.Context, p *pipelineState) error {
+	if p.embeddingErr != nil {
+		return nil
+	}
+	if !health.searchEnabled() {
+		log.Printf("Search disabled, not indexing embedding for asset %s", p.assetID)
+		return nil
+	}
+	if p.previous != nil && p.embeddingReused {
+		// The embedding was indexed when the partial asset was first processed
+		log.Printf("Embedding for asset %s is already indexed", p.assetID)
+		return nil
+	}
+	log.Printf("Received embedding with %d dimensions", len(p.embedding))
+
+	// A reprocessed asset may already be indexed, so keep it from matching itself
+	if k := duplicateSearchK(); k == 0 {
+		log.Printf("Similarity search skipped for asset %s (DUPLICATE_SEARCH_K=0)", p.assetID)
+	} else if distances, assetIDs, err := searchSimilar(p.embedding, k, p.assetID); err != nil {
+		log.Printf("Failed to perform similarity search: %v", err)
+	} else {
+		log.Printf("Similarity search found asset IDs: %v with distances: %v", assetIDs, distances)
+		if p.relatedAsset = nearDuplicate(distances, assetIDs); p.relatedAsset != nil {
+			log.Printf("Asset %s is a likely duplicate of asset %s (distance %v)", p.assetID, p.relatedAsset.AssetID, p.relatedAsset.Distance)
+		}
+	}
+
+	if err := globalIndexManager.Add(p.assetID, p.embedding); err != nil {
+		log.Printf("Failed to add embedding to index for asset %s: %v", p.assetID, err)
+	} else {
+		log.Printf("Successfully added embedding to index for asset %s", p.assetID)
+		publishIndexDelta(p.assetID, p.embedding)
+	}
+	return nil
+}
+
+// saveStage stores the asset in Firestore, flagging whichever stage failed.
+// Partial assets stop the pipeline: they are not certified until a retry fills in
+// the missing stage.
+func saveStage(ctx context.Context, p *pipelineState) error {
+	asset := &Asset{
+		ID:                      p.assetID,
+		UserID:                  p.userID,
+		Status:                  models.StatusCompleted,
+		CreatedAt:               time.Now(),
+		RawAnalysis:             p.analysisText,
+		OriginalityScore:        p.score,
+		Narrative:               p.narrative,
+		AnalysisFailed:          p.analysisErr != nil,
+		EmbeddingFailed:         p.embeddingErr != nil,
+		ModelVersion:            p.modelVersion,
+		EmbeddingModel:          p.embeddingModel,
+		ImageHash:               p.imageHash,
+		ThumbnailURL:            p.thumbnailURL,
+		NarrativeLanguage:       p.narrativeLanguage,
+		NarrativeOriginal:       p.narrativeOriginal,
+		NarrativeTranslatedFrom: p.narrativeTranslatedFrom,
+		ShortCode:               p.shortCode,
+		AnalysisWarning:         p.analysisWarning,
+		SkipAnchoring:           p.skipAnchoring,
+		BelowAnchorMinScore:     belowAnchorMinScore(p),
+		Visibility:              assetVisibility(p.visibility),
+		UploadBucket:            p.bucket,
+		RelatedAsset:            p.relatedAsset,
+		ProcessedBy:             workerID,
+		Frames:                  p.frameResults,
+	}
+	asset.SetEmbedding(p.embedding, embeddingPrecision())
+	if p.previous != nil {
+		asset.CreatedAt = p.previous.CreatedAt
+		// The search only runs when the embedding is first indexed
+		if asset.RelatedAsset == nil {
+			asset.RelatedAsset = p.previous.RelatedAsset
+		}
+	}
+	if asset.AnalysisFailed || asset.EmbeddingFailed {
+		asset.Status = models.StatusPartial
+	}
+
+	if err := storeAsset(ctx, asset); err != nil {
+		recordEvent(ctx, p.assetID, models.StageSaved, err)
+		// Keep the results so the save can be repeated once Firestore recovers
+		pendingSaves.add(p)
+		log.Printf("Queued asset %s for a later save attempt", p.assetID)
+		return fmt.Errorf("failed to save asset to Firestore: %v", err)
+	}
+	log.Printf("Successfully saved asset %s to Firestore", p.assetID)
+	recordEvent(ctx, p.assetID, models.StageSaved, nil)
+	p.asset = asset
+
+	if asset.IsPartial() {
+		log.Printf("Asset %s saved with partial results, awaiting retry", p.assetID)
+		return fmt.Errorf("partial result: analysis failed=%t, embedding failed=%t", asset.AnalysisFailed, asset.EmbeddingFailed)
+	}
+	return nil
+}
+
+// certifyStage generates the verifiable credential, signs it when signing is
+// configured, and saves it to GCS. A failure
+// is recorded but does not stop the pipeline; later stages skip the certificate.
+func certifyStage(ctx context.Context, p *pipelineState) error {
+	log.Printf("Generating verifiable credential certificate for asset %s", p.assetID)
+	credential, err := certificate.Generate(p.asset)
+	if err != nil {
+		log.Printf("Failed to generate certificate for asset %s: %v", p.assetID, err)
+		recordEvent(ctx, p.assetID, models.StageCertified, err)
+		return nil
+	}
+
+	if credentialSigner != nil {
+		if err := credentialSigner.Sign(credential); err != nil {
+			log.Printf("Failed to sign certificate for asset %s: %v", p.assetID, err)
+			recordEvent(ctx, p.assetID, models.StageCertified, err)
+			return nil
+		}
+	}
+
+	certificateJSON, err := json.MarshalIndent(credential, "", "  ")
+	if err != nil {
+		log.Printf("Failed to marshal certificate to JSON for asset %s: %v", p.assetID, err)
+		recordEvent(ctx, p.assetID, models.StageCertified, err)
+		return nil
+	}
+
+	if err := storeCertificate(ctx, p.asset, certificateJSON); err != nil {
+		log.Printf("Failed to save certificate to GCS for asset %s: %v", p.assetID, err)
+		recordEvent(ctx, p.assetID, models.StageCertified, err)
+		p.asset.CertificateUploadFailed = true
+		recordUploadFailure(ctx, p.assetID, certificateUploadFailedField)
+		return nil
+	}
+	log.Printf("Successfully generated and saved certificate for asset %s", p.assetID)
+	recordEvent(ctx, p.assetID, models.StageCertified, nil)
+	p.certificateJSON = certificateJSON
+	return nil
+}
+
+// logStage queues the saved certificate in Trillian unless the uploader opted out or
+// the asset scored below ANCHOR_MIN_SCORE
+func logStage(ctx context.Context, p *pipelineState) error {
+	if p.certificateJSON == nil {
+		return nil
+	}
+	if p.skipAnchoring {
+		log.Printf("Skipping Trillian integration for asset %s: anchoring skipped by request", p.assetID)
+		return nil
+	}
+	if p.asset.BelowAnchorMinScore {
+		log.Printf("Skipping Trillian integration for asset %s: score %d is below ANCHOR_MIN_SCORE", p.assetID, p.asset.OriginalityScore)
+		return nil
+	}
+	p.logged = logCertificate(ctx, p.assetID, p.certificateJSON)
+	return nil
+}
+
+// badgeStage renders the originality badge, signs it when configured, and saves it to GCS
+func badgeStage(ctx context.Context, p *pipelineState) error {
+	if p.certificateJSON == nil {
+		return nil
+	}
+	log.Printf("Generating badge for asset %s with score %d", p.assetID, p.asset.OriginalityScore)
+	badgeData, err := certificate.GenerateBadge(p.asset.OriginalityScore)
+	if err != nil {
+		log.Printf("Failed to generate badge for asset %s: %v", p.assetID, err)
+		return nil
+	}
+	// With BADGE_SIGNING the badge carries the asset ID and score signed with the credential key
+	if credentialSigner != nil && certificate.BadgeSigningFromEnv() {
+		signed, err := credentialSigner.SignBadge(badgeData, p.assetID, p.asset.OriginalityScore)
+		if err != nil {
+			log.Printf("Failed to sign badge for asset %s, saving it unsigned: %v", p.assetID, err)
+		} else {
+			badgeData = signed
+		}
+	}
+	if err := storeBadge(ctx, p.assetID, badgeData); err != nil {
+		log.Printf("Failed to save badge to GCS for asset %s: %v", p.assetID, err)
+		p.asset.BadgeUploadFailed = true
+		recordUploadFailure(ctx, p.assetID, badgeUploadFailedField)
+		return nil
+	}
+	log.Printf("Successfully generated and saved badge for asset %s", p.assetID)
+	return nil
+}
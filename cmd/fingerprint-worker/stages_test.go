@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/models"
+)
+
+func TestDownloadStage(t *testing.T) {
+	stubServices(t)
+
+	p := &pipelineState{userID: "user-1", assetID: "asset-1"}
+	if err := downloadStage(context.Background(), p); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if string(p.imageData) != string(testImageData) {
+		t.Errorf("Expected image data to be stored, but got %q", p.imageData)
+	}
+	if p.mimeType != "image/jpeg" {
+		t.Errorf("Expected MIME type image/jpeg, but got %q", p.mimeType)
+	}
+
+	fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+		return nil, fmt.Errorf("object not found")
+	}
+	if err := downloadStage(context.Background(), &pipelineState{assetID: "asset-1"}); err == nil {
+		t.Errorf("Expected an error when the download fails, but got nil")
+	}
+}
+
+func TestReuseStage(t *testing.T) {
+	t.Run("Cached analysis", func(t *testing.T) {
+		stubServices(t)
+		orig := analysisCache
+		t.Cleanup(func() { analysisCache = orig })
+		analysisCache = newMemoryAnalysisCache()
+
+		imageHash := fmt.Sprintf("%x", sha256.Sum256([]byte("image-bytes")))
+		analysisCache.Put(context.Background(), imageHash, &CachedAnalysis{OriginalityScore: 70, Embedding: []float32{1}, EmbeddingModel: defaultEmbeddingModel})
+
+		p := &pipelineState{assetID: "asset-1", imageData: []byte("image-bytes")}
+		if err := reuseStage(context.Background(), p); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !p.analysisReused || !p.embeddingReused || p.score != 70 {
+			t.Errorf("Expected cached results to be reused, but got %+v", p)
+		}
+	})
+
+	t.Run("Partial asset", func(t *testing.T) {
+		stubServices(t)
+		loadAsset = func(ctx context.Context, assetID string) (*Asset, error) {
+			return &Asset{ID: assetID, Status: models.StatusPartial, OriginalityScore: 60, AnalysisFailed: true, Embedding: []float32{1}}, nil
+		}
+
+		p := &pipelineState{assetID: "asset-1", imageData: []byte("image-bytes")}
+		if err := reuseStage(context.Background(), p); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if p.previous == nil {
+			t.Fatalf("Expected the partial asset to be loaded")
+		}
+		if p.analysisReused || !p.embeddingReused {
+			t.Errorf("Expected only the embedding to be reused, but got analysis=%t embedding=%t", p.analysisReused, p.embeddingReused)
+		}
+	})
+}
+
+func TestAnalyzeStage(t *testing.T) {
+	testCases := []struct {
+		name          string
+		analysisErr   error
+		embeddingErr  error
+		expectedScore int
+		expectError   bool
+	}{
+		{name: "Both succeed", expectedScore: 95},
+		{name: "Embedding fails", embeddingErr: fmt.Errorf("quota"), expectedScore: 95},
+		{name: "Both fail", analysisErr: fmt.Errorf("quota"), embeddingErr: fmt.Errorf("quota"), expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			var running, peak int32
+			track := func() {
+				if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&peak) {
+					atomic.StoreInt32(&peak, n)
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			}
+			analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+				track()
+				return "Confidence Score: 0.95\n\nJustification: Natural lighting.", "gemini-1.5-flash", tc.analysisErr
+			}
+			embedImage = func(imageData []byte, model string) ([]float32, error) {
+				track()
+				return []float32{0.1}, tc.embeddingErr
+			}
+
+			p := &pipelineState{assetID: "asset-1"}
+			err := analyzeStage(context.Background(), p)
+			if tc.expectError != (err != nil) {
+				t.Fatalf("Expected error=%t, but got %v", tc.expectError, err)
+			}
+			if p.score != tc.expectedScore {
+				t.Errorf("Expected score %d, but got %d", tc.expectedScore, p.score)
+			}
+			if peak != 2 {
+				t.Errorf("Expected analysis and embedding to run concurrently, but peak concurrency was %d", peak)
+			}
+		})
+	}
+}
+
+func TestAnalyzeStage_NormalizesEmbeddingForCosine(t *testing.T) {
+	stubServices(t)
+	t.Setenv("INDEX_METRIC", "cosine")
+	embedImage = func(imageData []byte, model string) ([]float32, error) {
+		return []float32{3, 4}, nil
+	}
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+
+	p := &pipelineState{userID: "user-1", assetID: "asset-1"}
+	if err := analyzeStage(context.Background(), p); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	saveStage(context.Background(), p)
+
+	if saved == nil || len(saved.Embedding) != 2 || saved.Embedding[0] != 0.6 || saved.Embedding[1] != 0.8 {
+		t.Errorf("Expected the stored embedding to be normalized to [0.6 0.8], but got %+v", saved)
+	}
+}
+
+func TestIndexStage_SkipsFailedOrIndexedEmbedding(t *testing.T) {
+	stubServices(t)
+	// A nil index manager would panic if the stage tried to use it
+	globalIndexManager = nil
+
+	testCases := []struct {
+		name  string
+		state *pipelineState
+	}{
+		{name: "Embedding failed", state: &pipelineState{embeddingErr: fmt.Errorf("quota")}},
+		{name: "Already indexed", state: &pipelineState{previous: &Asset{}, embeddingReused: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := indexStage(context.Background(), tc.state); err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestIndexStage_DuplicateSearchK(t *testing.T) {
+	testCases := []struct {
+		name       string
+		searchK    string
+		maxK       string
+		expectedK  int
+		expectCall bool
+	}{
+		{name: "Defaults to SEARCH_DEFAULT_K", searchK: "", expectedK: 5, expectCall: true},
+		{name: "Configured k", searchK: "3", expectedK: 3, expectCall: true},
+		{name: "Clamped to SEARCH_MAX_K", searchK: "50", maxK: "10", expectedK: 10, expectCall: true},
+		{name: "Zero skips the search", searchK: "0", expectCall: false},
+		{name: "Invalid falls back to default", searchK: "-1", expectedK: 5, expectCall: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("DUPLICATE_SEARCH_K", tc.searchK)
+			t.Setenv("SEARCH_DEFAULT_K", "")
+			t.Setenv("SEARCH_MAX_K", tc.maxK)
+			calls, searchedK := 0, 0
+			searchSimilar = func(vector []float32, k int, excludeID string) ([]float32, []string, error) {
+				calls++
+				searchedK = k
+				return []float32{0.01}, []string{"asset-0"}, nil
+			}
+
+			p := &pipelineState{assetID: "asset-1", embedding: []float32{0.1, 0.2, 0.3}}
+			if err := indexStage(context.Background(), p); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if tc.expectCall != (calls == 1) {
+				t.Fatalf("Expected a search to be made: %t, but got %d searches", tc.expectCall, calls)
+			}
+			if tc.expectCall && searchedK != tc.expectedK {
+				t.Errorf("Expected k=%d, but got %d", tc.expectedK, searchedK)
+			}
+			if !tc.expectCall && p.relatedAsset != nil {
+				t.Errorf("Expected no duplicate detection without a search, but got %+v", p.relatedAsset)
+			}
+		})
+	}
+}
+
+func TestSaveStage(t *testing.T) {
+	testCases := []struct {
+		name           string
+		state          *pipelineState
+		storeErr       error
+		expectedStatus string
+		expectError    bool
+	}{
+		{
+			name:           "Completed asset",
+			state:          &pipelineState{assetID: "asset-1", score: 90},
+			expectedStatus: models.StatusCompleted,
+		},
+		{
+			name:           "Partial asset stops pipeline",
+			state:          &pipelineState{assetID: "asset-1", embeddingErr: fmt.Errorf("quota")},
+			expectedStatus: models.StatusPartial,
+			expectError:    true,
+		},
+		{
+			name:        "Firestore failure",
+			state:       &pipelineState{assetID: "asset-1"},
+			storeErr:    fmt.Errorf("unavailable"),
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return tc.storeErr
+			}
+
+			err := saveStage(context.Background(), tc.state)
+			if tc.expectError != (err != nil) {
+				t.Fatalf("Expected error=%t, but got %v", tc.expectError, err)
+			}
+			if tc.storeErr == nil && tc.state.asset == nil {
+				t.Fatalf("Expected the saved asset to be kept on the state")
+			}
+			if tc.expectedStatus != "" && saved.Status != tc.expectedStatus {
+				t.Errorf("Expected status %q, but got %q", tc.expectedStatus, saved.Status)
+			}
+		})
+	}
+}
+
+func TestCertifyStage(t *testing.T) {
+	stubServices(t)
+	asset := &Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 90, CreatedAt: time.Now()}
+
+	p := &pipelineState{assetID: "asset-1", asset: asset}
+	if err := certifyStage(context.Background(), p); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if len(p.certificateJSON) == 0 {
+		t.Errorf("Expected certificate JSON to be kept on the state")
+	}
+
+	// A storage failure is recorded but does not stop the pipeline
+	storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error {
+		return fmt.Errorf("bucket unavailable")
+	}
+	p = &pipelineState{assetID: "asset-1", asset: asset}
+	if err := certifyStage(context.Background(), p); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if p.certificateJSON != nil {
+		t.Errorf("Expected no certificate JSON after a failed save")
+	}
+}
+
+func TestLogAndBadgeStages_RequireCertificate(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+	stubServices(t)
+
+	var queued, badges int
+	queueLeaf = func(ctx context.Context, logID int64, logServerAddr string, leafValue []byte) (int64, error) {
+		queued++
+		return 7, nil
+	}
+	storeBadge = func(ctx context.Context, assetID string, data []byte) error {
+		badges++
+		return nil
+	}
+
+	asset := &Asset{ID: "asset-1", OriginalityScore: 90}
+	for _, p := range []*pipelineState{
+		{assetID: "asset-1", asset: asset},
+		{assetID: "asset-1", asset: asset, certificateJSON: []byte(`{"id":"cert"}`)},
+	} {
+		if err := logStage(context.Background(), p); err != nil {
+			t.Errorf("Expected no error from log stage, but got %v", err)
+		}
+		if err := badgeStage(context.Background(), p); err != nil {
+			t.Errorf("Expected no error from badge stage, but got %v", err)
+		}
+	}
+
+	if queued != 1 {
+		t.Errorf("Expected one leaf to be queued, but got %d", queued)
+	}
+	if badges != 1 {
+		t.Errorf("Expected one badge to be saved, but got %d", badges)
+	}
+}
+
+func TestProcessImage_RedactsCredentialNarrative(t *testing.T) {
+	stubServices(t)
+	t.Setenv("NARRATIVE_REDACTION", "email")
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		return "Confidence Score: 0.95\n\nJustification: Metadata credits jane@example.com as the photographer.", "gemini-1.5-flash", nil
+	}
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+	var credentialJSON []byte
+	storeCertificate = func(ctx context.Context, asset *Asset, data []byte) error {
+		credentialJSON = data
+		return nil
+	}
+
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket})
+
+	if saved == nil || !strings.Contains(saved.RawAnalysis, "jane@example.com") {
+		t.Fatalf("Expected the raw analysis to keep the email, but got %+v", saved)
+	}
+	if strings.Contains(string(credentialJSON), "jane@example.com") || !strings.Contains(string(credentialJSON), certificate.RedactionMarker) {
+		t.Errorf("Expected the email to be redacted from the credential, but got %s", credentialJSON)
+	}
+}
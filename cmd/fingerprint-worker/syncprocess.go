@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"proofpix/internal/index"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+// defaultInclusionWait is how long /process/sync waits for a leaf to be integrated
+// into the log, overridable with SYNC_INCLUSION_TIMEOUT
+const defaultInclusionWait = 30 * time.Second
+
+// inclusionPollInterval is the delay between checks of the log while waiting for
+// integration. Tests shorten it.
+var inclusionPollInterval = time.Second
+
+// errLeafNotIntegrated is returned while a queued leaf is not yet part of the log tree
+var errLeafNotIntegrated = errors.New("leaf is not yet integrated into the log")
+
+// fetchLogInclusion returns the latest log root and the inclusion proof of a leaf,
+// or errLeafNotIntegrated. It is a package variable so tests can substitute a mock log.
+var fetchLogInclusion = getLogInclusion
+
+// loggedLeaf is a certificate leaf queued in a Trillian log
+type loggedLeaf struct {
+	logID int64
+	index int64
+	value []byte
+	// hasher is the hash algorithm of the log's tree
+	hasher leaf.Hasher
+}
+
+// logInclusion is the inclusion of a leaf in a log root
+type logInclusion struct {
+	treeSize int64
+	rootHash []byte
+	hashes   [][]byte
+}
+
+// inclusionProofView is the verified inclusion proof returned by /process/sync
+type inclusionProofView struct {
+	LeafIndex int64    `json:"leaf_index"`
+	TreeSize  int64    `json:"tree_size"`
+	RootHash  string   `json:"root_hash"`
+	Hashes    []string `json:"hashes"`
+}
+
+// inclusionWait returns SYNC_INCLUSION_TIMEOUT
+func inclusionWait() time.Duration {
+	value := os.Getenv("SYNC_INCLUSION_TIMEOUT")
+	if value == "" {
+		return defaultInclusionWait
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil || wait <= 0 {
+		log.Printf("Invalid SYNC_INCLUSION_TIMEOUT %q, using default of %v", value, defaultInclusionWait)
+		return defaultInclusionWait
+	}
+	return wait
+}
+
+// processSyncHandler processes an image before responding. With wait_for_inclusion
+// it also waits up to SYNC_INCLUSION_TIMEOUT for the certificate leaf to be
+// integrated into the log and returns the inclusion proof, checked against the log
+// root. If the wait times out the asset is left to be anchored asynchronously and
+// 202 is returned with anchoring "pending".
+func processSyncHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received %s request to %s", r.Method, r.URL.Path)
+
+	req, ok := decodeProcessRequest(w, r)
+	if !ok {
+		return
+	}
+
+	p, last := processImage(req.UserID, req.AssetID, req.options)
+	response := map[string]interface{}{"asset_id": req.AssetID}
+	if last.err != nil {
+		response["status"] = "failed"
+		response["stage"] = last.stage
+		response["message"] = last.err.Error()
+		writeProcessResponse(w, http.StatusUnprocessableEntity, response)
+		return
+	}
+	response["status"] = "completed"
+	if p.relatedAsset != nil {
+		response["related_asset"] = map[string]interface{}{
+			"asset_id":   p.relatedAsset.AssetID,
+			"distance":   p.relatedAsset.Distance,
+			"similarity": index.SimilarityLabel(p.relatedAsset.Distance),
+		}
+	}
+
+	switch {
+	case p.skipAnchoring:
+		response["anchoring"] = "skipped"
+	case p.asset != nil && p.asset.BelowAnchorMinScore:
+		response["anchoring"] = models.AnchoringBelowMinScore
+	case p.logged == nil:
+		// Not queued directly: Trillian is not configured, queueing failed, or the
+		// leaf is waiting in a batch
+		response["anchoring"] = "pending"
+	case !req.WaitForInclusion:
+		response["anchoring"] = "queued"
+		response["trillian_leaf_index"] = p.logged.index
+	default:
+		response["trillian_leaf_index"] = p.logged.index
+		proof, err := waitForInclusion(r.Context(), p.logged, inclusionWait())
+		if err != nil {
+			log.Printf("Leaf %d for asset %s not confirmed in the log, continuing asynchronously: %v", p.logged.index, req.AssetID, err)
+			response["anchoring"] = "pending"
+			writeProcessResponse(w, http.StatusAccepted, response)
+			return
+		}
+		response["anchoring"] = "integrated"
+		response["inclusion_proof"] = proof
+	}
+	writeProcessResponse(w, http.StatusOK, response)
+}
+
+// writeProcessResponse writes a JSON response for the processing endpoints
+func writeProcessResponse(w http.ResponseWriter, statusCode int, response map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// waitForInclusion polls the log until the leaf is integrated, then verifies its
+// inclusion proof against the log root. It gives up after wait, when ctx ends, or
+// when the log returns a proof that does not verify.
+func waitForInclusion(ctx context.Context, l *loggedLeaf, wait time.Duration) (*inclusionProofView, error) {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	leafHash := l.hasher.HashLeaf(l.value)
+	for {
+		inclusion, err := fetchLogInclusion(ctx, l.logID, l.index)
+		if err == nil {
+			if err := l.hasher.VerifyInclusion(l.index, inclusion.treeSize, leafHash, inclusion.hashes, inclusion.rootHash); err != nil {
+				return nil, err
+			}
+			view := &inclusionProofView{
+				LeafIndex: l.index,
+				TreeSize:  inclusion.treeSize,
+				RootHash:  hex.EncodeToString(inclusion.rootHash),
+				Hashes:    make([]string, len(inclusion.hashes)),
+			}
+			for i, hash := range inclusion.hashes {
+				view.Hashes[i] = hex.EncodeToString(hash)
+			}
+			return view, nil
+		}
+		if !errors.Is(err, errLeafNotIntegrated) {
+			log.Printf("Failed to check inclusion of leaf %d: %v", l.index, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("leaf %d not integrated after %v: %v", l.index, wait, err)
+		case <-time.After(inclusionPollInterval):
+		}
+	}
+}
+
+// getLogInclusion reads the latest root of the log in TRILLIAN_LOG_SERVER_ADDR and,
+// once the tree covers the leaf, its inclusion proof
+func getLogInclusion(ctx context.Context, logID int64, leafIndex int64) (*logInclusion, error) {
+	logServerAddr := os.Getenv("TRILLIAN_LOG_SERVER_ADDR")
+	conn, err := grpc.DialContext(ctx, logServerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Trillian Log Server at %s: %v", logServerAddr, err)
+	}
+	defer func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			log.Printf("Error closing gRPC connection: %v", closeErr)
+		}
+	}()
+	client := trillian.NewTrillianLogClient(conn)
+
+	rootResponse, err := client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: logID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest log root of Trillian log %d: %v", logID, err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(rootResponse.GetSignedLogRoot().GetLogRoot()); err != nil {
+		return nil, fmt.Errorf("failed to parse log root of Trillian log %d: %v", logID, err)
+	}
+	if int64(root.TreeSize) <= leafIndex {
+		return nil, errLeafNotIntegrated
+	}
+
+	proofResponse, err := client.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{
+		LogId:     logID,
+		LeafIndex: leafIndex,
+		TreeSize:  int64(root.TreeSize),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inclusion proof from Trillian log %d for leaf %d: %v", logID, leafIndex, err)
+	}
+	return &logInclusion{treeSize: int64(root.TreeSize), rootHash: root.RootHash, hashes: proofResponse.GetProof().GetHashes()}, nil
+}
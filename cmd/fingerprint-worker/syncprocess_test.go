@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/index"
+	"proofpix/internal/leaf"
+)
+
+func TestProcessSyncHandler_WaitsForInclusion(t *testing.T) {
+	t.Setenv("TRILLIAN_LOG_ID", "42")
+	t.Setenv("TRILLIAN_LOG_SERVER_ADDR", "localhost:8090")
+	origInterval := inclusionPollInterval
+	inclusionPollInterval = time.Millisecond
+	t.Cleanup(func() { inclusionPollInterval = origInterval })
+
+	testCases := []struct {
+		name              string
+		body              string
+		integrateAfter    int
+		timeout           string
+		minScore          string
+		expectedCode      int
+		expectedAnchoring string
+		expectProof       bool
+	}{
+		{
+			name:              "Integrated after a couple of polls",
+			body:              `{"user_id": "user-1", "asset_id": "asset-1", "wait_for_inclusion": true}`,
+			integrateAfter:    2,
+			expectedCode:      http.StatusOK,
+			expectedAnchoring: "integrated",
+			expectProof:       true,
+		},
+		{
+			name:              "Timeout falls back to pending",
+			body:              `{"user_id": "user-1", "asset_id": "asset-1", "wait_for_inclusion": true}`,
+			integrateAfter:    1000,
+			timeout:           "30ms",
+			expectedCode:      http.StatusAccepted,
+			expectedAnchoring: "pending",
+		},
+		{
+			name:              "Without waiting",
+			body:              `{"user_id": "user-1", "asset_id": "asset-1"}`,
+			expectedCode:      http.StatusOK,
+			expectedAnchoring: "queued",
+		},
+		{
+			name:              "Anchoring skipped",
+			body:              `{"user_id": "user-1", "asset_id": "asset-1", "skip_anchoring": true, "wait_for_inclusion": true}`,
+			expectedCode:      http.StatusOK,
+			expectedAnchoring: "skipped",
+		},
+		{
+			name:              "Below the minimum score",
+			body:              `{"user_id": "user-1", "asset_id": "asset-1", "wait_for_inclusion": true}`,
+			minScore:          "96",
+			expectedCode:      http.StatusOK,
+			expectedAnchoring: "below_min_score",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("SYNC_INCLUSION_TIMEOUT", tc.timeout)
+			t.Setenv("ANCHOR_MIN_SCORE", tc.minScore)
+
+			// A four-leaf log whose last leaf is the queued certificate
+			var queued []byte
+			queueLeaf = func(ctx context.Context, logID int64, logServerAddr string, leafValue []byte) (int64, error) {
+				queued = leafValue
+				return 3, nil
+			}
+			polls := 0
+			fetchLogInclusion = func(ctx context.Context, logID int64, leafIndex int64) (*logInclusion, error) {
+				polls++
+				if polls <= tc.integrateAfter {
+					return nil, errLeafNotIntegrated
+				}
+				h0, h1, h2, h3 := leaf.HashLeaf([]byte("a")), leaf.HashLeaf([]byte("b")), leaf.HashLeaf([]byte("c")), leaf.HashLeaf(queued)
+				left := leaf.HashChildren(h0, h1)
+				root := leaf.HashChildren(left, leaf.HashChildren(h2, h3))
+				return &logInclusion{treeSize: 4, rootHash: root, hashes: [][]byte{h2, left}}, nil
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/process/sync", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			processSyncHandler(rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			var body struct {
+				Anchoring      string              `json:"anchoring"`
+				InclusionProof *inclusionProofView `json:"inclusion_proof"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.Anchoring != tc.expectedAnchoring {
+				t.Errorf("Expected anchoring %q, but got %q", tc.expectedAnchoring, body.Anchoring)
+			}
+			if !tc.expectProof {
+				if body.InclusionProof != nil {
+					t.Errorf("Expected no inclusion proof, but got %+v", body.InclusionProof)
+				}
+				return
+			}
+			if polls != tc.integrateAfter+1 {
+				t.Errorf("Expected %d polls, but got %d", tc.integrateAfter+1, polls)
+			}
+			proof := body.InclusionProof
+			if proof == nil || proof.LeafIndex != 3 || proof.TreeSize != 4 || len(proof.Hashes) != 2 {
+				t.Fatalf("Expected an inclusion proof of leaf 3 in a tree of 4, but got %+v", proof)
+			}
+			if proof.Hashes[0] != hex.EncodeToString(leaf.HashLeaf([]byte("c"))) {
+				t.Errorf("Expected the sibling hash first, but got %s", proof.Hashes[0])
+			}
+		})
+	}
+}
+
+func TestWaitForInclusion_RejectsInvalidProof(t *testing.T) {
+	stubServices(t)
+	fetchLogInclusion = func(ctx context.Context, logID int64, leafIndex int64) (*logInclusion, error) {
+		return &logInclusion{treeSize: 2, rootHash: make([]byte, 32), hashes: [][]byte{leaf.HashLeaf([]byte("a"))}}, nil
+	}
+
+	l := &loggedLeaf{logID: 42, index: 1, value: []byte("certificate")}
+	if _, err := waitForInclusion(context.Background(), l, time.Second); err == nil {
+		t.Errorf("Expected a proof that does not match the root to be rejected")
+	}
+}
+
+func TestProcessSyncHandler_RelatedAsset(t *testing.T) {
+	stubServices(t)
+	t.Setenv("INDEX_METRIC", "cosine")
+	searchSimilar = func(vector []float32, k int, excludeID string) ([]float32, []string, error) {
+		return []float32{0.05, 0.6}, []string{"asset-1", "asset-9"}, nil
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/process/sync", strings.NewReader(`{"user_id": "user-1", "asset_id": "asset-2"}`))
+	rec := httptest.NewRecorder()
+	processSyncHandler(rec, req)
+
+	var body struct {
+		RelatedAsset struct {
+			AssetID    string  `json:"asset_id"`
+			Distance   float32 `json:"distance"`
+			Similarity string  `json:"similarity"`
+		} `json:"related_asset"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body.RelatedAsset.AssetID != "asset-1" || body.RelatedAsset.Distance != 0.05 || body.RelatedAsset.Similarity != index.SimilarityVerySimilar {
+		t.Errorf("Expected asset-1 labelled %q, but got %+v", index.SimilarityVerySimilar, body.RelatedAsset)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"proofpix/internal/models"
+)
+
+// Thumbnail defaults, overridable with THUMBNAIL_MAX_DIMENSION and THUMBNAIL_BUCKET
+const (
+	defaultThumbnailMaxDimension = 256
+	defaultThumbnailBucket       = "proofpix-thumbnails"
+	thumbnailJPEGQuality         = 80
+)
+
+// storeThumbnail uploads a thumbnail. It is a package variable so tests can
+// substitute a fake for GCS.
+var storeThumbnail = saveThumbnail
+
+// thumbnailMaxDimension returns THUMBNAIL_MAX_DIMENSION, the longest side of a thumbnail in pixels
+func thumbnailMaxDimension() int {
+	value := os.Getenv("THUMBNAIL_MAX_DIMENSION")
+	if value == "" {
+		return defaultThumbnailMaxDimension
+	}
+	dimension, err := strconv.Atoi(value)
+	if err != nil || dimension <= 0 {
+		log.Printf("Invalid THUMBNAIL_MAX_DIMENSION %q, using default of %d", value, defaultThumbnailMaxDimension)
+		return defaultThumbnailMaxDimension
+	}
+	return dimension
+}
+
+// thumbnailBucket returns THUMBNAIL_BUCKET
+func thumbnailBucket() string {
+	if bucket := os.Getenv("THUMBNAIL_BUCKET"); bucket != "" {
+		return bucket
+	}
+	return defaultThumbnailBucket
+}
+
+// thumbnailURL is the public URL of an asset's thumbnail
+func thumbnailURL(assetID string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/thumbnails/%s.jpg", thumbnailBucket(), assetID)
+}
+
+// generateThumbnail decodes a JPEG, PNG or WebP image and re-encodes it as a JPEG
+// whose longest side is at most maxDimension, keeping the aspect ratio. Smaller
+// images are not enlarged.
+func generateThumbnail(imageData []byte, maxDimension int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxDimension || height > maxDimension {
+		if width >= height {
+			width, height = maxDimension, max(1, height*maxDimension/width)
+		} else {
+			width, height = max(1, width*maxDimension/height), maxDimension
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// saveThumbnail uploads a JPEG thumbnail to thumbnails/{assetID}.jpg in THUMBNAIL_BUCKET
+func saveThumbnail(ctx context.Context, assetID string, data []byte) error {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create storage client: %v", err)
+	}
+	defer client.Close()
+
+	objectName := fmt.Sprintf("thumbnails/%s.jpg", assetID)
+	writer := client.Bucket(thumbnailBucket()).Object(objectName).NewWriter(ctx)
+	writer.ContentType = "image/jpeg"
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write thumbnail data: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close storage writer: %v", err)
+	}
+	return nil
+}
+
+// thumbnailStage stores a downscaled JPEG preview of the image for listing and
+// verification UIs, leaving the original upload untouched. A thumbnail is a
+// convenience, so failures are logged and processing continues without one.
+// Thumbnails are served from a public URL, so private assets get none.
+func thumbnailStage(ctx context.Context, p *pipelineState) error {
+	if assetVisibility(p.visibility) == models.VisibilityPrivate {
+		return nil
+	}
+	if p.previous != nil && p.previous.ThumbnailURL != "" {
+		p.thumbnailURL = p.previous.ThumbnailURL
+		return nil
+	}
+
+	data, err := generateThumbnail(p.previewImage(), thumbnailMaxDimension())
+	if err != nil {
+		log.Printf("Failed to generate thumbnail for asset %s (%s): %v", p.assetID, p.mimeType, err)
+		return nil
+	}
+	if err := storeThumbnail(ctx, p.assetID, data); err != nil {
+		log.Printf("Failed to save thumbnail to GCS for asset %s: %v", p.assetID, err)
+		return nil
+	}
+	p.thumbnailURL = thumbnailURL(p.assetID)
+	log.Printf("Saved %d-byte thumbnail for asset %s", len(data), p.assetID)
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+// sampleImage encodes a width x height gradient in the given format
+func sampleImage(t *testing.T, width, height int, format string) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("Failed to encode sample image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerateThumbnail(t *testing.T) {
+	testCases := []struct {
+		name           string
+		width, height  int
+		format         string
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{name: "Landscape PNG", width: 800, height: 600, format: "png", expectedWidth: 128, expectedHeight: 96},
+		{name: "Portrait JPEG", width: 300, height: 900, format: "jpeg", expectedWidth: 42, expectedHeight: 128},
+		{name: "Small image is not enlarged", width: 100, height: 50, format: "png", expectedWidth: 100, expectedHeight: 50},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := sampleImage(t, tc.width, tc.height, tc.format)
+			originalCopy := append([]byte(nil), original...)
+
+			thumbnail, err := generateThumbnail(original, 128)
+			if err != nil {
+				t.Fatalf("generateThumbnail() failed: %v", err)
+			}
+			config, format, err := image.DecodeConfig(bytes.NewReader(thumbnail))
+			if err != nil {
+				t.Fatalf("Thumbnail does not decode: %v", err)
+			}
+			if format != "jpeg" {
+				t.Errorf("Expected a JPEG thumbnail, but got %s", format)
+			}
+			if config.Width != tc.expectedWidth || config.Height != tc.expectedHeight {
+				t.Errorf("Expected %dx%d, but got %dx%d", tc.expectedWidth, tc.expectedHeight, config.Width, config.Height)
+			}
+			if !bytes.Equal(original, originalCopy) {
+				t.Errorf("Expected the original image to be left untouched")
+			}
+		})
+	}
+
+	if _, err := generateThumbnail([]byte("not an image"), 128); err == nil {
+		t.Errorf("Expected an error for undecodable data")
+	}
+}
+
+func TestProcessImage_StoresThumbnail(t *testing.T) {
+	stubServices(t)
+	t.Setenv("THUMBNAIL_MAX_DIMENSION", "64")
+	t.Setenv("THUMBNAIL_BUCKET", "thumbs")
+
+	original := sampleImage(t, 320, 240, "png")
+	fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+		return original, nil
+	}
+	var stored []byte
+	var storedID string
+	storeThumbnail = func(ctx context.Context, assetID string, data []byte) error {
+		storedID, stored = assetID, data
+		return nil
+	}
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket, SkipAnchoring: true})
+
+	if storedID != "asset-1" {
+		t.Fatalf("Expected a thumbnail stored for asset-1, but got %q", storedID)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(stored))
+	if err != nil || config.Width != 64 || config.Height != 48 {
+		t.Errorf("Expected a 64x48 JPEG thumbnail, but got %+v (%v)", config, err)
+	}
+	expectedURL := "https://storage.googleapis.com/thumbs/thumbnails/asset-1.jpg"
+	if saved == nil || saved.ThumbnailURL != expectedURL {
+		t.Errorf("Expected the asset to record thumbnail URL %s, but got %+v", expectedURL, saved)
+	}
+}
+
+func TestProcessImage_NoThumbnailForPrivateAsset(t *testing.T) {
+	stubServices(t)
+	stored := false
+	storeThumbnail = func(ctx context.Context, assetID string, data []byte) error {
+		stored = true
+		return nil
+	}
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+
+	processImage("user-1", "asset-1", processOptions{Bucket: defaultUploadBucket, SkipAnchoring: true, Visibility: models.VisibilityPrivate})
+
+	if stored {
+		t.Error("Expected no thumbnail to be stored for a private asset")
+	}
+	if saved == nil || saved.ThumbnailURL != "" {
+		t.Errorf("Expected the private asset to record no thumbnail URL, but got %+v", saved)
+	}
+}
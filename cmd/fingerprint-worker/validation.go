@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Analysis validation modes, selected with ANALYSIS_VALIDATION
+const (
+	// validationWarn stores an invalid result with a warning on the asset
+	validationWarn = "warn"
+	// validationStrict treats an invalid result as a failed analysis, leaving the
+	// asset partial so a retry asks the model again
+	validationStrict = "strict"
+	// validationOff skips validation
+	validationOff = "off"
+)
+
+// Default bounds on the narrative length, in characters
+const (
+	defaultNarrativeMinLength = 20
+	defaultNarrativeMaxLength = 4000
+)
+
+// errInvalidAnalysis is returned when a parsed analysis fails validation
+var errInvalidAnalysis = errors.New("invalid analysis result")
+
+// analysisValidationMode returns ANALYSIS_VALIDATION, defaulting to warn
+func analysisValidationMode() string {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("ANALYSIS_VALIDATION")))
+	switch mode {
+	case "":
+		return validationWarn
+	case validationWarn, validationStrict, validationOff:
+		return mode
+	}
+	log.Printf("Invalid ANALYSIS_VALIDATION %q, using default of %s", mode, validationWarn)
+	return validationWarn
+}
+
+// narrativeLengthBounds returns ANALYSIS_NARRATIVE_MIN_LENGTH and ANALYSIS_NARRATIVE_MAX_LENGTH
+func narrativeLengthBounds() (minLength, maxLength int) {
+	minLength = lengthSetting("ANALYSIS_NARRATIVE_MIN_LENGTH", defaultNarrativeMinLength)
+	maxLength = lengthSetting("ANALYSIS_NARRATIVE_MAX_LENGTH", defaultNarrativeMaxLength)
+	if maxLength < minLength {
+		log.Printf("ANALYSIS_NARRATIVE_MAX_LENGTH %d is below the minimum %d, using default bounds", maxLength, minLength)
+		return defaultNarrativeMinLength, defaultNarrativeMaxLength
+	}
+	return minLength, maxLength
+}
+
+// lengthSetting reads a non-negative length from the environment
+func lengthSetting(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	length, err := strconv.Atoi(value)
+	if err != nil || length < 0 {
+		log.Printf("Invalid %s %q, using default of %d", name, value, fallback)
+		return fallback
+	}
+	return length
+}
+
+// validateAnalysis checks a parsed analysis for results that cannot be right: a
+// score outside 0-100 or a narrative that is empty or outside the length bounds.
+// All problems are reported together.
+func validateAnalysis(score int, narrative string) error {
+	var problems []string
+	if score < 0 || score > 100 {
+		problems = append(problems, fmt.Sprintf("score %d is outside 0-100", score))
+	}
+
+	minLength, maxLength := narrativeLengthBounds()
+	length := len([]rune(strings.TrimSpace(narrative)))
+	switch {
+	case length == 0:
+		problems = append(problems, "narrative is empty")
+	case length < minLength:
+		problems = append(problems, fmt.Sprintf("narrative has %d characters, fewer than %d", length, minLength))
+	case length > maxLength:
+		problems = append(problems, fmt.Sprintf("narrative has %d characters, more than %d", length, maxLength))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errInvalidAnalysis, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkAnalysis validates a freshly parsed analysis according to ANALYSIS_VALIDATION.
+// In strict mode an invalid result becomes the analysis error; in warn mode it is
+// kept and the problem is recorded as a warning for the asset.
+func checkAnalysis(p *pipelineState) {
+	rejected, warning := applyAnalysisValidation("asset "+p.assetID, p.score, p.narrative)
+	switch {
+	case rejected != nil:
+		p.analysisErr = rejected
+	case warning != "":
+		p.analysisWarning = warning
+	}
+}
+
+// applyAnalysisValidation validates a parsed score and narrative according to
+// ANALYSIS_VALIDATION, returning the error that rejects them in strict mode or the
+// warning to record for them in warn mode. subject names what was analyzed in logs.
+func applyAnalysisValidation(subject string, score int, narrative string) (rejected error, warning string) {
+	mode := analysisValidationMode()
+	if mode == validationOff {
+		return nil, ""
+	}
+	err := validateAnalysis(score, narrative)
+	if err == nil {
+		return nil, ""
+	}
+
+	log.Printf("Analysis for %s failed validation (%s mode): %v", subject, mode, err)
+	if mode == validationStrict {
+		return err, ""
+	}
+	return nil, err.Error()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestValidateAnalysis(t *testing.T) {
+	t.Setenv("ANALYSIS_NARRATIVE_MIN_LENGTH", "10")
+	t.Setenv("ANALYSIS_NARRATIVE_MAX_LENGTH", "50")
+
+	testCases := []struct {
+		name        string
+		score       int
+		narrative   string
+		expectError bool
+		expectedMsg string
+	}{
+		{name: "Valid result", score: 98, narrative: "Lighting and shadows look natural."},
+		{name: "Score at bounds", score: 0, narrative: "Clearly generated imagery."},
+		{name: "Score above 100", score: 180, narrative: "Lighting and shadows look natural.", expectError: true, expectedMsg: "score 180"},
+		{name: "Negative score", score: -5, narrative: "Lighting and shadows look natural.", expectError: true, expectedMsg: "score -5"},
+		{name: "Empty narrative", score: 50, narrative: "   ", expectError: true, expectedMsg: "narrative is empty"},
+		{name: "Narrative too short", score: 50, narrative: "ok", expectError: true, expectedMsg: "fewer than 10"},
+		{name: "Narrative too long", score: 50, narrative: strings.Repeat("lorem ", 20), expectError: true, expectedMsg: "more than 50"},
+		{name: "Several problems", score: 101, narrative: "", expectError: true, expectedMsg: "score 101 is outside 0-100; narrative is empty"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAnalysis(tc.score, tc.narrative)
+			if !tc.expectError {
+				if err != nil {
+					t.Errorf("Expected no error, but got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, errInvalidAnalysis) {
+				t.Fatalf("Expected errInvalidAnalysis, but got %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.expectedMsg) {
+				t.Errorf("Expected error to mention %q, but got %v", tc.expectedMsg, err)
+			}
+		})
+	}
+}
+
+func TestAnalyzeStage_ValidationModes(t *testing.T) {
+	const invalid = "Confidence Score: 1.80\n\nJustification: ok"
+	const valid = "Confidence Score: 0.90\n\nJustification: Lighting and shadows look natural."
+
+	testCases := []struct {
+		name            string
+		mode            string
+		analysis        string
+		expectedStatus  string
+		expectWarning   bool
+		expectedFailure bool
+	}{
+		{name: "Valid result", mode: "strict", analysis: valid, expectedStatus: models.StatusCompleted},
+		{name: "Warn keeps invalid result", mode: "", analysis: invalid, expectedStatus: models.StatusCompleted, expectWarning: true},
+		{name: "Strict fails the analysis", mode: "strict", analysis: invalid, expectedStatus: models.StatusPartial, expectedFailure: true},
+		{name: "Off stores without checks", mode: "off", analysis: invalid, expectedStatus: models.StatusCompleted},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubServices(t)
+			t.Setenv("ANALYSIS_VALIDATION", tc.mode)
+			analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+				return tc.analysis, "test-model", nil
+			}
+			var saved *Asset
+			storeAsset = func(ctx context.Context, asset *Asset) error {
+				saved = asset
+				return nil
+			}
+
+			p := &pipelineState{userID: "user-1", assetID: "asset-1", imageData: []byte("image-bytes")}
+			if err := analyzeStage(context.Background(), p); err != nil {
+				t.Fatalf("Expected analyze stage to continue, but got %v", err)
+			}
+			saveStage(context.Background(), p)
+
+			if saved == nil {
+				t.Fatalf("Expected the asset to be saved")
+			}
+			if saved.Status != tc.expectedStatus {
+				t.Errorf("Expected status %q, but got %q", tc.expectedStatus, saved.Status)
+			}
+			if (saved.AnalysisWarning != "") != tc.expectWarning {
+				t.Errorf("Expected warning=%t, but got %q", tc.expectWarning, saved.AnalysisWarning)
+			}
+			if saved.AnalysisFailed != tc.expectedFailure {
+				t.Errorf("Expected analysis_failed=%t, but got %t", tc.expectedFailure, saved.AnalysisFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"proofpix/internal/index"
+	"proofpix/internal/models"
+)
+
+// Keyframe sampling of video uploads, overridable with VIDEO_KEYFRAME_INTERVAL,
+// VIDEO_MAX_FRAMES and VIDEO_MAX_DURATION
+const (
+	defaultKeyframeInterval = 2 * time.Second
+	defaultMaxKeyframes     = 10
+	defaultMaxVideoDuration = time.Minute
+)
+
+// errVideoTooLong is returned for videos longer than VIDEO_MAX_DURATION
+var errVideoTooLong = errors.New("video is too long")
+
+// videoTypes are the video MIME types analyzed through their keyframes
+var videoTypes = map[string]bool{
+	"video/mp4":       true,
+	"video/quicktime": true,
+	"video/webm":      true,
+}
+
+// sniffVideoType detects a video's MIME type from its leading bytes, reporting
+// false unless it is an accepted video type
+func sniffVideoType(data []byte) (string, bool) {
+	mimeType := http.DetectContentType(data)
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" && string(data[8:12]) == "qt  " {
+		mimeType = "video/quicktime"
+	}
+	return mimeType, videoTypes[mimeType]
+}
+
+// keyframe is a still taken from a video upload, encoded as JPEG
+type keyframe struct {
+	offset time.Duration
+	data   []byte
+}
+
+// keyframeOptions bounds keyframe extraction: a frame every interval, at most
+// maxFrames of them, from videos no longer than maxDuration
+type keyframeOptions struct {
+	interval    time.Duration
+	maxFrames   int
+	maxDuration time.Duration
+}
+
+// keyframeOptionsFromEnv returns the configured keyframe sampling
+func keyframeOptionsFromEnv() keyframeOptions {
+	return keyframeOptions{
+		interval:    videoDurationEnv("VIDEO_KEYFRAME_INTERVAL", defaultKeyframeInterval),
+		maxFrames:   maxKeyframes(),
+		maxDuration: videoDurationEnv("VIDEO_MAX_DURATION", defaultMaxVideoDuration),
+	}
+}
+
+// videoDurationEnv returns a positive duration from the environment
+func videoDurationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid %s %q, using default of %v", name, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// maxKeyframes returns VIDEO_MAX_FRAMES, the most keyframes analyzed per video
+func maxKeyframes() int {
+	value := os.Getenv("VIDEO_MAX_FRAMES")
+	if value == "" {
+		return defaultMaxKeyframes
+	}
+	frames, err := strconv.Atoi(value)
+	if err != nil || frames <= 0 {
+		log.Printf("Invalid VIDEO_MAX_FRAMES %q, using default of %d", value, defaultMaxKeyframes)
+		return defaultMaxKeyframes
+	}
+	return frames
+}
+
+// keyframeOffsets returns where keyframes are taken from a video of the given
+// length: its start, then every interval, up to maxFrames
+func keyframeOffsets(duration time.Duration, opts keyframeOptions) []time.Duration {
+	var offsets []time.Duration
+	for offset := time.Duration(0); offset < duration && len(offsets) < opts.maxFrames; offset += opts.interval {
+		offsets = append(offsets, offset)
+	}
+	if len(offsets) == 0 {
+		offsets = append(offsets, 0)
+	}
+	return offsets
+}
+
+// extractKeyframes returns the keyframes of a video. Tests replace it with a fake.
+var extractKeyframes = ffmpegKeyframes
+
+// ffmpegKeyframes probes the video's length with ffprobe and grabs each keyframe
+// with ffmpeg, which must be installed alongside the worker
+func ffmpegKeyframes(ctx context.Context, video []byte, opts keyframeOptions) ([]keyframe, error) {
+	// MP4 and QuickTime files may keep their index at the end, so ffmpeg needs a
+	// seekable file rather than a pipe
+	file, err := os.CreateTemp("", "proofpix-video-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary video file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(video)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write temporary video file: %v", err)
+	}
+
+	probe, err := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", file.Name()).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe video: %v", err)
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(probe)), 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read video duration %q: %v", strings.TrimSpace(string(probe)), err)
+	}
+	duration := time.Duration(seconds * float64(time.Second))
+	if duration > opts.maxDuration {
+		return nil, fmt.Errorf("%w: %v exceeds %v", errVideoTooLong, duration.Round(time.Millisecond), opts.maxDuration)
+	}
+
+	var frames []keyframe
+	for _, offset := range keyframeOffsets(duration, opts) {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64),
+			"-i", file.Name(), "-frames:v", "1", "-f", "image2pipe", "-vcodec", "mjpeg", "pipe:1")
+		cmd.Stderr = &stderr
+		data, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract keyframe at %v: %v: %s", offset, err, strings.TrimSpace(stderr.String()))
+		}
+		if len(data) == 0 {
+			// Offsets past the last decodable frame yield nothing
+			break
+		}
+		frames = append(frames, keyframe{offset: offset, data: data})
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no keyframes could be extracted")
+	}
+	return frames, nil
+}
+
+// isVideo reports whether the upload is a video analyzed through its keyframes
+func (p *pipelineState) isVideo() bool {
+	return len(p.frames) > 0
+}
+
+// previewImage returns the image previews are made from: the upload itself, or a
+// video's first keyframe
+func (p *pipelineState) previewImage() []byte {
+	if p.isVideo() {
+		return p.frames[0].data
+	}
+	return p.imageData
+}
+
+// frameAnalysis holds the Vertex results for one keyframe
+type frameAnalysis struct {
+	text            string
+	modelVersion    string
+	score           int
+	narrative       string
+	analysisWarning string
+	analysisErr     error
+
+	embedding      []float32
+	embeddingModel string
+	embeddingErr   error
+}
+
+// analyzeKeyframes runs the analysis and embedding of every keyframe through the
+// shared Vertex pools and aggregates them into the asset's results: the video scores
+// as its least original frame, and its embedding is the mean of the frame
+// embeddings. Analysis or embedding fails only when it failed for every frame.
+func analyzeKeyframes(ctx context.Context, p *pipelineState) error {
+	results := make([]frameAnalysis, len(p.frames))
+	var wg sync.WaitGroup
+	for i, frame := range p.frames {
+		result := &results[i]
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := analysisPool.do(ctx, func() {
+				result.text, result.modelVersion, result.analysisErr = analyzeImage(frame.data, "image/jpeg")
+			}); err != nil {
+				result.analysisErr = err
+			}
+			if result.analysisErr == nil {
+				if score, narrative, err := parseAnalysis(result.text); err != nil {
+					log.Printf("Failed to parse analysis of keyframe at %v for asset %s: %v", frame.offset, p.assetID, err)
+					result.narrative = result.text
+				} else {
+					// Frames are validated like images; strict mode fails an invalid frame
+					result.score, result.narrative = score, narrative
+					subject := fmt.Sprintf("keyframe at %v of asset %s", frame.offset, p.assetID)
+					result.analysisErr, result.analysisWarning = applyAnalysisValidation(subject, score, narrative)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := embeddingPool.do(ctx, func() {
+				result.embedding, result.embeddingModel, result.embeddingErr = embedWithFallback(frame.data)
+			}); err != nil {
+				result.embeddingErr = err
+			}
+		}()
+	}
+	log.Printf("Waiting for analysis and embedding of %d keyframes for asset %s...", len(p.frames), p.assetID)
+	wg.Wait()
+
+	aggregateKeyframes(p, results)
+
+	if p.analysisErr != nil {
+		log.Printf("Failed to analyze video keyframes: %v", p.analysisErr)
+	} else {
+		tagNarrativeLanguage(ctx, p)
+	}
+	if p.embeddingErr != nil {
+		log.Printf("Failed to embed video keyframes: %v", p.embeddingErr)
+	}
+	recordEvent(ctx, p.assetID, models.StageAnalyzed, p.analysisErr)
+	recordEvent(ctx, p.assetID, models.StageEmbedded, p.embeddingErr)
+
+	if p.analysisErr != nil && p.embeddingErr != nil {
+		return fmt.Errorf("analysis and embedding failed for every keyframe")
+	}
+	return nil
+}
+
+// aggregateKeyframes records the per-frame results and combines them into the
+// asset's analysis and embedding. Only frames embedded by the model that embedded
+// the most of them are averaged, so the embedding lives in a single model's space.
+func aggregateKeyframes(p *pipelineState, results []frameAnalysis) {
+	p.frameResults = make([]models.FrameResult, len(results))
+	embeddingModel := keyframeEmbeddingModel(results)
+	var texts, warnings []string
+	var lowest *frameAnalysis
+	var lowestOffset time.Duration
+	var sum []float32
+	embedded := 0
+	var analysisErr, embeddingErr error
+
+	for i := range results {
+		result, offset := &results[i], p.frames[i].offset
+		p.frameResults[i] = models.FrameResult{
+			OffsetSeconds:    offset.Seconds(),
+			OriginalityScore: result.score,
+			Narrative:        result.narrative,
+			AnalysisFailed:   result.analysisErr != nil,
+			EmbeddingFailed:  result.embeddingErr != nil,
+		}
+
+		if result.analysisErr != nil {
+			analysisErr = result.analysisErr
+		} else {
+			texts = append(texts, fmt.Sprintf("[%v] %s", offset, result.text))
+			if result.analysisWarning != "" {
+				warnings = append(warnings, fmt.Sprintf("keyframe at %v: %s", offset, result.analysisWarning))
+			}
+			if lowest == nil || result.score < lowest.score {
+				lowest, lowestOffset = result, offset
+			}
+		}
+
+		// Frames embedded by another model, such as a fallback, live in a different
+		// space and cannot be averaged in, even when the dimensions agree
+		if result.embeddingErr == nil && (result.embeddingModel != embeddingModel || sum != nil && len(result.embedding) != len(sum)) {
+			result.embeddingErr = fmt.Errorf("embedding is from model %s with %d dimensions, other keyframes used %s", result.embeddingModel, len(result.embedding), embeddingModel)
+			p.frameResults[i].EmbeddingFailed = true
+		}
+		if result.embeddingErr != nil {
+			embeddingErr = result.embeddingErr
+			continue
+		}
+		if sum == nil {
+			sum = make([]float32, len(result.embedding))
+			p.embeddingModel = result.embeddingModel
+		}
+		for j, value := range result.embedding {
+			sum[j] += value
+		}
+		embedded++
+	}
+
+	if lowest == nil {
+		p.analysisErr = fmt.Errorf("analysis failed for all %d keyframes: %v", len(results), analysisErr)
+	} else {
+		p.analysisErr = nil
+		p.analysisText = strings.Join(texts, "\n\n")
+		p.modelVersion = lowest.modelVersion
+		p.score = lowest.score
+		p.narrative = fmt.Sprintf("Lowest scoring of %d keyframes, at %v: %s", len(results), lowestOffset, lowest.narrative)
+		p.analysisWarning = strings.Join(warnings, "; ")
+	}
+
+	if embedded == 0 {
+		p.embeddingErr = fmt.Errorf("embedding failed for all %d keyframes: %v", len(results), embeddingErr)
+		return
+	}
+	p.embeddingErr = nil
+	for j := range sum {
+		sum[j] /= float32(embedded)
+	}
+	// Normalize once here so the saved and indexed vectors are the same
+	p.embedding = index.PrepareVector(sum)
+}
+
+// keyframeEmbeddingModel returns the model that embedded the most keyframes,
+// preferring the earliest frame's model on a tie, or "" when none were embedded
+func keyframeEmbeddingModel(results []frameAnalysis) string {
+	counts := map[string]int{}
+	var chosen string
+	for _, result := range results {
+		if result.embeddingErr != nil {
+			continue
+		}
+		counts[result.embeddingModel]++
+		if chosen == "" || counts[result.embeddingModel] > counts[chosen] {
+			chosen = result.embeddingModel
+		}
+	}
+	return chosen
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// testVideoData is an MP4 header followed by filler
+var testVideoData = []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isomvideo-bytes")
+
+func TestSniffVideoType(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         []byte
+		expectedType string
+		expectedOK   bool
+	}{
+		{name: "MP4", data: testVideoData, expectedType: "video/mp4", expectedOK: true},
+		{name: "QuickTime", data: []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  "), expectedType: "video/quicktime", expectedOK: true},
+		{name: "WebM", data: []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\xf7\x81\x01\x42\xf2\x81\x04\x42\xf3\x81\x08\x42\x82\x84webm"), expectedType: "video/webm", expectedOK: true},
+		{name: "JPEG", data: testImageData, expectedOK: false},
+		{name: "Text", data: []byte("hello"), expectedOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mimeType, ok := sniffVideoType(tc.data)
+			if ok != tc.expectedOK {
+				t.Fatalf("Expected ok %v, but got %v (type %s)", tc.expectedOK, ok, mimeType)
+			}
+			if ok && mimeType != tc.expectedType {
+				t.Errorf("Expected type %s, but got %s", tc.expectedType, mimeType)
+			}
+		})
+	}
+}
+
+func TestKeyframeOffsets(t *testing.T) {
+	opts := keyframeOptions{interval: 2 * time.Second, maxFrames: 4, maxDuration: time.Minute}
+
+	testCases := []struct {
+		name     string
+		duration time.Duration
+		expected []time.Duration
+	}{
+		{name: "Shorter than the interval", duration: 1500 * time.Millisecond, expected: []time.Duration{0}},
+		{name: "Every interval", duration: 5 * time.Second, expected: []time.Duration{0, 2 * time.Second, 4 * time.Second}},
+		{name: "Limited to max frames", duration: 30 * time.Second, expected: []time.Duration{0, 2 * time.Second, 4 * time.Second, 6 * time.Second}},
+		{name: "Unknown duration still takes the first frame", duration: 0, expected: []time.Duration{0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := keyframeOffsets(tc.duration, opts); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected offsets %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestKeyframeOptionsFromEnv(t *testing.T) {
+	t.Setenv("VIDEO_KEYFRAME_INTERVAL", "500ms")
+	t.Setenv("VIDEO_MAX_FRAMES", "0")
+	t.Setenv("VIDEO_MAX_DURATION", "")
+
+	expected := keyframeOptions{interval: 500 * time.Millisecond, maxFrames: defaultMaxKeyframes, maxDuration: defaultMaxVideoDuration}
+	if got := keyframeOptionsFromEnv(); got != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, got)
+	}
+}
+
+func TestProcessImage_VideoKeyframes(t *testing.T) {
+	stubServices(t)
+	t.Setenv("INDEX_METRIC", "")
+	t.Setenv("ANALYSIS_MONTHLY_BUDGET", "100")
+
+	fetchImage = func(ctx context.Context, bucket, userID, assetID string) ([]byte, error) {
+		return testVideoData, nil
+	}
+	var extractOpts keyframeOptions
+	extractKeyframes = func(ctx context.Context, video []byte, opts keyframeOptions) ([]keyframe, error) {
+		extractOpts = opts
+		return []keyframe{
+			{offset: 0, data: []byte("frame-a")},
+			{offset: 2 * time.Second, data: []byte("frame-b")},
+			{offset: 4 * time.Second, data: []byte("frame-c")},
+		}, nil
+	}
+
+	// Each frame gets its own score and a distinct embedding
+	scores := map[string]string{"frame-a": "0.90", "frame-b": "0.40", "frame-c": "0.80"}
+	vectors := map[string][]float32{"frame-a": {3, 0, 0}, "frame-b": {0, 3, 0}, "frame-c": {0, 0, 3}}
+	var mu sync.Mutex
+	var analyzed []string
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		mu.Lock()
+		analyzed = append(analyzed, string(imageData))
+		mu.Unlock()
+		if mimeType != "image/jpeg" {
+			t.Errorf("Expected keyframes to be analyzed as image/jpeg, but got %s", mimeType)
+		}
+		return fmt.Sprintf("Confidence Score: %s\n\nJustification: Looks like %s.", scores[string(imageData)], imageData), "gemini-1.5-flash", nil
+	}
+	embedImage = func(imageData []byte, model string) ([]float32, error) {
+		return vectors[string(imageData)], nil
+	}
+	var charged int
+	reserveUsage = func(ctx context.Context, userID, month string, calls, budget int) (int, bool, error) {
+		charged = calls
+		return calls, true, nil
+	}
+	var saved *Asset
+	storeAsset = func(ctx context.Context, asset *Asset) error {
+		saved = asset
+		return nil
+	}
+
+	state, last := processImage("user-1", "video-1", processOptions{Bucket: defaultUploadBucket})
+	if last.err != nil {
+		t.Fatalf("Expected processing to complete, but stage %s failed: %v", last.stage, last.err)
+	}
+
+	if extractOpts != keyframeOptionsFromEnv() {
+		t.Errorf("Expected the configured keyframe options, but got %+v", extractOpts)
+	}
+	if state.mimeType != "video/mp4" {
+		t.Errorf("Expected the upload to be detected as video/mp4, but got %s", state.mimeType)
+	}
+	if len(analyzed) != 3 {
+		t.Errorf("Expected every keyframe to be analyzed, but got %v", analyzed)
+	}
+	if charged != 6 {
+		t.Errorf("Expected 6 Vertex calls charged for 3 keyframes, but got %d", charged)
+	}
+
+	if saved == nil {
+		t.Fatal("Expected the asset to be saved")
+	}
+	expectedFrames := []models.FrameResult{
+		{OffsetSeconds: 0, OriginalityScore: 90, Narrative: "Looks like frame-a."},
+		{OffsetSeconds: 2, OriginalityScore: 40, Narrative: "Looks like frame-b."},
+		{OffsetSeconds: 4, OriginalityScore: 80, Narrative: "Looks like frame-c."},
+	}
+	if !reflect.DeepEqual(saved.Frames, expectedFrames) {
+		t.Errorf("Expected frames %+v, but got %+v", expectedFrames, saved.Frames)
+	}
+	if saved.Status != models.StatusCompleted || saved.OriginalityScore != 40 {
+		t.Errorf("Expected a completed asset scored as its lowest frame, 40, but got %s with %d", saved.Status, saved.OriginalityScore)
+	}
+	if expected := "Lowest scoring of 3 keyframes, at 2s: Looks like frame-b."; saved.Narrative != expected {
+		t.Errorf("Expected narrative %q, but got %q", expected, saved.Narrative)
+	}
+	if expected := []float32{1, 1, 1}; !reflect.DeepEqual(saved.Embedding, expected) {
+		t.Errorf("Expected the mean frame embedding %v, but got %v", expected, saved.Embedding)
+	}
+}
+
+func TestAggregateKeyframes_PartialFailures(t *testing.T) {
+	t.Setenv("INDEX_METRIC", "")
+	p := &pipelineState{assetID: "video-1", frames: []keyframe{{offset: 0}, {offset: time.Second}, {offset: 2 * time.Second}}}
+	aggregateKeyframes(p, []frameAnalysis{
+		{analysisErr: errors.New("quota exceeded"), embedding: []float32{2, 0}, embeddingModel: "multimodalembedding@001"},
+		{text: "Confidence Score: 0.7", score: 70, narrative: "Fine", embeddingErr: errors.New("timeout")},
+		{text: "Confidence Score: 0.6", score: 60, narrative: "Edited", embedding: []float32{0, 2, 0}, embeddingModel: "fallback"},
+	})
+
+	if p.analysisErr != nil || p.score != 60 {
+		t.Errorf("Expected the analysis to aggregate the frames that succeeded, but got score %d (err %v)", p.score, p.analysisErr)
+	}
+	// The third frame's embedding has another size and cannot be averaged in
+	if p.embeddingErr != nil || !reflect.DeepEqual(p.embedding, []float32{2, 0}) || p.embeddingModel != "multimodalembedding@001" {
+		t.Errorf("Expected the embedding of the first frame, but got %v from %s (err %v)", p.embedding, p.embeddingModel, p.embeddingErr)
+	}
+	failed := []bool{p.frameResults[0].AnalysisFailed, p.frameResults[1].EmbeddingFailed, p.frameResults[2].EmbeddingFailed}
+	if !reflect.DeepEqual(failed, []bool{true, true, true}) {
+		t.Errorf("Expected each frame's failure to be recorded, but got %+v", p.frameResults)
+	}
+
+	aggregateKeyframes(p, []frameAnalysis{
+		{analysisErr: errors.New("quota exceeded"), embeddingErr: errors.New("timeout")},
+		{analysisErr: errors.New("quota exceeded"), embeddingErr: errors.New("timeout")},
+		{analysisErr: errors.New("quota exceeded"), embeddingErr: errors.New("timeout")},
+	})
+	if p.analysisErr == nil || p.embeddingErr == nil {
+		t.Errorf("Expected both to fail when every frame failed, but got %v and %v", p.analysisErr, p.embeddingErr)
+	}
+}
+
+func TestAggregateKeyframes_SingleEmbeddingModel(t *testing.T) {
+	t.Setenv("INDEX_METRIC", "")
+	p := &pipelineState{assetID: "video-1", frames: []keyframe{{offset: 0}, {offset: time.Second}, {offset: 2 * time.Second}}}
+	aggregateKeyframes(p, []frameAnalysis{
+		{score: 70, narrative: "Fine", embedding: []float32{2, 0}, embeddingModel: "fallback"},
+		{score: 60, narrative: "Edited", embedding: []float32{0, 2}, embeddingModel: "multimodalembedding@001"},
+		{score: 80, narrative: "Fine", embedding: []float32{0, 4}, embeddingModel: "multimodalembedding@001"},
+	})
+
+	// The fallback frame has the same size but another model, so it is left out
+	if p.embeddingErr != nil || !reflect.DeepEqual(p.embedding, []float32{0, 3}) || p.embeddingModel != "multimodalembedding@001" {
+		t.Errorf("Expected the mean of the primary model's frames, but got %v from %s (err %v)", p.embedding, p.embeddingModel, p.embeddingErr)
+	}
+	failed := []bool{p.frameResults[0].EmbeddingFailed, p.frameResults[1].EmbeddingFailed, p.frameResults[2].EmbeddingFailed}
+	if !reflect.DeepEqual(failed, []bool{true, false, false}) {
+		t.Errorf("Expected only the fallback frame's embedding to be dropped, but got %+v", p.frameResults)
+	}
+}
+
+func TestAnalyzeKeyframes_Validation(t *testing.T) {
+	stubServices(t)
+	t.Setenv("INDEX_METRIC", "")
+
+	// The second frame's narrative is too short to be a real analysis
+	narratives := map[string]string{
+		"frame-a": "The lighting and grain are consistent throughout.",
+		"frame-b": "Bad.",
+	}
+	analyzeImage = func(imageData []byte, mimeType string) (string, string, error) {
+		return fmt.Sprintf("Confidence Score: %s\n\nJustification: %s", map[string]string{"frame-a": "0.90", "frame-b": "0.30"}[string(imageData)], narratives[string(imageData)]), "gemini-1.5-flash", nil
+	}
+	embedImage = func(imageData []byte, model string) ([]float32, error) {
+		return []float32{1, 0}, nil
+	}
+
+	testCases := []struct {
+		name            string
+		mode            string
+		expectedScore   int
+		expectedFailed  []bool
+		expectedWarning bool
+	}{
+		{name: "Strict fails the invalid frame", mode: validationStrict, expectedScore: 90, expectedFailed: []bool{false, true}},
+		{name: "Warn keeps it with a warning", mode: validationWarn, expectedScore: 30, expectedFailed: []bool{false, false}, expectedWarning: true},
+		{name: "Off keeps it silently", mode: validationOff, expectedScore: 30, expectedFailed: []bool{false, false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ANALYSIS_VALIDATION", tc.mode)
+			p := &pipelineState{assetID: "video-1", frames: []keyframe{
+				{offset: 0, data: []byte("frame-a")},
+				{offset: 2 * time.Second, data: []byte("frame-b")},
+			}}
+			if err := analyzeKeyframes(context.Background(), p); err != nil {
+				t.Fatalf("Expected the keyframes to be analyzed, but got %v", err)
+			}
+
+			if p.analysisErr != nil || p.score != tc.expectedScore {
+				t.Errorf("Expected score %d, but got %d (err %v)", tc.expectedScore, p.score, p.analysisErr)
+			}
+			failed := []bool{p.frameResults[0].AnalysisFailed, p.frameResults[1].AnalysisFailed}
+			if !reflect.DeepEqual(failed, tc.expectedFailed) {
+				t.Errorf("Expected failed frames %v, but got %v", tc.expectedFailed, failed)
+			}
+			if hasWarning := strings.Contains(p.analysisWarning, "keyframe at 2s"); hasWarning != tc.expectedWarning {
+				t.Errorf("Expected a warning for the second frame present=%t, but got %q", tc.expectedWarning, p.analysisWarning)
+			}
+		})
+	}
+}
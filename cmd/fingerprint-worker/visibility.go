@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"proofpix/internal/models"
+)
+
+// defaultAssetVisibility returns ASSET_DEFAULT_VISIBILITY, the visibility of assets
+// whose processing request names none. Unset, assets are public.
+func defaultAssetVisibility() string {
+	value := os.Getenv("ASSET_DEFAULT_VISIBILITY")
+	visibility, err := models.ParseVisibility(value)
+	if err != nil {
+		log.Printf("Invalid ASSET_DEFAULT_VISIBILITY %q, using default of %s", value, models.VisibilityPublic)
+		return models.VisibilityPublic
+	}
+	if visibility == "" {
+		return models.VisibilityPublic
+	}
+	return visibility
+}
+
+// assetVisibility returns the visibility to save an asset with: the requested or
+// previously stored one, or the default
+func assetVisibility(requested string) string {
+	if requested != "" {
+		return requested
+	}
+	return defaultAssetVisibility()
+}
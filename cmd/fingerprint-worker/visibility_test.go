@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestDecodeProcessRequest_Visibility(t *testing.T) {
+	testCases := []struct {
+		name               string
+		visibilityField    string
+		expectedCode       int
+		expectedVisibility string
+	}{
+		{name: "Unset", expectedCode: http.StatusOK, expectedVisibility: ""},
+		{name: "Public", visibilityField: `,"visibility":"public"`, expectedCode: http.StatusOK, expectedVisibility: models.VisibilityPublic},
+		{name: "Private in any case", visibilityField: `,"visibility":"Private"`, expectedCode: http.StatusOK, expectedVisibility: models.VisibilityPrivate},
+		{name: "Unknown", visibilityField: `,"visibility":"secret"`, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `{"user_id":"user-1","asset_id":"asset-1"` + tc.visibilityField + `}`
+			rec := httptest.NewRecorder()
+			req, ok := decodeProcessRequest(rec, httptest.NewRequest(http.MethodPost, "/process", strings.NewReader(body)))
+
+			if !ok {
+				if tc.expectedCode == http.StatusOK {
+					t.Fatalf("Expected the request to be accepted, but got %d: %s", rec.Code, rec.Body.String())
+				}
+				if rec.Code != tc.expectedCode {
+					t.Errorf("Expected status %d, but got %d", tc.expectedCode, rec.Code)
+				}
+				return
+			}
+			if tc.expectedCode != http.StatusOK {
+				t.Fatalf("Expected status %d, but the request was accepted", tc.expectedCode)
+			}
+			if req.options.Visibility != tc.expectedVisibility {
+				t.Errorf("Expected visibility %q, but got %q", tc.expectedVisibility, req.options.Visibility)
+			}
+		})
+	}
+}
+
+func TestAssetVisibility(t *testing.T) {
+	testCases := []struct {
+		name       string
+		requested  string
+		envDefault string
+		expected   string
+	}{
+		{name: "Default is public", expected: models.VisibilityPublic},
+		{name: "Configured default", envDefault: "private", expected: models.VisibilityPrivate},
+		{name: "Invalid default", envDefault: "hidden", expected: models.VisibilityPublic},
+		{name: "Requested overrides default", requested: models.VisibilityPublic, envDefault: "private", expected: models.VisibilityPublic},
+		{name: "Requested private", requested: models.VisibilityPrivate, expected: models.VisibilityPrivate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ASSET_DEFAULT_VISIBILITY", tc.envDefault)
+			if got := assetVisibility(tc.requested); got != tc.expected {
+				t.Errorf("Expected visibility %q, but got %q", tc.expected, got)
+			}
+		})
+	}
+}
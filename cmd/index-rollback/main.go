@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"proofpix/internal/index"
+)
+
+var (
+	bucketName = flag.String("bucket", "proofpix-index", "GCS bucket holding the index snapshots")
+	listOnly   = flag.Bool("list", false, "List the stored snapshots and exit")
+	target     = flag.String("to", "", "Snapshot object to roll back to (default: the one before the current snapshot)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: index-rollback [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		log.Fatalf("Failed to create GCS client: %v", err)
+	}
+	defer client.Close()
+
+	store := index.GCSStore{Client: client, Bucket: *bucketName}
+
+	if *listOnly {
+		if err := listSnapshots(ctx, store); err != nil {
+			log.Fatalf("Failed to list snapshots: %v", err)
+		}
+		return
+	}
+
+	previous, err := index.CurrentSnapshot(ctx, store)
+	if err != nil {
+		log.Fatalf("Failed to read current snapshot: %v", err)
+	}
+	current, err := index.RollbackSnapshot(ctx, store, *target)
+	if err != nil {
+		log.Fatalf("Rollback failed: %v", err)
+	}
+	log.Printf("Rolled back gs://%s/%s from %s to %s", *bucketName, index.LatestPointer, previous, current)
+	log.Println("Restart the fingerprint workers to load the restored index")
+}
+
+// listSnapshots prints the stored snapshots oldest first, marking the current one
+func listSnapshots(ctx context.Context, store index.ObjectStore) error {
+	snapshots, err := index.ListSnapshots(ctx, store)
+	if err != nil {
+		return err
+	}
+	current, err := index.CurrentSnapshot(ctx, store)
+	if err != nil {
+		return err
+	}
+	for _, name := range snapshots {
+		marker := " "
+		if name == current {
+			marker = "*"
+		}
+		fmt.Fprintf(os.Stdout, "%s %s\n", marker, name)
+	}
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -14,7 +13,8 @@ import (
 	"strings"
 
 	"google.golang.org/api/aiplatform/v1"
-	"google.golang.org/api/option"
+
+	"proofpix/internal/vertex"
 )
 
 // ImageResult represents the analysis result for a single image
@@ -90,7 +90,7 @@ func initGeminiClient(ctx context.Context) (*aiplatform.Service, error) {
 	}
 
 	// Initialize the AI Platform service
-	service, err := aiplatform.NewService(ctx, option.WithScopes(aiplatform.CloudPlatformScope))
+	service, err := vertex.NewService(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AI Platform service: %v", err)
 	}
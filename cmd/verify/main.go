@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+var (
+	projectID  = flag.String("project", os.Getenv("GOOGLE_CLOUD_PROJECT"), "Google Cloud project holding the assets collection")
+	logIDFlag  = flag.String("log_id", os.Getenv("TRILLIAN_LOG_ID"), "Trillian log (tree) ID")
+	logServer  = flag.String("log_server", os.Getenv("TRILLIAN_LOG_SERVER_ADDR"), "Address of the Trillian log server")
+	hashAlgo   = flag.String("hash_algorithm", os.Getenv("TRILLIAN_HASH_ALGORITHM"), "HashAlgorithm of the Trillian tree: SHA256, SHA384 or SHA512 (default SHA256)")
+	jsonOutput = flag.Bool("json", false, "Print the result as JSON")
+	trustList  = flag.String("trust_list", os.Getenv("CERTIFICATE_TRUST_LIST"), "JSON trust list of issuer keys to check signed certificates with, instead of resolving did:web issuers")
+	issuerDID  = flag.String("issuer_did", os.Getenv("CERTIFICATE_ISSUER_DID"), "The only did:web DID whose keys are trusted when resolving verification methods (required for certificates with an https issuer)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: verify [flags] <asset-id>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Validate arguments and flags
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *projectID == "" {
+		log.Fatal("--project flag or GOOGLE_CLOUD_PROJECT is required")
+	}
+	if *logServer == "" {
+		log.Fatal("--log_server flag or TRILLIAN_LOG_SERVER_ADDR is required")
+	}
+	logID, err := strconv.ParseInt(*logIDFlag, 10, 64)
+	if err != nil {
+		log.Fatalf("--log_id flag or TRILLIAN_LOG_ID must be a number: %v", err)
+	}
+	hasher, err := leaf.NewHasher(*hashAlgo)
+	if err != nil {
+		log.Fatalf("--hash_algorithm flag or TRILLIAN_HASH_ALGORITHM is invalid: %v", err)
+	}
+
+	var trusted *certificate.Verifier
+	if *trustList != "" {
+		list, err := certificate.LoadTrustList(*trustList)
+		if err != nil {
+			log.Fatalf("--trust_list flag or CERTIFICATE_TRUST_LIST is invalid: %v", err)
+		}
+		if trusted, err = certificate.NewVerifier(list); err != nil {
+			log.Fatalf("--trust_list flag or CERTIFICATE_TRUST_LIST is invalid: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	conn, err := grpc.Dial(*logServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to Trillian log server: %v", err)
+	}
+	defer conn.Close()
+
+	v := &verifier{
+		assets:       firestoreAssets{projectID: *projectID},
+		certificates: gcsCertificates{},
+		log:          trillian.NewTrillianLogClient(conn),
+		logID:        logID,
+		hasher:       hasher,
+		dids:         certificate.WebDIDResolver{},
+		issuerDID:    *issuerDID,
+		trusted:      trusted,
+	}
+
+	result, err := v.Verify(ctx, flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Verification failed: %v", err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+	} else {
+		printResult(os.Stdout, result)
+	}
+
+	if !result.Passed {
+		os.Exit(1)
+	}
+}
+
+// printResult writes a human-readable summary of the verification
+func printResult(w io.Writer, result *Result) {
+	verdict := "FAIL"
+	if result.Passed {
+		verdict = "PASS"
+	}
+	fmt.Fprintf(w, "Asset:  %s\n", result.AssetID)
+	fmt.Fprintf(w, "Score:  %d\n", result.Score)
+	fmt.Fprintf(w, "Result: %s\n", verdict)
+	for _, check := range result.Checks {
+		mark := "ok"
+		if !check.Passed {
+			mark = "FAILED"
+		}
+		fmt.Fprintf(w, "  %-12s %-6s %s\n", check.Name, mark, check.Detail)
+	}
+}
+
+// firestoreAssets reads assets from the Firestore assets collection
+type firestoreAssets struct {
+	projectID string
+}
+
+func (f firestoreAssets) GetAsset(ctx context.Context, assetID string) (*models.Asset, error) {
+	client, err := firestore.NewClient(ctx, f.projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	docSnap, err := client.Collection("assets").Doc(assetID).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var asset models.Asset
+	if err := docSnap.DataTo(&asset); err != nil {
+		return nil, fmt.Errorf("failed to parse asset data: %v", err)
+	}
+	return &asset, nil
+}
+
+// gcsCertificates reads certificates written by the worker to GCS
+type gcsCertificates struct{}
+
+func (gcsCertificates) GetCertificate(ctx context.Context, asset *models.Asset) ([]byte, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %v", err)
+	}
+	defer client.Close()
+
+	// Same layout the worker writes with, then the flat layout for older certificates
+	for _, objectName := range certificate.ObjectPaths(asset) {
+		reader, err := client.Bucket("proofpix-certificates").Object(objectName).NewReader(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		return data, err
+	}
+	return nil, fmt.Errorf("certificate not found")
+}
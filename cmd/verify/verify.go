@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+// assetSource loads asset documents
+type assetSource interface {
+	GetAsset(ctx context.Context, assetID string) (*models.Asset, error)
+}
+
+// certificateSource loads stored certificate JSON
+type certificateSource interface {
+	GetCertificate(ctx context.Context, asset *models.Asset) ([]byte, error)
+}
+
+// logReader is the subset of trillian.TrillianLogClient used to check inclusion
+type logReader interface {
+	GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error)
+	GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error)
+}
+
+// Check is the outcome of a single verification step
+type Check struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Result is the outcome of verifying an asset
+type Result struct {
+	AssetID   string  `json:"asset_id"`
+	Passed    bool    `json:"passed"`
+	Score     int     `json:"score"`
+	LeafIndex int64   `json:"leaf_index,omitempty"`
+	TreeSize  uint64  `json:"tree_size,omitempty"`
+	RootHash  string  `json:"root_hash,omitempty"`
+	Checks    []Check `json:"checks"`
+}
+
+// verifier checks an asset's certificate and its inclusion in the Trillian log
+type verifier struct {
+	assets       assetSource
+	certificates certificateSource
+	log          logReader
+	logID        int64
+	// hasher rebuilds leaves with the hash algorithm of the log's tree
+	hasher leaf.Hasher
+	// dids resolves DID verification methods of signed credentials
+	dids certificate.DIDResolver
+	// issuerDID, when set, is the only DID whose keys are trusted when resolving
+	// verification methods; credentials with an https issuer need it
+	issuerDID string
+	// trusted, when set, checks signed credentials against a static trust list
+	// instead of resolving DIDs. Without either, signed credentials fail.
+	trusted *certificate.Verifier
+}
+
+// addCheck records a step and reports whether it passed
+func (r *Result) addCheck(name string, err error, detail string) bool {
+	check := Check{Name: name, Passed: err == nil, Detail: detail}
+	if err != nil {
+		check.Detail = err.Error()
+	}
+	r.Checks = append(r.Checks, check)
+	return err == nil
+}
+
+// Verify runs every check for an asset. Failed checks are reported in the
+// Result; the error is only returned when the asset itself cannot be loaded.
+func (v *verifier) Verify(ctx context.Context, assetID string) (*Result, error) {
+	asset, err := v.assets.GetAsset(ctx, assetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load asset %s: %v", assetID, err)
+	}
+
+	result := &Result{AssetID: assetID, Score: asset.OriginalityScore, LeafIndex: asset.TrillianLeafIndex}
+
+	if asset.IsDeleted() {
+		result.addCheck("asset", fmt.Errorf("asset has been deleted"), "")
+		return result, nil
+	}
+	result.addCheck("asset", nil, fmt.Sprintf("status %s", asset.Status))
+
+	// The stored certificate must still describe this asset
+	certificateJSON, err := v.certificates.GetCertificate(ctx, asset)
+	if err == nil {
+		err = v.checkCertificate(ctx, certificateJSON, asset)
+	}
+	if !result.addCheck("certificate", err, "proofValue matches asset") {
+		return result, nil
+	}
+
+	if asset.TrillianLeafIndex == 0 {
+		result.addCheck("inclusion", fmt.Errorf("asset has not been logged yet"), "")
+		return result, nil
+	}
+
+	// Rebuild the leaf exactly as the worker logged it
+	leafValue, err := v.hasher.Value(asset.TrillianLeafFormat, certificateJSON)
+	if !result.addCheck("leaf", err, fmt.Sprintf("index %d", asset.TrillianLeafIndex)) {
+		return result, nil
+	}
+
+	// Verify the inclusion proof against the latest signed root
+	root, err := v.latestRoot(ctx)
+	if !result.addCheck("root", err, "") {
+		return result, nil
+	}
+	result.TreeSize = root.TreeSize
+	result.RootHash = hex.EncodeToString(root.RootHash)
+	result.Checks[len(result.Checks)-1].Detail = fmt.Sprintf("tree size %d", root.TreeSize)
+
+	err = v.checkInclusion(ctx, asset.TrillianLeafIndex, root, leafValue)
+	if !result.addCheck("inclusion", err, "inclusion proof matches root") {
+		return result, nil
+	}
+
+	result.Passed = true
+	return result, nil
+}
+
+// checkCertificate verifies a stored credential against its asset, with the trust
+// list when one is configured and otherwise by resolving the issuer's DID
+func (v *verifier) checkCertificate(ctx context.Context, certificateJSON []byte, asset *models.Asset) error {
+	switch {
+	case v.trusted != nil:
+		return v.trusted.VerifyJSON(certificateJSON, asset)
+	case v.dids != nil:
+		return certificate.VerifyJSONWithResolver(ctx, certificateJSON, asset, v.dids, v.issuerDID)
+	}
+	return certificate.VerifyJSON(certificateJSON, asset)
+}
+
+// latestRoot fetches and decodes the latest signed log root
+func (v *verifier) latestRoot(ctx context.Context) (*types.LogRootV1, error) {
+	response, err := v.log.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: v.logID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest log root: %v", err)
+	}
+	if response.SignedLogRoot == nil {
+		return nil, fmt.Errorf("log returned no signed root")
+	}
+
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(response.SignedLogRoot.LogRoot); err != nil {
+		return nil, fmt.Errorf("failed to decode log root: %v", err)
+	}
+	return &root, nil
+}
+
+// checkInclusion fetches the inclusion proof for a leaf and verifies it against the root
+func (v *verifier) checkInclusion(ctx context.Context, leafIndex int64, root *types.LogRootV1, leafValue []byte) error {
+	if uint64(leafIndex) >= root.TreeSize {
+		return fmt.Errorf("leaf %d is not yet integrated into tree of size %d", leafIndex, root.TreeSize)
+	}
+
+	response, err := v.log.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{
+		LogId:     v.logID,
+		LeafIndex: leafIndex,
+		TreeSize:  int64(root.TreeSize),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get inclusion proof: %v", err)
+	}
+	if response.Proof == nil {
+		return fmt.Errorf("log returned no inclusion proof")
+	}
+
+	return v.hasher.VerifyInclusion(leafIndex, int64(root.TreeSize), v.hasher.HashLeaf(leafValue), response.Proof.Hashes, root.RootHash)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+	"proofpix/internal/models"
+)
+
+type fakeAssets map[string]*models.Asset
+
+func (f fakeAssets) GetAsset(ctx context.Context, assetID string) (*models.Asset, error) {
+	asset, ok := f[assetID]
+	if !ok {
+		return nil, fmt.Errorf("not found")
+	}
+	return asset, nil
+}
+
+type fakeCertificates map[string][]byte
+
+func (f fakeCertificates) GetCertificate(ctx context.Context, asset *models.Asset) ([]byte, error) {
+	data, ok := f[asset.ID]
+	if !ok {
+		return nil, fmt.Errorf("certificate not found")
+	}
+	return data, nil
+}
+
+// mockLog serves a fixed signed root and inclusion proof
+type mockLog struct {
+	root  types.LogRootV1
+	proof [][]byte
+}
+
+func (m *mockLog) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	logRoot, err := m.root.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &trillian.SignedLogRoot{LogRoot: logRoot}}, nil
+}
+
+func (m *mockLog) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{LeafIndex: in.LeafIndex, Hashes: m.proof}}, nil
+}
+
+// newMockLog builds a three leaf log with the given value at index 2
+func newMockLog(leafValue []byte) *mockLog {
+	h0, h1, h2 := leaf.HashLeaf([]byte("leaf-0")), leaf.HashLeaf([]byte("leaf-1")), leaf.HashLeaf(leafValue)
+	left := leaf.HashChildren(h0, h1)
+	return &mockLog{
+		root:  types.LogRootV1{TreeSize: 3, RootHash: leaf.HashChildren(left, h2)},
+		proof: [][]byte{left},
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	base := models.Asset{
+		ID:                "asset-1",
+		UserID:            "user-1",
+		Status:            models.StatusCompleted,
+		CreatedAt:         time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore:  9,
+		TrillianLeafIndex: 2,
+	}
+	credential, err := certificate.Generate(&base)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	stored, _ := json.MarshalIndent(credential, "", "  ")
+	credential.Proof.ProofValue = "tampered"
+	tampered, _ := json.MarshalIndent(credential, "", "  ")
+
+	hashLeaf, _ := leaf.Value(leaf.FormatHash, stored)
+	certificateLeaf, _ := leaf.Value(leaf.FormatCertificate, stored)
+
+	testCases := []struct {
+		name         string
+		format       string
+		leafIndex    int64
+		certificate  []byte
+		log          *mockLog
+		expectPassed bool
+		failedCheck  string
+	}{
+		{name: "Hash leaf verifies", format: leaf.FormatHash, leafIndex: 2, certificate: stored, log: newMockLog(hashLeaf), expectPassed: true},
+		{name: "Certificate leaf verifies", format: leaf.FormatCertificate, leafIndex: 2, certificate: stored, log: newMockLog(certificateLeaf), expectPassed: true},
+		{name: "Tampered certificate", format: leaf.FormatHash, leafIndex: 2, certificate: tampered, log: newMockLog(hashLeaf), failedCheck: "certificate"},
+		{name: "Different leaf in log", format: leaf.FormatHash, leafIndex: 2, certificate: stored, log: newMockLog([]byte("forged")), failedCheck: "inclusion"},
+		{name: "Not yet logged", format: leaf.FormatHash, leafIndex: 0, certificate: stored, log: newMockLog(hashLeaf), failedCheck: "inclusion"},
+		{name: "Not yet integrated", format: leaf.FormatHash, leafIndex: 5, certificate: stored, log: newMockLog(hashLeaf), failedCheck: "inclusion"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset := base
+			asset.TrillianLeafFormat = tc.format
+			asset.TrillianLeafIndex = tc.leafIndex
+
+			v := &verifier{
+				assets:       fakeAssets{"asset-1": &asset},
+				certificates: fakeCertificates{"asset-1": tc.certificate},
+				log:          tc.log,
+				logID:        42,
+			}
+
+			result, err := v.Verify(context.Background(), "asset-1")
+			if err != nil {
+				t.Fatalf("Verify() failed: %v", err)
+			}
+			if result.Passed != tc.expectPassed {
+				t.Errorf("Expected passed to be %t, but got %t: %+v", tc.expectPassed, result.Passed, result.Checks)
+			}
+			if result.Score != 9 {
+				t.Errorf("Expected score 9, but got %d", result.Score)
+			}
+			if tc.failedCheck != "" {
+				last := result.Checks[len(result.Checks)-1]
+				if last.Name != tc.failedCheck || last.Passed {
+					t.Errorf("Expected failed %q check, but got %+v", tc.failedCheck, last)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifier_UnknownAsset(t *testing.T) {
+	v := &verifier{assets: fakeAssets{}, certificates: fakeCertificates{}, log: &mockLog{}}
+	if _, err := v.Verify(context.Background(), "missing"); err == nil {
+		t.Errorf("Expected an error for an unknown asset")
+	}
+}
+
+func TestPrintResult(t *testing.T) {
+	result := &Result{
+		AssetID: "asset-1",
+		Passed:  false,
+		Score:   7,
+		Checks:  []Check{{Name: "certificate", Passed: false, Detail: "proofValue does not match asset"}},
+	}
+
+	var out bytes.Buffer
+	printResult(&out, result)
+
+	for _, expected := range []string{"asset-1", "Score:  7", "Result: FAIL", "certificate", "FAILED"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestVerifier_SignedCertificate(t *testing.T) {
+	asset := &models.Asset{ID: "asset-1", UserID: "user-1", Status: models.StatusCompleted, CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), OriginalityScore: 9}
+	public, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	signer, err := certificate.NewSigningConfig(certificate.AlgorithmEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "https://proofpix.com/keys/1")
+	if err != nil {
+		t.Fatalf("Failed to create signing config: %v", err)
+	}
+	credential, _ := certificate.Generate(asset)
+	if err := signer.Sign(credential); err != nil {
+		t.Fatalf("Failed to sign certificate: %v", err)
+	}
+	signed, _ := json.Marshal(credential)
+	credential.Proof.ProofValue = "uZm9yZ2Vk"
+	forged, _ := json.Marshal(credential)
+
+	trusted, err := certificate.NewVerifier(certificate.TrustList{Keys: []certificate.TrustedKey{{
+		VerificationMethod: "https://proofpix.com/keys/1",
+		PublicKeyJWK:       &certificate.JWK{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(public)},
+	}}})
+	if err != nil {
+		t.Fatalf("NewVerifier() failed: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		certificate  []byte
+		trusted      *certificate.Verifier
+		expectPassed bool
+	}{
+		{name: "Signed with a trusted key", certificate: signed, trusted: trusted, expectPassed: true},
+		{name: "Forged signature", certificate: forged, trusted: trusted},
+		{name: "Signed without a trust list", certificate: signed},
+		{name: "Forged without a trust list", certificate: forged},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := &verifier{
+				assets:       fakeAssets{"asset-1": asset},
+				certificates: fakeCertificates{"asset-1": tc.certificate},
+				log:          &mockLog{},
+				dids:         certificate.WebDIDResolver{},
+				trusted:      tc.trusted,
+			}
+
+			result, err := v.Verify(context.Background(), "asset-1")
+			if err != nil {
+				t.Fatalf("Verify() failed: %v", err)
+			}
+			check := result.Checks[1]
+			if check.Name != "certificate" || check.Passed != tc.expectPassed {
+				t.Errorf("Expected the certificate check to pass=%t, but got %+v", tc.expectPassed, check)
+			}
+		})
+	}
+}
+
+// didDocuments is a DIDResolver serving fixed documents
+type didDocuments map[string]*certificate.DIDDocument
+
+func (d didDocuments) Resolve(ctx context.Context, did string) (*certificate.DIDDocument, error) {
+	if document, ok := d[did]; ok {
+		return document, nil
+	}
+	return nil, fmt.Errorf("%w: %s", certificate.ErrDIDResolution, did)
+}
+
+func TestVerifier_PinnedIssuerDID(t *testing.T) {
+	t.Setenv("CERTIFICATE_ISSUER_DID", "")
+	asset := &models.Asset{ID: "asset-1", UserID: "user-1", Status: models.StatusCompleted, CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), OriginalityScore: 9}
+
+	// Anyone can publish a DID document and sign an https-issued credential with it
+	sign := func(did string) ([]byte, *certificate.DIDDocument) {
+		public, key, _ := ed25519.GenerateKey(rand.Reader)
+		der, _ := x509.MarshalPKCS8PrivateKey(key)
+		signer, err := certificate.NewSigningConfig(certificate.AlgorithmEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), did+"#key-1")
+		if err != nil {
+			t.Fatalf("Failed to create signing config: %v", err)
+		}
+		credential, _ := certificate.Generate(asset)
+		if err := signer.Sign(credential); err != nil {
+			t.Fatalf("Failed to sign certificate: %v", err)
+		}
+		document, err := certificate.NewDIDDocument(did, certificate.DIDKey{Fragment: "key-1", PublicKey: public})
+		if err != nil {
+			t.Fatalf("NewDIDDocument() failed: %v", err)
+		}
+		data, _ := json.Marshal(credential)
+		return data, document
+	}
+	official, officialDocument := sign("did:web:proofpix.com")
+	impostor, impostorDocument := sign("did:web:attacker.example")
+	resolver := didDocuments{"did:web:proofpix.com": officialDocument, "did:web:attacker.example": impostorDocument}
+
+	testCases := []struct {
+		name         string
+		certificate  []byte
+		issuerDID    string
+		expectPassed bool
+	}{
+		{name: "Key of the pinned DID", certificate: official, issuerDID: "did:web:proofpix.com", expectPassed: true},
+		{name: "Key of another DID", certificate: impostor, issuerDID: "did:web:proofpix.com"},
+		{name: "No pinned DID", certificate: impostor},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := &verifier{
+				assets:       fakeAssets{"asset-1": asset},
+				certificates: fakeCertificates{"asset-1": tc.certificate},
+				log:          &mockLog{},
+				dids:         resolver,
+				issuerDID:    tc.issuerDID,
+			}
+
+			result, err := v.Verify(context.Background(), "asset-1")
+			if err != nil {
+				t.Fatalf("Verify() failed: %v", err)
+			}
+			check := result.Checks[1]
+			if check.Name != "certificate" || check.Passed != tc.expectPassed {
+				t.Errorf("Expected the certificate check to pass=%t, but got %+v", tc.expectPassed, check)
+			}
+		})
+	}
+}
@@ -9,9 +9,11 @@ require (
 	cloud.google.com/go/storage v1.52.0
 	firebase.google.com/go/v4 v4.14.1
 	github.com/DataIntelligenceCrew/go-faiss v0.2.0
+	github.com/HugoSmits86/nativewebp v1.2.0
 	github.com/google/uuid v1.6.0
 	github.com/rs/cors v1.11.1
 	github.com/tdewolff/canvas v0.0.0-20250728095813-50d4cb1eee71
+	golang.org/x/image v0.27.0
 	google.golang.org/api v0.243.0
 )
 
@@ -72,7 +74,6 @@ require (
 	go.opentelemetry.io/otel/sdk/metric v1.36.0 // indirect
 	go.opentelemetry.io/otel/trace v1.36.0 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
-	golang.org/x/image v0.27.0 // indirect
 	golang.org/x/net v0.42.0 // indirect
 	golang.org/x/oauth2 v0.30.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
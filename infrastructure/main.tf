@@ -304,6 +304,72 @@ resource "google_firestore_database" "proofpix_db" {
   depends_on = [google_project_service.required_apis]
 }
 
+# Composite index for counting a user's assets against their quota, which filters
+# out deleted assets and those awaiting upload by status
+resource "google_firestore_index" "asset_quota_count" {
+  project    = var.project_id
+  database   = google_firestore_database.proofpix_db.name
+  collection = "assets"
+
+  fields {
+    field_path = "user_id"
+    order      = "ASCENDING"
+  }
+  fields {
+    field_path = "status"
+    order      = "ASCENDING"
+  }
+}
+
+# Composite indexes for listing assets by a metadata tag. Firestore needs one per tag
+# key, since the key is part of the field path; filtering by an unindexed key is
+# rejected with a validation error.
+resource "google_firestore_index" "asset_tag_by_user" {
+  for_each = toset(var.asset_metadata_tag_keys)
+
+  project    = var.project_id
+  database   = google_firestore_database.proofpix_db.name
+  collection = "assets"
+
+  fields {
+    field_path = "user_id"
+    order      = "ASCENDING"
+  }
+  fields {
+    field_path = "metadata.${each.value}"
+    order      = "ASCENDING"
+  }
+  fields {
+    field_path = "created_at"
+    order      = "DESCENDING"
+  }
+  fields {
+    field_path = "__name__"
+    order      = "DESCENDING"
+  }
+}
+
+resource "google_firestore_index" "asset_tag" {
+  for_each = toset(var.asset_metadata_tag_keys)
+
+  project    = var.project_id
+  database   = google_firestore_database.proofpix_db.name
+  collection = "assets"
+
+  fields {
+    field_path = "metadata.${each.value}"
+    order      = "ASCENDING"
+  }
+  fields {
+    field_path = "created_at"
+    order      = "DESCENDING"
+  }
+  fields {
+    field_path = "__name__"
+    order      = "DESCENDING"
+  }
+}
+
 # Cloud Storage Bucket for asset uploads
 resource "google_storage_bucket" "proofpix_assets_upload" {
   name     = "${var.project_name}-assets-upload-${var.environment}-${random_id.bucket_suffix.hex}"
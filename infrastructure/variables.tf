@@ -25,4 +25,9 @@ variable "environment" {
   description = "The deployment environment (dev, staging, prod)"
   type        = string
   default     = "dev"
-} 
\ No newline at end of file
+} 
+variable "asset_metadata_tag_keys" {
+  description = "Asset metadata keys that GET /api/v1/assets?tag=key:value may filter on; each needs its own composite index"
+  type        = list(string)
+  default     = []
+}
@@ -3,12 +3,14 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
@@ -31,73 +33,103 @@ type FirebaseClient struct {
 }
 
 var (
-	firebaseClient *FirebaseClient
-	once           sync.Once
+	// firebaseClient holds the client once initialization has succeeded
+	firebaseClient atomic.Pointer[FirebaseClient]
+	// initMu serializes initialization attempts so concurrent callers don't race
+	initMu sync.Mutex
+	// newFirebaseClient creates the client. Tests replace it to simulate failures.
+	newFirebaseClient = createFirebaseClient
 )
 
-// ErrorResponse represents an error response
+// ErrFirebaseConfig is returned when Firebase cannot be initialized because of
+// missing configuration, which retrying will not fix
+var ErrFirebaseConfig = errors.New("firebase configuration error")
+
+// Error codes written by the middleware, shared with the API's error responses
+const (
+	ErrCodeUnauthorized = "UNAUTHORIZED"
+	ErrCodeInternal     = "INTERNAL_ERROR"
+)
+
+// ErrorResponse represents an error response. Success, Message and Code match the
+// shape of the API's own error responses.
 type ErrorResponse struct {
+	Success bool   `json:"success"`
 	Error   string `json:"error"`
 	Message string `json:"message"`
+	Code    string `json:"code"`
 }
 
-// InitFirebase initializes the Firebase client using environment variables
+// InitFirebase initializes the Firebase client using environment variables.
+// A successful initialization happens once; a failed one is not remembered, so
+// the next call tries again after a transient error such as a credential fetch
+// timeout has cleared.
 func InitFirebase() error {
-	var err error
-	once.Do(func() {
-		projectID := os.Getenv("FIREBASE_PROJECT_ID")
-		if projectID == "" {
-			projectID = os.Getenv("PROJECT_ID") // Fallback to PROJECT_ID from Terraform
-		}
+	if firebaseClient.Load() != nil {
+		return nil
+	}
 
-		if projectID == "" {
-			err = fmt.Errorf("FIREBASE_PROJECT_ID or PROJECT_ID environment variable is required")
-			return
-		}
+	initMu.Lock()
+	defer initMu.Unlock()
+	// Another caller may have finished initializing while we waited
+	if firebaseClient.Load() != nil {
+		return nil
+	}
 
-		// For Cloud Run, we can use Application Default Credentials
-		// which are automatically available in the GCP environment
-		ctx := context.Background()
-		
-		// Try to initialize with service account key if provided
-		serviceAccountKey := os.Getenv("FIREBASE_SERVICE_ACCOUNT_KEY")
-		var app *firebase.App
-		
-		if serviceAccountKey != "" {
-			log.Println("Initializing Firebase with service account key")
-			opt := option.WithCredentialsJSON([]byte(serviceAccountKey))
-			config := &firebase.Config{ProjectID: projectID}
-			app, err = firebase.NewApp(ctx, config, opt)
-		} else {
-			log.Println("Initializing Firebase with Application Default Credentials")
-			config := &firebase.Config{ProjectID: projectID}
-			app, err = firebase.NewApp(ctx, config)
-		}
+	client, err := newFirebaseClient(context.Background())
+	if err != nil {
+		return err
+	}
+	firebaseClient.Store(client)
+	return nil
+}
 
-		if err != nil {
-			err = fmt.Errorf("error initializing firebase app: %v", err)
-			return
-		}
+// createFirebaseClient builds a Firebase Auth client from the environment
+func createFirebaseClient(ctx context.Context) (*FirebaseClient, error) {
+	projectID := os.Getenv("FIREBASE_PROJECT_ID")
+	if projectID == "" {
+		projectID = os.Getenv("PROJECT_ID") // Fallback to PROJECT_ID from Terraform
+	}
 
-		authClient, authErr := app.Auth(ctx)
-		if authErr != nil {
-			err = fmt.Errorf("error getting auth client: %v", authErr)
-			return
-		}
+	if projectID == "" {
+		return nil, fmt.Errorf("%w: FIREBASE_PROJECT_ID or PROJECT_ID environment variable is required", ErrFirebaseConfig)
+	}
 
-		firebaseClient = &FirebaseClient{client: authClient}
-		log.Printf("Firebase initialized successfully for project: %s", projectID)
-	})
+	// For Cloud Run, we can use Application Default Credentials
+	// which are automatically available in the GCP environment
+	config := &firebase.Config{ProjectID: projectID}
+	var app *firebase.App
+	var err error
+
+	// Try to initialize with service account key if provided
+	serviceAccountKey := os.Getenv("FIREBASE_SERVICE_ACCOUNT_KEY")
+	if serviceAccountKey != "" {
+		log.Println("Initializing Firebase with service account key")
+		app, err = firebase.NewApp(ctx, config, option.WithCredentialsJSON([]byte(serviceAccountKey)))
+	} else {
+		log.Println("Initializing Firebase with Application Default Credentials")
+		app, err = firebase.NewApp(ctx, config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error initializing firebase app: %v", err)
+	}
 
-	return err
+	authClient, err := app.Auth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting auth client: %v", err)
+	}
+
+	log.Printf("Firebase initialized successfully for project: %s", projectID)
+	return &FirebaseClient{client: authClient}, nil
 }
 
-// GetFirebaseClient returns the singleton Firebase client
+// GetFirebaseClient returns the singleton Firebase client, initializing it first
+// if no earlier attempt has succeeded
 func GetFirebaseClient() (*FirebaseClient, error) {
-	if firebaseClient == nil {
-		return nil, fmt.Errorf("firebase client not initialized. Call InitFirebase() first")
+	if err := InitFirebase(); err != nil {
+		return nil, fmt.Errorf("firebase client not initialized: %v", err)
 	}
-	return firebaseClient, nil
+	return firebaseClient.Load(), nil
 }
 
 // CreateCustomToken creates a custom Firebase token for testing
@@ -153,8 +185,58 @@ func VerifyFirebaseJWT(next http.Handler) http.Handler {
 	})
 }
 
+// Policies for a token OptionalFirebaseJWT cannot verify, set with OPTIONAL_AUTH_POLICY
+const (
+	// PolicyFailOpen serves the request anonymously, as if no token had been sent
+	PolicyFailOpen = "fail-open"
+	// PolicyFailClosedOnPresent rejects the request with 401. Requests without a
+	// token are still served anonymously.
+	PolicyFailClosedOnPresent = "fail-closed-on-present"
+)
+
+// errAuthUnavailable is returned by verifyIDToken when the Firebase client cannot
+// be initialized, so the token could not be checked at all
+var errAuthUnavailable = errors.New("authentication service unavailable")
+
+// errMalformedAuthorization is returned for an Authorization header that does not
+// carry a bearer token
+var errMalformedAuthorization = errors.New("expected format: Bearer <token>")
+
+// verifyIDToken verifies a Firebase ID token. Tests replace it with a fake.
+var verifyIDToken = func(ctx context.Context, token string) (*auth.Token, error) {
+	client, err := GetFirebaseClient()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errAuthUnavailable, err)
+	}
+	return client.client.VerifyIDToken(ctx, token)
+}
+
+// OptionalAuthPolicyFromEnv returns the OPTIONAL_AUTH_POLICY, PolicyFailOpen when unset
+func OptionalAuthPolicyFromEnv() (string, error) {
+	switch policy := strings.ToLower(strings.TrimSpace(os.Getenv("OPTIONAL_AUTH_POLICY"))); policy {
+	case "":
+		return PolicyFailOpen, nil
+	case PolicyFailOpen, PolicyFailClosedOnPresent:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid OPTIONAL_AUTH_POLICY %q: expected %s or %s", policy, PolicyFailOpen, PolicyFailClosedOnPresent)
+	}
+}
+
+// optionalAuthPolicy returns the configured policy, falling back to PolicyFailOpen
+func optionalAuthPolicy() string {
+	policy, err := OptionalAuthPolicyFromEnv()
+	if err != nil {
+		log.Printf("%v, using %s", err, PolicyFailOpen)
+		return PolicyFailOpen
+	}
+	return policy
+}
+
 // OptionalFirebaseJWT creates a middleware that optionally verifies Firebase JWT tokens
-// This is useful for endpoints that can work with or without authentication
+// This is useful for endpoints that can work with or without authentication. A token
+// that is sent but cannot be verified is handled by OPTIONAL_AUTH_POLICY: ignored
+// with PolicyFailOpen, or rejected with PolicyFailClosedOnPresent.
 func OptionalFirebaseJWT(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -166,24 +248,40 @@ func OptionalFirebaseJWT(next http.Handler) http.Handler {
 		}
 
 		// If auth header exists, try to verify it
+		var decodedToken *auth.Token
+		err := errMalformedAuthorization
 		parts := strings.Split(authHeader, " ")
-		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
-			token := parts[1]
-			
-			client, err := GetFirebaseClient()
-			if err == nil {
-				decodedToken, err := client.client.VerifyIDToken(context.Background(), token)
-				if err == nil {
-					// Add user information to request context if token is valid
-					ctx := context.WithValue(r.Context(), UserIDKey, decodedToken.UID)
-					ctx = context.WithValue(ctx, UserKey, decodedToken)
-					r = r.WithContext(ctx)
-				}
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" && parts[1] != "" {
+			decodedToken, err = verifyIDToken(r.Context(), parts[1])
+		}
+		if err == nil {
+			// Add user information to request context if token is valid
+			ctx := context.WithValue(r.Context(), UserIDKey, decodedToken.UID)
+			ctx = context.WithValue(ctx, UserKey, decodedToken)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// An outage is logged apart from a bad token, which may be an attack
+		policy := optionalAuthPolicy()
+		unavailable := errors.Is(err, errAuthUnavailable)
+		if policy == PolicyFailOpen {
+			if unavailable {
+				log.Printf("Could not verify optional token for %s %s, continuing anonymously (%s): %v", r.Method, r.URL.Path, policy, err)
+			} else {
+				log.Printf("Ignoring invalid optional token for %s %s, continuing anonymously (%s): %v", r.Method, r.URL.Path, policy, err)
 			}
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		// Continue regardless of token validity
-		next.ServeHTTP(w, r)
+		if unavailable {
+			log.Printf("Could not verify optional token for %s %s, rejecting (%s): %v", r.Method, r.URL.Path, policy, err)
+			respondWithError(w, http.StatusInternalServerError, "Authentication service unavailable", "Internal server error")
+			return
+		}
+		log.Printf("Rejecting invalid optional token for %s %s (%s): %v", r.Method, r.URL.Path, policy, err)
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", "Token verification failed")
 	})
 }
 
@@ -204,9 +302,14 @@ func respondWithError(w http.ResponseWriter, statusCode int, error, message stri
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	
+	code := ErrCodeInternal
+	if statusCode == http.StatusUnauthorized {
+		code = ErrCodeUnauthorized
+	}
 	response := ErrorResponse{
 		Error:   error,
 		Message: message,
+		Code:    code,
 	}
 	
 	if err := json.NewEncoder(w).Encode(response); err != nil {
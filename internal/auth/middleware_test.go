@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// useClientFactory replaces client creation and clears any initialized client
+func useClientFactory(t *testing.T, factory func(ctx context.Context) (*FirebaseClient, error)) {
+	t.Helper()
+	orig := newFirebaseClient
+	newFirebaseClient = factory
+	firebaseClient.Store(nil)
+	t.Cleanup(func() {
+		newFirebaseClient = orig
+		firebaseClient.Store(nil)
+	})
+}
+
+func TestInitFirebase_RetriesAfterFailure(t *testing.T) {
+	var attempts int
+	client := &FirebaseClient{}
+	useClientFactory(t, func(ctx context.Context) (*FirebaseClient, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("metadata server timeout")
+		}
+		return client, nil
+	})
+
+	if err := InitFirebase(); err == nil {
+		t.Fatalf("Expected the first initialization to fail")
+	}
+	if _, err := GetFirebaseClient(); err != nil {
+		t.Fatalf("Expected GetFirebaseClient to retry and succeed, but got %v", err)
+	}
+
+	got, err := GetFirebaseClient()
+	if err != nil || got != client {
+		t.Errorf("Expected the initialized client, but got %v (err %v)", got, err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 initialization attempts, but got %d", attempts)
+	}
+}
+
+func TestInitFirebase_ConcurrentCallersInitializeOnce(t *testing.T) {
+	var attempts atomic.Int32
+	useClientFactory(t, func(ctx context.Context) (*FirebaseClient, error) {
+		attempts.Add(1)
+		return &FirebaseClient{}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := InitFirebase(); err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("Expected a single successful initialization, but got %d", got)
+	}
+}
+
+func TestCreateFirebaseClient_MissingProject(t *testing.T) {
+	t.Setenv("FIREBASE_PROJECT_ID", "")
+	t.Setenv("PROJECT_ID", "")
+
+	_, err := createFirebaseClient(context.Background())
+	if !errors.Is(err, ErrFirebaseConfig) {
+		t.Errorf("Expected ErrFirebaseConfig, but got %v", err)
+	}
+}
+
+// useTokenVerifier replaces ID token verification for the duration of the test
+func useTokenVerifier(t *testing.T, verify func(ctx context.Context, token string) (*auth.Token, error)) {
+	t.Helper()
+	orig := verifyIDToken
+	verifyIDToken = verify
+	t.Cleanup(func() { verifyIDToken = orig })
+}
+
+func TestOptionalFirebaseJWT_Policy(t *testing.T) {
+	useTokenVerifier(t, func(ctx context.Context, token string) (*auth.Token, error) {
+		switch token {
+		case "valid":
+			return &auth.Token{UID: "user-1"}, nil
+		case "outage":
+			return nil, fmt.Errorf("%w: metadata server timeout", errAuthUnavailable)
+		}
+		return nil, errors.New("ID token has expired")
+	})
+
+	testCases := []struct {
+		name           string
+		policy         string
+		authorization  string
+		expectedCode   int
+		expectedUserID string
+	}{
+		{name: "No token, fail-open", policy: PolicyFailOpen, expectedCode: http.StatusOK},
+		{name: "No token, fail-closed", policy: PolicyFailClosedOnPresent, expectedCode: http.StatusOK},
+		{name: "Valid token, fail-closed", policy: PolicyFailClosedOnPresent, authorization: "Bearer valid", expectedCode: http.StatusOK, expectedUserID: "user-1"},
+		{name: "Invalid token, fail-open", policy: PolicyFailOpen, authorization: "Bearer forged", expectedCode: http.StatusOK},
+		{name: "Invalid token, default policy", policy: "", authorization: "Bearer forged", expectedCode: http.StatusOK},
+		{name: "Invalid token, fail-closed", policy: PolicyFailClosedOnPresent, authorization: "Bearer forged", expectedCode: http.StatusUnauthorized},
+		{name: "Malformed header, fail-open", policy: PolicyFailOpen, authorization: "Basic dXNlcjpwYXNz", expectedCode: http.StatusOK},
+		{name: "Malformed header, fail-closed", policy: PolicyFailClosedOnPresent, authorization: "Basic dXNlcjpwYXNz", expectedCode: http.StatusUnauthorized},
+		{name: "Empty bearer token, fail-closed", policy: PolicyFailClosedOnPresent, authorization: "Bearer ", expectedCode: http.StatusUnauthorized},
+		{name: "Auth unavailable, fail-open", policy: PolicyFailOpen, authorization: "Bearer outage", expectedCode: http.StatusOK},
+		{name: "Auth unavailable, fail-closed", policy: PolicyFailClosedOnPresent, authorization: "Bearer outage", expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("OPTIONAL_AUTH_POLICY", tc.policy)
+
+			var served bool
+			var userID string
+			handler := OptionalFirebaseJWT(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = true
+				userID, _ = GetUserID(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/optional", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+			if served != (tc.expectedCode == http.StatusOK) {
+				t.Errorf("Expected the handler to be called only for status %d, but served was %v", http.StatusOK, served)
+			}
+			if userID != tc.expectedUserID {
+				t.Errorf("Expected user %q, but got %q", tc.expectedUserID, userID)
+			}
+		})
+	}
+}
+
+func TestOptionalAuthPolicyFromEnv(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expected    string
+		expectError bool
+	}{
+		{value: "", expected: PolicyFailOpen},
+		{value: "fail-open", expected: PolicyFailOpen},
+		{value: " Fail-Closed-On-Present ", expected: PolicyFailClosedOnPresent},
+		{value: "fail-closed", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv("OPTIONAL_AUTH_POLICY", tc.value)
+			policy, err := OptionalAuthPolicyFromEnv()
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error for %q, but got policy %q", tc.value, policy)
+				}
+				return
+			}
+			if err != nil || policy != tc.expected {
+				t.Errorf("Expected policy %q, but got %q (err %v)", tc.expected, policy, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Package bodylimit holds the request body bound shared by the API and the worker:
+// the MAX_REQUEST_BODY_BYTES setting and the check for reads that ran over it.
+// Each binary wraps its handlers with its own middleware, which decides how an
+// oversized request is reported.
+package bodylimit
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// DefaultMaxBytes bounds request bodies when MAX_REQUEST_BODY_BYTES is unset
+const DefaultMaxBytes = 1 << 20
+
+// MaxBytesFromEnv returns MAX_REQUEST_BODY_BYTES, or DefaultMaxBytes when it is
+// unset or not a positive integer
+func MaxBytesFromEnv() int64 {
+	value := os.Getenv("MAX_REQUEST_BODY_BYTES")
+	if value == "" {
+		return DefaultMaxBytes
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed <= 0 {
+		log.Printf("Invalid MAX_REQUEST_BODY_BYTES %q, using default of %d", value, DefaultMaxBytes)
+		return DefaultMaxBytes
+	}
+	return parsed
+}
+
+// IsTooLarge reports whether reading a request body failed on the limit set with
+// http.MaxBytesReader
+func IsTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
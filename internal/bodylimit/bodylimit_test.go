@@ -0,0 +1,48 @@
+package bodylimit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBytesFromEnv(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected int64
+	}{
+		{name: "Unset", value: "", expected: DefaultMaxBytes},
+		{name: "Configured", value: "4096", expected: 4096},
+		{name: "Zero", value: "0", expected: DefaultMaxBytes},
+		{name: "Negative", value: "-1", expected: DefaultMaxBytes},
+		{name: "Not a number", value: "1MB", expected: DefaultMaxBytes},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MAX_REQUEST_BODY_BYTES", tc.value)
+			if got := MaxBytesFromEnv(); got != tc.expected {
+				t.Errorf("Expected %d, but got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsTooLarge(t *testing.T) {
+	rec := httptest.NewRecorder()
+	body := http.MaxBytesReader(rec, io.NopCloser(strings.NewReader(strings.Repeat("x", 100))), 64)
+	_, err := io.ReadAll(body)
+	if !IsTooLarge(err) {
+		t.Errorf("Expected a read past the limit to be too large, but got %v", err)
+	}
+	if !IsTooLarge(fmt.Errorf("failed to decode request: %w", err)) {
+		t.Errorf("Expected a wrapped limit error to be too large")
+	}
+	if IsTooLarge(io.ErrUnexpectedEOF) {
+		t.Errorf("Expected other read errors not to be too large")
+	}
+}
@@ -2,17 +2,205 @@ package certificate
 
 import (
 	"bytes"
+	_ "embed"
 	"fmt"
+	"image"
 	"image/color"
 	"image/png"
+	"log"
+	"os"
+	"strings"
 
+	"github.com/HugoSmits86/nativewebp"
 	"github.com/tdewolff/canvas"
 	"github.com/tdewolff/canvas/renderers/rasterizer"
 )
 
+// Badge output formats accepted by GenerateBadgeFormat
+const (
+	BadgeFormatPNG  = "png"
+	BadgeFormatWebP = "webp"
+)
+
+// BadgeContentType returns the MIME type for a badge format
+func BadgeContentType(format string) string {
+	if format == BadgeFormatWebP {
+		return "image/webp"
+	}
+	return "image/png"
+}
+
+// PNG compression levels accepted in BADGE_PNG_COMPRESSION
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"speed":   png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// pngCompressionLevel returns the level named in BADGE_PNG_COMPRESSION, or the
+// standard library's default level when it is unset or invalid
+func pngCompressionLevel() png.CompressionLevel {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("BADGE_PNG_COMPRESSION")))
+	if name == "" {
+		return png.DefaultCompression
+	}
+	level, ok := pngCompressionLevels[name]
+	if !ok {
+		log.Printf("Invalid BADGE_PNG_COMPRESSION %q, using default of default", name)
+		return png.DefaultCompression
+	}
+	return level
+}
+
+// pngOptimizeEnabled reports whether BADGE_PNG_OPTIMIZE asks for the lossless
+// palette pass before PNG badges are encoded
+func pngOptimizeEnabled() bool {
+	switch strings.ToLower(os.Getenv("BADGE_PNG_OPTIMIZE")) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// optimizePalette returns img as a paletted image when it uses at most 256
+// distinct colors, which PNG stores in a quarter of the space of RGBA pixels.
+// Every pixel keeps its exact color; images with more colors are returned as is.
+func optimizePalette(img image.Image) image.Image {
+	bounds := img.Bounds()
+	var palette color.Palette
+	indexes := make(map[color.NRGBA]uint8)
+	paletted := image.NewPaletted(bounds, nil)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			index, ok := indexes[c]
+			if !ok {
+				if len(palette) == 256 {
+					return img
+				}
+				index = uint8(len(palette))
+				indexes[c] = index
+				palette = append(palette, c)
+			}
+			paletted.SetColorIndex(x, y, index)
+		}
+	}
+	paletted.Palette = palette
+	return paletted
+}
+
+// encodeBadgePNG encodes a badge at the configured compression level. With
+// BADGE_PNG_OPTIMIZE set, a paletted encoding is also tried and the smaller kept.
+func encodeBadgePNG(buf *bytes.Buffer, img image.Image) error {
+	encoder := png.Encoder{CompressionLevel: pngCompressionLevel()}
+	if err := encoder.Encode(buf, img); err != nil {
+		return err
+	}
+	if !pngOptimizeEnabled() {
+		return nil
+	}
+
+	paletted := optimizePalette(img)
+	if paletted == img {
+		return nil
+	}
+	var optimized bytes.Buffer
+	if err := encoder.Encode(&optimized, paletted); err != nil {
+		return err
+	}
+	if optimized.Len() < buf.Len() {
+		buf.Reset()
+		buf.Write(optimized.Bytes())
+	}
+	return nil
+}
+
+// embeddedFont is the Go Regular font (BSD licensed, see fonts/LICENSE) bundled
+// into the binary so badges can always be rendered, even in slim containers
+//
+//go:embed fonts/Go-Regular.ttf
+var embeddedFont []byte
+
+var (
+	// FontPath is the external font tried first when rendering badges.
+	// It can be overridden with the BADGE_FONT_PATH environment variable.
+	// NOTE: This font path must be included in the final Docker image
+	FontPath = defaultFontPath()
+
+	// SystemFonts are the system font names tried when FontPath cannot be loaded
+	SystemFonts = []string{"Arial", "Times New Roman", "Helvetica", "sans-serif"}
+)
+
+// defaultFontPath returns BADGE_FONT_PATH or the DejaVu Sans path
+func defaultFontPath() string {
+	if path := os.Getenv("BADGE_FONT_PATH"); path != "" {
+		return path
+	}
+	return "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+}
+
+// loadFontFamily loads the badge font from FontPath, then SystemFonts,
+// and finally the embedded font so text always renders
+func loadFontFamily() *canvas.FontFamily {
+	if FontPath != "" {
+		fontFamily := canvas.NewFontFamily("external")
+		if err := fontFamily.LoadFontFile(FontPath, canvas.FontRegular); err == nil {
+			return fontFamily
+		}
+	}
+
+	for _, fontName := range SystemFonts {
+		fontFamily := canvas.NewFontFamily("system")
+		if err := fontFamily.LoadSystemFont(fontName, canvas.FontRegular); err == nil {
+			return fontFamily
+		}
+	}
+
+	log.Printf("No external or system font available for badges, using embedded font")
+	fontFamily := canvas.NewFontFamily("embedded")
+	fontFamily.MustLoadFont(embeddedFont, 0, canvas.FontRegular)
+	return fontFamily
+}
+
 // GenerateBadge creates a PNG badge with an authenticity score
-// The badge color changes based on the score: green (>=90), orange (>=70), red (<70)
 func GenerateBadge(score int) ([]byte, error) {
+	return GenerateBadgeFormat(score, BadgeFormatPNG)
+}
+
+// GenerateBadgeFormat creates a badge in the requested format: "png" (the default when
+// format is empty) or "webp". WebP badges are lossless and noticeably smaller than PNG.
+// AVIF is not offered because no pure-Go encoder exists and the service avoids cgo.
+func GenerateBadgeFormat(score int, format string) ([]byte, error) {
+	if format == "" {
+		format = BadgeFormatPNG
+	}
+	if format != BadgeFormatPNG && format != BadgeFormatWebP {
+		return nil, fmt.Errorf("unsupported badge format %q", format)
+	}
+
+	img := renderBadge(score)
+
+	var buf bytes.Buffer
+	switch format {
+	case BadgeFormatWebP:
+		if err := nativewebp.Encode(&buf, img, nil); err != nil {
+			return nil, fmt.Errorf("failed to encode WebP: %w", err)
+		}
+	default:
+		// Encode as PNG using standard library
+		if err := encodeBadgePNG(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode PNG: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderBadge rasterizes the badge for a score.
+// The badge color changes based on the score: green (>=90), orange (>=70), red (<70)
+func renderBadge(score int) image.Image {
 	// Define badge dimensions
 	const (
 		width  = 250.0
@@ -42,39 +230,8 @@ func GenerateBadge(score int) ([]byte, error) {
 	// Add background rectangle to canvas
 	c.RenderPath(rect, style, canvas.Identity)
 
-	// Load font face
-	// NOTE: This font path must be included in the final Docker image
-	// Consider copying DejaVuSans.ttf to /app/fonts/ in the Docker container
-	fontPath := "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
-	
-	// Try to load the font, fallback to built-in options if not available
-	fontFamily := canvas.NewFontFamily("dejavu")
-	err := fontFamily.LoadFontFile(fontPath, canvas.FontRegular)
-	if err != nil {
-		// If external font loading fails, create a minimal font family
-		// In production, ensure the font file is available in the Docker image
-		fontFamily = canvas.NewFontFamily("fallback")
-		
-		// Try to load a basic system font
-		systemFonts := []string{"Arial", "Times New Roman", "Helvetica", "sans-serif"}
-		fontLoaded := false
-		
-		for _, fontName := range systemFonts {
-			err = fontFamily.LoadSystemFont(fontName, canvas.FontRegular)
-			if err == nil {
-				fontLoaded = true
-				break
-			}
-		}
-		
-		// If no system fonts work, the canvas will have to work without custom fonts
-		// This is a graceful degradation approach
-		if !fontLoaded {
-			// Use a simple approach: render a badge without text if fonts fail completely
-			// In a real production environment, you'd want to bundle fonts with the application
-			return nil, fmt.Errorf("unable to load any font for badge generation - please ensure fonts are available in the system or Docker image")
-		}
-	}
+	// Load font family, falling back to the embedded font when none are available
+	fontFamily := loadFontFamily()
 
 	white := color.RGBA{255, 255, 255, 255}
 	face := fontFamily.Face(12.0, white) // White text
@@ -96,19 +253,8 @@ func GenerateBadge(score int) ([]byte, error) {
 	scoreMatrix := canvas.Identity.Translate(scoreX, scoreY)
 	c.RenderText(scoreText, scoreMatrix)
 
-	// Render canvas to PNG in memory using the rasterizer
-	var buf bytes.Buffer
-	ras := rasterizer.New(width, height, canvas.DPMM(3.0), canvas.DefaultColorSpace)
-	
 	// Render the canvas to the rasterizer, then get the image
+	ras := rasterizer.New(width, height, canvas.DPMM(3.0), canvas.DefaultColorSpace)
 	c.RenderTo(ras)
-	img := ras.Image
-	
-	// Encode as PNG using standard library
-	err = png.Encode(&buf, img)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode PNG: %w", err)
-	}
-
-	return buf.Bytes(), nil
+	return ras.Image
 }
\ No newline at end of file
@@ -0,0 +1,168 @@
+package certificate
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestGenerateBadge_EmbeddedFontOnly(t *testing.T) {
+	// Make the external and system fonts unavailable
+	origPath, origSystem := FontPath, SystemFonts
+	FontPath = "/nonexistent/font.ttf"
+	SystemFonts = nil
+	defer func() { FontPath, SystemFonts = origPath, origSystem }()
+
+	if name := loadFontFamily().Name(); name != "embedded" {
+		t.Fatalf("Expected the embedded font to be used, but got %q", name)
+	}
+
+	badge, err := GenerateBadge(95)
+	if err != nil {
+		t.Fatalf("GenerateBadge() failed with only the embedded font: %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(badge))
+	if err != nil {
+		t.Fatalf("GenerateBadge() did not return a valid PNG: %v", err)
+	}
+	if img.Bounds().Dx() == 0 || img.Bounds().Dy() == 0 {
+		t.Errorf("Expected a non-empty badge image, but got bounds %v", img.Bounds())
+	}
+}
+
+func TestGenerateBadgeFormat(t *testing.T) {
+	pngBadge, err := GenerateBadgeFormat(95, BadgeFormatPNG)
+	if err != nil {
+		t.Fatalf("Failed to generate PNG badge: %v", err)
+	}
+	pngConfig, err := png.DecodeConfig(bytes.NewReader(pngBadge))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG badge: %v", err)
+	}
+
+	webpBadge, err := GenerateBadgeFormat(95, BadgeFormatWebP)
+	if err != nil {
+		t.Fatalf("Failed to generate WebP badge: %v", err)
+	}
+	webpConfig, err := webp.DecodeConfig(bytes.NewReader(webpBadge))
+	if err != nil {
+		t.Fatalf("Failed to decode WebP badge: %v", err)
+	}
+
+	if webpConfig.Width != pngConfig.Width || webpConfig.Height != pngConfig.Height {
+		t.Errorf("Expected WebP badge to be %dx%d, but got %dx%d", pngConfig.Width, pngConfig.Height, webpConfig.Width, webpConfig.Height)
+	}
+	if _, err := webp.Decode(bytes.NewReader(webpBadge)); err != nil {
+		t.Errorf("Expected WebP badge to decode fully, but got %v", err)
+	}
+}
+
+func TestGenerateBadgeFormat_DefaultAndUnknown(t *testing.T) {
+	badge, err := GenerateBadgeFormat(80, "")
+	if err != nil {
+		t.Fatalf("Expected empty format to default to PNG, but got %v", err)
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(badge)); err != nil {
+		t.Errorf("Expected default badge to be a PNG, but got %v", err)
+	}
+
+	for _, format := range []string{"avif", "gif", "PNG"} {
+		if _, err := GenerateBadgeFormat(80, format); err == nil {
+			t.Errorf("Expected an error for format %q, but got nil", format)
+		}
+	}
+}
+
+func TestGenerateBadge_PNGCompression(t *testing.T) {
+	testCases := []struct {
+		name        string
+		compression string
+		optimize    string
+	}{
+		{name: "No compression", compression: "none"},
+		{name: "Default", compression: ""},
+		{name: "Invalid falls back to default", compression: "maximum"},
+		{name: "Best compression", compression: "best"},
+		{name: "Best compression with optimization", compression: "best", optimize: "true"},
+	}
+
+	reference := renderBadge(95)
+	sizes := make(map[string]int)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("BADGE_PNG_COMPRESSION", tc.compression)
+			t.Setenv("BADGE_PNG_OPTIMIZE", tc.optimize)
+
+			badge, err := GenerateBadge(95)
+			if err != nil {
+				t.Fatalf("GenerateBadge() failed: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(badge))
+			if err != nil {
+				t.Fatalf("GenerateBadge() did not return a valid PNG: %v", err)
+			}
+
+			// Compression and the palette pass are lossless
+			bounds := reference.Bounds()
+			if img.Bounds() != bounds {
+				t.Fatalf("Expected bounds %v, but got %v", bounds, img.Bounds())
+			}
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					if color.NRGBAModel.Convert(img.At(x, y)) != color.NRGBAModel.Convert(reference.At(x, y)) {
+						t.Fatalf("Expected pixel (%d, %d) to be unchanged, but got %v", x, y, img.At(x, y))
+					}
+				}
+			}
+			sizes[tc.name] = len(badge)
+		})
+	}
+
+	if sizes["Best compression"] >= sizes["No compression"] {
+		t.Errorf("Expected best compression to be smaller than none, but got %d and %d bytes", sizes["Best compression"], sizes["No compression"])
+	}
+	if sizes["Best compression"] > sizes["Default"] {
+		t.Errorf("Expected best compression to be no larger than default, but got %d and %d bytes", sizes["Best compression"], sizes["Default"])
+	}
+	if sizes["Best compression with optimization"] > sizes["Best compression"] {
+		t.Errorf("Expected the optimization pass to never grow the badge, but got %d and %d bytes", sizes["Best compression with optimization"], sizes["Best compression"])
+	}
+}
+
+func TestOptimizePalette(t *testing.T) {
+	// A two-color image is stored losslessly as a palette
+	few := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			few.Set(x, y, color.RGBA{uint8(76 * ((x / 8) % 2)), 175, 80, 255})
+		}
+	}
+	paletted, ok := optimizePalette(few).(*image.Paletted)
+	if !ok {
+		t.Fatalf("Expected a paletted image for two colors")
+	}
+	if len(paletted.Palette) != 2 {
+		t.Errorf("Expected a palette of 2 colors, but got %d", len(paletted.Palette))
+	}
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if color.NRGBAModel.Convert(paletted.At(x, y)) != color.NRGBAModel.Convert(few.At(x, y)) {
+				t.Fatalf("Expected pixel (%d, %d) to be unchanged, but got %v", x, y, paletted.At(x, y))
+			}
+		}
+	}
+
+	// More than 256 colors cannot be paletted without loss
+	many := image.NewRGBA(image.Rect(0, 0, 300, 1))
+	for x := 0; x < 300; x++ {
+		many.Set(x, 0, color.RGBA{uint8(x), uint8(x >> 8), 0, 255})
+	}
+	if optimizePalette(many) != image.Image(many) {
+		t.Errorf("Expected an image with 300 colors to be returned unchanged")
+	}
+}
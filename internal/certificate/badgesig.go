@@ -0,0 +1,199 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BadgeMetadataKeyword is the keyword of the PNG tEXt chunk holding a badge's signed metadata
+const BadgeMetadataKeyword = "ProofPix-Badge"
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+var (
+	// ErrBadgeNotSigned is returned when a badge carries no signed metadata
+	ErrBadgeNotSigned = errors.New("badge is not signed")
+	// ErrInvalidBadgeSignature is returned when a badge's metadata or image does not match its signature
+	ErrInvalidBadgeSignature = errors.New("badge signature is invalid")
+)
+
+// BadgeMetadata is the signed metadata embedded in a PNG badge. The signature covers
+// every other field, including the SHA-256 of the badge without its metadata chunk,
+// so neither the asset ID and score nor the rendered image can be altered.
+type BadgeMetadata struct {
+	AssetID            string `json:"assetId"`
+	Score              int    `json:"score"`
+	Algorithm          string `json:"algorithm"`
+	VerificationMethod string `json:"verificationMethod,omitempty"`
+	ImageDigest        string `json:"imageDigest"`
+	Signature          string `json:"signature,omitempty"`
+}
+
+// BadgeSigningFromEnv reports whether BADGE_SIGNING is set, embedding signed
+// metadata in PNG badges when credentials are signed
+func BadgeSigningFromEnv() bool {
+	value := os.Getenv("BADGE_SIGNING")
+	if value == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid BADGE_SIGNING %q, using default of false", value)
+		return false
+	}
+	return enabled
+}
+
+// SignBadge embeds metadata naming the asset and its score, signed with the
+// configured key, in a PNG badge. Metadata from an earlier signing is replaced.
+func (c *SigningConfig) SignBadge(badge []byte, assetID string, score int) ([]byte, error) {
+	image, _, err := splitBadgeMetadata(badge)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(image)
+	metadata := BadgeMetadata{
+		AssetID:            assetID,
+		Score:              score,
+		Algorithm:          c.Algorithm,
+		VerificationMethod: c.VerificationMethod,
+		ImageDigest:        hex.EncodeToString(digest[:]),
+	}
+	payload, err := badgeSigningPayload(metadata)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := c.signBytes(payload)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Signature = multibaseBase64URL + base64.RawURLEncoding.EncodeToString(signature)
+
+	text, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode badge metadata: %v", err)
+	}
+	chunk := pngChunk("tEXt", append([]byte(BadgeMetadataKeyword+"\x00"), text...))
+
+	// splitBadgeMetadata checked that the file ends with the 12-byte IEND chunk
+	iend := len(image) - 12
+	signed := make([]byte, 0, len(image)+len(chunk))
+	signed = append(signed, image[:iend]...)
+	signed = append(signed, chunk...)
+	return append(signed, image[iend:]...), nil
+}
+
+// VerifyBadge checks the signed metadata embedded in a PNG badge against the
+// issuer's public key and returns it. A badge whose metadata or pixels were changed
+// after signing fails with ErrInvalidBadgeSignature.
+func VerifyBadge(badge []byte, publicKey crypto.PublicKey) (*BadgeMetadata, error) {
+	image, chunks, err := splitBadgeMetadata(badge)
+	if err != nil {
+		return nil, err
+	}
+	switch len(chunks) {
+	case 0:
+		return nil, ErrBadgeNotSigned
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w: %d metadata chunks", ErrInvalidBadgeSignature, len(chunks))
+	}
+
+	var metadata BadgeMetadata
+	if err := json.Unmarshal(chunks[0], &metadata); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse metadata: %v", ErrInvalidBadgeSignature, err)
+	}
+	if err := checkKeyAlgorithm(metadata.Algorithm, publicKey); err != nil {
+		return nil, err
+	}
+	if digest := sha256.Sum256(image); metadata.ImageDigest != hex.EncodeToString(digest[:]) {
+		return nil, fmt.Errorf("%w: image does not match its digest", ErrInvalidBadgeSignature)
+	}
+
+	if !strings.HasPrefix(metadata.Signature, multibaseBase64URL) {
+		return nil, fmt.Errorf("%w: unexpected signature encoding", ErrInvalidBadgeSignature)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(metadata.Signature[len(multibaseBase64URL):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBadgeSignature, err)
+	}
+	payload, err := badgeSigningPayload(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyBytes(publicKey, payload, signature) {
+		return nil, ErrInvalidBadgeSignature
+	}
+	return &metadata, nil
+}
+
+// badgeSigningPayload is the JCS serialization of the metadata without its signature
+func badgeSigningPayload(metadata BadgeMetadata) ([]byte, error) {
+	metadata.Signature = ""
+	value, err := genericJSON(&metadata)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalJSON(value)
+}
+
+// splitBadgeMetadata walks the chunks of a PNG file and returns it without its
+// badge metadata chunks, along with the text of each one removed
+func splitBadgeMetadata(data []byte) ([]byte, [][]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, nil, fmt.Errorf("badge is not a PNG file")
+	}
+	keyword := []byte(BadgeMetadataKeyword + "\x00")
+
+	image := append([]byte(nil), pngSignature...)
+	var texts [][]byte
+	for offset := len(pngSignature); ; {
+		if len(data)-offset < 12 {
+			return nil, nil, fmt.Errorf("badge PNG is truncated")
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if length > len(data)-offset-12 {
+			return nil, nil, fmt.Errorf("badge PNG chunk at offset %d is truncated", offset)
+		}
+		end := offset + 12 + length
+		chunkType := string(data[offset+4 : offset+8])
+		chunkData := data[offset+8 : offset+8+length]
+
+		if chunkType == "tEXt" && bytes.HasPrefix(chunkData, keyword) {
+			texts = append(texts, chunkData[len(keyword):])
+		} else {
+			image = append(image, data[offset:end]...)
+		}
+
+		if chunkType == "IEND" {
+			if end != len(data) {
+				return nil, nil, fmt.Errorf("badge PNG has data after IEND")
+			}
+			return image, texts, nil
+		}
+		offset = end
+	}
+}
+
+// pngChunk encodes a PNG chunk with its length and CRC
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
@@ -0,0 +1,143 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"testing"
+)
+
+func TestSignBadge_RoundTrip(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate P-256 key: %v", err)
+	}
+	badge, err := GenerateBadge(87)
+	if err != nil {
+		t.Fatalf("GenerateBadge() failed: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		algorithm string
+		key       crypto.PrivateKey
+	}{
+		{name: "Ed25519", algorithm: AlgorithmEd25519, key: edKey},
+		{name: "ECDSA P-256", algorithm: AlgorithmECDSAP256, key: ecKey},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := NewSigningConfig(tc.algorithm, pkcs8PEM(t, tc.key), "did:web:proofpix.com#key-1")
+			if err != nil {
+				t.Fatalf("NewSigningConfig() failed: %v", err)
+			}
+
+			signed, err := config.SignBadge(badge, "asset-1", 87)
+			if err != nil {
+				t.Fatalf("SignBadge() failed: %v", err)
+			}
+			if _, err := png.Decode(bytes.NewReader(signed)); err != nil {
+				t.Fatalf("Expected the signed badge to remain a valid PNG, but got %v", err)
+			}
+
+			metadata, err := VerifyBadge(signed, config.PublicKey())
+			if err != nil {
+				t.Fatalf("VerifyBadge() failed: %v", err)
+			}
+			if metadata.AssetID != "asset-1" || metadata.Score != 87 || metadata.Algorithm != tc.algorithm || metadata.VerificationMethod != "did:web:proofpix.com#key-1" {
+				t.Errorf("Expected the signed asset ID, score and key, but got %+v", metadata)
+			}
+
+			// Signing again replaces the metadata rather than adding to it
+			resigned, err := config.SignBadge(signed, "asset-1", 87)
+			if err != nil {
+				t.Fatalf("SignBadge() failed on a signed badge: %v", err)
+			}
+			if _, err := VerifyBadge(resigned, config.PublicKey()); err != nil {
+				t.Errorf("Expected a re-signed badge to verify, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyBadge_Rejects(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	config, err := NewSigningConfig(AlgorithmEd25519, pkcs8PEM(t, key), "")
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	badge, err := GenerateBadge(40)
+	if err != nil {
+		t.Fatalf("GenerateBadge() failed: %v", err)
+	}
+	signed, err := config.SignBadge(badge, "asset-1", 40)
+	if err != nil {
+		t.Fatalf("SignBadge() failed: %v", err)
+	}
+
+	// withMetadata re-embeds the signed metadata after changing it
+	withMetadata := func(change func(*BadgeMetadata)) []byte {
+		image, texts, err := splitBadgeMetadata(signed)
+		if err != nil || len(texts) != 1 {
+			t.Fatalf("Expected one metadata chunk, but got %d (%v)", len(texts), err)
+		}
+		var metadata BadgeMetadata
+		if err := json.Unmarshal(texts[0], &metadata); err != nil {
+			t.Fatalf("Failed to decode metadata: %v", err)
+		}
+		change(&metadata)
+		text, _ := json.Marshal(metadata)
+		chunk := pngChunk("tEXt", append([]byte(BadgeMetadataKeyword+"\x00"), text...))
+		iend := len(image) - 12
+		return append(append(append([]byte(nil), image[:iend]...), chunk...), image[iend:]...)
+	}
+
+	// A flipped byte at the start of the compressed pixels
+	tamperedPixels := append([]byte(nil), signed...)
+	idat := bytes.LastIndex(tamperedPixels, []byte("IDAT"))
+	tamperedPixels[idat+8] ^= 0xff
+
+	testCases := []struct {
+		name        string
+		badge       []byte
+		publicKey   crypto.PublicKey
+		expectedErr error
+	}{
+		{name: "Raised score", badge: withMetadata(func(m *BadgeMetadata) { m.Score = 99 }), publicKey: config.PublicKey(), expectedErr: ErrInvalidBadgeSignature},
+		{name: "Other asset", badge: withMetadata(func(m *BadgeMetadata) { m.AssetID = "asset-2" }), publicKey: config.PublicKey(), expectedErr: ErrInvalidBadgeSignature},
+		{name: "Altered image", badge: tamperedPixels, publicKey: config.PublicKey(), expectedErr: ErrInvalidBadgeSignature},
+		{name: "Other issuer's key", badge: signed, publicKey: otherPublic, expectedErr: ErrInvalidBadgeSignature},
+		{name: "Unsigned badge", badge: badge, publicKey: config.PublicKey(), expectedErr: ErrBadgeNotSigned},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := VerifyBadge(tc.badge, tc.publicKey); !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected %v, but got %v", tc.expectedErr, err)
+			}
+		})
+	}
+
+	if _, err := VerifyBadge([]byte("not a png"), config.PublicKey()); err == nil {
+		t.Error("Expected an error for a file that is not a PNG")
+	}
+}
@@ -0,0 +1,184 @@
+package certificate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Canonicalize returns the canonical serialization of a credential without its
+// proof: the JSON Canonicalization Scheme (JCS, RFC 8785) the declared
+// cryptosuites name. Object members are sorted by their UTF-16 code units, no
+// insignificant whitespace is written, strings are escaped minimally and numbers
+// use the ECMAScript form, so the output depends neither on struct field order,
+// map iteration order nor the Go version. Signing and verification both use it.
+func Canonicalize(credential *VerifiableCredential) ([]byte, error) {
+	if credential == nil {
+		return nil, fmt.Errorf("credential is required")
+	}
+	document := *credential
+	document.Proof = Proof{}
+
+	value, err := genericJSON(&document)
+	if err != nil {
+		return nil, err
+	}
+	delete(value.(map[string]interface{}), "proof")
+	return canonicalJSON(value)
+}
+
+// canonicalProofOptions returns the JCS serialization of a credential's proof
+// without its proof value, so the proof's type, cryptosuite, creation time and
+// verification method are covered by the signature too
+func canonicalProofOptions(proof Proof) ([]byte, error) {
+	proof.ProofValue = ""
+	value, err := genericJSON(&proof)
+	if err != nil {
+		return nil, err
+	}
+	delete(value.(map[string]interface{}), "proofValue")
+	return canonicalJSON(value)
+}
+
+// genericJSON round-trips a value through encoding/json into maps, slices and
+// json.Numbers, applying the struct tags and omitempty rules of its type
+func genericJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode credential: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode credential: %v", err)
+	}
+	return value, nil
+}
+
+// canonicalJSON serializes a value decoded by genericJSON according to RFC 8785
+func canonicalJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		writeCanonicalString(buf, v)
+	case json.Number:
+		number, err := canonicalNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(number)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, element); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot canonicalize %T", value)
+	}
+	return nil
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, as RFC 8785 requires; this
+// differs from Go's byte order for characters outside the Basic Multilingual Plane
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeCanonicalString writes a JSON string escaping only the quote, the backslash
+// and control characters, which use their short forms where JSON has one
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// canonicalNumber formats a number as ECMAScript's Number.prototype.toString does:
+// the shortest representation that round-trips, in plain notation from 1e-6 up to
+// 1e21 and in exponent notation without a padded exponent outside that range
+func canonicalNumber(n json.Number) (string, error) {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("cannot canonicalize number %s", n)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	formatted := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(formatted, "e")
+	sign := exponent[0]
+	exponent = strings.TrimLeft(exponent[1:], "0")
+	return mantissa + "e" + string(sign) + exponent, nil
+}
@@ -0,0 +1,191 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// canonicalFixture is a credential touching every field type of the schema
+func canonicalFixture() *VerifiableCredential {
+	return &VerifiableCredential{
+		Context:      []string{"https://www.w3.org/2018/credentials/v1", "https://schema.org"},
+		Type:         []string{"VerifiableCredential", "ProofPixAuthenticityCredential"},
+		Issuer:       "did:web:proofpix.com",
+		IssuanceDate: "2024-01-15T10:30:00Z",
+		CredentialSubject: CredentialSubject{
+			ID:                    "urn:proofpix:asset:asset-1",
+			Type:                  "ImageAuthenticityAssertion",
+			Creator:               "user-1",
+			AuthenticityRating:    AuthenticityRating{Type: "Rating", RatingValue: 9, BestRating: 10, WorstRating: 1},
+			AuthenticityNarrative: "Natural <light> & \"shadows\"\n",
+			RelatedAsset:          &RelatedAsset{ID: "urn:proofpix:asset:asset-0", Distance: 0.02},
+		},
+		Proof: Proof{Type: "DataIntegrityProof", Created: "2024-01-15T10:30:00Z", ProofPurpose: "assertionMethod", ProofValue: "abc"},
+	}
+}
+
+func TestCanonicalize_Golden(t *testing.T) {
+	expected := `{"@context":["https://www.w3.org/2018/credentials/v1","https://schema.org"],` +
+		`"@type":["VerifiableCredential","ProofPixAuthenticityCredential"],` +
+		`"credentialSubject":{"authenticityNarrative":"Natural <light> & \"shadows\"\n",` +
+		`"authenticityRating":{"@type":"Rating","bestRating":10,"ratingValue":9,"worstRating":1},` +
+		`"creator":"user-1","id":"urn:proofpix:asset:asset-1","relatedAsset":{"distance":0.02,"id":"urn:proofpix:asset:asset-0"},` +
+		`"type":"ImageAuthenticityAssertion"},` +
+		`"issuanceDate":"2024-01-15T10:30:00Z","issuer":"did:web:proofpix.com"}`
+
+	canonical, err := Canonicalize(canonicalFixture())
+	if err != nil {
+		t.Fatalf("Canonicalize() failed: %v", err)
+	}
+	if string(canonical) != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, canonical)
+	}
+}
+
+func TestCanonicalize_Stable(t *testing.T) {
+	credential := canonicalFixture()
+	first, err := Canonicalize(credential)
+	if err != nil {
+		t.Fatalf("Canonicalize() failed: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		again, err := Canonicalize(credential)
+		if err != nil || !bytes.Equal(first, again) {
+			t.Fatalf("Expected identical output on call %d, but got %s (%v)", i, again, err)
+		}
+	}
+
+	// The proof is excluded, so signing it does not change the canonical form
+	credential.Proof = Proof{Type: "DataIntegrityProof", Cryptosuite: CryptosuiteEdDSA, ProofValue: "u123"}
+	if excluded, _ := Canonicalize(credential); !bytes.Equal(first, excluded) {
+		t.Errorf("Expected the proof to be excluded, but got %s", excluded)
+	}
+	if credential.Proof.ProofValue != "u123" {
+		t.Errorf("Expected the credential to be left unchanged, but got proof %+v", credential.Proof)
+	}
+
+	if _, err := Canonicalize(nil); err == nil {
+		t.Errorf("Expected an error for a nil credential")
+	}
+}
+
+func TestCanonicalize_FieldOrder(t *testing.T) {
+	ordered := `{"@context":["https://www.w3.org/2018/credentials/v1"],"@type":["VerifiableCredential"],"issuer":"did:web:proofpix.com","issuanceDate":"2024-01-15T10:30:00Z",
+		"credentialSubject":{"id":"urn:proofpix:asset:asset-1","type":"ImageAuthenticityAssertion","creator":"user-1","authenticityRating":{"@type":"Rating","ratingValue":9,"bestRating":10,"worstRating":1},"authenticityNarrative":"ok"}}`
+	reordered := `{"credentialSubject":{"authenticityNarrative":"ok","authenticityRating":{"worstRating":1,"bestRating":10,"ratingValue":9,"@type":"Rating"},"creator":"user-1","type":"ImageAuthenticityAssertion","id":"urn:proofpix:asset:asset-1"},
+		"issuanceDate":"2024-01-15T10:30:00Z","issuer":"did:web:proofpix.com","@type":["VerifiableCredential"],"@context":["https://www.w3.org/2018/credentials/v1"]}`
+
+	var a, b VerifiableCredential
+	if err := json.Unmarshal([]byte(ordered), &a); err != nil {
+		t.Fatalf("Failed to parse credential: %v", err)
+	}
+	if err := json.Unmarshal([]byte(reordered), &b); err != nil {
+		t.Fatalf("Failed to parse credential: %v", err)
+	}
+	canonicalA, errA := Canonicalize(&a)
+	canonicalB, errB := Canonicalize(&b)
+	if errA != nil || errB != nil || !bytes.Equal(canonicalA, canonicalB) {
+		t.Errorf("Expected identical output for reordered fields, but got\n%s\n%s", canonicalA, canonicalB)
+	}
+
+	// Generic objects are sorted the same way whatever order their keys arrive in
+	for i := 0; i < 20; i++ {
+		first, _ := genericFromJSON(t, `{"b":1,"a":{"d":[true,null],"c":"x"}}`)
+		second, _ := genericFromJSON(t, `{"a":{"c":"x","d":[true,null]},"b":1}`)
+		if first != `{"a":{"c":"x","d":[true,null]},"b":1}` || first != second {
+			t.Fatalf("Expected sorted members, but got %s and %s", first, second)
+		}
+	}
+}
+
+// genericFromJSON canonicalizes arbitrary JSON text
+func genericFromJSON(t *testing.T, text string) (string, error) {
+	t.Helper()
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("Failed to parse %s: %v", text, err)
+	}
+	canonical, err := canonicalJSON(value)
+	return string(canonical), err
+}
+
+func TestCanonicalJSON_RFC8785(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Integers", input: `[0,-0,1,-1,10,1e2,9007199254740992]`, expected: `[0,0,1,-1,10,100,9007199254740992]`},
+		{name: "Fractions", input: `[0.5,4.50,0.000001,1e-7,333333333.33333329]`, expected: `[0.5,4.5,0.000001,1e-7,333333333.3333333]`},
+		{name: "Large numbers", input: `[1e20,1e21,1.5e300]`, expected: `[100000000000000000000,1e+21,1.5e+300]`},
+		{name: "String escapes", input: `["\u000f\u001f\b\t\n\f\r\"\\","<>&\u2028","\u00e9\u20ac"]`, expected: "[\"\\u000f\\u001f\\b\\t\\n\\f\\r\\\"\\\\\",\"<>&\u2028\",\"\u00e9\u20ac\"]"},
+		{name: "UTF-16 key order", input: `{"\u20ac":1,"\r":2,"\ufb33":3,"1":4,"\ud83d\ude00":5,"\u0080":6,"\u00f6":7}`, expected: "{\"\\r\":2,\"1\":4,\"\u0080\":6,\"\u00f6\":7,\"\u20ac\":1,\"\U0001F600\":5,\"\ufb33\":3}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			canonical, err := genericFromJSON(t, tc.input)
+			if err != nil {
+				t.Fatalf("canonicalJSON() failed: %v", err)
+			}
+			if canonical != tc.expected {
+				t.Errorf("Expected %s, but got %s", tc.expected, canonical)
+			}
+		})
+	}
+}
+
+func TestVerifySignature_Canonical(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	config, err := NewSigningConfig(AlgorithmEd25519, pkcs8PEM(t, key), "did:web:proofpix.com#key-1")
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+	publicKey := key.Public()
+
+	// A credential signed over the canonical form
+	signed := canonicalFixture()
+	if err := config.Sign(signed); err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if err := VerifySignature(signed, publicKey); err != nil {
+		t.Errorf("Expected the signature to verify, but got %v", err)
+	}
+
+	// Re-encoding with other field order or whitespace keeps it valid
+	data, _ := json.MarshalIndent(signed, "", "    ")
+	var reparsed VerifiableCredential
+	if err := json.Unmarshal(data, &reparsed); err != nil {
+		t.Fatalf("Failed to parse credential: %v", err)
+	}
+	if err := VerifySignature(&reparsed, publicKey); err != nil {
+		t.Errorf("Expected the re-encoded credential to verify, but got %v", err)
+	}
+
+	// The proof options are covered by the signature
+	tampered := *signed
+	tampered.Proof.Created = "2030-01-01T00:00:00Z"
+	if err := VerifySignature(&tampered, publicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a changed proof creation time to be rejected, but got %v", err)
+	}
+
+	// A signature over the plain JSON encoding rather than the canonical form is rejected
+	plain := canonicalFixture()
+	plain.Proof.Type, plain.Proof.Cryptosuite, plain.Proof.VerificationMethod = signedProofType, CryptosuiteEdDSA, config.VerificationMethod
+	payload, _ := json.Marshal(plain)
+	plain.Proof.ProofValue = multibaseBase64URL + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, payload))
+	if err := VerifySignature(plain, publicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a signature over the plain JSON encoding to be rejected, but got %v", err)
+	}
+}
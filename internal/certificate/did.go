@@ -0,0 +1,361 @@
+package certificate
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultIssuer is the credential issuer when no issuer DID is configured
+const DefaultIssuer = "https://proofpix.com"
+
+// didWebPrefix starts every did:web identifier
+const didWebPrefix = "did:web:"
+
+// didDocumentContexts are the JSON-LD contexts of published DID documents
+var didDocumentContexts = []string{
+	"https://www.w3.org/ns/did/v1",
+	"https://w3id.org/security/suites/jws-2020/v1",
+}
+
+// jsonWebKeyType is the verification method type of keys published as JWKs
+const jsonWebKeyType = "JsonWebKey2020"
+
+// maxDIDDocumentSize caps the DID documents read by WebDIDResolver
+const maxDIDDocumentSize = 1 << 20
+
+var (
+	// ErrInvalidDID is returned for identifiers that are not usable did:web DIDs
+	ErrInvalidDID = errors.New("invalid did:web identifier")
+	// ErrDIDResolution is returned when a DID document cannot be fetched or does
+	// not contain the requested verification method
+	ErrDIDResolution = errors.New("failed to resolve DID")
+)
+
+// IssuerFromEnv returns the did:web identifier in CERTIFICATE_ISSUER_DID, or
+// DefaultIssuer when DIDs are disabled
+func IssuerFromEnv() string {
+	did := os.Getenv("CERTIFICATE_ISSUER_DID")
+	if did == "" {
+		return DefaultIssuer
+	}
+	if _, err := DIDWebURL(did); err != nil {
+		log.Printf("Invalid CERTIFICATE_ISSUER_DID %q, using default of %s", did, DefaultIssuer)
+		return DefaultIssuer
+	}
+	return did
+}
+
+// IsDID reports whether an issuer or verification method is a DID or DID URL
+func IsDID(id string) bool {
+	return strings.HasPrefix(id, "did:")
+}
+
+// splitDIDURL separates a DID URL such as did:web:proofpix.com#key-1 into the DID
+// and its fragment
+func splitDIDURL(didURL string) (did, fragment string) {
+	did, fragment, _ = strings.Cut(didURL, "#")
+	return did, fragment
+}
+
+// DIDWebURL returns the HTTPS location of a did:web DID document: the host's
+// /.well-known/did.json, or did.json under the path for DIDs with path segments
+func DIDWebURL(did string) (string, error) {
+	if !strings.HasPrefix(did, didWebPrefix) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDID, did)
+	}
+	segments := strings.Split(strings.TrimPrefix(did, didWebPrefix), ":")
+	for i, segment := range segments {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil || decoded == "" || strings.ContainsAny(decoded, "/?#") {
+			return "", fmt.Errorf("%w: %q", ErrInvalidDID, did)
+		}
+		segments[i] = decoded
+	}
+
+	location := url.URL{Scheme: "https", Host: segments[0]}
+	if len(segments) == 1 {
+		location.Path = "/.well-known/did.json"
+	} else {
+		location.Path = "/" + strings.Join(segments[1:], "/") + "/did.json"
+	}
+	return location.String(), nil
+}
+
+// JWK is a public key in JSON Web Key form: OKP/Ed25519 or EC/P-256
+type JWK struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y,omitempty"`
+}
+
+// publicKeyJWK encodes an Ed25519 or P-256 public key as a JWK
+func publicKeyJWK(publicKey crypto.PublicKey) (*JWK, error) {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return &JWK{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(key)}, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			break
+		}
+		x, y := make([]byte, 32), make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		return &JWK{KeyType: "EC", Curve: "P-256", X: base64.RawURLEncoding.EncodeToString(x), Y: base64.RawURLEncoding.EncodeToString(y)}, nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, publicKey)
+}
+
+// PublicKey decodes the JWK into an Ed25519 or P-256 public key
+func (j *JWK) PublicKey() (crypto.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(j.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWK x coordinate: %v", err)
+	}
+
+	switch {
+	case j.KeyType == "OKP" && j.Curve == "Ed25519":
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 JWK length %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	case j.KeyType == "EC" && j.Curve == "P-256":
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK y coordinate: %v", err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("P-256 JWK point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: JWK %s/%s", ErrUnsupportedAlgorithm, j.KeyType, j.Curve)
+}
+
+// DIDDocument is the subset of a DID document needed to verify credentials
+type DIDDocument struct {
+	Context            DIDContext              `json:"@context,omitempty"`
+	ID                 string                  `json:"id"`
+	VerificationMethod []DIDVerificationMethod `json:"verificationMethod"`
+	AssertionMethod    []string                `json:"assertionMethod,omitempty"`
+}
+
+// DIDContext is the @context of a DID document. It is published as an array, but
+// documents with a single context may give it as a string.
+type DIDContext []string
+
+// UnmarshalJSON accepts a single context string or an array of them
+func (c *DIDContext) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*c = DIDContext{single}
+		return nil
+	}
+	var contexts []string
+	if err := json.Unmarshal(data, &contexts); err != nil {
+		return fmt.Errorf("@context must be a string or an array of strings: %v", err)
+	}
+	*c = contexts
+	return nil
+}
+
+// DIDVerificationMethod is one public key listed in a DID document
+type DIDVerificationMethod struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	Controller   string `json:"controller"`
+	PublicKeyJWK *JWK   `json:"publicKeyJwk"`
+}
+
+// DIDKey is a public key to publish under a fragment of the issuer DID
+type DIDKey struct {
+	Fragment  string
+	PublicKey crypto.PublicKey
+}
+
+// RetiredKeysFromEnv returns the public keys in CERTIFICATE_RETIRED_KEYS, a comma
+// separated list of fragment=file entries such as "key-1=/secrets/key-1.pub.pem",
+// each file holding a PEM (PKIX) Ed25519 or P-256 public key. Keys retired by a
+// rotation stay published so credentials signed with them still verify.
+func RetiredKeysFromEnv() ([]DIDKey, error) {
+	value := os.Getenv("CERTIFICATE_RETIRED_KEYS")
+	if value == "" {
+		return nil, nil
+	}
+
+	var keys []DIDKey
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		fragment, file, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		fragment = strings.TrimPrefix(strings.TrimSpace(fragment), "#")
+		if !ok || fragment == "" || strings.TrimSpace(file) == "" {
+			return nil, fmt.Errorf("invalid CERTIFICATE_RETIRED_KEYS entry %q: expected fragment=file", entry)
+		}
+		if seen[fragment] {
+			return nil, fmt.Errorf("duplicate CERTIFICATE_RETIRED_KEYS fragment %q", fragment)
+		}
+		seen[fragment] = true
+
+		keyPEM, err := os.ReadFile(strings.TrimSpace(file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read retired key %s: %v", fragment, err)
+		}
+		publicKey, err := parsePublicKeyPEM(keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retired key %s: %v", fragment, err)
+		}
+		keys = append(keys, DIDKey{Fragment: fragment, PublicKey: publicKey})
+	}
+	return keys, nil
+}
+
+// parsePublicKeyPEM parses a PEM (PKIX) public key of a supported algorithm
+func parsePublicKeyPEM(keyPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in public key")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	if _, err := publicKeyJWK(publicKey); err != nil {
+		return nil, err
+	}
+	return publicKey, nil
+}
+
+// NewDIDDocument builds the DID document for an issuer DID, listing each key as a
+// verification method usable for credential proofs. Publishing retired keys
+// alongside the current one keeps credentials signed before a rotation verifiable.
+func NewDIDDocument(did string, keys ...DIDKey) (*DIDDocument, error) {
+	if _, err := DIDWebURL(did); err != nil {
+		return nil, err
+	}
+
+	doc := &DIDDocument{Context: didDocumentContexts, ID: did}
+	for _, key := range keys {
+		jwk, err := publicKeyJWK(key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		id := did + "#" + key.Fragment
+		doc.VerificationMethod = append(doc.VerificationMethod, DIDVerificationMethod{
+			ID:           id,
+			Type:         jsonWebKeyType,
+			Controller:   did,
+			PublicKeyJWK: jwk,
+		})
+		doc.AssertionMethod = append(doc.AssertionMethod, id)
+	}
+	return doc, nil
+}
+
+// PublicKey returns the key of the verification method with the given DID URL.
+// Methods may be listed by full DID URL or by fragment alone, and must be
+// authorized for assertions when the document lists assertion methods.
+func (d *DIDDocument) PublicKey(verificationMethod string) (crypto.PublicKey, error) {
+	_, fragment := splitDIDURL(verificationMethod)
+	matches := func(id string) bool {
+		return id == verificationMethod || fragment != "" && id == "#"+fragment
+	}
+
+	if len(d.AssertionMethod) > 0 {
+		authorized := false
+		for _, id := range d.AssertionMethod {
+			authorized = authorized || matches(id)
+		}
+		if !authorized {
+			return nil, fmt.Errorf("%w: %s is not an assertion method", ErrDIDResolution, verificationMethod)
+		}
+	}
+
+	for _, method := range d.VerificationMethod {
+		if !matches(method.ID) {
+			continue
+		}
+		if method.PublicKeyJWK == nil {
+			return nil, fmt.Errorf("%w: %s has no publicKeyJwk", ErrDIDResolution, verificationMethod)
+		}
+		return method.PublicKeyJWK.PublicKey()
+	}
+	return nil, fmt.Errorf("%w: no verification method %s", ErrDIDResolution, verificationMethod)
+}
+
+// DIDResolver fetches the DID document of a DID
+type DIDResolver interface {
+	Resolve(ctx context.Context, did string) (*DIDDocument, error)
+}
+
+// WebDIDResolver resolves did:web DIDs over HTTPS. A nil Client uses a client
+// with a 10 second timeout.
+type WebDIDResolver struct {
+	Client *http.Client
+}
+
+// Resolve downloads and decodes the DID document, checking that it describes did
+func (r WebDIDResolver) Resolve(ctx context.Context, did string) (*DIDDocument, error) {
+	location, err := DIDWebURL(did)
+	if err != nil {
+		return nil, err
+	}
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDIDResolution, err)
+	}
+	req.Header.Set("Accept", "application/did+json, application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDIDResolution, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %s", ErrDIDResolution, location, resp.Status)
+	}
+
+	var doc DIDDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDIDDocumentSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: invalid DID document: %v", ErrDIDResolution, err)
+	}
+	if doc.ID != did {
+		return nil, fmt.Errorf("%w: document id %q does not match %q", ErrDIDResolution, doc.ID, did)
+	}
+	return &doc, nil
+}
+
+// ResolveVerificationKey resolves the DID of a DID URL verification method and
+// returns the public key it names
+func ResolveVerificationKey(ctx context.Context, resolver DIDResolver, verificationMethod string) (crypto.PublicKey, error) {
+	did, fragment := splitDIDURL(verificationMethod)
+	if !IsDID(did) || fragment == "" {
+		return nil, fmt.Errorf("%w: verification method %q is not a DID URL", ErrInvalidDID, verificationMethod)
+	}
+	doc, err := resolver.Resolve(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	return doc.PublicKey(verificationMethod)
+}
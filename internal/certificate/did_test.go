@@ -0,0 +1,302 @@
+package certificate
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// stubResolver serves DID documents from memory
+type stubResolver map[string]*DIDDocument
+
+func (s stubResolver) Resolve(ctx context.Context, did string) (*DIDDocument, error) {
+	doc, ok := s[did]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s not found", ErrDIDResolution, did)
+	}
+	return doc, nil
+}
+
+func TestDIDWebURL(t *testing.T) {
+	testCases := []struct {
+		did         string
+		expectedURL string
+	}{
+		{did: "did:web:proofpix.com", expectedURL: "https://proofpix.com/.well-known/did.json"},
+		{did: "did:web:proofpix.com:issuers:main", expectedURL: "https://proofpix.com/issuers/main/did.json"},
+		{did: "did:web:localhost%3A8443", expectedURL: "https://localhost:8443/.well-known/did.json"},
+		{did: "did:key:z6Mk"},
+		{did: "https://proofpix.com"},
+		{did: "did:web:"},
+		{did: "did:web:proofpix.com%2Fevil"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.did, func(t *testing.T) {
+			location, err := DIDWebURL(tc.did)
+			if tc.expectedURL == "" {
+				if !errors.Is(err, ErrInvalidDID) {
+					t.Errorf("Expected ErrInvalidDID, but got %q, %v", location, err)
+				}
+				return
+			}
+			if err != nil || location != tc.expectedURL {
+				t.Errorf("Expected %q, but got %q (%v)", tc.expectedURL, location, err)
+			}
+		})
+	}
+}
+
+func TestIssuerFromEnv(t *testing.T) {
+	testCases := []struct {
+		name     string
+		did      string
+		expected string
+	}{
+		{name: "DIDs disabled", did: "", expected: DefaultIssuer},
+		{name: "did:web issuer", did: "did:web:proofpix.com", expected: "did:web:proofpix.com"},
+		{name: "Invalid falls back", did: "did:example:123", expected: DefaultIssuer},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CERTIFICATE_ISSUER_DID", tc.did)
+			credential, err := Generate(&models.Asset{ID: "asset-1", UserID: "user-1"})
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if credential.Issuer != tc.expected {
+				t.Errorf("Expected issuer %q, but got %q", tc.expected, credential.Issuer)
+			}
+		})
+	}
+}
+
+func TestVerifyWithResolver_DIDIssuedCredential(t *testing.T) {
+	const did = "did:web:proofpix.com"
+	t.Setenv("CERTIFICATE_ISSUER_DID", did)
+	t.Setenv("CERTIFICATE_SIGNING_ALGORITHM", AlgorithmEd25519)
+	t.Setenv("CERTIFICATE_VERIFICATION_METHOD", "#key-2")
+
+	// The issuer publishes a retired P-256 key and the current Ed25519 key
+	_, currentKey, _ := ed25519.GenerateKey(rand.Reader)
+	retiredKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	keyFile := t.TempDir() + "/signing.pem"
+	if err := os.WriteFile(keyFile, pkcs8PEM(t, currentKey), 0o600); err != nil {
+		t.Fatalf("Failed to write signing key: %v", err)
+	}
+	t.Setenv("CERTIFICATE_SIGNING_KEY_FILE", keyFile)
+
+	signer, err := SigningConfigFromEnv()
+	if err != nil {
+		t.Fatalf("SigningConfigFromEnv() failed: %v", err)
+	}
+	if signer.VerificationMethod != did+"#key-2" {
+		t.Fatalf("Expected the fragment to expand to %s#key-2, but got %q", did, signer.VerificationMethod)
+	}
+
+	asset := &models.Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 9, CreatedAt: time.Now()}
+	credential, err := Generate(asset)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if err := signer.Sign(credential); err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	published, err := NewDIDDocument(did,
+		DIDKey{Fragment: "key-1", PublicKey: retiredKey.Public()},
+		DIDKey{Fragment: "key-2", PublicKey: currentKey.Public()},
+	)
+	if err != nil {
+		t.Fatalf("NewDIDDocument() failed: %v", err)
+	}
+	wrongKey, _ := NewDIDDocument(did, DIDKey{Fragment: "key-2", PublicKey: otherKey.Public()})
+	notAssertion := *published
+	notAssertion.AssertionMethod = []string{did + "#key-1"}
+	relative, _ := NewDIDDocument(did, DIDKey{Fragment: "key-2", PublicKey: currentKey.Public()})
+	relative.VerificationMethod[0].ID = "#key-2"
+	relative.AssertionMethod = []string{"#key-2"}
+
+	testCases := []struct {
+		name        string
+		resolver    stubResolver
+		issuer      string
+		trustedDID  string
+		expectValid bool
+	}{
+		{name: "Key found in DID document", resolver: stubResolver{did: published}, expectValid: true},
+		{name: "Relative verification method ids", resolver: stubResolver{did: relative}, expectValid: true},
+		{name: "Different key under the fragment", resolver: stubResolver{did: wrongKey}},
+		{name: "Key not an assertion method", resolver: stubResolver{did: &notAssertion}},
+		{name: "DID cannot be resolved", resolver: stubResolver{}},
+		{name: "Issuer does not control the key", resolver: stubResolver{did: published}, issuer: "did:web:example.com"},
+		{name: "Key of the trusted DID", resolver: stubResolver{did: published}, trustedDID: did, expectValid: true},
+		{name: "Issuer other than the trusted DID", resolver: stubResolver{did: published}, trustedDID: "did:web:example.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checked := *credential
+			if tc.issuer != "" {
+				checked.Issuer = tc.issuer
+			}
+			err := VerifyWithResolver(context.Background(), &checked, asset, tc.resolver, tc.trustedDID)
+			if tc.expectValid && err != nil {
+				t.Errorf("Expected the credential to verify, but got %v", err)
+			}
+			if !tc.expectValid && !errors.Is(err, ErrInconsistentCertificate) {
+				t.Errorf("Expected ErrInconsistentCertificate, but got %v", err)
+			}
+		})
+	}
+
+	// Unsigned credentials with the https issuer need no resolver
+	t.Setenv("CERTIFICATE_ISSUER_DID", "")
+	unsigned, _ := Generate(asset)
+
+	// Signed ones name no DID that must control the key, so one has to be trusted
+	httpsIssued, _ := Generate(asset)
+	if err := signer.Sign(httpsIssued); err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if err := VerifyWithResolver(context.Background(), httpsIssued, asset, stubResolver{did: published}, ""); !errors.Is(err, ErrInconsistentCertificate) {
+		t.Errorf("Expected ErrInconsistentCertificate without a trusted DID, but got %v", err)
+	}
+	if err := VerifyWithResolver(context.Background(), httpsIssued, asset, stubResolver{did: published}, did); err != nil {
+		t.Errorf("Expected a key of the trusted DID to verify, but got %v", err)
+	}
+	if err := VerifyWithResolver(context.Background(), unsigned, asset, stubResolver{}, ""); err != nil {
+		t.Errorf("Expected an unsigned credential to verify without a DID, but got %v", err)
+	}
+}
+
+func TestWebDIDResolver(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	var did string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		doc, _ := NewDIDDocument(did, DIDKey{Fragment: "key-1", PublicKey: key.Public()})
+		json.NewEncoder(w).Encode(doc)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	did = "did:web:" + strings.ReplaceAll(host, ":", "%3A")
+	resolver := WebDIDResolver{Client: server.Client()}
+
+	publicKey, err := ResolveVerificationKey(context.Background(), resolver, did+"#key-1")
+	if err != nil {
+		t.Fatalf("ResolveVerificationKey() failed: %v", err)
+	}
+	if !key.Public().(ed25519.PublicKey).Equal(publicKey) {
+		t.Errorf("Expected the published key, but got %v", publicKey)
+	}
+
+	if _, err := ResolveVerificationKey(context.Background(), resolver, did+"#key-9"); !errors.Is(err, ErrDIDResolution) {
+		t.Errorf("Expected ErrDIDResolution for an unknown key, but got %v", err)
+	}
+	if _, err := resolver.Resolve(context.Background(), did+":missing"); !errors.Is(err, ErrDIDResolution) {
+		t.Errorf("Expected ErrDIDResolution for a missing document, but got %v", err)
+	}
+}
+
+func TestDIDContext_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name        string
+		context     string
+		expected    []string
+		expectError bool
+	}{
+		{name: "Array", context: `["https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/jws-2020/v1"]`, expected: didDocumentContexts},
+		{name: "Single string", context: `"https://www.w3.org/ns/did/v1"`, expected: []string{"https://www.w3.org/ns/did/v1"}},
+		{name: "Object", context: `{"@vocab": "https://example.com/"}`, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc DIDDocument
+			err := json.Unmarshal([]byte(`{"@context": `+tc.context+`, "id": "did:web:proofpix.com", "verificationMethod": []}`), &doc)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, but got context %v", doc.Context)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to decode DID document: %v", err)
+			}
+			if strings.Join(doc.Context, " ") != strings.Join(tc.expected, " ") {
+				t.Errorf("Expected context %v, but got %v", tc.expected, doc.Context)
+			}
+		})
+	}
+}
+
+func TestRetiredKeysFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(publicKey)
+	keyFile := filepath.Join(dir, "key-1.pub.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	testCases := []struct {
+		name              string
+		value             string
+		expectedFragments []string
+		expectError       bool
+	}{
+		{name: "Unset", value: ""},
+		{name: "One key", value: "key-1=" + keyFile, expectedFragments: []string{"key-1"}},
+		{name: "Fragment with hash", value: "#key-1=" + keyFile + ", key-0=" + keyFile, expectedFragments: []string{"key-1", "key-0"}},
+		{name: "Missing file", value: "key-1=" + filepath.Join(dir, "missing.pem"), expectError: true},
+		{name: "Missing fragment", value: keyFile, expectError: true},
+		{name: "Duplicate fragment", value: "key-1=" + keyFile + ",key-1=" + keyFile, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CERTIFICATE_RETIRED_KEYS", tc.value)
+			keys, err := RetiredKeysFromEnv()
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, but got %d keys", len(keys))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RetiredKeysFromEnv() failed: %v", err)
+			}
+			if len(keys) != len(tc.expectedFragments) {
+				t.Fatalf("Expected %d keys, but got %d", len(tc.expectedFragments), len(keys))
+			}
+			for i, key := range keys {
+				if key.Fragment != tc.expectedFragments[i] || !publicKey.Equal(key.PublicKey) {
+					t.Errorf("Expected the key under %s, but got %s", tc.expectedFragments[i], key.Fragment)
+				}
+			}
+		})
+	}
+}
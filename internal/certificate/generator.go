@@ -15,9 +15,7 @@ func Generate(asset *models.Asset) (*VerifiableCredential, error) {
 	}
 
 	// Generate proof value from asset ID and created timestamp
-	proofData := asset.ID + asset.CreatedAt.Format(time.RFC3339)
-	hash := sha256.Sum256([]byte(proofData))
-	proofValue := fmt.Sprintf("%x", hash)
+	proofValue := ComputeProofValue(asset)
 
 	// Set current time as issuance date and proof creation time
 	now := time.Now()
@@ -27,18 +25,24 @@ func Generate(asset *models.Asset) (*VerifiableCredential, error) {
 	// Create credential subject ID based on asset ID
 	credentialSubjectID := fmt.Sprintf("urn:proofpix:asset:%s", asset.ID)
 
-	// Set rating value based on originality score (1-10 scale)
-	ratingValue := asset.OriginalityScore
-	if ratingValue < 1 {
-		ratingValue = 1
-	} else if ratingValue > 10 {
-		ratingValue = 10
-	}
+	// Map the originality score onto the 1-10 rating scale (CERTIFICATE_RATING_THRESHOLDS)
+	ratingValue := RatingMappingFromEnv().Rating(asset.OriginalityScore)
 
 	// Use narrative from asset or fallback to raw analysis
-	authenticityNarrative := asset.Narrative
+	authenticityNarrative, narrativeLanguage := asset.Narrative, asset.NarrativeLanguage
 	if authenticityNarrative == "" {
-		authenticityNarrative = asset.RawAnalysis
+		authenticityNarrative, narrativeLanguage = asset.RawAnalysis, ""
+	}
+	// The credential is public; the asset keeps the unredacted text
+	authenticityNarrative = RedactNarrative(authenticityNarrative)
+
+	// Record the likely source of a near-duplicate image
+	var relatedAsset *RelatedAsset
+	if asset.RelatedAsset != nil {
+		relatedAsset = &RelatedAsset{
+			ID:       fmt.Sprintf("urn:proofpix:asset:%s", asset.RelatedAsset.AssetID),
+			Distance: asset.RelatedAsset.Distance,
+		}
 	}
 
 	// Create the verifiable credential
@@ -51,7 +55,7 @@ func Generate(asset *models.Asset) (*VerifiableCredential, error) {
 			"VerifiableCredential",
 			"ProofPixAuthenticityCredential",
 		},
-		Issuer:       "https://proofpix.com",
+		Issuer:       IssuerFromEnv(),
 		IssuanceDate: issuanceDate,
 		CredentialSubject: CredentialSubject{
 			ID:      credentialSubjectID,
@@ -60,10 +64,13 @@ func Generate(asset *models.Asset) (*VerifiableCredential, error) {
 			AuthenticityRating: AuthenticityRating{
 				Type:        "Rating",
 				RatingValue: ratingValue,
-				BestRating:  10,
-				WorstRating: 1,
+				BestRating:  BestRating,
+				WorstRating: WorstRating,
 			},
-			AuthenticityNarrative: authenticityNarrative,
+			AuthenticityNarrative:         authenticityNarrative,
+			AuthenticityNarrativeLanguage: narrativeLanguage,
+			ModelVersion:                  asset.ModelVersion,
+			RelatedAsset:                  relatedAsset,
 		},
 		Proof: Proof{
 			Type:         "DataIntegrityProof",
@@ -73,5 +80,17 @@ func Generate(asset *models.Asset) (*VerifiableCredential, error) {
 		},
 	}
 
+	if IncludeWorkerFromEnv() {
+		credential.Proof.ProcessedBy = asset.ProcessedBy
+	}
+
 	return credential, nil
-}
\ No newline at end of file
+}
+
+// ComputeProofValue derives the credential proof value from the asset ID and creation time.
+// The timestamp is normalised to UTC so the value survives a Firestore round trip.
+func ComputeProofValue(asset *models.Asset) string {
+	proofData := asset.ID + asset.CreatedAt.UTC().Format(time.RFC3339)
+	hash := sha256.Sum256([]byte(proofData))
+	return fmt.Sprintf("%x", hash)
+}
@@ -1,6 +1,8 @@
 package certificate
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +20,7 @@ func TestGenerate(t *testing.T) {
 		OriginalityScore: 8,
 		Narrative:        "High confidence in image authenticity",
 		Embedding:        []float32{0.1, 0.2, 0.3},
+		ModelVersion:     "gemini-1.5-flash-002",
 	}
 
 	// Generate the verifiable credential
@@ -59,6 +62,10 @@ func TestGenerate(t *testing.T) {
 		t.Errorf("CredentialSubject.Creator = %s, want user-456", credential.CredentialSubject.Creator)
 	}
 
+	if credential.CredentialSubject.ModelVersion != "gemini-1.5-flash-002" {
+		t.Errorf("CredentialSubject.ModelVersion = %s, want gemini-1.5-flash-002", credential.CredentialSubject.ModelVersion)
+	}
+
 	if credential.CredentialSubject.AuthenticityRating.RatingValue != 8 {
 		t.Errorf("AuthenticityRating.RatingValue = %d, want 8", credential.CredentialSubject.AuthenticityRating.RatingValue)
 	}
@@ -113,4 +120,95 @@ func TestGenerateWithFallbackNarrative(t *testing.T) {
 	if credential.CredentialSubject.AuthenticityNarrative != "Fallback analysis text" {
 		t.Errorf("AuthenticityNarrative = %s, want 'Fallback analysis text'", credential.CredentialSubject.AuthenticityNarrative)
 	}
-}
\ No newline at end of file
+}
+func TestGenerateRelatedAsset(t *testing.T) {
+	testCases := []struct {
+		name         string
+		relatedAsset *models.RelatedAsset
+		expected     *RelatedAsset
+	}{
+		{name: "Near duplicate", relatedAsset: &models.RelatedAsset{AssetID: "original-1", Distance: 0.02}, expected: &RelatedAsset{ID: "urn:proofpix:asset:original-1", Distance: 0.02}},
+		{name: "No near match", relatedAsset: nil, expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			credential, err := Generate(&models.Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 7, RelatedAsset: tc.relatedAsset})
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+
+			got := credential.CredentialSubject.RelatedAsset
+			if tc.expected == nil {
+				if got != nil {
+					t.Errorf("Expected no relatedAsset, but got %+v", got)
+				}
+			} else if got == nil || *got != *tc.expected {
+				t.Errorf("Expected relatedAsset %+v, but got %+v", tc.expected, got)
+			}
+
+			data, _ := json.Marshal(credential)
+			if present := strings.Contains(string(data), `"relatedAsset"`); present != (tc.expected != nil) {
+				t.Errorf("Expected relatedAsset in the JSON to be %t, but got %s", tc.expected != nil, data)
+			}
+		})
+	}
+}
+
+func TestGenerateProcessedBy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		include  string
+		expected string
+	}{
+		{name: "Included when enabled", include: "true", expected: "europe-west4-b"},
+		{name: "Omitted by default", include: ""},
+		{name: "Invalid setting omits it", include: "sometimes"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CERTIFICATE_INCLUDE_WORKER", tc.include)
+			credential, err := Generate(&models.Asset{ID: "asset-1", UserID: "user-1", ProcessedBy: "europe-west4-b"})
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if credential.Proof.ProcessedBy != tc.expected {
+				t.Errorf("Expected processedBy %q, but got %q", tc.expected, credential.Proof.ProcessedBy)
+			}
+			data, _ := json.Marshal(credential)
+			if present := strings.Contains(string(data), `"processedBy"`); present != (tc.expected != "") {
+				t.Errorf("Expected processedBy in the JSON to be %t, but got %s", tc.expected != "", data)
+			}
+		})
+	}
+}
+
+func TestGenerateNarrativeLanguage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		asset    *models.Asset
+		expected string
+	}{
+		{name: "Tagged narrative", asset: &models.Asset{ID: "asset-1", Narrative: "La lumière est naturelle.", NarrativeLanguage: "fr"}, expected: "fr"},
+		{name: "Untagged narrative", asset: &models.Asset{ID: "asset-1", Narrative: "Natural lighting."}, expected: ""},
+		{name: "Fallback to raw analysis", asset: &models.Asset{ID: "asset-1", RawAnalysis: "Analyse brute", NarrativeLanguage: "fr"}, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			credential, err := Generate(tc.asset)
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if got := credential.CredentialSubject.AuthenticityNarrativeLanguage; got != tc.expected {
+				t.Errorf("Expected narrative language %q, but got %q", tc.expected, got)
+			}
+
+			data, _ := json.Marshal(credential)
+			if present := strings.Contains(string(data), `"authenticityNarrativeLanguage"`); present != (tc.expected != "") {
+				t.Errorf("Expected authenticityNarrativeLanguage in the JSON to be %t, but got %s", tc.expected != "", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package certificate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// JWT algorithms used for VC-JWT encodings
+const (
+	jwtAlgorithmNone  = "none"
+	jwtAlgorithmEdDSA = "EdDSA"
+	jwtAlgorithmES256 = "ES256"
+)
+
+// jwtHeader is the JOSE header of a VC-JWT
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// jwtClaims are the registered claims of a VC-JWT with the credential under "vc"
+type jwtClaims struct {
+	Issuer    string                `json:"iss"`
+	Subject   string                `json:"sub"`
+	NotBefore int64                 `json:"nbf,omitempty"`
+	JWTID     string                `json:"jti,omitempty"`
+	VC        *VerifiableCredential `json:"vc"`
+}
+
+// ErrSignerRequired is returned when a token that must be signed is encoded without a signer
+var ErrSignerRequired = errors.New("a signing key is required")
+
+// EncodeJWT serializes the credential as a VC-JWT (VC Data Model 1.1, section 6.3.1).
+// With a signer the token is signed with EdDSA or ES256 to match its algorithm;
+// without one it is an unsecured JWT ("alg": "none") whose integrity rests on the
+// credential's own proof.
+func EncodeJWT(credential *VerifiableCredential, signer *SigningConfig) (string, error) {
+	claims := jwtClaims{
+		Issuer:  credential.Issuer,
+		Subject: credential.CredentialSubject.ID,
+		JWTID:   credential.CredentialSubject.ID,
+		VC:      credential,
+	}
+	if issued, err := time.Parse(time.RFC3339, credential.IssuanceDate); err == nil {
+		claims.NotBefore = issued.Unix()
+	}
+	return encodeJWT(claims, signer)
+}
+
+// VerificationResult is the outcome of verifying an asset against the log, as
+// attested by a verification JWT
+type VerificationResult struct {
+	AssetID string `json:"asset_id"`
+	Score   int    `json:"score"`
+	// Status is the inclusion status: logged, pending_inclusion or not_anchored
+	Status            string `json:"status"`
+	Logged            bool   `json:"logged"`
+	CertificateStatus string `json:"certificate_status,omitempty"`
+	LeafIndex         int64  `json:"leaf_index,omitempty"`
+	// TreeSize and RootHash (hex) identify the log root the inclusion proof was
+	// checked against
+	TreeSize uint64 `json:"tree_size,omitempty"`
+	RootHash string `json:"root_hash,omitempty"`
+}
+
+// verificationClaims are the claims of a verification JWT, with the result under
+// "verification" in the way a VP-JWT carries its presentation under "vp"
+type verificationClaims struct {
+	Issuer       string              `json:"iss"`
+	Subject      string              `json:"sub"`
+	IssuedAt     int64               `json:"iat"`
+	Verification *VerificationResult `json:"verification"`
+}
+
+// EncodeVerificationJWT serializes a verification result as a JWT signed by issuer
+// at issuedAt. Unlike a VC-JWT it has no embedded proof to fall back on, so a
+// signer is required.
+func EncodeVerificationJWT(issuer string, result *VerificationResult, signer *SigningConfig, issuedAt time.Time) (string, error) {
+	if signer == nil {
+		return "", ErrSignerRequired
+	}
+	return encodeJWT(verificationClaims{
+		Issuer:       issuer,
+		Subject:      result.AssetID,
+		IssuedAt:     issuedAt.Unix(),
+		Verification: result,
+	}, signer)
+}
+
+// encodeJWT encodes and signs claims, producing an unsecured JWT without a signer
+func encodeJWT(claims interface{}, signer *SigningConfig) (string, error) {
+	header := jwtHeader{Algorithm: jwtAlgorithmNone, Type: "JWT"}
+	if signer != nil {
+		switch signer.Algorithm {
+		case AlgorithmEd25519:
+			header.Algorithm = jwtAlgorithmEdDSA
+		case AlgorithmECDSAP256:
+			header.Algorithm = jwtAlgorithmES256
+		default:
+			return "", fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, signer.Algorithm)
+		}
+		header.KeyID = signer.VerificationMethod
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT header: %v", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT claims: %v", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	if signer == nil {
+		return signingInput + ".", nil
+	}
+	signature, err := signer.signBytes([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
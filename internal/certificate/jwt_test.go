@@ -0,0 +1,159 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// decodeJWT splits a token and decodes its header, claims and signature
+func decodeJWT(t *testing.T, token string) (jwtHeader, jwtClaims, string, []byte) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 JWT segments, but got %d", len(parts))
+	}
+	var header jwtHeader
+	var claims jwtClaims
+	for i, target := range []interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatalf("Segment %d is not base64url: %v", i, err)
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			t.Fatalf("Segment %d is not JSON: %v", i, err)
+		}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("Signature is not base64url: %v", err)
+	}
+	return header, claims, parts[0] + "." + parts[1], signature
+}
+
+func TestEncodeJWT(t *testing.T) {
+	credential, err := Generate(&models.Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 9, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edSigner, err := NewSigningConfig(AlgorithmEd25519, pkcs8PEM(t, edKey), "did:web:proofpix.com#key-1")
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+	ecSigner, err := NewSigningConfig(AlgorithmECDSAP256, pkcs8PEM(t, ecKey), "")
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+
+	testCases := []struct {
+		name              string
+		signer            *SigningConfig
+		expectedAlgorithm string
+		verify            func(signingInput string, signature []byte) bool
+	}{
+		{
+			name:              "Unsecured",
+			expectedAlgorithm: "none",
+			verify:            func(signingInput string, signature []byte) bool { return len(signature) == 0 },
+		},
+		{
+			name:              "EdDSA",
+			signer:            edSigner,
+			expectedAlgorithm: "EdDSA",
+			verify: func(signingInput string, signature []byte) bool {
+				return ed25519.Verify(edKey.Public().(ed25519.PublicKey), []byte(signingInput), signature)
+			},
+		},
+		{
+			name:              "ES256",
+			signer:            ecSigner,
+			expectedAlgorithm: "ES256",
+			verify: func(signingInput string, signature []byte) bool {
+				if len(signature) != 64 {
+					return false
+				}
+				digest := sha256.Sum256([]byte(signingInput))
+				r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+				return ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := EncodeJWT(credential, tc.signer)
+			if err != nil {
+				t.Fatalf("EncodeJWT() failed: %v", err)
+			}
+
+			header, claims, signingInput, signature := decodeJWT(t, token)
+			if header.Algorithm != tc.expectedAlgorithm || header.Type != "JWT" {
+				t.Errorf("Expected alg %s and typ JWT, but got %+v", tc.expectedAlgorithm, header)
+			}
+			if claims.Issuer != credential.Issuer || claims.Subject != credential.CredentialSubject.ID {
+				t.Errorf("Expected iss/sub from the credential, but got %q/%q", claims.Issuer, claims.Subject)
+			}
+			if claims.NotBefore == 0 {
+				t.Errorf("Expected nbf from the issuance date")
+			}
+			if claims.VC == nil || claims.VC.Proof.ProofValue != credential.Proof.ProofValue {
+				t.Errorf("Expected the credential under vc, but got %+v", claims.VC)
+			}
+			if !tc.verify(signingInput, signature) {
+				t.Errorf("Expected a valid %s signature", tc.expectedAlgorithm)
+			}
+		})
+	}
+}
+
+func TestEncodeVerificationJWT(t *testing.T) {
+	result := &VerificationResult{AssetID: "asset-1", Score: 9, Status: "logged", Logged: true, LeafIndex: 3, TreeSize: 4, RootHash: "abcd"}
+	if _, err := EncodeVerificationJWT(DefaultIssuer, result, nil, time.Now()); !errors.Is(err, ErrSignerRequired) {
+		t.Errorf("Expected ErrSignerRequired without a signer, but got %v", err)
+	}
+
+	publicKey, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := NewSigningConfig(AlgorithmEd25519, pkcs8PEM(t, key), "did:web:proofpix.com#key-1")
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+	issuedAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	token, err := EncodeVerificationJWT(DefaultIssuer, result, signer, issuedAt)
+	if err != nil {
+		t.Fatalf("EncodeVerificationJWT() failed: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 JWT segments, but got %d", len(parts))
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		t.Errorf("Expected a valid EdDSA signature")
+	}
+	var claims verificationClaims
+	data, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(data, &claims); err != nil {
+		t.Fatalf("Claims are not JSON: %v", err)
+	}
+	if claims.Issuer != DefaultIssuer || claims.Subject != "asset-1" || claims.IssuedAt != issuedAt.Unix() {
+		t.Errorf("Expected iss, sub and iat claims, but got %+v", claims)
+	}
+	if claims.Verification == nil || *claims.Verification != *result {
+		t.Errorf("Expected the result under verification, but got %+v", claims.Verification)
+	}
+}
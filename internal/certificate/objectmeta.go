@@ -0,0 +1,100 @@
+package certificate
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Object kinds whose storage metadata is configurable, used as environment prefixes
+const (
+	CertificateObjects = "CERTIFICATE_OBJECT"
+	BadgeObjects       = "BADGE_OBJECT"
+)
+
+// Cache-Control set on uploaded objects when none is configured. Certificates are
+// replaced on regeneration, so caches revalidate them; badges change rarely.
+const (
+	DefaultCertificateCacheControl = "no-cache"
+	DefaultBadgeCacheControl       = "public, max-age=86400"
+)
+
+// storageClasses are the GCS storage classes objects may be written with
+var storageClasses = map[string]bool{"STANDARD": true, "NEARLINE": true, "COLDLINE": true, "ARCHIVE": true}
+
+// cacheDirectives are the Cache-Control directives accepted, and whether each
+// takes a number of seconds
+var cacheDirectives = map[string]bool{
+	"public": false, "private": false, "no-cache": false, "no-store": false, "no-transform": false,
+	"must-revalidate": false, "proxy-revalidate": false, "immutable": false,
+	"max-age": true, "s-maxage": true, "stale-while-revalidate": true, "stale-if-error": true,
+}
+
+// ObjectMetadata is the storage metadata set on uploaded objects. An empty
+// StorageClass keeps the bucket's default class.
+type ObjectMetadata struct {
+	CacheControl string
+	StorageClass string
+}
+
+// DefaultObjectMetadata returns the metadata a kind of object is written with when
+// none is configured
+func DefaultObjectMetadata(kind string) ObjectMetadata {
+	if kind == BadgeObjects {
+		return ObjectMetadata{CacheControl: DefaultBadgeCacheControl}
+	}
+	return ObjectMetadata{CacheControl: DefaultCertificateCacheControl}
+}
+
+// ObjectMetadataFromEnv returns the metadata configured for a kind of object in
+// {kind}_CACHE_CONTROL and {kind}_STORAGE_CLASS, with kind CertificateObjects or
+// BadgeObjects
+func ObjectMetadataFromEnv(kind string) (ObjectMetadata, error) {
+	meta := DefaultObjectMetadata(kind)
+
+	if value := os.Getenv(kind + "_CACHE_CONTROL"); value != "" {
+		cacheControl, err := ParseCacheControl(value)
+		if err != nil {
+			return ObjectMetadata{}, fmt.Errorf("invalid %s_CACHE_CONTROL %q: %v", kind, value, err)
+		}
+		meta.CacheControl = cacheControl
+	}
+	if value := os.Getenv(kind + "_STORAGE_CLASS"); value != "" {
+		class := strings.ToUpper(strings.TrimSpace(value))
+		if !storageClasses[class] {
+			return ObjectMetadata{}, fmt.Errorf("invalid %s_STORAGE_CLASS %q: expected STANDARD, NEARLINE, COLDLINE or ARCHIVE", kind, value)
+		}
+		meta.StorageClass = class
+	}
+	return meta, nil
+}
+
+// ParseCacheControl checks a comma-separated list of Cache-Control directives and
+// returns it normalized, such as "public, max-age=3600"
+func ParseCacheControl(value string) (string, error) {
+	var directives []string
+	for _, part := range strings.Split(value, ",") {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		takesSeconds, known := cacheDirectives[name]
+		if !known {
+			return "", fmt.Errorf("unknown directive %q", name)
+		}
+		if takesSeconds != hasArg {
+			if takesSeconds {
+				return "", fmt.Errorf("directive %q needs a number of seconds", name)
+			}
+			return "", fmt.Errorf("directive %q takes no value", name)
+		}
+		if takesSeconds {
+			seconds, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || seconds < 0 {
+				return "", fmt.Errorf("directive %q needs a number of seconds, got %q", name, arg)
+			}
+			name = fmt.Sprintf("%s=%d", name, seconds)
+		}
+		directives = append(directives, name)
+	}
+	return strings.Join(directives, ", "), nil
+}
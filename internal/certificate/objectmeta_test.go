@@ -0,0 +1,56 @@
+package certificate
+
+import "testing"
+
+func TestParseCacheControl(t *testing.T) {
+	testCases := []struct {
+		name          string
+		value         string
+		expected      string
+		expectedError bool
+	}{
+		{name: "Single directive", value: "no-store", expected: "no-store"},
+		{name: "Normalized list", value: "Public,MAX-AGE = 3600 , immutable", expected: "public, max-age=3600, immutable"},
+		{name: "Unknown directive", value: "public, forever", expectedError: true},
+		{name: "Missing seconds", value: "max-age", expectedError: true},
+		{name: "Negative seconds", value: "max-age=-1", expectedError: true},
+		{name: "Value on a flag", value: "no-cache=1", expectedError: true},
+		{name: "Empty directive", value: "public,,private", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseCacheControl(tc.value)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("Expected an error, but got %q", got)
+				}
+				return
+			}
+			if err != nil || got != tc.expected {
+				t.Errorf("Expected %q, but got %q (err %v)", tc.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestObjectMetadataFromEnv(t *testing.T) {
+	t.Setenv("CERTIFICATE_OBJECT_CACHE_CONTROL", "")
+	t.Setenv("CERTIFICATE_OBJECT_STORAGE_CLASS", "nearline")
+	t.Setenv("BADGE_OBJECT_CACHE_CONTROL", "")
+	t.Setenv("BADGE_OBJECT_STORAGE_CLASS", "")
+
+	meta, err := ObjectMetadataFromEnv(CertificateObjects)
+	expected := ObjectMetadata{CacheControl: DefaultCertificateCacheControl, StorageClass: "NEARLINE"}
+	if err != nil || meta != expected {
+		t.Errorf("Expected %+v, but got %+v (err %v)", expected, meta, err)
+	}
+	if meta, err := ObjectMetadataFromEnv(BadgeObjects); err != nil || meta != DefaultObjectMetadata(BadgeObjects) {
+		t.Errorf("Expected the badge defaults, but got %+v (err %v)", meta, err)
+	}
+
+	t.Setenv("BADGE_OBJECT_STORAGE_CLASS", "GLACIER")
+	if _, err := ObjectMetadataFromEnv(BadgeObjects); err == nil {
+		t.Errorf("Expected an error for an unknown storage class")
+	}
+}
@@ -0,0 +1,68 @@
+package certificate
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// DefaultPathTemplate is the flat layout certificates were always stored in
+const DefaultPathTemplate = "certificates/{assetID}.json"
+
+// PathTemplate returns the object name template for stored certificates, from
+// CERTIFICATE_PATH_TEMPLATE. Supported placeholders are {assetID}, {userID} and
+// {yyyy}, {mm}, {dd} from the asset's UTC creation date. A template without
+// {assetID} could map two assets to one object and is rejected.
+func PathTemplate() string {
+	template := os.Getenv("CERTIFICATE_PATH_TEMPLATE")
+	if template == "" {
+		return DefaultPathTemplate
+	}
+	if !strings.Contains(template, "{assetID}") {
+		log.Printf("Invalid CERTIFICATE_PATH_TEMPLATE %q, using default of %q", template, DefaultPathTemplate)
+		return DefaultPathTemplate
+	}
+	return template
+}
+
+// ObjectPath returns the object name the asset's certificate is stored under
+func ObjectPath(asset *models.Asset) string {
+	return expandPath(PathTemplate(), asset)
+}
+
+// ObjectPaths returns the object names to look for an asset's certificate under:
+// the configured path first, then the flat default for certificates written
+// before the template was changed
+func ObjectPaths(asset *models.Asset) []string {
+	paths := []string{ObjectPath(asset)}
+	if legacy := expandPath(DefaultPathTemplate, asset); legacy != paths[0] {
+		paths = append(paths, legacy)
+	}
+	return paths
+}
+
+// HistoryObjectPath returns the object name a replaced certificate is kept under:
+// a history folder beside the current certificate, named by when it was replaced
+func HistoryObjectPath(asset *models.Asset, replacedAt time.Time) string {
+	return HistoryPrefix(asset) + replacedAt.UTC().Format("20060102T150405.000000000Z") + ".json"
+}
+
+// HistoryPrefix returns the prefix of every replaced certificate kept for the asset
+// under the configured path
+func HistoryPrefix(asset *models.Asset) string {
+	return strings.TrimSuffix(ObjectPath(asset), ".json") + "/history/"
+}
+
+func expandPath(template string, asset *models.Asset) string {
+	created := asset.CreatedAt.UTC()
+	return strings.NewReplacer(
+		"{assetID}", asset.ID,
+		"{userID}", asset.UserID,
+		"{yyyy}", created.Format("2006"),
+		"{mm}", created.Format("01"),
+		"{dd}", created.Format("02"),
+	).Replace(template)
+}
@@ -0,0 +1,61 @@
+package certificate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestObjectPath_Template(t *testing.T) {
+	asset := &models.Asset{
+		ID:        "asset-1",
+		UserID:    "user-9",
+		CreatedAt: time.Date(2024, 3, 7, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*3600)),
+	}
+
+	testCases := []struct {
+		name          string
+		template      string
+		expectedPath  string
+		expectedPaths []string
+	}{
+		{
+			name:          "Default flat layout",
+			expectedPath:  "certificates/asset-1.json",
+			expectedPaths: []string{"certificates/asset-1.json"},
+		},
+		{
+			name:          "Per-user folders",
+			template:      "certificates/{userID}/{assetID}.json",
+			expectedPath:  "certificates/user-9/asset-1.json",
+			expectedPaths: []string{"certificates/user-9/asset-1.json", "certificates/asset-1.json"},
+		},
+		{
+			name:          "Date partitions use UTC",
+			template:      "certificates/{yyyy}/{mm}/{dd}/{assetID}.json",
+			expectedPath:  "certificates/2024/03/08/asset-1.json",
+			expectedPaths: []string{"certificates/2024/03/08/asset-1.json", "certificates/asset-1.json"},
+		},
+		{
+			name:          "Template without asset ID falls back to default",
+			template:      "certificates/{userID}.json",
+			expectedPath:  "certificates/asset-1.json",
+			expectedPaths: []string{"certificates/asset-1.json"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CERTIFICATE_PATH_TEMPLATE", tc.template)
+
+			if got := ObjectPath(asset); got != tc.expectedPath {
+				t.Errorf("Expected path %q, but got %q", tc.expectedPath, got)
+			}
+			if got := ObjectPaths(asset); !reflect.DeepEqual(got, tc.expectedPaths) {
+				t.Errorf("Expected lookup paths %v, but got %v", tc.expectedPaths, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package certificate
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Bounds of the credential rating scale
+const (
+	WorstRating = 1
+	BestRating  = 10
+)
+
+// Range of originality scores produced by the analysis
+const (
+	MinOriginalityScore = 0
+	MaxOriginalityScore = 100
+)
+
+// RatingThreshold maps every score from MinScore up to the next threshold to Rating
+type RatingThreshold struct {
+	MinScore int
+	Rating   int
+}
+
+// RatingMapping converts originality scores into credential ratings. It lists
+// thresholds in ascending order of MinScore, starting at MinOriginalityScore so that
+// every score maps to a rating. A nil mapping clamps the score into the rating scale.
+type RatingMapping []RatingThreshold
+
+// ParseRatingMapping parses a comma-separated list of score:rating thresholds, such
+// as "0:1,50:4,80:8,95:10", and checks that it covers the whole score range with
+// ratings on the credential scale
+func ParseRatingMapping(spec string) (RatingMapping, error) {
+	var mapping RatingMapping
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		score, rating, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("threshold %q is not score:rating", entry)
+		}
+		minScore, err := strconv.Atoi(strings.TrimSpace(score))
+		if err != nil || minScore < MinOriginalityScore || minScore > MaxOriginalityScore {
+			return nil, fmt.Errorf("threshold %q: score must be between %d and %d", entry, MinOriginalityScore, MaxOriginalityScore)
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(rating))
+		if err != nil || value < WorstRating || value > BestRating {
+			return nil, fmt.Errorf("threshold %q: rating must be between %d and %d", entry, WorstRating, BestRating)
+		}
+		if len(mapping) > 0 && minScore <= mapping[len(mapping)-1].MinScore {
+			return nil, fmt.Errorf("threshold %q: scores must be in ascending order", entry)
+		}
+		mapping = append(mapping, RatingThreshold{MinScore: minScore, Rating: value})
+	}
+	if mapping[0].MinScore != MinOriginalityScore {
+		return nil, fmt.Errorf("first threshold must start at score %d so every score has a rating", MinOriginalityScore)
+	}
+	return mapping, nil
+}
+
+// Rating returns the rating of an originality score. Scores outside the score range
+// are treated as its nearest bound.
+func (m RatingMapping) Rating(score int) int {
+	if len(m) == 0 {
+		return clampRating(score)
+	}
+	if score < MinOriginalityScore {
+		score = MinOriginalityScore
+	} else if score > MaxOriginalityScore {
+		score = MaxOriginalityScore
+	}
+	rating := m[0].Rating
+	for _, threshold := range m {
+		if score < threshold.MinScore {
+			break
+		}
+		rating = threshold.Rating
+	}
+	return rating
+}
+
+// clampRating is the default mapping: the score itself, clamped into the rating scale
+func clampRating(score int) int {
+	if score < WorstRating {
+		return WorstRating
+	}
+	if score > BestRating {
+		return BestRating
+	}
+	return score
+}
+
+// RatingMappingFromEnv returns the mapping in CERTIFICATE_RATING_THRESHOLDS, or nil
+// for the default clamp. An invalid mapping is logged and the default is used.
+func RatingMappingFromEnv() RatingMapping {
+	spec := os.Getenv("CERTIFICATE_RATING_THRESHOLDS")
+	if strings.TrimSpace(spec) == "" {
+		return nil
+	}
+	mapping, err := ParseRatingMapping(spec)
+	if err != nil {
+		log.Printf("Invalid CERTIFICATE_RATING_THRESHOLDS %q, clamping scores instead: %v", spec, err)
+		return nil
+	}
+	return mapping
+}
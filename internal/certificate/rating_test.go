@@ -0,0 +1,92 @@
+package certificate
+
+import (
+	"testing"
+
+	"proofpix/internal/models"
+)
+
+func TestRatingMapping_Rating(t *testing.T) {
+	curve, err := ParseRatingMapping("0:1, 40:2, 60:4, 80:7, 95:10")
+	if err != nil {
+		t.Fatalf("ParseRatingMapping() failed: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		mapping  RatingMapping
+		score    int
+		expected int
+	}{
+		{name: "Default clamps low scores", score: 0, expected: 1},
+		{name: "Default keeps scores on the scale", score: 7, expected: 7},
+		{name: "Default clamps high scores", score: 85, expected: 10},
+		{name: "Lowest band", mapping: curve, score: 0, expected: 1},
+		{name: "Just below a threshold", mapping: curve, score: 39, expected: 1},
+		{name: "On a threshold", mapping: curve, score: 40, expected: 2},
+		{name: "Inside a band", mapping: curve, score: 72, expected: 4},
+		{name: "Steep top of the curve", mapping: curve, score: 94, expected: 7},
+		{name: "Top band", mapping: curve, score: 100, expected: 10},
+		{name: "Below the score range", mapping: curve, score: -5, expected: 1},
+		{name: "Above the score range", mapping: curve, score: 250, expected: 10},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if rating := tc.mapping.Rating(tc.score); rating != tc.expected {
+				t.Errorf("Expected rating %d for score %d, but got %d", tc.expected, tc.score, rating)
+			}
+		})
+	}
+}
+
+func TestParseRatingMapping_Invalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		spec string
+	}{
+		{name: "Does not start at zero", spec: "10:1,50:5"},
+		{name: "Descending scores", spec: "0:1,60:5,40:3"},
+		{name: "Repeated score", spec: "0:1,50:5,50:6"},
+		{name: "Rating above the scale", spec: "0:1,90:11"},
+		{name: "Rating below the scale", spec: "0:0"},
+		{name: "Score above the range", spec: "0:1,101:10"},
+		{name: "Missing separator", spec: "0:1,50"},
+		{name: "Not a number", spec: "0:one"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseRatingMapping(tc.spec); err == nil {
+				t.Errorf("Expected %q to be rejected", tc.spec)
+			}
+		})
+	}
+}
+
+func TestGenerate_RatingThresholds(t *testing.T) {
+	asset := &models.Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 85}
+
+	testCases := []struct {
+		name       string
+		thresholds string
+		expected   int
+	}{
+		{name: "Default clamp", expected: 10},
+		{name: "Custom curve", thresholds: "0:1,50:3,80:6,95:10", expected: 6},
+		{name: "Invalid curve falls back to the clamp", thresholds: "20:1,80:6", expected: 10},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CERTIFICATE_RATING_THRESHOLDS", tc.thresholds)
+			credential, err := Generate(asset)
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if rating := credential.CredentialSubject.AuthenticityRating.RatingValue; rating != tc.expected {
+				t.Errorf("Expected rating %d, but got %d", tc.expected, rating)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package certificate
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// RedactionMarker replaces redacted text in credential narratives
+const RedactionMarker = "[redacted]"
+
+// builtinRedactions are the named patterns that NARRATIVE_REDACTION can enable
+var builtinRedactions = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"phone": regexp.MustCompile(`\+?\d[\d ().-]{7,}\d`),
+	"url":   regexp.MustCompile(`https?://[^\s)]+`),
+}
+
+// redactionPatternsFromEnv returns the patterns to redact from credential narratives:
+// the built-in patterns named in NARRATIVE_REDACTION (comma-separated: email, phone,
+// url) and the regular expressions in NARRATIVE_REDACTION_PATTERNS, one per line.
+// Unknown names and invalid expressions are logged and ignored.
+func redactionPatternsFromEnv() []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, name := range strings.Split(os.Getenv("NARRATIVE_REDACTION"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		pattern, ok := builtinRedactions[name]
+		if !ok {
+			log.Printf("Invalid NARRATIVE_REDACTION entry %q, ignoring it", name)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	for _, expr := range strings.Split(os.Getenv("NARRATIVE_REDACTION_PATTERNS"), "\n") {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("Invalid NARRATIVE_REDACTION_PATTERNS entry %q, ignoring it: %v", expr, err)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// RedactNarrative replaces every match of the configured redaction patterns with
+// RedactionMarker. Credentials are public, so this keeps identifying details the
+// model mentioned out of them; the asset keeps the full text.
+func RedactNarrative(narrative string) string {
+	for _, pattern := range redactionPatternsFromEnv() {
+		narrative = pattern.ReplaceAllString(narrative, RedactionMarker)
+	}
+	return narrative
+}
@@ -0,0 +1,85 @@
+package certificate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestRedactNarrative(t *testing.T) {
+	testCases := []struct {
+		name      string
+		builtins  string
+		patterns  string
+		narrative string
+		expected  string
+	}{
+		{
+			name:      "Redaction disabled",
+			narrative: "Shot by jane@example.com",
+			expected:  "Shot by jane@example.com",
+		},
+		{
+			name:      "Email",
+			builtins:  "email",
+			narrative: "Shot by jane.doe@example.com near the harbour",
+			expected:  "Shot by [redacted] near the harbour",
+		},
+		{
+			name:      "Several built-ins",
+			builtins:  "email, phone,url",
+			narrative: "Contact +1 (555) 123-4567 or see https://example.com/jane.",
+			expected:  "Contact [redacted] or see [redacted]",
+		},
+		{
+			name:      "Custom patterns",
+			patterns:  "(?i)licen[cs]e plate [A-Z0-9-]+\nJane Doe",
+			narrative: "Jane Doe parked by a car with License plate AB-123.",
+			expected:  "[redacted] parked by a car with [redacted].",
+		},
+		{
+			name:      "Unknown and invalid entries are ignored",
+			builtins:  "email,passport",
+			patterns:  "([unclosed",
+			narrative: "Sent from jane@example.com",
+			expected:  "Sent from [redacted]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NARRATIVE_REDACTION", tc.builtins)
+			t.Setenv("NARRATIVE_REDACTION_PATTERNS", tc.patterns)
+			if got := RedactNarrative(tc.narrative); got != tc.expected {
+				t.Errorf("Expected %q, but got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGenerate_RedactsNarrative(t *testing.T) {
+	t.Setenv("NARRATIVE_REDACTION", "email")
+	asset := &models.Asset{
+		ID:          "asset-1",
+		UserID:      "user-1",
+		CreatedAt:   time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		RawAnalysis: "Confidence Score: 0.9\n\nJustification: EXIF names the owner jane@example.com.",
+	}
+
+	credential, err := Generate(asset)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	narrative := credential.CredentialSubject.AuthenticityNarrative
+	if strings.Contains(narrative, "jane@example.com") || !strings.Contains(narrative, RedactionMarker) {
+		t.Errorf("Expected the email to be redacted from the credential, but got %q", narrative)
+	}
+	if !strings.Contains(asset.RawAnalysis, "jane@example.com") {
+		t.Errorf("Expected the raw analysis to keep the email, but got %q", asset.RawAnalysis)
+	}
+	if err := Verify(credential, asset); err != nil {
+		t.Errorf("Expected the redacted credential to verify, but got %v", err)
+	}
+}
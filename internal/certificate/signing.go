@@ -0,0 +1,287 @@
+package certificate
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Supported signing algorithms for credential proofs
+const (
+	AlgorithmEd25519   = "Ed25519"
+	AlgorithmECDSAP256 = "ECDSA-P256"
+)
+
+// Data Integrity cryptosuites declared in the proof for each algorithm. Unsigned
+// credentials leave the cryptosuite empty and carry a digest as their proof value.
+const (
+	CryptosuiteEdDSA = "eddsa-jcs-2022"
+	CryptosuiteECDSA = "ecdsa-jcs-2019"
+)
+
+// signedProofType is the proof type of every signed credential; the cryptosuite selects the algorithm
+const signedProofType = "DataIntegrityProof"
+
+// multibaseBase64URL prefixes proof values encoded as unpadded base64url
+const multibaseBase64URL = "u"
+
+var (
+	// ErrUnsupportedAlgorithm is returned for an unknown algorithm or cryptosuite
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	// ErrKeyAlgorithmMismatch is returned when a key does not match the selected algorithm
+	ErrKeyAlgorithmMismatch = errors.New("key does not match signing algorithm")
+	// ErrInvalidSignature is returned when a credential's signature does not verify
+	ErrInvalidSignature = errors.New("credential signature is invalid")
+)
+
+// SigningConfig selects the algorithm and key used to sign credentials
+type SigningConfig struct {
+	Algorithm string
+	// VerificationMethod identifies the public key in the proof, e.g. a DID URL
+	VerificationMethod string
+	key                crypto.Signer
+}
+
+// NewSigningConfig parses a PEM private key (PKCS#8, or SEC 1 for ECDSA) and checks
+// that it matches the selected algorithm
+func NewSigningConfig(algorithm string, keyPEM []byte, verificationMethod string) (*SigningConfig, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in signing key")
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "EC PRIVATE KEY" {
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %v", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T cannot sign", ErrKeyAlgorithmMismatch, parsed)
+	}
+	if err := checkKeyAlgorithm(algorithm, signer.Public()); err != nil {
+		return nil, err
+	}
+
+	return &SigningConfig{Algorithm: algorithm, VerificationMethod: verificationMethod, key: signer}, nil
+}
+
+// SigningConfigFromEnv returns the signing config selected by CERTIFICATE_SIGNING_ALGORITHM
+// ("Ed25519" or "ECDSA-P256") with the PEM key in CERTIFICATE_SIGNING_KEY_FILE, or nil
+// when no algorithm is set and credentials keep their unsigned digest proof
+func SigningConfigFromEnv() (*SigningConfig, error) {
+	algorithm := os.Getenv("CERTIFICATE_SIGNING_ALGORITHM")
+	if algorithm == "" {
+		return nil, nil
+	}
+
+	keyFile := os.Getenv("CERTIFICATE_SIGNING_KEY_FILE")
+	if keyFile == "" {
+		return nil, fmt.Errorf("CERTIFICATE_SIGNING_KEY_FILE must be set when CERTIFICATE_SIGNING_ALGORITHM is %q", algorithm)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %v", err)
+	}
+
+	return NewSigningConfig(algorithm, keyPEM, verificationMethodFromEnv())
+}
+
+// verificationMethodFromEnv returns CERTIFICATE_VERIFICATION_METHOD. With an issuer
+// DID configured, a bare fragment such as "#key-2" is expanded to a DID URL of the
+// issuer, and an unset method defaults to the issuer's "#key-1".
+func verificationMethodFromEnv() string {
+	method := os.Getenv("CERTIFICATE_VERIFICATION_METHOD")
+	issuer := IssuerFromEnv()
+	if !IsDID(issuer) {
+		return method
+	}
+	switch {
+	case method == "":
+		return issuer + "#key-1"
+	case strings.HasPrefix(method, "#"):
+		return issuer + method
+	}
+	return method
+}
+
+// PublicKey returns the public half of the signing key
+func (c *SigningConfig) PublicKey() crypto.PublicKey {
+	return c.key.Public()
+}
+
+// Sign replaces the credential's digest proof with a signature over the credential
+// using the configured algorithm, setting the proof type and cryptosuite to match
+func (c *SigningConfig) Sign(credential *VerifiableCredential) error {
+	cryptosuite, err := cryptosuiteFor(c.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	credential.Proof.Type = signedProofType
+	credential.Proof.Cryptosuite = cryptosuite
+	credential.Proof.VerificationMethod = c.VerificationMethod
+
+	payload, err := signingPayload(credential)
+	if err != nil {
+		return err
+	}
+	signature, err := c.signBytes(payload)
+	if err != nil {
+		return err
+	}
+
+	credential.Proof.ProofValue = multibaseBase64URL + base64.RawURLEncoding.EncodeToString(signature)
+	return nil
+}
+
+// signBytes signs payload with the configured key. ECDSA signatures use the
+// fixed-size r||s encoding shared by the ecdsa cryptosuites and JWS ES256.
+func (c *SigningConfig) signBytes(payload []byte) ([]byte, error) {
+	switch key := c.key.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, payload), nil
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(payload)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign credential: %v", err)
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrKeyAlgorithmMismatch, c.key)
+	}
+}
+
+// IsSigned reports whether the credential declares a signature cryptosuite rather
+// than an unsigned digest proof
+func IsSigned(credential *VerifiableCredential) bool {
+	return credential.Proof.Cryptosuite != ""
+}
+
+// VerifySignature checks a signed credential against the issuer's public key,
+// dispatching on the cryptosuite declared in its proof
+func VerifySignature(credential *VerifiableCredential, publicKey crypto.PublicKey) error {
+	algorithm, err := algorithmFor(credential.Proof.Cryptosuite)
+	if err != nil {
+		return err
+	}
+	if credential.Proof.Type != signedProofType {
+		return fmt.Errorf("%w: proof type %q", ErrUnsupportedAlgorithm, credential.Proof.Type)
+	}
+	if err := checkKeyAlgorithm(algorithm, publicKey); err != nil {
+		return err
+	}
+
+	encoded := credential.Proof.ProofValue
+	if !strings.HasPrefix(encoded, multibaseBase64URL) {
+		return fmt.Errorf("%w: unexpected proof value encoding", ErrInvalidSignature)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encoded[len(multibaseBase64URL):])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	payload, err := signingPayload(credential)
+	if err != nil {
+		return err
+	}
+	if !verifyBytes(publicKey, payload, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyBytes checks a signature over payload made by signBytes
+func verifyBytes(publicKey crypto.PublicKey, payload, signature []byte) bool {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature)
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(payload)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	}
+	return false
+}
+
+// signingPayload is the data a credential's signature covers, laid out as in the
+// jcs cryptosuites: the SHA-256 of the canonical proof options followed by the
+// SHA-256 of the canonical credential without its proof
+func signingPayload(credential *VerifiableCredential) ([]byte, error) {
+	options, err := canonicalProofOptions(credential.Proof)
+	if err != nil {
+		return nil, err
+	}
+	document, err := Canonicalize(credential)
+	if err != nil {
+		return nil, err
+	}
+	optionsHash, documentHash := sha256.Sum256(options), sha256.Sum256(document)
+	return append(optionsHash[:], documentHash[:]...), nil
+}
+
+// checkKeyAlgorithm verifies that a public key has the type required by the algorithm
+func checkKeyAlgorithm(algorithm string, publicKey crypto.PublicKey) error {
+	switch algorithm {
+	case AlgorithmEd25519:
+		if _, ok := publicKey.(ed25519.PublicKey); ok {
+			return nil
+		}
+	case AlgorithmECDSAP256:
+		if key, ok := publicKey.(*ecdsa.PublicKey); ok && key.Curve == elliptic.P256() {
+			return nil
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, algorithm)
+	}
+	return fmt.Errorf("%w: %s requires a different key than %T", ErrKeyAlgorithmMismatch, algorithm, publicKey)
+}
+
+// cryptosuiteFor maps a signing algorithm to the cryptosuite declared in the proof
+func cryptosuiteFor(algorithm string) (string, error) {
+	switch algorithm {
+	case AlgorithmEd25519:
+		return CryptosuiteEdDSA, nil
+	case AlgorithmECDSAP256:
+		return CryptosuiteECDSA, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, algorithm)
+	}
+}
+
+// algorithmFor maps a declared cryptosuite back to its signing algorithm
+func algorithmFor(cryptosuite string) (string, error) {
+	switch cryptosuite {
+	case CryptosuiteEdDSA:
+		return AlgorithmEd25519, nil
+	case CryptosuiteECDSA:
+		return AlgorithmECDSAP256, nil
+	default:
+		return "", fmt.Errorf("%w: cryptosuite %q", ErrUnsupportedAlgorithm, cryptosuite)
+	}
+}
@@ -0,0 +1,151 @@
+package certificate
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// pkcs8PEM encodes a private key as a PKCS#8 PEM block
+func pkcs8PEM(t *testing.T, key crypto.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestSignAndVerify(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate P-256 key: %v", err)
+	}
+
+	testCases := []struct {
+		name                string
+		algorithm           string
+		key                 crypto.PrivateKey
+		expectedCryptosuite string
+	}{
+		{name: "Ed25519", algorithm: AlgorithmEd25519, key: edKey, expectedCryptosuite: CryptosuiteEdDSA},
+		{name: "ECDSA P-256", algorithm: AlgorithmECDSAP256, key: ecKey, expectedCryptosuite: CryptosuiteECDSA},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := NewSigningConfig(tc.algorithm, pkcs8PEM(t, tc.key), "did:web:proofpix.com#key-1")
+			if err != nil {
+				t.Fatalf("NewSigningConfig() failed: %v", err)
+			}
+
+			asset := &models.Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 9, CreatedAt: time.Now()}
+			credential, err := Generate(asset)
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if err := config.Sign(credential); err != nil {
+				t.Fatalf("Sign() failed: %v", err)
+			}
+
+			if credential.Proof.Type != "DataIntegrityProof" || credential.Proof.Cryptosuite != tc.expectedCryptosuite {
+				t.Errorf("Expected DataIntegrityProof/%s, but got %s/%s", tc.expectedCryptosuite, credential.Proof.Type, credential.Proof.Cryptosuite)
+			}
+			if credential.Proof.VerificationMethod != "did:web:proofpix.com#key-1" {
+				t.Errorf("Expected verification method to be set, but got %q", credential.Proof.VerificationMethod)
+			}
+			if err := VerifySignature(credential, config.PublicKey()); err != nil {
+				t.Errorf("Expected signature to verify, but got %v", err)
+			}
+			if err := VerifyWithKey(credential, asset, config.PublicKey()); err != nil {
+				t.Errorf("Expected signed credential to verify against its asset, but got %v", err)
+			}
+
+			// Any change to the signed content invalidates the signature
+			credential.CredentialSubject.AuthenticityRating.RatingValue = 10
+			if err := VerifySignature(credential, config.PublicKey()); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Expected ErrInvalidSignature for a tampered credential, but got %v", err)
+			}
+			if err := VerifyWithKey(credential, asset, config.PublicKey()); !errors.Is(err, ErrInconsistentCertificate) {
+				t.Errorf("Expected ErrInconsistentCertificate for a tampered credential, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewSigningConfig_KeyMismatch(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate P-384 key: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		algorithm   string
+		key         crypto.PrivateKey
+		expectedErr error
+	}{
+		{name: "Ed25519 key for ECDSA", algorithm: AlgorithmECDSAP256, key: edKey, expectedErr: ErrKeyAlgorithmMismatch},
+		{name: "P-384 key for ECDSA P-256", algorithm: AlgorithmECDSAP256, key: p384Key, expectedErr: ErrKeyAlgorithmMismatch},
+		{name: "ECDSA key for Ed25519", algorithm: AlgorithmEd25519, key: p384Key, expectedErr: ErrKeyAlgorithmMismatch},
+		{name: "Unknown algorithm", algorithm: "RSA", key: edKey, expectedErr: ErrUnsupportedAlgorithm},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewSigningConfig(tc.algorithm, pkcs8PEM(t, tc.key), "")
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected %v, but got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifySignature_WrongKeyType(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	config, err := NewSigningConfig(AlgorithmEd25519, pkcs8PEM(t, edKey), "")
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+	credential, _ := Generate(&models.Asset{ID: "asset-1", UserID: "user-1"})
+	if err := config.Sign(credential); err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	// The declared cryptosuite decides the algorithm, so an ECDSA key is rejected
+	if err := VerifySignature(credential, &ecKey.PublicKey); !errors.Is(err, ErrKeyAlgorithmMismatch) {
+		t.Errorf("Expected ErrKeyAlgorithmMismatch, but got %v", err)
+	}
+}
+
+func TestSigningConfigFromEnv(t *testing.T) {
+	t.Setenv("CERTIFICATE_SIGNING_ALGORITHM", "")
+	if signer, err := SigningConfigFromEnv(); signer != nil || err != nil {
+		t.Errorf("Expected signing to be disabled, but got %v (%v)", signer, err)
+	}
+
+	t.Setenv("CERTIFICATE_SIGNING_ALGORITHM", AlgorithmECDSAP256)
+	t.Setenv("CERTIFICATE_SIGNING_KEY_FILE", "")
+	if _, err := SigningConfigFromEnv(); err == nil {
+		t.Errorf("Expected an error when the key file is missing, but got nil")
+	}
+}
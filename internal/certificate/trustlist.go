@@ -0,0 +1,112 @@
+package certificate
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"proofpix/internal/models"
+)
+
+// ErrUntrustedIssuer is returned by a Verifier for credentials that are unsigned,
+// or signed by a key its trust list does not contain
+var ErrUntrustedIssuer = errors.New("credential issuer is not trusted")
+
+// TrustedKey is an issuer key a Verifier accepts signatures from
+type TrustedKey struct {
+	// VerificationMethod is the proof verificationMethod the key verifies, such as
+	// did:web:proofpix.com#key-1
+	VerificationMethod string `json:"verificationMethod"`
+	// Issuer, when set, is the only credential issuer the key may sign for
+	Issuer       string `json:"issuer,omitempty"`
+	PublicKeyJWK *JWK   `json:"publicKeyJwk"`
+}
+
+// TrustList is the set of issuer keys trusted for offline verification, stored as
+// JSON: {"keys": [{"verificationMethod": ..., "issuer": ..., "publicKeyJwk": {...}}]}
+type TrustList struct {
+	Keys []TrustedKey `json:"keys"`
+}
+
+// LoadTrustList reads a trust list from a JSON file
+func LoadTrustList(path string) (TrustList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TrustList{}, fmt.Errorf("failed to read trust list: %v", err)
+	}
+	var list TrustList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return TrustList{}, fmt.Errorf("failed to parse trust list %s: %v", path, err)
+	}
+	return list, nil
+}
+
+// trustedKey is a decoded TrustedKey
+type trustedKey struct {
+	issuer    string
+	publicKey crypto.PublicKey
+}
+
+// Verifier checks signed credentials against a static trust list, without any
+// network access: the key is chosen by the proof's verificationMethod, so it works
+// for air-gapped verifiers that cannot resolve DIDs.
+type Verifier struct {
+	keys map[string]trustedKey
+}
+
+// NewVerifier decodes the keys of a trust list. Every key needs a distinct
+// verification method and a supported JWK.
+func NewVerifier(list TrustList) (*Verifier, error) {
+	v := &Verifier{keys: make(map[string]trustedKey, len(list.Keys))}
+	for _, key := range list.Keys {
+		if key.VerificationMethod == "" || key.PublicKeyJWK == nil {
+			return nil, fmt.Errorf("trust list entry %q needs a verificationMethod and publicKeyJwk", key.VerificationMethod)
+		}
+		if _, duplicate := v.keys[key.VerificationMethod]; duplicate {
+			return nil, fmt.Errorf("trust list lists %s more than once", key.VerificationMethod)
+		}
+		publicKey, err := key.PublicKeyJWK.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("trust list entry %s: %v", key.VerificationMethod, err)
+		}
+		v.keys[key.VerificationMethod] = trustedKey{issuer: key.Issuer, publicKey: publicKey}
+	}
+	return v, nil
+}
+
+// Verify checks that the credential is signed by a trusted key of its issuer and,
+// when asset is not nil, that it describes the asset as Verify does
+func (v *Verifier) Verify(credential *VerifiableCredential, asset *models.Asset) error {
+	if credential == nil {
+		return fmt.Errorf("credential is required")
+	}
+	if !IsSigned(credential) {
+		return fmt.Errorf("%w: credential is unsigned", ErrUntrustedIssuer)
+	}
+	key, ok := v.keys[credential.Proof.VerificationMethod]
+	if !ok {
+		return fmt.Errorf("%w: unknown verification method %q", ErrUntrustedIssuer, credential.Proof.VerificationMethod)
+	}
+	if key.issuer != "" && key.issuer != credential.Issuer {
+		return fmt.Errorf("%w: %s does not sign for issuer %q", ErrUntrustedIssuer, credential.Proof.VerificationMethod, credential.Issuer)
+	}
+
+	if asset != nil {
+		return VerifyWithKey(credential, asset, key.publicKey)
+	}
+	if err := VerifySignature(credential, key.publicKey); err != nil {
+		return fmt.Errorf("%w: %v", ErrInconsistentCertificate, err)
+	}
+	return nil
+}
+
+// VerifyJSON parses a stored credential and verifies it as Verify does
+func (v *Verifier) VerifyJSON(data []byte, asset *models.Asset) error {
+	var credential VerifiableCredential
+	if err := json.Unmarshal(data, &credential); err != nil {
+		return fmt.Errorf("%w: failed to parse certificate: %v", ErrInconsistentCertificate, err)
+	}
+	return v.Verify(&credential, asset)
+}
@@ -0,0 +1,120 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+// signedCredential generates a credential for the asset signed with the given key
+func signedCredential(t *testing.T, asset *models.Asset, algorithm string, key interface{}, verificationMethod string) *VerifiableCredential {
+	t.Helper()
+	config, err := NewSigningConfig(algorithm, pkcs8PEM(t, key), verificationMethod)
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+	credential, err := Generate(asset)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if err := config.Sign(credential); err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	return credential
+}
+
+func TestVerifier(t *testing.T) {
+	asset := &models.Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 9, CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)}
+	edPublic, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, rogueKey, _ := ed25519.GenerateKey(rand.Reader)
+	edJWK, _ := publicKeyJWK(edPublic)
+	ecJWK, _ := publicKeyJWK(&ecKey.PublicKey)
+
+	verifier, err := NewVerifier(TrustList{Keys: []TrustedKey{
+		{VerificationMethod: "did:web:proofpix.com#key-1", Issuer: DefaultIssuer, PublicKeyJWK: edJWK},
+		{VerificationMethod: "did:web:proofpix.com#key-2", PublicKeyJWK: ecJWK},
+	}})
+	if err != nil {
+		t.Fatalf("NewVerifier() failed: %v", err)
+	}
+
+	tampered := signedCredential(t, asset, AlgorithmEd25519, edKey, "did:web:proofpix.com#key-1")
+	tampered.CredentialSubject.AuthenticityRating.RatingValue = 10
+	otherIssuer := signedCredential(t, asset, AlgorithmEd25519, edKey, "did:web:proofpix.com#key-1")
+	otherIssuer.Issuer = "did:web:impostor.example"
+	unsigned, _ := Generate(asset)
+
+	testCases := []struct {
+		name          string
+		credential    *VerifiableCredential
+		asset         *models.Asset
+		expectedError error
+	}{
+		{name: "Trusted Ed25519 key", credential: signedCredential(t, asset, AlgorithmEd25519, edKey, "did:web:proofpix.com#key-1"), asset: asset},
+		{name: "Trusted P-256 key", credential: signedCredential(t, asset, AlgorithmECDSAP256, ecKey, "did:web:proofpix.com#key-2"), asset: asset},
+		{name: "Signature only, without the asset", credential: signedCredential(t, asset, AlgorithmEd25519, edKey, "did:web:proofpix.com#key-1")},
+		{name: "Unknown verification method", credential: signedCredential(t, asset, AlgorithmEd25519, rogueKey, "did:web:rogue.example#key-1"), asset: asset, expectedError: ErrUntrustedIssuer},
+		{name: "Untrusted key claiming a trusted method", credential: signedCredential(t, asset, AlgorithmEd25519, rogueKey, "did:web:proofpix.com#key-1"), asset: asset, expectedError: ErrInconsistentCertificate},
+		{name: "Key used for another issuer", credential: otherIssuer, asset: asset, expectedError: ErrUntrustedIssuer},
+		{name: "Unsigned credential", credential: unsigned, asset: asset, expectedError: ErrUntrustedIssuer},
+		{name: "Tampered credential", credential: tampered, expectedError: ErrInconsistentCertificate},
+		{name: "Credential of another asset", credential: signedCredential(t, asset, AlgorithmEd25519, edKey, "did:web:proofpix.com#key-1"), asset: &models.Asset{ID: "asset-2", UserID: "user-1"}, expectedError: ErrInconsistentCertificate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifier.Verify(tc.credential, tc.asset)
+			if tc.expectedError == nil {
+				if err != nil {
+					t.Errorf("Expected the credential to verify, but got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("Expected %v, but got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadTrustList(t *testing.T) {
+	edPublic, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edJWK, _ := publicKeyJWK(edPublic)
+	data, _ := json.Marshal(TrustList{Keys: []TrustedKey{{VerificationMethod: "did:web:proofpix.com#key-1", PublicKeyJWK: edJWK}}})
+	path := filepath.Join(t.TempDir(), "trust.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write trust list: %v", err)
+	}
+
+	list, err := LoadTrustList(path)
+	if err != nil {
+		t.Fatalf("LoadTrustList() failed: %v", err)
+	}
+	verifier, err := NewVerifier(list)
+	if err != nil {
+		t.Fatalf("NewVerifier() failed: %v", err)
+	}
+	credential := signedCredential(t, &models.Asset{ID: "asset-1", UserID: "user-1"}, AlgorithmEd25519, edKey, "did:web:proofpix.com#key-1")
+	stored, _ := json.Marshal(credential)
+	if err := verifier.VerifyJSON(stored, nil); err != nil {
+		t.Errorf("Expected the stored credential to verify, but got %v", err)
+	}
+
+	if _, err := LoadTrustList(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Expected an error for a missing trust list")
+	}
+	duplicate := TrustList{Keys: []TrustedKey{list.Keys[0], list.Keys[0]}}
+	if _, err := NewVerifier(duplicate); err == nil {
+		t.Errorf("Expected an error for a duplicated verification method")
+	}
+}
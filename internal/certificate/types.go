@@ -17,6 +17,17 @@ type CredentialSubject struct {
 	Creator               string            `json:"creator"`
 	AuthenticityRating    AuthenticityRating `json:"authenticityRating"`
 	AuthenticityNarrative string            `json:"authenticityNarrative"`
+	// AuthenticityNarrativeLanguage is the BCP 47 tag of the narrative's language, when recorded
+	AuthenticityNarrativeLanguage string `json:"authenticityNarrativeLanguage,omitempty"`
+	ModelVersion          string            `json:"modelVersion,omitempty"`
+	RelatedAsset          *RelatedAsset     `json:"relatedAsset,omitempty"`
+}
+
+// RelatedAsset identifies an earlier asset the image closely matched when it was
+// certified, documenting that it may be derived from that asset
+type RelatedAsset struct {
+	ID       string  `json:"id"`
+	Distance float32 `json:"distance"`
 }
 
 // AuthenticityRating represents a schema.org-style rating for image authenticity
@@ -27,10 +38,16 @@ type AuthenticityRating struct {
 	WorstRating int    `json:"worstRating"`
 }
 
-// Proof represents cryptographic proof for the verifiable credential
+// Proof represents cryptographic proof for the verifiable credential. Unsigned
+// proofs carry a digest of the asset; signed proofs declare their cryptosuite.
 type Proof struct {
-	Type         string `json:"type"`
-	Created      string `json:"created"`
-	ProofPurpose string `json:"proofPurpose"`
-	ProofValue   string `json:"proofValue"`
+	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite,omitempty"`
+	Created            string `json:"created"`
+	VerificationMethod string `json:"verificationMethod,omitempty"`
+	ProofPurpose       string `json:"proofPurpose"`
+	// ProcessedBy identifies the worker or region that produced the credential,
+	// when CERTIFICATE_INCLUDE_WORKER is set; signatures cover it
+	ProcessedBy string `json:"processedBy,omitempty"`
+	ProofValue  string `json:"proofValue"`
 }
\ No newline at end of file
@@ -0,0 +1,111 @@
+package certificate
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"proofpix/internal/models"
+)
+
+// ErrInconsistentCertificate is returned when a stored credential does not match its asset
+var ErrInconsistentCertificate = errors.New("certificate is inconsistent with asset")
+
+// ErrVerificationKeyRequired is returned, wrapped in ErrInconsistentCertificate, when
+// a signed credential is checked without a key: its proof cannot be recomputed from
+// the asset, so it would otherwise be accepted unchecked
+var ErrVerificationKeyRequired = errors.New("signed credential needs the issuer's key to be verified")
+
+// Verify checks that a stored credential is internally consistent with the asset it
+// describes: the subject and creator are compared, and the proof is checked according
+// to its declared type. Unsigned proofs are recomputed from the asset; signed proofs
+// need the issuer's key, so Verify rejects them (use VerifyWithKey or
+// VerifyWithResolver).
+func Verify(credential *VerifiableCredential, asset *models.Asset) error {
+	return VerifyWithKey(credential, asset, nil)
+}
+
+// VerifyWithKey is Verify with the issuer's public key for signed credentials. A
+// signed credential fails with ErrVerificationKeyRequired when publicKey is nil.
+func VerifyWithKey(credential *VerifiableCredential, asset *models.Asset, publicKey crypto.PublicKey) error {
+	if credential == nil || asset == nil {
+		return fmt.Errorf("credential and asset are required")
+	}
+
+	if IsSigned(credential) {
+		if publicKey == nil {
+			return fmt.Errorf("%w: %w", ErrInconsistentCertificate, ErrVerificationKeyRequired)
+		}
+		if err := VerifySignature(credential, publicKey); err != nil {
+			return fmt.Errorf("%w: %v", ErrInconsistentCertificate, err)
+		}
+	} else if expected := ComputeProofValue(asset); credential.Proof.ProofValue != expected {
+		return fmt.Errorf("%w: proofValue does not match asset", ErrInconsistentCertificate)
+	}
+
+	if expected := fmt.Sprintf("urn:proofpix:asset:%s", asset.ID); credential.CredentialSubject.ID != expected {
+		return fmt.Errorf("%w: subject %q does not match asset", ErrInconsistentCertificate, credential.CredentialSubject.ID)
+	}
+
+	if credential.CredentialSubject.Creator != asset.UserID {
+		return fmt.Errorf("%w: creator %q does not match asset owner", ErrInconsistentCertificate, credential.CredentialSubject.Creator)
+	}
+
+	return nil
+}
+
+// VerifyWithResolver is Verify for credentials whose proof names a DID URL
+// verification method: the issuer's DID document is fetched with resolver to obtain
+// the key, and the signature is checked with it. A DID-issued credential must be
+// signed by a key of its issuer. Anyone can publish a DID document, so when
+// trustedDID is set the key must also belong to that DID, and a credential whose
+// issuer is not a DID (such as DefaultIssuer) is only accepted with a key of
+// trustedDID. Other credentials are checked as by Verify.
+func VerifyWithResolver(ctx context.Context, credential *VerifiableCredential, asset *models.Asset, resolver DIDResolver, trustedDID string) error {
+	if credential == nil || !IsSigned(credential) || !IsDID(credential.Proof.VerificationMethod) {
+		return Verify(credential, asset)
+	}
+
+	did, _ := splitDIDURL(credential.Proof.VerificationMethod)
+	if IsDID(credential.Issuer) && did != credential.Issuer {
+		return fmt.Errorf("%w: verification method %q is not controlled by issuer %q", ErrInconsistentCertificate, credential.Proof.VerificationMethod, credential.Issuer)
+	}
+	if !IsDID(credential.Issuer) && trustedDID == "" {
+		return fmt.Errorf("%w: issuer %q is not a DID and no trusted DID is configured for verification method %q", ErrInconsistentCertificate, credential.Issuer, credential.Proof.VerificationMethod)
+	}
+	if trustedDID != "" && did != trustedDID {
+		return fmt.Errorf("%w: verification method %q does not belong to trusted DID %q", ErrInconsistentCertificate, credential.Proof.VerificationMethod, trustedDID)
+	}
+	publicKey, err := ResolveVerificationKey(ctx, resolver, credential.Proof.VerificationMethod)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInconsistentCertificate, err)
+	}
+	return VerifyWithKey(credential, asset, publicKey)
+}
+
+// VerifyJSON parses a stored credential and verifies it against the asset
+func VerifyJSON(data []byte, asset *models.Asset) error {
+	return VerifyJSONWithKey(data, asset, nil)
+}
+
+// VerifyJSONWithKey parses a stored credential and verifies it against the asset,
+// checking a signed proof with publicKey
+func VerifyJSONWithKey(data []byte, asset *models.Asset, publicKey crypto.PublicKey) error {
+	var credential VerifiableCredential
+	if err := json.Unmarshal(data, &credential); err != nil {
+		return fmt.Errorf("%w: failed to parse certificate: %v", ErrInconsistentCertificate, err)
+	}
+	return VerifyWithKey(&credential, asset, publicKey)
+}
+
+// VerifyJSONWithResolver parses a stored credential and verifies it against the
+// asset, resolving DID verification methods with resolver (see VerifyWithResolver)
+func VerifyJSONWithResolver(ctx context.Context, data []byte, asset *models.Asset, resolver DIDResolver, trustedDID string) error {
+	var credential VerifiableCredential
+	if err := json.Unmarshal(data, &credential); err != nil {
+		return fmt.Errorf("%w: failed to parse certificate: %v", ErrInconsistentCertificate, err)
+	}
+	return VerifyWithResolver(ctx, &credential, asset, resolver, trustedDID)
+}
@@ -0,0 +1,134 @@
+package certificate
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"proofpix/internal/models"
+)
+
+func TestVerifyJSON(t *testing.T) {
+	asset := &models.Asset{
+		ID:               "test-asset-123",
+		UserID:           "user-456",
+		CreatedAt:        time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		OriginalityScore: 8,
+		Narrative:        "High confidence in image authenticity",
+	}
+
+	credential, err := Generate(asset)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	original, err := json.Marshal(credential)
+	if err != nil {
+		t.Fatalf("Failed to marshal credential: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		tamper      func(vc *VerifiableCredential)
+		raw         []byte
+		expectError bool
+	}{
+		{name: "Untouched certificate", tamper: func(vc *VerifiableCredential) {}},
+		{name: "Tampered proofValue", tamper: func(vc *VerifiableCredential) { vc.Proof.ProofValue = "deadbeef" }, expectError: true},
+		{name: "Tampered subject", tamper: func(vc *VerifiableCredential) { vc.CredentialSubject.ID = "urn:proofpix:asset:other" }, expectError: true},
+		{name: "Tampered creator", tamper: func(vc *VerifiableCredential) { vc.CredentialSubject.Creator = "mallory" }, expectError: true},
+		{name: "Corrupted JSON", raw: []byte("{not json"), expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.raw
+			if data == nil {
+				var vc VerifiableCredential
+				if err := json.Unmarshal(original, &vc); err != nil {
+					t.Fatalf("Failed to copy credential: %v", err)
+				}
+				tc.tamper(&vc)
+				data, _ = json.Marshal(vc)
+			}
+
+			err := VerifyJSON(data, asset)
+			if tc.expectError && !errors.Is(err, ErrInconsistentCertificate) {
+				t.Errorf("Expected ErrInconsistentCertificate, but got %v", err)
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyWithKey_SignedCredential(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	config, err := NewSigningConfig(AlgorithmEd25519, pkcs8PEM(t, key), "did:web:proofpix.com#key-1")
+	if err != nil {
+		t.Fatalf("NewSigningConfig() failed: %v", err)
+	}
+	asset := &models.Asset{ID: "asset-1", UserID: "user-1", OriginalityScore: 9, CreatedAt: time.Now()}
+	signed, err := Generate(asset)
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if err := config.Sign(signed); err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	// A forged proof only needs to declare a cryptosuite to look signed
+	forged, _ := Generate(asset)
+	forged.Proof.Cryptosuite = CryptosuiteEdDSA
+	forged.Proof.ProofValue = "uZm9yZ2Vk"
+
+	testCases := []struct {
+		name        string
+		credential  *VerifiableCredential
+		withKey     bool
+		expectError bool
+	}{
+		{name: "Signed with the key", credential: signed, withKey: true},
+		{name: "Signed without a key", credential: signed, expectError: true},
+		{name: "Forged with the key", credential: forged, withKey: true, expectError: true},
+		{name: "Forged without a key", credential: forged, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, _ := json.Marshal(tc.credential)
+			var publicKey crypto.PublicKey
+			if tc.withKey {
+				publicKey = config.PublicKey()
+			}
+
+			err := VerifyJSONWithKey(data, asset, publicKey)
+			if tc.expectError && !errors.Is(err, ErrInconsistentCertificate) {
+				t.Errorf("Expected ErrInconsistentCertificate, but got %v", err)
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if !tc.withKey && !errors.Is(err, ErrVerificationKeyRequired) {
+				t.Errorf("Expected ErrVerificationKeyRequired without a key, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestComputeProofValue_TimezoneIndependent(t *testing.T) {
+	created := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	utcAsset := &models.Asset{ID: "asset-1", CreatedAt: created}
+	localAsset := &models.Asset{ID: "asset-1", CreatedAt: created.In(time.FixedZone("EST", -5*3600))}
+
+	if ComputeProofValue(utcAsset) != ComputeProofValue(localAsset) {
+		t.Errorf("Expected proof value to be independent of time zone")
+	}
+}
@@ -0,0 +1,22 @@
+package certificate
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// IncludeWorkerFromEnv reports whether CERTIFICATE_INCLUDE_WORKER is set, recording
+// the worker that processed an asset in its credential's proof
+func IncludeWorkerFromEnv() bool {
+	value := os.Getenv("CERTIFICATE_INCLUDE_WORKER")
+	if value == "" {
+		return false
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid CERTIFICATE_INCLUDE_WORKER %q, using default of false", value)
+		return false
+	}
+	return include
+}
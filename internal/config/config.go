@@ -0,0 +1,244 @@
+// Package config reads and validates the service configuration once at startup,
+// so missing or malformed environment variables stop a deployment immediately
+// instead of failing the first request that needs them.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"proofpix/internal/certificate"
+	"proofpix/internal/leaf"
+)
+
+// Service names the binary being configured, which decides what is required
+type Service string
+
+const (
+	API    Service = "api"
+	Worker Service = "worker"
+)
+
+// DefaultPort is the HTTP port used when PORT is not set
+const DefaultPort = "8080"
+
+// Config is the validated configuration shared by the API and the worker. Tunables
+// not listed here are still read by the packages that use them, but Load checks
+// that they parse.
+type Config struct {
+	Port string
+	// ProjectID is the Google Cloud project holding Firestore, GCS and Vertex AI
+	ProjectID string
+	// FirebaseProjectID is FIREBASE_PROJECT_ID, falling back to PROJECT_ID (API only)
+	FirebaseProjectID string
+	// UploadBucket is the GCS bucket that receives uploads (API only)
+	UploadBucket string
+	// TrillianLogID and TrillianLogServerAddr are both set or both empty; without
+	// them certificates are not anchored
+	TrillianLogID         int64
+	TrillianLogServerAddr string
+	TrillianLeafFormat    string
+	// TrillianHashAlgorithm is the HashAlgorithm of the Trillian tree
+	TrillianHashAlgorithm string
+}
+
+// TrillianConfigured reports whether a Trillian log is configured
+func (c *Config) TrillianConfigured() bool {
+	return c.TrillianLogID != 0 && c.TrillianLogServerAddr != ""
+}
+
+// ValidationError lists every missing or invalid variable found by Load
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Value kinds checked for optional tunables
+type kind int
+
+const (
+	positiveInt kind = iota
+	nonNegativeInt
+	nonNegativeFloat
+	positiveDuration
+	boolean
+)
+
+// tunables are optional variables read elsewhere with a default. Load rejects
+// values those readers would otherwise ignore with a warning.
+var tunables = map[Service][]struct {
+	name string
+	kind kind
+}{
+	API: {
+		{"ASSET_QUOTA_PER_USER", nonNegativeInt},
+		{"ASSET_RETENTION_DAYS", nonNegativeInt},
+		{"ASSET_STREAM_TIMEOUT", positiveDuration},
+		{"BADGE_CACHE_MAX_AGE", nonNegativeInt},
+		{"CERTIFICATE_INCLUDE_WORKER", boolean},
+		{"MAX_REQUEST_BODY_BYTES", positiveInt},
+		{"READ_ONLY", boolean},
+		{"REQUEUE_CONCURRENCY", positiveInt},
+		{"TRILLIAN_INTEGRATION_INTERVAL", positiveDuration},
+		{"VERIFY_CACHE_FAILED_TTL", positiveDuration},
+		{"VERIFY_CACHE_MAX_ENTRIES", nonNegativeInt},
+		{"VERIFY_CACHE_TTL", positiveDuration},
+	},
+	Worker: {
+		{"ANALYSIS_MONTHLY_BUDGET", nonNegativeInt},
+		{"ANCHOR_MIN_SCORE", nonNegativeInt},
+		{"ASSET_RETENTION_DAYS", nonNegativeInt},
+		{"BADGE_SIGNING", boolean},
+		{"CERTIFICATE_INCLUDE_WORKER", boolean},
+		{"DUPLICATE_DISTANCE_THRESHOLD", nonNegativeFloat},
+		{"DUPLICATE_SEARCH_K", nonNegativeInt},
+		{"EMBEDDING_REVERIFY_TOLERANCE", nonNegativeFloat},
+		{"FIRESTORE_WRITE_MAX_ATTEMPTS", positiveInt},
+		{"GCS_UPLOAD_MAX_ATTEMPTS", positiveInt},
+		{"IMAGE_URL_MAX_BYTES", positiveInt},
+		{"IMAGE_URL_TIMEOUT", positiveDuration},
+		{"INDEX_BUILD_BATCH_SIZE", positiveInt},
+		{"INDEX_BUILD_TIMEOUT", positiveDuration},
+		{"INDEX_LOAD_TIMEOUT", positiveDuration},
+		{"INDEX_SAVE_TIMEOUT", positiveDuration},
+		{"INDEX_SNAPSHOTS_TO_KEEP", positiveInt},
+		{"MAX_REQUEST_BODY_BYTES", positiveInt},
+		{"SEARCH_DEFAULT_K", positiveInt},
+		{"SEARCH_MAX_CONCURRENT", nonNegativeInt},
+		{"SEARCH_MAX_K", positiveInt},
+		{"SEARCH_RECENCY_HALF_LIFE", positiveDuration},
+		{"SYNC_INCLUSION_TIMEOUT", positiveDuration},
+		{"THUMBNAIL_MAX_DIMENSION", positiveInt},
+		{"TRILLIAN_BATCH_INTERVAL", positiveDuration},
+		{"TRILLIAN_BATCH_SIZE", positiveInt},
+		{"VERTEX_HTTP_TIMEOUT", positiveDuration},
+		{"VIDEO_KEYFRAME_INTERVAL", positiveDuration},
+		{"VIDEO_MAX_DURATION", positiveDuration},
+		{"VIDEO_MAX_FRAMES", positiveInt},
+	},
+}
+
+// Load reads the configuration of the given service from the environment. It
+// returns a *ValidationError naming every problem at once rather than the first.
+func Load(service Service) (*Config, error) {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	cfg := &Config{
+		Port:                  os.Getenv("PORT"),
+		ProjectID:             os.Getenv("GOOGLE_CLOUD_PROJECT"),
+		TrillianLogServerAddr: os.Getenv("TRILLIAN_LOG_SERVER_ADDR"),
+	}
+
+	if cfg.Port == "" {
+		cfg.Port = DefaultPort
+	} else if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		problem("PORT %q is not a valid port", cfg.Port)
+	}
+
+	if cfg.ProjectID == "" {
+		problem("GOOGLE_CLOUD_PROJECT is required")
+	}
+
+	if value := os.Getenv("TRILLIAN_LOG_ID"); value != "" {
+		logID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || logID <= 0 {
+			problem("TRILLIAN_LOG_ID %q is not a positive integer", value)
+		}
+		cfg.TrillianLogID = logID
+		if cfg.TrillianLogServerAddr == "" {
+			problem("TRILLIAN_LOG_SERVER_ADDR is required when TRILLIAN_LOG_ID is set")
+		}
+	} else if cfg.TrillianLogServerAddr != "" {
+		problem("TRILLIAN_LOG_ID is required when TRILLIAN_LOG_SERVER_ADDR is set")
+	}
+
+	format, err := leaf.FormatFromEnv()
+	if err != nil {
+		problem("%v", err)
+	}
+	cfg.TrillianLeafFormat = format
+
+	hasher, err := leaf.HasherFromEnv()
+	if err != nil {
+		problem("%v", err)
+	}
+	cfg.TrillianHashAlgorithm = hasher.Algorithm()
+
+	if service == API {
+		cfg.FirebaseProjectID = os.Getenv("FIREBASE_PROJECT_ID")
+		if cfg.FirebaseProjectID == "" {
+			cfg.FirebaseProjectID = os.Getenv("PROJECT_ID")
+		}
+		if cfg.FirebaseProjectID == "" {
+			problem("FIREBASE_PROJECT_ID or PROJECT_ID is required")
+		}
+		cfg.UploadBucket = os.Getenv("GCS_BUCKET_NAME")
+		if cfg.UploadBucket == "" {
+			problem("GCS_BUCKET_NAME is required")
+		}
+	}
+
+	// The worker issues credentials; the API re-signs them on regeneration
+	if spec := os.Getenv("CERTIFICATE_RATING_THRESHOLDS"); strings.TrimSpace(spec) != "" {
+		if _, err := certificate.ParseRatingMapping(spec); err != nil {
+			problem("CERTIFICATE_RATING_THRESHOLDS %q is invalid: %v", spec, err)
+		}
+	}
+
+	if service == Worker {
+		for _, kind := range []string{certificate.CertificateObjects, certificate.BadgeObjects} {
+			if _, err := certificate.ObjectMetadataFromEnv(kind); err != nil {
+				problem("%v", err)
+			}
+		}
+	}
+
+	for _, tunable := range tunables[service] {
+		if value := os.Getenv(tunable.name); value != "" {
+			if msg := checkValue(value, tunable.kind); msg != "" {
+				problem("%s %q %s", tunable.name, value, msg)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// checkValue returns why value is not of the given kind, or "" when it is
+func checkValue(value string, k kind) string {
+	switch k {
+	case positiveInt:
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return "is not a positive integer"
+		}
+	case nonNegativeInt:
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return "is not a non-negative integer"
+		}
+	case nonNegativeFloat:
+		if f, err := strconv.ParseFloat(value, 32); err != nil || f < 0 {
+			return "is not a non-negative number"
+		}
+	case positiveDuration:
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return "is not a positive duration such as 30s"
+		}
+	case boolean:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "is not true or false"
+		}
+	}
+	return ""
+}
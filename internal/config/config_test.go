@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// clearEnv unsets every variable Load reads for the duration of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"PORT", "GOOGLE_CLOUD_PROJECT", "FIREBASE_PROJECT_ID", "PROJECT_ID", "GCS_BUCKET_NAME",
+		"TRILLIAN_LOG_ID", "TRILLIAN_LOG_SERVER_ADDR", "TRILLIAN_LEAF_FORMAT", "TRILLIAN_HASH_ALGORITHM", "CERTIFICATE_RATING_THRESHOLDS",
+		"CERTIFICATE_OBJECT_CACHE_CONTROL", "CERTIFICATE_OBJECT_STORAGE_CLASS", "BADGE_OBJECT_CACHE_CONTROL", "BADGE_OBJECT_STORAGE_CLASS"} {
+		t.Setenv(name, "")
+	}
+	for _, service := range tunables {
+		for _, tunable := range service {
+			t.Setenv(tunable.name, "")
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name             string
+		service          Service
+		env              map[string]string
+		expected         *Config
+		expectedProblems []string
+	}{
+		{
+			name:    "Valid API configuration",
+			service: API,
+			env: map[string]string{
+				"GOOGLE_CLOUD_PROJECT":     "proofpix",
+				"PROJECT_ID":               "proofpix-auth",
+				"GCS_BUCKET_NAME":          "proofpix-uploads",
+				"TRILLIAN_LOG_ID":          "42",
+				"TRILLIAN_LOG_SERVER_ADDR": "trillian:8090",
+				"TRILLIAN_LEAF_FORMAT":     "certificate",
+				"TRILLIAN_HASH_ALGORITHM":  "sha-384",
+				"READ_ONLY":                "true",
+			},
+			expected: &Config{
+				Port:                  DefaultPort,
+				ProjectID:             "proofpix",
+				FirebaseProjectID:     "proofpix-auth",
+				UploadBucket:          "proofpix-uploads",
+				TrillianLogID:         42,
+				TrillianLogServerAddr: "trillian:8090",
+				TrillianLeafFormat:    "certificate",
+				TrillianHashAlgorithm: "SHA384",
+			},
+		},
+		{
+			name:    "Valid worker configuration without Trillian",
+			service: Worker,
+			env: map[string]string{
+				"PORT":                 "9090",
+				"GOOGLE_CLOUD_PROJECT": "proofpix",
+				"TRILLIAN_BATCH_SIZE":  "50",
+				"VERTEX_HTTP_TIMEOUT":  "90s",
+			},
+			expected: &Config{Port: "9090", ProjectID: "proofpix", TrillianLeafFormat: "hash", TrillianHashAlgorithm: "SHA256"},
+		},
+		{
+			name:    "FIREBASE_PROJECT_ID takes precedence over PROJECT_ID",
+			service: API,
+			env: map[string]string{
+				"GOOGLE_CLOUD_PROJECT": "proofpix",
+				"FIREBASE_PROJECT_ID":  "firebase",
+				"PROJECT_ID":           "terraform",
+				"GCS_BUCKET_NAME":      "proofpix-uploads",
+			},
+			expected: &Config{Port: DefaultPort, ProjectID: "proofpix", FirebaseProjectID: "firebase", UploadBucket: "proofpix-uploads", TrillianLeafFormat: "hash", TrillianHashAlgorithm: "SHA256"},
+		},
+		{
+			name:    "Missing API values are all reported",
+			service: API,
+			env:     map[string]string{},
+			expectedProblems: []string{
+				"GOOGLE_CLOUD_PROJECT is required",
+				"FIREBASE_PROJECT_ID or PROJECT_ID is required",
+				"GCS_BUCKET_NAME is required",
+			},
+		},
+		{
+			name:    "API-only values are not required by the worker",
+			service: Worker,
+			env:     map[string]string{},
+			expectedProblems: []string{
+				"GOOGLE_CLOUD_PROJECT is required",
+			},
+		},
+		{
+			name:    "Invalid values",
+			service: Worker,
+			env: map[string]string{
+				"PORT":                          "http",
+				"GOOGLE_CLOUD_PROJECT":          "proofpix",
+				"TRILLIAN_LOG_ID":               "-3",
+				"TRILLIAN_LEAF_FORMAT":          "merkle",
+				"TRILLIAN_HASH_ALGORITHM":       "MD5",
+				"TRILLIAN_BATCH_SIZE":           "0",
+				"VERTEX_HTTP_TIMEOUT":           "90",
+				"CERTIFICATE_RATING_THRESHOLDS": "10:5",
+				"BADGE_OBJECT_STORAGE_CLASS":    "GLACIER",
+			},
+			expectedProblems: []string{
+				`PORT "http" is not a valid port`,
+				`TRILLIAN_LOG_ID "-3" is not a positive integer`,
+				"TRILLIAN_LOG_SERVER_ADDR is required when TRILLIAN_LOG_ID is set",
+				`unknown TRILLIAN_LEAF_FORMAT "merkle": expected hash or certificate`,
+				`invalid TRILLIAN_HASH_ALGORITHM: unknown hash algorithm "MD5": expected SHA256, SHA384 or SHA512`,
+				`CERTIFICATE_RATING_THRESHOLDS "10:5" is invalid: first threshold must start at score 0 so every score has a rating`,
+				`invalid BADGE_OBJECT_STORAGE_CLASS "GLACIER": expected STANDARD, NEARLINE, COLDLINE or ARCHIVE`,
+				`TRILLIAN_BATCH_SIZE "0" is not a positive integer`,
+				`VERTEX_HTTP_TIMEOUT "90" is not a positive duration such as 30s`,
+			},
+		},
+		{
+			name:    "Log server without a log ID",
+			service: Worker,
+			env: map[string]string{
+				"GOOGLE_CLOUD_PROJECT":     "proofpix",
+				"TRILLIAN_LOG_SERVER_ADDR": "trillian:8090",
+			},
+			expectedProblems: []string{"TRILLIAN_LOG_ID is required when TRILLIAN_LOG_SERVER_ADDR is set"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load(tc.service)
+			if tc.expectedProblems == nil {
+				if err != nil {
+					t.Fatalf("Load() failed: %v", err)
+				}
+				if !reflect.DeepEqual(cfg, tc.expected) {
+					t.Errorf("Expected %+v, but got %+v", tc.expected, cfg)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a ValidationError, but got %v", err)
+			}
+			if !reflect.DeepEqual(validationErr.Problems, tc.expectedProblems) {
+				t.Errorf("Expected problems %q, but got %q", tc.expectedProblems, validationErr.Problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Package float16 converts vectors to and from IEEE 754 half precision, used to
+// store embeddings in about half the space of float32
+package float16
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// FromFloat32 rounds f to the nearest half-precision value, ties to even. Values
+// beyond the half-precision range become infinities and values too small for it
+// become signed zeros.
+func FromFloat32(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	if exp == 0xff {
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+
+	// Rebias the exponent from float32's 127 to half precision's 15
+	e := exp - 127 + 15
+	if e >= 0x1f {
+		return sign | 0x7c00
+	}
+	if e <= 0 {
+		// Subnormal in half precision: shift the mantissa, with its implicit bit,
+		// into units of 2^-24
+		shift := uint32(14 - e)
+		if shift > 24 {
+			return sign
+		}
+		full := mant | 0x800000
+		half := full >> shift
+		rem, midpoint := full&(1<<shift-1), uint32(1)<<(shift-1)
+		if rem > midpoint || rem == midpoint && half&1 == 1 {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	// A carry out of the mantissa correctly moves to the next exponent
+	h := sign | uint16(e)<<10 | uint16(mant>>13)
+	rem := mant & 0x1fff
+	if rem > 0x1000 || rem == 0x1000 && h&1 == 1 {
+		h++
+	}
+	return h
+}
+
+// ToFloat32 returns the float32 equal to the half-precision value h
+func ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		// Zero or subnormal, a multiple of 2^-24
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp-15+127)<<23 | mant<<13)
+}
+
+// Encode packs a vector as little-endian half-precision values, two bytes each
+func Encode(vector []float32) []byte {
+	raw := make([]byte, len(vector)*2)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint16(raw[i*2:], FromFloat32(v))
+	}
+	return raw
+}
+
+// Decode unpacks little-endian half-precision values written by Encode
+func Decode(raw []byte) ([]float32, error) {
+	if len(raw)%2 != 0 {
+		return nil, fmt.Errorf("float16 byte length %d is not a multiple of 2", len(raw))
+	}
+	vector := make([]float32, len(raw)/2)
+	for i := range vector {
+		vector[i] = ToFloat32(binary.LittleEndian.Uint16(raw[i*2:]))
+	}
+	return vector, nil
+}
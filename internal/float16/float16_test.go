@@ -0,0 +1,87 @@
+package float16
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFromFloat32(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    float32
+		expected uint16
+	}{
+		{name: "Zero", value: 0, expected: 0x0000},
+		{name: "Negative zero", value: float32(math.Copysign(0, -1)), expected: 0x8000},
+		{name: "One", value: 1, expected: 0x3c00},
+		{name: "Negative two", value: -2, expected: 0xc000},
+		{name: "Largest half", value: 65504, expected: 0x7bff},
+		{name: "Overflow", value: 1e6, expected: 0x7c00},
+		{name: "Negative infinity", value: float32(math.Inf(-1)), expected: 0xfc00},
+		{name: "Smallest normal", value: 1.0 / (1 << 14), expected: 0x0400},
+		{name: "Smallest subnormal", value: 1.0 / (1 << 24), expected: 0x0001},
+		{name: "Underflow", value: 1e-9, expected: 0x0000},
+		{name: "Tie rounds to even", value: 1 + 1.0/(1<<11), expected: 0x3c00},
+		{name: "Tie rounds up to even", value: 1 + 3.0/(1<<11), expected: 0x3c02},
+		{name: "Subnormal tie rounds to even", value: 1.5 / (1 << 24), expected: 0x0002},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FromFloat32(tc.value); got != tc.expected {
+				t.Errorf("Expected %#04x, but got %#04x", tc.expected, got)
+			}
+		})
+	}
+
+	if got := ToFloat32(FromFloat32(float32(math.NaN()))); !math.IsNaN(float64(got)) {
+		t.Errorf("Expected NaN to survive a round trip, but got %v", got)
+	}
+}
+
+func TestRoundTrip_UnitVector(t *testing.T) {
+	// A unit-length vector the size of an embedding, as the worker stores them
+	rng := rand.New(rand.NewSource(1))
+	vector := make([]float32, 1408)
+	var norm float64
+	for i := range vector {
+		vector[i] = float32(rng.NormFloat64())
+		norm += float64(vector[i]) * float64(vector[i])
+	}
+	for i := range vector {
+		vector[i] /= float32(math.Sqrt(norm))
+	}
+
+	raw := Encode(vector)
+	if len(raw) != len(vector)*2 {
+		t.Fatalf("Expected %d bytes, but got %d", len(vector)*2, len(raw))
+	}
+	decoded, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	if len(decoded) != len(vector) {
+		t.Fatalf("Expected %d values, but got %d", len(vector), len(decoded))
+	}
+
+	// Each value is within half a unit in the last place: 2^-11 relative for
+	// normal values, 2^-25 absolute for subnormal ones
+	var distance float64
+	for i, v := range vector {
+		diff := math.Abs(float64(decoded[i] - v))
+		if bound := math.Max(math.Abs(float64(v))/(1<<11), 1.0/(1<<25)); diff > bound {
+			t.Errorf("Expected value %d within %g of %v, but got %v", i, bound, v, decoded[i])
+		}
+		distance += diff * diff
+	}
+	if distance = math.Sqrt(distance); distance > 1e-3 {
+		t.Errorf("Expected the decoded vector within 1e-3 of the original, but the distance is %g", distance)
+	}
+}
+
+func TestDecode_OddLength(t *testing.T) {
+	if _, err := Decode([]byte{0, 0x3c, 0}); err == nil {
+		t.Error("Expected an error for an odd byte length")
+	}
+}
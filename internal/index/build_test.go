@@ -0,0 +1,125 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/DataIntelligenceCrew/go-faiss"
+	"google.golang.org/api/iterator"
+)
+
+// syntheticDocuments returns a document source over count distinct embeddings
+func syntheticDocuments(count int) func() (string, map[string]interface{}, error) {
+	docs := make([]map[string]interface{}, count)
+	for i := range docs {
+		vector := make(firestore.Vector32, EmbeddingDimension)
+		vector[i%EmbeddingDimension] = float32(1 + i/EmbeddingDimension)
+		docs[i] = map[string]interface{}{"status": "completed", "embedding": vector}
+	}
+	next := 0
+	return func() (string, map[string]interface{}, error) {
+		if next == len(docs) {
+			return "", nil, iterator.Done
+		}
+		next++
+		return fmt.Sprintf("asset-%d", next-1), docs[next-1], nil
+	}
+}
+
+func TestBuild_AddsInBoundedBatches(t *testing.T) {
+	const count, batchSize = 2000, 128
+	t.Setenv("INDEX_BUILD_BATCH_SIZE", fmt.Sprint(batchSize))
+	t.Setenv("INDEX_METRIC", "")
+
+	// Batches are only recorded, so the allocations measured are the build's own
+	// rather than the index storage, which FAISS keeps outside the Go heap
+	var batches, largest int
+	original := addBatch
+	addBatch = func(_ faiss.Index, vectors []float32) error {
+		batches++
+		if len(vectors) > largest {
+			largest = len(vectors)
+		}
+		return nil
+	}
+	t.Cleanup(func() { addBatch = original })
+
+	m := newTestManager(t, EmbeddingDimension)
+	var initial, before, after, released runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&initial)
+	next := syntheticDocuments(count)
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	if err := m.buildFrom(context.Background(), next); err != nil {
+		t.Fatalf("buildFrom() failed: %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	// Once the documents are released, the built manager must not hold on to them
+	next = nil
+	runtime.GC()
+	runtime.ReadMemStats(&released)
+	runtime.KeepAlive(m)
+
+	if expected := (count + batchSize - 1) / batchSize; batches != expected {
+		t.Errorf("Expected %d batches, but got %d", expected, batches)
+	}
+	if largest > batchSize*EmbeddingDimension {
+		t.Errorf("Expected batches of at most %d values, but got %d", batchSize*EmbeddingDimension, largest)
+	}
+	// Copying the whole collection into one flat slice would allocate all of it again
+	collection := uint64(count * EmbeddingDimension * 4)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated >= collection/2 {
+		t.Errorf("Expected the build to allocate well under the %d byte collection, but it allocated %d bytes", collection, allocated)
+	}
+	if retained := int64(released.HeapAlloc) - int64(initial.HeapAlloc); retained >= int64(collection/2) {
+		t.Errorf("Expected the built index to retain well under the %d byte collection, but it retained %d bytes", collection, retained)
+	}
+}
+
+func TestBuild_IndexesEveryBatch(t *testing.T) {
+	const count, batchSize = 300, 128
+	t.Setenv("INDEX_BUILD_BATCH_SIZE", fmt.Sprint(batchSize))
+	t.Setenv("INDEX_METRIC", "")
+
+	m := newTestManager(t, EmbeddingDimension)
+	if err := m.buildFrom(context.Background(), syntheticDocuments(count)); err != nil {
+		t.Fatalf("buildFrom() failed: %v", err)
+	}
+
+	if total := m.index.Ntotal(); total != count {
+		t.Fatalf("Expected %d indexed vectors, but got %d", count, total)
+	}
+	// The first and last vector of each batch, including the partial last batch
+	for _, i := range []int{0, batchSize - 1, batchSize, 2*batchSize - 1, 2 * batchSize, count - 1} {
+		query := make([]float32, EmbeddingDimension)
+		query[i%EmbeddingDimension] = float32(1 + i/EmbeddingDimension)
+		_, assetIDs, err := m.Search(query, 1)
+		if err != nil {
+			t.Fatalf("Search() failed: %v", err)
+		}
+		if expected := fmt.Sprintf("asset-%d", i); len(assetIDs) != 1 || assetIDs[0] != expected {
+			t.Errorf("Expected %s to match itself, but got %v", expected, assetIDs)
+		}
+	}
+}
+
+func TestBuild_FailedBatchLeavesIndexUnchanged(t *testing.T) {
+	t.Setenv("INDEX_BUILD_BATCH_SIZE", "10")
+	original := addBatch
+	addBatch = func(faiss.Index, []float32) error { return fmt.Errorf("out of memory") }
+	t.Cleanup(func() { addBatch = original })
+
+	m := newTestManager(t, EmbeddingDimension)
+	existing := m.index
+	if err := m.buildFrom(context.Background(), syntheticDocuments(25)); err == nil {
+		t.Fatal("Expected an error when a batch cannot be added, but got nil")
+	}
+	if m.index != existing {
+		t.Error("Expected a failed build to keep the previous index")
+	}
+}
@@ -0,0 +1,49 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDimensionMismatch is returned for a remote vector whose size differs from the index
+var ErrDimensionMismatch = errors.New("vector dimension does not match the index")
+
+// ApplyDelta adds a vector another worker indexed, so every instance can find assets
+// whichever one processed them. It is idempotent: a delta for an asset already
+// indexed with the same vector, including this instance's own add echoed back, is
+// ignored, and applied reports whether the index changed. A different vector for a
+// known asset is added like a reprocessed asset's, and search keeps its nearest.
+func (m *IndexManager) ApplyDelta(assetID string, vector []float32) (applied bool, err error) {
+	if assetID == "" {
+		return false, errors.New("asset ID is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.index == nil {
+		return false, errors.New("index is not initialized")
+	}
+	if dimension := m.index.D(); len(vector) != dimension {
+		return false, fmt.Errorf("%w: got %d values, the index has %d", ErrDimensionMismatch, len(vector), dimension)
+	}
+	if existing, known := m.vectors[assetID]; known && equalVectors(existing, vector) {
+		return false, nil
+	}
+	if err := m.addLocked(assetID, vector); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func equalVectors(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
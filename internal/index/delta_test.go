@@ -0,0 +1,48 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestApplyDelta(t *testing.T) {
+	m := newTestManager(t, 3)
+	if err := m.Add("local", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	// A vector indexed by another worker becomes searchable here
+	applied, err := m.ApplyDelta("remote", []float32{0, 1, 0})
+	if err != nil || !applied {
+		t.Fatalf("Expected the delta to be applied, but got %t (err %v)", applied, err)
+	}
+	_, assetIDs, err := m.Search([]float32{0, 0.9, 0.1}, 1)
+	if err != nil || len(assetIDs) != 1 || assetIDs[0] != "remote" {
+		t.Errorf("Expected the remote asset as the nearest result, but got %v (err %v)", assetIDs, err)
+	}
+	if _, ids, err := m.SearchByAssetID(context.Background(), "remote", 1); err != nil || len(ids) != 1 || ids[0] != "remote" {
+		t.Errorf("Expected the remote asset to be searchable by ID, but got %v (err %v)", ids, err)
+	}
+
+	// The same delta again, or an echo of a local add, changes nothing
+	for _, id := range []string{"remote", "local"} {
+		vector := []float32{0, 1, 0}
+		if id == "local" {
+			vector = []float32{1, 0, 0}
+		}
+		if applied, err := m.ApplyDelta(id, vector); err != nil || applied {
+			t.Errorf("Expected a repeated delta for %s to be ignored, but got %t (err %v)", id, applied, err)
+		}
+	}
+	if total := m.index.Ntotal(); total != 2 {
+		t.Errorf("Expected 2 vectors in the index, but got %d", total)
+	}
+
+	if _, err := m.ApplyDelta("short", []float32{1, 0}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, but got %v", err)
+	}
+	if _, err := (&IndexManager{}).ApplyDelta("remote", []float32{0, 1, 0}); err == nil {
+		t.Errorf("Expected an error without an index")
+	}
+}
@@ -0,0 +1,120 @@
+package index
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"cloud.google.com/go/firestore"
+
+	"proofpix/internal/float16"
+)
+
+// EncodingBase64 selects embeddings stored as base64 strings of little-endian float32 values
+const EncodingBase64 = "base64"
+
+// errMissingEmbedding is returned when a document has no embedding to index
+var errMissingEmbedding = errors.New("embedding field is missing")
+
+// embeddingEncoding returns the alternate embedding encoding configured in EMBEDDING_ENCODING.
+// An empty value means only native Firestore arrays and vectors are accepted.
+func embeddingEncoding() string {
+	return os.Getenv("EMBEDDING_ENCODING")
+}
+
+// documentEmbedding returns the embedding of an asset document. One stored at half
+// precision in embedding_f16 is dequantized whatever EMBEDDING_ENCODING is.
+func documentEmbedding(data map[string]interface{}, encoding string, dimension int) ([]float32, error) {
+	if data["embedding"] == nil && data["embedding_f16"] != nil {
+		raw, ok := data["embedding_f16"].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("embedding_f16 has unsupported type %T", data["embedding_f16"])
+		}
+		vector, err := float16.Decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		return checkDimension(vector, dimension)
+	}
+	return decodeEmbedding(data["embedding"], encoding, dimension)
+}
+
+// decodeEmbedding converts a Firestore embedding value into a vector of the given dimension.
+// Arrays of numbers and Firestore vectors are always accepted; base64 strings and raw bytes
+// are accepted when encoding is EncodingBase64.
+func decodeEmbedding(value interface{}, encoding string, dimension int) ([]float32, error) {
+	var vector []float32
+
+	switch v := value.(type) {
+	case nil:
+		return nil, errMissingEmbedding
+	case []interface{}:
+		vector = make([]float32, len(v))
+		for i, element := range v {
+			switch n := element.(type) {
+			case float64:
+				vector[i] = float32(n)
+			case int64:
+				// Firestore returns whole numbers as integers
+				vector[i] = float32(n)
+			default:
+				return nil, fmt.Errorf("element %d has type %T, expected a number", i, element)
+			}
+		}
+	case firestore.Vector32:
+		vector = []float32(v)
+	case firestore.Vector64:
+		vector = make([]float32, len(v))
+		for i, n := range v {
+			vector[i] = float32(n)
+		}
+	case string:
+		if encoding != EncodingBase64 {
+			return nil, fmt.Errorf("embedding is a string but EMBEDDING_ENCODING is not %q", EncodingBase64)
+		}
+		raw, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 embedding: %v", err)
+		}
+		if vector, err = decodeFloat32s(raw); err != nil {
+			return nil, err
+		}
+	case []byte:
+		if encoding != EncodingBase64 {
+			return nil, fmt.Errorf("embedding is bytes but EMBEDDING_ENCODING is not %q", EncodingBase64)
+		}
+		var err error
+		if vector, err = decodeFloat32s(v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("embedding has unsupported type %T", value)
+	}
+	return checkDimension(vector, dimension)
+}
+
+// checkDimension returns vector if it has the given dimension
+func checkDimension(vector []float32, dimension int) ([]float32, error) {
+	if len(vector) == 0 {
+		return nil, errMissingEmbedding
+	}
+	if len(vector) != dimension {
+		return nil, fmt.Errorf("embedding has %d dimensions, expected %d", len(vector), dimension)
+	}
+	return vector, nil
+}
+
+// decodeFloat32s interprets raw bytes as little-endian float32 values
+func decodeFloat32s(raw []byte) ([]float32, error) {
+	if len(raw)%4 != 0 {
+		return nil, fmt.Errorf("embedding byte length %d is not a multiple of 4", len(raw))
+	}
+	vector := make([]float32, len(raw)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return vector, nil
+}
@@ -0,0 +1,128 @@
+package index
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+
+	"proofpix/internal/float16"
+)
+
+// encodeFloat32s packs a vector as little-endian float32 bytes
+func encodeFloat32s(vector []float32) []byte {
+	raw := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(v))
+	}
+	return raw
+}
+
+func TestDecodeEmbedding(t *testing.T) {
+	expected := []float32{0.5, 1, -2}
+	encoded := base64.StdEncoding.EncodeToString(encodeFloat32s(expected))
+
+	testCases := []struct {
+		name          string
+		value         interface{}
+		encoding      string
+		expectMissing bool
+		expectError   bool
+	}{
+		// Correctly typed
+		{name: "Array of floats", value: []interface{}{0.5, 1.0, -2.0}},
+		{name: "Array with whole numbers", value: []interface{}{0.5, int64(1), int64(-2)}},
+		{name: "Vector32", value: firestore.Vector32{0.5, 1, -2}},
+		{name: "Vector64", value: firestore.Vector64{0.5, 1, -2}},
+		{name: "Base64 string with encoding", value: encoded, encoding: EncodingBase64},
+		{name: "Raw bytes with encoding", value: encodeFloat32s(expected), encoding: EncodingBase64},
+
+		// Wrongly typed
+		{name: "Base64 string without encoding", value: encoded, expectError: true},
+		{name: "GCS reference", value: "gs://proofpix-embeddings/asset-1.bin", encoding: EncodingBase64, expectError: true},
+		{name: "Map", value: map[string]interface{}{"uri": "gs://x"}, expectError: true},
+		{name: "Array of strings", value: []interface{}{"0.5", "1", "-2"}, expectError: true},
+		{name: "Wrong dimension", value: []interface{}{0.5, 1.0}, expectError: true},
+
+		// Missing
+		{name: "Nil", value: nil, expectMissing: true},
+		{name: "Empty array", value: []interface{}{}, expectMissing: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vector, err := decodeEmbedding(tc.value, tc.encoding, len(expected))
+
+			switch {
+			case tc.expectMissing:
+				if !errors.Is(err, errMissingEmbedding) {
+					t.Errorf("Expected errMissingEmbedding, but got %v", err)
+				}
+			case tc.expectError:
+				if err == nil || errors.Is(err, errMissingEmbedding) {
+					t.Errorf("Expected an invalid embedding error, but got %v", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Expected no error, but got %v", err)
+				}
+				for i := range expected {
+					if vector[i] != expected[i] {
+						t.Errorf("Expected vector %v, but got %v", expected, vector)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentEmbedding(t *testing.T) {
+	expected := []float32{0.5, 1, -2}
+	half := float16.Encode(expected)
+
+	testCases := []struct {
+		name          string
+		data          map[string]interface{}
+		expectMissing bool
+		expectError   bool
+	}{
+		{name: "Full precision", data: map[string]interface{}{"embedding": []interface{}{0.5, 1.0, -2.0}}},
+		{name: "Half precision", data: map[string]interface{}{"embedding": nil, "embedding_f16": half}},
+		{name: "Full precision preferred", data: map[string]interface{}{"embedding": []interface{}{0.5, 1.0, -2.0}, "embedding_f16": []byte{1}}},
+		{name: "Half precision of the wrong type", data: map[string]interface{}{"embedding_f16": "AAA="}, expectError: true},
+		{name: "Half precision of odd length", data: map[string]interface{}{"embedding_f16": half[:5]}, expectError: true},
+		{name: "Half precision of the wrong dimension", data: map[string]interface{}{"embedding_f16": half[:4]}, expectError: true},
+		{name: "Neither", data: map[string]interface{}{"status": "completed"}, expectMissing: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vector, err := documentEmbedding(tc.data, "", len(expected))
+
+			switch {
+			case tc.expectMissing:
+				if !errors.Is(err, errMissingEmbedding) {
+					t.Errorf("Expected errMissingEmbedding, but got %v", err)
+				}
+			case tc.expectError:
+				if err == nil || errors.Is(err, errMissingEmbedding) {
+					t.Errorf("Expected an invalid embedding error, but got %v", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Expected no error, but got %v", err)
+				}
+				for i := range expected {
+					if vector[i] != expected[i] {
+						t.Errorf("Expected vector %v, but got %v", expected, vector)
+						break
+					}
+				}
+			}
+		})
+	}
+}
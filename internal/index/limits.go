@@ -0,0 +1,63 @@
+package index
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// Built-in search limits, overridable with SEARCH_DEFAULT_K and SEARCH_MAX_K
+const (
+	defaultSearchK = 5
+	maxSearchK     = 100
+)
+
+// defaultBuildBatchSize is the number of vectors added to the index at a time
+// during a build, overridable with INDEX_BUILD_BATCH_SIZE
+const defaultBuildBatchSize = 1000
+
+// BuildBatchSize returns the number of vectors an index build buffers before adding
+// them to the index, which bounds the memory a build needs besides the index itself
+func BuildBatchSize() int {
+	return envInt("INDEX_BUILD_BATCH_SIZE", defaultBuildBatchSize)
+}
+
+// DefaultK returns the number of neighbours returned when a caller does not ask for a specific k
+func DefaultK() int {
+	k := envInt("SEARCH_DEFAULT_K", defaultSearchK)
+	if maxK := MaxK(); k > maxK {
+		return maxK
+	}
+	return k
+}
+
+// MaxK returns the largest k a search may request
+func MaxK() int {
+	return envInt("SEARCH_MAX_K", maxSearchK)
+}
+
+// ClampK normalises a requested k: values of 0 or less use DefaultK and values
+// above MaxK are reduced to MaxK, so no caller can force an unbounded result set.
+func ClampK(k int) int {
+	if k <= 0 {
+		return DefaultK()
+	}
+	if maxK := MaxK(); k > maxK {
+		return maxK
+	}
+	return k
+}
+
+// envInt reads a positive integer from the environment, falling back on invalid values
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("Invalid %s %q, using default of %d", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
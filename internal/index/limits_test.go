@@ -0,0 +1,58 @@
+package index
+
+import "testing"
+
+func TestClampK(t *testing.T) {
+	t.Setenv("SEARCH_DEFAULT_K", "7")
+	t.Setenv("SEARCH_MAX_K", "20")
+
+	testCases := []struct {
+		name      string
+		requested int
+		expected  int
+	}{
+		{name: "Missing k uses default", requested: 0, expected: 7},
+		{name: "Negative k uses default", requested: -3, expected: 7},
+		{name: "In range k is kept", requested: 12, expected: 12},
+		{name: "Max k is kept", requested: 20, expected: 20},
+		{name: "Over max k is clamped", requested: 5000, expected: 20},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ClampK(tc.requested); got != tc.expected {
+				t.Errorf("Expected ClampK(%d) to be %d, but got %d", tc.requested, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestClampK_BuiltInDefaults(t *testing.T) {
+	t.Setenv("SEARCH_DEFAULT_K", "")
+	t.Setenv("SEARCH_MAX_K", "not-a-number")
+
+	if got := ClampK(0); got != defaultSearchK {
+		t.Errorf("Expected default k %d, but got %d", defaultSearchK, got)
+	}
+	if got := ClampK(maxSearchK + 1); got != maxSearchK {
+		t.Errorf("Expected max k %d, but got %d", maxSearchK, got)
+	}
+}
+
+func TestSearch_ClampsK(t *testing.T) {
+	t.Setenv("SEARCH_MAX_K", "2")
+	m := newTestManager(t, 2)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if err := m.Add(id, []float32{1, 0}); err != nil {
+			t.Fatalf("Add(%s) failed: %v", id, err)
+		}
+	}
+
+	_, assetIDs, err := m.Search([]float32{1, 0}, 1000)
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+	if len(assetIDs) != 2 {
+		t.Errorf("Expected 2 results after clamping, but got %d", len(assetIDs))
+	}
+}
@@ -3,10 +3,11 @@ package index
 import (
 	"context"
 	"errors"
-	"io"
+	"fmt"
 	"log"
-	"os"
+	"sort"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
@@ -14,15 +15,45 @@ import (
 	"google.golang.org/api/iterator"
 )
 
+// EmbeddingDimension is the size of Gemini's multimodal embeddings
+const EmbeddingDimension = 1408
+
+// ErrAssetNotIndexed is returned when an asset has no vector in the index
+var ErrAssetNotIndexed = errors.New("asset is not indexed")
+
 // IndexManager manages FAISS indices and provides thread-safe operations
 type IndexManager struct {
 	index faiss.Index
 	idMap map[int64]string
-	mu    sync.RWMutex
+	// vectors caches the embedding of each asset added since the index was built or
+	// loaded for SearchByAssetID; other vectors are read from source
+	vectors map[string][]float32
+	// source reads the embeddings of indexed assets missing from vectors
+	source VectorSource
+	// removed holds labels of deleted assets that must no longer appear in results
+	removed map[int64]bool
+	// createdAt holds each indexed asset's creation time for recency ranking
+	createdAt map[string]time.Time
+	mu        sync.RWMutex
+	// saveMu serializes snapshot saves. It is separate from mu so searches and adds
+	// continue while a snapshot is uploaded.
+	saveMu sync.Mutex
+	// searchSlots bounds concurrent searches when SEARCH_MAX_CONCURRENT is set; it
+	// is created by the first search
+	searchSlots    chan struct{}
+	searchSlotsSet sync.Once
+	// mappedFile is the file the index is memory-mapped from when it was loaded with
+	// INDEX_LOAD_MODE=mmap, removed once another index replaces it
+	mappedFile string
 }
 
-// Load downloads and loads a FAISS index from Google Cloud Storage
-func (m *IndexManager) Load(ctx context.Context, bucketName, objectName string) error {
+// Load downloads the current index snapshot from Google Cloud Storage, following the
+// latest pointer (see LoadSnapshot). It gives up after INDEX_LOAD_TIMEOUT, or
+// earlier if ctx says so, so a hung read cannot hold up startup indefinitely.
+func (m *IndexManager) Load(ctx context.Context, bucketName string) error {
+	ctx, cancel := withTimeout(ctx, "INDEX_LOAD_TIMEOUT", defaultLoadTimeout)
+	defer cancel()
+
 	// Initialize a Google Cloud Storage client
 	client, err := storage.NewClient(ctx)
 	if err != nil {
@@ -30,54 +61,16 @@ func (m *IndexManager) Load(ctx context.Context, bucketName, objectName string)
 	}
 	defer client.Close()
 
-	// Get the GCS object handle
-	obj := client.Bucket(bucketName).Object(objectName)
-	
-	// Attempt to download the GCS object
-	reader, err := obj.NewReader(ctx)
-	if err != nil {
-		// If the download error is storage.ErrObjectNotExist, log and return nil
-		if err == storage.ErrObjectNotExist {
-			log.Printf("Index file not found in GCS: gs://%s/%s", bucketName, objectName)
-			return nil
-		}
-		return err
-	}
-	defer reader.Close()
-
-	// Create a temporary file to store the downloaded index
-	tempFile, err := os.CreateTemp("", "faiss_index_*.bin")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tempFile.Name()) // Clean up temporary file
-	defer tempFile.Close()
-
-	// Read the object contents into the temporary file
-	_, err = io.Copy(tempFile, reader)
-	if err != nil {
-		return err
-	}
-
-	// Close the temp file before reading it with FAISS
-	tempFile.Close()
-
-	// Use faiss.ReadIndex to load the index from the temporary file
-	loadedIndex, err := faiss.ReadIndex(tempFile.Name())
-	if err != nil {
-		return err
-	}
-
-	// Use mutex lock before writing to m.index
-	m.mu.Lock()
-	m.index = loadedIndex
-	m.mu.Unlock()
-
-	return nil
+	return m.LoadSnapshot(ctx, GCSStore{Client: client, Bucket: bucketName})
 }
 
-// Build creates a new FAISS index from Firestore documents containing embeddings
+// Build creates a new FAISS index from Firestore documents containing embeddings.
+// It gives up after INDEX_BUILD_TIMEOUT, or earlier if ctx says so, leaving the
+// current index in place.
 func (m *IndexManager) Build(ctx context.Context, projectID, collectionName string) error {
+	ctx, cancel := withTimeout(ctx, "INDEX_BUILD_TIMEOUT", defaultBuildTimeout)
+	defer cancel()
+
 	// Initialize a Firestore client
 	client, err := firestore.NewClient(ctx, projectID)
 	if err != nil {
@@ -89,13 +82,59 @@ func (m *IndexManager) Build(ctx context.Context, projectID, collectionName stri
 	iter := client.Collection(collectionName).Documents(ctx)
 	defer iter.Stop()
 
-	// Create local slices to hold vectors and asset IDs
-	var vectors [][]float32
-	var assetIDs []string
+	return m.buildFrom(ctx, func() (string, map[string]interface{}, error) {
+		doc, err := iter.Next()
+		if err != nil {
+			return "", nil, err
+		}
+		return doc.Ref.ID, doc.Data(), nil
+	})
+}
+
+// buildFrom builds the index from the documents returned by next, which reports
+// iterator.Done after the last one. The context is checked between documents, so
+// a canceled build stops promptly instead of finishing the collection.
+func (m *IndexManager) buildFrom(ctx context.Context, next func() (id string, data map[string]interface{}, err error)) error {
+	// Create a new FAISS index with Gemini's multimodal embedding dimension
+	index, err := faiss.NewIndexFlatL2(EmbeddingDimension)
+	if err != nil {
+		return err
+	}
+	// A build that fails part way frees the vectors it already added
+	built := false
+	defer func() {
+		if !built {
+			index.Delete()
+		}
+	}()
+
+	// Vectors are added to the index in batches of INDEX_BUILD_BATCH_SIZE through one
+	// reused buffer, so the memory a build needs beyond the index itself is bounded
+	// by the batch size rather than the collection size
+	batchSize := BuildBatchSize()
+	batch := make([]float32, 0, batchSize*EmbeddingDimension)
+	idMap := make(map[int64]string)
+	createdAt := make(map[string]time.Time)
+	encoding := embeddingEncoding()
+	indexed, missing, invalid := 0, 0, 0
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := addBatch(index, batch); err != nil {
+			return fmt.Errorf("failed to add vectors %d-%d to the index: %v", indexed-len(batch)/EmbeddingDimension, indexed-1, err)
+		}
+		batch = batch[:0]
+		return nil
+	}
 
 	// Iterate through the documents
 	for {
-		doc, err := iter.Next()
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("index build aborted after %d documents: %w", indexed+missing+invalid, err)
+		}
+		docID, data, err := next()
 		if err == iterator.Done {
 			break
 		}
@@ -103,105 +142,93 @@ func (m *IndexManager) Build(ctx context.Context, projectID, collectionName stri
 			return err
 		}
 
-		// Unmarshal the document data
-		data := doc.Data()
+		// Soft-deleted assets are excluded from search
+		if status, ok := data["status"].(string); ok && status == "deleted" {
+			continue
+		}
+		
+		// Convert the embedding to []float32, counting documents that can't be indexed
+		vector, err := documentEmbedding(data, encoding, EmbeddingDimension)
+		if errors.Is(err, errMissingEmbedding) {
+			missing++
+			continue
+		}
+		if err != nil {
+			log.Printf("Skipping document %s with invalid embedding: %v", docID, err)
+			invalid++
+			continue
+		}
+		// Embeddings stored before INDEX_METRIC=cosine was set are normalized here
+		vector = PrepareVector(vector)
 		
-		// Check if the document contains an embedding
-		if embeddingData, exists := data["embedding"]; exists {
-			// Convert embedding to []float32
-			if embeddingSlice, ok := embeddingData.([]interface{}); ok {
-				vector := make([]float32, len(embeddingSlice))
-				for i, val := range embeddingSlice {
-					if floatVal, ok := val.(float64); ok {
-						vector[i] = float32(floatVal)
-					}
-				}
-				
-				// Get the asset ID (use document ID if no specific asset ID field)
-				assetID := doc.Ref.ID
-				if assetIDData, exists := data["assetId"]; exists {
-					if assetIDStr, ok := assetIDData.(string); ok {
-						assetID = assetIDStr
-					}
-				}
-				
-				// Append to local slices
-				vectors = append(vectors, vector)
-				assetIDs = append(assetIDs, assetID)
+		// Get the asset ID (use document ID if no specific asset ID field)
+		assetID := docID
+		if assetIDData, exists := data["assetId"]; exists {
+			if assetIDStr, ok := assetIDData.(string); ok {
+				assetID = assetIDStr
 			}
 		}
-	}
+		
+		// The vector's label is its position in the index
+		idMap[int64(indexed)] = assetID
+		if t, ok := data["created_at"].(time.Time); ok {
+			createdAt[assetID] = t
+		}
+		batch = append(batch, vector...)
+		indexed++
 
-	// Create a new FAISS index with dimension 1408 (Gemini's multimodal embedding dimension)
-	index, err := faiss.NewIndexFlatL2(1408)
-	if err != nil {
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return err
+			}
+			log.Printf("Index build added %d embeddings", indexed)
+		}
+	}
+	if err := flush(); err != nil {
 		return err
 	}
 
-	// Add all collected vectors to the index
-	if len(vectors) > 0 {
-		// Convert [][]float32 to the format expected by FAISS
-		flatVectors := make([]float32, len(vectors)*1408)
-		for i, vector := range vectors {
-			copy(flatVectors[i*1408:(i+1)*1408], vector)
-		}
-		
-		err = index.Add(flatVectors)
-		if err != nil {
-			return err
-		}
+	if missing > 0 || invalid > 0 {
+		log.Printf("Index build skipped %d documents: %d without an embedding, %d with an invalid embedding", missing+invalid, missing, invalid)
+	}
+	log.Printf("Index build collected %d embeddings", indexed)
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("index build aborted: %w", err)
 	}
 
 	// Wrap modifications to m.index and m.idMap in mutex lock
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
 	// Set the new index
 	m.index = index
+	built = true
+	m.idMap = idMap
+	// Keeping every vector in Go memory would double the index's footprint, so
+	// SearchByAssetID reads built vectors from the vector source
+	m.vectors = nil
+	m.removed = nil
+	m.createdAt = createdAt
+	previous := m.mappedFile
+	m.mappedFile = ""
+	m.mu.Unlock()
 
-	// Populate the idMap by mapping index position to asset ID
-	m.idMap = make(map[int64]string)
-	for i, assetID := range assetIDs {
-		m.idMap[int64(i)] = assetID
-	}
-
+	// A built index lives in memory, so a previously mapped file is no longer needed
+	removeMappedFile(previous)
 	return nil
 }
 
-// Save uploads the FAISS index to Google Cloud Storage
-func (m *IndexManager) Save(ctx context.Context, bucketName, objectName string) error {
-	// Check if m.index is nil
-	m.mu.RLock()
-	if m.index == nil {
-		m.mu.RUnlock()
-		return errors.New("no index to save: index is nil")
-	}
-	index := m.index
-	m.mu.RUnlock()
-
-	// Create a temporary file on disk
-	tempFile, err := os.CreateTemp("", "faiss_index_save_*.bin")
-	if err != nil {
-		return err
-	}
-	tempFileName := tempFile.Name()
-	defer os.Remove(tempFileName) // Ensure temporary file is removed
-	
-	// Close the temp file so FAISS can write to it
-	tempFile.Close()
-
-	// Use faiss.WriteIndex to save the index to the temporary file
-	err = faiss.WriteIndex(index, tempFileName)
-	if err != nil {
-		return err
-	}
+// addBatch adds a batch of flattened vectors to an index being built
+var addBatch = func(index faiss.Index, vectors []float32) error {
+	return index.Add(vectors)
+}
 
-	// Reopen the temp file for reading
-	tempFile, err = os.Open(tempFileName)
-	if err != nil {
-		return err
-	}
-	defer tempFile.Close()
+// Save uploads the FAISS index to Google Cloud Storage as a new snapshot and moves
+// the latest pointer to it, keeping SnapshotsToKeep versions (see SaveSnapshot). It
+// gives up after INDEX_SAVE_TIMEOUT, or earlier if ctx says so.
+func (m *IndexManager) Save(ctx context.Context, bucketName string) error {
+	ctx, cancel := withTimeout(ctx, "INDEX_SAVE_TIMEOUT", defaultSaveTimeout)
+	defer cancel()
 
 	// Initialize a Google Cloud Storage client
 	client, err := storage.NewClient(ctx)
@@ -210,21 +237,24 @@ func (m *IndexManager) Save(ctx context.Context, bucketName, objectName string)
 	}
 	defer client.Close()
 
-	// Get the GCS object handle for upload
-	obj := client.Bucket(bucketName).Object(objectName)
-	
-	// Create a writer to upload the file
-	writer := obj.NewWriter(ctx)
-	defer writer.Close()
-
-	// Copy the temporary file contents to GCS
-	_, err = io.Copy(writer, tempFile)
+	name, err := m.SaveSnapshot(ctx, GCSStore{Client: client, Bucket: bucketName}, SnapshotsToKeep())
 	if err != nil {
 		return err
 	}
+	log.Printf("Saved index snapshot gs://%s/%s", bucketName, name)
+	return nil
+}
 
-	// Close the writer to finalize the upload
-	return writer.Close()
+// Stats returns the number of vectors in the index and the number of labels mapped
+// to asset IDs. Snapshots are saved and loaded with their labels, so the two only
+// differ when the index has drifted from its ID map.
+func (m *IndexManager) Stats() (ntotal int64, mapped int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.index != nil {
+		ntotal = m.index.Ntotal()
+	}
+	return ntotal, len(m.idMap)
 }
 
 // HasIndex returns true if the manager has a loaded index, false otherwise
@@ -234,44 +264,115 @@ func (m *IndexManager) HasIndex() bool {
 	return m.index != nil
 }
 
-// Search performs a similarity search on the index and returns distances and asset IDs
+// Search performs a similarity search on the index and returns distances and asset IDs.
+// k is normalised with ClampK, so 0 requests the default number of results.
+//
+// Results are cleaned before they are returned:
+//   - each asset appears at most once, at the distance of its nearest vector, even if
+//     it was added more than once;
+//   - removed assets and unknown labels are dropped;
+//   - results are ordered by ascending distance, with ties broken by asset ID, so the
+//     same query against the same index always returns the same order.
+//
+// Fewer than k results are returned only when the index holds fewer distinct assets.
 func (m *IndexManager) Search(vector []float32, k int) (distances []float32, assetIDs []string, err error) {
+	return m.SearchExcluding(vector, k)
+}
+
+// SearchExcluding is Search with the given assets left out of the results. Reprocessing
+// an asset that is already indexed excludes its own ID, which would otherwise match
+// itself at distance 0. Excluded assets do not count towards k.
+func (m *IndexManager) SearchExcluding(vector []float32, k int, excludeIDs ...string) (distances []float32, assetIDs []string, err error) {
+	k = ClampK(k)
+	var exclude map[string]bool
+	if len(excludeIDs) > 0 {
+		exclude = make(map[string]bool, len(excludeIDs))
+		for _, assetID := range excludeIDs {
+			exclude[assetID] = true
+		}
+	}
+	
+	// Wait for a search slot before taking the lock, so queued searches do not
+	// hold back writers
+	release, err := m.acquireSearch()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	
 	// Use a read lock at the beginning and defer the unlock
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
-	// Check if m.index is nil or has 0 vectors
+	// Check if m.index is nil
 	if m.index == nil {
 		return []float32{}, []string{}, nil
 	}
 	
 	// Check if index has 0 vectors
-	if m.index.Ntotal() == 0 {
+	total := m.index.Ntotal()
+	if total == 0 {
 		return []float32{}, []string{}, nil
 	}
 	
-	// Call the m.index.Search() method, passing the vector and k
-	distances, labels, err := m.index.Search(vector, k)
-	if err != nil {
-		return nil, nil, err
+	// Duplicates and removed vectors take up slots, so widen the search until k
+	// distinct assets are found or the whole index has been searched
+	fetch := int64(k)
+	for {
+		if fetch > total {
+			fetch = total
+		}
+		rawDistances, labels, err := m.index.Search(vector, fetch)
+		if err != nil {
+			return nil, nil, err
+		}
+		distances, assetIDs = m.cleanResults(rawDistances, labels, k, exclude)
+		if len(assetIDs) >= k || fetch == total {
+			return distances, assetIDs, nil
+		}
+		fetch *= 2
 	}
-	
-	// Create a new slice for the string assetIDs
-	assetIDs = make([]string, len(labels))
-	
-	// Iterate through the integer labels returned by the search
+}
+
+// cleanResults maps FAISS labels to asset IDs, keeping the nearest occurrence of each
+// asset, dropping removed, excluded and unknown labels, and sorting by distance then
+// asset ID. At most k results are returned. Callers must hold m.mu.
+func (m *IndexManager) cleanResults(distances []float32, labels []int64, k int, exclude map[string]bool) ([]float32, []string) {
+	nearest := make(map[string]float32, len(labels))
 	for i, label := range labels {
-		// Look up the corresponding asset ID string from m.idMap
-		if assetID, exists := m.idMap[label]; exists {
-			assetIDs[i] = assetID
-		} else {
-			// Handle case where label is not found in idMap
-			assetIDs[i] = ""
+		// Skip vectors of assets that have been removed
+		if m.removed[label] {
+			continue
+		}
+		assetID, exists := m.idMap[label]
+		if !exists || exclude[assetID] {
+			continue
 		}
+		if d, seen := nearest[assetID]; !seen || distances[i] < d {
+			nearest[assetID] = distances[i]
+		}
+	}
+	
+	assetIDs := make([]string, 0, len(nearest))
+	for assetID := range nearest {
+		assetIDs = append(assetIDs, assetID)
+	}
+	sort.Slice(assetIDs, func(i, j int) bool {
+		di, dj := nearest[assetIDs[i]], nearest[assetIDs[j]]
+		if di != dj {
+			return di < dj
+		}
+		return assetIDs[i] < assetIDs[j]
+	})
+	if len(assetIDs) > k {
+		assetIDs = assetIDs[:k]
 	}
 	
-	// Return the final distances and asset IDs
-	return distances, assetIDs, nil
+	cleaned := make([]float32, len(assetIDs))
+	for i, assetID := range assetIDs {
+		cleaned[i] = nearest[assetID]
+	}
+	return cleaned, assetIDs
 }
 
 // Add adds a new vector to the index with the given asset ID
@@ -279,7 +380,11 @@ func (m *IndexManager) Add(assetID string, vector []float32) error {
 	// Use a write lock at the beginning and defer the unlock
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.addLocked(assetID, vector)
+}
 
+// addLocked adds a vector with the write lock held
+func (m *IndexManager) addLocked(assetID string, vector []float32) error {
 	// Check if m.index is nil
 	if m.index == nil {
 		return errors.New("index is not initialized")
@@ -299,6 +404,81 @@ func (m *IndexManager) Add(assetID string, vector []float32) error {
 		m.idMap = make(map[int64]string)
 	}
 	m.idMap[newID] = assetID
+	if m.vectors == nil {
+		m.vectors = make(map[string][]float32)
+	}
+	m.vectors[assetID] = append([]float32(nil), vector...)
+	// Assets are indexed as they are processed, so now stands in for created_at
+	if m.createdAt == nil {
+		m.createdAt = make(map[string]time.Time)
+	}
+	if _, known := m.createdAt[assetID]; !known {
+		m.createdAt[assetID] = time.Now()
+	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// SetVectorSource sets where SearchByAssetID reads the embedding of an indexed
+// asset that is not cached in memory
+func (m *IndexManager) SetVectorSource(source VectorSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.source = source
+}
+
+// SearchByAssetID runs a similarity search using the stored vector of an already
+// indexed asset. Searching an asset's own vector returns it as the top result at
+// distance ~0, which makes this useful both for debugging and "find similar" flows.
+// Vectors not cached in memory, such as those of a loaded snapshot, are read from
+// the vector source.
+func (m *IndexManager) SearchByAssetID(ctx context.Context, assetID string, k int) (distances []float32, assetIDs []string, err error) {
+	// Look up the cached vector under a read lock
+	m.mu.RLock()
+	vector, cached := m.vectors[assetID]
+	indexed := cached || m.indexedLocked(assetID)
+	source := m.source
+	m.mu.RUnlock()
+
+	if !indexed || (!cached && source == nil) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrAssetNotIndexed, assetID)
+	}
+	if !cached {
+		if vector, err = source.Vector(ctx, assetID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return m.Search(vector, k)
+}
+
+// indexedLocked reports whether the asset has a vector in the index that has not
+// been removed. Callers must hold m.mu.
+func (m *IndexManager) indexedLocked(assetID string) bool {
+	for label, id := range m.idMap {
+		if id == assetID && !m.removed[label] {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove excludes an asset's vectors from future search results.
+// FAISS flat indexes renumber vectors on removal, so the vectors are kept in
+// the index and filtered out at search time until the next Build.
+func (m *IndexManager) Remove(assetID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for label, id := range m.idMap {
+		if id != assetID {
+			continue
+		}
+		if m.removed == nil {
+			m.removed = make(map[int64]bool)
+		}
+		m.removed[label] = true
+	}
+	delete(m.vectors, assetID)
+	delete(m.createdAt, assetID)
+}
@@ -0,0 +1,211 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+// newTestManager returns an IndexManager backed by an empty in-memory flat index
+func newTestManager(t *testing.T, dimension int) *IndexManager {
+	t.Helper()
+	flatIndex, err := faiss.NewIndexFlatL2(dimension)
+	if err != nil {
+		t.Fatalf("Failed to create FAISS index: %v", err)
+	}
+	return &IndexManager{index: flatIndex}
+}
+
+func TestSearchByAssetID_SelfMatch(t *testing.T) {
+	m := newTestManager(t, 3)
+
+	// Add a few well-separated vectors
+	vectors := map[string][]float32{
+		"asset-a": {1, 0, 0},
+		"asset-b": {0, 1, 0},
+		"asset-c": {0, 0, 1},
+	}
+	for _, id := range []string{"asset-a", "asset-b", "asset-c"} {
+		if err := m.Add(id, vectors[id]); err != nil {
+			t.Fatalf("Add(%s) failed: %v", id, err)
+		}
+	}
+
+	distances, assetIDs, err := m.SearchByAssetID(context.Background(), "asset-b", 2)
+	if err != nil {
+		t.Fatalf("SearchByAssetID() failed: %v", err)
+	}
+
+	if len(assetIDs) == 0 || assetIDs[0] != "asset-b" {
+		t.Fatalf("Expected asset-b to be the top result, but got %v", assetIDs)
+	}
+	if distances[0] > 1e-6 {
+		t.Errorf("Expected self-match distance ~0, but got %f", distances[0])
+	}
+}
+
+func TestSearchByAssetID_NotIndexed(t *testing.T) {
+	m := newTestManager(t, 3)
+
+	_, _, err := m.SearchByAssetID(context.Background(), "missing", 5)
+	if !errors.Is(err, ErrAssetNotIndexed) {
+		t.Errorf("Expected ErrAssetNotIndexed, but got %v", err)
+	}
+}
+
+// vectorMap is a VectorSource backed by a map
+type vectorMap map[string][]float32
+
+func (v vectorMap) Vector(ctx context.Context, assetID string) ([]float32, error) {
+	vector, ok := v[assetID]
+	if !ok {
+		return nil, ErrAssetNotIndexed
+	}
+	return vector, nil
+}
+
+func TestSearchByAssetID_LoadedSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	vectors := vectorMap{"asset-a": {1, 0, 0}, "asset-b": {0, 1, 0}, "asset-c": {0, 0, 1}}
+	source := newTestManager(t, 3)
+	for _, id := range []string{"asset-a", "asset-b", "asset-c"} {
+		if err := source.Add(id, vectors[id]); err != nil {
+			t.Fatalf("Add(%s) failed: %v", id, err)
+		}
+	}
+	if _, err := source.SaveSnapshot(ctx, store, 5); err != nil {
+		t.Fatalf("SaveSnapshot() failed: %v", err)
+	}
+
+	m := &IndexManager{}
+	if err := m.LoadSnapshot(ctx, store); err != nil {
+		t.Fatalf("LoadSnapshot() failed: %v", err)
+	}
+
+	// A loaded snapshot caches no vectors, so there is nothing to search by
+	if _, _, err := m.SearchByAssetID(ctx, "asset-b", 2); !errors.Is(err, ErrAssetNotIndexed) {
+		t.Fatalf("Expected ErrAssetNotIndexed without a vector source, but got %v", err)
+	}
+
+	m.SetVectorSource(vectors)
+	distances, assetIDs, err := m.SearchByAssetID(ctx, "asset-b", 2)
+	if err != nil {
+		t.Fatalf("SearchByAssetID() failed: %v", err)
+	}
+	if len(assetIDs) == 0 || assetIDs[0] != "asset-b" || distances[0] > 1e-6 {
+		t.Errorf("Expected asset-b to match itself first, but got %v %v", assetIDs, distances)
+	}
+
+	// Only indexed assets are looked up
+	vectors["asset-d"] = []float32{0.9, 0.1, 0}
+	if _, _, err := m.SearchByAssetID(ctx, "asset-d", 2); !errors.Is(err, ErrAssetNotIndexed) {
+		t.Errorf("Expected ErrAssetNotIndexed for an asset missing from the index, but got %v", err)
+	}
+	m.Remove("asset-a")
+	if _, _, err := m.SearchByAssetID(ctx, "asset-a", 2); !errors.Is(err, ErrAssetNotIndexed) {
+		t.Errorf("Expected ErrAssetNotIndexed for a removed asset, but got %v", err)
+	}
+}
+
+func TestRemove_ExcludesAssetFromSearch(t *testing.T) {
+	m := newTestManager(t, 3)
+	if err := m.Add("asset-a", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := m.Add("asset-b", []float32{0.9, 0.1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	m.Remove("asset-a")
+
+	_, assetIDs, err := m.Search([]float32{1, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, id := range assetIDs {
+		if id == "asset-a" {
+			t.Errorf("Expected removed asset to be excluded, but got %v", assetIDs)
+		}
+	}
+	if len(assetIDs) != 1 || assetIDs[0] != "asset-b" {
+		t.Errorf("Expected only asset-b in results, but got %v", assetIDs)
+	}
+
+	if _, _, err := m.SearchByAssetID(context.Background(), "asset-a", 1); !errors.Is(err, ErrAssetNotIndexed) {
+		t.Errorf("Expected removed asset to be unsearchable by ID, but got %v", err)
+	}
+}
+
+func TestSearchExcluding_FiltersExcludedAsset(t *testing.T) {
+	m := newTestManager(t, 3)
+	for id, vector := range map[string][]float32{
+		"asset-a": {1, 0, 0},
+		"asset-b": {0.9, 0.1, 0},
+		"asset-c": {0, 1, 0},
+	} {
+		if err := m.Add(id, vector); err != nil {
+			t.Fatalf("Add(%s) failed: %v", id, err)
+		}
+	}
+
+	// Reprocessing asset-a searches with its own vector
+	_, assetIDs, err := m.SearchExcluding([]float32{1, 0, 0}, 2, "asset-a")
+	if err != nil {
+		t.Fatalf("SearchExcluding failed: %v", err)
+	}
+	if len(assetIDs) != 2 || assetIDs[0] != "asset-b" || assetIDs[1] != "asset-c" {
+		t.Errorf("Expected [asset-b asset-c] without the excluded asset, but got %v", assetIDs)
+	}
+
+	_, assetIDs, err = m.Search([]float32{1, 0, 0}, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(assetIDs) != 1 || assetIDs[0] != "asset-a" {
+		t.Errorf("Expected Search without exclusions to self-match, but got %v", assetIDs)
+	}
+}
+
+func TestSearch_DeduplicatesAndOrdersResults(t *testing.T) {
+	m := newTestManager(t, 3)
+
+	// asset-a was added twice, once far from the query and once exactly on it;
+	// asset-b and asset-c tie at the same distance
+	additions := []struct {
+		id     string
+		vector []float32
+	}{
+		{"asset-a", []float32{0, 0, 5}},
+		{"asset-c", []float32{1, 0, 1}},
+		{"asset-a", []float32{1, 0, 0}},
+		{"asset-b", []float32{1, 1, 0}},
+		{"asset-a", []float32{1, 0, 0}},
+	}
+	for _, add := range additions {
+		if err := m.Add(add.id, add.vector); err != nil {
+			t.Fatalf("Add(%s) failed: %v", add.id, err)
+		}
+	}
+
+	distances, assetIDs, err := m.Search([]float32{1, 0, 0}, 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	expectedIDs := []string{"asset-a", "asset-b", "asset-c"}
+	expectedDistances := []float32{0, 1, 1}
+	if len(assetIDs) != len(expectedIDs) {
+		t.Fatalf("Expected results %v, but got %v", expectedIDs, assetIDs)
+	}
+	for i := range expectedIDs {
+		if assetIDs[i] != expectedIDs[i] {
+			t.Errorf("Expected result %d to be %s, but got %s (all: %v)", i, expectedIDs[i], assetIDs[i], assetIDs)
+		}
+		if distances[i] != expectedDistances[i] {
+			t.Errorf("Expected distance %v for %s, but got %v", expectedDistances[i], expectedIDs[i], distances[i])
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package index
+
+import (
+	"log"
+	"math"
+	"os"
+	"strings"
+)
+
+// Similarity metrics, selected with INDEX_METRIC
+const (
+	// MetricL2 compares raw embeddings by Euclidean distance
+	MetricL2 = "l2"
+	// MetricCosine compares embeddings by angle. Vectors are L2-normalized before
+	// they are stored or indexed; the flat L2 index then ranks them exactly as
+	// cosine similarity would, since for unit vectors |a-b|² = 2 - 2cos(a,b).
+	MetricCosine = "cosine"
+)
+
+// Metric returns INDEX_METRIC, defaulting to l2
+func Metric() string {
+	metric := strings.ToLower(strings.TrimSpace(os.Getenv("INDEX_METRIC")))
+	switch metric {
+	case "":
+		return MetricL2
+	case MetricL2, MetricCosine:
+		return metric
+	}
+	log.Printf("Invalid INDEX_METRIC %q, using default of %s", metric, MetricL2)
+	return MetricL2
+}
+
+// Normalize returns a copy of the vector scaled to unit length. A zero vector
+// has no direction and is returned unchanged.
+func Normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	normalized := append([]float32(nil), vector...)
+	if sum == 0 {
+		return normalized
+	}
+	norm := math.Sqrt(sum)
+	for i, v := range normalized {
+		normalized[i] = float32(float64(v) / norm)
+	}
+	return normalized
+}
+
+// PrepareVector applies the normalization the configured metric needs. Every
+// vector that is stored, indexed or used as a query should go through it so the
+// stored and indexed forms agree.
+func PrepareVector(vector []float32) []float32 {
+	if Metric() == MetricCosine {
+		return Normalize(vector)
+	}
+	return vector
+}
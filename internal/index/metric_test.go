@@ -0,0 +1,67 @@
+package index
+
+import (
+	"math"
+	"testing"
+)
+
+func vectorLength(vector []float32) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum)
+}
+
+func TestNormalize_UnitLength(t *testing.T) {
+	testCases := []struct {
+		name   string
+		vector []float32
+	}{
+		{name: "Simple", vector: []float32{3, 4}},
+		{name: "Negative values", vector: []float32{-1, 2, -2}},
+		{name: "Already unit", vector: []float32{0, 1, 0}},
+		{name: "Large values", vector: []float32{1e6, 2e6, 3e6}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := append([]float32(nil), tc.vector...)
+			normalized := Normalize(tc.vector)
+			if length := vectorLength(normalized); math.Abs(length-1) > 1e-6 {
+				t.Errorf("Expected unit length, but got %f", length)
+			}
+			for i := range original {
+				if tc.vector[i] != original[i] {
+					t.Fatalf("Expected the input to be left unchanged, but got %v", tc.vector)
+				}
+			}
+		})
+	}
+
+	if zero := Normalize([]float32{0, 0}); zero[0] != 0 || zero[1] != 0 {
+		t.Errorf("Expected a zero vector to stay zero, but got %v", zero)
+	}
+}
+
+func TestPrepareVector_FollowsMetric(t *testing.T) {
+	testCases := []struct {
+		name           string
+		metric         string
+		expectedLength float64
+	}{
+		{name: "Default leaves vectors raw", metric: "", expectedLength: 5},
+		{name: "L2 leaves vectors raw", metric: "l2", expectedLength: 5},
+		{name: "Cosine normalizes", metric: "cosine", expectedLength: 1},
+		{name: "Invalid falls back to l2", metric: "manhattan", expectedLength: 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("INDEX_METRIC", tc.metric)
+			if length := vectorLength(PrepareVector([]float32{3, 4})); math.Abs(length-tc.expectedLength) > 1e-6 {
+				t.Errorf("Expected length %f, but got %f", tc.expectedLength, length)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package index
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result orderings, selected with SEARCH_RANKING
+const (
+	// RankDistance orders results by embedding distance alone
+	RankDistance = "distance"
+	// RankRecency blends embedding distance with how recently each asset was created
+	RankRecency = "recency"
+)
+
+// Built-in recency ranking settings, overridable with SEARCH_RECENCY_WEIGHT and
+// SEARCH_RECENCY_HALF_LIFE
+const (
+	defaultRecencyWeight   = 0.3
+	defaultRecencyHalfLife = 30 * 24 * time.Hour
+)
+
+// recencyCandidateFactor widens the search in recency mode so that newer assets
+// just beyond the k nearest can move into the results
+const recencyCandidateFactor = 4
+
+// RankOptions selects how search results are ordered
+type RankOptions struct {
+	Mode string
+	// RecencyWeight is the share of the blended score given to recency, from 0 to 1
+	RecencyWeight float64
+	// HalfLife is the age at which an asset's recency counts half as much as a new one
+	HalfLife time.Duration
+	// Now is the reference time for ages; zero means the current time
+	Now time.Time
+}
+
+// RankOptionsFromEnv returns the ordering configured in SEARCH_RANKING (distance by
+// default), SEARCH_RECENCY_WEIGHT and SEARCH_RECENCY_HALF_LIFE
+func RankOptionsFromEnv() RankOptions {
+	opts := RankOptions{Mode: RankDistance, RecencyWeight: defaultRecencyWeight, HalfLife: defaultRecencyHalfLife}
+
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("SEARCH_RANKING"))); mode {
+	case "", RankDistance:
+	case RankRecency:
+		opts.Mode = RankRecency
+	default:
+		log.Printf("Invalid SEARCH_RANKING %q, using default of %s", mode, RankDistance)
+	}
+
+	if value := os.Getenv("SEARCH_RECENCY_WEIGHT"); value != "" {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight < 0 || weight > 1 {
+			log.Printf("Invalid SEARCH_RECENCY_WEIGHT %q, using default of %v", value, defaultRecencyWeight)
+		} else {
+			opts.RecencyWeight = weight
+		}
+	}
+	if value := os.Getenv("SEARCH_RECENCY_HALF_LIFE"); value != "" {
+		halfLife, err := time.ParseDuration(value)
+		if err != nil || halfLife <= 0 {
+			log.Printf("Invalid SEARCH_RECENCY_HALF_LIFE %q, using default of %v", value, defaultRecencyHalfLife)
+		} else {
+			opts.HalfLife = halfLife
+		}
+	}
+	return opts
+}
+
+// SearchRanked is SearchExcluding with results ordered according to opts. In
+// recency mode a wider set of candidates is searched and re-ranked by
+// rerankByRecency before the k best are returned.
+func (m *IndexManager) SearchRanked(vector []float32, k int, opts RankOptions, excludeIDs ...string) (distances []float32, assetIDs []string, err error) {
+	if opts.Mode != RankRecency {
+		return m.SearchExcluding(vector, k, excludeIDs...)
+	}
+
+	k = ClampK(k)
+	distances, assetIDs, err = m.SearchExcluding(vector, k*recencyCandidateFactor, excludeIDs...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.mu.RLock()
+	createdAt := make(map[string]time.Time, len(assetIDs))
+	for _, assetID := range assetIDs {
+		if t, ok := m.createdAt[assetID]; ok {
+			createdAt[assetID] = t
+		}
+	}
+	m.mu.RUnlock()
+
+	distances, assetIDs = rerankByRecency(distances, assetIDs, createdAt, opts)
+	if len(assetIDs) > k {
+		distances, assetIDs = distances[:k], assetIDs[:k]
+	}
+	return distances, assetIDs, nil
+}
+
+// rerankByRecency orders results by a blended score, lower being better:
+//
+//	(1-w) * distance/maxDistance + w * (1 - 0.5^(age/halfLife))
+//
+// Distances are scaled by the largest in the set so both terms range over [0, 1].
+// Assets with no known creation time count as infinitely old. Ties keep distance
+// order, then asset ID order. The returned distances are the original ones.
+func rerankByRecency(distances []float32, assetIDs []string, createdAt map[string]time.Time, opts RankOptions) ([]float32, []string) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var maxDistance float64
+	for _, d := range distances {
+		maxDistance = math.Max(maxDistance, float64(d))
+	}
+
+	type ranked struct {
+		distance float32
+		assetID  string
+		score    float64
+	}
+	results := make([]ranked, len(assetIDs))
+	for i, assetID := range assetIDs {
+		distanceTerm := 0.0
+		if maxDistance > 0 {
+			distanceTerm = float64(distances[i]) / maxDistance
+		}
+		ageTerm := 1.0
+		if t, ok := createdAt[assetID]; ok && opts.HalfLife > 0 {
+			age := math.Max(0, now.Sub(t).Hours())
+			ageTerm = 1 - math.Pow(0.5, age/opts.HalfLife.Hours())
+		}
+		score := (1-opts.RecencyWeight)*distanceTerm + opts.RecencyWeight*ageTerm
+		results[i] = ranked{distance: distances[i], assetID: assetID, score: score}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score < results[j].score
+		}
+		if results[i].distance != results[j].distance {
+			return results[i].distance < results[j].distance
+		}
+		return results[i].assetID < results[j].assetID
+	})
+
+	rankedDistances := make([]float32, len(results))
+	rankedIDs := make([]string, len(results))
+	for i, r := range results {
+		rankedDistances[i], rankedIDs[i] = r.distance, r.assetID
+	}
+	return rankedDistances, rankedIDs
+}
@@ -0,0 +1,98 @@
+package index
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRerankByRecency_ReordersTiedResults(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := map[string]time.Time{
+		"asset-old":    now.Add(-90 * 24 * time.Hour),
+		"asset-recent": now.Add(-24 * time.Hour),
+		"asset-new":    now,
+	}
+	distances := []float32{0.5, 0.5, 0.5, 2}
+	assetIDs := []string{"asset-old", "asset-recent", "asset-unknown", "asset-new"}
+
+	testCases := []struct {
+		name     string
+		opts     RankOptions
+		expected []string
+	}{
+		{
+			name:     "Recency breaks distance ties",
+			opts:     RankOptions{Mode: RankRecency, RecencyWeight: 0.3, HalfLife: 30 * 24 * time.Hour, Now: now},
+			expected: []string{"asset-recent", "asset-old", "asset-unknown", "asset-new"},
+		},
+		{
+			name:     "Heavy weight lets a new, farther asset pass older ones",
+			opts:     RankOptions{Mode: RankRecency, RecencyWeight: 0.9, HalfLife: 30 * 24 * time.Hour, Now: now},
+			expected: []string{"asset-recent", "asset-new", "asset-old", "asset-unknown"},
+		},
+		{
+			name:     "Zero weight keeps distance order",
+			opts:     RankOptions{Mode: RankRecency, RecencyWeight: 0, HalfLife: 30 * 24 * time.Hour, Now: now},
+			expected: []string{"asset-old", "asset-recent", "asset-unknown", "asset-new"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rankedDistances, rankedIDs := rerankByRecency(distances, assetIDs, createdAt, tc.opts)
+			for i, id := range tc.expected {
+				if rankedIDs[i] != id {
+					t.Fatalf("Expected %v, but got %v", tc.expected, rankedIDs)
+				}
+			}
+			for i, id := range rankedIDs {
+				if id == "asset-new" && rankedDistances[i] != 2 {
+					t.Errorf("Expected the original distance 2 for asset-new, but got %f", rankedDistances[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSearchRanked_DefaultIsPureDistance(t *testing.T) {
+	m := newTestManager(t, 3)
+	for _, id := range []string{"asset-a", "asset-b", "asset-c"} {
+		if err := m.Add(id, []float32{1, 0, 0}); err != nil {
+			t.Fatalf("Add(%s) failed: %v", id, err)
+		}
+	}
+	// asset-c is the newest and asset-a far older; all three tie on distance
+	now := time.Now()
+	m.createdAt["asset-a"] = now.Add(-365 * 24 * time.Hour)
+	m.createdAt["asset-b"] = now.Add(-30 * 24 * time.Hour)
+	m.createdAt["asset-c"] = now
+
+	testCases := []struct {
+		name     string
+		ranking  string
+		expected []string
+	}{
+		{name: "Default", ranking: "", expected: []string{"asset-a", "asset-b", "asset-c"}},
+		{name: "Distance", ranking: "distance", expected: []string{"asset-a", "asset-b", "asset-c"}},
+		{name: "Recency", ranking: "recency", expected: []string{"asset-c", "asset-b", "asset-a"}},
+		{name: "Invalid falls back to distance", ranking: "newest", expected: []string{"asset-a", "asset-b", "asset-c"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("SEARCH_RANKING", tc.ranking)
+			_, assetIDs, err := m.SearchRanked([]float32{1, 0, 0}, 3, RankOptionsFromEnv())
+			if err != nil {
+				t.Fatalf("SearchRanked failed: %v", err)
+			}
+			if len(assetIDs) != len(tc.expected) {
+				t.Fatalf("Expected %v, but got %v", tc.expected, assetIDs)
+			}
+			for i, id := range tc.expected {
+				if assetIDs[i] != id {
+					t.Fatalf("Expected %v, but got %v", tc.expected, assetIDs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package index
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrSearchBusy is returned by a search rejected because SEARCH_MAX_CONCURRENT
+// searches are already running (SEARCH_WHEN_BUSY=reject)
+var ErrSearchBusy = errors.New("too many concurrent index searches")
+
+// Values of SEARCH_WHEN_BUSY
+const (
+	// SearchBusyQueue makes a search wait for a free slot
+	SearchBusyQueue = "queue"
+	// SearchBusyReject makes a search return ErrSearchBusy instead of waiting
+	SearchBusyReject = "reject"
+)
+
+// maxConcurrentSearches returns SEARCH_MAX_CONCURRENT. 0, the default, leaves
+// searches unbounded.
+func maxConcurrentSearches() int {
+	value := os.Getenv("SEARCH_MAX_CONCURRENT")
+	if value == "" {
+		return 0
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		log.Printf("Invalid SEARCH_MAX_CONCURRENT %q, using default of unbounded", value)
+		return 0
+	}
+	return limit
+}
+
+// searchBusyMode returns SEARCH_WHEN_BUSY, defaulting to SearchBusyQueue
+func searchBusyMode() string {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv("SEARCH_WHEN_BUSY"))); value {
+	case "", SearchBusyQueue:
+		return SearchBusyQueue
+	case SearchBusyReject:
+		return SearchBusyReject
+	default:
+		log.Printf("Invalid SEARCH_WHEN_BUSY %q, using default of %s", value, SearchBusyQueue)
+		return SearchBusyQueue
+	}
+}
+
+// acquireSearch takes one of the SEARCH_MAX_CONCURRENT search slots, waiting for a
+// free one or, with SEARCH_WHEN_BUSY=reject, failing with ErrSearchBusy when all
+// are taken. The returned function gives the slot back.
+func (m *IndexManager) acquireSearch() (release func(), err error) {
+	m.searchSlotsSet.Do(func() {
+		if limit := maxConcurrentSearches(); limit > 0 {
+			m.searchSlots = make(chan struct{}, limit)
+		}
+	})
+	if m.searchSlots == nil {
+		return func() {}, nil
+	}
+
+	if searchBusyMode() == SearchBusyReject {
+		select {
+		case m.searchSlots <- struct{}{}:
+		default:
+			return nil, ErrSearchBusy
+		}
+	} else {
+		m.searchSlots <- struct{}{}
+	}
+	return func() { <-m.searchSlots }, nil
+}
@@ -0,0 +1,115 @@
+package index
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireSearch_BoundsConcurrency(t *testing.T) {
+	t.Setenv("SEARCH_MAX_CONCURRENT", "2")
+	t.Setenv("SEARCH_WHEN_BUSY", "")
+	m := newTestManager(t, 3)
+
+	var inFlight, maxInFlight int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := m.acquireSearch()
+			if err != nil {
+				t.Errorf("acquireSearch() failed: %v", err)
+				return
+			}
+			defer release()
+			current := atomic.AddInt32(&inFlight, 1)
+			for {
+				observed := atomic.LoadInt32(&maxInFlight)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent searches, but got %d", maxInFlight)
+	}
+}
+
+func TestSearch_QueuesWhenSaturated(t *testing.T) {
+	t.Setenv("SEARCH_MAX_CONCURRENT", "1")
+	t.Setenv("SEARCH_WHEN_BUSY", "queue")
+	m := newTestManager(t, 3)
+	if err := m.Add("asset-a", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// Hold the only slot, as a long-running search would
+	release, err := m.acquireSearch()
+	if err != nil {
+		t.Fatalf("acquireSearch() failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := m.Search([]float32{1, 0, 0}, 1)
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatalf("Expected the search to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the queued search to succeed, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the queued search to run once the slot was released")
+	}
+}
+
+func TestSearch_RejectsWhenSaturated(t *testing.T) {
+	t.Setenv("SEARCH_MAX_CONCURRENT", "1")
+	t.Setenv("SEARCH_WHEN_BUSY", "reject")
+	m := newTestManager(t, 3)
+	if err := m.Add("asset-a", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	release, err := m.acquireSearch()
+	if err != nil {
+		t.Fatalf("acquireSearch() failed: %v", err)
+	}
+	if _, _, err := m.Search([]float32{1, 0, 0}, 1); !errors.Is(err, ErrSearchBusy) {
+		t.Errorf("Expected ErrSearchBusy while saturated, but got %v", err)
+	}
+
+	release()
+	if _, assetIDs, err := m.Search([]float32{1, 0, 0}, 1); err != nil || len(assetIDs) != 1 {
+		t.Errorf("Expected a result once a slot is free, but got %v (%v)", assetIDs, err)
+	}
+}
+
+func TestSearch_UnboundedByDefault(t *testing.T) {
+	t.Setenv("SEARCH_MAX_CONCURRENT", "")
+	t.Setenv("SEARCH_WHEN_BUSY", "reject")
+	m := newTestManager(t, 3)
+
+	// Without a limit no slot is ever taken, so searches are never rejected
+	for i := 0; i < 3; i++ {
+		if _, err := m.acquireSearch(); err != nil {
+			t.Fatalf("Expected no limit by default, but got %v", err)
+		}
+	}
+}
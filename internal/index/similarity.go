@@ -0,0 +1,105 @@
+package index
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Similarity labels, from the closest match to the furthest
+const (
+	SimilarityIdentical   = "identical"
+	SimilarityVerySimilar = "very_similar"
+	SimilaritySimilar     = "similar"
+	SimilarityDifferent   = "different"
+)
+
+// SimilarityBands are the largest search distances labelled identical, very
+// similar and similar; anything further is different. Distances are the squared
+// L2 distances the index returns.
+type SimilarityBands struct {
+	Identical   float32
+	VerySimilar float32
+	Similar     float32
+}
+
+// Default bands per metric. Cosine distances are between unit vectors, where a
+// distance d is a cosine similarity of 1 - d/2: identical is about 0.99, very
+// similar 0.95 (the duplicate threshold) and similar 0.8. Raw l2 embeddings are not
+// unit length, so their bands are wider.
+var defaultSimilarityBands = map[string]SimilarityBands{
+	MetricCosine: {Identical: 0.02, VerySimilar: 0.1, Similar: 0.4},
+	MetricL2:     {Identical: 0.05, VerySimilar: 0.25, Similar: 0.8},
+}
+
+// DefaultSimilarityBands returns the bands used for a metric when none are configured
+func DefaultSimilarityBands(metric string) SimilarityBands {
+	if bands, ok := defaultSimilarityBands[metric]; ok {
+		return bands
+	}
+	return defaultSimilarityBands[MetricL2]
+}
+
+// ParseSimilarityBands parses three comma-separated, increasing distances: the
+// upper bounds of identical, very similar and similar, such as "0.02,0.1,0.4"
+func ParseSimilarityBands(spec string) (SimilarityBands, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) != 3 {
+		return SimilarityBands{}, fmt.Errorf("expected 3 distances, got %d", len(parts))
+	}
+	var bounds [3]float32
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil || value < 0 {
+			return SimilarityBands{}, fmt.Errorf("%q is not a non-negative distance", strings.TrimSpace(part))
+		}
+		if i > 0 && float32(value) <= bounds[i-1] {
+			return SimilarityBands{}, fmt.Errorf("distances must increase, got %v after %v", value, bounds[i-1])
+		}
+		bounds[i] = float32(value)
+	}
+	return SimilarityBands{Identical: bounds[0], VerySimilar: bounds[1], Similar: bounds[2]}, nil
+}
+
+// SimilarityBandsFromEnv returns the bands in SIMILARITY_BANDS, or the defaults for
+// the configured INDEX_METRIC
+func SimilarityBandsFromEnv() SimilarityBands {
+	return SimilarityBandsFor(Metric())
+}
+
+// SimilarityBandsFor returns the bands for distances measured with a metric.
+// SIMILARITY_BANDS applies to the configured INDEX_METRIC only; other metrics
+// always use their defaults.
+func SimilarityBandsFor(metric string) SimilarityBands {
+	defaults := DefaultSimilarityBands(metric)
+	spec := os.Getenv("SIMILARITY_BANDS")
+	if strings.TrimSpace(spec) == "" || metric != Metric() {
+		return defaults
+	}
+	bands, err := ParseSimilarityBands(spec)
+	if err != nil {
+		log.Printf("Invalid SIMILARITY_BANDS %q (%v), using defaults of %v,%v,%v", spec, err, defaults.Identical, defaults.VerySimilar, defaults.Similar)
+		return defaults
+	}
+	return bands
+}
+
+// Label returns the similarity label of a search distance
+func (b SimilarityBands) Label(distance float32) string {
+	switch {
+	case distance <= b.Identical:
+		return SimilarityIdentical
+	case distance <= b.VerySimilar:
+		return SimilarityVerySimilar
+	case distance <= b.Similar:
+		return SimilaritySimilar
+	}
+	return SimilarityDifferent
+}
+
+// SimilarityLabel labels a search distance with the configured bands
+func SimilarityLabel(distance float32) string {
+	return SimilarityBandsFromEnv().Label(distance)
+}
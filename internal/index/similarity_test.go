@@ -0,0 +1,71 @@
+package index
+
+import "testing"
+
+func TestSimilarityLabel(t *testing.T) {
+	testCases := []struct {
+		name     string
+		metric   string
+		bands    string
+		distance float32
+		expected string
+	}{
+		{name: "Cosine exact match", metric: MetricCosine, distance: 0, expected: SimilarityIdentical},
+		{name: "Cosine re-encoded copy", metric: MetricCosine, distance: 0.015, expected: SimilarityIdentical},
+		{name: "Cosine duplicate threshold", metric: MetricCosine, distance: 0.1, expected: SimilarityVerySimilar},
+		{name: "Cosine same scene", metric: MetricCosine, distance: 0.3, expected: SimilaritySimilar},
+		{name: "Cosine unrelated", metric: MetricCosine, distance: 0.9, expected: SimilarityDifferent},
+		{name: "L2 near match", metric: MetricL2, distance: 0.2, expected: SimilarityVerySimilar},
+		{name: "L2 distant", metric: MetricL2, distance: 1.5, expected: SimilarityDifferent},
+		{name: "Configured bands", metric: MetricCosine, bands: "0.01, 0.05, 0.2", distance: 0.1, expected: SimilaritySimilar},
+		{name: "Invalid bands use defaults", metric: MetricCosine, bands: "0.2,0.1,0.4", distance: 0.1, expected: SimilarityVerySimilar},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("INDEX_METRIC", tc.metric)
+			t.Setenv("SIMILARITY_BANDS", tc.bands)
+			if got := SimilarityLabel(tc.distance); got != tc.expected {
+				t.Errorf("Expected %q for distance %v, but got %q", tc.expected, tc.distance, got)
+			}
+		})
+	}
+}
+
+func TestParseSimilarityBands(t *testing.T) {
+	testCases := []struct {
+		name          string
+		spec          string
+		expected      SimilarityBands
+		expectedError bool
+	}{
+		{name: "Valid", spec: "0.02,0.1,0.4", expected: SimilarityBands{Identical: 0.02, VerySimilar: 0.1, Similar: 0.4}},
+		{name: "Too few", spec: "0.1,0.4", expectedError: true},
+		{name: "Not a number", spec: "0.02,close,0.4", expectedError: true},
+		{name: "Negative", spec: "-0.1,0.1,0.4", expectedError: true},
+		{name: "Not increasing", spec: "0.1,0.1,0.4", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bands, err := ParseSimilarityBands(tc.spec)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("Expected an error, but got %+v", bands)
+				}
+				return
+			}
+			if err != nil || bands != tc.expected {
+				t.Errorf("Expected %+v, but got %+v (err %v)", tc.expected, bands, err)
+			}
+		})
+	}
+}
+
+func TestSimilarityBandsFor_OtherMetric(t *testing.T) {
+	t.Setenv("INDEX_METRIC", MetricL2)
+	t.Setenv("SIMILARITY_BANDS", "0.01,0.05,0.2")
+	if bands := SimilarityBandsFor(MetricCosine); bands != DefaultSimilarityBands(MetricCosine) {
+		t.Errorf("Expected the cosine defaults while l2 is configured, but got %+v", bands)
+	}
+}
@@ -0,0 +1,487 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/DataIntelligenceCrew/go-faiss"
+	"google.golang.org/api/iterator"
+)
+
+// Snapshot layout in the index bucket. Every save writes a new versioned object
+// under SnapshotPrefix and then moves the LatestPointer to it, so a bad build never
+// overwrites the only copy and can be rolled back by moving the pointer. Each
+// snapshot's labels are stored next to it, named with labelsSuffix in place of
+// snapshotSuffix.
+const (
+	SnapshotPrefix = "index/"
+	LatestPointer  = SnapshotPrefix + "latest"
+	// LegacyObject is the single overwritten object used before snapshots existed.
+	// It is still loaded when no pointer has been written yet.
+	LegacyObject = "latest.faiss"
+
+	snapshotSuffix     = ".faiss"
+	labelsSuffix       = ".labels.json"
+	snapshotTimeFormat = "20060102T150405.000000000Z"
+	defaultSnapshots   = 5
+)
+
+// ErrObjectNotFound is returned by an ObjectStore when the named object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrSnapshotNotFound is returned when loading finds no snapshot with its labels
+var ErrSnapshotNotFound = errors.New("index snapshot not found")
+
+// ErrUnknownSnapshot is returned when rolling back to a snapshot that is not stored
+var ErrUnknownSnapshot = errors.New("unknown index snapshot")
+
+// ErrSaveInProgress is returned by a save skipped because another save of the same
+// index is still running (INDEX_CONCURRENT_SAVE=skip)
+var ErrSaveInProgress = errors.New("index save already in progress")
+
+// Values of INDEX_CONCURRENT_SAVE
+const (
+	// SaveModeQueue makes a save wait for the one in progress, then run
+	SaveModeQueue = "queue"
+	// SaveModeSkip makes a save return ErrSaveInProgress instead of waiting
+	SaveModeSkip = "skip"
+)
+
+// concurrentSaveMode returns INDEX_CONCURRENT_SAVE, defaulting to SaveModeQueue
+func concurrentSaveMode() string {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv("INDEX_CONCURRENT_SAVE"))); value {
+	case "", SaveModeQueue:
+		return SaveModeQueue
+	case SaveModeSkip:
+		return SaveModeSkip
+	default:
+		log.Printf("Invalid INDEX_CONCURRENT_SAVE %q, using default of %s", value, SaveModeQueue)
+		return SaveModeQueue
+	}
+}
+
+// Values of INDEX_LOAD_MODE
+const (
+	// LoadModeMemory reads the whole index into RAM
+	LoadModeMemory = "memory"
+	// LoadModeMmap memory-maps the index file (FAISS IO_FLAG_MMAP), so the kernel
+	// pages vectors in as searches touch them and the index can exceed available RAM
+	LoadModeMmap = "mmap"
+)
+
+// loadMode returns INDEX_LOAD_MODE, defaulting to LoadModeMemory
+func loadMode() string {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv("INDEX_LOAD_MODE"))); value {
+	case "", LoadModeMemory:
+		return LoadModeMemory
+	case LoadModeMmap:
+		return LoadModeMmap
+	default:
+		log.Printf("Invalid INDEX_LOAD_MODE %q, using default of %s", value, LoadModeMemory)
+		return LoadModeMemory
+	}
+}
+
+// mmapDir returns INDEX_MMAP_DIR, where memory-mapped index files are kept while
+// loaded, defaulting to the temporary directory
+func mmapDir() string {
+	if dir := os.Getenv("INDEX_MMAP_DIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+// snapshotTime stamps new snapshots; tests replace it to control version names
+var snapshotTime = time.Now
+
+// ObjectStore is the subset of bucket operations needed to keep index snapshots
+type ObjectStore interface {
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	Put(ctx context.Context, name string, r io.Reader) error
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// SnapshotsToKeep returns how many snapshots a save retains, from INDEX_SNAPSHOTS_TO_KEEP
+func SnapshotsToKeep() int {
+	return envInt("INDEX_SNAPSHOTS_TO_KEEP", defaultSnapshots)
+}
+
+// snapshotName returns the object name for a snapshot taken at t
+func snapshotName(t time.Time) string {
+	return SnapshotPrefix + t.UTC().Format(snapshotTimeFormat) + snapshotSuffix
+}
+
+// labelsName returns the object name of the labels saved with a snapshot
+func labelsName(snapshot string) string {
+	return strings.TrimSuffix(snapshot, snapshotSuffix) + labelsSuffix
+}
+
+// snapshotLabels is what a snapshot needs besides the FAISS vectors to serve
+// search results: the asset ID of each label, the labels of removed assets and
+// the creation times used for recency ranking
+type snapshotLabels struct {
+	IDs       map[int64]string     `json:"ids"`
+	Removed   []int64              `json:"removed,omitempty"`
+	CreatedAt map[string]time.Time `json:"created_at,omitempty"`
+}
+
+// ListSnapshots returns the stored snapshot object names, oldest first
+func ListSnapshots(ctx context.Context, store ObjectStore) ([]string, error) {
+	names, err := store.List(ctx, SnapshotPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list index snapshots: %v", err)
+	}
+	var snapshots []string
+	for _, name := range names {
+		if strings.HasSuffix(name, snapshotSuffix) {
+			snapshots = append(snapshots, name)
+		}
+	}
+	// Timestamps are fixed width, so lexical order is chronological order
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// CurrentSnapshot returns the snapshot the latest pointer refers to, or "" when no
+// pointer has been written
+func CurrentSnapshot(ctx context.Context, store ObjectStore) (string, error) {
+	reader, err := store.Get(ctx, LatestPointer)
+	if errors.Is(err, ErrObjectNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read index pointer: %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read index pointer: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetCurrentSnapshot moves the latest pointer to an existing snapshot. It is how a
+// rollback is performed.
+func SetCurrentSnapshot(ctx context.Context, store ObjectStore, name string) error {
+	snapshots, err := ListSnapshots(ctx, store)
+	if err != nil {
+		return err
+	}
+	i := sort.SearchStrings(snapshots, name)
+	if i == len(snapshots) || snapshots[i] != name {
+		return fmt.Errorf("%w: %s", ErrUnknownSnapshot, name)
+	}
+	if err := store.Put(ctx, LatestPointer, strings.NewReader(name)); err != nil {
+		return fmt.Errorf("failed to update index pointer: %v", err)
+	}
+	return nil
+}
+
+// RollbackSnapshot moves the latest pointer to the snapshot named to, or, when to is
+// empty, to the snapshot saved immediately before the current one. It returns the
+// snapshot now in use.
+func RollbackSnapshot(ctx context.Context, store ObjectStore, to string) (string, error) {
+	if to == "" {
+		snapshots, err := ListSnapshots(ctx, store)
+		if err != nil {
+			return "", err
+		}
+		current, err := CurrentSnapshot(ctx, store)
+		if err != nil {
+			return "", err
+		}
+		i := sort.SearchStrings(snapshots, current)
+		if i == len(snapshots) || snapshots[i] != current {
+			return "", fmt.Errorf("%w: current snapshot %q is not stored", ErrUnknownSnapshot, current)
+		}
+		if i == 0 {
+			return "", fmt.Errorf("%w: no snapshot older than %s", ErrUnknownSnapshot, current)
+		}
+		to = snapshots[i-1]
+	}
+	if err := SetCurrentSnapshot(ctx, store, to); err != nil {
+		return "", err
+	}
+	return to, nil
+}
+
+// SaveSnapshot writes the index as a new versioned snapshot, points latest at it and
+// prunes the oldest snapshots so that at most keep remain. The snapshot the pointer
+// refers to is never pruned.
+//
+// Only one save runs at a time per manager, so a periodic save and a rebuild cannot
+// interleave their uploads and pointer updates. A save started while another is in
+// progress waits for it, or with INDEX_CONCURRENT_SAVE=skip returns
+// ErrSaveInProgress. The index is read-locked only while it is serialized, not
+// during the upload.
+func (m *IndexManager) SaveSnapshot(ctx context.Context, store ObjectStore, keep int) (string, error) {
+	if concurrentSaveMode() == SaveModeSkip {
+		if !m.saveMu.TryLock() {
+			return "", ErrSaveInProgress
+		}
+	} else {
+		m.saveMu.Lock()
+	}
+	defer m.saveMu.Unlock()
+
+	data, labels, err := m.serialize()
+	if err != nil {
+		return "", err
+	}
+
+	// The labels go first, so a snapshot is never live without them
+	name := snapshotName(snapshotTime())
+	if err := store.Put(ctx, labelsName(name), bytes.NewReader(labels)); err != nil {
+		return "", fmt.Errorf("failed to upload index labels %s: %v", labelsName(name), err)
+	}
+	if err := store.Put(ctx, name, bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("failed to upload index snapshot %s: %v", name, err)
+	}
+	if err := store.Put(ctx, LatestPointer, strings.NewReader(name)); err != nil {
+		return "", fmt.Errorf("failed to update index pointer: %v", err)
+	}
+
+	if err := pruneSnapshots(ctx, store, name, keep); err != nil {
+		// The new snapshot is already live; old copies can be cleaned up next time
+		log.Printf("Failed to prune old index snapshots: %v", err)
+	}
+	return name, nil
+}
+
+// serialize writes the index and its labels to bytes, holding the read lock so
+// that no vector is added while they are written and the two always agree
+func (m *IndexManager) serialize() (data, labels []byte, err error) {
+	tempFile, err := os.CreateTemp("", "faiss_index_save_*.bin")
+	if err != nil {
+		return nil, nil, err
+	}
+	tempFileName := tempFile.Name()
+	defer os.Remove(tempFileName)
+	tempFile.Close()
+
+	m.mu.RLock()
+	if m.index == nil {
+		m.mu.RUnlock()
+		return nil, nil, errors.New("no index to save: index is nil")
+	}
+	err = faiss.WriteIndex(m.index, tempFileName)
+	if err == nil {
+		labels, err = json.Marshal(m.labelsLocked())
+	}
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = os.ReadFile(tempFileName)
+	return data, labels, err
+}
+
+// labelsLocked returns the manager's labels. Callers must hold m.mu.
+func (m *IndexManager) labelsLocked() snapshotLabels {
+	labels := snapshotLabels{IDs: m.idMap, CreatedAt: m.createdAt}
+	if labels.IDs == nil {
+		labels.IDs = map[int64]string{}
+	}
+	for label := range m.removed {
+		labels.Removed = append(labels.Removed, label)
+	}
+	sort.Slice(labels.Removed, func(i, j int) bool { return labels.Removed[i] < labels.Removed[j] })
+	return labels
+}
+
+// readLabels reads the labels saved with a snapshot
+func readLabels(ctx context.Context, store ObjectStore, snapshot string) (*snapshotLabels, error) {
+	reader, err := store.Get(ctx, labelsName(snapshot))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var labels snapshotLabels
+	if err := json.NewDecoder(reader).Decode(&labels); err != nil {
+		return nil, fmt.Errorf("failed to decode index labels %s: %v", labelsName(snapshot), err)
+	}
+	return &labels, nil
+}
+
+// pruneSnapshots deletes the oldest snapshots beyond keep, sparing current
+func pruneSnapshots(ctx context.Context, store ObjectStore, current string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	snapshots, err := ListSnapshots(ctx, store)
+	if err != nil {
+		return err
+	}
+	for len(snapshots) > keep {
+		oldest := snapshots[0]
+		snapshots = snapshots[1:]
+		if oldest == current {
+			continue
+		}
+		for _, name := range []string{oldest, labelsName(oldest)} {
+			if err := store.Delete(ctx, name); err != nil && !errors.Is(err, ErrObjectNotFound) {
+				return fmt.Errorf("failed to delete %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadSnapshot loads the snapshot the latest pointer refers to together with its
+// labels, replacing the index, its asset IDs, removed assets and creation times at
+// once. Without a pointer it falls back to LegacyObject; if neither exists, or the
+// snapshot was saved without labels and so cannot name its results, the manager is
+// left unchanged and ErrSnapshotNotFound is returned so the caller can build one.
+// Any other failure also leaves the manager unchanged. With INDEX_LOAD_MODE=mmap the
+// downloaded file is memory-mapped from INDEX_MMAP_DIR instead of read into RAM, and
+// kept there until the index is replaced.
+func (m *IndexManager) LoadSnapshot(ctx context.Context, store ObjectStore) error {
+	name, err := CurrentSnapshot(ctx, store)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = LegacyObject
+	}
+
+	labels, err := readLabels(ctx, store, name)
+	if errors.Is(err, ErrObjectNotFound) {
+		return fmt.Errorf("%w: %s or its labels", ErrSnapshotNotFound, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	reader, err := store.Get(ctx, name)
+	if errors.Is(err, ErrObjectNotFound) {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	mode, dir, ioflags := loadMode(), "", 0
+	if mode == LoadModeMmap {
+		// No IOFlagReadOnly: processed assets are still added to the loaded index
+		dir, ioflags = mmapDir(), faiss.IOFlagMmap
+	}
+	tempFile, err := os.CreateTemp(dir, "faiss_index_*.bin")
+	if err != nil {
+		return err
+	}
+	mapped := false
+	defer func() {
+		if !mapped {
+			os.Remove(tempFile.Name())
+		}
+	}()
+	defer tempFile.Close()
+
+	// The temporary file is removed on every return, including a canceled copy
+	if _, err := io.Copy(tempFile, contextReader{ctx: ctx, reader: reader}); err != nil {
+		return err
+	}
+	tempFile.Close()
+
+	loadedIndex, err := faiss.ReadIndex(tempFile.Name(), ioflags)
+	if err != nil {
+		return err
+	}
+
+	removed := make(map[int64]bool, len(labels.Removed))
+	for _, label := range labels.Removed {
+		removed[label] = true
+	}
+
+	m.mu.Lock()
+	m.index = loadedIndex
+	m.idMap = labels.IDs
+	m.removed = removed
+	m.createdAt = labels.CreatedAt
+	// The snapshot's vectors are read from the vector source when searched by ID
+	m.vectors = nil
+	previous := m.mappedFile
+	m.mappedFile = ""
+	if mode == LoadModeMmap {
+		// The mapping reads from the file for as long as the index is loaded
+		m.mappedFile, mapped = tempFile.Name(), true
+	}
+	m.mu.Unlock()
+	removeMappedFile(previous)
+
+	log.Printf("Loaded index snapshot %s (%s)", name, mode)
+	return nil
+}
+
+// removeMappedFile deletes the file a replaced index was memory-mapped from. The
+// manager's lock has already been released by every search of that index, and an
+// unlinked file stays readable to any mapping that remains.
+func removeMappedFile(name string) {
+	if name == "" {
+		return
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove memory-mapped index file %s: %v", name, err)
+	}
+}
+
+// GCSStore is an ObjectStore backed by a Google Cloud Storage bucket
+type GCSStore struct {
+	Client *storage.Client
+	Bucket string
+}
+
+func (s GCSStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	reader, err := s.Client.Bucket(s.Bucket).Object(name).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, ErrObjectNotFound
+	}
+	return reader, err
+}
+
+func (s GCSStore) Put(ctx context.Context, name string, r io.Reader) error {
+	writer := s.Client.Bucket(s.Bucket).Object(name).NewWriter(ctx)
+	if _, err := io.Copy(writer, r); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+func (s GCSStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	it := s.Client.Bucket(s.Bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, attrs.Name)
+	}
+	return names, nil
+}
+
+func (s GCSStore) Delete(ctx context.Context, name string) error {
+	err := s.Client.Bucket(s.Bucket).Object(name).Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return ErrObjectNotFound
+	}
+	return err
+}
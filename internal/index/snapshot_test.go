@@ -0,0 +1,515 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory ObjectStore
+type memoryStore struct {
+	objects map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: map[string][]byte{}}
+}
+
+func (s *memoryStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	data, ok := s.objects[name]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.objects[name] = data
+	return nil
+}
+
+func (s *memoryStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	for name := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, name string) error {
+	if _, ok := s.objects[name]; !ok {
+		return ErrObjectNotFound
+	}
+	delete(s.objects, name)
+	return nil
+}
+
+// useSnapshotClock makes each snapshot one minute newer than the last
+func useSnapshotClock(t *testing.T) {
+	t.Helper()
+	original := snapshotTime
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	snapshotTime = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	t.Cleanup(func() { snapshotTime = original })
+}
+
+func TestSaveSnapshot_CreatesVersionAndMovesPointer(t *testing.T) {
+	useSnapshotClock(t)
+	ctx := context.Background()
+	store := newMemoryStore()
+	m := newTestManager(t, 3)
+	if err := m.Add("asset-a", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	first, err := m.SaveSnapshot(ctx, store, 5)
+	if err != nil {
+		t.Fatalf("SaveSnapshot() failed: %v", err)
+	}
+	if err := m.Add("asset-b", []float32{0, 1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	second, err := m.SaveSnapshot(ctx, store, 5)
+	if err != nil {
+		t.Fatalf("SaveSnapshot() failed: %v", err)
+	}
+
+	if first == second || !strings.HasPrefix(second, SnapshotPrefix) || !strings.HasSuffix(second, ".faiss") {
+		t.Fatalf("Expected two distinct versioned snapshots, but got %s and %s", first, second)
+	}
+	current, err := CurrentSnapshot(ctx, store)
+	if err != nil || current != second {
+		t.Errorf("Expected pointer to %s, but got %s (err %v)", second, current, err)
+	}
+	snapshots, _ := ListSnapshots(ctx, store)
+	if !reflect.DeepEqual(snapshots, []string{first, second}) {
+		t.Errorf("Expected prior snapshot to be kept, but got %v", snapshots)
+	}
+	if bytes.Equal(store.objects[first], store.objects[second]) {
+		t.Errorf("Expected the new snapshot to hold the updated index")
+	}
+
+	// Load follows the pointer
+	loaded := &IndexManager{}
+	if err := loaded.LoadSnapshot(ctx, store); err != nil {
+		t.Fatalf("LoadSnapshot() failed: %v", err)
+	}
+	if !loaded.HasIndex() || loaded.index.Ntotal() != 2 {
+		t.Errorf("Expected the latest snapshot with 2 vectors to be loaded")
+	}
+}
+
+func TestSaveSnapshot_PrunesBeyondKeep(t *testing.T) {
+	useSnapshotClock(t)
+	ctx := context.Background()
+	store := newMemoryStore()
+	m := newTestManager(t, 3)
+
+	var saved []string
+	for i := 0; i < 4; i++ {
+		name, err := m.SaveSnapshot(ctx, store, 2)
+		if err != nil {
+			t.Fatalf("SaveSnapshot() failed: %v", err)
+		}
+		saved = append(saved, name)
+	}
+
+	snapshots, _ := ListSnapshots(ctx, store)
+	if !reflect.DeepEqual(snapshots, saved[2:]) {
+		t.Errorf("Expected only the last 2 snapshots, but got %v", snapshots)
+	}
+}
+
+func TestRollbackSnapshot(t *testing.T) {
+	useSnapshotClock(t)
+	ctx := context.Background()
+	store := newMemoryStore()
+	m := newTestManager(t, 3)
+
+	first, _ := m.SaveSnapshot(ctx, store, 5)
+	second, _ := m.SaveSnapshot(ctx, store, 5)
+	third, _ := m.SaveSnapshot(ctx, store, 5)
+
+	testCases := []struct {
+		name        string
+		to          string
+		start       string
+		expected    string
+		expectedErr error
+	}{
+		{name: "Previous snapshot", start: third, expected: second},
+		{name: "Named snapshot", to: first, start: third, expected: first},
+		{name: "Unknown snapshot", to: "index/missing.faiss", start: third, expectedErr: ErrUnknownSnapshot},
+		{name: "Nothing older", start: first, expectedErr: ErrUnknownSnapshot},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := SetCurrentSnapshot(ctx, store, tc.start); err != nil {
+				t.Fatalf("SetCurrentSnapshot() failed: %v", err)
+			}
+
+			got, err := RollbackSnapshot(ctx, store, tc.to)
+			if tc.expectedErr != nil {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Fatalf("Expected %v, but got %v", tc.expectedErr, err)
+				}
+				if current, _ := CurrentSnapshot(ctx, store); current != tc.start {
+					t.Errorf("Expected pointer to stay at %s, but got %s", tc.start, current)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RollbackSnapshot() failed: %v", err)
+			}
+			if current, _ := CurrentSnapshot(ctx, store); got != tc.expected || current != tc.expected {
+				t.Errorf("Expected pointer at %s, but got %s (returned %s)", tc.expected, current, got)
+			}
+		})
+	}
+
+	// Rolling back never deletes snapshots
+	if after, _ := ListSnapshots(ctx, store); len(after) != 3 {
+		t.Errorf("Expected 3 snapshots after rollback, but got %v", after)
+	}
+}
+
+func TestLoadSnapshot_FallsBackToLegacyObject(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+
+	// No pointer and no legacy object: nothing to load
+	m := &IndexManager{}
+	if err := m.LoadSnapshot(ctx, store); !errors.Is(err, ErrSnapshotNotFound) || m.HasIndex() {
+		t.Fatalf("Expected no index and ErrSnapshotNotFound, but got err %v", err)
+	}
+
+	// A legacy latest.faiss is loaded when no pointer exists
+	source := newTestManager(t, 3)
+	source.Add("asset-a", []float32{1, 0, 0})
+	name, err := source.SaveSnapshot(ctx, store, 5)
+	if err != nil {
+		t.Fatalf("SaveSnapshot() failed: %v", err)
+	}
+	store.objects[LegacyObject] = store.objects[name]
+	delete(store.objects, name)
+	delete(store.objects, LatestPointer)
+
+	// Without its labels the index could not name its results, so it is not used
+	if err := m.LoadSnapshot(ctx, store); !errors.Is(err, ErrSnapshotNotFound) || m.HasIndex() {
+		t.Fatalf("Expected a legacy index without labels to be ignored, but got err %v", err)
+	}
+
+	store.objects[labelsName(LegacyObject)] = store.objects[labelsName(name)]
+	if err := m.LoadSnapshot(ctx, store); err != nil {
+		t.Fatalf("LoadSnapshot() failed: %v", err)
+	}
+	if !m.HasIndex() {
+		t.Errorf("Expected the legacy index to be loaded")
+	}
+	if _, assetIDs, err := m.Search([]float32{1, 0, 0}, 1); err != nil || len(assetIDs) != 1 || assetIDs[0] != "asset-a" {
+		t.Errorf("Expected the legacy index to find asset-a, but got %v (err %v)", assetIDs, err)
+	}
+}
+
+func TestLoadSnapshot_RestoresLabels(t *testing.T) {
+	useSnapshotClock(t)
+	ctx := context.Background()
+	store := newMemoryStore()
+	source := newTestManager(t, 3)
+	for id, vector := range map[string][]float32{"asset-a": {1, 0, 0}, "asset-b": {0, 1, 0}, "asset-c": {0, 0, 1}} {
+		if err := source.Add(id, vector); err != nil {
+			t.Fatalf("Add(%s) failed: %v", id, err)
+		}
+	}
+	source.Remove("asset-c")
+	if _, err := source.SaveSnapshot(ctx, store, 5); err != nil {
+		t.Fatalf("SaveSnapshot() failed: %v", err)
+	}
+
+	// A manager holding another index has its index and labels replaced together
+	m := newTestManager(t, 3)
+	if err := m.Add("stale", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := m.LoadSnapshot(ctx, store); err != nil {
+		t.Fatalf("LoadSnapshot() failed: %v", err)
+	}
+
+	if ntotal, mapped := m.Stats(); ntotal != 3 || mapped != 3 {
+		t.Errorf("Expected 3 vectors and 3 labels, but got %d and %d", ntotal, mapped)
+	}
+	for query, expected := range map[string][]float32{"asset-a": {1, 0, 0}, "asset-b": {0, 1, 0}} {
+		if _, assetIDs, err := m.Search(expected, 1); err != nil || len(assetIDs) != 1 || assetIDs[0] != query {
+			t.Errorf("Expected %s to match itself, but got %v (err %v)", query, assetIDs, err)
+		}
+	}
+	_, assetIDs, _ := m.Search([]float32{0, 0, 1}, 3)
+	if !reflect.DeepEqual(assetIDs, []string{"asset-a", "asset-b"}) {
+		t.Errorf("Expected the removed asset to stay excluded, but got %v", assetIDs)
+	}
+	if len(m.createdAt) != len(source.createdAt) {
+		t.Errorf("Expected creation times %v, but got %v", source.createdAt, m.createdAt)
+	}
+	for id, createdAt := range source.createdAt {
+		if !m.createdAt[id].Equal(createdAt) {
+			t.Errorf("Expected %s to have been created at %v, but got %v", id, createdAt, m.createdAt[id])
+		}
+	}
+	if m.vectors != nil {
+		t.Errorf("Expected no cached vectors from the replaced index, but got %v", m.vectors)
+	}
+}
+
+func TestLoadSnapshot_MmapMatchesMemory(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	source := newTestManager(t, 4)
+	vectors := map[string][]float32{
+		"asset-a": {1, 0, 0, 0},
+		"asset-b": {0, 1, 0, 0},
+		"asset-c": {0, 0, 1, 0},
+		"asset-d": {0.9, 0.1, 0, 0},
+		"asset-e": {0.5, 0.5, 0.5, 0.5},
+	}
+	ids := make([]string, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := source.Add(id, vectors[id]); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if _, err := source.SaveSnapshot(ctx, store, 5); err != nil {
+		t.Fatalf("SaveSnapshot() failed: %v", err)
+	}
+
+	load := func(mode string) *IndexManager {
+		t.Setenv("INDEX_LOAD_MODE", mode)
+		m := &IndexManager{}
+		if err := m.LoadSnapshot(ctx, store); err != nil {
+			t.Fatalf("LoadSnapshot() in %s mode failed: %v", mode, err)
+		}
+		return m
+	}
+	dir := t.TempDir()
+	t.Setenv("INDEX_MMAP_DIR", dir)
+	inMemory, mapped := load(LoadModeMemory), load(LoadModeMmap)
+
+	if inMemory.mappedFile != "" {
+		t.Errorf("Expected no mapped file in memory mode, but got %s", inMemory.mappedFile)
+	}
+	if mapped.mappedFile == "" || !strings.HasPrefix(mapped.mappedFile, dir) {
+		t.Fatalf("Expected the mapped file to be kept in INDEX_MMAP_DIR %s, but got %q", dir, mapped.mappedFile)
+	}
+
+	for _, query := range [][]float32{{1, 0, 0, 0}, {0, 0.7, 0.7, 0}, {0.4, 0.6, 0.5, 0.5}} {
+		memoryDistances, memoryIDs, err := inMemory.Search(query, 3)
+		if err != nil {
+			t.Fatalf("Search() in memory mode failed: %v", err)
+		}
+		mappedDistances, mappedIDs, err := mapped.Search(query, 3)
+		if err != nil {
+			t.Fatalf("Search() in mmap mode failed: %v", err)
+		}
+		if len(memoryIDs) != 3 || !reflect.DeepEqual(mappedIDs, memoryIDs) || !reflect.DeepEqual(mappedDistances, memoryDistances) {
+			t.Errorf("Expected the same results for %v, but got %v %v in memory and %v %v memory-mapped", query, memoryIDs, memoryDistances, mappedIDs, mappedDistances)
+		}
+	}
+
+	// Replacing the mapped index removes its file
+	mappedFile := mapped.mappedFile
+	t.Setenv("INDEX_LOAD_MODE", LoadModeMemory)
+	if err := mapped.LoadSnapshot(ctx, store); err != nil {
+		t.Fatalf("LoadSnapshot() failed: %v", err)
+	}
+	if _, err := os.Stat(mappedFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the replaced mapped file to be removed, but got %v", err)
+	}
+}
+
+func TestLoadMode(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{value: "", expected: LoadModeMemory},
+		{value: "memory", expected: LoadModeMemory},
+		{value: " MMAP ", expected: LoadModeMmap},
+		{value: "disk", expected: LoadModeMemory},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv("INDEX_LOAD_MODE", tc.value)
+			if got := loadMode(); got != tc.expected {
+				t.Errorf("Expected load mode %s, but got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+// blockingStore is a goroutine-safe memoryStore whose snapshot uploads wait on
+// release, and which records how many uploads overlap
+type blockingStore struct {
+	mu        sync.Mutex
+	store     *memoryStore
+	release   chan struct{}
+	uploading chan struct{}
+	active    int
+	maxActive int
+}
+
+func (s *blockingStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Get(ctx, name)
+}
+
+func (s *blockingStore) Put(ctx context.Context, name string, r io.Reader) error {
+	if strings.HasSuffix(name, snapshotSuffix) {
+		s.mu.Lock()
+		s.active++
+		if s.active > s.maxActive {
+			s.maxActive = s.active
+		}
+		s.mu.Unlock()
+		s.uploading <- struct{}{}
+		<-s.release
+		defer func() {
+			s.mu.Lock()
+			s.active--
+			s.mu.Unlock()
+		}()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Put(ctx, name, r)
+}
+
+func (s *blockingStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.List(ctx, prefix)
+}
+
+func (s *blockingStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Delete(ctx, name)
+}
+
+func newBlockingStore() *blockingStore {
+	return &blockingStore{store: newMemoryStore(), release: make(chan struct{}), uploading: make(chan struct{}, 16)}
+}
+
+func TestSaveSnapshot_ConcurrentSavesAreSerialized(t *testing.T) {
+	var mu sync.Mutex
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	original := snapshotTime
+	snapshotTime = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	t.Cleanup(func() { snapshotTime = original })
+
+	ctx := context.Background()
+	store := newBlockingStore()
+	m := newTestManager(t, 3)
+	m.Add("asset-a", []float32{1, 0, 0})
+
+	const saves = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, saves)
+	for i := 0; i < saves; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.SaveSnapshot(ctx, store, 10)
+			errs <- err
+		}()
+	}
+
+	// The index stays writable while the first upload is in flight
+	<-store.uploading
+	if err := m.Add("asset-b", []float32{0, 1, 0}); err != nil {
+		t.Fatalf("Add during a save failed: %v", err)
+	}
+	close(store.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("SaveSnapshot() failed: %v", err)
+		}
+	}
+	if store.maxActive != 1 {
+		t.Errorf("Expected uploads to run one at a time, but %d overlapped", store.maxActive)
+	}
+	snapshots, _ := ListSnapshots(ctx, store)
+	if len(snapshots) != saves {
+		t.Fatalf("Expected %d snapshots, but got %v", saves, snapshots)
+	}
+	current, _ := CurrentSnapshot(ctx, store)
+	if current != snapshots[len(snapshots)-1] {
+		t.Errorf("Expected the pointer to name the newest snapshot %s, but got %s", snapshots[len(snapshots)-1], current)
+	}
+
+	loaded := &IndexManager{}
+	if err := loaded.LoadSnapshot(ctx, store); err != nil {
+		t.Fatalf("LoadSnapshot() failed: %v", err)
+	}
+	if total := loaded.index.Ntotal(); total != 2 {
+		t.Errorf("Expected the latest snapshot to hold 2 vectors, but got %d", total)
+	}
+}
+
+func TestSaveSnapshot_SkipsWhileSaveInProgress(t *testing.T) {
+	useSnapshotClock(t)
+	t.Setenv("INDEX_CONCURRENT_SAVE", "skip")
+	ctx := context.Background()
+	store := newBlockingStore()
+	m := newTestManager(t, 3)
+	m.Add("asset-a", []float32{1, 0, 0})
+
+	done := make(chan error)
+	go func() {
+		_, err := m.SaveSnapshot(ctx, store, 5)
+		done <- err
+	}()
+	<-store.uploading
+
+	if _, err := m.SaveSnapshot(ctx, store, 5); !errors.Is(err, ErrSaveInProgress) {
+		t.Errorf("Expected ErrSaveInProgress, but got %v", err)
+	}
+	close(store.release)
+	if err := <-done; err != nil {
+		t.Errorf("SaveSnapshot() failed: %v", err)
+	}
+	if snapshots, _ := ListSnapshots(ctx, store); len(snapshots) != 1 {
+		t.Errorf("Expected only the first save to write a snapshot, but got %v", snapshots)
+	}
+}
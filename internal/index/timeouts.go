@@ -0,0 +1,53 @@
+package index
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Built-in deadlines for the storage round trips of Load, Save and Build,
+// overridable with INDEX_LOAD_TIMEOUT, INDEX_SAVE_TIMEOUT and INDEX_BUILD_TIMEOUT
+const (
+	defaultLoadTimeout  = 2 * time.Minute
+	defaultSaveTimeout  = 2 * time.Minute
+	defaultBuildTimeout = 10 * time.Minute
+)
+
+// envDuration reads a positive duration from the environment, falling back on
+// invalid values
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("Invalid %s %q, using default of %v", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// withTimeout bounds an operation by the timeout configured in the named variable.
+// A deadline already set on ctx is kept when it is the earlier one, and canceling
+// ctx still aborts the operation.
+func withTimeout(ctx context.Context, name string, fallback time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, envDuration(name, fallback))
+}
+
+// contextReader stops a copy once its context is done, so a stalled or canceled
+// download is not written out in full before the caller notices
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
@@ -0,0 +1,121 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// endlessDocuments returns a document source that never runs out, pausing between
+// documents, and counts how many were read
+func endlessDocuments(read *int, pause time.Duration) func() (string, map[string]interface{}, error) {
+	return func() (string, map[string]interface{}, error) {
+		*read++
+		time.Sleep(pause)
+		return "asset", map[string]interface{}{"status": "completed"}, nil
+	}
+}
+
+func TestBuild_CanceledContextAbortsPromptly(t *testing.T) {
+	m := newTestManager(t, 3)
+	if err := m.Add("existing", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	read := 0
+	done := make(chan error, 1)
+	go func() { done <- m.buildFrom(ctx, endlessDocuments(&read, time.Millisecond)) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the build to stop after cancellation")
+	}
+	if _, ids, err := m.Search([]float32{1, 0, 0}, 1); err != nil || len(ids) != 1 || ids[0] != "existing" {
+		t.Errorf("Expected the previous index to be kept, but got %v (err %v)", ids, err)
+	}
+
+	// An already canceled build reads nothing
+	read = 0
+	if err := m.buildFrom(ctx, endlessDocuments(&read, 0)); !errors.Is(err, context.Canceled) || read != 0 {
+		t.Errorf("Expected an immediate abort, but got %v after %d documents", err, read)
+	}
+}
+
+func TestBuild_Timeout(t *testing.T) {
+	t.Setenv("INDEX_BUILD_TIMEOUT", "30ms")
+	ctx, cancel := withTimeout(context.Background(), "INDEX_BUILD_TIMEOUT", defaultBuildTimeout)
+	defer cancel()
+
+	m := &IndexManager{}
+	read := 0
+	start := time.Now()
+	err := m.buildFrom(ctx, endlessDocuments(&read, time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the build to stop at the timeout, but it took %v", elapsed)
+	}
+	if m.HasIndex() {
+		t.Errorf("Expected no index after a timed out build")
+	}
+}
+
+func TestWithTimeout_KeepsEarlierDeadline(t *testing.T) {
+	t.Setenv("INDEX_LOAD_TIMEOUT", "1h")
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelParent()
+
+	ctx, cancel := withTimeout(parent, "INDEX_LOAD_TIMEOUT", defaultLoadTimeout)
+	defer cancel()
+	parentDeadline, _ := parent.Deadline()
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(parentDeadline) {
+		t.Errorf("Expected the caller's deadline %v, but got %v", parentDeadline, deadline)
+	}
+
+	t.Setenv("INDEX_LOAD_TIMEOUT", "invalid")
+	if got := envDuration("INDEX_LOAD_TIMEOUT", defaultLoadTimeout); got != defaultLoadTimeout {
+		t.Errorf("Expected the default of %v for an invalid value, but got %v", defaultLoadTimeout, got)
+	}
+}
+
+func TestLoadSnapshot_CanceledRemovesTempFile(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	store := newMemoryStore()
+	saved := newTestManager(t, 3)
+	if err := saved.Add("asset-a", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if _, err := saved.SaveSnapshot(context.Background(), store, 5); err != nil {
+		t.Fatalf("SaveSnapshot() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := &IndexManager{}
+	if err := m.LoadSnapshot(ctx, store); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, but got %v", err)
+	}
+	if m.HasIndex() {
+		t.Errorf("Expected no index after a canceled load")
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected temporary files to be removed, but found %d", len(entries))
+	}
+}
@@ -0,0 +1,45 @@
+package index
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VectorSource reads the stored embedding of an indexed asset whose vector is not
+// cached in memory, such as one indexed by a loaded snapshot
+type VectorSource interface {
+	Vector(ctx context.Context, assetID string) ([]float32, error)
+}
+
+// FirestoreVectors reads embeddings from the asset documents of a Firestore collection
+type FirestoreVectors struct {
+	ProjectID  string
+	Collection string
+}
+
+// Vector returns the embedding stored on the asset's document, prepared for the
+// configured metric like the vectors in the index
+func (s FirestoreVectors) Vector(ctx context.Context, assetID string) ([]float32, error) {
+	client, err := firestore.NewClient(ctx, s.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	doc, err := client.Collection(s.Collection).Doc(assetID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("%w: %s", ErrAssetNotIndexed, assetID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset %s: %v", assetID, err)
+	}
+	vector, err := documentEmbedding(doc.Data(), embeddingEncoding(), EmbeddingDimension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding of asset %s: %v", assetID, err)
+	}
+	return PrepareVector(vector), nil
+}
@@ -0,0 +1,85 @@
+package leaf
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+)
+
+// Supported hash algorithms, named as in the HashAlgorithm of a Trillian tree
+const (
+	SHA256 = "SHA256"
+	SHA384 = "SHA384"
+	SHA512 = "SHA512"
+)
+
+// Hasher computes leaf values and RFC 6962 Merkle hashes with the hash algorithm of
+// a Trillian tree. The tree hashes leaves and nodes with its HashAlgorithm, so the
+// worker must digest certificates and verifiers must recompute leaves with the same
+// one for proofs to verify. The zero Hasher uses SHA-256, the RFC 6962 default.
+type Hasher struct {
+	algorithm string
+	newHash   func() hash.Hash
+}
+
+// NewHasher returns the Hasher for a hash algorithm. Names are case-insensitive and
+// may include a dash, as in SHA-256; an empty name selects SHA256.
+func NewHasher(algorithm string) (Hasher, error) {
+	switch strings.ReplaceAll(strings.ToUpper(algorithm), "-", "") {
+	case "", SHA256:
+		return Hasher{algorithm: SHA256, newHash: sha256.New}, nil
+	case SHA384:
+		return Hasher{algorithm: SHA384, newHash: sha512.New384}, nil
+	case SHA512:
+		return Hasher{algorithm: SHA512, newHash: sha512.New}, nil
+	default:
+		return Hasher{}, fmt.Errorf("unknown hash algorithm %q: expected %s, %s or %s", algorithm, SHA256, SHA384, SHA512)
+	}
+}
+
+// HasherFromEnv returns the Hasher for TRILLIAN_HASH_ALGORITHM, which must match the
+// HashAlgorithm the Trillian tree was created with, defaulting to SHA256
+func HasherFromEnv() (Hasher, error) {
+	hasher, err := NewHasher(os.Getenv("TRILLIAN_HASH_ALGORITHM"))
+	if err != nil {
+		return Hasher{}, fmt.Errorf("invalid TRILLIAN_HASH_ALGORITHM: %v", err)
+	}
+	return hasher, nil
+}
+
+// Algorithm returns the name of the hash algorithm
+func (h Hasher) Algorithm() string {
+	if h.algorithm == "" {
+		return SHA256
+	}
+	return h.algorithm
+}
+
+// Size returns the length in bytes of the hashes and digests the Hasher produces
+func (h Hasher) Size() int {
+	return h.hash().Size()
+}
+
+// Digest returns the plain hash of data, as stored in FormatHash leaves
+func (h Hasher) Digest(data []byte) []byte {
+	return h.sum(data)
+}
+
+func (h Hasher) hash() hash.Hash {
+	if h.newHash == nil {
+		return sha256.New()
+	}
+	return h.newHash()
+}
+
+// sum hashes the concatenation of parts
+func (h Hasher) sum(parts ...[]byte) []byte {
+	digest := h.hash()
+	for _, part := range parts {
+		digest.Write(part)
+	}
+	return digest.Sum(nil)
+}
@@ -0,0 +1,97 @@
+package leaf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewHasher(t *testing.T) {
+	testCases := []struct {
+		name         string
+		expected     string
+		expectedSize int
+		expectError  bool
+	}{
+		{name: "", expected: SHA256, expectedSize: 32},
+		{name: "SHA256", expected: SHA256, expectedSize: 32},
+		{name: "sha-384", expected: SHA384, expectedSize: 48},
+		{name: "SHA512", expected: SHA512, expectedSize: 64},
+		{name: "MD5", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hasher, err := NewHasher(tc.name)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error for %q, but got nil", tc.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewHasher() failed: %v", err)
+			}
+			if hasher.Algorithm() != tc.expected {
+				t.Errorf("Expected algorithm %s, but got %s", tc.expected, hasher.Algorithm())
+			}
+			if hasher.Size() != tc.expectedSize || len(hasher.HashLeaf([]byte("leaf"))) != tc.expectedSize {
+				t.Errorf("Expected %d-byte hashes, but got %d", tc.expectedSize, hasher.Size())
+			}
+		})
+	}
+}
+
+func TestHasher_LeafAndProofAgree(t *testing.T) {
+	certificateJSON := []byte("{\n  \"issuer\": \"https://proofpix.com\"\n}")
+
+	for _, algorithm := range []string{SHA256, SHA384, SHA512} {
+		t.Run(algorithm, func(t *testing.T) {
+			hasher, err := NewHasher(algorithm)
+			if err != nil {
+				t.Fatalf("NewHasher() failed: %v", err)
+			}
+
+			// Worker side: the leaf queued at index 1 of a three-leaf tree
+			queued, err := hasher.Value(FormatHash, certificateJSON)
+			if err != nil {
+				t.Fatalf("Value() failed: %v", err)
+			}
+			h0, h1, h2 := hasher.HashLeaf([]byte("a")), hasher.HashLeaf(queued), hasher.HashLeaf([]byte("c"))
+			root := hasher.HashChildren(hasher.HashChildren(h0, h1), h2)
+			proof := [][]byte{h0, h2}
+
+			// Verify side: rebuild the leaf from the stored certificate
+			rebuilt, err := hasher.Value(FormatHash, certificateJSON)
+			if err != nil {
+				t.Fatalf("Value() failed: %v", err)
+			}
+			if !bytes.Equal(hasher.HashLeaf(rebuilt), h1) {
+				t.Errorf("Expected the rebuilt leaf hash to equal the queued one")
+			}
+			if err := hasher.VerifyInclusion(1, 3, hasher.HashLeaf(rebuilt), proof, root); err != nil {
+				t.Errorf("Expected the proof to verify, but got %v", err)
+			}
+
+			// A verifier hashing with another algorithm cannot reproduce the tree
+			if algorithm != SHA256 {
+				other := Hasher{}
+				otherLeaf, _ := other.Value(FormatHash, certificateJSON)
+				if err := other.VerifyInclusion(1, 3, other.HashLeaf(otherLeaf), proof, root); !errors.Is(err, ErrInclusionProofMismatch) {
+					t.Errorf("Expected a mismatch when verifying with SHA256, but got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHasherFromEnv(t *testing.T) {
+	t.Setenv("TRILLIAN_HASH_ALGORITHM", "SHA384")
+	if hasher, err := HasherFromEnv(); err != nil || hasher.Algorithm() != SHA384 {
+		t.Errorf("Expected %s, but got %s (%v)", SHA384, hasher.Algorithm(), err)
+	}
+	t.Setenv("TRILLIAN_HASH_ALGORITHM", "BLAKE2")
+	if _, err := HasherFromEnv(); err == nil {
+		t.Errorf("Expected an error for an unknown algorithm")
+	}
+}
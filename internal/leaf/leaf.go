@@ -0,0 +1,78 @@
+// Package leaf defines how certificates are encoded as Trillian log leaves.
+//
+// Two formats are supported:
+//
+//   - FormatHash (default) stores the 32-byte SHA-256 of the stored certificate
+//     JSON. Leaves are small and fixed size, but the log alone reveals nothing:
+//     checking a leaf requires fetching the certificate from storage.
+//   - FormatCertificate stores the full certificate as compact JSON (typically
+//     1-2 KB). The log grows accordingly, but every leaf can be inspected and
+//     audited directly from the log without access to ProofPix storage.
+//
+// Leaf digests and Merkle hashes use the hash algorithm of the Trillian tree,
+// configured in TRILLIAN_HASH_ALGORITHM (see Hasher).
+package leaf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Supported leaf formats
+const (
+	FormatHash        = "hash"
+	FormatCertificate = "certificate"
+)
+
+// FormatFromEnv returns the leaf format configured in TRILLIAN_LEAF_FORMAT, defaulting to FormatHash
+func FormatFromEnv() (string, error) {
+	format := os.Getenv("TRILLIAN_LEAF_FORMAT")
+	if format == "" {
+		return FormatHash, nil
+	}
+	if format != FormatHash && format != FormatCertificate {
+		return "", fmt.Errorf("unknown TRILLIAN_LEAF_FORMAT %q: expected %s or %s", format, FormatHash, FormatCertificate)
+	}
+	return format, nil
+}
+
+// Value encodes stored certificate JSON as a leaf value in the given format, with
+// FormatHash leaves holding its SHA-256
+func Value(format string, certificateJSON []byte) ([]byte, error) {
+	return Hasher{}.Value(format, certificateJSON)
+}
+
+// Matches reports whether a leaf value read from the log corresponds to the stored
+// certificate, with FormatHash leaves holding its SHA-256
+func Matches(format string, certificateJSON, leafValue []byte) (bool, error) {
+	return Hasher{}.Matches(format, certificateJSON, leafValue)
+}
+
+// Value encodes stored certificate JSON as a leaf value in the given format.
+// An empty format is treated as FormatHash, which all assets logged before
+// formats were configurable use.
+func (h Hasher) Value(format string, certificateJSON []byte) ([]byte, error) {
+	switch format {
+	case "", FormatHash:
+		return h.Digest(certificateJSON), nil
+	case FormatCertificate:
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, certificateJSON); err != nil {
+			return nil, fmt.Errorf("failed to canonicalize certificate: %v", err)
+		}
+		return compact.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown leaf format %q", format)
+	}
+}
+
+// Matches reports whether a leaf value read from the log corresponds to the stored certificate
+func (h Hasher) Matches(format string, certificateJSON, leafValue []byte) (bool, error) {
+	expected, err := h.Value(format, certificateJSON)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(expected, leafValue), nil
+}
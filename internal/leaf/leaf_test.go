@@ -0,0 +1,89 @@
+package leaf
+
+import (
+	"testing"
+)
+
+// mockLog is an append-only in-memory stand-in for a Trillian log
+type mockLog struct {
+	leaves [][]byte
+}
+
+func (l *mockLog) queue(value []byte) int64 {
+	l.leaves = append(l.leaves, append([]byte(nil), value...))
+	return int64(len(l.leaves) - 1)
+}
+
+func TestLeafFormats_RoundTrip(t *testing.T) {
+	certificateJSON := []byte("{\n  \"issuer\": \"https://proofpix.com\",\n  \"proof\": {\n    \"proofValue\": \"abc\"\n  }\n}")
+	tamperedJSON := []byte("{\n  \"issuer\": \"https://proofpix.com\",\n  \"proof\": {\n    \"proofValue\": \"xyz\"\n  }\n}")
+
+	testCases := []struct {
+		name           string
+		format         string
+		expectedLength int
+	}{
+		{name: "Hash format", format: FormatHash, expectedLength: 32},
+		{name: "Legacy empty format", format: "", expectedLength: 32},
+		{name: "Certificate format", format: FormatCertificate, expectedLength: len(`{"issuer":"https://proofpix.com","proof":{"proofValue":"abc"}}`)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			log := &mockLog{}
+
+			// Worker side: encode and queue the leaf
+			value, err := Value(tc.format, certificateJSON)
+			if err != nil {
+				t.Fatalf("Value() failed: %v", err)
+			}
+			if len(value) != tc.expectedLength {
+				t.Errorf("Expected leaf of %d bytes, but got %d", tc.expectedLength, len(value))
+			}
+			index := log.queue(value)
+
+			// Verify side: compare the logged leaf to the stored certificate
+			matched, err := Matches(tc.format, certificateJSON, log.leaves[index])
+			if err != nil {
+				t.Fatalf("Matches() failed: %v", err)
+			}
+			if !matched {
+				t.Errorf("Expected stored certificate to match its leaf")
+			}
+
+			matched, err = Matches(tc.format, tamperedJSON, log.leaves[index])
+			if err != nil {
+				t.Fatalf("Matches() failed: %v", err)
+			}
+			if matched {
+				t.Errorf("Expected tampered certificate not to match its leaf")
+			}
+		})
+	}
+}
+
+func TestFormatFromEnv(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expected    string
+		expectError bool
+	}{
+		{value: "", expected: FormatHash},
+		{value: "hash", expected: FormatHash},
+		{value: "certificate", expected: FormatCertificate},
+		{value: "base64", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv("TRILLIAN_LEAF_FORMAT", tc.value)
+			format, err := FormatFromEnv()
+			if tc.expectError && err == nil {
+				t.Errorf("Expected an error for %q, but got nil", tc.value)
+			}
+			if !tc.expectError && format != tc.expected {
+				t.Errorf("Expected format %q, but got %q", tc.expected, format)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package leaf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrInclusionProofMismatch is returned when an inclusion proof does not lead to the expected root
+var ErrInclusionProofMismatch = errors.New("inclusion proof does not match root hash")
+
+// RFC 6962 domain separation prefixes for leaf and interior node hashes
+const (
+	leafHashPrefix = 0x00
+	nodeHashPrefix = 0x01
+)
+
+// HashLeaf returns the RFC 6962 Merkle leaf hash of a leaf value, as computed by Trillian
+// for a SHA-256 tree
+func HashLeaf(leafValue []byte) []byte {
+	return Hasher{}.HashLeaf(leafValue)
+}
+
+// HashChildren returns the RFC 6962 hash of an interior node of a SHA-256 tree
+func HashChildren(left, right []byte) []byte {
+	return Hasher{}.HashChildren(left, right)
+}
+
+// VerifyInclusion checks an inclusion proof in a SHA-256 tree; see Hasher.VerifyInclusion
+func VerifyInclusion(leafIndex, treeSize int64, leafHash []byte, proof [][]byte, rootHash []byte) error {
+	return Hasher{}.VerifyInclusion(leafIndex, treeSize, leafHash, proof, rootHash)
+}
+
+// RootFromInclusionProof recomputes the root of a SHA-256 tree; see Hasher.RootFromInclusionProof
+func RootFromInclusionProof(leafIndex, treeSize int64, leafHash []byte, proof [][]byte) ([]byte, error) {
+	return Hasher{}.RootFromInclusionProof(leafIndex, treeSize, leafHash, proof)
+}
+
+// HashLeaf returns the RFC 6962 Merkle leaf hash of a leaf value, as computed by Trillian
+func (h Hasher) HashLeaf(leafValue []byte) []byte {
+	return h.sum([]byte{leafHashPrefix}, leafValue)
+}
+
+// HashChildren returns the RFC 6962 hash of an interior node
+func (h Hasher) HashChildren(left, right []byte) []byte {
+	return h.sum([]byte{nodeHashPrefix}, left, right)
+}
+
+// VerifyInclusion checks that leafHash sits at leafIndex in a tree of treeSize leaves with
+// the given root hash, following the algorithm in RFC 9162 section 2.1.3.2.
+func (h Hasher) VerifyInclusion(leafIndex, treeSize int64, leafHash []byte, proof [][]byte, rootHash []byte) error {
+	computed, err := h.RootFromInclusionProof(leafIndex, treeSize, leafHash, proof)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(computed, rootHash) {
+		return ErrInclusionProofMismatch
+	}
+	return nil
+}
+
+// RootFromInclusionProof recomputes the root hash implied by an inclusion proof, so
+// callers can report it alongside the expected root when the two differ
+func (h Hasher) RootFromInclusionProof(leafIndex, treeSize int64, leafHash []byte, proof [][]byte) ([]byte, error) {
+	if leafIndex < 0 || leafIndex >= treeSize {
+		return nil, fmt.Errorf("leaf index %d is outside tree of size %d", leafIndex, treeSize)
+	}
+
+	fn, sn := leafIndex, treeSize-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return nil, fmt.Errorf("%w: proof is too long", ErrInclusionProofMismatch)
+		}
+		if fn&1 == 1 || fn == sn {
+			r = h.HashChildren(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = h.HashChildren(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return nil, fmt.Errorf("%w: proof is too short", ErrInclusionProofMismatch)
+	}
+	return r, nil
+}
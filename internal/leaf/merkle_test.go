@@ -0,0 +1,80 @@
+package leaf
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// treeHash computes the RFC 6962 Merkle tree hash of leaf hashes
+func treeHash(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	case 1:
+		return leaves[0]
+	}
+	k := splitPoint(len(leaves))
+	return HashChildren(treeHash(leaves[:k]), treeHash(leaves[k:]))
+}
+
+// auditPath computes the RFC 6962 inclusion proof for leaf m
+func auditPath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := splitPoint(len(leaves))
+	if m < k {
+		return append(auditPath(m, leaves[:k]), treeHash(leaves[k:]))
+	}
+	return append(auditPath(m-k, leaves[k:]), treeHash(leaves[:k]))
+}
+
+// splitPoint returns the largest power of two smaller than n
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = HashLeaf([]byte(fmt.Sprintf("leaf-%d", i)))
+		}
+		root := treeHash(leaves)
+
+		for index := 0; index < size; index++ {
+			proof := auditPath(index, leaves)
+			if err := VerifyInclusion(int64(index), int64(size), leaves[index], proof, root); err != nil {
+				t.Errorf("Expected leaf %d of %d to verify, but got %v", index, size, err)
+			}
+
+			// A different leaf must not verify with the same proof
+			if err := VerifyInclusion(int64(index), int64(size), HashLeaf([]byte("other")), proof, root); !errors.Is(err, ErrInclusionProofMismatch) {
+				t.Errorf("Expected mismatch for forged leaf %d of %d, but got %v", index, size, err)
+			}
+		}
+	}
+}
+
+func TestVerifyInclusion_InvalidInputs(t *testing.T) {
+	leaves := [][]byte{HashLeaf([]byte("a")), HashLeaf([]byte("b")), HashLeaf([]byte("c"))}
+	root := treeHash(leaves)
+	proof := auditPath(1, leaves)
+
+	if err := VerifyInclusion(3, 3, leaves[0], proof, root); err == nil {
+		t.Errorf("Expected an error for an out of range index")
+	}
+	if err := VerifyInclusion(1, 3, leaves[1], proof[:1], root); !errors.Is(err, ErrInclusionProofMismatch) {
+		t.Errorf("Expected mismatch for a truncated proof, but got %v", err)
+	}
+	if err := VerifyInclusion(1, 3, leaves[1], append(proof, root), root); !errors.Is(err, ErrInclusionProofMismatch) {
+		t.Errorf("Expected mismatch for an extended proof, but got %v", err)
+	}
+}
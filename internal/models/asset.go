@@ -1,15 +1,270 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"proofpix/internal/float16"
+)
+
+// StatusAwaitingUpload marks an asset whose upload URL was issued but whose image
+// has not been processed yet
+const StatusAwaitingUpload = "awaiting_upload"
+
+// StatusProcessing marks an asset the worker has started processing
+const StatusProcessing = "processing"
+
+// StatusCompleted marks an asset whose analysis and embedding both succeeded
+const StatusCompleted = "completed"
+
+// StatusPartial marks an asset saved with only some of its results. AnalysisFailed
+// and EmbeddingFailed record which stage is missing so a retry can fill it in.
+const StatusPartial = "partial"
+
+// StatusQuotaExceeded marks an asset that was not analyzed because its owner's
+// monthly analysis budget was exhausted
+const StatusQuotaExceeded = "quota_exceeded"
+
+// StatusDeleted marks an asset as soft-deleted. Its artifacts are retained until
+// the retention period has elapsed, after which the reaper purges them.
+const StatusDeleted = "deleted"
+
+// Asset visibilities. Public assets can be verified by anyone who knows their ID;
+// private (unlisted) assets only by their owner.
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// Embedding storage precisions. Float16 halves the size of the stored embedding at
+// a relative error of at most 2^-11 per value.
+const (
+	EmbeddingPrecisionFloat32 = "float32"
+	EmbeddingPrecisionFloat16 = "float16"
+)
+
+// Reasons a certified asset was not queued in the transparency log
+const (
+	// AnchoringSkippedByChoice means the uploader opted out of anchoring
+	AnchoringSkippedByChoice = "skipped_by_choice"
+	// AnchoringBelowMinScore means the originality score was below the worker's
+	// ANCHOR_MIN_SCORE
+	AnchoringBelowMinScore = "below_min_score"
+)
+
+// ErrInvalidVisibility is returned by ParseVisibility for an unknown visibility
+var ErrInvalidVisibility = errors.New("visibility must be public or private")
+
+// ErrRestoreWindowExpired is returned when restoring an asset after its retention period
+var ErrRestoreWindowExpired = errors.New("restore window has expired")
 
 // Asset represents a document in Firestore
 type Asset struct {
-	ID               string    `firestore:"id,omitempty"`
-	UserID           string    `firestore:"user_id"`
-	Status           string    `firestore:"status"`
-	CreatedAt        time.Time `firestore:"created_at"`
-	RawAnalysis      string    `firestore:"raw_analysis"`
-	OriginalityScore int       `firestore:"originality_score"`
-	Narrative        string    `firestore:"narrative"`
-	Embedding        []float32 `firestore:"embedding"`
-}
\ No newline at end of file
+	ID                 string    `firestore:"id,omitempty"`
+	UserID             string    `firestore:"user_id"`
+	Status             string    `firestore:"status"`
+	CreatedAt          time.Time `firestore:"created_at"`
+	RawAnalysis        string    `firestore:"raw_analysis"`
+	OriginalityScore   int       `firestore:"originality_score"`
+	Narrative          string    `firestore:"narrative"`
+	Embedding          []float32 `firestore:"embedding"`
+	TrillianLeafIndex  int64     `firestore:"trillian_leaf_index,omitempty"`
+	TrillianLeafFormat string    `firestore:"trillian_leaf_format,omitempty"`
+	DeletedAt          time.Time `firestore:"deleted_at,omitempty"`
+	StatusBeforeDelete string    `firestore:"status_before_delete,omitempty"`
+	AnalysisFailed     bool      `firestore:"analysis_failed,omitempty"`
+	EmbeddingFailed    bool      `firestore:"embedding_failed,omitempty"`
+	ModelVersion       string    `firestore:"model_version,omitempty"`
+	// CertificateUploadFailed and BadgeUploadFailed record that the worker could not
+	// store the certificate or badge in GCS even after retrying, so a backfill can
+	// regenerate them
+	CertificateUploadFailed bool `firestore:"certificate_upload_failed,omitempty"`
+	BadgeUploadFailed       bool `firestore:"badge_upload_failed,omitempty"`
+	// EmbeddingFloat16 holds the embedding as little-endian half-precision values
+	// in place of Embedding when it was stored at EmbeddingPrecisionFloat16
+	EmbeddingFloat16 []byte `firestore:"embedding_f16,omitempty"`
+	// EmbeddingModel is the Vertex model that produced Embedding, which differs
+	// from the primary model when a fallback was used
+	EmbeddingModel string `firestore:"embedding_model,omitempty"`
+	// ImageHash is the hex SHA-256 of the uploaded image bytes, used to find the
+	// assets certifying a given image
+	ImageHash string `firestore:"image_hash,omitempty"`
+	// ThumbnailURL is the public URL of a downscaled JPEG preview of the image,
+	// stored at thumbnails/{id}.jpg
+	ThumbnailURL string `firestore:"thumbnail_url,omitempty"`
+	// NarrativeLanguage is the BCP 47 tag of the language Narrative is written in,
+	// "und" when undetermined, recorded when NARRATIVE_LANGUAGE is tag or translate
+	NarrativeLanguage string `firestore:"narrative_language,omitempty"`
+	// NarrativeOriginal is the model's narrative before it was translated to
+	// English, written in NarrativeTranslatedFrom
+	NarrativeOriginal       string `firestore:"narrative_original,omitempty"`
+	NarrativeTranslatedFrom string `firestore:"narrative_translated_from,omitempty"`
+	// ShortCode is the human-friendly code resolving to the asset at /v/{code},
+	// reserved in the short_codes collection so no two assets share one
+	ShortCode string `firestore:"short_code,omitempty"`
+	// AnalysisWarning records why the stored analysis failed validation, when it
+	// was kept anyway (ANALYSIS_VALIDATION=warn)
+	AnalysisWarning string `firestore:"analysis_warning,omitempty"`
+	// SkipAnchoring records that the uploader chose not to queue the certificate in Trillian
+	SkipAnchoring bool `firestore:"skip_anchoring,omitempty"`
+	// BelowAnchorMinScore records that the certificate was not queued in Trillian
+	// because the originality score was below ANCHOR_MIN_SCORE
+	BelowAnchorMinScore bool `firestore:"below_anchor_min_score,omitempty"`
+	// Visibility is VisibilityPublic or VisibilityPrivate; assets saved before it
+	// existed have none and are public
+	Visibility string `firestore:"visibility,omitempty"`
+	// UploadBucket is the GCS bucket holding the original upload; assets saved
+	// before it existed were uploaded to the default bucket
+	UploadBucket string `firestore:"upload_bucket,omitempty"`
+	// RelatedAsset is the nearest existing asset when the similarity search found a
+	// likely duplicate at processing time
+	RelatedAsset *RelatedAsset `firestore:"related_asset,omitempty"`
+	// ProcessedBy is the WORKER_ID of the worker that processed the asset,
+	// identifying the instance or region behind its credential
+	ProcessedBy string `firestore:"processed_by,omitempty"`
+	// Frames holds the results of each keyframe analyzed for a video upload, in
+	// order; the asset's score and embedding aggregate them. Images have none.
+	Frames []FrameResult `firestore:"frames,omitempty"`
+	// Metadata holds key/value tags attached to the asset, such as campaign: spring
+	Metadata map[string]string `firestore:"metadata,omitempty"`
+	// CredentialHistory lists earlier credentials replaced by regeneration, oldest first
+	CredentialHistory []CredentialRevision `firestore:"credential_history,omitempty"`
+	// SkippedFields names stored fields that could not be decoded and were left
+	// empty. It is never persisted; an asset with skipped fields must not be saved
+	// back over its document.
+	SkippedFields []string `firestore:"-" json:"-"`
+}
+
+// RelatedAsset records a near match found by duplicate detection
+type RelatedAsset struct {
+	AssetID  string  `firestore:"asset_id"`
+	Distance float32 `firestore:"distance"`
+}
+
+// FrameResult records the analysis of one keyframe of a video upload
+type FrameResult struct {
+	// OffsetSeconds is the keyframe's position in the video
+	OffsetSeconds    float64 `firestore:"offset_seconds"`
+	OriginalityScore int     `firestore:"originality_score"`
+	Narrative        string  `firestore:"narrative,omitempty"`
+	AnalysisFailed   bool    `firestore:"analysis_failed,omitempty"`
+	EmbeddingFailed  bool    `firestore:"embedding_failed,omitempty"`
+}
+
+// CredentialRevision records a credential that was replaced by a regenerated one.
+// The replaced credential is kept at ObjectPath; the leaf fields name the log leaf
+// that anchored it, if any.
+type CredentialRevision struct {
+	ObjectPath         string    `firestore:"object_path"`
+	ReplacedAt         time.Time `firestore:"replaced_at"`
+	TrillianLeafIndex  int64     `firestore:"trillian_leaf_index,omitempty"`
+	TrillianLeafFormat string    `firestore:"trillian_leaf_format,omitempty"`
+}
+
+// IsPending reports whether the asset is awaiting its upload or being processed
+func (a *Asset) IsPending() bool {
+	return a.Status == StatusAwaitingUpload || a.Status == StatusProcessing
+}
+
+// IsPartial reports whether the asset is missing its analysis or embedding
+func (a *Asset) IsPartial() bool {
+	return a.Status == StatusPartial
+}
+
+// IsQuotaExceeded reports whether the asset was blocked by its owner's analysis budget
+func (a *Asset) IsQuotaExceeded() bool {
+	return a.Status == StatusQuotaExceeded
+}
+
+// AnchoringSkipped returns why the asset was certified without being queued in the
+// transparency log, AnchoringSkippedByChoice or AnchoringBelowMinScore, or "" when
+// it was queued or is expected to be
+func (a *Asset) AnchoringSkipped() string {
+	switch {
+	case a.TrillianLeafIndex != 0:
+		return ""
+	case a.SkipAnchoring:
+		return AnchoringSkippedByChoice
+	case a.BelowAnchorMinScore:
+		return AnchoringBelowMinScore
+	}
+	return ""
+}
+
+// SetEmbedding stores vector at the given precision, clearing whichever embedding
+// field the precision does not use
+func (a *Asset) SetEmbedding(vector []float32, precision string) {
+	a.Embedding, a.EmbeddingFloat16 = nil, nil
+	if len(vector) == 0 {
+		return
+	}
+	if precision == EmbeddingPrecisionFloat16 {
+		a.EmbeddingFloat16 = float16.Encode(vector)
+		return
+	}
+	a.Embedding = vector
+}
+
+// EmbeddingVector returns the stored embedding, dequantizing one stored at half
+// precision, or nil when the asset has none
+func (a *Asset) EmbeddingVector() []float32 {
+	if len(a.Embedding) > 0 || len(a.EmbeddingFloat16) == 0 {
+		return a.Embedding
+	}
+	vector, err := float16.Decode(a.EmbeddingFloat16)
+	if err != nil {
+		return nil
+	}
+	return vector
+}
+
+// IsPrivate reports whether only the asset's owner may verify it
+func (a *Asset) IsPrivate() bool {
+	return a.Visibility == VisibilityPrivate
+}
+
+// ParseVisibility validates a visibility, ignoring case. An empty value is
+// returned as is, for callers to apply their default.
+func ParseVisibility(value string) (string, error) {
+	switch visibility := strings.ToLower(strings.TrimSpace(value)); visibility {
+	case "", VisibilityPublic, VisibilityPrivate:
+		return visibility, nil
+	}
+	return "", fmt.Errorf("%w: got %q", ErrInvalidVisibility, value)
+}
+
+// IsDeleted reports whether the asset has been soft-deleted
+func (a *Asset) IsDeleted() bool {
+	return a.Status == StatusDeleted
+}
+
+// SoftDelete marks the asset as deleted, remembering its previous status for restore
+func (a *Asset) SoftDelete(now time.Time) {
+	if a.IsDeleted() {
+		return
+	}
+	a.StatusBeforeDelete = a.Status
+	a.Status = StatusDeleted
+	a.DeletedAt = now
+}
+
+// Restore undoes a soft delete if the asset is still within its retention period
+func (a *Asset) Restore(now time.Time, retention time.Duration) error {
+	if !a.IsDeleted() {
+		return nil
+	}
+	if a.PurgeEligible(now, retention) {
+		return ErrRestoreWindowExpired
+	}
+	a.Status = a.StatusBeforeDelete
+	a.StatusBeforeDelete = ""
+	a.DeletedAt = time.Time{}
+	return nil
+}
+
+// PurgeEligible reports whether a soft-deleted asset has outlived its retention period
+func (a *Asset) PurgeEligible(now time.Time, retention time.Duration) bool {
+	return a.IsDeleted() && !a.DeletedAt.IsZero() && !now.Before(a.DeletedAt.Add(retention))
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAssetSoftDeleteAndRestore(t *testing.T) {
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	retention := 7 * 24 * time.Hour
+
+	asset := &Asset{ID: "asset-1", Status: "completed"}
+	asset.SoftDelete(deletedAt)
+
+	if !asset.IsDeleted() {
+		t.Fatalf("Expected asset to be deleted, but status is %q", asset.Status)
+	}
+	if !asset.DeletedAt.Equal(deletedAt) {
+		t.Errorf("Expected DeletedAt %v, but got %v", deletedAt, asset.DeletedAt)
+	}
+
+	// Restoring inside the window brings back the original status
+	if err := asset.Restore(deletedAt.Add(24*time.Hour), retention); err != nil {
+		t.Fatalf("Restore() within the window failed: %v", err)
+	}
+	if asset.Status != "completed" || !asset.DeletedAt.IsZero() {
+		t.Errorf("Expected restored asset to be completed with no DeletedAt, but got %q / %v", asset.Status, asset.DeletedAt)
+	}
+}
+
+func TestAssetRestoreAfterWindow(t *testing.T) {
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	retention := 7 * 24 * time.Hour
+
+	asset := &Asset{ID: "asset-1", Status: "completed"}
+	asset.SoftDelete(deletedAt)
+
+	err := asset.Restore(deletedAt.Add(retention), retention)
+	if !errors.Is(err, ErrRestoreWindowExpired) {
+		t.Errorf("Expected ErrRestoreWindowExpired, but got %v", err)
+	}
+	if !asset.IsDeleted() {
+		t.Errorf("Expected asset to remain deleted after a failed restore")
+	}
+}
+
+func TestAssetPurgeEligible(t *testing.T) {
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	retention := 30 * 24 * time.Hour
+
+	testCases := []struct {
+		name     string
+		asset    Asset
+		now      time.Time
+		expected bool
+	}{
+		{
+			name:     "Not deleted",
+			asset:    Asset{Status: "completed"},
+			now:      deletedAt.Add(365 * 24 * time.Hour),
+			expected: false,
+		},
+		{
+			name:     "Deleted within retention",
+			asset:    Asset{Status: StatusDeleted, DeletedAt: deletedAt},
+			now:      deletedAt.Add(retention - time.Second),
+			expected: false,
+		},
+		{
+			name:     "Deleted exactly at retention",
+			asset:    Asset{Status: StatusDeleted, DeletedAt: deletedAt},
+			now:      deletedAt.Add(retention),
+			expected: true,
+		},
+		{
+			name:     "Deleted without timestamp",
+			asset:    Asset{Status: StatusDeleted},
+			now:      deletedAt,
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.asset.PurgeEligible(tc.now, retention); got != tc.expected {
+				t.Errorf("Expected PurgeEligible to be %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestAssetSetEmbedding(t *testing.T) {
+	vector := []float32{0.5, -0.25, 0.1}
+
+	testCases := []struct {
+		name          string
+		precision     string
+		expectFloat16 bool
+	}{
+		{name: "Default", precision: ""},
+		{name: "Float32", precision: EmbeddingPrecisionFloat32},
+		{name: "Float16", precision: EmbeddingPrecisionFloat16, expectFloat16: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset := &Asset{Embedding: []float32{9}, EmbeddingFloat16: []byte{1, 2}}
+			asset.SetEmbedding(vector, tc.precision)
+
+			if tc.expectFloat16 != (asset.Embedding == nil) || tc.expectFloat16 != (len(asset.EmbeddingFloat16) == 2*len(vector)) {
+				t.Fatalf("Expected float16 storage=%t, but got %v / %v", tc.expectFloat16, asset.Embedding, asset.EmbeddingFloat16)
+			}
+			got := asset.EmbeddingVector()
+			if len(got) != len(vector) {
+				t.Fatalf("Expected %d values, but got %v", len(vector), got)
+			}
+			for i, v := range vector {
+				if diff := got[i] - v; diff > 1e-3 || diff < -1e-3 {
+					t.Errorf("Expected value %d near %v, but got %v", i, v, got[i])
+				}
+			}
+		})
+	}
+
+	asset := &Asset{}
+	asset.SetEmbedding(nil, EmbeddingPrecisionFloat16)
+	if asset.EmbeddingVector() != nil || asset.EmbeddingFloat16 != nil {
+		t.Errorf("Expected no embedding, but got %+v", asset)
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Processing stages recorded in an asset's audit trail
+const (
+	StageDownloaded = "downloaded"
+	StageAnalyzed   = "analyzed"
+	StageEmbedded   = "embedded"
+	StageSaved      = "saved"
+	StageCertified  = "certified"
+	StageLogged     = "logged"
+	StageCompleted  = "completed"
+	StageFailed     = "failed"
+)
+
+// AssetEvent represents a single entry in an asset's processing audit trail.
+// Events are stored in the "events" subcollection of the asset document.
+type AssetEvent struct {
+	Stage     string    `firestore:"stage" json:"stage"`
+	Success   bool      `firestore:"success" json:"success"`
+	Detail    string    `firestore:"detail,omitempty" json:"detail,omitempty"`
+	Timestamp time.Time `firestore:"timestamp" json:"timestamp"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultRetentionDays is how long soft-deleted assets can be restored before they are purged
+const DefaultRetentionDays = 30
+
+// RetentionPeriod returns the soft-delete retention period from ASSET_RETENTION_DAYS.
+// The API uses it to decide whether an asset can still be restored and the worker's
+// reaper to decide when to purge it, so both must read the same setting.
+func RetentionPeriod() time.Duration {
+	days := DefaultRetentionDays
+	if value := os.Getenv("ASSET_RETENTION_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			days = parsed
+		} else {
+			log.Printf("Invalid ASSET_RETENTION_DAYS %q, using default of %d days", value, DefaultRetentionDays)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,69 @@
+// Package shortcode derives the short human-friendly codes printed on badges in
+// place of asset UUIDs, such as 7K3M-Q9TD, and normalizes codes typed back in.
+package shortcode
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Length is the number of characters in a code. 8 Crockford base32 characters
+// give 40 bits, so collisions are rare but possible and must be handled by
+// whoever stores the codes.
+const Length = 8
+
+// alphabet is Crockford's base32, which leaves out I, L, O and U so codes read
+// back from print are unambiguous
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var encoding = base32.NewEncoding(alphabet).WithPadding(base32.NoPadding)
+
+// ErrInvalid is returned by Normalize for text that cannot be a short code
+var ErrInvalid = errors.New("invalid short code")
+
+// Generate returns the candidate code for an asset. Attempt 0 is derived from the
+// asset ID alone; when that code already belongs to another asset, callers retry
+// with attempt 1, 2, ... which derive unrelated codes.
+func Generate(assetID string, attempt int) string {
+	input := assetID
+	if attempt > 0 {
+		input = fmt.Sprintf("%s:%d", assetID, attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+	return encoding.EncodeToString(sum[:])[:Length]
+}
+
+// Format splits a code into two dash-separated groups for display, e.g. 7K3M-Q9TD
+func Format(code string) string {
+	if len(code) != Length {
+		return code
+	}
+	return code[:Length/2] + "-" + code[Length/2:]
+}
+
+// Normalize turns a typed code into its canonical form: dashes and spaces are
+// dropped, letters upper-cased, and the look-alikes O, I and L read as 0, 1 and 1.
+func Normalize(code string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		switch r {
+		case '-', ' ':
+			continue
+		case 'O':
+			r = '0'
+		case 'I', 'L':
+			r = '1'
+		}
+		if !strings.ContainsRune(alphabet, r) {
+			return "", fmt.Errorf("%w: unexpected character %q", ErrInvalid, r)
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() != Length {
+		return "", fmt.Errorf("%w: expected %d characters, got %d", ErrInvalid, Length, b.Len())
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,74 @@
+package shortcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGenerate_Unique(t *testing.T) {
+	seen := map[string]string{}
+	for i := 0; i < 10000; i++ {
+		assetID := fmt.Sprintf("asset-%d", i)
+		code := Generate(assetID, 0)
+		if len(code) != Length {
+			t.Fatalf("Expected a %d-character code, but got %q", Length, code)
+		}
+		if normalized, err := Normalize(code); err != nil || normalized != code {
+			t.Fatalf("Expected %q to be canonical, but got %q (%v)", code, normalized, err)
+		}
+		if other, ok := seen[code]; ok {
+			t.Fatalf("Expected unique codes, but %s and %s both got %s", other, assetID, code)
+		}
+		seen[code] = assetID
+	}
+}
+
+func TestGenerate_Attempts(t *testing.T) {
+	if Generate("asset-1", 0) != Generate("asset-1", 0) {
+		t.Errorf("Expected the same code for the same asset and attempt")
+	}
+	codes := map[string]bool{}
+	for attempt := 0; attempt < 5; attempt++ {
+		codes[Generate("asset-1", attempt)] = true
+	}
+	if len(codes) != 5 {
+		t.Errorf("Expected a different code for each attempt, but got %d distinct", len(codes))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		invalid  bool
+	}{
+		{input: "7K3MQ9TD", expected: "7K3MQ9TD"},
+		{input: "7k3m-q9td", expected: "7K3MQ9TD"},
+		{input: "7K3M Q9TD", expected: "7K3MQ9TD"},
+		{input: "OIL0-1234", expected: "01101234"},
+		{input: "7K3MQ9T", invalid: true},
+		{input: "7K3MQ9TDX", invalid: true},
+		{input: "7K3MQ9TU", invalid: true},
+		{input: "7K3M/Q9TD", invalid: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			code, err := Normalize(tc.input)
+			if tc.invalid {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("Expected ErrInvalid, but got %q (%v)", code, err)
+				}
+				return
+			}
+			if err != nil || code != tc.expected {
+				t.Errorf("Expected %s, but got %q (%v)", tc.expected, code, err)
+			}
+		})
+	}
+
+	if formatted := Format("7K3MQ9TD"); formatted != "7K3M-Q9TD" {
+		t.Errorf("Expected 7K3M-Q9TD, but got %s", formatted)
+	}
+}
@@ -0,0 +1,55 @@
+// Package vertex creates Vertex AI clients with bounded request times.
+package vertex
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/api/aiplatform/v1"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// DefaultHTTPTimeout bounds each Vertex AI request when VERTEX_HTTP_TIMEOUT is not set.
+// Gemini analysis of a large image takes tens of seconds, so this leaves headroom
+// while still freeing the caller from a hung connection.
+const DefaultHTTPTimeout = 120 * time.Second
+
+// HTTPTimeoutFromEnv returns the Vertex AI request timeout in VERTEX_HTTP_TIMEOUT
+func HTTPTimeoutFromEnv() time.Duration {
+	value := os.Getenv("VERTEX_HTTP_TIMEOUT")
+	if value == "" {
+		return DefaultHTTPTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Printf("Invalid VERTEX_HTTP_TIMEOUT %q, using default of %v", value, DefaultHTTPTimeout)
+		return DefaultHTTPTimeout
+	}
+	return timeout
+}
+
+// HTTPClient returns an HTTP client authorized for Vertex AI whose requests time
+// out after VERTEX_HTTP_TIMEOUT. Extra options are passed to the transport.
+func HTTPClient(ctx context.Context, opts ...option.ClientOption) (*http.Client, error) {
+	opts = append([]option.ClientOption{option.WithScopes(aiplatform.CloudPlatformScope)}, opts...)
+	client, _, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vertex AI HTTP client: %v", err)
+	}
+	client.Timeout = HTTPTimeoutFromEnv()
+	return client, nil
+}
+
+// NewService creates an AI Platform service whose requests use HTTPClient
+func NewService(ctx context.Context) (*aiplatform.Service, error) {
+	client, err := HTTPClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return aiplatform.NewService(ctx, option.WithHTTPClient(client))
+}
@@ -0,0 +1,35 @@
+package vertex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+func TestHTTPClient_Timeout(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "Default", value: "", expected: DefaultHTTPTimeout},
+		{name: "Configured", value: "45s", expected: 45 * time.Second},
+		{name: "Invalid falls back", value: "soon", expected: DefaultHTTPTimeout},
+		{name: "Zero falls back", value: "0s", expected: DefaultHTTPTimeout},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("VERTEX_HTTP_TIMEOUT", tc.value)
+			client, err := HTTPClient(context.Background(), option.WithoutAuthentication())
+			if err != nil {
+				t.Fatalf("HTTPClient() failed: %v", err)
+			}
+			if client.Timeout != tc.expected {
+				t.Errorf("Expected timeout %v, but got %v", tc.expected, client.Timeout)
+			}
+		})
+	}
+}